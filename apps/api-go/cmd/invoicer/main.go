@@ -2,71 +2,259 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
+	"net"
 	"net/http"
 	"os"
+	"os/signal"
 	"path/filepath"
-	"strings"
+	"sync/atomic"
+	"syscall"
 	"time"
 
 	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/api"
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/auth"
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/buildinfo"
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/config"
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/logging"
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/notify"
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/payments"
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/pdf"
 	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/runtime"
 	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/storage"
 )
 
 func main() {
-	addr := ":8080"
-	databaseURL := strings.TrimSpace(os.Getenv("DATABASE_URL"))
-	if databaseURL == "" {
-		log.Fatal("DATABASE_URL is required")
+	cfg, err := config.Load()
+	if err != nil {
+		fatal("invalid configuration", err)
+	}
+	logger := logging.New(os.Stdout, cfg.LogLevel)
+	slog.SetDefault(logger)
+
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := runMigrate(cfg, os.Args[2:], os.Stdout); err != nil {
+			fatal("migrate", err)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "purge" {
+		if err := runPurge(cfg, logger, os.Args[2:], os.Stdout); err != nil {
+			fatal("purge", err)
+		}
+		return
 	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
-	store, err := storage.Open(ctx, databaseURL)
+	store, err := storage.OpenWithPool(ctx, cfg.DatabaseURL, poolConfig(cfg))
 	if err != nil {
-		log.Fatalf("open postgres store: %v", err)
+		fatal("open postgres store", err)
 	}
 	defer store.Close()
 
 	if err := store.Migrate(ctx); err != nil {
-		log.Fatalf("migrate postgres store: %v", err)
+		fatal("migrate postgres store", err)
 	}
 
-	workspaceRoot, err := findWorkspaceRoot()
+	workspaceRoot, err := findWorkspaceRoot(cfg.WorkspaceRoot)
 	if err != nil {
-		log.Fatalf("resolve workspace root: %v", err)
+		fatal("resolve workspace root", err)
 	}
 	runner := runtime.NewSubprocessCollectionRunner(runtime.CollectionRunnerConfig{
 		WorkspaceRoot:       workspaceRoot,
 		WorkerPythonPath:    filepath.Join(workspaceRoot, "apps", "workers-py", "src"),
-		FilesDir:            strings.TrimSpace(os.Getenv("FILES_DIR")),
-		GraphClientID:       strings.TrimSpace(os.Getenv("GRAPH_CLIENT_ID")),
-		GraphAuthority:      strings.TrimSpace(os.Getenv("GRAPH_AUTHORITY")),
-		GraphTokenCachePath: strings.TrimSpace(os.Getenv("GRAPH_TOKEN_CACHE_PATH")),
-		MonthlyGmailArgs:    strings.TrimSpace(os.Getenv("MONTHLY_GMAIL_ARGS")),
-		MonthlyGraphArgs:    strings.TrimSpace(os.Getenv("MONTHLY_GRAPH_ARGS")),
+		FilesDir:            cfg.FilesDir,
+		GraphClientID:       cfg.GraphClientID,
+		GraphAuthority:      cfg.GraphAuthority,
+		GraphTokenCachePath: cfg.GraphTokenCachePath,
+		MonthlyGmailArgs:    cfg.MonthlyGmailArgs,
+		MonthlyGraphArgs:    cfg.MonthlyGraphArgs,
 	})
 	reportRunner := runtime.NewSubprocessReportRunner(runtime.ReportRunnerConfig{
 		WorkspaceRoot:    workspaceRoot,
 		WorkerPythonPath: filepath.Join(workspaceRoot, "apps", "workers-py", "src"),
-		FilesDir:         strings.TrimSpace(os.Getenv("FILES_DIR")),
+		FilesDir:         cfg.FilesDir,
 	})
 
+	var verifier *auth.Verifier
+	if cfg.JWTPublicKeyFile != "" {
+		pemBytes, err := os.ReadFile(cfg.JWTPublicKeyFile)
+		if err != nil {
+			fatal("read JWT_PUBLIC_KEY_FILE", err)
+		}
+		key, err := auth.ParsePublicKeyPEM(pemBytes)
+		if err != nil {
+			fatal("parse JWT_PUBLIC_KEY_FILE", err)
+		}
+		verifier = auth.NewVerifier(key, cfg.JWTLeeway)
+	}
+
+	var emailSender notify.EmailSender = notify.NoopSender{}
+	if cfg.SMTPAddr != "" {
+		emailSender = notify.NewSMTPSender(cfg.SMTPAddr, cfg.SMTPFrom, cfg.SMTPUsername, cfg.SMTPPassword)
+	} else {
+		logger.Warn("SMTP_ADDR is not set; dunning reminder emails will be dropped")
+	}
+
+	objectStore, err := openObjectStore(cfg)
+	if err != nil {
+		fatal("open attachment object store", err)
+	}
+
+	var paymentProvider payments.Provider
+	if cfg.StripeSecretKey != "" {
+		paymentProvider, err = payments.NewStripe(payments.StripeConfig{
+			SecretKey:     cfg.StripeSecretKey,
+			WebhookSecret: cfg.StripeWebhookSecret,
+		})
+		if err != nil {
+			fatal("configure stripe", err)
+		}
+	}
+
 	server := api.NewServer(
 		api.WithStore(store),
 		api.WithCollectionRunner(runner),
 		api.WithReportRunner(reportRunner),
+		api.WithPDFRenderer(pdf.NewRenderer(pdf.Config{
+			CompanyName: cfg.PDFCompanyName,
+		})),
+		api.WithReadinessChecks(
+			api.NewReadinessCheck("postgres", store.Ping),
+			api.NewReadinessCheck("object_store", objectStore.Ping),
+		),
+		api.WithLogger(logger),
+		api.WithTokenVerifier(verifier),
+		api.WithRateLimit(cfg.RateLimitRPS, cfg.RateLimitBurst),
+		api.WithMaxBodyBytes(cfg.MaxBodyBytes),
+		api.WithCORSOrigins(cfg.CORSAllowedOrigins...),
+		api.WithEmailSender(emailSender),
+		api.WithDunningSchedule(cfg.DunningSchedule...),
+		api.WithObjectStore(objectStore),
+		api.WithAttachmentLimits(cfg.AttachmentMaxBytes, cfg.AttachmentContentTypes...),
+		api.WithSummaryCacheTTL(cfg.SummaryCacheTTL),
+		api.WithRequestTimeout(cfg.RequestTimeout),
+		api.WithPoolStats(store.PoolStats),
+		api.WithInvoiceNumberFormat(cfg.InvoiceNumbers),
+		api.WithPaymentProvider(paymentProvider),
+		api.WithShareLinks([]byte(cfg.ShareLinkSecret), cfg.PublicBaseURL),
+		api.WithRetention(cfg.DeletedRetention, cfg.AuditRetention),
 	)
-	log.Printf("api-go listening on %s", addr)
-	log.Fatal(http.ListenAndServe(addr, server.Handler()))
+
+	var conns connCounter
+	httpServer := &http.Server{
+		Addr:      cfg.ListenAddr,
+		Handler:   server.Handler(),
+		ConnState: conns.track,
+		ErrorLog:  slog.NewLogLogger(logger.Handler(), slog.LevelWarn),
+	}
+
+	signalCtx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	go server.RunWebhookWorker(signalCtx, cfg.WebhookPollInterval)
+	go server.RunOverdueScheduler(signalCtx, cfg.OverdueScanInterval)
+	go server.RunRecurringScheduler(signalCtx, cfg.RecurringScanInterval)
+	go server.RunDunningScheduler(signalCtx, cfg.DunningScanInterval)
+	go server.RunPurgeScheduler(signalCtx, cfg.PurgeInterval)
+
+	serveErr := make(chan error, 1)
+	go func() {
+		logger.Info("api-go listening", "addr", cfg.ListenAddr, "version", buildinfo.Version, "commit", buildinfo.Revision())
+		serveErr <- httpServer.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serveErr:
+		if !errors.Is(err, http.ErrServerClosed) {
+			fatal("serve http", err)
+		}
+		return
+	case <-signalCtx.Done():
+	}
+	stop()
+
+	logger.Info("shutdown signal received, draining connections", "timeout", cfg.ShutdownTimeout.String())
+	server.BeginShutdown()
+
+	shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancelShutdown()
+	if err := httpServer.Shutdown(shutdownCtx); err != nil {
+		logger.Warn("graceful shutdown timed out, closing remaining connections", "error", err, "open_connections", conns.active())
+		if err := httpServer.Close(); err != nil {
+			logger.Error("force close http server", "error", err)
+		}
+		return
+	}
+	if err := server.WaitForBackground(shutdownCtx); err != nil {
+		logger.Warn("background jobs still running at shutdown", "error", err)
+	}
+	logger.Info("api-go stopped")
+}
+
+// fatal logs err and exits, like log.Fatal but through the JSON logger.
+func fatal(msg string, err error) {
+	slog.Error(msg, "error", err)
+	os.Exit(1)
+}
+
+// pingableObjectStore is an attachment store /readyz can probe.
+type pingableObjectStore interface {
+	api.ObjectStore
+	Ping(ctx context.Context) error
+}
+
+// openObjectStore opens the S3 bucket attachments are kept in, or the
+// directory when no bucket is configured.
+func openObjectStore(cfg *config.Config) (pingableObjectStore, error) {
+	if cfg.S3Bucket != "" {
+		return storage.NewS3ObjectStore(storage.S3Config{
+			Endpoint:        cfg.S3Endpoint,
+			Region:          cfg.S3Region,
+			Bucket:          cfg.S3Bucket,
+			AccessKeyID:     cfg.S3AccessKeyID,
+			SecretAccessKey: cfg.S3SecretAccessKey,
+		})
+	}
+	return storage.NewFilesystemObjectStore(cfg.AttachmentDir)
+}
+
+// poolConfig sizes the Postgres pool from the DB_* settings.
+func poolConfig(cfg *config.Config) storage.PoolConfig {
+	return storage.PoolConfig{
+		MaxConns:        int32(cfg.DBMaxConns),
+		MinIdleConns:    int32(cfg.DBMinIdleConns),
+		MaxConnLifetime: cfg.DBConnMaxLifetime,
+		MaxConnIdleTime: cfg.DBConnMaxIdleTime,
+	}
+}
+
+// connCounter tracks open HTTP connections so a forced shutdown can report
+// how many were abandoned.
+type connCounter struct {
+	open atomic.Int64
+}
+
+func (c *connCounter) track(_ net.Conn, state http.ConnState) {
+	switch state {
+	case http.StateNew:
+		c.open.Add(1)
+	case http.StateHijacked, http.StateClosed:
+		c.open.Add(-1)
+	}
+}
+
+func (c *connCounter) active() int64 {
+	return c.open.Load()
 }
 
-func findWorkspaceRoot() (string, error) {
-	if root := strings.TrimSpace(os.Getenv("WORKSPACE_ROOT")); root != "" {
-		return root, nil
+func findWorkspaceRoot(configured string) (string, error) {
+	if configured != "" {
+		return configured, nil
 	}
 
 	wd, err := os.Getwd()
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os/signal"
+	"strconv"
+	"syscall"
+
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/config"
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/storage"
+)
+
+const migrateUsage = `usage: invoicer migrate <command>
+
+commands:
+  up         apply every pending migration
+  down N     revert the last N migrations
+  version    print the applied schema version
+  force V    record version V as applied after repairing a failed migration`
+
+// runMigrate serves `invoicer migrate`. The server applies pending
+// migrations itself on startup; this is for operators who need to step the
+// schema by hand.
+func runMigrate(cfg *config.Config, args []string, stdout io.Writer) error {
+	if len(args) == 0 {
+		return errors.New(migrateUsage)
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	store, err := storage.Open(ctx, cfg.DatabaseURL)
+	if err != nil {
+		return fmt.Errorf("open postgres store: %w", err)
+	}
+	defer store.Close()
+
+	switch {
+	case args[0] == "up" && len(args) == 1:
+		if err := store.Migrate(ctx); err != nil {
+			return err
+		}
+	case args[0] == "down" && len(args) == 2:
+		steps, err := strconv.Atoi(args[1])
+		if err != nil || steps <= 0 {
+			return fmt.Errorf("down takes a positive number of migrations, got %q", args[1])
+		}
+		if err := store.MigrateDown(ctx, steps); err != nil {
+			return err
+		}
+	case args[0] == "force" && len(args) == 2:
+		version, err := strconv.Atoi(args[1])
+		if err != nil || version < 0 {
+			return fmt.Errorf("force takes a schema version, got %q", args[1])
+		}
+		if err := store.ForceSchemaVersion(ctx, version); err != nil {
+			return err
+		}
+	case args[0] == "version" && len(args) == 1:
+	default:
+		return errors.New(migrateUsage)
+	}
+
+	version, dirty, err := store.SchemaVersion(ctx)
+	if err != nil {
+		return err
+	}
+	latest, err := storage.LatestSchemaVersion()
+	if err != nil {
+		return err
+	}
+	state := "clean"
+	if dirty {
+		state = "dirty"
+	}
+	_, err = fmt.Fprintf(stdout, "schema version %d (%s), binary knows up to %d\n", version, state, latest)
+	return err
+}
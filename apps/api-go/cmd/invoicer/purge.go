@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os/signal"
+	"syscall"
+
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/api"
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/config"
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/storage"
+)
+
+const purgeUsage = `usage: invoicer purge [--dry-run]
+
+Permanently removes invoices and customers soft-deleted more than
+DELETED_RETENTION_DAYS ago, their attachments, audit entries older
+than AUDIT_RETENTION_DAYS, and expired idempotency keys. --dry-run
+counts them instead.`
+
+// runPurge serves `invoicer purge`, the run the server makes every
+// PURGE_INTERVAL, for operators who need it now or want to see what it
+// would remove.
+func runPurge(cfg *config.Config, logger *slog.Logger, args []string, stdout io.Writer) error {
+	flags := flag.NewFlagSet("purge", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	dryRun := flags.Bool("dry-run", false, "")
+	if err := flags.Parse(args); err != nil || flags.NArg() > 0 {
+		return errors.New(purgeUsage)
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	store, err := storage.Open(ctx, cfg.DatabaseURL)
+	if err != nil {
+		return fmt.Errorf("open postgres store: %w", err)
+	}
+	defer store.Close()
+	objectStore, err := openObjectStore(cfg)
+	if err != nil {
+		return fmt.Errorf("open attachment object store: %w", err)
+	}
+	server := api.NewServer(
+		api.WithStore(store),
+		api.WithObjectStore(objectStore),
+		api.WithLogger(logger),
+		api.WithRetention(cfg.DeletedRetention, cfg.AuditRetention),
+	)
+
+	summary, err := server.PurgeDeletedRecords(ctx, *dryRun)
+	verb := "purged"
+	if *dryRun {
+		verb = "would purge"
+	}
+	logger.Info("purge finished", "dry_run", *dryRun, "invoices", summary.Invoices, "customers", summary.Customers,
+		"attachments", summary.Attachments, "audit_entries", summary.AuditEntries, "idempotency_keys", summary.IdempotencyKeys, "error", err)
+	if _, writeErr := fmt.Fprintf(stdout, "%s %d invoices, %d customers, %d attachments, %d audit entries and %d idempotency keys\n",
+		verb, summary.Invoices, summary.Customers, summary.Attachments, summary.AuditEntries, summary.IdempotencyKeys); err == nil {
+		err = writeErr
+	}
+	return err
+}
@@ -0,0 +1,114 @@
+package api
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const maxRequestIDLength = 128
+
+// withAccessLog logs one line per request at completion with the request's
+// context, so the line carries the request ID set by withMiddleware.
+func (s *Server) withAccessLog(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		started := time.Now()
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(recorder, r)
+
+		level := slog.LevelInfo
+		if recorder.status >= http.StatusInternalServerError {
+			level = slog.LevelError
+		}
+		attrs := []slog.Attr{
+			slog.String("method", r.Method),
+			slog.String("path", loggedPath(r.URL.Path)),
+			slog.Int("status", recorder.status),
+			slog.Float64("latency_ms", float64(time.Since(started).Microseconds())/1000),
+			slog.Int64("bytes", recorder.bytes),
+		}
+		if recorder.err != nil {
+			attrs = append(attrs, slog.String("error", recorder.err.Error()))
+		}
+		s.logger.LogAttrs(r.Context(), level, "http request", attrs...)
+	})
+}
+
+// loggedPath is path with the token of a share link masked, so the access
+// log holds no working links.
+func loggedPath(path string) string {
+	rest, ok := strings.CutPrefix(path, publicInvoicesPath)
+	if !ok {
+		return path
+	}
+	if _, action, found := strings.Cut(rest, "/"); found {
+		return publicInvoicesPath + "{token}/" + action
+	}
+	return publicInvoicesPath + "{token}"
+}
+
+// statusRecorder captures what the handler wrote for the access log.
+type statusRecorder struct {
+	http.ResponseWriter
+	status      int
+	bytes       int64
+	wroteHeader bool
+	err         error
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	if !r.wroteHeader {
+		r.status = status
+		r.wroteHeader = true
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(p []byte) (int, error) {
+	r.wroteHeader = true
+	n, err := r.ResponseWriter.Write(p)
+	r.bytes += int64(n)
+	return n, err
+}
+
+func (r *statusRecorder) Flush() {
+	if flusher, ok := r.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// recordRequestError attaches err to the access log line of the request
+// being served through w. It looks through every wrapping writer, since the
+// metrics middleware records the response too and sits inside the log.
+func recordRequestError(w http.ResponseWriter, err error) {
+	for w != nil {
+		if recorder, ok := w.(*statusRecorder); ok {
+			recorder.err = errors.Join(recorder.err, err)
+		}
+		unwrapper, ok := w.(interface{ Unwrap() http.ResponseWriter })
+		if !ok {
+			return
+		}
+		w = unwrapper.Unwrap()
+	}
+}
+
+// validRequestID accepts client-supplied correlation IDs that are short and
+// made of visible ASCII, so they are safe to echo and log.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < '!' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,75 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/logging"
+)
+
+func TestAccessLogCarriesRequestID(t *testing.T) {
+	var buf bytes.Buffer
+	server := NewServer(WithLogger(logging.New(&buf, nil)))
+
+	req := httptest.NewRequest(http.MethodGet, "/healthz", nil)
+	req.Header.Set("X-Request-ID", "client-req-1")
+	rec := httptest.NewRecorder()
+	server.Handler().ServeHTTP(rec, req)
+
+	if got := rec.Header().Get("X-Request-ID"); got != "client-req-1" {
+		t.Fatalf("expected incoming request ID to be echoed, got %q", got)
+	}
+	var line map[string]any
+	if err := json.Unmarshal(bytes.TrimSpace(buf.Bytes()), &line); err != nil {
+		t.Fatalf("decode access log %q: %v", buf.String(), err)
+	}
+	if line["msg"] != "http request" || line["request_id"] != "client-req-1" || line["method"] != "GET" ||
+		line["path"] != "/healthz" || line["status"] != float64(http.StatusOK) {
+		t.Fatalf("unexpected access log line: %v", line)
+	}
+	if _, ok := line["latency_ms"]; !ok {
+		t.Fatalf("expected latency in access log: %v", line)
+	}
+}
+
+func TestRequestIDIsGeneratedForMissingOrUnsafeHeader(t *testing.T) {
+	server := NewServer()
+	for _, incoming := range []string{"", "has space", strings.Repeat("x", maxRequestIDLength+1)} {
+		req := httptest.NewRequest(http.MethodGet, "/healthz", nil)
+		req.Header.Set("X-Request-ID", incoming)
+		rec := httptest.NewRecorder()
+		server.Handler().ServeHTTP(rec, req)
+
+		got := rec.Header().Get("X-Request-ID")
+		if got == "" || got == incoming {
+			t.Fatalf("expected generated request ID for %q, got %q", incoming, got)
+		}
+	}
+}
+
+func TestAccessLogRecordsInternalErrors(t *testing.T) {
+	var buf bytes.Buffer
+	server := NewServer(WithLogger(logging.New(&buf, nil)))
+	handler := server.withAccessLog(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		server.withIdempotency(w, r, Session{TenantID: "tenant-alpha"}, func(w http.ResponseWriter) {
+			server.writeInternalError(w, errors.New("database is down"))
+		})
+	}))
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/things", nil)
+	req.Header.Set("Idempotency-Key", "key-1")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	var line map[string]any
+	if err := json.Unmarshal(bytes.TrimSpace(buf.Bytes()), &line); err != nil {
+		t.Fatalf("decode access log %q: %v", buf.String(), err)
+	}
+	if line["level"] != "ERROR" || line["status"] != float64(http.StatusInternalServerError) || line["error"] != "database is down" {
+		t.Fatalf("unexpected access log line: %v", line)
+	}
+}
@@ -0,0 +1,316 @@
+package api
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/apierror"
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/invoice"
+)
+
+const (
+	defaultMaxAttachmentBytes int64 = 10 << 20
+	// multipartOverhead is the room left in an upload's body for the part
+	// headers and boundaries around the file.
+	multipartOverhead   = 64 << 10
+	maxFilenameLength   = 255
+	attachmentFormField = "file"
+)
+
+// defaultAttachmentContentTypes are the documents and images accepted as
+// attachments unless configured otherwise.
+var defaultAttachmentContentTypes = []string{
+	"application/pdf",
+	"image/jpeg",
+	"image/png",
+	"image/webp",
+	"text/csv",
+	"text/plain",
+}
+
+// Attachment is a file uploaded to an invoice. Checksum is the hex SHA-256
+// of the content; invoices of a tenant that carry the same file share one
+// stored object.
+type Attachment struct {
+	ID          string `json:"id"`
+	TenantID    string `json:"tenantId"`
+	InvoiceID   string `json:"invoiceId"`
+	Filename    string `json:"filename"`
+	Size        int64  `json:"size"`
+	ContentType string `json:"contentType"`
+	Checksum    string `json:"checksum"`
+	ObjectKey   string `json:"-"`
+	CreatedAt   string `json:"createdAt"`
+}
+
+type AttachmentList struct {
+	Items []Attachment `json:"items"`
+}
+
+var errAttachmentMissing = apierror.Field(attachmentFormField, "is required")
+
+func contentTypeSet(contentTypes []string) map[string]bool {
+	set := make(map[string]bool, len(contentTypes))
+	for _, contentType := range contentTypes {
+		set[strings.ToLower(contentType)] = true
+	}
+	return set
+}
+
+// attachmentObjectKey is where a tenant's blob with checksum is stored.
+func attachmentObjectKey(tenantID, checksum string) string {
+	return "attachments/" + url.PathEscape(tenantID) + "/" + checksum
+}
+
+// isFileUpload reports whether r is an attachment upload or an invoice
+// import, the routes that take multipart bodies.
+func isFileUpload(r *http.Request) bool {
+	return isAttachmentUpload(r) || (r.Method == http.MethodPost && r.URL.Path == "/v1/invoices/import")
+}
+
+// isAttachmentUpload reports whether r is a POST /v1/invoices/{id}/attachments.
+func isAttachmentUpload(r *http.Request) bool {
+	id, action := trimPrefixID(r.URL.Path, "/v1/invoices/")
+	return r.Method == http.MethodPost && strings.HasPrefix(r.URL.Path, "/v1/invoices/") && id != "" && action == "attachments"
+}
+
+// handleInvoiceAttachments serves /v1/invoices/{id}/attachments and the
+// attachment and download routes below it.
+func (s *Server) handleInvoiceAttachments(w http.ResponseWriter, r *http.Request, session Session, item invoice.Invoice, rest string) {
+	attachmentID, action, _ := strings.Cut(rest, "/")
+	switch {
+	case rest == "" && r.Method == http.MethodGet:
+		items, err := s.store.ListInvoiceAttachments(r.Context(), session.TenantID, item.ID)
+		if err != nil {
+			s.writeInternalError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, AttachmentList{Items: items})
+	case rest == "" && r.Method == http.MethodPost:
+		s.uploadAttachment(w, r, session, item)
+	case rest == "":
+		apierror.WriteError(w, http.StatusMethodNotAllowed, errMethodNotAllowed)
+	case action != "" && action != "download", strings.Contains(action, "/"):
+		apierror.WriteError(w, http.StatusNotFound, errRouteNotFound)
+	case r.Method != http.MethodGet:
+		apierror.WriteError(w, http.StatusMethodNotAllowed, errMethodNotAllowed)
+	default:
+		attachment, err := s.store.GetInvoiceAttachment(r.Context(), session.TenantID, item.ID, attachmentID)
+		if err != nil {
+			s.writeLookupError(w, err, "attachment")
+			return
+		}
+		if action == "download" {
+			s.downloadAttachment(w, r, attachment)
+			return
+		}
+		writeJSON(w, http.StatusOK, attachment)
+	}
+}
+
+// uploadAttachment stores the "file" part of a multipart body. The part is
+// spooled to a temporary file while it is hashed, so the whole upload never
+// sits in memory. Uploading a file the invoice already has answers 200 with
+// the existing attachment, which also makes retries safe without an
+// Idempotency-Key.
+func (s *Server) uploadAttachment(w http.ResponseWriter, r *http.Request, session Session, item invoice.Invoice) {
+	ctx := r.Context()
+	part, err := uploadedFile(r)
+	if err != nil {
+		s.writeUploadError(w, err)
+		return
+	}
+	defer part.Close()
+
+	spool, err := os.CreateTemp("", "attachment-*")
+	if err != nil {
+		s.writeInternalError(w, err)
+		return
+	}
+	defer os.Remove(spool.Name())
+	defer spool.Close()
+	hash := sha256.New()
+	size, err := io.Copy(io.MultiWriter(spool, hash), io.LimitReader(part, s.maxAttachmentBytes+1))
+	if err != nil {
+		s.writeUploadError(w, err)
+		return
+	}
+	switch {
+	case size > s.maxAttachmentBytes:
+		s.writeUploadError(w, &http.MaxBytesError{Limit: s.maxAttachmentBytes})
+		return
+	case size == 0:
+		writeValidationError(w, apierror.Field(attachmentFormField, "must not be empty"))
+		return
+	}
+
+	sniffed := make([]byte, 512)
+	n, _ := spool.ReadAt(sniffed, 0)
+	contentType := attachmentContentType(part.Header.Get("Content-Type"), sniffed[:n])
+	if !s.attachmentTypes[contentType] {
+		allowed := make([]string, 0, len(s.attachmentTypes))
+		for allowedType := range s.attachmentTypes {
+			allowed = append(allowed, allowedType)
+		}
+		slices.Sort(allowed)
+		apierror.WriteError(w, http.StatusUnsupportedMediaType, &apierror.Error{
+			Code:    apierror.CodeUnsupportedMediaType,
+			Message: fmt.Sprintf("attachments of type %s are not accepted", contentType),
+			Details: map[string]any{"allowed": allowed},
+		})
+		return
+	}
+
+	checksum := hex.EncodeToString(hash.Sum(nil))
+	attachment := Attachment{
+		ID:          s.newID("att"),
+		TenantID:    session.TenantID,
+		InvoiceID:   item.ID,
+		Filename:    attachmentFilename(part.FileName()),
+		Size:        size,
+		ContentType: contentType,
+		Checksum:    checksum,
+		ObjectKey:   attachmentObjectKey(session.TenantID, checksum),
+		CreatedAt:   utcNow(),
+	}
+	// The object goes in before the row that points at it, so a listed
+	// attachment can always be downloaded. Both happen under the blob's
+	// lock, so a purge cannot delete a blob this upload decided to reuse.
+	var saved Attachment
+	var created bool
+	err = s.store.LockAttachmentObject(ctx, session.TenantID, checksum, func(ctx context.Context) error {
+		stored, err := s.store.AttachmentObjectStored(ctx, session.TenantID, checksum)
+		if err != nil {
+			return err
+		}
+		if !stored {
+			if _, err := spool.Seek(0, io.SeekStart); err != nil {
+				return err
+			}
+			if err := s.objectStore.Put(ctx, attachment.ObjectKey, spool, size, contentType); err != nil {
+				return err
+			}
+		}
+		saved, created, err = s.store.CreateInvoiceAttachment(ctx, attachment)
+		return err
+	})
+	if err != nil {
+		s.writeLookupError(w, err, "invoice")
+		return
+	}
+	if !created {
+		writeJSON(w, http.StatusOK, saved)
+		return
+	}
+	if err := s.recordAudit(ctx, session.TenantID, routeCtx(r).RequestID, "invoice.attachment.added", "invoice", item.ID, fmt.Sprintf("Attached %s", saved.Filename)); err != nil {
+		s.writeInternalError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, saved)
+}
+
+// uploadedFile returns the "file" part of a multipart body, skipping the
+// parts before it, or errAttachmentMissing when there is none.
+func uploadedFile(r *http.Request) (*multipart.Part, error) {
+	reader, err := r.MultipartReader()
+	if err != nil {
+		return nil, errAttachmentMissing
+	}
+	for {
+		part, err := reader.NextPart()
+		if errors.Is(err, io.EOF) {
+			return nil, errAttachmentMissing
+		}
+		if err != nil {
+			return nil, err
+		}
+		if part.FormName() == attachmentFormField && part.FileName() != "" {
+			return part, nil
+		}
+		part.Close()
+	}
+}
+
+// downloadAttachment streams the attachment's blob. It is always served as
+// a download with the stored type and nosniff, so an uploaded file cannot
+// render as a page of the API's origin.
+func (s *Server) downloadAttachment(w http.ResponseWriter, r *http.Request, attachment Attachment) {
+	etag := `"` + attachment.Checksum + `"`
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", "private")
+	if header := r.Header.Get("If-None-Match"); header != "" && etagListMatches(header, etag, true) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	body, err := s.objectStore.Get(r.Context(), attachment.ObjectKey)
+	if err != nil {
+		s.writeInternalError(w, fmt.Errorf("read attachment %s: %w", attachment.ID, err))
+		return
+	}
+	defer body.Close()
+	w.Header().Set("Content-Type", attachment.ContentType)
+	w.Header().Set("Content-Length", strconv.FormatInt(attachment.Size, 10))
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": attachment.Filename}))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(http.StatusOK)
+	if _, err := io.Copy(w, body); err != nil {
+		s.logger.WarnContext(r.Context(), "stream attachment", "attachment_id", attachment.ID, "error", err)
+	}
+}
+
+// attachmentContentType is the declared media type of the file, or the
+// sniffed one when the client sent none or a generic binary type.
+func attachmentContentType(declared string, head []byte) string {
+	mediaType, _, err := mime.ParseMediaType(declared)
+	if err != nil || mediaType == "application/octet-stream" {
+		mediaType, _, _ = mime.ParseMediaType(http.DetectContentType(head))
+	}
+	return strings.ToLower(mediaType)
+}
+
+// attachmentFilename keeps the base name the client sent, without control
+// characters and at most maxFilenameLength characters long.
+func attachmentFilename(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, strings.TrimSpace(name))
+	if runes := []rune(name); len(runes) > maxFilenameLength {
+		name = string(runes[len(runes)-maxFilenameLength:])
+	}
+	if name == "" || name == "." || name == ".." {
+		return "attachment"
+	}
+	return name
+}
+
+// writeUploadError answers a multipart body that could not be read: 413
+// when it ran past the size limit, 400 when the file is missing or the body
+// is malformed.
+func (s *Server) writeUploadError(w http.ResponseWriter, err error) {
+	var maxBytes *http.MaxBytesError
+	if errors.As(err, &maxBytes) {
+		apierror.WriteError(w, http.StatusRequestEntityTooLarge, apierror.New(apierror.CodePayloadTooLarge, fmt.Sprintf("uploads must be at most %d bytes", s.maxAttachmentBytes)))
+		return
+	}
+	if errors.Is(err, errAttachmentMissing) {
+		writeValidationError(w, errAttachmentMissing)
+		return
+	}
+	apierror.WriteError(w, http.StatusBadRequest, apierror.New(apierror.CodeInvalidRequest, "invalid multipart body"))
+}
@@ -0,0 +1,159 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"testing"
+
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/apierror"
+)
+
+var pdfForTest = []byte("%PDF-1.4\n1 0 obj << >> endobj\n%%EOF\n")
+
+func TestUploadInvoiceAttachmentDeduplicatesByChecksum(t *testing.T) {
+	objects := NewMemoryObjectStore()
+	server := NewServer(WithObjectStore(objects))
+	cookie := loginForTest(t, server)
+	customer := createCustomerForTest(t, server, cookie, CustomerCreateRequest{Name: "Acme"})
+	first := createInvoiceForTest(t, server, cookie, InvoiceCreateRequest{Number: "INV-0001", CustomerID: customer.ID, Currency: "ILS"})
+	second := createInvoiceForTest(t, server, cookie, InvoiceCreateRequest{Number: "INV-0002", CustomerID: customer.ID, Currency: "ILS"})
+
+	rec := uploadAttachmentForTest(t, server, cookie, first.ID, "receipt.pdf", "application/pdf", pdfForTest)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected upload 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	created := decodeAttachmentForTest(t, rec)
+	if created.Filename != "receipt.pdf" || created.Size != int64(len(pdfForTest)) || created.ContentType != "application/pdf" || len(created.Checksum) != 64 {
+		t.Fatalf("unexpected attachment %#v", created)
+	}
+
+	rec = uploadAttachmentForTest(t, server, cookie, first.ID, "copy.pdf", "application/pdf", pdfForTest)
+	if again := decodeAttachmentForTest(t, rec); rec.Code != http.StatusOK || again.ID != created.ID || again.Filename != "receipt.pdf" {
+		t.Fatalf("expected the existing attachment with 200, got %d %#v", rec.Code, again)
+	}
+	rec = uploadAttachmentForTest(t, server, cookie, second.ID, "receipt.pdf", "application/pdf", pdfForTest)
+	if other := decodeAttachmentForTest(t, rec); rec.Code != http.StatusCreated || other.ID == created.ID || other.Checksum != created.Checksum {
+		t.Fatalf("expected a new attachment on the second invoice, got %d %#v", rec.Code, other)
+	}
+	if objects.Len() != 1 {
+		t.Fatalf("expected one stored object for the same file, got %d", objects.Len())
+	}
+
+	rec = doJSON(t, server, cookie, http.MethodGet, "/v1/invoices/"+first.ID+"/attachments", nil)
+	var list AttachmentList
+	if err := json.NewDecoder(rec.Body).Decode(&list); err != nil || rec.Code != http.StatusOK || len(list.Items) != 1 || list.Items[0].ID != created.ID {
+		t.Fatalf("expected the one attachment listed, got %d %#v, %v", rec.Code, list, err)
+	}
+	rec = doJSON(t, server, cookie, http.MethodGet, "/v1/invoices/"+second.ID+"/attachments/"+created.ID, nil)
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("expected another invoice's attachment to be 404, got %d", rec.Code)
+	}
+}
+
+func TestDownloadInvoiceAttachmentStreamsFile(t *testing.T) {
+	server := NewServer()
+	cookie := loginForTest(t, server)
+	customer := createCustomerForTest(t, server, cookie, CustomerCreateRequest{Name: "Acme"})
+	item := createInvoiceForTest(t, server, cookie, InvoiceCreateRequest{Number: "INV-0001", CustomerID: customer.ID, Currency: "ILS"})
+	// No declared type: the content is sniffed.
+	created := decodeAttachmentForTest(t, uploadAttachmentForTest(t, server, cookie, item.ID, "scan \"1\".pdf", "", pdfForTest))
+
+	rec := doJSON(t, server, cookie, http.MethodGet, "/v1/invoices/"+item.ID+"/attachments/"+created.ID+"/download", nil)
+	if rec.Code != http.StatusOK || !bytes.Equal(rec.Body.Bytes(), pdfForTest) {
+		t.Fatalf("expected the file bytes, got %d %q", rec.Code, rec.Body.String())
+	}
+	header := rec.Header()
+	if header.Get("Content-Type") != "application/pdf" || header.Get("Content-Disposition") != `attachment; filename="scan \"1\".pdf"` ||
+		header.Get("X-Content-Type-Options") != "nosniff" || header.Get("ETag") != `"`+created.Checksum+`"` {
+		t.Fatalf("unexpected download headers %v", header)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/invoices/"+item.ID+"/attachments/"+created.ID+"/download", nil)
+	req.AddCookie(cookie)
+	req.Header.Set("If-None-Match", header.Get("ETag"))
+	rec = httptest.NewRecorder()
+	server.Handler().ServeHTTP(rec, req)
+	if rec.Code != http.StatusNotModified || rec.Body.Len() != 0 {
+		t.Fatalf("expected 304 for a held ETag, got %d", rec.Code)
+	}
+}
+
+func TestUploadInvoiceAttachmentEnforcesLimits(t *testing.T) {
+	server := NewServer(WithAttachmentLimits(64, "application/pdf"))
+	cookie := loginForTest(t, server)
+	customer := createCustomerForTest(t, server, cookie, CustomerCreateRequest{Name: "Acme"})
+	item := createInvoiceForTest(t, server, cookie, InvoiceCreateRequest{Number: "INV-0001", CustomerID: customer.ID, Currency: "ILS"})
+
+	rec := uploadAttachmentForTest(t, server, cookie, item.ID, "notes.txt", "text/plain", []byte("hello"))
+	if code := decodeErrorCodeForTest(t, rec); rec.Code != http.StatusUnsupportedMediaType || code != apierror.CodeUnsupportedMediaType {
+		t.Fatalf("expected 415 for a refused type, got %d %s", rec.Code, code)
+	}
+	rec = uploadAttachmentForTest(t, server, cookie, item.ID, "big.pdf", "application/pdf", bytes.Repeat([]byte("x"), 65))
+	if code := decodeErrorCodeForTest(t, rec); rec.Code != http.StatusRequestEntityTooLarge || code != apierror.CodePayloadTooLarge {
+		t.Fatalf("expected 413 for an oversized file, got %d %s", rec.Code, code)
+	}
+	rec = uploadAttachmentForTest(t, server, cookie, item.ID, "empty.pdf", "application/pdf", nil)
+	if code := decodeErrorCodeForTest(t, rec); rec.Code != http.StatusBadRequest || code != apierror.CodeValidationFailed {
+		t.Fatalf("expected 400 for an empty file, got %d %s", rec.Code, code)
+	}
+	rec = doJSON(t, server, cookie, http.MethodPost, "/v1/invoices/"+item.ID+"/attachments", map[string]string{"file": "x"})
+	if rec.Code != http.StatusUnsupportedMediaType {
+		t.Fatalf("expected 415 for a JSON body, got %d", rec.Code)
+	}
+
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	writer.WriteField("note", "no file here")
+	writer.Close()
+	req := httptest.NewRequest(http.MethodPost, "/v1/invoices/"+item.ID+"/attachments", &body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	req.AddCookie(cookie)
+	rec = httptest.NewRecorder()
+	server.Handler().ServeHTTP(rec, req)
+	if code := decodeErrorCodeForTest(t, rec); rec.Code != http.StatusBadRequest || code != apierror.CodeValidationFailed {
+		t.Fatalf("expected 400 without a file part, got %d %s", rec.Code, code)
+	}
+}
+
+func uploadAttachmentForTest(t *testing.T, server *Server, cookie *http.Cookie, invoiceID, filename, contentType string, data []byte) *httptest.ResponseRecorder {
+	t.Helper()
+
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	header := textproto.MIMEHeader{}
+	header.Set("Content-Disposition", multipartFileDisposition(filename))
+	if contentType != "" {
+		header.Set("Content-Type", contentType)
+	}
+	part, err := writer.CreatePart(header)
+	if err != nil {
+		t.Fatalf("create part: %v", err)
+	}
+	part.Write(data)
+	writer.Close()
+	req := httptest.NewRequest(http.MethodPost, "/v1/invoices/"+invoiceID+"/attachments", &body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	req.AddCookie(cookie)
+	rec := httptest.NewRecorder()
+	server.Handler().ServeHTTP(rec, req)
+	return rec
+}
+
+func multipartFileDisposition(filename string) string {
+	escaped, _ := json.Marshal(filename)
+	return `form-data; name="file"; filename=` + string(escaped)
+}
+
+func decodeAttachmentForTest(t *testing.T, rec *httptest.ResponseRecorder) Attachment {
+	t.Helper()
+
+	var attachment Attachment
+	if err := json.NewDecoder(rec.Body).Decode(&attachment); err != nil {
+		t.Fatalf("decode attachment: %v", err)
+	}
+	return attachment
+}
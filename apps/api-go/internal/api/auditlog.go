@@ -0,0 +1,182 @@
+package api
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"slices"
+
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/apierror"
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/auth"
+)
+
+// Actor types of an audit log entry. Background workers such as the
+// overdue scan act as the system.
+const (
+	ActorUser   = "user"
+	ActorSystem = "system"
+)
+
+// Entity types and actions of the audit log. Actions match the activity feed
+// events the handlers record for the same change.
+const (
+	AuditEntityInvoice  = "invoice"
+	AuditEntityCustomer = "customer"
+
+	AuditInvoiceCreated         = "invoice.created"
+	AuditInvoiceUpdated         = "invoice.updated"
+	AuditInvoiceDeleted         = "invoice.deleted"
+	AuditInvoiceItemAdded       = "invoice.item.added"
+	AuditInvoiceItemRemoved     = "invoice.item.removed"
+	AuditInvoiceOverdue         = "invoice.overdue"
+	AuditInvoicePaymentReceived = "invoice.payment_received"
+	AuditInvoiceVoided          = "invoice.voided"
+	AuditInvoiceCredited        = "invoice.credited"
+	AuditCustomerCreated        = "customer.created"
+	AuditCustomerUpdated        = "customer.updated"
+	AuditCustomerDeleted        = "customer.deleted"
+)
+
+// permissionViewDeleted lets a caller read soft-deleted records with
+// ?include_deleted=true.
+const permissionViewDeleted = "audit:read"
+
+var errViewDeletedForbidden = apierror.New(apierror.CodeForbidden, "include_deleted requires the "+permissionViewDeleted+" permission")
+
+// AuditLogEntry records one change to an invoice or customer: who made it,
+// from which request, and the fields it changed. Stores write the entry in
+// the transaction of the change it describes, so the log cannot disagree
+// with the data.
+type AuditLogEntry struct {
+	ID         string                 `json:"id"`
+	TenantID   string                 `json:"tenantId"`
+	EntityType string                 `json:"entityType"`
+	EntityID   string                 `json:"entityId"`
+	Action     string                 `json:"action"`
+	Actor      AuditActor             `json:"actor"`
+	RequestID  string                 `json:"requestId,omitempty"`
+	Changes    map[string]FieldChange `json:"changes"`
+	CreatedAt  string                 `json:"createdAt"`
+}
+
+// AuditActor is the caller taken from the request's auth claims.
+type AuditActor struct {
+	Type  string `json:"type"`
+	ID    string `json:"id,omitempty"`
+	Email string `json:"email,omitempty"`
+}
+
+// FieldChange is a field's JSON value before and after a change. Before is
+// null for created records.
+type FieldChange struct {
+	Before any `json:"before"`
+	After  any `json:"after"`
+}
+
+type AuditLogList struct {
+	Items []AuditLogEntry `json:"items"`
+}
+
+// NewAuditLogEntry describes the change of an entity from before to after,
+// either of which may be nil, by the caller in ctx.
+func NewAuditLogEntry(ctx context.Context, tenantID, entityType, entityID, action string, before, after any) (AuditLogEntry, error) {
+	changes, err := diffFields(before, after)
+	if err != nil {
+		return AuditLogEntry{}, fmt.Errorf("diff %s %s: %w", entityType, entityID, err)
+	}
+	entry := AuditLogEntry{
+		ID:         newAuditLogID(),
+		TenantID:   tenantID,
+		EntityType: entityType,
+		EntityID:   entityID,
+		Action:     action,
+		Actor:      AuditActor{Type: ActorSystem},
+		Changes:    changes,
+		CreatedAt:  utcNow(),
+	}
+	if claims, ok := auth.FromContext(ctx); ok {
+		entry.Actor = AuditActor{Type: ActorUser, ID: claims.Subject, Email: claims.Email}
+	}
+	if route, ok := ctx.Value(routeContextKey).(routeContext); ok {
+		entry.RequestID = route.RequestID
+	}
+	return entry, nil
+}
+
+// diffFields compares the JSON objects of before and after field by field.
+func diffFields(before, after any) (map[string]FieldChange, error) {
+	old, err := jsonFieldsOf(before)
+	if err != nil {
+		return nil, err
+	}
+	current, err := jsonFieldsOf(after)
+	if err != nil {
+		return nil, err
+	}
+	changes := make(map[string]FieldChange)
+	for field, value := range current {
+		if previous, ok := old[field]; !ok || !reflect.DeepEqual(previous, value) {
+			changes[field] = FieldChange{Before: old[field], After: value}
+		}
+	}
+	for field, value := range old {
+		if _, ok := current[field]; !ok {
+			changes[field] = FieldChange{Before: value}
+		}
+	}
+	return changes, nil
+}
+
+func jsonFieldsOf(value any) (map[string]any, error) {
+	fields := make(map[string]any)
+	if value == nil {
+		return fields, nil
+	}
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(encoded, &fields); err != nil {
+		return nil, err
+	}
+	return fields, nil
+}
+
+func newAuditLogID() string {
+	var buf [8]byte
+	_, _ = rand.Read(buf[:])
+	return "alog-" + hex.EncodeToString(buf[:])
+}
+
+// includeDeleted reads ?include_deleted, which only callers allowed to view
+// deleted records may set.
+func includeDeleted(r *http.Request, session Session) (bool, error) {
+	switch r.URL.Query().Get("include_deleted") {
+	case "", "false":
+		return false, nil
+	case "true":
+		if !session.can(permissionViewDeleted) {
+			return false, errViewDeletedForbidden
+		}
+		return true, nil
+	default:
+		return false, apierror.Field("include_deleted", "must be true or false")
+	}
+}
+
+// writeIncludeDeletedError answers for an include_deleted that was refused.
+func writeIncludeDeletedError(w http.ResponseWriter, err error) {
+	status := http.StatusBadRequest
+	if err == errViewDeletedForbidden {
+		status = http.StatusForbidden
+	}
+	apierror.WriteError(w, status, err)
+}
+
+func (s Session) can(permission string) bool {
+	return slices.Contains(s.Permissions, permission)
+}
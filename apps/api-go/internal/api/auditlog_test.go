@@ -0,0 +1,175 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/apierror"
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/invoice"
+)
+
+func TestInvoiceHistoryRecordsEachChange(t *testing.T) {
+	server := NewServer()
+	cookie := loginForTest(t, server)
+	created := createInvoiceForTest(t, server, cookie, InvoiceCreateRequest{
+		Number:     "INV-0001",
+		CustomerID: "cust-1",
+		Currency:   "ILS",
+		Items:      []InvoiceLineItemRequest{{Description: "Hosting", Quantity: 1, UnitPrice: 10000}},
+	})
+	if rec := doJSON(t, server, cookie, http.MethodPost, "/v1/invoices/"+created.ID+"/items", InvoiceLineItemRequest{Description: "Support", Quantity: 2, UnitPrice: 500}); rec.Code != http.StatusCreated {
+		t.Fatalf("expected add item 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if rec := patchInvoiceForTest(t, server, cookie, "/v1/invoices/"+created.ID, map[string]any{"dueAt": "2026-07-01T00:00:00Z"}); rec.Code != http.StatusOK {
+		t.Fatalf("expected patch 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if rec := doJSON(t, server, cookie, http.MethodDelete, "/v1/invoices/"+created.ID, nil); rec.Code != http.StatusNoContent {
+		t.Fatalf("expected delete 204, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	rec := doJSON(t, server, cookie, http.MethodGet, "/v1/invoices/"+created.ID+"/history?include_deleted=true", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected history 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var history AuditLogList
+	if err := json.NewDecoder(rec.Body).Decode(&history); err != nil {
+		t.Fatalf("decode history: %v", err)
+	}
+	want := []string{AuditInvoiceCreated, AuditInvoiceItemAdded, AuditInvoiceUpdated, AuditInvoiceDeleted}
+	if len(history.Items) != len(want) {
+		t.Fatalf("expected %d entries, got %#v", len(want), history.Items)
+	}
+	for i, entry := range history.Items {
+		if entry.Action != want[i] || entry.Actor.Type != ActorUser || entry.Actor.Email != "operator@example.com" || entry.RequestID == "" {
+			t.Fatalf("unexpected entry %d: %#v", i, entry)
+		}
+	}
+	if change := history.Items[0].Changes["number"]; change.Before != nil || change.After != "INV-0001" {
+		t.Fatalf("expected the created entry to set every field, got %#v", history.Items[0].Changes)
+	}
+	if change := history.Items[1].Changes["subtotal"]; change.Before != float64(10000) || change.After != float64(11000) {
+		t.Fatalf("expected the added item to change the subtotal, got %#v", history.Items[1].Changes)
+	}
+	updated := history.Items[2].Changes
+	if change := updated["dueAt"]; change.Before != nil || change.After != "2026-07-01T00:00:00Z" {
+		t.Fatalf("expected the update to set the due date, got %#v", updated)
+	}
+	for field := range updated {
+		if field != "dueAt" && field != "updatedAt" && field != "version" {
+			t.Fatalf("expected unchanged fields to be left out, got %s in %#v", field, updated)
+		}
+	}
+	if change := history.Items[3].Changes["deletedAt"]; change.Before != nil || change.After == nil {
+		t.Fatalf("expected the delete to set deletedAt, got %#v", history.Items[3].Changes)
+	}
+}
+
+func TestSoftDeletedInvoicesNeedIncludeDeleted(t *testing.T) {
+	server := NewServer()
+	cookie := loginForTest(t, server)
+	kept := createInvoiceForTest(t, server, cookie, InvoiceCreateRequest{Number: "INV-0001", CustomerID: "cust-1", Currency: "ILS", Subtotal: 100, Total: 100})
+	deleted := createInvoiceForTest(t, server, cookie, InvoiceCreateRequest{Number: "INV-0002", CustomerID: "cust-1", Currency: "ILS", Subtotal: 100, Total: 100})
+	if rec := doJSON(t, server, cookie, http.MethodDelete, "/v1/invoices/"+deleted.ID, nil); rec.Code != http.StatusNoContent {
+		t.Fatalf("expected delete 204, got %d", rec.Code)
+	}
+
+	for _, path := range []string{"/v1/invoices/" + deleted.ID, "/v1/invoices/" + deleted.ID + "/history", "/v1/invoices/" + deleted.ID + "/pdf"} {
+		if rec := doJSON(t, server, cookie, http.MethodGet, path, nil); rec.Code != http.StatusNotFound {
+			t.Fatalf("GET %s: expected 404, got %d", path, rec.Code)
+		}
+	}
+	if rec := patchInvoiceForTest(t, server, cookie, "/v1/invoices/"+deleted.ID+"?include_deleted=true", map[string]any{"status": "open"}); rec.Code != http.StatusNotFound {
+		t.Fatalf("expected patch of a deleted invoice 404, got %d", rec.Code)
+	}
+	if items := listInvoiceIDsForTest(t, server, cookie, "/v1/invoices"); len(items) != 1 || items[0] != kept.ID {
+		t.Fatalf("expected only the live invoice, got %v", items)
+	}
+	if items := listInvoiceIDsForTest(t, server, cookie, "/v1/invoices?include_deleted=true"); len(items) != 2 {
+		t.Fatalf("expected both invoices with include_deleted, got %v", items)
+	}
+	if results, err := server.store.SearchInvoices(context.Background(), "tenant-alpha", invoice.SearchQuery{Text: "INV-0002", Mode: invoice.SearchSubstring}); err != nil || len(results) != 0 {
+		t.Fatalf("expected search to leave out deleted invoices, got %#v, %v", results, err)
+	}
+
+	rec := doJSON(t, server, cookie, http.MethodGet, "/v1/invoices/"+deleted.ID+"?include_deleted=true", nil)
+	var got invoice.Invoice
+	if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+		t.Fatalf("decode invoice: %v", err)
+	}
+	if rec.Code != http.StatusOK || got.DeletedAt == "" {
+		t.Fatalf("expected the deleted invoice with include_deleted, got %d %#v", rec.Code, got)
+	}
+	if rec := doJSON(t, server, cookie, http.MethodGet, "/v1/invoices?include_deleted=yes", nil); rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected invalid include_deleted 400, got %d", rec.Code)
+	}
+}
+
+func TestIncludeDeletedRequiresAuditPermission(t *testing.T) {
+	server := NewServer()
+	payload, _ := json.Marshal(LoginRequest{Email: "clerk@example.com", TenantID: "tenant-alpha", Permissions: []string{"invoices:read", "invoices:write"}})
+	req := httptest.NewRequest(http.MethodPost, "/auth/login", bytes.NewReader(payload))
+	req.Header.Set("Content-Type", "application/json")
+	login := httptest.NewRecorder()
+	server.Handler().ServeHTTP(login, req)
+	if login.Code != http.StatusOK {
+		t.Fatalf("expected login 200, got %d", login.Code)
+	}
+	cookie := login.Result().Cookies()[0]
+
+	for _, path := range []string{"/v1/invoices?include_deleted=true", "/v1/customers?include_deleted=true"} {
+		rec := doJSON(t, server, cookie, http.MethodGet, path, nil)
+		if code := decodeErrorCodeForTest(t, rec); rec.Code != http.StatusForbidden || code != apierror.CodeForbidden {
+			t.Fatalf("GET %s: expected 403 %s, got %d %s", path, apierror.CodeForbidden, rec.Code, code)
+		}
+	}
+	if rec := doJSON(t, server, cookie, http.MethodGet, "/v1/invoices?include_deleted=false", nil); rec.Code != http.StatusOK {
+		t.Fatalf("expected include_deleted=false to need no permission, got %d", rec.Code)
+	}
+}
+
+func TestNewAuditLogEntryDiffsChangedFields(t *testing.T) {
+	before := Customer{ID: "cust-1", Name: "Acme", BillingAddress: Address{City: "Tel Aviv"}}
+	after := before
+	after.Name = "Acme Ltd"
+	after.BillingAddress.City = "Haifa"
+	after.DeletedAt = "2026-01-01T00:00:00Z"
+
+	entry, err := NewAuditLogEntry(context.Background(), "tenant-a", AuditEntityCustomer, "cust-1", AuditCustomerUpdated, before, after)
+	if err != nil {
+		t.Fatalf("new audit log entry: %v", err)
+	}
+	if entry.Actor != (AuditActor{Type: ActorSystem}) || entry.RequestID != "" {
+		t.Fatalf("expected a system actor without a request, got %#v", entry)
+	}
+	if len(entry.Changes) != 3 {
+		t.Fatalf("expected name, billingAddress and deletedAt to change, got %#v", entry.Changes)
+	}
+	if change := entry.Changes["name"]; change.Before != "Acme" || change.After != "Acme Ltd" {
+		t.Fatalf("unexpected name change %#v", change)
+	}
+	if change := entry.Changes["deletedAt"]; change.Before != nil || change.After != after.DeletedAt {
+		t.Fatalf("expected an omitted field to diff from null, got %#v", change)
+	}
+}
+
+func listInvoiceIDsForTest(t *testing.T, server *Server, cookie *http.Cookie, path string) []string {
+	t.Helper()
+
+	rec := doJSON(t, server, cookie, http.MethodGet, path, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected list 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var page InvoiceList
+	if err := json.NewDecoder(rec.Body).Decode(&page); err != nil {
+		t.Fatalf("decode invoices: %v", err)
+	}
+	ids := make([]string, len(page.Items))
+	for i, item := range page.Items {
+		ids[i] = item.ID
+	}
+	return ids
+}
@@ -0,0 +1,94 @@
+package api
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/auth"
+)
+
+func TestBearerTokenAuthenticatesAsOrg(t *testing.T) {
+	key, server := newBearerTestServer(t)
+
+	rec := doBearer(t, server, http.MethodGet, "/v1/me", signBearerForTest(t, key, "user-7", "org-b", time.Hour), nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected me 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if !strings.Contains(rec.Body.String(), `"tenantId":"org-b"`) || !strings.Contains(rec.Body.String(), `"id":"user-7"`) {
+		t.Fatalf("expected identity from claims, got %s", rec.Body.String())
+	}
+}
+
+func TestBearerTokenFailures(t *testing.T) {
+	key, server := newBearerTestServer(t)
+
+	rec := doBearer(t, server, http.MethodGet, "/v1/invoices", signBearerForTest(t, key, "user-7", "org-b", -time.Minute), nil)
+	if rec.Code != http.StatusUnauthorized || !strings.Contains(rec.Header().Get("WWW-Authenticate"), "token expired") {
+		t.Fatalf("expected expired 401 with hint, got %d %q", rec.Code, rec.Header().Get("WWW-Authenticate"))
+	}
+
+	rec = doBearer(t, server, http.MethodGet, "/v1/invoices", "garbage", nil)
+	if rec.Code != http.StatusUnauthorized || rec.Header().Get("WWW-Authenticate") != `Bearer error="invalid_token"` {
+		t.Fatalf("expected invalid 401, got %d %q", rec.Code, rec.Header().Get("WWW-Authenticate"))
+	}
+
+	rec = doBearer(t, NewServer(), http.MethodGet, "/v1/invoices", signBearerForTest(t, key, "user-7", "org-b", time.Hour), nil)
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 without a configured verifier, got %d", rec.Code)
+	}
+
+	for _, path := range []string{"/healthz", "/readyz"} {
+		rec := httptest.NewRecorder()
+		server.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected %s to stay public, got %d", path, rec.Code)
+		}
+	}
+}
+
+func newBearerTestServer(t *testing.T) (*rsa.PrivateKey, *Server) {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	return key, NewServer(WithTokenVerifier(auth.NewVerifier(&key.PublicKey, 0)))
+}
+
+func signBearerForTest(t *testing.T, key *rsa.PrivateKey, subject, orgID string, ttl time.Duration) string {
+	t.Helper()
+	token, err := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"sub":    subject,
+		"org_id": orgID,
+		"email":  subject + "@example.com",
+		"exp":    time.Now().Add(ttl).Unix(),
+	}).SignedString(key)
+	if err != nil {
+		t.Fatalf("sign token: %v", err)
+	}
+	return token
+}
+
+func doBearer(t *testing.T, server *Server, method, path, token string, payload any) *httptest.ResponseRecorder {
+	t.Helper()
+	var body bytes.Buffer
+	if payload != nil {
+		if err := json.NewEncoder(&body).Encode(payload); err != nil {
+			t.Fatalf("encode payload: %v", err)
+		}
+	}
+	req := httptest.NewRequest(method, path, &body)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+	rec := httptest.NewRecorder()
+	server.Handler().ServeHTTP(rec, req)
+	return rec
+}
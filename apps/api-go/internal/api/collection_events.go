@@ -0,0 +1,194 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultEventHeartbeat is how often an idle event stream sends a
+	// comment, well inside the minute after which proxies commonly drop a
+	// quiet connection.
+	defaultEventHeartbeat = 15 * time.Second
+	// defaultEventPollInterval is how often a stream rereads its job, which
+	// catches transitions made by another instance of the API.
+	defaultEventPollInterval = 2 * time.Second
+	// eventRetryMillis is how long EventSource waits before reconnecting
+	// after the stream breaks.
+	eventRetryMillis = 2000
+)
+
+// jobWatchers wakes the event streams of a collection job when this
+// instance transitions it.
+type jobWatchers struct {
+	mu       sync.Mutex
+	closed   bool
+	watchers map[string]map[chan struct{}]struct{}
+}
+
+func newJobWatchers() *jobWatchers {
+	return &jobWatchers{watchers: map[string]map[chan struct{}]struct{}{}}
+}
+
+func jobWatchKey(tenantID, jobID string) string {
+	return tenantID + "/" + jobID
+}
+
+// watch returns a channel that receives a value after each transition of
+// the job, and is closed once the server shuts down, and a function that
+// stops watching.
+func (h *jobWatchers) watch(tenantID, jobID string) (<-chan struct{}, func()) {
+	ch := make(chan struct{}, 1)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		close(ch)
+		return ch, func() {}
+	}
+	key := jobWatchKey(tenantID, jobID)
+	if h.watchers[key] == nil {
+		h.watchers[key] = map[chan struct{}]struct{}{}
+	}
+	h.watchers[key][ch] = struct{}{}
+	return ch, func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		if _, ok := h.watchers[key][ch]; !ok {
+			return
+		}
+		delete(h.watchers[key], ch)
+		if len(h.watchers[key]) == 0 {
+			delete(h.watchers, key)
+		}
+	}
+}
+
+// notify wakes the watchers of a job. A watcher that has not caught up
+// with the previous wake-up is not woken twice: it rereads the job anyway.
+func (h *jobWatchers) notify(tenantID, jobID string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for ch := range h.watchers[jobWatchKey(tenantID, jobID)] {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// close ends every watch, so open streams return and let the HTTP server
+// drain.
+func (h *jobWatchers) close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		return
+	}
+	h.closed = true
+	for key, chans := range h.watchers {
+		for ch := range chans {
+			close(ch)
+		}
+		delete(h.watchers, key)
+	}
+}
+
+func collectionJobTerminal(status string) bool {
+	return status == CollectionJobSucceeded || status == CollectionJobFailed
+}
+
+// streamCollectionJobEvents sends the job's status as Server-Sent Events:
+// one "status" event with the job on connect and another after each
+// transition, with a comment every heartbeat interval while it waits. The
+// event ID is the status, so an EventSource that reconnects after the
+// terminal event is answered 204, which tells it to stop.
+func (s *Server) streamCollectionJobEvents(w http.ResponseWriter, r *http.Request, session Session, item CollectionJob) {
+	if collectionJobTerminal(item.Status) && r.Header.Get("Last-Event-ID") == item.Status {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	changed, stop := s.jobWatchers.watch(session.TenantID, item.ID)
+	defer stop()
+	// Reread now that transitions wake the stream, so none falls between
+	// the lookup and the watch.
+	item, err := s.store.GetCollectionJob(r.Context(), session.TenantID, item.ID)
+	if err != nil {
+		s.writeLookupError(w, err, "collection job")
+		return
+	}
+
+	header := w.Header()
+	header.Set("Content-Type", "text/event-stream")
+	header.Set("Cache-Control", "no-store")
+	// Asks nginx not to buffer the stream.
+	header.Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	flush := http.NewResponseController(w).Flush
+	if _, err := fmt.Fprintf(w, "retry: %d\n\n", eventRetryMillis); err != nil {
+		return
+	}
+
+	heartbeat := time.NewTicker(s.eventHeartbeat)
+	defer heartbeat.Stop()
+	poll := time.NewTicker(s.eventPollInterval)
+	defer poll.Stop()
+	sent := ""
+	for {
+		if item.Status != sent {
+			if err := writeJobEvent(w, item); err != nil {
+				return
+			}
+			_ = flush()
+			sent = item.Status
+		}
+		if collectionJobTerminal(item.Status) {
+			return
+		}
+		select {
+		case <-r.Context().Done():
+			return
+		case <-heartbeat.C:
+			if _, err := w.Write([]byte(": heartbeat\n\n")); err != nil {
+				return
+			}
+			_ = flush()
+			continue
+		case _, open := <-changed:
+			if !open {
+				return
+			}
+		case <-poll.C:
+		}
+		next, err := s.store.GetCollectionJob(r.Context(), session.TenantID, item.ID)
+		if errors.Is(err, ErrNotFound) {
+			return
+		}
+		if err != nil {
+			// The next poll tries again; the client keeps the last status.
+			s.logger.WarnContext(r.Context(), "collection job events reread failed", "job_id", item.ID, "error", err)
+			continue
+		}
+		item = next
+	}
+}
+
+func writeJobEvent(w http.ResponseWriter, item CollectionJob) error {
+	data, err := json.Marshal(item)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %s\nevent: status\ndata: %s\n\n", item.Status, data)
+	return err
+}
+
+// isEventStreamRequest reports whether r asks for a job's event stream,
+// which stays open for the length of the job.
+func isEventStreamRequest(r *http.Request) bool {
+	id, action := trimPrefixID(r.URL.Path, "/v1/collection-jobs/")
+	return strings.HasPrefix(r.URL.Path, "/v1/collection-jobs/") && id != "" && action == "events"
+}
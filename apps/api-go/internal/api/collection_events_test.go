@@ -0,0 +1,237 @@
+package api
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCollectionJobEventsFollowTheJobToTheEnd(t *testing.T) {
+	store := NewMemoryStore()
+	server := NewServer(WithStore(store))
+	server.eventHeartbeat = 20 * time.Millisecond
+	cookie := loginForTest(t, server)
+	job := queueCollectionJobForTest(t, store, "tenant-alpha")
+	stream := openEventStreamForTest(t, server, cookie, job.ID)
+	if got := stream.resp.Header.Get("Content-Type"); got != "text/event-stream" || stream.resp.Header.Get("Content-Encoding") != "" {
+		t.Fatalf("unexpected stream headers %v", stream.resp.Header)
+	}
+
+	if event := stream.next(t); event.retry != "2000" {
+		t.Fatalf("expected the stream to open with a retry interval, got %#v", event)
+	}
+	if event := stream.next(t); event.name != "status" || event.id != CollectionJobQueued || event.job.ID != job.ID || event.job.Status != CollectionJobQueued {
+		t.Fatalf("expected the current status first, got %#v", event)
+	}
+	if event := stream.next(t); event.comment != "heartbeat" {
+		t.Fatalf("expected a heartbeat while the job waits, got %#v", event)
+	}
+
+	ctx := context.Background()
+	if err := server.transitionCollectionJob(ctx, &job, CollectionJobRunning); err != nil {
+		t.Fatalf("start job: %v", err)
+	}
+	if event := stream.nextEvent(t); event.id != CollectionJobRunning || event.job.StartedAt == "" {
+		t.Fatalf("expected the running transition, got %#v", event)
+	}
+	if err := server.transitionCollectionJob(ctx, &job, CollectionJobSucceeded); err != nil {
+		t.Fatalf("finish job: %v", err)
+	}
+	if event := stream.nextEvent(t); event.id != CollectionJobSucceeded || event.job.FinishedAt == "" {
+		t.Fatalf("expected the succeeded transition, got %#v", event)
+	}
+	stream.expectEnd(t)
+
+	// An EventSource reconnecting after the terminal event is told to stop.
+	req := httptest.NewRequest(http.MethodGet, "/v1/collection-jobs/"+job.ID+"/events", nil)
+	req.Header.Set("Last-Event-ID", CollectionJobSucceeded)
+	req.AddCookie(cookie)
+	rec := httptest.NewRecorder()
+	server.Handler().ServeHTTP(rec, req)
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("expected a reconnect after the end to get 204, got %d", rec.Code)
+	}
+}
+
+func TestCollectionJobEventsSeeTransitionsFromOtherInstances(t *testing.T) {
+	store := NewMemoryStore()
+	server := NewServer(WithStore(store))
+	server.eventPollInterval = 10 * time.Millisecond
+	cookie := loginForTest(t, server)
+	job := queueCollectionJobForTest(t, store, "tenant-alpha")
+	stream := openEventStreamForTest(t, server, cookie, job.ID)
+	stream.nextEvent(t)
+
+	// Another instance sharing the store fails the job; nothing here is
+	// notified, so the stream has to find out by rereading.
+	other := NewServer(WithStore(store))
+	if err := other.transitionCollectionJob(context.Background(), &job, CollectionJobFailed); err != nil {
+		t.Fatalf("fail job: %v", err)
+	}
+	if event := stream.nextEvent(t); event.id != CollectionJobFailed {
+		t.Fatalf("expected the failed transition, got %#v", event)
+	}
+	stream.expectEnd(t)
+}
+
+func TestCollectionJobEventsAreTenantScoped(t *testing.T) {
+	store := NewMemoryStore()
+	server := NewServer(WithStore(store))
+	cookie := loginForTest(t, server)
+	job := queueCollectionJobForTest(t, store, "tenant-beta")
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/collection-jobs/"+job.ID+"/events", nil)
+	req.AddCookie(cookie)
+	rec := httptest.NewRecorder()
+	server.Handler().ServeHTTP(rec, req)
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("expected another tenant's job to be 404, got %d", rec.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/v1/collection-jobs/"+job.ID+"/events", nil)
+	rec = httptest.NewRecorder()
+	server.Handler().ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("expected a stream without a session to be 401, got %d", rec.Code)
+	}
+}
+
+func TestCollectionJobEventsEndAtShutdown(t *testing.T) {
+	store := NewMemoryStore()
+	server := NewServer(WithStore(store))
+	cookie := loginForTest(t, server)
+	job := queueCollectionJobForTest(t, store, "tenant-alpha")
+	stream := openEventStreamForTest(t, server, cookie, job.ID)
+	stream.nextEvent(t)
+
+	server.BeginShutdown()
+	stream.expectEnd(t)
+}
+
+func queueCollectionJobForTest(t *testing.T, store *MemoryStore, tenantID string) CollectionJob {
+	t.Helper()
+	now := utcNow()
+	job := CollectionJob{ID: "job-events-1", TenantID: tenantID, Status: CollectionJobQueued, Attempt: 1, Providers: []string{"gmail"}, QueuedAt: now, CreatedAt: now, UpdatedAt: now}
+	if err := store.CreateCollectionJob(context.Background(), job); err != nil {
+		t.Fatalf("create job: %v", err)
+	}
+	return job
+}
+
+type eventStreamForTest struct {
+	resp   *http.Response
+	reader *bufio.Reader
+}
+
+// streamEventForTest is one block of an event stream: an event, a retry
+// interval or a comment.
+type streamEventForTest struct {
+	id, name, retry, comment string
+	job                      CollectionJob
+}
+
+func openEventStreamForTest(t *testing.T, server *Server, cookie *http.Cookie, jobID string) *eventStreamForTest {
+	t.Helper()
+	ts := httptest.NewServer(server.Handler())
+	t.Cleanup(ts.Close)
+	req, err := http.NewRequest(http.MethodGet, ts.URL+"/v1/collection-jobs/"+jobID+"/events", nil)
+	if err != nil {
+		t.Fatalf("new request: %v", err)
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	req.Header.Set("Accept-Encoding", "gzip")
+	req.AddCookie(cookie)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("open stream: %v", err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected stream 200, got %d", resp.StatusCode)
+	}
+	return &eventStreamForTest{resp: resp, reader: bufio.NewReader(resp.Body)}
+}
+
+func (s *eventStreamForTest) next(t *testing.T) streamEventForTest {
+	t.Helper()
+	type result struct {
+		event streamEventForTest
+		err   error
+	}
+	done := make(chan result, 1)
+	go func() {
+		var event streamEventForTest
+		for {
+			line, err := s.reader.ReadString('\n')
+			if err != nil {
+				done <- result{err: err}
+				return
+			}
+			line = strings.TrimSuffix(line, "\n")
+			if line == "" {
+				done <- result{event: event}
+				return
+			}
+			field, value, _ := strings.Cut(line, ":")
+			value = strings.TrimPrefix(value, " ")
+			switch field {
+			case "":
+				event.comment = value
+			case "id":
+				event.id = value
+			case "event":
+				event.name = value
+			case "retry":
+				event.retry = value
+			case "data":
+				if err := json.Unmarshal([]byte(value), &event.job); err != nil {
+					done <- result{err: err}
+					return
+				}
+			}
+		}
+	}()
+	select {
+	case got := <-done:
+		if got.err != nil {
+			t.Fatalf("read stream: %v", got.err)
+		}
+		return got.event
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the stream")
+		return streamEventForTest{}
+	}
+}
+
+// nextEvent skips the retry interval and heartbeats.
+func (s *eventStreamForTest) nextEvent(t *testing.T) streamEventForTest {
+	t.Helper()
+	for {
+		if event := s.next(t); event.name != "" {
+			return event
+		}
+	}
+}
+
+func (s *eventStreamForTest) expectEnd(t *testing.T) {
+	t.Helper()
+	done := make(chan error, 1)
+	go func() {
+		_, err := io.ReadAll(s.reader)
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("expected the stream to end cleanly, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the stream to end")
+	}
+}
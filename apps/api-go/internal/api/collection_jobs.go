@@ -0,0 +1,70 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+const (
+	CollectionJobQueued    = "queued"
+	CollectionJobRunning   = "running"
+	CollectionJobSucceeded = "succeeded"
+	CollectionJobFailed    = "failed"
+)
+
+// collectionJobTransitions lists the allowed next states. Succeeded and failed
+// are terminal: a retry enqueues a new job linked through RetryOf instead of
+// reviving the old row.
+var collectionJobTransitions = map[string][]string{
+	CollectionJobQueued:  {CollectionJobRunning, CollectionJobFailed},
+	CollectionJobRunning: {CollectionJobSucceeded, CollectionJobFailed},
+}
+
+// ErrInvalidTransition is returned for a status change the lifecycle forbids.
+var ErrInvalidTransition = errors.New("invalid collection job transition")
+
+func validCollectionJobStatus(status string) bool {
+	switch status {
+	case CollectionJobQueued, CollectionJobRunning, CollectionJobSucceeded, CollectionJobFailed:
+		return true
+	}
+	return false
+}
+
+func canTransitionCollectionJob(from, to string) bool {
+	for _, next := range collectionJobTransitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// transitionCollectionJob moves item to status, stamps the matching
+// timestamp, and persists the change with a compare-and-swap on the previous
+// status so two writers cannot both advance the same job.
+func (s *Server) transitionCollectionJob(ctx context.Context, item *CollectionJob, status string) error {
+	from := item.Status
+	if !canTransitionCollectionJob(from, status) {
+		return fmt.Errorf("%w: %s -> %s", ErrInvalidTransition, from, status)
+	}
+	next := *item
+	now := utcNow()
+	next.Status = status
+	next.UpdatedAt = now
+	switch status {
+	case CollectionJobRunning:
+		next.StartedAt = now
+		next.Error = ""
+	case CollectionJobSucceeded, CollectionJobFailed:
+		next.FinishedAt = now
+	}
+	if err := s.store.TransitionCollectionJob(ctx, next, from); err != nil {
+		return err
+	}
+	*item = next
+	s.metrics.recordCollectionJob(status)
+	s.jobWatchers.notify(next.TenantID, next.ID)
+	return nil
+}
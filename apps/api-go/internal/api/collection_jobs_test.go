@@ -0,0 +1,357 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/apierror"
+)
+
+func TestCollectionJobTransitions(t *testing.T) {
+	cases := []struct {
+		from, to string
+		allowed  bool
+	}{
+		{CollectionJobQueued, CollectionJobRunning, true},
+		{CollectionJobQueued, CollectionJobFailed, true},
+		{CollectionJobRunning, CollectionJobSucceeded, true},
+		{CollectionJobRunning, CollectionJobFailed, true},
+		{CollectionJobQueued, CollectionJobSucceeded, false},
+		{CollectionJobRunning, CollectionJobQueued, false},
+		{CollectionJobSucceeded, CollectionJobRunning, false},
+		{CollectionJobFailed, CollectionJobRunning, false},
+		{CollectionJobSucceeded, CollectionJobFailed, false},
+	}
+	for _, tc := range cases {
+		if got := canTransitionCollectionJob(tc.from, tc.to); got != tc.allowed {
+			t.Errorf("%s -> %s: expected allowed=%v, got %v", tc.from, tc.to, tc.allowed, got)
+		}
+	}
+}
+
+func TestTransitionCollectionJobRejectsStaleWriter(t *testing.T) {
+	store := NewMemoryStore()
+	server := NewServer(WithStore(store))
+	ctx := context.Background()
+	job := CollectionJob{ID: "job-1", TenantID: "tenant-alpha", Status: CollectionJobQueued, Attempt: 1, CreatedAt: utcNow(), UpdatedAt: utcNow()}
+	if err := store.CreateCollectionJob(ctx, job); err != nil {
+		t.Fatalf("create job: %v", err)
+	}
+
+	first, second := job, job
+	if err := server.transitionCollectionJob(ctx, &first, CollectionJobRunning); err != nil {
+		t.Fatalf("first transition: %v", err)
+	}
+	if first.StartedAt == "" {
+		t.Fatal("expected running transition to stamp startedAt")
+	}
+	if err := server.transitionCollectionJob(ctx, &second, CollectionJobRunning); !errors.Is(err, ErrConflict) {
+		t.Fatalf("expected stale writer conflict, got %v", err)
+	}
+	if second.Status != CollectionJobQueued {
+		t.Fatalf("expected failed transition to leave the copy untouched, got %s", second.Status)
+	}
+
+	if err := server.transitionCollectionJob(ctx, &first, CollectionJobSucceeded); err != nil {
+		t.Fatalf("finish job: %v", err)
+	}
+	if err := server.transitionCollectionJob(ctx, &first, CollectionJobRunning); !errors.Is(err, ErrInvalidTransition) {
+		t.Fatalf("expected succeeded -> running to be rejected, got %v", err)
+	}
+	stored, err := store.GetCollectionJob(ctx, "tenant-alpha", job.ID)
+	if err != nil {
+		t.Fatalf("get job: %v", err)
+	}
+	if stored.Status != CollectionJobSucceeded || stored.FinishedAt == "" {
+		t.Fatalf("unexpected stored job: %#v", stored)
+	}
+}
+
+func TestListCollectionJobsFiltersByStatus(t *testing.T) {
+	server := NewServer(
+		WithCollectionRunner(fakeCollectionRunner{
+			err:    "provider timeout",
+			result: CollectionRunResult{Status: CollectionJobFailed},
+		}),
+	)
+	cookie := loginForTest(t, server)
+
+	rec := doJSON(t, server, cookie, http.MethodPost, "/v1/collection-jobs", CollectionJobCreateRequest{
+		Providers: []string{"gmail"},
+		Month:     6,
+		Year:      2026,
+	})
+	var failed CollectionJob
+	if err := json.NewDecoder(rec.Body).Decode(&failed); err != nil {
+		t.Fatalf("decode job: %v", err)
+	}
+	if failed.Attempt != 1 || failed.QueuedAt == "" || failed.FinishedAt == "" {
+		t.Fatalf("unexpected first attempt: %#v", failed)
+	}
+	retry := doJSON(t, server, cookie, http.MethodPost, "/v1/collection-jobs/"+failed.ID+"/retry", nil)
+	var retried CollectionJob
+	if err := json.NewDecoder(retry.Body).Decode(&retried); err != nil {
+		t.Fatalf("decode retry: %v", err)
+	}
+	if retried.Attempt != 2 {
+		t.Fatalf("expected retry to be attempt 2, got %d", retried.Attempt)
+	}
+	if err := server.WaitForBackground(context.Background()); err != nil {
+		t.Fatalf("wait for retry: %v", err)
+	}
+
+	for status, want := range map[string]int{"": 2, "failed": 2, "succeeded": 0} {
+		list := doJSON(t, server, cookie, http.MethodGet, "/v1/collection-jobs?status="+status, nil)
+		var page CollectionJobList
+		if err := json.NewDecoder(list.Body).Decode(&page); err != nil {
+			t.Fatalf("decode list: %v", err)
+		}
+		if len(page.Items) != want {
+			t.Fatalf("status=%q: expected %d jobs, got %d", status, want, len(page.Items))
+		}
+	}
+	if rec := doJSON(t, server, cookie, http.MethodGet, "/v1/collection-jobs?status=done", nil); rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected unknown status 400, got %d", rec.Code)
+	}
+}
+
+func TestRetryCollectionJobRequiresFailedStatus(t *testing.T) {
+	server := NewServer(WithCollectionRunner(fakeCollectionRunner{
+		result: CollectionRunResult{Status: CollectionJobSucceeded},
+	}))
+	cookie := loginForTest(t, server)
+	job := createCollectionJobForTest(t, server, cookie)
+
+	rec := doJSON(t, server, cookie, http.MethodPost, "/v1/collection-jobs/"+job.ID+"/retry", nil)
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("expected retry of succeeded job 409, got %d", rec.Code)
+	}
+}
+
+func TestRetryCollectionJobIsIdempotent(t *testing.T) {
+	server := NewServer(WithCollectionRunner(fakeCollectionRunner{
+		err:    "provider timeout",
+		result: CollectionRunResult{Status: CollectionJobFailed},
+	}))
+	cookie := loginForTest(t, server)
+	job := createCollectionJobForTest(t, server, cookie)
+
+	retry := func(key string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/v1/collection-jobs/"+job.ID+"/retry", nil)
+		req.AddCookie(cookie)
+		req.Header.Set("Idempotency-Key", key)
+		rec := httptest.NewRecorder()
+		server.Handler().ServeHTTP(rec, req)
+		return rec
+	}
+	first := retry("click-1")
+	second := retry("click-1")
+	if first.Code != http.StatusAccepted || second.Code != http.StatusAccepted {
+		t.Fatalf("expected both calls 202, got %d and %d", first.Code, second.Code)
+	}
+	if first.Body.String() != second.Body.String() {
+		t.Fatalf("expected replayed body, got %s vs %s", first.Body.String(), second.Body.String())
+	}
+	if second.Header().Get("Idempotency-Replayed") != "true" {
+		t.Fatal("expected replay to be marked")
+	}
+	if err := server.WaitForBackground(context.Background()); err != nil {
+		t.Fatalf("wait for retry: %v", err)
+	}
+
+	other := retry("click-2")
+	if other.Code != http.StatusAccepted || other.Body.String() == first.Body.String() {
+		t.Fatalf("expected a new key to start another run, got %d", other.Code)
+	}
+	if err := server.WaitForBackground(context.Background()); err != nil {
+		t.Fatalf("wait for retry: %v", err)
+	}
+	page := doJSON(t, server, cookie, http.MethodGet, "/v1/collection-jobs", nil)
+	var list CollectionJobList
+	if err := json.NewDecoder(page.Body).Decode(&list); err != nil {
+		t.Fatalf("decode list: %v", err)
+	}
+	if len(list.Items) != 3 {
+		t.Fatalf("expected original plus two retries, got %d jobs", len(list.Items))
+	}
+}
+
+func TestIdempotencyKeyExpiresAfterTTL(t *testing.T) {
+	store := NewMemoryStore()
+	server := NewServer(WithStore(store))
+	var calls int
+	handle := func(w http.ResponseWriter) {
+		calls++
+		writeJSON(w, http.StatusAccepted, map[string]int{"call": calls})
+	}
+	session := Session{TenantID: "tenant-alpha"}
+	run := func() {
+		req := httptest.NewRequest(http.MethodPost, "/v1/things", nil)
+		req.Header.Set("Idempotency-Key", "key-1")
+		server.withIdempotency(httptest.NewRecorder(), req, session, handle)
+	}
+
+	run()
+	run()
+	if calls != 1 {
+		t.Fatalf("expected replay within TTL, handler ran %d times", calls)
+	}
+
+	// Backdate the stored record past its TTL.
+	id := idempotencyRecordID("tenant-alpha", "POST /v1/things", "key-1")
+	record := store.idempotencyKeys[id]
+	record.ExpiresAt = time.Now().UTC().Add(-time.Second).Format(time.RFC3339)
+	store.idempotencyKeys[id] = record
+	run()
+	if calls != 2 {
+		t.Fatalf("expected expired key to run again, handler ran %d times", calls)
+	}
+}
+
+func TestIdempotencyClaimHoldsItsKeyForALeaseOnly(t *testing.T) {
+	store := NewMemoryStore()
+	server := NewServer(WithStore(store), WithRequestTimeout(time.Second))
+	id := idempotencyRecordID("tenant-alpha", "POST /v1/things", "key-1")
+	var claimExpiry string
+	handle := func(w http.ResponseWriter) {
+		store.mu.RLock()
+		claimExpiry = store.idempotencyKeys[id].ExpiresAt
+		store.mu.RUnlock()
+		writeJSON(w, http.StatusCreated, map[string]string{"id": "thing-1"})
+	}
+	run := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/v1/things", nil)
+		req.Header.Set("Idempotency-Key", "key-1")
+		rec := httptest.NewRecorder()
+		server.withIdempotency(rec, req, Session{TenantID: "tenant-alpha"}, handle)
+		return rec
+	}
+
+	started := time.Now().UTC()
+	run()
+	if leaseEnd := started.Add(idempotencyClaimLease + time.Second).Format(time.RFC3339); claimExpiry == "" || claimExpiry > leaseEnd {
+		t.Fatalf("expected the in-flight claim to expire within %s, got %s", idempotencyClaimLease, claimExpiry)
+	}
+	if completed := store.idempotencyKeys[id].ExpiresAt; completed < started.Add(defaultIdempotencyTTL).Format(time.RFC3339) {
+		t.Fatalf("expected the completed response kept for the TTL, got %s", completed)
+	}
+
+	// A claim left behind by a crashed request is taken over once its lease
+	// lapses, long before the TTL.
+	stale := time.Now().UTC().Add(-idempotencyClaimLease - time.Minute)
+	store.idempotencyKeys[id] = IdempotencyRecord{
+		TenantID: "tenant-alpha", Scope: "POST /v1/things", Key: "key-1",
+		CreatedAt: stale.Format(time.RFC3339),
+		ExpiresAt: stale.Add(idempotencyClaimLease).Format(time.RFC3339),
+	}
+	if rec := run(); rec.Code != http.StatusCreated || rec.Header().Get("Idempotency-Replayed") != "" {
+		t.Fatalf("expected the lapsed claim to be taken over, got %d %v", rec.Code, rec.Header())
+	}
+}
+
+func TestIdempotencyKeyReleasedOnServerError(t *testing.T) {
+	server := NewServer()
+	var calls int
+	handle := func(w http.ResponseWriter) {
+		calls++
+		apierror.WriteError(w, http.StatusInternalServerError, errors.New("boom"))
+	}
+	for range 2 {
+		req := httptest.NewRequest(http.MethodPost, "/v1/things", nil)
+		req.Header.Set("Idempotency-Key", "key-1")
+		server.withIdempotency(httptest.NewRecorder(), req, Session{TenantID: "tenant-alpha"}, handle)
+	}
+	if calls != 2 {
+		t.Fatalf("expected failed request to be retryable with the same key, handler ran %d times", calls)
+	}
+}
+
+func TestIdempotentReplayKeepsLocationAndETag(t *testing.T) {
+	server := NewServer()
+	handle := func(w http.ResponseWriter) {
+		w.Header().Set("Location", "/v1/things/thing-1")
+		w.Header().Set("ETag", `"1"`)
+		w.Header().Set("X-Debug", "first")
+		writeJSON(w, http.StatusCreated, map[string]string{"id": "thing-1"})
+	}
+	run := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/v1/things", nil)
+		req.Header.Set("Idempotency-Key", "key-1")
+		rec := httptest.NewRecorder()
+		server.withIdempotency(rec, req, Session{TenantID: "tenant-alpha"}, handle)
+		return rec
+	}
+
+	run()
+	replay := run()
+	if replay.Header().Get("Idempotency-Replayed") != "true" || replay.Code != http.StatusCreated {
+		t.Fatalf("expected a replayed 201, got %d %v", replay.Code, replay.Header())
+	}
+	if replay.Header().Get("Location") != "/v1/things/thing-1" || replay.Header().Get("ETag") != `"1"` {
+		t.Fatalf("expected Location and ETag replayed, got %v", replay.Header())
+	}
+	if replay.Header().Get("X-Debug") != "" {
+		t.Fatalf("expected only allowlisted headers replayed, got %v", replay.Header())
+	}
+}
+
+func createCollectionJobForTest(t *testing.T, server *Server, cookie *http.Cookie) CollectionJob {
+	t.Helper()
+
+	rec := doJSON(t, server, cookie, http.MethodPost, "/v1/collection-jobs", CollectionJobCreateRequest{
+		Providers: []string{"gmail"},
+		Month:     6,
+		Year:      2026,
+	})
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected create 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var job CollectionJob
+	if err := json.NewDecoder(rec.Body).Decode(&job); err != nil {
+		t.Fatalf("decode job: %v", err)
+	}
+	return job
+}
+
+func getCollectionJobForTest(t *testing.T, server *Server, cookie *http.Cookie, id string) CollectionJob {
+	t.Helper()
+
+	rec := doJSON(t, server, cookie, http.MethodGet, "/v1/collection-jobs/"+id, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected get 200, got %d", rec.Code)
+	}
+	var job CollectionJob
+	if err := json.NewDecoder(rec.Body).Decode(&job); err != nil {
+		t.Fatalf("decode job: %v", err)
+	}
+	return job
+}
+
+func TestCollectionJobsAreIsolatedBetweenOrgs(t *testing.T) {
+	key, server := newBearerTestServer(t)
+	orgA := signBearerForTest(t, key, "user-a", "org-a", time.Hour)
+	orgB := signBearerForTest(t, key, "user-b", "org-b", time.Hour)
+
+	rec := doBearer(t, server, http.MethodPost, "/v1/collection-jobs", orgA, CollectionJobCreateRequest{Providers: []string{"gmail"}, Month: 6, Year: 2026})
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected create 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var job CollectionJob
+	if err := json.NewDecoder(rec.Body).Decode(&job); err != nil {
+		t.Fatalf("decode job: %v", err)
+	}
+
+	for _, attempt := range []struct{ method, path string }{
+		{http.MethodGet, "/v1/collection-jobs/" + job.ID},
+		{http.MethodPost, "/v1/collection-jobs/" + job.ID + "/retry"},
+	} {
+		if rec := doBearer(t, server, attempt.method, attempt.path, orgB, nil); rec.Code != http.StatusNotFound {
+			t.Fatalf("%s %s as org-b: expected 404, got %d", attempt.method, attempt.path, rec.Code)
+		}
+	}
+}
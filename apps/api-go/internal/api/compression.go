@@ -0,0 +1,234 @@
+package api
+
+import (
+	"compress/gzip"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// compressMinBytes is the smallest body worth compressing: below it the
+// encoding's framing and the CPU cost outweigh the bytes saved.
+const compressMinBytes = 1 << 10
+
+// compressionEncodings are the content codings the server produces, most
+// preferred first. zstd compresses JSON about as well as gzip at a
+// fraction of the CPU.
+var compressionEncodings = []string{"zstd", "gzip"}
+
+type compressor interface {
+	io.WriteCloser
+	Flush() error
+	Reset(io.Writer)
+}
+
+var compressorPools = map[string]*sync.Pool{
+	"gzip": {New: func() any { return gzip.NewWriter(nil) }},
+	"zstd": {New: func() any {
+		// Browsers refuse zstd windows over 8 MiB; a smaller window also
+		// keeps pooled encoders cheap.
+		encoder, _ := zstd.NewWriter(nil, zstd.WithEncoderConcurrency(1), zstd.WithWindowSize(1<<20))
+		return encoder
+	}},
+}
+
+// withCompression compresses responses with the best encoding the client
+// accepts. Only textual bodies such as JSON and CSV are compressed: PDFs,
+// images and archives are compressed already. Bodies are compressed as they
+// are written, so streamed exports stay streamed, but the first
+// compressMinBytes are held back to leave small responses alone. ETags are
+// kept as they are, since they name the invoice version If-Match checks,
+// not the bytes on the wire.
+func (s *Server) withCompression(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead {
+			next.ServeHTTP(w, r)
+			return
+		}
+		cw := &compressWriter{ResponseWriter: w, encoding: negotiateEncoding(r.Header.Get("Accept-Encoding")), status: http.StatusOK}
+		defer cw.close()
+		next.ServeHTTP(cw, r)
+	})
+}
+
+// negotiateEncoding picks the supported coding with the highest q-value in
+// an Accept-Encoding header, preferring the server's order on ties. It
+// returns "" when the body should be sent as is.
+func negotiateEncoding(header string) string {
+	weights := map[string]float64{}
+	for _, part := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(part, ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		weight := 1.0
+		if key, value, ok := strings.Cut(strings.TrimSpace(params), "="); ok && strings.TrimSpace(key) == "q" {
+			parsed, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+			if err != nil {
+				continue
+			}
+			weight = parsed
+		}
+		weights[name] = weight
+	}
+	best, bestWeight := "", 0.0
+	for _, encoding := range compressionEncodings {
+		weight, ok := weights[encoding]
+		if !ok {
+			weight, ok = weights["*"]
+		}
+		if ok && weight > bestWeight {
+			best, bestWeight = encoding, weight
+		}
+	}
+	return best
+}
+
+// compressibleType reports whether a body of the given media type shrinks
+// meaningfully when compressed.
+func compressibleType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	switch {
+	case mediaType == "text/event-stream":
+		// Events are small and must reach the client as they are sent.
+		return false
+	case strings.HasPrefix(mediaType, "text/"),
+		mediaType == "application/json", strings.HasSuffix(mediaType, "+json"),
+		mediaType == "application/xml", strings.HasSuffix(mediaType, "+xml"),
+		mediaType == "application/javascript":
+		return true
+	}
+	return false
+}
+
+// compressWriter holds the status and the start of the body back until it
+// knows whether to compress: once compressMinBytes are written, the body
+// is flushed, or the handler returns.
+type compressWriter struct {
+	http.ResponseWriter
+	encoding string
+	status   int
+	decided  bool
+	buf      []byte
+	encoder  compressor
+}
+
+func (w *compressWriter) WriteHeader(status int) {
+	if w.decided {
+		w.ResponseWriter.WriteHeader(status)
+		return
+	}
+	if status < http.StatusOK {
+		w.ResponseWriter.WriteHeader(status)
+		return
+	}
+	w.status = status
+	if status == http.StatusNoContent || status == http.StatusNotModified {
+		w.decide(false)
+	}
+}
+
+func (w *compressWriter) Write(p []byte) (int, error) {
+	if !w.decided {
+		w.buf = append(w.buf, p...)
+		if len(w.buf) < compressMinBytes {
+			return len(p), nil
+		}
+		if err := w.decide(true); err != nil {
+			return 0, err
+		}
+		return len(p), nil
+	}
+	if w.encoder != nil {
+		return w.encoder.Write(p)
+	}
+	return w.ResponseWriter.Write(p)
+}
+
+// Flush compresses a streamed body whatever its size so far: more is on
+// the way.
+func (w *compressWriter) Flush() {
+	if !w.decided {
+		_ = w.decide(true)
+	}
+	if w.encoder != nil {
+		_ = w.encoder.Flush()
+	}
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (w *compressWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// decide sends the held-back status, compressing the body when it may be
+// and large is set, and writes out what was held back.
+func (w *compressWriter) decide(large bool) error {
+	w.decided = true
+	header := w.Header()
+	if header.Get("Content-Type") == "" && len(w.buf) > 0 {
+		header.Set("Content-Type", http.DetectContentType(w.buf))
+	}
+	compressible := compressibleType(header.Get("Content-Type")) && header.Get("Content-Encoding") == "" &&
+		w.status != http.StatusPartialContent && header.Get("Content-Range") == ""
+	if compressible {
+		addVary(header, "Accept-Encoding")
+		if length, err := strconv.Atoi(header.Get("Content-Length")); err == nil && length < compressMinBytes {
+			large = false
+		}
+	}
+	if compressible && large && w.encoding != "" {
+		header.Del("Content-Length")
+		header.Set("Content-Encoding", w.encoding)
+		w.encoder = compressorPools[w.encoding].Get().(compressor)
+		w.encoder.Reset(w.ResponseWriter)
+	}
+	w.ResponseWriter.WriteHeader(w.status)
+	buf := w.buf
+	w.buf = nil
+	if len(buf) == 0 {
+		return nil
+	}
+	_, err := w.Write(buf)
+	return err
+}
+
+// close sends whatever was held back and ends the compressed stream. A
+// handler that wrote nothing is left for net/http to answer.
+func (w *compressWriter) close() {
+	if !w.decided {
+		if w.status == http.StatusOK && len(w.buf) == 0 {
+			return
+		}
+		_ = w.decide(false)
+	}
+	if w.encoder != nil {
+		_ = w.encoder.Close()
+		w.encoder.Reset(nil)
+		compressorPools[w.encoding].Put(w.encoder)
+		w.encoder = nil
+	}
+}
+
+// addVary adds value to the Vary header unless it is listed already.
+func addVary(header http.Header, value string) {
+	for _, line := range header.Values("Vary") {
+		for _, listed := range strings.Split(line, ",") {
+			if strings.EqualFold(strings.TrimSpace(listed), value) {
+				return
+			}
+		}
+	}
+	header.Add("Vary", value)
+}
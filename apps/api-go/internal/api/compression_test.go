@@ -0,0 +1,167 @@
+package api
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/invoice"
+)
+
+func TestNegotiateEncoding(t *testing.T) {
+	cases := map[string]string{
+		"":                         "",
+		"identity":                 "",
+		"gzip":                     "gzip",
+		"gzip, deflate, br, zstd":  "zstd",
+		"zstd;q=0, gzip":           "gzip",
+		"gzip;q=1.0, zstd;q=0.5":   "gzip",
+		"GZIP;q=0.8":               "gzip",
+		"*":                        "zstd",
+		"*;q=0.5, zstd;q=0":        "gzip",
+		"br":                       "",
+		"gzip;q=0":                 "",
+		"gzip;q=oops, deflate":     "",
+		" zstd ; q=0.9 , gzip;q=1": "gzip",
+	}
+	for header, want := range cases {
+		if got := negotiateEncoding(header); got != want {
+			t.Errorf("negotiateEncoding(%q) = %q, want %q", header, got, want)
+		}
+	}
+}
+
+func TestInvoiceListIsCompressedWhenAccepted(t *testing.T) {
+	server := NewServer()
+	cookie := loginForTest(t, server)
+	for i := range 20 {
+		createInvoiceForTest(t, server, cookie, InvoiceCreateRequest{CustomerID: fmt.Sprintf("cust-%d", i), Currency: "ILS", Subtotal: 1000})
+	}
+	plain := getWithEncodingForTest(server, cookie, "/v1/invoices?limit=100", "")
+	if plain.Header().Get("Content-Encoding") != "" || plain.Header().Get("Vary") != "Accept-Encoding" {
+		t.Fatalf("expected an uncompressed body that varies on Accept-Encoding, got %v", plain.Header())
+	}
+
+	for encoding, decode := range map[string]func(io.Reader) (io.Reader, error){
+		"gzip": func(r io.Reader) (io.Reader, error) { return gzip.NewReader(r) },
+		"zstd": func(r io.Reader) (io.Reader, error) { return zstd.NewReader(r) },
+	} {
+		rec := getWithEncodingForTest(server, cookie, "/v1/invoices?limit=100", encoding)
+		if rec.Code != http.StatusOK || rec.Header().Get("Content-Encoding") != encoding || rec.Header().Get("Vary") != "Accept-Encoding" || rec.Header().Get("Content-Length") != "" {
+			t.Fatalf("%s: unexpected response %d %v", encoding, rec.Code, rec.Header())
+		}
+		if rec.Body.Len() >= plain.Body.Len()/2 {
+			t.Errorf("%s: expected the list to shrink by half, got %d bytes from %d", encoding, rec.Body.Len(), plain.Body.Len())
+		}
+		reader, err := decode(rec.Body)
+		if err != nil {
+			t.Fatalf("%s: open body: %v", encoding, err)
+		}
+		body, err := io.ReadAll(reader)
+		if err != nil || !bytes.Equal(body, plain.Body.Bytes()) {
+			t.Fatalf("%s: expected the decompressed body to match, got %v", encoding, err)
+		}
+	}
+}
+
+func TestSmallAndBinaryResponsesAreNotCompressed(t *testing.T) {
+	server := NewServer()
+	cookie := loginForTest(t, server)
+	created := createInvoiceForTest(t, server, cookie, InvoiceCreateRequest{CustomerID: "cust-1", Currency: "ILS", Subtotal: 1000})
+
+	small := getWithEncodingForTest(server, cookie, "/v1/invoices/"+created.ID, "gzip")
+	if small.Code != http.StatusOK || small.Header().Get("Content-Encoding") != "" || small.Header().Get("Vary") != "Accept-Encoding" {
+		t.Fatalf("expected a small invoice sent as is, got %d %v", small.Code, small.Header())
+	}
+	pdf := getWithEncodingForTest(server, cookie, "/v1/invoices/"+created.ID+"/pdf", "gzip")
+	if pdf.Code != http.StatusOK || pdf.Header().Get("Content-Encoding") != "" || pdf.Header().Get("Content-Length") == "" || !bytes.HasPrefix(pdf.Body.Bytes(), []byte("%PDF")) {
+		t.Fatalf("expected the PDF sent as is, got %d %v", pdf.Code, pdf.Header())
+	}
+	if missing := getWithEncodingForTest(server, cookie, "/v1/nothing-here", "gzip"); missing.Code != http.StatusNotFound || missing.Header().Get("Content-Encoding") != "" {
+		t.Fatalf("expected a small error sent as is, got %d %v", missing.Code, missing.Header())
+	}
+}
+
+func TestInvoiceExportIsStreamedCompressed(t *testing.T) {
+	server := NewServer()
+	cookie := loginForTest(t, server)
+	for i := range 3 {
+		createInvoiceForTest(t, server, cookie, InvoiceCreateRequest{CustomerID: "cust-1", Currency: "ILS", Subtotal: int64(1000 + i), IssuedAt: "2024-03-01T09:00:00Z"})
+	}
+	path := "/v1/invoices/export?issuedFrom=2024-01-01T00:00:00Z&issuedTo=2025-01-01T00:00:00Z"
+	plain := getWithEncodingForTest(server, cookie, path, "")
+
+	// The export is flushed page by page, so even a short one is
+	// compressed rather than held back.
+	rec := getWithEncodingForTest(server, cookie, path, "gzip")
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Encoding") != "gzip" || !strings.HasPrefix(rec.Header().Get("Content-Type"), "text/csv") {
+		t.Fatalf("expected a gzipped CSV, got %d %v", rec.Code, rec.Header())
+	}
+	reader, err := gzip.NewReader(rec.Body)
+	if err != nil {
+		t.Fatalf("open body: %v", err)
+	}
+	body, err := io.ReadAll(reader)
+	if err != nil || !bytes.Equal(body, plain.Body.Bytes()) || strings.Count(string(body), "\n") != 4 {
+		t.Fatalf("expected the header and three rows, got %q, %v", body, err)
+	}
+	if rec.Result().Trailer.Get("X-Export-Truncated") != "false" {
+		t.Fatalf("expected the trailer after the compressed body, got %v", rec.Result().Trailer)
+	}
+}
+
+// BenchmarkCompressInvoiceList compresses a page of 100 invoices, as the
+// list endpoint sends it, and reports the bytes on the wire against the
+// raw body.
+func BenchmarkCompressInvoiceList(b *testing.B) {
+	items := make([]invoice.Invoice, 100)
+	for i := range items {
+		items[i] = invoice.Invoice{
+			ID: fmt.Sprintf("inv-%d-3f9a1c2e", i), TenantID: "tenant-alpha", Number: fmt.Sprintf("INV-%04d", i+1), CustomerID: fmt.Sprintf("cust-%d", i%12),
+			Currency: "ILS", Subtotal: int64(10000 + 37*i), TaxRate: 1700, Tax: int64(1700 + 6*i), Total: int64(11700 + 43*i), Status: invoice.StatusOpen,
+			IssuedAt: "2024-03-01T09:00:00Z", DueAt: "2024-03-31T09:00:00Z", CreatedAt: "2024-03-01T08:55:12Z", UpdatedAt: "2024-03-01T09:00:00Z", Version: 2,
+		}
+	}
+	handler := (&Server{}).withCompression(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, InvoiceList{Items: items})
+	}))
+
+	for _, encoding := range []string{"", "gzip", "zstd"} {
+		name := encoding
+		if name == "" {
+			name = "identity"
+		}
+		b.Run(name, func(b *testing.B) {
+			req := httptest.NewRequest(http.MethodGet, "/v1/invoices", nil)
+			req.Header.Set("Accept-Encoding", encoding)
+			wire := 0
+			for range b.N {
+				rec := httptest.NewRecorder()
+				handler.ServeHTTP(rec, req)
+				wire = rec.Body.Len()
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/invoices", nil))
+			b.ReportMetric(float64(wire), "wire-bytes")
+			b.ReportMetric(float64(wire)/float64(rec.Body.Len()), "ratio")
+		})
+	}
+}
+
+func getWithEncodingForTest(server *Server, cookie *http.Cookie, path, acceptEncoding string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	if acceptEncoding != "" {
+		req.Header.Set("Accept-Encoding", acceptEncoding)
+	}
+	req.AddCookie(cookie)
+	rec := httptest.NewRecorder()
+	server.Handler().ServeHTTP(rec, req)
+	return rec
+}
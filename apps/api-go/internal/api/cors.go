@@ -0,0 +1,93 @@
+package api
+
+import (
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/apierror"
+)
+
+// CORS policy for the allowed origins. Credentials are allowed so the
+// session cookie works cross-origin, which is why origins are matched
+// against an allowlist instead of reflected.
+var (
+	corsAllowedMethods = []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete}
+	corsAllowedHeaders = []string{"Authorization", "Content-Type", idempotencyKeyHeader, "X-Request-ID", "If-Match", "If-None-Match"}
+	corsExposedHeaders = []string{"X-Request-ID", "Idempotency-Replayed", "Retry-After", "ETag", "Location", "Content-Disposition"}
+)
+
+const corsMaxAge = 600
+
+var errOriginNotAllowed = apierror.New(apierror.CodeForbidden, "origin not allowed")
+
+// handleCORS applies the CORS policy to a request that carries an Origin.
+// Preflights are answered here; requests from origins outside the
+// allowlist are rejected with 403. Same-origin requests, such as those from
+// /docs, pass through. It reports false once it has written a response.
+func (s *Server) handleCORS(w http.ResponseWriter, r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	w.Header().Add("Vary", "Origin")
+	if sameOrigin(origin, r) {
+		return true
+	}
+	if !s.corsOrigins[origin] {
+		apierror.WriteError(w, http.StatusForbidden, errOriginNotAllowed)
+		return false
+	}
+	w.Header().Set("Access-Control-Allow-Origin", origin)
+	w.Header().Set("Access-Control-Allow-Credentials", "true")
+
+	method := r.Header.Get("Access-Control-Request-Method")
+	if r.Method != http.MethodOptions || method == "" {
+		w.Header().Set("Access-Control-Expose-Headers", strings.Join(corsExposedHeaders, ", "))
+		return true
+	}
+	w.Header().Add("Vary", "Access-Control-Request-Method")
+	w.Header().Add("Vary", "Access-Control-Request-Headers")
+	if !slices.Contains(corsAllowedMethods, method) || !corsHeadersAllowed(r.Header.Get("Access-Control-Request-Headers")) {
+		apierror.WriteError(w, http.StatusForbidden, apierror.New(apierror.CodeForbidden, "method or headers not allowed"))
+		return false
+	}
+	w.Header().Set("Access-Control-Allow-Methods", strings.Join(corsAllowedMethods, ", "))
+	w.Header().Set("Access-Control-Allow-Headers", strings.Join(corsAllowedHeaders, ", "))
+	w.Header().Set("Access-Control-Max-Age", strconv.Itoa(corsMaxAge))
+	w.WriteHeader(http.StatusNoContent)
+	return false
+}
+
+func corsHeadersAllowed(requested string) bool {
+	for _, header := range strings.Split(requested, ",") {
+		header = strings.TrimSpace(header)
+		if header == "" {
+			continue
+		}
+		if !slices.ContainsFunc(corsAllowedHeaders, func(allowed string) bool { return strings.EqualFold(allowed, header) }) {
+			return false
+		}
+	}
+	return true
+}
+
+// sameOrigin reports whether origin is the scheme and host the request was
+// sent to. Behind a proxy that terminates TLS the scheme is taken from
+// X-Forwarded-Proto.
+func sameOrigin(origin string, r *http.Request) bool {
+	parsed, err := url.Parse(origin)
+	return err == nil && parsed.Host != "" && strings.EqualFold(parsed.Scheme, requestScheme(r)) && strings.EqualFold(parsed.Host, r.Host)
+}
+
+func requestScheme(r *http.Request) string {
+	if r.TLS != nil {
+		return "https"
+	}
+	if proto := strings.ToLower(r.Header.Get("X-Forwarded-Proto")); proto == "https" || proto == "http" {
+		return proto
+	}
+	return "http"
+}
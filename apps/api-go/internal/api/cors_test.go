@@ -0,0 +1,134 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/apierror"
+)
+
+const testOrigin = "https://app.example.com"
+
+func TestCORSPreflightFromAllowedOrigin(t *testing.T) {
+	server := NewServer(WithCORSOrigins(testOrigin))
+	req := httptest.NewRequest(http.MethodOptions, "/v1/invoices", nil)
+	req.Header.Set("Origin", testOrigin)
+	req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+	req.Header.Set("Access-Control-Request-Headers", "content-type, idempotency-key, authorization")
+	rec := httptest.NewRecorder()
+	server.Handler().ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("expected preflight 204, got %d: %s", rec.Code, rec.Body.String())
+	}
+	header := rec.Header()
+	if header.Get("Access-Control-Allow-Origin") != testOrigin || header.Get("Access-Control-Allow-Credentials") != "true" || header.Get("Access-Control-Max-Age") == "" {
+		t.Fatalf("unexpected preflight headers %v", header)
+	}
+	for _, allowed := range []string{"Authorization", "Content-Type", "Idempotency-Key", "X-Request-ID"} {
+		if !strings.Contains(header.Get("Access-Control-Allow-Headers"), allowed) {
+			t.Fatalf("expected %s in allowed headers, got %q", allowed, header.Get("Access-Control-Allow-Headers"))
+		}
+	}
+	if !strings.Contains(header.Get("Access-Control-Allow-Methods"), http.MethodPatch) {
+		t.Fatalf("expected PATCH allowed, got %q", header.Get("Access-Control-Allow-Methods"))
+	}
+}
+
+func TestCORSPreflightRejectsUnlistedMethodsAndHeaders(t *testing.T) {
+	server := NewServer(WithCORSOrigins(testOrigin))
+	for name, headers := range map[string][2]string{
+		"method": {"TRACE", ""},
+		"header": {http.MethodPost, "X-Custom"},
+	} {
+		req := httptest.NewRequest(http.MethodOptions, "/v1/invoices", nil)
+		req.Header.Set("Origin", testOrigin)
+		req.Header.Set("Access-Control-Request-Method", headers[0])
+		req.Header.Set("Access-Control-Request-Headers", headers[1])
+		rec := httptest.NewRecorder()
+		server.Handler().ServeHTTP(rec, req)
+		if rec.Code != http.StatusForbidden || rec.Header().Get("Access-Control-Allow-Methods") != "" {
+			t.Fatalf("%s: expected 403 without allow headers, got %d %v", name, rec.Code, rec.Header())
+		}
+	}
+}
+
+func TestCORSActualRequestExposesRequestID(t *testing.T) {
+	server := NewServer(WithCORSOrigins(testOrigin))
+	cookie := loginForTest(t, server)
+	req := httptest.NewRequest(http.MethodGet, "/v1/invoices", nil)
+	req.Header.Set("Origin", testOrigin)
+	req.AddCookie(cookie)
+	rec := httptest.NewRecorder()
+	server.Handler().ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	header := rec.Header()
+	if header.Get("Access-Control-Allow-Origin") != testOrigin || header.Get("Access-Control-Allow-Credentials") != "true" || header.Get("Vary") != "Origin" {
+		t.Fatalf("unexpected CORS headers %v", header)
+	}
+	if !strings.Contains(header.Get("Access-Control-Expose-Headers"), "X-Request-ID") || header.Get("X-Request-ID") == "" {
+		t.Fatalf("expected X-Request-ID exposed, got %v", header)
+	}
+	for _, exposed := range []string{"Location", "Content-Disposition", "ETag"} {
+		if !strings.Contains(header.Get("Access-Control-Expose-Headers"), exposed) {
+			t.Fatalf("expected %s exposed, got %q", exposed, header.Get("Access-Control-Expose-Headers"))
+		}
+	}
+}
+
+func TestCORSRejectsDisallowedOrigins(t *testing.T) {
+	server := NewServer(WithCORSOrigins(testOrigin))
+	for _, method := range []string{http.MethodOptions, http.MethodGet} {
+		req := httptest.NewRequest(method, "/healthz", nil)
+		req.Header.Set("Origin", "https://evil.example.com")
+		req.Header.Set("Access-Control-Request-Method", http.MethodGet)
+		rec := httptest.NewRecorder()
+		server.Handler().ServeHTTP(rec, req)
+		if code := decodeErrorCodeForTest(t, rec); rec.Code != http.StatusForbidden || code != apierror.CodeForbidden {
+			t.Fatalf("%s: expected 403 %s, got %d %s", method, apierror.CodeForbidden, rec.Code, code)
+		}
+		if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "" {
+			t.Fatalf("%s: expected the origin not reflected, got %q", method, got)
+		}
+	}
+}
+
+func TestCORSLetsSameOriginAndNonBrowserRequestsThrough(t *testing.T) {
+	server := NewServer()
+	for name, origin := range map[string]string{"same origin": "http://example.com", "no origin": ""} {
+		req := httptest.NewRequest(http.MethodGet, "/healthz", nil)
+		if origin != "" {
+			req.Header.Set("Origin", origin)
+		}
+		rec := httptest.NewRecorder()
+		server.Handler().ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK || rec.Header().Get("Access-Control-Allow-Origin") != "" {
+			t.Fatalf("%s: expected 200 without CORS headers, got %d %v", name, rec.Code, rec.Header())
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/healthz", nil)
+	req.Header.Set("Origin", "https://example.com")
+	req.Header.Set("X-Forwarded-Proto", "https")
+	rec := httptest.NewRecorder()
+	server.Handler().ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected the scheme to be taken from X-Forwarded-Proto, got %d", rec.Code)
+	}
+}
+
+func TestCORSSameOriginNeedsTheSameScheme(t *testing.T) {
+	server := NewServer()
+	req := httptest.NewRequest(http.MethodGet, "/healthz", nil)
+	req.Header.Set("Origin", "https://example.com")
+	rec := httptest.NewRecorder()
+	server.Handler().ServeHTTP(rec, req)
+	if code := decodeErrorCodeForTest(t, rec); rec.Code != http.StatusForbidden || code != apierror.CodeForbidden {
+		t.Fatalf("expected an https origin on a plain http request 403 %s, got %d %s", apierror.CodeForbidden, rec.Code, code)
+	}
+}
@@ -0,0 +1,185 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/apierror"
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/invoice"
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/money"
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/pdf"
+)
+
+// InvoiceVoidRequest voids an invoice that never took any money.
+type InvoiceVoidRequest struct {
+	Reason string `json:"reason"`
+}
+
+// CreditNoteCreateRequest credits money received against an invoice. Amount
+// is positive, tax included, in the invoice currency's minor units; when it
+// is left out, everything that can still be credited is.
+type CreditNoteCreateRequest struct {
+	Amount int64  `json:"amount"`
+	Reason string `json:"reason"`
+}
+
+type CreditNoteList struct {
+	Items []invoice.CreditNote `json:"items"`
+}
+
+// handleInvoiceVoid serves POST /v1/invoices/{id}/void. The invoice stays
+// listed and readable with its number and amounts; only its status changes.
+func (s *Server) handleInvoiceVoid(w http.ResponseWriter, r *http.Request, session Session, item invoice.Invoice) {
+	if r.Method != http.MethodPost {
+		apierror.WriteError(w, http.StatusMethodNotAllowed, errMethodNotAllowed)
+		return
+	}
+	var req InvoiceVoidRequest
+	if err := decodeJSON(r, &req); err != nil {
+		writeBodyError(w, err)
+		return
+	}
+	reason := strings.TrimSpace(req.Reason)
+	if err := invoice.ValidateReason(reason, true); err != nil {
+		writeValidationError(w, err)
+		return
+	}
+	updated, err := s.store.VoidInvoice(r.Context(), session.TenantID, item.ID, reason, utcNow())
+	switch {
+	case errors.Is(err, invoice.ErrAlreadyVoid):
+		apierror.WriteError(w, http.StatusConflict, apierror.New(apierror.CodeInvoiceVoid, "invoice is already void"))
+		return
+	case errors.Is(err, invoice.ErrPaymentsReceived):
+		apierror.WriteError(w, http.StatusConflict, errInvoiceHasPayments)
+		return
+	case err != nil:
+		s.writeLookupError(w, err, "invoice")
+		return
+	}
+	if err := s.recordAudit(r.Context(), session.TenantID, routeCtx(r).RequestID, "invoice.voided", "invoice", item.ID, fmt.Sprintf("Voided invoice %s: %s", invoiceLabel(item), reason)); err != nil {
+		s.writeInternalError(w, err)
+		return
+	}
+	w.Header().Set("ETag", invoiceETag(updated))
+	writeJSON(w, http.StatusOK, updated)
+}
+
+// handleInvoiceCreditNote serves POST /v1/invoices/{id}/credit-note.
+func (s *Server) handleInvoiceCreditNote(w http.ResponseWriter, r *http.Request, session Session, item invoice.Invoice) {
+	if r.Method != http.MethodPost {
+		apierror.WriteError(w, http.StatusMethodNotAllowed, errMethodNotAllowed)
+		return
+	}
+	s.withIdempotency(w, r, session, func(w http.ResponseWriter) {
+		s.issueCreditNote(w, r, session, item)
+	})
+}
+
+func (s *Server) issueCreditNote(w http.ResponseWriter, r *http.Request, session Session, item invoice.Invoice) {
+	var req CreditNoteCreateRequest
+	if err := decodeJSON(r, &req); err != nil {
+		writeBodyError(w, err)
+		return
+	}
+	if req.Amount < 0 {
+		writeValidationError(w, apierror.Field("amount", "must be positive"))
+		return
+	}
+	reason := strings.TrimSpace(req.Reason)
+	if err := invoice.ValidateReason(reason, false); err != nil {
+		writeValidationError(w, err)
+		return
+	}
+	now := utcNow()
+	note := invoice.CreditNote{
+		ID:        s.newID("cn"),
+		TenantID:  session.TenantID,
+		InvoiceID: item.ID,
+		Reason:    reason,
+		IssuedAt:  now,
+		CreatedAt: now,
+	}
+	note, updated, err := s.store.IssueCreditNote(r.Context(), note, req.Amount)
+	switch {
+	case errors.Is(err, invoice.ErrNotCreditable):
+		apierror.WriteError(w, http.StatusConflict, apierror.New(apierror.CodeInvoiceNotCreditable, "only money received can be credited; void unpaid invoices instead"))
+		return
+	case errors.Is(err, invoice.ErrCreditExceeded):
+		// Reload so the amount reflects credit notes issued since item was read.
+		if current, err := s.store.GetInvoice(r.Context(), session.TenantID, item.ID); err == nil {
+			item = current
+		}
+		apierror.WriteError(w, http.StatusUnprocessableEntity, &apierror.Error{
+			Code:    apierror.CodeCreditExceeded,
+			Message: "credit exceeds what is left to credit on the invoice",
+			Details: map[string]any{"creditable": item.Creditable()},
+		})
+		return
+	case err != nil:
+		s.writeLookupError(w, err, "invoice")
+		return
+	}
+	amount := money.Money{Amount: -note.Total, Currency: note.Currency}.Format()
+	if err := s.recordAudit(r.Context(), session.TenantID, routeCtx(r).RequestID, "invoice.credited", "invoice", updated.ID, fmt.Sprintf("Issued credit note %s for %s", note.Number, amount)); err != nil {
+		s.writeInternalError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, note)
+}
+
+// handleInvoiceCreditNotes serves GET /v1/invoices/{id}/credit-notes and the
+// credit note and PDF routes below it.
+func (s *Server) handleInvoiceCreditNotes(w http.ResponseWriter, r *http.Request, session Session, item invoice.Invoice, rest string) {
+	creditNoteID, action, _ := strings.Cut(rest, "/")
+	switch {
+	case r.Method != http.MethodGet:
+		apierror.WriteError(w, http.StatusMethodNotAllowed, errMethodNotAllowed)
+	case rest == "":
+		items, err := s.store.ListCreditNotes(r.Context(), session.TenantID, item.ID)
+		if err != nil {
+			s.writeInternalError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, CreditNoteList{Items: items})
+	case action != "" && action != "pdf":
+		apierror.WriteError(w, http.StatusNotFound, errRouteNotFound)
+	default:
+		note, err := s.store.GetCreditNote(r.Context(), session.TenantID, item.ID, creditNoteID)
+		if err != nil {
+			s.writeLookupError(w, err, "credit note")
+			return
+		}
+		if action == "" {
+			writeJSON(w, http.StatusOK, note)
+			return
+		}
+		customer, err := s.billingCustomer(r.Context(), item)
+		if err != nil {
+			s.writeInternalError(w, err)
+			return
+		}
+		s.writePDF(w, r, creditNoteDocument(note, item, customer), "credit-note-"+note.Number+".pdf")
+	}
+}
+
+// creditNoteDocument builds the print view of a credit note: one summary
+// row that credits the original invoice, with the note's negative amounts.
+func creditNoteDocument(note invoice.CreditNote, item invoice.Invoice, customer *Customer) pdf.Document {
+	generatedAt, _ := time.Parse(time.RFC3339, note.CreatedAt)
+	return pdf.Document{
+		Kind:        pdf.KindCreditNote,
+		Number:      note.Number,
+		Credits:     item.Number,
+		Note:        note.Reason,
+		Currency:    note.Currency,
+		IssuedAt:    note.IssuedAt,
+		BillTo:      billTo(item, customer),
+		Subtotal:    note.Subtotal,
+		Tax:         note.Tax,
+		Total:       note.Total,
+		GeneratedAt: generatedAt,
+	}
+}
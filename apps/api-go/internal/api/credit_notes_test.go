@@ -0,0 +1,177 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/apierror"
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/invoice"
+)
+
+func TestVoidInvoiceKeepsItReadableButFrozen(t *testing.T) {
+	server := NewServer()
+	cookie := loginForTest(t, server)
+	created := createInvoiceForTest(t, server, cookie, InvoiceCreateRequest{Number: "INV-0001", CustomerID: "cust-1", Currency: "ILS", Subtotal: 1000, Status: invoice.StatusOpen})
+
+	if rec := doJSON(t, server, cookie, http.MethodPost, "/v1/invoices/"+created.ID+"/void", InvoiceVoidRequest{}); rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected void without a reason to be 400, got %d", rec.Code)
+	}
+	rec := doJSON(t, server, cookie, http.MethodPost, "/v1/invoices/"+created.ID+"/void", InvoiceVoidRequest{Reason: "Issued to the wrong customer"})
+	var voided invoice.Invoice
+	if err := json.NewDecoder(rec.Body).Decode(&voided); err != nil {
+		t.Fatalf("decode invoice: %v", err)
+	}
+	if rec.Code != http.StatusOK || voided.Status != invoice.StatusVoid || voided.VoidedAt == "" || voided.VoidReason != "Issued to the wrong customer" || voided.Number != "INV-0001" || voided.Total != 1000 {
+		t.Fatalf("expected the voided invoice with its number and amounts, got %d %#v", rec.Code, voided)
+	}
+	if rec.Header().Get("ETag") != invoiceETag(voided) {
+		t.Fatalf("expected the new version's ETag, got %q", rec.Header().Get("ETag"))
+	}
+
+	rec = doJSON(t, server, cookie, http.MethodPost, "/v1/invoices/"+created.ID+"/void", InvoiceVoidRequest{Reason: "Again"})
+	if code := decodeErrorCodeForTest(t, rec); rec.Code != http.StatusConflict || code != apierror.CodeInvoiceVoid {
+		t.Fatalf("expected a second void 409 %s, got %d %s", apierror.CodeInvoiceVoid, rec.Code, code)
+	}
+	rec = patchInvoiceForTest(t, server, cookie, "/v1/invoices/"+created.ID, map[string]any{"notes": "changed"})
+	if code := decodeErrorCodeForTest(t, rec); rec.Code != http.StatusConflict || code != apierror.CodeInvoiceVoid {
+		t.Fatalf("expected an update of a void invoice 409 %s, got %d %s", apierror.CodeInvoiceVoid, rec.Code, code)
+	}
+	rec = doJSON(t, server, cookie, http.MethodPost, "/v1/invoices/"+created.ID+"/payments", PaymentCreateRequest{Amount: 100, Method: invoice.PaymentCard})
+	if code := decodeErrorCodeForTest(t, rec); rec.Code != http.StatusConflict || code != apierror.CodeInvoiceNotPayable {
+		t.Fatalf("expected a payment on a void invoice 409 %s, got %d %s", apierror.CodeInvoiceNotPayable, rec.Code, code)
+	}
+	if rec := doJSON(t, server, cookie, http.MethodDelete, "/v1/invoices/"+created.ID, nil); rec.Code != http.StatusConflict {
+		t.Fatalf("expected a delete of a void invoice 409, got %d", rec.Code)
+	}
+	if ids := listInvoiceIDsForTest(t, server, cookie, "/v1/invoices"); len(ids) != 1 || ids[0] != created.ID {
+		t.Fatalf("expected the void invoice to stay listed, got %v", ids)
+	}
+}
+
+func TestVoidInvoiceRefusesInvoicesWithPayments(t *testing.T) {
+	server := NewServer()
+	cookie := loginForTest(t, server)
+	created := createInvoiceForTest(t, server, cookie, InvoiceCreateRequest{Number: "INV-0001", CustomerID: "cust-1", Currency: "ILS", Subtotal: 1000, Status: invoice.StatusOpen})
+	recordPaymentForTest(t, server, cookie, created.ID, PaymentCreateRequest{Amount: 400, Method: invoice.PaymentCard})
+
+	rec := doJSON(t, server, cookie, http.MethodPost, "/v1/invoices/"+created.ID+"/void", InvoiceVoidRequest{Reason: "Duplicate"})
+	if code := decodeErrorCodeForTest(t, rec); rec.Code != http.StatusConflict || code != apierror.CodeInvoicePaid {
+		t.Fatalf("expected void of a part-paid invoice 409 %s, got %d %s", apierror.CodeInvoicePaid, rec.Code, code)
+	}
+	rec = patchInvoiceForTest(t, server, cookie, "/v1/invoices/"+created.ID, map[string]any{"status": invoice.StatusVoid})
+	if code := decodeErrorCodeForTest(t, rec); rec.Code != http.StatusConflict || code != apierror.CodeInvoicePaid {
+		t.Fatalf("expected a void status update 409 %s, got %d %s", apierror.CodeInvoicePaid, rec.Code, code)
+	}
+	if item := getInvoiceForTest(t, server, cookie, created.ID); item.Status != invoice.StatusOpen || item.AmountPaid != 400 {
+		t.Fatalf("expected the invoice untouched, got %#v", item)
+	}
+}
+
+func TestCreditNotesReverseMoneyReceived(t *testing.T) {
+	server := NewServer()
+	cookie := loginForTest(t, server)
+	created := createInvoiceForTest(t, server, cookie, InvoiceCreateRequest{Number: "INV-0001", CustomerID: "cust-1", Currency: "ILS", Subtotal: 1000, TaxRate: 1700, Total: 1170, Status: invoice.StatusOpen})
+	recordPaymentForTest(t, server, cookie, created.ID, PaymentCreateRequest{Amount: 1170, Method: invoice.PaymentCard})
+
+	first := issueCreditNoteForTest(t, server, cookie, created.ID, CreditNoteCreateRequest{Amount: 585, Reason: "Half the order was returned"})
+	if first.Number != "CN-0001" || first.InvoiceID != created.ID || first.Currency != "ILS" || first.Total != -585 || first.Tax != -85 || first.Subtotal != -500 {
+		t.Fatalf("unexpected first credit note %#v", first)
+	}
+	rec := doJSON(t, server, cookie, http.MethodPost, "/v1/invoices/"+created.ID+"/credit-note", CreditNoteCreateRequest{Amount: 600})
+	var body apierror.Error
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatalf("decode error: %v", err)
+	}
+	if rec.Code != http.StatusUnprocessableEntity || body.Code != apierror.CodeCreditExceeded || body.Details["creditable"] != float64(585) {
+		t.Fatalf("expected 422 credit_exceeded with 585 creditable, got %d %#v", rec.Code, body)
+	}
+	second := issueCreditNoteForTest(t, server, cookie, created.ID, CreditNoteCreateRequest{})
+	if second.Number != "CN-0002" || second.Total != -585 || second.Subtotal+second.Tax != second.Total {
+		t.Fatalf("expected the rest credited as CN-0002, got %#v", second)
+	}
+	rec = doJSON(t, server, cookie, http.MethodPost, "/v1/invoices/"+created.ID+"/credit-note", CreditNoteCreateRequest{})
+	if code := decodeErrorCodeForTest(t, rec); rec.Code != http.StatusConflict || code != apierror.CodeInvoiceNotCreditable {
+		t.Fatalf("expected a fully credited invoice 409 %s, got %d %s", apierror.CodeInvoiceNotCreditable, rec.Code, code)
+	}
+
+	paid := getInvoiceForTest(t, server, cookie, created.ID)
+	if paid.Status != invoice.StatusPaid || paid.AmountPaid != 1170 || paid.AmountCredited != 1170 {
+		t.Fatalf("expected the invoice to stay paid with all of it credited, got %#v", paid)
+	}
+	rec = doJSON(t, server, cookie, http.MethodGet, "/v1/invoices/"+created.ID+"/credit-notes", nil)
+	var list CreditNoteList
+	if err := json.NewDecoder(rec.Body).Decode(&list); err != nil {
+		t.Fatalf("decode credit notes: %v", err)
+	}
+	if rec.Code != http.StatusOK || len(list.Items) != 2 || list.Items[0].ID != first.ID || list.Items[1].ID != second.ID {
+		t.Fatalf("expected both credit notes in order, got %d %#v", rec.Code, list.Items)
+	}
+	rec = doJSON(t, server, cookie, http.MethodGet, "/v1/invoices/"+created.ID+"/credit-notes/"+first.ID, nil)
+	var got invoice.CreditNote
+	if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+		t.Fatalf("decode credit note: %v", err)
+	}
+	if rec.Code != http.StatusOK || got != first {
+		t.Fatalf("expected the first credit note, got %d %#v", rec.Code, got)
+	}
+	if rec := doJSON(t, server, cookie, http.MethodGet, "/v1/invoices/"+created.ID+"/credit-notes/cn-missing", nil); rec.Code != http.StatusNotFound {
+		t.Fatalf("expected an unknown credit note 404, got %d", rec.Code)
+	}
+
+	rec = doJSON(t, server, cookie, http.MethodGet, "/v1/invoices/"+created.ID+"/credit-notes/"+first.ID+"/pdf", nil)
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "application/pdf" || !bytes.HasPrefix(rec.Body.Bytes(), []byte("%PDF-")) {
+		t.Fatalf("expected a credit note PDF, got %d %q", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	if disposition := rec.Header().Get("Content-Disposition"); disposition != "attachment; filename=credit-note-CN-0001.pdf" {
+		t.Fatalf("unexpected disposition %q", disposition)
+	}
+}
+
+func TestCreditNotesRejectInvoicesWithoutPayments(t *testing.T) {
+	server := NewServer()
+	cookie := loginForTest(t, server)
+	open := createInvoiceForTest(t, server, cookie, InvoiceCreateRequest{Number: "INV-0001", CustomerID: "cust-1", Currency: "ILS", Subtotal: 1000, Status: invoice.StatusOpen})
+
+	rec := doJSON(t, server, cookie, http.MethodPost, "/v1/invoices/"+open.ID+"/credit-note", CreditNoteCreateRequest{Amount: 100})
+	if code := decodeErrorCodeForTest(t, rec); rec.Code != http.StatusConflict || code != apierror.CodeInvoiceNotCreditable {
+		t.Fatalf("expected an unpaid invoice 409 %s, got %d %s", apierror.CodeInvoiceNotCreditable, rec.Code, code)
+	}
+	if rec := doJSON(t, server, cookie, http.MethodPost, "/v1/invoices/"+open.ID+"/credit-note", CreditNoteCreateRequest{Amount: -100}); rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected a negative amount 400, got %d", rec.Code)
+	}
+	if rec := doJSON(t, server, cookie, http.MethodPost, "/v1/invoices/inv-missing/credit-note", CreditNoteCreateRequest{}); rec.Code != http.StatusNotFound {
+		t.Fatalf("expected an unknown invoice 404, got %d", rec.Code)
+	}
+}
+
+func TestInvoiceSummaryCollectsNetOfCreditNotes(t *testing.T) {
+	server := NewServer()
+	cookie := loginForTest(t, server)
+	now := time.Now().UTC()
+	created := createInvoiceForTest(t, server, cookie, InvoiceCreateRequest{Number: "INV-0001", CustomerID: "cust-1", Currency: "ILS", Subtotal: 1000, Status: invoice.StatusOpen, IssuedAt: now.Format(time.RFC3339)})
+	recordPaymentForTest(t, server, cookie, created.ID, PaymentCreateRequest{Amount: 1000, Method: invoice.PaymentCard, ReceivedAt: now.Format(time.RFC3339)})
+	issueCreditNoteForTest(t, server, cookie, created.ID, CreditNoteCreateRequest{Amount: 250})
+
+	query := "issuedFrom=" + now.AddDate(0, 0, -1).Format(time.DateOnly) + "&issuedTo=" + now.AddDate(0, 0, 1).Format(time.DateOnly)
+	summary := getInvoiceSummaryForTest(t, server, cookie, query)
+	if len(summary.Currencies) != 1 || summary.Currencies[0].Collected != 750 || summary.Currencies[0].Credited != 250 {
+		t.Fatalf("expected 750 collected after a 250 credit, got %#v", summary.Currencies)
+	}
+}
+
+func issueCreditNoteForTest(t *testing.T, server *Server, cookie *http.Cookie, invoiceID string, req CreditNoteCreateRequest) invoice.CreditNote {
+	t.Helper()
+
+	rec := doJSON(t, server, cookie, http.MethodPost, "/v1/invoices/"+invoiceID+"/credit-note", req)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected credit note 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var item invoice.CreditNote
+	if err := json.NewDecoder(rec.Body).Decode(&item); err != nil {
+		t.Fatalf("decode credit note: %v", err)
+	}
+	return item
+}
@@ -0,0 +1,173 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"slices"
+	"testing"
+
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/pdf"
+)
+
+func TestCustomersCRUD(t *testing.T) {
+	server := NewServer()
+	cookie := loginForTest(t, server)
+
+	created := createCustomerForTest(t, server, cookie, CustomerCreateRequest{
+		Name:            " Acme Ltd ",
+		Email:           "billing@acme.example",
+		BillingAddress:  Address{Line1: "1 Main St", City: "Tel Aviv", PostalCode: "6100000", Country: "IL"},
+		DefaultCurrency: "ils",
+	})
+	if created.Name != "Acme Ltd" || created.DefaultCurrency != "ILS" || created.TenantID != "tenant-alpha" {
+		t.Fatalf("expected normalized customer, got %#v", created)
+	}
+
+	rec := doJSON(t, server, cookie, http.MethodPatch, "/v1/customers/"+created.ID, map[string]any{"taxId": "IL-514000000"})
+	var updated Customer
+	if err := json.NewDecoder(rec.Body).Decode(&updated); err != nil {
+		t.Fatalf("decode customer: %v", err)
+	}
+	if rec.Code != http.StatusOK || updated.TaxID != "IL-514000000" || updated.Email != created.Email {
+		t.Fatalf("expected patched tax ID only, got %d %#v", rec.Code, updated)
+	}
+
+	rec = doJSON(t, server, cookie, http.MethodGet, "/v1/customers", nil)
+	var list CustomerList
+	if err := json.NewDecoder(rec.Body).Decode(&list); err != nil {
+		t.Fatalf("decode list: %v", err)
+	}
+	if rec.Code != http.StatusOK || len(list.Items) != 1 || list.Items[0].ID != created.ID {
+		t.Fatalf("expected one customer, got %d %#v", rec.Code, list.Items)
+	}
+
+	if rec := doJSON(t, server, cookie, http.MethodDelete, "/v1/customers/"+created.ID, nil); rec.Code != http.StatusNoContent {
+		t.Fatalf("expected delete 204, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if rec := doJSON(t, server, cookie, http.MethodGet, "/v1/customers/"+created.ID, nil); rec.Code != http.StatusNotFound {
+		t.Fatalf("expected deleted customer 404, got %d", rec.Code)
+	}
+}
+
+func TestCustomersRejectInvalidFields(t *testing.T) {
+	server := NewServer()
+	cookie := loginForTest(t, server)
+
+	for name, req := range map[string]CustomerCreateRequest{
+		"no name":          {Email: "billing@acme.example"},
+		"bad email":        {Name: "Acme", Email: "billing@"},
+		"display name":     {Name: "Acme", Email: "Acme <billing@acme.example>"},
+		"unknown currency": {Name: "Acme", DefaultCurrency: "ABC"},
+	} {
+		if rec := doJSON(t, server, cookie, http.MethodPost, "/v1/customers", req); rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", name, rec.Code)
+		}
+	}
+
+	created := createCustomerForTest(t, server, cookie, CustomerCreateRequest{Name: "Acme"})
+	if rec := doJSON(t, server, cookie, http.MethodPatch, "/v1/customers/"+created.ID, map[string]any{"email": "nope"}); rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected invalid patch 400, got %d", rec.Code)
+	}
+}
+
+func TestCustomerDeleteIsSoft(t *testing.T) {
+	server := NewServer()
+	cookie := loginForTest(t, server)
+	customer := createCustomerForTest(t, server, cookie, CustomerCreateRequest{Name: "Acme"})
+	createInvoiceForTest(t, server, cookie, InvoiceCreateRequest{
+		Number:     "INV-0001",
+		CustomerID: customer.ID,
+		Currency:   "ILS",
+		Subtotal:   10000,
+		Total:      10000,
+	})
+
+	if rec := doJSON(t, server, cookie, http.MethodDelete, "/v1/customers/"+customer.ID+"?force=hard", nil); rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected unknown force 400, got %d", rec.Code)
+	}
+	if rec := doJSON(t, server, cookie, http.MethodDelete, "/v1/customers/"+customer.ID, nil); rec.Code != http.StatusNoContent {
+		t.Fatalf("expected invoiced customer delete 204, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if rec := doJSON(t, server, cookie, http.MethodGet, "/v1/customers/"+customer.ID, nil); rec.Code != http.StatusNotFound {
+		t.Fatalf("expected soft-deleted customer 404, got %d", rec.Code)
+	}
+	if rec := doJSON(t, server, cookie, http.MethodDelete, "/v1/customers/"+customer.ID+"?force=soft", nil); rec.Code != http.StatusNotFound {
+		t.Fatalf("expected second delete 404, got %d", rec.Code)
+	}
+	stored, err := server.store.GetCustomer(context.Background(), "tenant-alpha", customer.ID)
+	if err != nil || stored.DeletedAt == "" {
+		t.Fatalf("expected soft-deleted row to remain, got %#v, %v", stored, err)
+	}
+
+	rec := doJSON(t, server, cookie, http.MethodGet, "/v1/customers/"+customer.ID+"?include_deleted=true", nil)
+	var deleted Customer
+	if err := json.NewDecoder(rec.Body).Decode(&deleted); err != nil {
+		t.Fatalf("decode customer: %v", err)
+	}
+	if rec.Code != http.StatusOK || deleted.DeletedAt != stored.DeletedAt {
+		t.Fatalf("expected include_deleted to return the customer, got %d %#v", rec.Code, deleted)
+	}
+	rec = doJSON(t, server, cookie, http.MethodGet, "/v1/customers?include_deleted=true", nil)
+	var list CustomerList
+	if err := json.NewDecoder(rec.Body).Decode(&list); err != nil {
+		t.Fatalf("decode list: %v", err)
+	}
+	if len(list.Items) != 1 || list.Items[0].ID != customer.ID {
+		t.Fatalf("expected the deleted customer listed, got %#v", list.Items)
+	}
+	if rec := doJSON(t, server, cookie, http.MethodPatch, "/v1/customers/"+customer.ID+"?include_deleted=true", map[string]any{"name": "Acme 2"}); rec.Code != http.StatusNotFound {
+		t.Fatalf("expected soft-deleted customer patch 404, got %d", rec.Code)
+	}
+}
+
+func TestInvoicePDFBillsCustomerRecord(t *testing.T) {
+	renderer := &capturingRenderer{}
+	server := NewServer(WithPDFRenderer(renderer))
+	cookie := loginForTest(t, server)
+	customer := createCustomerForTest(t, server, cookie, CustomerCreateRequest{
+		Name:           "Acme Ltd",
+		BillingAddress: Address{Line1: "1 Main St", City: "Tel Aviv", PostalCode: "6100000", Country: "IL"},
+		TaxID:          "IL-514000000",
+	})
+	created := createInvoiceForTest(t, server, cookie, InvoiceCreateRequest{
+		Number:     "INV-0001",
+		CustomerID: customer.ID,
+		Currency:   "ILS",
+		Subtotal:   10000,
+		Total:      10000,
+	})
+
+	if rec := doJSON(t, server, cookie, http.MethodGet, "/v1/invoices/"+created.ID+"/pdf", nil); rec.Code != http.StatusOK {
+		t.Fatalf("expected pdf 200, got %d", rec.Code)
+	}
+	want := pdf.Party{Name: "Acme Ltd", Lines: []string{"1 Main St", "6100000 Tel Aviv", "IL"}, TaxID: "IL-514000000"}
+	if got := renderer.doc.BillTo; got.Name != want.Name || !slices.Equal(got.Lines, want.Lines) || got.TaxID != want.TaxID {
+		t.Fatalf("expected bill-to %#v, got %#v", want, got)
+	}
+}
+
+func createCustomerForTest(t *testing.T, server *Server, cookie *http.Cookie, req CustomerCreateRequest) Customer {
+	t.Helper()
+
+	rec := doJSON(t, server, cookie, http.MethodPost, "/v1/customers", req)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected create 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var item Customer
+	if err := json.NewDecoder(rec.Body).Decode(&item); err != nil {
+		t.Fatalf("decode customer: %v", err)
+	}
+	return item
+}
+
+type capturingRenderer struct {
+	doc pdf.Document
+}
+
+func (r *capturingRenderer) Render(w io.Writer, doc pdf.Document) error {
+	r.doc = doc
+	_, err := io.WriteString(w, "%PDF-")
+	return err
+}
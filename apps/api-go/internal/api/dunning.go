@@ -0,0 +1,237 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/apierror"
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/invoice"
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/money"
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/notify"
+)
+
+const (
+	dunningBatchSize           = 100
+	defaultDunningScanInterval = time.Hour
+)
+
+// defaultDunningSchedule reminds customers one, seven and fourteen days
+// past the due date.
+var defaultDunningSchedule = []int{1, 7, 14}
+
+var (
+	errInvoiceNotRemindable = apierror.New(apierror.CodeInvoiceNotRemindable, "only open or overdue invoices can be reminded")
+	errCustomerEmailMissing = apierror.New(apierror.CodeCustomerEmailMissing, "the invoice's customer has no email address")
+	errReminderNotSent      = apierror.New(apierror.CodeEmailNotSent, "the reminder email could not be sent; try again later")
+)
+
+// InvoiceReminder is a reminder email sent for an invoice. Steps count from
+// 1 and include manual reminders, which move the schedule on.
+type InvoiceReminder struct {
+	TenantID  string
+	InvoiceID string
+	Step      int
+	Email     string
+	Manual    bool
+	SentAt    string
+}
+
+// DueReminder is an overdue invoice whose reminder Step is due, with the
+// customer address it goes to.
+type DueReminder struct {
+	Invoice invoice.Invoice
+	Step    int
+	Email   string
+}
+
+// SendDunningReminders emails every overdue invoice whose next reminder
+// under the dunning schedule has come due, and reports how many it sent.
+// Paid and void invoices are never overdue, so their dunning stops. A
+// failed email is released and retried on the next scan; the scan stops at
+// the first batch with failures so it does not pick the same invoices
+// again.
+func (s *Server) SendDunningReminders(ctx context.Context) (int, error) {
+	sent := 0
+	for {
+		due, err := s.store.ListDueInvoiceReminders(ctx, utcNow(), s.dunningSchedule, dunningBatchSize)
+		if err != nil {
+			return sent, err
+		}
+		var failures []error
+		for _, reminder := range due {
+			_, err := s.sendReminder(ctx, "", reminder.Invoice, reminder.Email, reminder.Step, false)
+			switch {
+			case err == nil:
+				sent++
+			case errors.Is(err, ErrConflict):
+				// Another replica claimed this step.
+			default:
+				failures = append(failures, err)
+			}
+		}
+		if len(failures) > 0 {
+			return sent, errors.Join(failures...)
+		}
+		if len(due) < dunningBatchSize {
+			return sent, nil
+		}
+	}
+}
+
+// RunDunningScheduler calls SendDunningReminders at startup and then every
+// interval until ctx is done.
+func (s *Server) RunDunningScheduler(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = defaultDunningScanInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		sent, err := s.SendDunningReminders(ctx)
+		if err != nil && ctx.Err() == nil {
+			s.logger.ErrorContext(ctx, "send dunning reminders", "error", err)
+		}
+		if sent > 0 {
+			s.logger.InfoContext(ctx, "sent dunning reminders", "count", sent)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// handleInvoiceRemind serves POST /v1/invoices/{id}/remind, which sends the
+// next reminder right away, even once the schedule is used up.
+func (s *Server) handleInvoiceRemind(w http.ResponseWriter, r *http.Request, session Session, item invoice.Invoice) {
+	if r.Method != http.MethodPost {
+		apierror.WriteError(w, http.StatusMethodNotAllowed, errMethodNotAllowed)
+		return
+	}
+	s.withIdempotency(w, r, session, func(w http.ResponseWriter) {
+		s.remindInvoice(w, r, session, item)
+	})
+}
+
+func (s *Server) remindInvoice(w http.ResponseWriter, r *http.Request, session Session, item invoice.Invoice) {
+	if item.Status != invoice.StatusOpen && item.Status != invoice.StatusOverdue {
+		apierror.WriteError(w, http.StatusConflict, errInvoiceNotRemindable)
+		return
+	}
+	ctx := r.Context()
+	customer, err := s.store.GetCustomer(ctx, session.TenantID, item.CustomerID)
+	if errors.Is(err, ErrNotFound) || (err == nil && customer.Email == "") {
+		apierror.WriteError(w, http.StatusUnprocessableEntity, errCustomerEmailMissing)
+		return
+	}
+	if err != nil {
+		s.writeInternalError(w, err)
+		return
+	}
+	reminders, err := s.store.ListInvoiceReminders(ctx, session.TenantID, item.ID)
+	if err != nil {
+		s.writeInternalError(w, err)
+		return
+	}
+	reminder, err := s.sendReminder(ctx, routeCtx(r).RequestID, item, customer.Email, len(reminders)+1, true)
+	var sendErr *reminderSendError
+	switch {
+	case errors.Is(err, ErrConflict):
+		apierror.WriteError(w, http.StatusConflict, apierror.New(apierror.CodeConflict, "another reminder for this invoice is being sent"))
+		return
+	case errors.As(err, &sendErr):
+		s.logger.ErrorContext(ctx, "send invoice reminder", "invoice_id", item.ID, "error", err)
+		apierror.WriteError(w, http.StatusBadGateway, errReminderNotSent)
+		return
+	case err != nil:
+		s.writeInternalError(w, err)
+		return
+	}
+	item.Dunning = item.DunningState(s.dunningSchedule, reminder.Step, reminder.SentAt)
+	writeJSON(w, http.StatusOK, item)
+}
+
+// reminderSendError is an email the sender refused, as opposed to a store
+// failure around it.
+type reminderSendError struct {
+	err error
+}
+
+func (e *reminderSendError) Error() string {
+	return "send reminder email: " + e.err.Error()
+}
+
+func (e *reminderSendError) Unwrap() error {
+	return e.err
+}
+
+// sendReminder claims step for the invoice, emails it, and records an audit
+// event. The claim is written first so a crash after the email goes out
+// cannot send it again; a refused email releases the claim.
+func (s *Server) sendReminder(ctx context.Context, requestID string, item invoice.Invoice, email string, step int, manual bool) (InvoiceReminder, error) {
+	reminder := InvoiceReminder{
+		TenantID:  item.TenantID,
+		InvoiceID: item.ID,
+		Step:      step,
+		Email:     email,
+		Manual:    manual,
+		SentAt:    utcNow(),
+	}
+	if err := s.store.RecordInvoiceReminder(ctx, reminder); err != nil {
+		return InvoiceReminder{}, err
+	}
+	if err := s.emailSender.Send(ctx, reminderEmail(item, email)); err != nil {
+		if releaseErr := s.store.DeleteInvoiceReminder(ctx, item.TenantID, item.ID, step); releaseErr != nil {
+			return InvoiceReminder{}, errors.Join(&reminderSendError{err: err}, releaseErr)
+		}
+		return InvoiceReminder{}, &reminderSendError{err: err}
+	}
+	message := fmt.Sprintf("Sent reminder %d for invoice %s to %s", step, item.Number, email)
+	if err := s.recordAudit(ctx, item.TenantID, requestID, "invoice.reminder_sent", "invoice", item.ID, message); err != nil {
+		return InvoiceReminder{}, err
+	}
+	return reminder, nil
+}
+
+// reminderEmail is the reminder for item, quoting the amount still due.
+func reminderEmail(item invoice.Invoice, to string) notify.Email {
+	due := money.Money{Amount: item.AmountDue(), Currency: item.Currency}.Format()
+	var body strings.Builder
+	body.WriteString("Hello,\n\n")
+	if dueAt, err := time.Parse(time.RFC3339, item.DueAt); err == nil {
+		tense := "is"
+		if item.Status == invoice.StatusOverdue {
+			tense = "was"
+		}
+		fmt.Fprintf(&body, "This is a reminder that invoice %s %s due on %s. ", item.Number, tense, dueAt.Format(time.DateOnly))
+	} else {
+		fmt.Fprintf(&body, "This is a reminder about invoice %s. ", item.Number)
+	}
+	fmt.Fprintf(&body, "The amount outstanding is %s.\n\n", due)
+	body.WriteString("If you have already paid, please disregard this message.\n")
+
+	subject := fmt.Sprintf("Reminder: invoice %s", item.Number)
+	if item.Status == invoice.StatusOverdue {
+		subject += " is past due"
+	}
+	return notify.Email{To: to, Subject: subject, Body: body.String()}
+}
+
+// withDunning fills in the invoice's dunning state for a single read.
+func (s *Server) withDunning(ctx context.Context, item invoice.Invoice) (invoice.Invoice, error) {
+	reminders, err := s.store.ListInvoiceReminders(ctx, item.TenantID, item.ID)
+	if err != nil {
+		return item, err
+	}
+	lastReminderAt := ""
+	if len(reminders) > 0 {
+		lastReminderAt = reminders[len(reminders)-1].SentAt
+	}
+	item.Dunning = item.DunningState(s.dunningSchedule, len(reminders), lastReminderAt)
+	return item, nil
+}
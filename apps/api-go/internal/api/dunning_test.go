@@ -0,0 +1,167 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/apierror"
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/invoice"
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/notify"
+)
+
+func TestSendDunningRemindersFollowsSchedule(t *testing.T) {
+	sender := &recordingEmailSender{}
+	server := NewServer(WithEmailSender(sender))
+	cookie := loginForTest(t, server)
+	customer := createCustomerForTest(t, server, cookie, CustomerCreateRequest{Name: "Acme", Email: "ap@acme.test"})
+	silent := createCustomerForTest(t, server, cookie, CustomerCreateRequest{Name: "No Mail"})
+	twoDaysAgo := time.Now().UTC().Add(-48 * time.Hour).Format(time.RFC3339)
+	overdue := createInvoiceForTest(t, server, cookie, InvoiceCreateRequest{Number: "INV-0001", CustomerID: customer.ID, Currency: "ILS", Subtotal: 11700, Total: 11700, Status: invoice.StatusOpen, DueAt: twoDaysAgo})
+	createInvoiceForTest(t, server, cookie, InvoiceCreateRequest{Number: "INV-0002", CustomerID: silent.ID, Currency: "ILS", Status: invoice.StatusOpen, DueAt: twoDaysAgo})
+	if _, err := server.MarkOverdueInvoices(context.Background()); err != nil {
+		t.Fatalf("mark overdue: %v", err)
+	}
+
+	if sent, err := server.SendDunningReminders(context.Background()); err != nil || sent != 1 {
+		t.Fatalf("expected one reminder sent, got %d, %v", sent, err)
+	}
+	emails := sender.emails()
+	if len(emails) != 1 || emails[0].To != "ap@acme.test" || !strings.Contains(emails[0].Subject, "INV-0001 is past due") || !strings.Contains(emails[0].Body, "ILS 117.00") {
+		t.Fatalf("unexpected reminder emails %#v", emails)
+	}
+	// The second step is six days out, and a restarted server must not
+	// send the first one again.
+	restarted := NewServer(WithStore(server.store), WithEmailSender(sender))
+	if sent, err := restarted.SendDunningReminders(context.Background()); err != nil || sent != 0 {
+		t.Fatalf("expected nothing more to send, got %d, %v", sent, err)
+	}
+
+	got := getInvoiceForTest(t, server, cookie, overdue.ID)
+	if got.Dunning == nil || got.Dunning.Status != invoice.DunningActive || got.Dunning.RemindersSent != 1 || got.Dunning.LastReminderAt == "" || got.Dunning.NextReminderAt == "" {
+		t.Fatalf("expected active dunning with one reminder, got %#v", got.Dunning)
+	}
+	events, err := server.store.ListAuditEvents(context.Background(), "tenant-alpha", "invoice", overdue.ID)
+	if err != nil {
+		t.Fatalf("list audit events: %v", err)
+	}
+	found := false
+	for _, event := range events {
+		found = found || event.Action == "invoice.reminder_sent"
+	}
+	if !found {
+		t.Fatalf("expected an invoice.reminder_sent audit event, got %#v", events)
+	}
+
+	recordPaymentForTest(t, server, cookie, overdue.ID, PaymentCreateRequest{Amount: 11700, Method: "bank_transfer"})
+	if got := getInvoiceForTest(t, server, cookie, overdue.ID); got.Dunning == nil || got.Dunning.Status != invoice.DunningStopped || got.Dunning.NextReminderAt != "" {
+		t.Fatalf("expected dunning to stop once paid, got %#v", got.Dunning)
+	}
+}
+
+func TestSendDunningRemindersReleasesFailedReminders(t *testing.T) {
+	sender := &recordingEmailSender{err: errors.New("mailbox unavailable")}
+	server := NewServer(WithEmailSender(sender))
+	cookie := loginForTest(t, server)
+	customer := createCustomerForTest(t, server, cookie, CustomerCreateRequest{Name: "Acme", Email: "ap@acme.test"})
+	created := createInvoiceForTest(t, server, cookie, InvoiceCreateRequest{Number: "INV-0001", CustomerID: customer.ID, Currency: "ILS", Status: invoice.StatusOpen, DueAt: time.Now().UTC().Add(-48 * time.Hour).Format(time.RFC3339)})
+	if _, err := server.MarkOverdueInvoices(context.Background()); err != nil {
+		t.Fatalf("mark overdue: %v", err)
+	}
+
+	if sent, err := server.SendDunningReminders(context.Background()); err == nil || sent != 0 {
+		t.Fatalf("expected the failed send to be reported, got %d, %v", sent, err)
+	}
+	if reminders, err := server.store.ListInvoiceReminders(context.Background(), "tenant-alpha", created.ID); err != nil || len(reminders) != 0 {
+		t.Fatalf("expected the failed reminder to be released, got %#v, %v", reminders, err)
+	}
+	sender.setErr(nil)
+	if sent, err := server.SendDunningReminders(context.Background()); err != nil || sent != 1 {
+		t.Fatalf("expected the reminder to be retried, got %d, %v", sent, err)
+	}
+}
+
+func TestRemindInvoiceSendsNextReminder(t *testing.T) {
+	sender := &recordingEmailSender{}
+	server := NewServer(WithEmailSender(sender), WithDunningSchedule(3))
+	cookie := loginForTest(t, server)
+	customer := createCustomerForTest(t, server, cookie, CustomerCreateRequest{Name: "Acme", Email: "ap@acme.test"})
+	silent := createCustomerForTest(t, server, cookie, CustomerCreateRequest{Name: "No Mail"})
+	tomorrow := time.Now().UTC().Add(24 * time.Hour).Format(time.RFC3339)
+	open := createInvoiceForTest(t, server, cookie, InvoiceCreateRequest{Number: "INV-0001", CustomerID: customer.ID, Currency: "ILS", Status: invoice.StatusOpen, DueAt: tomorrow})
+	draft := createInvoiceForTest(t, server, cookie, InvoiceCreateRequest{Number: "INV-0002", CustomerID: customer.ID, Currency: "ILS"})
+	noEmail := createInvoiceForTest(t, server, cookie, InvoiceCreateRequest{Number: "INV-0003", CustomerID: silent.ID, Currency: "ILS", Status: invoice.StatusOpen, DueAt: tomorrow})
+
+	for i := 1; i <= 2; i++ {
+		rec := doJSON(t, server, cookie, http.MethodPost, "/v1/invoices/"+open.ID+"/remind", nil)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected remind 200, got %d: %s", rec.Code, rec.Body.String())
+		}
+	}
+	// Manual reminders go beyond the one-step schedule.
+	got := getInvoiceForTest(t, server, cookie, open.ID)
+	if got.Dunning == nil || got.Dunning.RemindersSent != 2 || got.Dunning.Status != invoice.DunningCompleted {
+		t.Fatalf("expected two reminders sent, got %#v", got.Dunning)
+	}
+	if emails := sender.emails(); len(emails) != 2 || strings.Contains(emails[0].Subject, "past due") {
+		t.Fatalf("expected two reminders for a not yet due invoice, got %#v", emails)
+	}
+
+	cases := []struct {
+		id     string
+		status int
+		code   string
+	}{
+		{draft.ID, http.StatusConflict, apierror.CodeInvoiceNotRemindable},
+		{noEmail.ID, http.StatusUnprocessableEntity, apierror.CodeCustomerEmailMissing},
+	}
+	for _, tc := range cases {
+		rec := doJSON(t, server, cookie, http.MethodPost, "/v1/invoices/"+tc.id+"/remind", nil)
+		if code := decodeErrorCodeForTest(t, rec); rec.Code != tc.status || code != tc.code {
+			t.Fatalf("expected %d %s, got %d %s", tc.status, tc.code, rec.Code, code)
+		}
+	}
+
+	sender.setErr(errors.New("connection refused"))
+	rec := doJSON(t, server, cookie, http.MethodPost, "/v1/invoices/"+open.ID+"/remind", nil)
+	if code := decodeErrorCodeForTest(t, rec); rec.Code != http.StatusBadGateway || code != apierror.CodeEmailNotSent {
+		t.Fatalf("expected 502 %s, got %d %s", apierror.CodeEmailNotSent, rec.Code, code)
+	}
+	if got := getInvoiceForTest(t, server, cookie, open.ID); got.Dunning.RemindersSent != 2 {
+		t.Fatalf("expected the failed reminder not to count, got %#v", got.Dunning)
+	}
+}
+
+// recordingEmailSender keeps every email it is asked to send, or refuses
+// them all with err.
+type recordingEmailSender struct {
+	mu   sync.Mutex
+	sent []notify.Email
+	err  error
+}
+
+func (s *recordingEmailSender) Send(_ context.Context, email notify.Email) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return s.err
+	}
+	s.sent = append(s.sent, email)
+	return nil
+}
+
+func (s *recordingEmailSender) setErr(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.err = err
+}
+
+func (s *recordingEmailSender) emails() []notify.Email {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]notify.Email(nil), s.sent...)
+}
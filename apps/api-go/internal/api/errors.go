@@ -0,0 +1,62 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/apierror"
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/invoice"
+)
+
+var (
+	errRouteNotFound    = apierror.New(apierror.CodeNotFound, "not found")
+	errMethodNotAllowed = apierror.New(apierror.CodeMethodNotAllowed, "method not allowed")
+	errUnauthorized     = apierror.New(apierror.CodeUnauthorized, "unauthorized")
+	errInvoiceNotDraft  = apierror.New(apierror.CodeInvoiceNotDraft, "line items can only change while the invoice is a draft")
+	errInvoiceVoid      = apierror.New(apierror.CodeInvoiceVoid, "void invoices cannot be changed")
+	// errInvoiceIssued protects what an issued invoice bills: its number,
+	// which must stay in its organization's sequence without gaps, and its
+	// customer, currency and amounts, which the customer has been sent.
+	errInvoiceIssued = apierror.New(apierror.CodeInvoiceNotDraft, "the number, customer, currency and amounts of an issued invoice cannot change")
+	// errInvoiceIssuedDelete refuses to delete an issued invoice.
+	errInvoiceIssuedDelete = apierror.New(apierror.CodeInvoiceNotDraft, "issued invoices cannot be deleted; void them instead")
+	// errInvoiceHasPayments refuses to void an invoice that took money.
+	errInvoiceHasPayments = apierror.New(apierror.CodeInvoicePaid, "invoices with payments cannot be voided; issue a credit note instead")
+	// errUnknownCustomer rejects an invoice for a customer ID the tenant
+	// has no live customer under.
+	errUnknownCustomer = apierror.Field("customerId", "is not a customer of this tenant")
+	// errServiceUnavailable answers a store that returned ErrUnavailable.
+	errServiceUnavailable = apierror.New(apierror.CodeServiceUnavailable, "the service is temporarily unavailable; try again shortly")
+)
+
+// writeValidationError answers 400 for a request that failed validation.
+// Field errors from the invoice package are listed under details like those
+// raised here.
+func writeValidationError(w http.ResponseWriter, err error) {
+	apierror.WriteError(w, http.StatusBadRequest, validationError(err))
+}
+
+// validationError is the response body of a validation error.
+func validationError(err error) *apierror.Error {
+	var fieldErr *invoice.ValidationError
+	if errors.As(err, &fieldErr) {
+		return apierror.Field(fieldErr.Field, fieldErr.Message)
+	}
+	var apiErr *apierror.Error
+	if errors.As(err, &apiErr) {
+		return apiErr
+	}
+	return apierror.New(apierror.CodeValidationFailed, err.Error())
+}
+
+// requiredFields is the validation error for a request missing some of its
+// required fields. missing maps each required field to whether it is absent.
+func requiredFields(message string, missing map[string]bool) *apierror.Error {
+	fields := make(map[string]string, len(missing))
+	for field, absent := range missing {
+		if absent {
+			fields[field] = "is required"
+		}
+	}
+	return apierror.Validation(message, fields)
+}
@@ -0,0 +1,139 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/apierror"
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/invoice"
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/logging"
+)
+
+func TestErrorResponsesCarryCodes(t *testing.T) {
+	server := NewServer()
+	cookie := loginForTest(t, server)
+	created := createInvoiceForTest(t, server, cookie, InvoiceCreateRequest{Number: "INV-0001", CustomerID: "cust-1", Currency: "ILS"})
+
+	cases := []struct {
+		name    string
+		method  string
+		path    string
+		body    any
+		status  int
+		code    string
+		details map[string]any
+	}{
+		{"missing invoice", http.MethodGet, "/v1/invoices/inv-missing", nil, http.StatusNotFound, "invoice_not_found", nil},
+		{"unknown route", http.MethodGet, "/v1/nothing", nil, http.StatusNotFound, apierror.CodeNotFound, nil},
+		{"wrong method", http.MethodPut, "/v1/invoices", map[string]any{}, http.StatusMethodNotAllowed, apierror.CodeMethodNotAllowed, nil},
+		{"duplicate number", http.MethodPost, "/v1/invoices", InvoiceCreateRequest{Number: "INV-0001", CustomerID: "cust-1", Currency: "ILS"}, http.StatusConflict, apierror.CodeInvoiceNumberTaken, nil},
+		{"invalid invoice", http.MethodPost, "/v1/invoices", InvoiceCreateRequest{CustomerID: "cust-1", Currency: "shekel"}, http.StatusBadRequest, apierror.CodeValidationFailed,
+			map[string]any{"currency": "must be a 3-letter ISO 4217 code"}},
+		{"invalid line item", http.MethodPost, "/v1/invoices", InvoiceCreateRequest{CustomerID: "cust-1", Currency: "ILS", Items: []InvoiceLineItemRequest{{Quantity: 1}}}, http.StatusBadRequest, apierror.CodeValidationFailed,
+			map[string]any{"items[0].description": "is required"}},
+		{"bad list filter", http.MethodGet, "/v1/invoices?order=sideways", nil, http.StatusBadRequest, apierror.CodeValidationFailed,
+			map[string]any{"order": "must be asc or desc"}},
+		{"missing schedule fields", http.MethodPost, "/v1/schedules", ScheduleCreateRequest{Name: "monthly", Timezone: "UTC"}, http.StatusBadRequest, apierror.CodeValidationFailed,
+			map[string]any{"cron": "is required", "providers": "is required"}},
+		{"missing If-Match", http.MethodPatch, "/v1/invoices/" + created.ID, map[string]any{"status": invoice.StatusOpen}, http.StatusPreconditionRequired, apierror.CodePreconditionRequired, nil},
+	}
+	for _, tc := range cases {
+		rec := doJSON(t, server, cookie, tc.method, tc.path, tc.body)
+		var body apierror.Error
+		if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+			t.Fatalf("%s: decode: %v", tc.name, err)
+		}
+		if rec.Code != tc.status || body.Code != tc.code || body.Message == "" {
+			t.Errorf("%s: expected %d %s, got %d %#v", tc.name, tc.status, tc.code, rec.Code, body)
+			continue
+		}
+		for field, want := range tc.details {
+			if body.Details[field] != want {
+				t.Errorf("%s: expected details[%s] = %v, got %v", tc.name, field, want, body.Details)
+			}
+		}
+	}
+	transition := patchInvoiceForTest(t, server, cookie, "/v1/invoices/"+created.ID, map[string]any{"status": invoice.StatusOverdue})
+	if code := decodeErrorCodeForTest(t, transition); transition.Code != http.StatusUnprocessableEntity || code != apierror.CodeInvalidTransition {
+		t.Errorf("illegal transition: expected 422 %s, got %d %s", apierror.CodeInvalidTransition, transition.Code, code)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/invoices", nil)
+	rec := httptest.NewRecorder()
+	server.Handler().ServeHTTP(rec, req)
+	if !strings.Contains(rec.Body.String(), `"code":"unauthorized"`) {
+		t.Fatalf("expected unauthorized code, got %d %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestPanicsBecomeInternalErrors(t *testing.T) {
+	var buf bytes.Buffer
+	server := NewServer(WithLogger(logging.New(&buf, nil)))
+	handler := server.withMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var lookup map[string]*invoice.Invoice
+		_ = lookup["inv-1"].Number
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/invoices/inv-1", nil)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	var body apierror.Error
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if rec.Code != http.StatusInternalServerError || body.Code != apierror.CodeInternal || body.Message != "internal server error" {
+		t.Fatalf("expected internal_error 500, got %d %#v", rec.Code, body)
+	}
+	requestID := rec.Header().Get("X-Request-ID")
+	var panicLine, accessLine map[string]any
+	for _, raw := range bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n")) {
+		var line map[string]any
+		if err := json.Unmarshal(raw, &line); err != nil {
+			t.Fatalf("decode log line %q: %v", raw, err)
+		}
+		switch line["msg"] {
+		case "panic serving request":
+			panicLine = line
+		case "http request":
+			accessLine = line
+		}
+	}
+	if panicLine == nil || !strings.Contains(panicLine["stack"].(string), "TestPanicsBecomeInternalErrors") || panicLine["request_id"] != requestID {
+		t.Fatalf("expected the panic logged with its stack, got %v", panicLine)
+	}
+	if accessLine == nil || accessLine["status"] != float64(http.StatusInternalServerError) || !strings.Contains(accessLine["error"].(string), "nil pointer") {
+		t.Fatalf("expected the access log to record the panic, got %v", accessLine)
+	}
+}
+
+// failoverStore fails creates the way the Postgres store does once a
+// failover outlasts its retries.
+type failoverStore struct {
+	*MemoryStore
+}
+
+func (s failoverStore) CreateInvoice(context.Context, invoice.Invoice, invoice.NumberFormat) (invoice.Invoice, error) {
+	return invoice.Invoice{}, fmt.Errorf("%w: %w", ErrUnavailable, errors.New("ERROR: could not serialize access (SQLSTATE 40001)"))
+}
+
+func TestUnavailableStoreAnswersServiceUnavailable(t *testing.T) {
+	server := NewServer(WithStore(failoverStore{NewMemoryStore()}))
+	cookie := loginForTest(t, server)
+	seedCustomersForTest(t, server, "tenant-alpha", "cust-1")
+
+	rec := doJSON(t, server, cookie, http.MethodPost, "/v1/invoices", InvoiceCreateRequest{CustomerID: "cust-1", Currency: "ILS", Subtotal: 1000})
+	if code := decodeErrorCodeForTest(t, rec); rec.Code != http.StatusServiceUnavailable || code != apierror.CodeServiceUnavailable {
+		t.Fatalf("expected 503 %s, got %d %s", apierror.CodeServiceUnavailable, rec.Code, code)
+	}
+	if rec.Header().Get("Retry-After") != "1" {
+		t.Fatalf("expected Retry-After, got %v", rec.Header())
+	}
+}
@@ -0,0 +1,69 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/apierror"
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/invoice"
+)
+
+var (
+	errIfMatchRequired = apierror.New(apierror.CodePreconditionRequired, "If-Match is required; send the ETag of the invoice being updated")
+	errVersionMismatch = apierror.New(apierror.CodePreconditionFailed, "invoice changed since it was read; fetch it again and retry")
+)
+
+// invoiceETag is the strong entity tag of an invoice version. It changes
+// with every write, so it also stands in for the representation.
+func invoiceETag(item invoice.Invoice) string {
+	sum := sha256.Sum256(fmt.Appendf(nil, "%s:%d", item.ID, item.Version))
+	return `"` + hex.EncodeToString(sum[:8]) + `"`
+}
+
+// etagListMatches reports whether the If-Match or If-None-Match header value
+// is "*" or names etag. If-None-Match uses the weak comparison, where a
+// W/-prefixed tag matches too; If-Match only accepts the strong tag.
+func etagListMatches(header, etag string, weak bool) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if weak {
+			candidate = strings.TrimPrefix(candidate, "W/")
+		}
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// writeInvoiceRead answers a GET of item, or 304 when the client's
+// If-None-Match already names its current version.
+func writeInvoiceRead(w http.ResponseWriter, r *http.Request, item invoice.Invoice) {
+	etag := invoiceETag(item)
+	w.Header().Set("ETag", etag)
+	if header := r.Header.Get("If-None-Match"); header != "" && etagListMatches(header, etag, true) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	writeJSON(w, http.StatusOK, item)
+}
+
+// checkIfMatch requires an If-Match naming the current version of item
+// before it is modified. It reports false once it has written 428 or 412;
+// a 412 carries the current ETag so the client knows it must re-read.
+func checkIfMatch(w http.ResponseWriter, r *http.Request, item invoice.Invoice) bool {
+	header := r.Header.Get("If-Match")
+	if header == "" {
+		apierror.WriteError(w, http.StatusPreconditionRequired, errIfMatchRequired)
+		return false
+	}
+	if etag := invoiceETag(item); !etagListMatches(header, etag, false) {
+		w.Header().Set("ETag", etag)
+		apierror.WriteError(w, http.StatusPreconditionFailed, errVersionMismatch)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,108 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/apierror"
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/invoice"
+)
+
+func TestInvoiceReadsHonorIfNoneMatch(t *testing.T) {
+	server := NewServer()
+	cookie := loginForTest(t, server)
+	created := createInvoiceForTest(t, server, cookie, InvoiceCreateRequest{CustomerID: "cust-1", Currency: "ILS", Subtotal: 100, Total: 100})
+	path := "/v1/invoices/" + created.ID
+
+	first := doJSON(t, server, cookie, http.MethodGet, path, nil)
+	etag := first.Header().Get("ETag")
+	if first.Code != http.StatusOK || !strings.HasPrefix(etag, `"`) || created.Version != 1 {
+		t.Fatalf("expected a quoted ETag for version 1, got %d %q %d", first.Code, etag, created.Version)
+	}
+	for _, header := range []string{etag, "W/" + etag, `"other", ` + etag, "*"} {
+		rec := conditionalRequestForTest(server, cookie, http.MethodGet, path, "If-None-Match", header, nil)
+		if rec.Code != http.StatusNotModified || rec.Body.Len() != 0 || rec.Header().Get("ETag") != etag {
+			t.Fatalf("If-None-Match %s: expected an empty 304, got %d %q", header, rec.Code, rec.Body.String())
+		}
+	}
+
+	if rec := patchInvoiceForTest(t, server, cookie, path, map[string]any{"status": "open"}); rec.Code != http.StatusOK || rec.Header().Get("ETag") == etag {
+		t.Fatalf("expected the update to answer a new ETag, got %d %q", rec.Code, rec.Header().Get("ETag"))
+	}
+	if rec := conditionalRequestForTest(server, cookie, http.MethodGet, path, "If-None-Match", etag, nil); rec.Code != http.StatusOK {
+		t.Fatalf("expected a changed invoice to be sent again, got %d", rec.Code)
+	}
+}
+
+func TestInvoiceUpdatesRequireTheCurrentVersion(t *testing.T) {
+	server := NewServer()
+	cookie := loginForTest(t, server)
+	created := createInvoiceForTest(t, server, cookie, InvoiceCreateRequest{CustomerID: "cust-1", Currency: "ILS", Subtotal: 100, Total: 100})
+	path := "/v1/invoices/" + created.ID
+	etag := doJSON(t, server, cookie, http.MethodGet, path, nil).Header().Get("ETag")
+
+	// Two editors read the same version; the second write must not win.
+	if rec := conditionalRequestForTest(server, cookie, http.MethodPatch, path, "If-Match", etag, map[string]any{"status": "open"}); rec.Code != http.StatusOK {
+		t.Fatalf("expected the first update 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	rec := conditionalRequestForTest(server, cookie, http.MethodPatch, path, "If-Match", etag, map[string]any{"status": "void"})
+	if code := decodeErrorCodeForTest(t, rec); rec.Code != http.StatusPreconditionFailed || code != apierror.CodePreconditionFailed {
+		t.Fatalf("expected a stale update 412 %s, got %d %s", apierror.CodePreconditionFailed, rec.Code, code)
+	}
+	current := doJSON(t, server, cookie, http.MethodGet, path, nil)
+	if rec.Header().Get("ETag") != current.Header().Get("ETag") {
+		t.Fatalf("expected the 412 to carry the current ETag, got %q", rec.Header().Get("ETag"))
+	}
+	if rec := conditionalRequestForTest(server, cookie, http.MethodPatch, path, "If-Match", "W/"+current.Header().Get("ETag"), map[string]any{"status": "void"}); rec.Code != http.StatusPreconditionFailed {
+		t.Fatalf("expected a weak If-Match to be refused, got %d", rec.Code)
+	}
+
+	got, err := server.store.GetInvoice(context.Background(), "tenant-alpha", created.ID)
+	if err != nil || got.Status != invoice.StatusOpen || got.Version != 2 {
+		t.Fatalf("expected only the first update stored, got %#v, %v", got, err)
+	}
+	stale := got
+	stale.Version = 1
+	if err := server.store.UpdateInvoice(context.Background(), &stale, invoice.DefaultNumberFormat); !errors.Is(err, ErrVersionMismatch) {
+		t.Fatalf("expected the store to refuse an old version, got %v", err)
+	}
+}
+
+func TestInvoiceVersionMovesWithEveryWrite(t *testing.T) {
+	server := NewServer()
+	cookie := loginForTest(t, server)
+	created := createInvoiceForTest(t, server, cookie, InvoiceCreateRequest{CustomerID: "cust-1", Currency: "ILS"})
+	if rec := doJSON(t, server, cookie, http.MethodPost, "/v1/invoices/"+created.ID+"/items", InvoiceLineItemRequest{Description: "Hosting", Quantity: 1, UnitPrice: 500}); rec.Code != http.StatusCreated {
+		t.Fatalf("expected add item 201, got %d", rec.Code)
+	}
+	if rec := patchInvoiceForTest(t, server, cookie, "/v1/invoices/"+created.ID, map[string]any{"status": "open"}); rec.Code != http.StatusOK {
+		t.Fatalf("expected patch 200, got %d", rec.Code)
+	}
+	if rec := doJSON(t, server, cookie, http.MethodPost, "/v1/invoices/"+created.ID+"/payments", PaymentCreateRequest{Amount: 100, Method: "bank_transfer", ReceivedAt: "2026-06-02T09:30:00Z"}); rec.Code != http.StatusCreated {
+		t.Fatalf("expected payment 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	got, err := server.store.GetInvoice(context.Background(), "tenant-alpha", created.ID)
+	if err != nil || got.Version != 4 {
+		t.Fatalf("expected version 4 after three writes, got %d, %v", got.Version, err)
+	}
+}
+
+func conditionalRequestForTest(server *Server, cookie *http.Cookie, method, path, header, value string, payload any) *httptest.ResponseRecorder {
+	var body []byte
+	if payload != nil {
+		body, _ = json.Marshal(payload)
+	}
+	req := httptest.NewRequest(method, path, bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(header, value)
+	req.AddCookie(cookie)
+	rec := httptest.NewRecorder()
+	server.Handler().ServeHTTP(rec, req)
+	return rec
+}
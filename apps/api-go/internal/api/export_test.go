@@ -0,0 +1,120 @@
+package api
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/invoice"
+)
+
+func TestInvoiceExportStreamsMatchingInvoices(t *testing.T) {
+	server := NewServer()
+	cookie := loginForTest(t, server)
+	createInvoiceForTest(t, server, cookie, InvoiceCreateRequest{
+		Number: "=HYPERLINK(1)", CustomerID: "cust-1", Currency: "ILS", Subtotal: 10000, TaxRate: 1700, Total: 11700,
+		Status: invoice.StatusOpen, IssuedAt: "2026-06-01T00:00:00Z",
+	})
+	createInvoiceForTest(t, server, cookie, InvoiceCreateRequest{
+		Number: "INV-0002", CustomerID: "cust-2", Currency: "JPY", Subtotal: 1500, Total: 1500,
+		IssuedAt: "2026-06-02T00:00:00Z",
+	})
+	createInvoiceForTest(t, server, cookie, InvoiceCreateRequest{
+		Number: "INV-0003", CustomerID: "cust-1", Currency: "BHD", Subtotal: 12345, Total: 12345,
+		IssuedAt: "2026-05-01T00:00:00Z",
+	})
+
+	rec := doJSON(t, server, cookie, http.MethodGet, "/v1/invoices/export?issuedFrom=2026-06-01&issuedTo=2026-06-30&order=asc", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected export 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if got := rec.Header().Get("Content-Disposition"); got != "attachment; filename=invoices.csv" {
+		t.Fatalf("unexpected content disposition %q", got)
+	}
+	if got := rec.Header().Get("Content-Type"); !strings.HasPrefix(got, "text/csv") {
+		t.Fatalf("unexpected content type %q", got)
+	}
+	rows, err := csv.NewReader(rec.Body).ReadAll()
+	if err != nil {
+		t.Fatalf("read csv: %v", err)
+	}
+	if len(rows) != 3 || strings.Join(rows[0], ",") != strings.Join(exportHeader, ",") {
+		t.Fatalf("expected header and two rows, got %v", rows)
+	}
+	if rows[1][1] != "'=HYPERLINK(1)" || rows[1][5] != "100.00" || rows[1][6] != "17.00" || rows[1][7] != "117.00" {
+		t.Fatalf("unexpected first row %v", rows[1])
+	}
+	if rows[2][1] != "INV-0002" || rows[2][7] != "1500" {
+		t.Fatalf("expected JPY without decimals, got %v", rows[2])
+	}
+	if got := rec.Result().Trailer.Get("X-Export-Truncated"); got != "false" {
+		t.Fatalf("expected untruncated trailer, got %q", got)
+	}
+
+	rec = doJSON(t, server, cookie, http.MethodGet, "/v1/invoices/export?issuedFrom=2026-01-01&issuedTo=2026-12-31&customerId=cust-1&status=draft", nil)
+	rows, err = csv.NewReader(rec.Body).ReadAll()
+	if err != nil || len(rows) != 2 || rows[1][1] != "INV-0003" || rows[1][7] != "12.345" {
+		t.Fatalf("expected the filtered BHD invoice, got %v, %v", rows, err)
+	}
+}
+
+func TestInvoiceExportPagesThroughLargeResults(t *testing.T) {
+	server := NewServer()
+	cookie := loginForTest(t, server)
+	total := 2*exportPageSize + 7
+	for i := range total {
+		now := utcNow()
+		if _, err := server.store.CreateInvoice(context.Background(), invoice.Invoice{
+			ID:         fmt.Sprintf("inv-%04d", i),
+			TenantID:   "tenant-alpha",
+			Number:     fmt.Sprintf("INV-%04d", i),
+			CustomerID: "cust-1",
+			Currency:   "ILS",
+			Status:     invoice.StatusDraft,
+			IssuedAt:   fmt.Sprintf("2026-06-%02dT00:00:00Z", i%28+1),
+			CreatedAt:  now,
+			UpdatedAt:  now,
+		}, invoice.DefaultNumberFormat); err != nil {
+			t.Fatalf("create invoice: %v", err)
+		}
+	}
+
+	rec := doJSON(t, server, cookie, http.MethodGet, "/v1/invoices/export?issuedFrom=2026-06-01&issuedTo=2026-06-30", nil)
+	rows, err := csv.NewReader(rec.Body).ReadAll()
+	if err != nil {
+		t.Fatalf("read csv: %v", err)
+	}
+	if len(rows) != total+1 {
+		t.Fatalf("expected %d rows, got %d", total+1, len(rows))
+	}
+	seen := make(map[string]bool, total)
+	for _, row := range rows[1:] {
+		if seen[row[0]] {
+			t.Fatalf("row %s exported twice", row[0])
+		}
+		seen[row[0]] = true
+	}
+}
+
+func TestInvoiceExportRejectsUnboundedFilters(t *testing.T) {
+	server := NewServer()
+	cookie := loginForTest(t, server)
+
+	for _, query := range []string{
+		"",
+		"?issuedFrom=2026-06-01",
+		"?status=open&customerId=cust-1",
+		"?issuedFrom=2026-06-01&issuedTo=2026-06-30&limit=10",
+		"?issuedFrom=2026-06-01&issuedTo=2026-06-30&status=sent",
+	} {
+		if rec := doJSON(t, server, cookie, http.MethodGet, "/v1/invoices/export"+query, nil); rec.Code != http.StatusBadRequest {
+			t.Errorf("%q: expected 400, got %d", query, rec.Code)
+		}
+	}
+	if rec := doJSON(t, server, cookie, http.MethodPost, "/v1/invoices/export", nil); rec.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("expected POST 405, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,171 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/apierror"
+)
+
+const (
+	idempotencyKeyHeader      = "Idempotency-Key"
+	idempotencyReplayedHeader = "Idempotency-Replayed"
+	maxIdempotencyKeyLength   = 255
+	defaultIdempotencyTTL     = 24 * time.Hour
+	// idempotencyClaimLease is the least time an in-flight claim holds its
+	// key. A claim whose process died is taken over once it lapses, instead
+	// of answering 409 until the TTL runs out.
+	idempotencyClaimLease = 5 * time.Minute
+)
+
+// replayedHeaders are the response headers stored with an idempotent
+// response and sent again when it is replayed, besides Content-Type.
+var replayedHeaders = []string{"Location", "ETag"}
+
+// IdempotencyRecord remembers the response to a request made with an
+// Idempotency-Key. A record with StatusCode 0 is a claim held by a request
+// that is still running, which expires after a short lease rather than the
+// TTL. RequestHash fingerprints the request body so a key
+// reused for a different request can be told apart from a retry. Headers
+// holds those of replayedHeaders the response set.
+type IdempotencyRecord struct {
+	TenantID    string
+	Scope       string
+	Key         string
+	RequestHash string
+	StatusCode  int
+	ContentType string
+	Headers     map[string]string
+	Body        []byte
+	CreatedAt   string
+	ExpiresAt   string
+}
+
+func (r IdempotencyRecord) completed() bool {
+	return r.StatusCode != 0
+}
+
+// withIdempotency runs handle at most once per tenant, route and
+// Idempotency-Key. A repeated key replays the stored response; requests
+// without the header run normally. Reusing a key with a different body is
+// a client bug and gets 422. Server errors release the key so the client
+// can try again with it.
+func (s *Server) withIdempotency(w http.ResponseWriter, r *http.Request, session Session, handle func(http.ResponseWriter)) {
+	key := strings.TrimSpace(r.Header.Get(idempotencyKeyHeader))
+	if key == "" {
+		handle(w)
+		return
+	}
+	if len(key) > maxIdempotencyKeyLength {
+		apierror.WriteError(w, http.StatusBadRequest, apierror.New(apierror.CodeInvalidIdempotencyKey, "Idempotency-Key must be at most 255 characters"))
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeBodyError(w, jsonBodyError(err))
+		return
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	hash := sha256.Sum256(body)
+
+	now := time.Now().UTC()
+	claim := IdempotencyRecord{
+		TenantID:    session.TenantID,
+		Scope:       r.Method + " " + r.URL.Path,
+		Key:         key,
+		RequestHash: hex.EncodeToString(hash[:]),
+		CreatedAt:   now.Format(time.RFC3339),
+		ExpiresAt:   now.Add(s.idempotencyClaimLease()).Format(time.RFC3339),
+	}
+	existing, claimed, err := s.store.ClaimIdempotencyKey(r.Context(), claim)
+	if err != nil && !errors.Is(err, ErrConflict) {
+		s.writeInternalError(w, err)
+		return
+	}
+	if !claimed {
+		if err == nil && existing.RequestHash != "" && existing.RequestHash != claim.RequestHash {
+			apierror.WriteError(w, http.StatusUnprocessableEntity, apierror.New(apierror.CodeIdempotencyKeyReused, "Idempotency-Key was already used with a different request body"))
+			return
+		}
+		if err != nil || !existing.completed() {
+			apierror.WriteError(w, http.StatusConflict, apierror.New(apierror.CodeIdempotencyKeyInFlight, "a request with this Idempotency-Key is still in progress"))
+			return
+		}
+		w.Header().Set("Content-Type", existing.ContentType)
+		for name, value := range existing.Headers {
+			w.Header().Set(name, value)
+		}
+		w.Header().Set(idempotencyReplayedHeader, "true")
+		w.WriteHeader(existing.StatusCode)
+		_, _ = w.Write(existing.Body)
+		return
+	}
+
+	recorder := &bufferedResponse{ResponseWriter: w, status: http.StatusOK}
+	handle(recorder)
+
+	// The outcome is stored even if the client has gone away, so a retry
+	// after a dropped connection still sees the first result.
+	ctx := context.WithoutCancel(r.Context())
+	if recorder.status >= http.StatusInternalServerError {
+		_ = s.store.ReleaseIdempotencyKey(ctx, claim.TenantID, claim.Scope, claim.Key)
+	} else {
+		claim.StatusCode = recorder.status
+		claim.ContentType = recorder.Header().Get("Content-Type")
+		for _, name := range replayedHeaders {
+			if value := recorder.Header().Get(name); value != "" {
+				if claim.Headers == nil {
+					claim.Headers = map[string]string{}
+				}
+				claim.Headers[name] = value
+			}
+		}
+		claim.Body = recorder.body.Bytes()
+		claim.ExpiresAt = now.Add(s.idempotencyTTL).Format(time.RFC3339)
+		if err := s.store.CompleteIdempotencyKey(ctx, claim); err != nil {
+			_ = s.store.ReleaseIdempotencyKey(ctx, claim.TenantID, claim.Scope, claim.Key)
+		}
+	}
+	w.WriteHeader(recorder.status)
+	_, _ = w.Write(recorder.body.Bytes())
+}
+
+// idempotencyClaimLease is how long a claim holds its key while its request
+// runs: long enough to outlast the request timeout, never past the TTL.
+func (s *Server) idempotencyClaimLease() time.Duration {
+	return min(max(idempotencyClaimLease, 2*s.requestTimeout), s.idempotencyTTL)
+}
+
+// bufferedResponse holds a handler's response so it can be stored before
+// being sent. Headers go straight to the wrapped writer.
+type bufferedResponse struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+	body        bytes.Buffer
+}
+
+func (b *bufferedResponse) WriteHeader(status int) {
+	if b.wroteHeader {
+		return
+	}
+	b.status = status
+	b.wroteHeader = true
+}
+
+func (b *bufferedResponse) Write(p []byte) (int, error) {
+	b.wroteHeader = true
+	return b.body.Write(p)
+}
+
+func (b *bufferedResponse) Unwrap() http.ResponseWriter {
+	return b.ResponseWriter
+}
@@ -0,0 +1,31 @@
+package api
+
+import (
+	"net/http"
+	"runtime"
+	"time"
+
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/buildinfo"
+)
+
+// InfoResponse identifies the running build. UptimeSeconds counts from
+// StartedAt.
+type InfoResponse struct {
+	Version       string `json:"version"`
+	Commit        string `json:"commit"`
+	GoVersion     string `json:"goVersion"`
+	StartedAt     string `json:"startedAt"`
+	UptimeSeconds int64  `json:"uptimeSeconds"`
+}
+
+// handleInfo serves /info. Unlike /healthz it needs a session, so the build
+// a pod runs is not told to anyone who can reach it.
+func (s *Server) handleInfo(w http.ResponseWriter, _ *http.Request, _ Session) {
+	writeJSON(w, http.StatusOK, InfoResponse{
+		Version:       buildinfo.Version,
+		Commit:        buildinfo.Revision(),
+		GoVersion:     runtime.Version(),
+		StartedAt:     s.startedAt.UTC().Format(time.RFC3339),
+		UptimeSeconds: int64(time.Since(s.startedAt).Seconds()),
+	})
+}
@@ -0,0 +1,39 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/buildinfo"
+)
+
+func TestInfoReportsTheInjectedBuild(t *testing.T) {
+	version, commit := buildinfo.Version, buildinfo.Commit
+	t.Cleanup(func() { buildinfo.Version, buildinfo.Commit = version, commit })
+	buildinfo.Version, buildinfo.Commit = "v1.4.0", "0123abcd"
+
+	server := NewServer()
+	server.startedAt = time.Now().Add(-90 * time.Second)
+	cookie := loginForTest(t, server)
+	rec := doJSON(t, server, cookie, http.MethodGet, "/info", nil)
+	var info InfoResponse
+	if err := json.NewDecoder(rec.Body).Decode(&info); err != nil || rec.Code != http.StatusOK {
+		t.Fatalf("expected info 200, got %d, %v", rec.Code, err)
+	}
+	if info.Version != "v1.4.0" || info.Commit != "0123abcd" || info.GoVersion != runtime.Version() {
+		t.Fatalf("expected the injected build, got %#v", info)
+	}
+	if info.UptimeSeconds < 90 || info.StartedAt != server.startedAt.UTC().Format(time.RFC3339) {
+		t.Fatalf("expected the uptime since the start, got %#v", info)
+	}
+
+	rec = httptest.NewRecorder()
+	server.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/info", nil))
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("expected info without a session to be 401, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,191 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/apierror"
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/invoice"
+)
+
+// MaxInvoiceBatchSize caps the invoices of one batch create, which runs in a
+// single transaction.
+const MaxInvoiceBatchSize = 500
+
+// Batch modes. An atomic batch creates every invoice or none; a partial
+// batch creates the valid ones and reports the rest.
+const (
+	BatchAtomic  = "atomic"
+	BatchPartial = "partial"
+)
+
+type InvoiceBatchRequest struct {
+	Items []InvoiceCreateRequest `json:"items"`
+}
+
+// InvoiceBatchResult is the outcome of the item at Index of the request:
+// the created invoice or the error that kept it from being created.
+type InvoiceBatchResult struct {
+	Index   int              `json:"index"`
+	Invoice *invoice.Invoice `json:"invoice,omitempty"`
+	Error   *apierror.Error  `json:"error,omitempty"`
+}
+
+type InvoiceBatchResponse struct {
+	Mode    string               `json:"mode"`
+	Created int                  `json:"created"`
+	Failed  int                  `json:"failed"`
+	Results []InvoiceBatchResult `json:"results"`
+}
+
+var errInvoiceNumberTaken = apierror.New(apierror.CodeInvoiceNumberTaken, "invoice number already exists")
+
+// TakenInvoiceNumbers returns the indexes of the items whose number is stored
+// already, as reported by stored, or used by an earlier item of the same
+// tenant. Items without a number are numbered by the store and never taken.
+// Stores use it to check a batch before writing it.
+func TakenInvoiceNumbers(items []invoice.Invoice, stored func(invoice.Invoice) bool) []int {
+	var taken []int
+	seen := make(map[[2]string]bool, len(items))
+	for i, item := range items {
+		if item.Number == "" {
+			continue
+		}
+		key := [2]string{item.TenantID, item.Number}
+		if seen[key] || stored(item) {
+			taken = append(taken, i)
+		}
+		seen[key] = true
+	}
+	return taken
+}
+
+// handleInvoiceBatch creates up to MaxInvoiceBatchSize invoices in one
+// transaction. In the default atomic mode any invalid item or taken number
+// rejects the batch with 422 and the failing items; ?mode=partial creates the
+// valid items and answers 200 with a result per item.
+func (s *Server) handleInvoiceBatch(w http.ResponseWriter, r *http.Request, session Session) {
+	if r.Method != http.MethodPost {
+		apierror.WriteError(w, http.StatusMethodNotAllowed, errMethodNotAllowed)
+		return
+	}
+	mode := r.URL.Query().Get("mode")
+	switch mode {
+	case "":
+		mode = BatchAtomic
+	case BatchAtomic, BatchPartial:
+	default:
+		writeValidationError(w, apierror.Field("mode", "must be atomic or partial"))
+		return
+	}
+	s.withIdempotency(w, r, session, func(w http.ResponseWriter) {
+		s.createInvoiceBatch(w, r, session, mode)
+	})
+}
+
+func (s *Server) createInvoiceBatch(w http.ResponseWriter, r *http.Request, session Session, mode string) {
+	var req InvoiceBatchRequest
+	if err := decodeJSON(r, &req); err != nil {
+		writeBodyError(w, err)
+		return
+	}
+	switch {
+	case len(req.Items) == 0:
+		writeValidationError(w, apierror.Field("items", "must not be empty"))
+		return
+	case len(req.Items) > MaxInvoiceBatchSize:
+		writeValidationError(w, apierror.Field("items", fmt.Sprintf("must have at most %d invoices", MaxInvoiceBatchSize)))
+		return
+	}
+
+	ctx := r.Context()
+	now := utcNow()
+	results := make([]InvoiceBatchResult, len(req.Items))
+	valid := make([]invoice.Invoice, 0, len(req.Items))
+	indexes := make([]int, 0, len(req.Items))
+	exemptions := map[string]bool{}
+	unknown := map[string]bool{}
+	for i, itemReq := range req.Items {
+		results[i].Index = i
+		customerID := strings.TrimSpace(itemReq.CustomerID)
+		exempt, ok := exemptions[customerID]
+		if !ok && !unknown[customerID] {
+			var err error
+			exempt, err = s.invoiceCustomerTaxExempt(ctx, session.TenantID, customerID)
+			switch {
+			case errors.Is(err, errUnknownCustomer):
+				unknown[customerID] = true
+			case err != nil:
+				s.writeInternalError(w, err)
+				return
+			default:
+				exemptions[customerID] = exempt
+			}
+		}
+		if unknown[customerID] {
+			results[i].Error = errUnknownCustomer
+			continue
+		}
+		item, err := s.newInvoice(session.TenantID, itemReq, exempt, now)
+		if err != nil {
+			results[i].Error = validationError(err)
+			continue
+		}
+		valid = append(valid, item)
+		indexes = append(indexes, i)
+	}
+
+	atomic := mode == BatchAtomic
+	if atomic && len(valid) < len(req.Items) {
+		writeBatchRejected(w, results)
+		return
+	}
+	taken, err := s.store.CreateInvoices(ctx, valid, s.invoiceNumbers, atomic)
+	if err != nil {
+		s.writeInvoiceWriteError(w, err)
+		return
+	}
+	for _, i := range taken {
+		results[indexes[i]].Error = errInvoiceNumberTaken
+	}
+	if atomic && len(taken) > 0 {
+		writeBatchRejected(w, results)
+		return
+	}
+
+	resp := InvoiceBatchResponse{Mode: mode, Results: results}
+	for i, item := range valid {
+		result := &results[indexes[i]]
+		if result.Error != nil {
+			continue
+		}
+		s.announceInvoiceCreated(ctx, session.TenantID, routeCtx(r).RequestID, item)
+		result.Invoice = &item
+		resp.Created++
+	}
+	resp.Failed = len(req.Items) - resp.Created
+	s.metrics.invoicesCreated.Add(float64(resp.Created))
+	status := http.StatusOK
+	if atomic {
+		status = http.StatusCreated
+	}
+	writeJSON(w, status, resp)
+}
+
+// writeBatchRejected answers an atomic batch that created nothing with the
+// items that failed.
+func writeBatchRejected(w http.ResponseWriter, results []InvoiceBatchResult) {
+	failed := []InvoiceBatchResult{}
+	for _, result := range results {
+		if result.Error != nil {
+			failed = append(failed, result)
+		}
+	}
+	apierror.WriteError(w, http.StatusUnprocessableEntity, &apierror.Error{
+		Code:    apierror.CodeBatchRejected,
+		Message: fmt.Sprintf("%d of %d invoices failed; none were created", len(failed), len(results)),
+		Details: map[string]any{"results": failed},
+	})
+}
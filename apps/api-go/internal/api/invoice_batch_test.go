@@ -0,0 +1,140 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/apierror"
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/invoice"
+)
+
+func TestInvoiceBatchIsAtomicByDefault(t *testing.T) {
+	server := NewServer()
+	cookie := loginForTest(t, server)
+	createInvoiceForTest(t, server, cookie, InvoiceCreateRequest{Number: "INV-0001", CustomerID: "cust-1", Currency: "ILS", Subtotal: 100, Total: 100})
+
+	rec := doJSON(t, server, cookie, http.MethodPost, "/v1/invoices/batch", InvoiceBatchRequest{Items: []InvoiceCreateRequest{
+		batchItemForTest("INV-0002"),
+		{Number: "INV-0003", CustomerID: "cust-1"},
+		batchItemForTest("INV-0001"),
+		batchItemForTest("INV-0004"),
+	}})
+	var body apierror.Error
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatalf("decode error: %v", err)
+	}
+	if rec.Code != http.StatusUnprocessableEntity || body.Code != apierror.CodeBatchRejected {
+		t.Fatalf("expected 422 %s, got %d %#v", apierror.CodeBatchRejected, rec.Code, body)
+	}
+	results, _ := body.Details["results"].([]any)
+	if len(results) != 1 || results[0].(map[string]any)["index"] != float64(1) {
+		t.Fatalf("expected only the invalid item reported, got %#v", body.Details)
+	}
+	assertInvoiceCountForTest(t, server, 1)
+
+	// With every item valid, the taken number alone rejects the batch.
+	rec = doJSON(t, server, cookie, http.MethodPost, "/v1/invoices/batch", InvoiceBatchRequest{Items: []InvoiceCreateRequest{batchItemForTest("INV-0002"), batchItemForTest("INV-0001")}})
+	if code := decodeErrorCodeForTest(t, rec); rec.Code != http.StatusUnprocessableEntity || code != apierror.CodeBatchRejected {
+		t.Fatalf("expected a taken number to reject the batch, got %d %s", rec.Code, code)
+	}
+	assertInvoiceCountForTest(t, server, 1)
+
+	rec = doJSON(t, server, cookie, http.MethodPost, "/v1/invoices/batch?mode=atomic", InvoiceBatchRequest{Items: []InvoiceCreateRequest{batchItemForTest("INV-0002"), batchItemForTest("INV-0003")}})
+	resp := decodeBatchResponseForTest(t, rec, http.StatusCreated)
+	if resp.Mode != BatchAtomic || resp.Created != 2 || resp.Failed != 0 || resp.Results[1].Invoice == nil || resp.Results[1].Invoice.Number != "INV-0003" || resp.Results[1].Invoice.Total != 1170 {
+		t.Fatalf("unexpected atomic batch %#v", resp)
+	}
+	assertInvoiceCountForTest(t, server, 3)
+}
+
+func TestInvoiceBatchPartialCreatesValidItems(t *testing.T) {
+	server := NewServer()
+	cookie := loginForTest(t, server)
+	seedCustomersForTest(t, server, "tenant-alpha", "cust-1")
+
+	rec := doJSON(t, server, cookie, http.MethodPost, "/v1/invoices/batch?mode=partial", InvoiceBatchRequest{Items: []InvoiceCreateRequest{
+		batchItemForTest("INV-0001"),
+		{Number: "INV-0002", CustomerID: "cust-1"},
+		batchItemForTest("INV-0001"),
+		batchItemForTest("INV-0003"),
+	}})
+	resp := decodeBatchResponseForTest(t, rec, http.StatusOK)
+	if resp.Mode != BatchPartial || resp.Created != 2 || resp.Failed != 2 || len(resp.Results) != 4 {
+		t.Fatalf("unexpected partial batch %#v", resp)
+	}
+	for i, want := range []string{"", apierror.CodeValidationFailed, apierror.CodeInvoiceNumberTaken, ""} {
+		result := resp.Results[i]
+		if result.Index != i || (want == "") != (result.Invoice != nil) || (want != "" && (result.Error == nil || result.Error.Code != want)) {
+			t.Fatalf("unexpected result %d: %#v", i, result)
+		}
+	}
+	if _, ok := resp.Results[1].Error.Details["currency"]; !ok {
+		t.Fatalf("expected the validation error to name the field, got %#v", resp.Results[1].Error)
+	}
+	assertInvoiceCountForTest(t, server, 2)
+	history, err := server.store.ListAuditLog(context.Background(), "tenant-alpha", AuditEntityInvoice, resp.Results[3].Invoice.ID)
+	if err != nil || len(history) != 1 || history[0].Action != AuditInvoiceCreated {
+		t.Fatalf("expected the batch creation logged, got %#v, %v", history, err)
+	}
+}
+
+func TestInvoiceBatchValidatesTheRequest(t *testing.T) {
+	server := NewServer()
+	cookie := loginForTest(t, server)
+	tooMany := make([]InvoiceCreateRequest, MaxInvoiceBatchSize+1)
+	for i := range tooMany {
+		tooMany[i] = InvoiceCreateRequest{CustomerID: "cust-1", Currency: "ILS", Subtotal: 1, Total: 1}
+	}
+
+	cases := map[string]struct {
+		path string
+		body InvoiceBatchRequest
+	}{
+		"empty":    {"/v1/invoices/batch", InvoiceBatchRequest{}},
+		"too many": {"/v1/invoices/batch", InvoiceBatchRequest{Items: tooMany}},
+		"mode":     {"/v1/invoices/batch?mode=best-effort", InvoiceBatchRequest{Items: []InvoiceCreateRequest{batchItemForTest("INV-0001")}}},
+	}
+	for name, tc := range cases {
+		if rec := doJSON(t, server, cookie, http.MethodPost, tc.path, tc.body); rec.Code != http.StatusBadRequest {
+			t.Fatalf("%s: expected 400, got %d: %s", name, rec.Code, rec.Body.String())
+		}
+	}
+	if rec := doJSON(t, server, cookie, http.MethodGet, "/v1/invoices/batch", nil); rec.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("expected GET 405, got %d", rec.Code)
+	}
+	assertInvoiceCountForTest(t, server, 0)
+}
+
+func batchItemForTest(number string) InvoiceCreateRequest {
+	return InvoiceCreateRequest{
+		Number:     number,
+		CustomerID: "cust-1",
+		Currency:   "ILS",
+		Items:      []InvoiceLineItemRequest{{Description: "Hosting", Quantity: 1, UnitPrice: 1000, TaxRate: 1700}},
+	}
+}
+
+func decodeBatchResponseForTest(t *testing.T, rec *httptest.ResponseRecorder, status int) InvoiceBatchResponse {
+	t.Helper()
+
+	if rec.Code != status {
+		t.Fatalf("expected batch %d, got %d: %s", status, rec.Code, rec.Body.String())
+	}
+	var resp InvoiceBatchResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode batch response: %v", err)
+	}
+	return resp
+}
+
+func assertInvoiceCountForTest(t *testing.T, server *Server, want int) {
+	t.Helper()
+
+	items, err := server.store.ListInvoices(context.Background(), "tenant-alpha", invoice.ListFilter{Limit: 100})
+	if err != nil || len(items) != want {
+		t.Fatalf("expected %d invoices, got %d, %v", want, len(items), err)
+	}
+}
@@ -0,0 +1,401 @@
+package api
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/apierror"
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/invoice"
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/money"
+)
+
+// importChunkSize is how many rows of an import are created per
+// transaction.
+const importChunkSize = 100
+
+var (
+	// errImportStopped is the answer of an import a store failure cut
+	// short. The rows created before it stand.
+	errImportStopped = apierror.New(apierror.CodeInternal, "the import stopped on a storage error; the rows not created can be imported again")
+	// errImportRowFailed marks the rows the store failed on.
+	errImportRowFailed = apierror.New(apierror.CodeInternal, "row could not be stored")
+	// errImportRowNotProcessed marks the rows a stopped import did not get
+	// to.
+	errImportRowNotProcessed = apierror.New(apierror.CodeImportRowNotProcessed, "row was not processed because the import stopped")
+)
+
+// importColumns are the columns an import reads, each with the request
+// field newInvoice reports its errors under.
+var importColumns = map[string]string{
+	"number":            "number",
+	"customer_id":       "customerId",
+	"status":            "status",
+	"currency":          "currency",
+	"subtotal":          "subtotal",
+	"total":             "total",
+	"tax_rate":          "taxRate",
+	"issued_at":         "issuedAt",
+	"due_at":            "dueAt",
+	"paid_at":           "paidAt",
+	"payment_reference": "paymentReference",
+}
+
+// exportOnlyColumns are written by an export and skipped by an import, so an
+// export can be imported as is: IDs and creation times are assigned and tax
+// is computed.
+var exportOnlyColumns = map[string]bool{"id": true, "tax": true, "created_at": true}
+
+// InvoiceImportResult is the outcome of the row starting at Line of the
+// file, the header being line 1: the invoice created from it or the error
+// that rejected it. A dry run leaves InvoiceID empty.
+type InvoiceImportResult struct {
+	Line      int             `json:"line"`
+	InvoiceID string          `json:"invoiceId,omitempty"`
+	Number    string          `json:"number,omitempty"`
+	Error     *apierror.Error `json:"error,omitempty"`
+}
+
+// InvoiceImportResponse reports every row of an import. On a dry run
+// Created counts the rows that would be created. Error is set when a store
+// failure stopped the import partway; it is still answered with 200 so the
+// client learns which rows were created.
+type InvoiceImportResponse struct {
+	DryRun   bool                  `json:"dryRun"`
+	Created  int                   `json:"created"`
+	Rejected int                   `json:"rejected"`
+	Results  []InvoiceImportResult `json:"results"`
+	Error    *apierror.Error       `json:"error,omitempty"`
+}
+
+// importCustomer is what an import needs to know about a customer ID.
+type importCustomer struct {
+	known  bool
+	exempt bool
+}
+
+// handleInvoiceImport creates invoices from the CSV in the "file" part of a
+// multipart upload, held to the attachment size limit. Valid rows are
+// created and the rest rejected with a result per row, as in a partial
+// batch; ?dry_run=true validates every row, numbers included, and creates
+// nothing. The file is spooled to disk and read a row at a time, so memory
+// holds one chunk of invoices however long it is.
+func (s *Server) handleInvoiceImport(w http.ResponseWriter, r *http.Request, session Session) {
+	if r.Method != http.MethodPost {
+		apierror.WriteError(w, http.StatusMethodNotAllowed, errMethodNotAllowed)
+		return
+	}
+	var dryRun bool
+	switch r.URL.Query().Get("dry_run") {
+	case "", "false":
+	case "true":
+		dryRun = true
+	default:
+		writeValidationError(w, apierror.Field("dry_run", "must be true or false"))
+		return
+	}
+	part, err := uploadedFile(r)
+	if err != nil {
+		s.writeUploadError(w, err)
+		return
+	}
+	defer part.Close()
+	// Spooling first means an oversized file is refused before any of it
+	// is imported.
+	spool, err := os.CreateTemp("", "import-*")
+	if err != nil {
+		s.writeInternalError(w, err)
+		return
+	}
+	defer os.Remove(spool.Name())
+	defer spool.Close()
+	size, err := io.Copy(spool, io.LimitReader(part, s.maxAttachmentBytes+1))
+	if err != nil {
+		s.writeUploadError(w, err)
+		return
+	}
+	if size > s.maxAttachmentBytes {
+		s.writeUploadError(w, &http.MaxBytesError{Limit: s.maxAttachmentBytes})
+		return
+	}
+	if _, err := spool.Seek(0, io.SeekStart); err != nil {
+		s.writeInternalError(w, err)
+		return
+	}
+
+	reader := csv.NewReader(spool)
+	reader.ReuseRecord = true
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		writeValidationError(w, apierror.Field(attachmentFormField, "must start with a header row"))
+		return
+	}
+	if err != nil {
+		writeValidationError(w, apierror.Field(attachmentFormField, "has an unreadable header row: "+err.Error()))
+		return
+	}
+	columns, err := importHeader(header)
+	if err != nil {
+		writeValidationError(w, err)
+		return
+	}
+	s.importInvoices(w, r, session, reader, columns, dryRun)
+}
+
+// importHeader returns the column names of the header row, lower-cased,
+// after checking each is known and the required ones are there.
+func importHeader(header []string) ([]string, error) {
+	columns := make([]string, len(header))
+	seen := make(map[string]bool, len(header))
+	for i, name := range header {
+		if i == 0 {
+			name = strings.TrimPrefix(name, "\ufeff")
+		}
+		name = strings.ToLower(strings.TrimSpace(name))
+		if _, ok := importColumns[name]; !ok && !exportOnlyColumns[name] {
+			return nil, apierror.Field(attachmentFormField, fmt.Sprintf("has unknown column %q", name))
+		}
+		if seen[name] {
+			return nil, apierror.Field(attachmentFormField, fmt.Sprintf("has column %q more than once", name))
+		}
+		seen[name] = true
+		columns[i] = name
+	}
+	for _, name := range []string{"customer_id", "currency"} {
+		if !seen[name] {
+			return nil, apierror.Field(attachmentFormField, fmt.Sprintf("is missing column %q", name))
+		}
+	}
+	return columns, nil
+}
+
+func (s *Server) importInvoices(w http.ResponseWriter, r *http.Request, session Session, reader *csv.Reader, columns []string, dryRun bool) {
+	ctx := r.Context()
+	now := utcNow()
+	resp := InvoiceImportResponse{DryRun: dryRun, Results: []InvoiceImportResult{}}
+	customers := map[string]importCustomer{}
+	// A dry run creates nothing, so numbers used by earlier chunks of the
+	// file are tracked here instead of being found in the store.
+	numbered := map[string]bool{}
+	var chunk []invoice.Invoice
+	var indexes []int
+
+	flush := func() error {
+		var taken []int
+		if dryRun {
+			numbers := make([]string, 0, len(chunk))
+			for _, item := range chunk {
+				if item.Number != "" {
+					numbers = append(numbers, item.Number)
+				}
+			}
+			stored, err := s.store.StoredInvoiceNumbers(ctx, session.TenantID, numbers)
+			if err != nil {
+				return err
+			}
+			taken = TakenInvoiceNumbers(chunk, func(item invoice.Invoice) bool {
+				return stored[item.Number] || numbered[item.Number]
+			})
+			for _, number := range numbers {
+				numbered[number] = true
+			}
+		} else {
+			var err error
+			if taken, err = s.store.CreateInvoices(ctx, chunk, s.invoiceNumbers, false); err != nil {
+				return err
+			}
+		}
+		for _, i := range taken {
+			resp.Results[indexes[i]].Error = errInvoiceNumberTaken
+		}
+		for i, item := range chunk {
+			result := &resp.Results[indexes[i]]
+			if result.Error != nil {
+				continue
+			}
+			result.Number = item.Number
+			resp.Created++
+			if dryRun {
+				continue
+			}
+			s.announceInvoiceCreated(ctx, session.TenantID, routeCtx(r).RequestID, item)
+			result.InvoiceID = item.ID
+			s.metrics.invoicesCreated.Inc()
+		}
+		chunk, indexes = chunk[:0], indexes[:0]
+		return nil
+	}
+	// stop reports the import as cut short by err on the rows at failed:
+	// they and the rest of the chunk in hand were not created, and the rest
+	// of the file is listed unread.
+	stop := func(err error, failed ...int) {
+		s.logger.ErrorContext(ctx, "invoice import stopped", "created", resp.Created, "error", err)
+		for _, i := range failed {
+			resp.Results[i].Error = errImportRowFailed
+		}
+		for _, i := range indexes {
+			if resp.Results[i].Error == nil {
+				resp.Results[i].Error = errImportRowNotProcessed
+			}
+		}
+		for {
+			_, err := reader.Read()
+			var parseErr *csv.ParseError
+			if err != nil && !errors.As(err, &parseErr) {
+				break
+			}
+			var line int
+			if parseErr != nil {
+				line = parseErr.StartLine
+			} else {
+				line, _ = reader.FieldPos(0)
+			}
+			resp.Results = append(resp.Results, InvoiceImportResult{Line: line, Error: errImportRowNotProcessed})
+		}
+		resp.Error = errImportStopped
+	}
+
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		var parseErr *csv.ParseError
+		if err != nil && !errors.As(err, &parseErr) {
+			stop(err)
+			break
+		}
+		if parseErr != nil {
+			// The reader carries on with the next row after a malformed one.
+			resp.Results = append(resp.Results, InvoiceImportResult{
+				Line:  parseErr.StartLine,
+				Error: apierror.New(apierror.CodeValidationFailed, "row is not valid CSV: "+parseErr.Err.Error()),
+			})
+			continue
+		}
+		line, _ := reader.FieldPos(0)
+		resp.Results = append(resp.Results, InvoiceImportResult{Line: line})
+		result := &resp.Results[len(resp.Results)-1]
+		item, err := s.importRow(ctx, session.TenantID, columns, record, customers, now)
+		if err != nil {
+			var apiErr *apierror.Error
+			if !errors.As(err, &apiErr) {
+				stop(err, len(resp.Results)-1)
+				break
+			}
+			result.Error = apiErr
+			continue
+		}
+		chunk = append(chunk, item)
+		indexes = append(indexes, len(resp.Results)-1)
+		if len(chunk) == importChunkSize {
+			if err := flush(); err != nil {
+				stop(err, indexes...)
+				break
+			}
+		}
+	}
+	if len(chunk) > 0 && resp.Error == nil {
+		if err := flush(); err != nil {
+			stop(err, indexes...)
+		}
+	}
+	resp.Rejected = len(resp.Results) - resp.Created
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// importRow builds the invoice of one row. Errors the row is rejected for
+// are *apierror.Error naming the column; any other error is the store's.
+func (s *Server) importRow(ctx context.Context, tenantID string, columns, record []string, customers map[string]importCustomer, now string) (invoice.Invoice, error) {
+	values := make(map[string]string, len(columns))
+	for i, name := range columns {
+		values[name] = strings.TrimSpace(record[i])
+	}
+	req := InvoiceCreateRequest{
+		Number:           csvUntext(values["number"]),
+		CustomerID:       csvUntext(values["customer_id"]),
+		Currency:         invoice.NormalizeCurrency(values["currency"]),
+		Status:           invoice.Status(values["status"]),
+		IssuedAt:         values["issued_at"],
+		DueAt:            values["due_at"],
+		PaidAt:           values["paid_at"],
+		PaymentReference: csvUntext(values["payment_reference"]),
+	}
+	if !money.IsCurrency(req.Currency) {
+		return invoice.Invoice{}, apierror.Field("currency", "must be an ISO 4217 currency code")
+	}
+	for _, amount := range []struct {
+		column string
+		dst    *int64
+	}{{"subtotal", &req.Subtotal}, {"total", &req.Total}} {
+		if values[amount.column] == "" {
+			continue
+		}
+		parsed, err := money.ParseDecimal(values[amount.column], req.Currency)
+		if err != nil {
+			return invoice.Invoice{}, apierror.Field(amount.column, fmt.Sprintf("must be a decimal amount with at most %d decimal places", money.Scale(req.Currency)))
+		}
+		*amount.dst = parsed.Amount
+	}
+	if value := values["tax_rate"]; value != "" {
+		rate, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return invoice.Invoice{}, apierror.Field("tax_rate", "must be a whole number of basis points")
+		}
+		req.TaxRate = rate
+	}
+
+	var exempt bool
+	if req.CustomerID != "" {
+		customer, ok := customers[req.CustomerID]
+		if !ok {
+			stored, err := s.store.GetCustomer(ctx, tenantID, req.CustomerID)
+			switch {
+			case errors.Is(err, ErrNotFound):
+			case err != nil:
+				return invoice.Invoice{}, err
+			default:
+				customer = importCustomer{known: stored.DeletedAt == "", exempt: stored.TaxExempt}
+			}
+			customers[req.CustomerID] = customer
+		}
+		if !customer.known {
+			return invoice.Invoice{}, apierror.Field("customer_id", "is not a customer of this tenant")
+		}
+		exempt = customer.exempt
+	}
+	item, err := s.newInvoice(tenantID, req, exempt, now)
+	if err != nil {
+		return invoice.Invoice{}, importFieldError(err)
+	}
+	return item, nil
+}
+
+// importFieldError reports a newInvoice validation error under the column
+// of the field it names.
+func importFieldError(err error) *apierror.Error {
+	var fieldErr *invoice.ValidationError
+	if !errors.As(err, &fieldErr) {
+		return validationError(err)
+	}
+	for column, field := range importColumns {
+		if field == fieldErr.Field {
+			return apierror.Field(column, fieldErr.Message)
+		}
+	}
+	return apierror.Field(fieldErr.Field, fieldErr.Message)
+}
+
+// csvUntext undoes csvText, so exported text reads back as it was stored.
+func csvUntext(value string) string {
+	if len(value) > 1 && value[0] == '\'' && strings.ContainsRune("=+-@\t\r", rune(value[1])) {
+		return value[1:]
+	}
+	return value
+}
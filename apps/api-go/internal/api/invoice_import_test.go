@@ -0,0 +1,236 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/apierror"
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/invoice"
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/logging"
+)
+
+func TestInvoiceImportCreatesValidRowsAndReportsTheRest(t *testing.T) {
+	server := NewServer()
+	cookie := loginForTest(t, server)
+	customer := createCustomerForTest(t, server, cookie, CustomerCreateRequest{Name: "Acme"})
+	createInvoiceForTest(t, server, cookie, InvoiceCreateRequest{Number: "INV-0001", CustomerID: customer.ID, Currency: "ILS"})
+	file := strings.Join([]string{
+		"\ufeffNumber,customer_id,currency,subtotal,tax_rate,status,issued_at",
+		"INV-0100," + customer.ID + ",ils,100.50,1700,open,2026-06-01T00:00:00Z",
+		"," + customer.ID + ",JPY,1500,,open,",
+		"'=HYPERLINK(1)," + customer.ID + ",ILS,1,,,",
+		"INV-0101," + customer.ID + ",XYZ,1,,,",
+		"INV-0102,cust-missing,ILS,1,,,",
+		"INV-0103," + customer.ID + ",JPY,1.5,,,",
+		"INV-0001," + customer.ID + ",ILS,1,,,",
+		"INV-0100," + customer.ID + ",ILS,1,,,",
+		"INV-0104," + customer.ID + ",ILS",
+	}, "\n")
+	wantErrors := map[int]string{
+		5:  "currency",
+		6:  "customer_id",
+		7:  "subtotal",
+		8:  apierror.CodeInvoiceNumberTaken,
+		9:  apierror.CodeInvoiceNumberTaken,
+		10: "wrong number of fields",
+	}
+	check := func(resp InvoiceImportResponse, dryRun bool) {
+		t.Helper()
+		if resp.DryRun != dryRun || resp.Created != 3 || resp.Rejected != 6 || len(resp.Results) != 9 {
+			t.Fatalf("expected 3 created and 6 rejected rows, got %#v", resp)
+		}
+		for i, result := range resp.Results {
+			if result.Line != i+2 {
+				t.Fatalf("expected row %d on line %d, got %#v", i, i+2, result)
+			}
+			want, rejected := wantErrors[result.Line]
+			switch {
+			case !rejected && (result.Error != nil || (result.InvoiceID == "") != dryRun):
+				t.Fatalf("expected line %d to be created, got %#v", result.Line, result)
+			case rejected && (result.Error == nil || result.InvoiceID != ""):
+				t.Fatalf("expected line %d to be rejected, got %#v", result.Line, result)
+			case rejected && result.Error.Code != want && result.Error.Details[want] == nil && !strings.Contains(result.Error.Message, want):
+				t.Fatalf("expected line %d rejected for %s, got %#v", result.Line, want, result.Error)
+			}
+		}
+	}
+
+	rec := importInvoicesForTest(t, server, cookie, "?dry_run=true", file)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected dry run 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	check(decodeImportForTest(t, rec), true)
+	stored, err := server.store.ListInvoices(context.Background(), "tenant-alpha", invoice.ListFilter{Limit: 100})
+	if err != nil || len(stored) != 1 {
+		t.Fatalf("expected the dry run to create nothing, got %d invoices, %v", len(stored), err)
+	}
+
+	rec = importInvoicesForTest(t, server, cookie, "", file)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected import 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	resp := decodeImportForTest(t, rec)
+	check(resp, false)
+	first, err := server.store.GetInvoice(context.Background(), "tenant-alpha", resp.Results[0].InvoiceID)
+	if err != nil || first.Number != "INV-0100" || first.Currency != "ILS" || first.Subtotal != 10050 || first.TaxRate != 1700 || first.Status != invoice.StatusOpen {
+		t.Fatalf("unexpected first imported invoice %#v, %v", first, err)
+	}
+	if numbered := resp.Results[1]; numbered.Number == "" {
+		t.Fatalf("expected the unnumbered row to be numbered, got %#v", numbered)
+	}
+	if quoted, err := server.store.GetInvoice(context.Background(), "tenant-alpha", resp.Results[2].InvoiceID); err != nil || quoted.Number != "=HYPERLINK(1)" {
+		t.Fatalf("expected the export's formula quoting undone, got %#v, %v", quoted, err)
+	}
+
+	// Every row of the file is taken now.
+	rec = importInvoicesForTest(t, server, cookie, "?dry_run=true", file)
+	if again := decodeImportForTest(t, rec); again.Created != 1 || again.Results[1].Error != nil {
+		t.Fatalf("expected only the unnumbered row to import again, got %#v", again)
+	}
+}
+
+func TestInvoiceImportFindsNumbersTakenAcrossChunks(t *testing.T) {
+	server := NewServer()
+	cookie := loginForTest(t, server)
+	customer := createCustomerForTest(t, server, cookie, CustomerCreateRequest{Name: "Acme"})
+	var file strings.Builder
+	file.WriteString("number,customer_id,currency,subtotal\n")
+	for i := range importChunkSize + 1 {
+		fmt.Fprintf(&file, "IMP-%03d,%s,ILS,10\n", i, customer.ID)
+	}
+	fmt.Fprintf(&file, "IMP-000,%s,ILS,10\n", customer.ID)
+
+	for _, query := range []string{"?dry_run=true", ""} {
+		resp := decodeImportForTest(t, importInvoicesForTest(t, server, cookie, query, file.String()))
+		last := resp.Results[len(resp.Results)-1]
+		if resp.Created != importChunkSize+1 || resp.Rejected != 1 || last.Error == nil || last.Error.Code != apierror.CodeInvoiceNumberTaken {
+			t.Fatalf("%s: expected the repeated number in the second chunk rejected, got %d created, %#v", query, resp.Created, last)
+		}
+	}
+}
+
+// chunkFailingStore fails CreateInvoices from call failAt on.
+type chunkFailingStore struct {
+	*MemoryStore
+	calls, failAt int
+}
+
+func (s *chunkFailingStore) CreateInvoices(ctx context.Context, items []invoice.Invoice, format invoice.NumberFormat, atomic bool) ([]int, error) {
+	if s.calls++; s.calls >= s.failAt {
+		return nil, errors.New("connection reset")
+	}
+	return s.MemoryStore.CreateInvoices(ctx, items, format, atomic)
+}
+
+func TestInvoiceImportReportsRowsCreatedBeforeAFailedChunk(t *testing.T) {
+	store := &chunkFailingStore{MemoryStore: NewMemoryStore(), failAt: 2}
+	server := NewServer(WithStore(store), WithLogger(logging.New(io.Discard, nil)))
+	cookie := loginForTest(t, server)
+	customer := createCustomerForTest(t, server, cookie, CustomerCreateRequest{Name: "Acme"})
+	var file strings.Builder
+	file.WriteString("number,customer_id,currency,subtotal\n")
+	rows := 2*importChunkSize + 5
+	for i := range rows {
+		fmt.Fprintf(&file, "IMP-%03d,%s,ILS,10\n", i, customer.ID)
+	}
+
+	rec := importInvoicesForTest(t, server, cookie, "", file.String())
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected a partial report with 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	resp := decodeImportForTest(t, rec)
+	if resp.Error == nil || resp.Created != importChunkSize || resp.Rejected != rows-importChunkSize || len(resp.Results) != rows {
+		t.Fatalf("expected the first chunk created and the rest reported, got error %v, %d created, %d rejected, %d results", resp.Error, resp.Created, resp.Rejected, len(resp.Results))
+	}
+	for i, result := range resp.Results {
+		var want string
+		switch {
+		case i < importChunkSize:
+			if result.InvoiceID == "" || result.Error != nil {
+				t.Fatalf("expected line %d created, got %#v", result.Line, result)
+			}
+			continue
+		case i < 2*importChunkSize:
+			want = apierror.CodeInternal
+		default:
+			want = apierror.CodeImportRowNotProcessed
+		}
+		if result.Line != i+2 || result.InvoiceID != "" || result.Error == nil || result.Error.Code != want {
+			t.Fatalf("expected line %d reported as %s, got %#v", i+2, want, result)
+		}
+	}
+	stored, err := store.ListInvoices(context.Background(), "tenant-alpha", invoice.ListFilter{Limit: invoice.MaxListLimit})
+	if err != nil || len(stored) != importChunkSize {
+		t.Fatalf("expected the first chunk stored, got %d invoices, %v", len(stored), err)
+	}
+}
+
+func TestInvoiceImportRejectsBadFiles(t *testing.T) {
+	server := NewServer(WithAttachmentLimits(64))
+	cookie := loginForTest(t, server)
+	cases := map[string]struct {
+		query  string
+		file   string
+		status int
+	}{
+		"unknown column":  {file: "customer_id,currency,colour\n", status: http.StatusBadRequest},
+		"missing column":  {file: "number,currency\n", status: http.StatusBadRequest},
+		"repeated column": {file: "customer_id,currency,currency\n", status: http.StatusBadRequest},
+		"empty file":      {file: "", status: http.StatusBadRequest},
+		"bad dry_run":     {query: "?dry_run=maybe", file: "customer_id,currency\n", status: http.StatusBadRequest},
+		"too large":       {file: "customer_id,currency\n" + strings.Repeat("cust-1,ILS\n", 10), status: http.StatusRequestEntityTooLarge},
+	}
+	for name, tc := range cases {
+		rec := importInvoicesForTest(t, server, cookie, tc.query, tc.file)
+		if rec.Code != tc.status {
+			t.Fatalf("%s: expected %d, got %d: %s", name, tc.status, rec.Code, rec.Body.String())
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/invoices/import", strings.NewReader("customer_id,currency\n"))
+	req.Header.Set("Content-Type", "text/csv")
+	req.AddCookie(cookie)
+	rec := httptest.NewRecorder()
+	server.Handler().ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnsupportedMediaType {
+		t.Fatalf("expected a bare CSV body to be 415, got %d", rec.Code)
+	}
+}
+
+func importInvoicesForTest(t *testing.T, server *Server, cookie *http.Cookie, query, file string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	part, err := writer.CreateFormFile("file", "invoices.csv")
+	if err != nil {
+		t.Fatalf("create part: %v", err)
+	}
+	part.Write([]byte(file))
+	writer.Close()
+	req := httptest.NewRequest(http.MethodPost, "/v1/invoices/import"+query, &body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	req.AddCookie(cookie)
+	rec := httptest.NewRecorder()
+	server.Handler().ServeHTTP(rec, req)
+	return rec
+}
+
+func decodeImportForTest(t *testing.T, rec *httptest.ResponseRecorder) InvoiceImportResponse {
+	t.Helper()
+
+	var resp InvoiceImportResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode import: %v", err)
+	}
+	return resp
+}
@@ -0,0 +1,150 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"sync"
+	"testing"
+
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/apierror"
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/invoice"
+)
+
+func TestInvoicesWithoutNumberAreNumberedInSequence(t *testing.T) {
+	server := NewServer()
+	cookie := loginForTest(t, server)
+	unnumbered := InvoiceCreateRequest{CustomerID: "cust-1", Currency: "ILS", Subtotal: 1000, Status: invoice.StatusOpen}
+
+	if first := createInvoiceForTest(t, server, cookie, unnumbered); first.Number != "INV-0001" {
+		t.Fatalf("expected INV-0001, got %q", first.Number)
+	}
+	createInvoiceForTest(t, server, cookie, InvoiceCreateRequest{Number: "INV-0002", CustomerID: "cust-1", Currency: "ILS", Subtotal: 1000})
+	if next := createInvoiceForTest(t, server, cookie, unnumbered); next.Number != "INV-0003" {
+		t.Fatalf("expected the explicitly taken INV-0002 skipped, got %q", next.Number)
+	}
+	// A create that fails is not given a number, so none is skipped.
+	if rec := doJSON(t, server, cookie, http.MethodPost, "/v1/invoices", InvoiceCreateRequest{CustomerID: "cust-1", Currency: "ILS", Subtotal: -1, Status: invoice.StatusOpen}); rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected invalid invoice 400, got %d", rec.Code)
+	}
+	if next := createInvoiceForTest(t, server, cookie, unnumbered); next.Number != "INV-0004" {
+		t.Fatalf("expected INV-0004, got %q", next.Number)
+	}
+
+	other, err := server.store.CreateInvoice(context.Background(), invoice.Invoice{
+		ID: "inv-other", TenantID: "tenant-beta", CustomerID: "cust-1", Currency: "ILS", Status: invoice.StatusOpen, CreatedAt: utcNow(), UpdatedAt: utcNow(),
+	}, server.invoiceNumbers)
+	if err != nil || other.Number != "INV-0001" {
+		t.Fatalf("expected another organization to start at INV-0001, got %q, %v", other.Number, err)
+	}
+}
+
+func TestInvoiceNumbersRestartYearly(t *testing.T) {
+	server := NewServer(WithInvoiceNumberFormat(invoice.NumberFormat{Prefix: "INV-", Width: 6, YearlyReset: true}))
+	cookie := loginForTest(t, server)
+	create := func(issuedAt string) string {
+		return createInvoiceForTest(t, server, cookie, InvoiceCreateRequest{CustomerID: "cust-1", Currency: "ILS", Subtotal: 1000, Status: invoice.StatusOpen, IssuedAt: issuedAt}).Number
+	}
+
+	got := []string{create("2024-03-01T09:00:00Z"), create("2024-12-31T09:00:00Z"), create("2025-01-02T09:00:00Z"), create("2024-11-01T09:00:00Z")}
+	want := []string{"INV-2024-000001", "INV-2024-000002", "INV-2025-000001", "INV-2024-000003"}
+	if !slices.Equal(got, want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+}
+
+func TestConcurrentInvoiceCreatesGetGapFreeNumbers(t *testing.T) {
+	server := NewServer()
+	cookie := loginForTest(t, server)
+	seedCustomersForTest(t, server, "tenant-alpha", "cust-1")
+
+	const creates = 50
+	var wg sync.WaitGroup
+	numbers := make([]string, creates)
+	for i := range numbers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			rec := doJSON(t, server, cookie, http.MethodPost, "/v1/invoices", InvoiceCreateRequest{CustomerID: "cust-1", Currency: "ILS", Subtotal: 1000, Status: invoice.StatusOpen})
+			var item invoice.Invoice
+			if rec.Code != http.StatusCreated || json.NewDecoder(rec.Body).Decode(&item) != nil {
+				t.Errorf("expected create 201, got %d: %s", rec.Code, rec.Body.String())
+				return
+			}
+			numbers[i] = item.Number
+		}()
+	}
+	wg.Wait()
+
+	slices.Sort(numbers)
+	for i, number := range numbers {
+		if want := fmt.Sprintf("INV-%04d", i+1); number != want {
+			t.Fatalf("expected numbers INV-0001 to INV-%04d without duplicates or gaps, got %v", creates, numbers)
+		}
+	}
+}
+
+func TestInvoiceBatchNumbersItemsWithoutNumber(t *testing.T) {
+	server := NewServer()
+	cookie := loginForTest(t, server)
+	seedCustomersForTest(t, server, "tenant-alpha", "cust-1")
+
+	items := []InvoiceCreateRequest{batchItemForTest(""), batchItemForTest("INV-0002"), batchItemForTest(""), batchItemForTest("")}
+	for i := range items {
+		items[i].Status = invoice.StatusOpen
+	}
+	rec := doJSON(t, server, cookie, http.MethodPost, "/v1/invoices/batch", InvoiceBatchRequest{Items: items})
+	resp := decodeBatchResponseForTest(t, rec, http.StatusCreated)
+	var got []string
+	for _, result := range resp.Results {
+		got = append(got, result.Invoice.Number)
+	}
+	if want := []string{"INV-0001", "INV-0002", "INV-0003", "INV-0004"}; !slices.Equal(got, want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+	if stored := getInvoiceForTest(t, server, cookie, resp.Results[3].Invoice.ID); stored.Number != "INV-0004" {
+		t.Fatalf("expected the allocated number stored, got %q", stored.Number)
+	}
+}
+
+func TestDraftsAreNumberedWhenIssued(t *testing.T) {
+	server := NewServer()
+	cookie := loginForTest(t, server)
+	draft := InvoiceCreateRequest{CustomerID: "cust-1", Currency: "ILS", Subtotal: 1000}
+
+	discarded := createInvoiceForTest(t, server, cookie, draft)
+	kept := createInvoiceForTest(t, server, cookie, draft)
+	if discarded.Number != "" || kept.Number != "" {
+		t.Fatalf("expected drafts to stay unnumbered, got %q and %q", discarded.Number, kept.Number)
+	}
+	if rec := doJSON(t, server, cookie, http.MethodDelete, "/v1/invoices/"+discarded.ID, nil); rec.Code != http.StatusNoContent {
+		t.Fatalf("expected a draft delete 204, got %d: %s", rec.Code, rec.Body.String())
+	}
+	rec := patchInvoiceForTest(t, server, cookie, "/v1/invoices/"+kept.ID, map[string]any{"status": invoice.StatusOpen})
+	var issued invoice.Invoice
+	if rec.Code != http.StatusOK || json.NewDecoder(rec.Body).Decode(&issued) != nil || issued.Number != "INV-0001" {
+		t.Fatalf("expected the issued draft to take INV-0001, got %d %q", rec.Code, issued.Number)
+	}
+	if stored := getInvoiceForTest(t, server, cookie, kept.ID); stored.Number != "INV-0001" {
+		t.Fatalf("expected the allocated number stored, got %q", stored.Number)
+	}
+	if next := createInvoiceForTest(t, server, cookie, InvoiceCreateRequest{CustomerID: "cust-1", Currency: "ILS", Subtotal: 1000, Status: invoice.StatusOpen}); next.Number != "INV-0002" {
+		t.Fatalf("expected INV-0002 next, got %q", next.Number)
+	}
+
+	rec = patchInvoiceForTest(t, server, cookie, "/v1/invoices/"+kept.ID, map[string]any{"number": "INV-0099"})
+	if code := decodeErrorCodeForTest(t, rec); rec.Code != http.StatusConflict || code != apierror.CodeInvoiceNotDraft {
+		t.Fatalf("expected renumbering an issued invoice 409 %s, got %d %s", apierror.CodeInvoiceNotDraft, rec.Code, code)
+	}
+	rec = doJSON(t, server, cookie, http.MethodDelete, "/v1/invoices/"+kept.ID, nil)
+	if code := decodeErrorCodeForTest(t, rec); rec.Code != http.StatusConflict || code != apierror.CodeInvoiceNotDraft {
+		t.Fatalf("expected deleting an issued invoice 409 %s, got %d %s", apierror.CodeInvoiceNotDraft, rec.Code, code)
+	}
+
+	renamed := createInvoiceForTest(t, server, cookie, draft)
+	if rec := patchInvoiceForTest(t, server, cookie, "/v1/invoices/"+renamed.ID, map[string]any{"number": "DRAFT-7"}); rec.Code != http.StatusOK {
+		t.Fatalf("expected a draft's number to change, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
@@ -0,0 +1,859 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/apierror"
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/invoice"
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/pdf"
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/tax"
+)
+
+// InvoiceCreateRequest creates an invoice. Tax is computed by the server:
+// the Tax field is accepted so older clients keep working, but ignored.
+type InvoiceCreateRequest struct {
+	Number           string         `json:"number"`
+	CustomerID       string         `json:"customerId"`
+	Currency         string         `json:"currency"`
+	Subtotal         int64          `json:"subtotal"`
+	Tax              int64          `json:"tax"`
+	Total            int64          `json:"total"`
+	TaxRate          int64          `json:"taxRate"`
+	TaxRounding      tax.Rounding   `json:"taxRounding"`
+	Status           invoice.Status `json:"status"`
+	IssuedAt         string         `json:"issuedAt"`
+	DueAt            string         `json:"dueAt"`
+	PaidAt           string         `json:"paidAt"`
+	PaymentReference string         `json:"paymentReference"`
+	// Items, when present, replace the amounts above: subtotal and total
+	// are computed from the lines and may be omitted.
+	Items []InvoiceLineItemRequest `json:"items"`
+}
+
+type InvoiceLineItemRequest struct {
+	Description string `json:"description"`
+	Quantity    int64  `json:"quantity"`
+	UnitPrice   int64  `json:"unitPrice"`
+	TaxRate     int64  `json:"taxRate"`
+}
+
+type InvoiceList struct {
+	Items      []invoice.Invoice `json:"items"`
+	NextCursor string            `json:"nextCursor,omitempty"`
+}
+
+// InvoiceUpdateRequest uses pointers so a PATCH can distinguish "leave as is"
+// from an explicit zero amount. Tax is ignored as in InvoiceCreateRequest.
+type InvoiceUpdateRequest struct {
+	Number           *string         `json:"number"`
+	CustomerID       *string         `json:"customerId"`
+	Currency         *string         `json:"currency"`
+	Subtotal         *int64          `json:"subtotal"`
+	Tax              *int64          `json:"tax"`
+	Total            *int64          `json:"total"`
+	TaxRate          *int64          `json:"taxRate"`
+	TaxRounding      *tax.Rounding   `json:"taxRounding"`
+	Status           *invoice.Status `json:"status"`
+	IssuedAt         *string         `json:"issuedAt"`
+	DueAt            *string         `json:"dueAt"`
+	PaidAt           *string         `json:"paidAt"`
+	PaymentReference *string         `json:"paymentReference"`
+}
+
+func (s *Server) handleInvoices(w http.ResponseWriter, r *http.Request, session Session) {
+	switch r.Method {
+	case http.MethodGet:
+		filter, err := parseInvoiceListFilter(r.URL.Query())
+		if err != nil {
+			writeValidationError(w, err)
+			return
+		}
+		if filter.IncludeDeleted, err = includeDeleted(r, session); err != nil {
+			writeIncludeDeletedError(w, err)
+			return
+		}
+		pageSize := filter.Limit
+		filter.Limit = pageSize + 1
+		items, err := s.store.ListInvoices(r.Context(), session.TenantID, filter)
+		if err != nil {
+			s.writeInternalError(w, err)
+			return
+		}
+		page := InvoiceList{Items: items}
+		if len(items) > pageSize {
+			page.Items = items[:pageSize]
+			page.NextCursor = invoice.CursorAfter(page.Items[pageSize-1], filter.Sort, filter.Descending).Encode()
+		}
+		writeJSON(w, http.StatusOK, page)
+	case http.MethodPost:
+		s.withIdempotency(w, r, session, func(w http.ResponseWriter) {
+			s.createInvoice(w, r, session)
+		})
+	default:
+		apierror.WriteError(w, http.StatusMethodNotAllowed, errMethodNotAllowed)
+	}
+}
+
+func (s *Server) createInvoice(w http.ResponseWriter, r *http.Request, session Session) {
+	var req InvoiceCreateRequest
+	if err := decodeJSON(r, &req); err != nil {
+		writeBodyError(w, err)
+		return
+	}
+	s.storeNewInvoice(w, r, session, req)
+}
+
+// storeNewInvoice creates the invoice req describes and answers 201 with it.
+func (s *Server) storeNewInvoice(w http.ResponseWriter, r *http.Request, session Session, req InvoiceCreateRequest) {
+	exempt, err := s.invoiceCustomerTaxExempt(r.Context(), session.TenantID, strings.TrimSpace(req.CustomerID))
+	if errors.Is(err, errUnknownCustomer) {
+		writeValidationError(w, err)
+		return
+	}
+	if err != nil {
+		s.writeInternalError(w, err)
+		return
+	}
+	item, err := s.newInvoice(session.TenantID, req, exempt, utcNow())
+	if err != nil {
+		writeValidationError(w, err)
+		return
+	}
+	item, err = s.store.CreateInvoice(r.Context(), item, s.invoiceNumbers)
+	if err != nil {
+		s.writeInvoiceWriteError(w, err)
+		return
+	}
+	s.metrics.invoicesCreated.Inc()
+	s.announceInvoiceCreated(r.Context(), session.TenantID, routeCtx(r).RequestID, item)
+	w.Header().Set("Location", "/v1/invoices/"+item.ID)
+	writeJSON(w, http.StatusCreated, item)
+}
+
+// InvoiceDuplicateRequest overrides fields of the copy POST
+// /v1/invoices/{id}/duplicate makes. Fields left out keep the source's
+// customer and currency; the copy has no number, dates or payments of its
+// own unless given here.
+type InvoiceDuplicateRequest struct {
+	Number     *string `json:"number"`
+	CustomerID *string `json:"customerId"`
+	Currency   *string `json:"currency"`
+	IssuedAt   *string `json:"issuedAt"`
+	DueAt      *string `json:"dueAt"`
+}
+
+// handleInvoiceDuplicate serves POST /v1/invoices/{id}/duplicate: a new
+// draft with the source's line items, customer, currency and tax settings.
+// Any source may be copied, void and paid ones included. The body is
+// optional.
+func (s *Server) handleInvoiceDuplicate(w http.ResponseWriter, r *http.Request, session Session, source invoice.Invoice) {
+	if r.Method != http.MethodPost {
+		apierror.WriteError(w, http.StatusMethodNotAllowed, errMethodNotAllowed)
+		return
+	}
+	s.withIdempotency(w, r, session, func(w http.ResponseWriter) {
+		var overrides InvoiceDuplicateRequest
+		if r.ContentLength != 0 {
+			if err := decodeJSON(r, &overrides); err != nil {
+				writeBodyError(w, err)
+				return
+			}
+		}
+		s.storeNewInvoice(w, r, session, duplicateInvoiceRequest(source, overrides))
+	})
+}
+
+// duplicateInvoiceRequest is the create request that copies source. Line
+// items are copied by value, so the copy's amounts are computed afresh.
+func duplicateInvoiceRequest(source invoice.Invoice, overrides InvoiceDuplicateRequest) InvoiceCreateRequest {
+	req := InvoiceCreateRequest{
+		CustomerID:  source.CustomerID,
+		Currency:    source.Currency,
+		TaxRate:     source.TaxRate,
+		TaxRounding: source.TaxRounding,
+		Status:      invoice.StatusDraft,
+	}
+	if len(source.Items) == 0 {
+		req.Subtotal = source.Subtotal
+	}
+	for _, line := range source.Items {
+		req.Items = append(req.Items, InvoiceLineItemRequest{
+			Description: line.Description,
+			Quantity:    line.Quantity,
+			UnitPrice:   line.UnitPrice,
+			TaxRate:     line.TaxRate,
+		})
+	}
+	if overrides.Number != nil {
+		req.Number = *overrides.Number
+	}
+	if overrides.CustomerID != nil {
+		req.CustomerID = *overrides.CustomerID
+	}
+	if overrides.Currency != nil {
+		req.Currency = *overrides.Currency
+	}
+	if overrides.IssuedAt != nil {
+		req.IssuedAt = *overrides.IssuedAt
+	}
+	if overrides.DueAt != nil {
+		req.DueAt = *overrides.DueAt
+	}
+	return req
+}
+
+// newInvoice builds the invoice req creates, with tax computed for a customer
+// that is exempt or not. Every error it returns is a validation error.
+func (s *Server) newInvoice(tenantID string, req InvoiceCreateRequest, exempt bool, now string) (invoice.Invoice, error) {
+	item := invoice.Invoice{
+		ID:               s.newID("inv"),
+		TenantID:         tenantID,
+		Number:           strings.TrimSpace(req.Number),
+		CustomerID:       strings.TrimSpace(req.CustomerID),
+		Currency:         invoice.NormalizeCurrency(req.Currency),
+		Subtotal:         req.Subtotal,
+		TaxRate:          req.TaxRate,
+		TaxRounding:      req.TaxRounding,
+		TaxExempt:        exempt,
+		Status:           req.Status,
+		IssuedAt:         req.IssuedAt,
+		DueAt:            req.DueAt,
+		PaidAt:           req.PaidAt,
+		PaymentReference: strings.TrimSpace(req.PaymentReference),
+		CreatedAt:        now,
+		UpdatedAt:        now,
+		Version:          1,
+	}
+	if len(req.Items) > 0 {
+		items := make([]invoice.LineItem, 0, len(req.Items))
+		for i, line := range req.Items {
+			lineItem, err := s.newLineItem(item.ID, line, now)
+			if err != nil {
+				var fieldErr *invoice.ValidationError
+				if errors.As(err, &fieldErr) {
+					err = &invoice.ValidationError{Field: fmt.Sprintf("items[%d].%s", i, fieldErr.Field), Message: fieldErr.Message}
+				}
+				return invoice.Invoice{}, err
+			}
+			lineItem.Position = i + 1
+			items = append(items, lineItem)
+		}
+		item.Items = items
+	}
+	if item.TaxRounding == "" {
+		item.TaxRounding = tax.RoundLine
+	}
+	item.ComputeTax()
+	if err := checkClientTotals(item, optionalAmount(req.Subtotal), optionalAmount(req.Total)); err != nil {
+		return invoice.Invoice{}, err
+	}
+	if item.Status == "" {
+		item.Status = invoice.StatusDraft
+	}
+	// Without a number, the store allocates the next one when the invoice
+	// is issued, on create or later.
+	if err := item.ValidateUnnumbered(); err != nil {
+		return invoice.Invoice{}, err
+	}
+	return item, nil
+}
+
+// announceInvoiceCreated records the activity event and the webhook event of
+// a stored invoice. The invoice is committed by then, so a failure is logged
+// rather than answered with 500: that would release the idempotency key and
+// let a retry create the invoice a second time.
+func (s *Server) announceInvoiceCreated(ctx context.Context, tenantID, requestID string, item invoice.Invoice) {
+	if err := s.recordAudit(ctx, tenantID, requestID, "invoice.created", "invoice", item.ID, fmt.Sprintf("Created invoice %s", invoiceLabel(item))); err != nil {
+		s.logger.ErrorContext(ctx, "record invoice created activity", "invoice_id", item.ID, "error", err)
+	}
+	if err := s.publishWebhookEvent(ctx, tenantID, EventInvoiceCreated, item); err != nil {
+		s.logger.ErrorContext(ctx, "publish invoice created webhook", "invoice_id", item.ID, "error", err)
+	}
+}
+
+func (s *Server) handleInvoiceDetail(w http.ResponseWriter, r *http.Request, session Session) {
+	id, action := trimPrefixID(r.URL.Path, "/v1/invoices/")
+	withDeleted, err := includeDeleted(r, session)
+	if err != nil {
+		writeIncludeDeletedError(w, err)
+		return
+	}
+	// A soft-deleted invoice can only be read, and only when asked for.
+	item, err := s.store.GetInvoice(r.Context(), session.TenantID, id)
+	if err == nil && item.DeletedAt != "" && (!withDeleted || r.Method != http.MethodGet) {
+		err = ErrNotFound
+	}
+	if err != nil {
+		s.writeLookupError(w, err, "invoice")
+		return
+	}
+
+	switch sub, itemID, _ := strings.Cut(action, "/"); {
+	case action == "":
+	case sub == "items":
+		s.handleInvoiceLineItems(w, r, session, item, itemID)
+		return
+	case sub == "attachments":
+		s.handleInvoiceAttachments(w, r, session, item, itemID)
+		return
+	case sub == "credit-notes":
+		s.handleInvoiceCreditNotes(w, r, session, item, itemID)
+		return
+	case action == "void":
+		s.handleInvoiceVoid(w, r, session, item)
+		return
+	case action == "duplicate":
+		s.handleInvoiceDuplicate(w, r, session, item)
+		return
+	case action == "credit-note":
+		s.handleInvoiceCreditNote(w, r, session, item)
+		return
+	case action == "payments":
+		s.handleInvoicePayments(w, r, session, item)
+		return
+	case action == "pay":
+		s.handleInvoicePay(w, r, session, item)
+		return
+	case action == "history":
+		s.handleInvoiceHistory(w, r, session, item)
+		return
+	case action == "remind":
+		s.handleInvoiceRemind(w, r, session, item)
+		return
+	case action == "share":
+		s.handleInvoiceShare(w, r, session, item)
+		return
+	case action == "pdf":
+		if r.Method != http.MethodGet {
+			apierror.WriteError(w, http.StatusMethodNotAllowed, errMethodNotAllowed)
+			return
+		}
+		s.writeInvoicePDF(w, r, item)
+		return
+	default:
+		apierror.WriteError(w, http.StatusNotFound, errRouteNotFound)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		item, err := s.withDunning(r.Context(), item)
+		if err != nil {
+			s.writeInternalError(w, err)
+			return
+		}
+		writeInvoiceRead(w, r, item)
+	case http.MethodPatch:
+		if item.Status == invoice.StatusVoid {
+			apierror.WriteError(w, http.StatusConflict, errInvoiceVoid)
+			return
+		}
+		if !checkIfMatch(w, r, item) {
+			return
+		}
+		var req InvoiceUpdateRequest
+		if err := decodeJSON(r, &req); err != nil {
+			writeBodyError(w, err)
+			return
+		}
+		if item.Status != invoice.StatusDraft && req.changesIssuedFields(item) {
+			apierror.WriteError(w, http.StatusConflict, errInvoiceIssued)
+			return
+		}
+		previousStatus := item.Status
+		applyInvoiceUpdate(&item, req)
+		if req.CustomerID != nil {
+			exempt, err := s.invoiceCustomerTaxExempt(r.Context(), session.TenantID, item.CustomerID)
+			if errors.Is(err, errUnknownCustomer) {
+				writeValidationError(w, err)
+				return
+			}
+			if err != nil {
+				s.writeInternalError(w, err)
+				return
+			}
+			item.TaxExempt = exempt
+		}
+		// Totals are only recomputed when the request touches them, so an
+		// unrelated change such as a status update leaves stored amounts alone.
+		if req.changesTotals() {
+			item.ComputeTax()
+			if err := checkClientTotals(item, req.Subtotal, req.Total); err != nil {
+				writeValidationError(w, err)
+				return
+			}
+		}
+		if !previousStatus.CanTransitionTo(item.Status) && item.Status.Valid() {
+			apierror.WriteError(w, http.StatusUnprocessableEntity, &apierror.Error{
+				Code:    apierror.CodeInvalidTransition,
+				Message: fmt.Sprintf("invoice cannot move from %s to %s", previousStatus, item.Status),
+				Details: map[string]any{
+					"from":    previousStatus,
+					"to":      item.Status,
+					"allowed": previousStatus.NextStatuses(),
+				},
+			})
+			return
+		}
+		item.UpdatedAt = utcNow()
+		if previousStatus != invoice.StatusVoid && item.Status == invoice.StatusVoid {
+			// Voiding through a status update follows the rules of the void
+			// route, without a reason.
+			if item.AmountPaid > 0 {
+				apierror.WriteError(w, http.StatusConflict, errInvoiceHasPayments)
+				return
+			}
+			item.VoidedAt = item.UpdatedAt
+		}
+		// A draft issued without a number is numbered by the store.
+		if err := item.ValidateUnnumbered(); err != nil {
+			writeValidationError(w, err)
+			return
+		}
+		if err := s.store.UpdateInvoice(r.Context(), &item, s.invoiceNumbers); err != nil {
+			s.writeInvoiceWriteError(w, err)
+			return
+		}
+		item.Version++
+		if err := s.recordAudit(r.Context(), session.TenantID, routeCtx(r).RequestID, "invoice.updated", "invoice", item.ID, "Updated invoice"); err != nil {
+			s.writeInternalError(w, err)
+			return
+		}
+		if event := invoiceStatusEvent(previousStatus, item.Status); event != "" {
+			if err := s.publishWebhookEvent(r.Context(), session.TenantID, event, item); err != nil {
+				s.writeInternalError(w, err)
+				return
+			}
+		}
+		w.Header().Set("ETag", invoiceETag(item))
+		writeJSON(w, http.StatusOK, item)
+	case http.MethodDelete:
+		// Void invoices stay on record, readable and listed.
+		if item.Status == invoice.StatusVoid {
+			apierror.WriteError(w, http.StatusConflict, apierror.New(apierror.CodeInvoiceVoid, "void invoices cannot be deleted"))
+			return
+		}
+		if item.Status == invoice.StatusPaid || item.AmountPaid > 0 {
+			apierror.WriteError(w, http.StatusConflict, apierror.New(apierror.CodeInvoicePaid, "paid invoices cannot be deleted"))
+			return
+		}
+		// Deleting an issued invoice would leave a gap in the numbering, so
+		// only drafts can go.
+		if item.Status != invoice.StatusDraft {
+			apierror.WriteError(w, http.StatusConflict, errInvoiceIssuedDelete)
+			return
+		}
+		if err := s.store.SoftDeleteInvoice(r.Context(), session.TenantID, item.ID, utcNow()); err != nil {
+			s.writeLookupError(w, err, "invoice")
+			return
+		}
+		if err := s.recordAudit(r.Context(), session.TenantID, routeCtx(r).RequestID, "invoice.deleted", "invoice", item.ID, fmt.Sprintf("Deleted invoice %s", invoiceLabel(item))); err != nil {
+			s.writeInternalError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		apierror.WriteError(w, http.StatusMethodNotAllowed, errMethodNotAllowed)
+	}
+}
+
+// handleInvoiceHistory serves GET /v1/invoices/{id}/history, the invoice's
+// audit log oldest first.
+func (s *Server) handleInvoiceHistory(w http.ResponseWriter, r *http.Request, session Session, item invoice.Invoice) {
+	if r.Method != http.MethodGet {
+		apierror.WriteError(w, http.StatusMethodNotAllowed, errMethodNotAllowed)
+		return
+	}
+	items, err := s.store.ListAuditLog(r.Context(), session.TenantID, AuditEntityInvoice, item.ID)
+	if err != nil {
+		s.writeInternalError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, AuditLogList{Items: items})
+}
+
+// handleInvoiceLineItems serves POST /v1/invoices/{id}/items and
+// DELETE /v1/invoices/{id}/items/{itemId}. Both answer with the whole invoice
+// so clients pick up the recomputed totals.
+func (s *Server) handleInvoiceLineItems(w http.ResponseWriter, r *http.Request, session Session, item invoice.Invoice, itemID string) {
+	switch {
+	case itemID == "" && r.Method == http.MethodPost:
+		if item.Status != invoice.StatusDraft {
+			apierror.WriteError(w, http.StatusConflict, errInvoiceNotDraft)
+			return
+		}
+		var req InvoiceLineItemRequest
+		if err := decodeJSON(r, &req); err != nil {
+			writeBodyError(w, err)
+			return
+		}
+		lineItem, err := s.newLineItem(item.ID, req, utcNow())
+		if err != nil {
+			writeValidationError(w, err)
+			return
+		}
+		updated, err := s.store.AddInvoiceLineItem(r.Context(), session.TenantID, lineItem)
+		if err != nil {
+			s.writeLineItemError(w, err)
+			return
+		}
+		if err := s.recordAudit(r.Context(), session.TenantID, routeCtx(r).RequestID, "invoice.item.added", "invoice", item.ID, fmt.Sprintf("Added line item %q", lineItem.Description)); err != nil {
+			s.writeInternalError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusCreated, updated)
+	case itemID != "" && !strings.Contains(itemID, "/") && r.Method == http.MethodDelete:
+		if item.Status != invoice.StatusDraft {
+			apierror.WriteError(w, http.StatusConflict, errInvoiceNotDraft)
+			return
+		}
+		updated, err := s.store.DeleteInvoiceLineItem(r.Context(), session.TenantID, item.ID, itemID, utcNow())
+		if err != nil {
+			s.writeLineItemError(w, err)
+			return
+		}
+		if err := s.recordAudit(r.Context(), session.TenantID, routeCtx(r).RequestID, "invoice.item.removed", "invoice", item.ID, "Removed line item "+itemID); err != nil {
+			s.writeInternalError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, updated)
+	case strings.Contains(itemID, "/"):
+		apierror.WriteError(w, http.StatusNotFound, errRouteNotFound)
+	default:
+		apierror.WriteError(w, http.StatusMethodNotAllowed, errMethodNotAllowed)
+	}
+}
+
+func (s *Server) newLineItem(invoiceID string, req InvoiceLineItemRequest, now string) (invoice.LineItem, error) {
+	item := invoice.LineItem{
+		ID:          s.newID("item"),
+		InvoiceID:   invoiceID,
+		Description: req.Description,
+		Quantity:    req.Quantity,
+		UnitPrice:   req.UnitPrice,
+		TaxRate:     req.TaxRate,
+		CreatedAt:   now,
+	}
+	if err := item.Validate(); err != nil {
+		return invoice.LineItem{}, err
+	}
+	item.Normalize()
+	return item, nil
+}
+
+func (s *Server) writeLineItemError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, invoice.ErrNotDraft):
+		apierror.WriteError(w, http.StatusConflict, errInvoiceNotDraft)
+	case errors.Is(err, ErrNotFound):
+		apierror.WriteError(w, http.StatusNotFound, apierror.NotFound("line item"))
+	default:
+		s.writeInternalError(w, err)
+	}
+}
+
+// writeInvoicePDF renders the invoice with the billing block of its customer.
+func (s *Server) writeInvoicePDF(w http.ResponseWriter, r *http.Request, item invoice.Invoice) {
+	customer, err := s.billingCustomer(r.Context(), item)
+	if err != nil {
+		s.writeInternalError(w, err)
+		return
+	}
+	s.writePDF(w, r, invoiceDocument(item, customer), "invoice-"+invoiceLabel(item)+".pdf")
+}
+
+// invoiceLabel names an invoice in audit messages and file names: by its
+// number, or by its ID while it is an unnumbered draft.
+func invoiceLabel(item invoice.Invoice) string {
+	if item.Number == "" {
+		return item.ID
+	}
+	return item.Number
+}
+
+// billingCustomer is the customer whose billing block item prints, or nil
+// when its customer ID does not name a customer record and the ID is
+// printed instead.
+func (s *Server) billingCustomer(ctx context.Context, item invoice.Invoice) (*Customer, error) {
+	customer, err := s.store.GetCustomer(ctx, item.TenantID, item.CustomerID)
+	switch {
+	case err == nil:
+		return &customer, nil
+	case errors.Is(err, ErrNotFound):
+		return nil, nil
+	default:
+		return nil, err
+	}
+}
+
+// writePDF renders doc in the locale the request asks for and sends it as
+// a download named filename.
+func (s *Server) writePDF(w http.ResponseWriter, r *http.Request, doc pdf.Document, filename string) {
+	doc.Locale = pdfLocale(r)
+	var buf bytes.Buffer
+	if err := s.pdfRenderer.Render(&buf, doc); err != nil {
+		s.writeInternalError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/pdf")
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{
+		"filename": filename,
+	}))
+	w.Header().Set("Content-Language", pdf.ResolveLocale(doc.Locale))
+	addVary(w.Header(), "Accept-Language")
+	w.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
+	w.WriteHeader(http.StatusOK)
+	_, _ = buf.WriteTo(w)
+}
+
+// pdfLocale is the locale query parameter, or else the most preferred
+// language in Accept-Language that PDFs are printed in. The renderer prints
+// anything it does not support in English.
+func pdfLocale(r *http.Request) string {
+	if tag := strings.TrimSpace(r.URL.Query().Get("locale")); tag != "" {
+		return tag
+	}
+	best, bestWeight := "", 0.0
+	for _, part := range strings.Split(r.Header.Get("Accept-Language"), ",") {
+		tag, params, _ := strings.Cut(part, ";")
+		tag = strings.TrimSpace(tag)
+		weight := 1.0
+		if key, value, ok := strings.Cut(strings.TrimSpace(params), "="); ok && strings.TrimSpace(key) == "q" {
+			parsed, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+			if err != nil {
+				continue
+			}
+			weight = parsed
+		}
+		if pdf.SupportsLocale(tag) && weight > bestWeight {
+			best, bestWeight = tag, weight
+		}
+	}
+	return best
+}
+
+// invoiceDocument builds the print view of an invoice. The PDF timestamp is
+// pinned to the invoice's last update so unchanged invoices re-render to the
+// same bytes. Invoices created with bare totals have no lines and print a
+// single summary row.
+func invoiceDocument(item invoice.Invoice, customer *Customer) pdf.Document {
+	generatedAt, _ := time.Parse(time.RFC3339, item.UpdatedAt)
+	lines := make([]pdf.Line, 0, len(item.Items))
+	for _, line := range item.Items {
+		lines = append(lines, pdf.Line{
+			Description: line.Description,
+			Quantity:    line.Quantity,
+			UnitPrice:   line.UnitPrice,
+			Amount:      line.Amount,
+		})
+	}
+	return pdf.Document{
+		Number:      item.Number,
+		Currency:    item.Currency,
+		IssuedAt:    item.IssuedAt,
+		DueAt:       item.DueAt,
+		BillTo:      billTo(item, customer),
+		Lines:       lines,
+		Subtotal:    item.Subtotal,
+		Tax:         item.Tax,
+		Total:       item.Total,
+		GeneratedAt: generatedAt,
+	}
+}
+
+func billTo(item invoice.Invoice, customer *Customer) pdf.Party {
+	if customer == nil {
+		return pdf.Party{Name: item.CustomerID}
+	}
+	lines := customer.BillingAddress.Lines()
+	if customer.Email != "" {
+		lines = append(lines, customer.Email)
+	}
+	return pdf.Party{Name: customer.Name, Lines: lines, TaxID: customer.TaxID}
+}
+
+func parseInvoiceListFilter(query url.Values) (invoice.ListFilter, error) {
+	filter := invoice.ListFilter{
+		Status:     invoice.Status(query.Get("status")),
+		CustomerID: strings.TrimSpace(query.Get("customerId")),
+		Sort:       invoice.SortIssuedAt,
+		Descending: true,
+		Limit:      invoice.DefaultListLimit,
+	}
+	if filter.Status != "" && !filter.Status.Valid() {
+		return filter, apierror.Field("status", "must be one of draft, open, paid, overdue, void")
+	}
+	if value := query.Get("sort"); value != "" {
+		filter.Sort = invoice.SortField(value)
+		if !filter.Sort.Valid() {
+			return filter, apierror.Field("sort", "must be issued_at or total")
+		}
+	}
+	switch query.Get("order") {
+	case "", "desc":
+	case "asc":
+		filter.Descending = false
+	default:
+		return filter, apierror.Field("order", "must be asc or desc")
+	}
+	if value := query.Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit <= 0 {
+			return filter, apierror.Field("limit", "must be a positive integer")
+		}
+		filter.Limit = min(limit, invoice.MaxListLimit)
+	}
+	var err error
+	if filter.IssuedFrom, err = parseDateBound(query.Get("issuedFrom"), false); err != nil {
+		return filter, apierror.Field("issuedFrom", err.Error())
+	}
+	if filter.IssuedTo, err = parseDateBound(query.Get("issuedTo"), true); err != nil {
+		return filter, apierror.Field("issuedTo", err.Error())
+	}
+	if filter.IssuedFrom != "" && filter.IssuedTo != "" && filter.IssuedTo < filter.IssuedFrom {
+		return filter, apierror.Field("issuedTo", "must not be before issuedFrom")
+	}
+	if value := query.Get("cursor"); value != "" {
+		cursor, err := invoice.DecodeCursor(value, filter.Sort, filter.Descending)
+		if err != nil {
+			return filter, err
+		}
+		filter.After = &cursor
+	}
+	return filter, nil
+}
+
+// parseDateBound accepts an RFC3339 timestamp or a YYYY-MM-DD date. A bare
+// date used as an upper bound covers the whole day.
+func parseDateBound(value string, endOfDay bool) (string, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return "", nil
+	}
+	if ts, err := time.Parse(time.RFC3339, value); err == nil {
+		return ts.UTC().Format(time.RFC3339), nil
+	}
+	day, err := time.Parse(time.DateOnly, value)
+	if err != nil {
+		return "", errors.New("must be an RFC3339 timestamp or YYYY-MM-DD date")
+	}
+	if endOfDay {
+		day = day.Add(24*time.Hour - time.Second)
+	}
+	return day.UTC().Format(time.RFC3339), nil
+}
+
+func applyInvoiceUpdate(item *invoice.Invoice, req InvoiceUpdateRequest) {
+	if req.Number != nil {
+		item.Number = strings.TrimSpace(*req.Number)
+	}
+	if req.CustomerID != nil {
+		item.CustomerID = strings.TrimSpace(*req.CustomerID)
+	}
+	if req.Currency != nil {
+		item.Currency = invoice.NormalizeCurrency(*req.Currency)
+	}
+	if req.Subtotal != nil {
+		item.Subtotal = *req.Subtotal
+	}
+	if req.TaxRate != nil {
+		item.TaxRate = *req.TaxRate
+	}
+	if req.TaxRounding != nil {
+		item.TaxRounding = *req.TaxRounding
+	}
+	if req.Status != nil {
+		item.Status = *req.Status
+	}
+	if req.IssuedAt != nil {
+		item.IssuedAt = *req.IssuedAt
+	}
+	if req.DueAt != nil {
+		item.DueAt = *req.DueAt
+	}
+	if req.PaidAt != nil {
+		item.PaidAt = *req.PaidAt
+	}
+	if req.PaymentReference != nil {
+		item.PaymentReference = strings.TrimSpace(*req.PaymentReference)
+	}
+}
+
+// changesIssuedFields reports whether req changes what an issued invoice
+// bills: its number, customer, currency or amounts. Sending the current
+// value is not a change.
+func (req InvoiceUpdateRequest) changesIssuedFields(item invoice.Invoice) bool {
+	return (req.Number != nil && strings.TrimSpace(*req.Number) != item.Number) ||
+		(req.CustomerID != nil && strings.TrimSpace(*req.CustomerID) != item.CustomerID) ||
+		(req.Currency != nil && invoice.NormalizeCurrency(*req.Currency) != item.Currency) ||
+		(req.Subtotal != nil && *req.Subtotal != item.Subtotal) ||
+		(req.Tax != nil && *req.Tax != item.Tax) ||
+		(req.Total != nil && *req.Total != item.Total) ||
+		(req.TaxRate != nil && *req.TaxRate != item.TaxRate) ||
+		(req.TaxRounding != nil && *req.TaxRounding != item.TaxRounding)
+}
+
+func (req InvoiceUpdateRequest) changesTotals() bool {
+	return req.Subtotal != nil || req.Tax != nil || req.Total != nil || req.TaxRate != nil || req.TaxRounding != nil || req.CustomerID != nil
+}
+
+// invoiceCustomerTaxExempt reports whether the customer an invoice is
+// created for or moved to is tax-exempt. A customer ID that names no live
+// customer of the tenant is errUnknownCustomer, as on an import row; an
+// empty one is left for invoice validation to reject.
+func (s *Server) invoiceCustomerTaxExempt(ctx context.Context, tenantID, customerID string) (bool, error) {
+	if customerID == "" {
+		return false, nil
+	}
+	customer, err := s.store.GetCustomer(ctx, tenantID, customerID)
+	if errors.Is(err, ErrNotFound) || (err == nil && customer.DeletedAt != "") {
+		return false, errUnknownCustomer
+	}
+	if err != nil {
+		return false, err
+	}
+	return customer.TaxExempt, nil
+}
+
+// checkClientTotals rejects a subtotal or total the client sent that
+// disagrees with the computed one, so a client that priced the invoice
+// differently finds out instead of billing an amount it did not expect.
+func checkClientTotals(item invoice.Invoice, subtotal, total *int64) error {
+	if subtotal != nil && *subtotal != item.Subtotal {
+		return apierror.Field("subtotal", fmt.Sprintf("must match line items (%d)", item.Subtotal))
+	}
+	if total != nil && *total != item.Total {
+		return apierror.Field("total", fmt.Sprintf("must equal subtotal + tax (%d + %d = %d)", item.Subtotal, item.Tax, item.Total))
+	}
+	return nil
+}
+
+// optionalAmount treats a zero amount in a create request as not sent.
+func optionalAmount(amount int64) *int64 {
+	if amount == 0 {
+		return nil
+	}
+	return &amount
+}
+
+func (s *Server) writeInvoiceWriteError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrConflict):
+		apierror.WriteError(w, http.StatusConflict, apierror.New(apierror.CodeInvoiceNumberTaken, "invoice number already exists"))
+	case errors.Is(err, ErrNotFound):
+		apierror.WriteError(w, http.StatusNotFound, apierror.NotFound("invoice"))
+	case errors.Is(err, ErrVersionMismatch):
+		apierror.WriteError(w, http.StatusPreconditionFailed, errVersionMismatch)
+	default:
+		s.writeInternalError(w, err)
+	}
+}
@@ -0,0 +1,958 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/apierror"
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/invoice"
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/logging"
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/tax"
+)
+
+func TestInvoiceCRUDLifecycle(t *testing.T) {
+	server := NewServer()
+	cookie := loginForTest(t, server)
+
+	created := createInvoiceForTest(t, server, cookie, InvoiceCreateRequest{
+		Number:     "INV-0001",
+		CustomerID: "cust-1",
+		Currency:   "ils",
+		Subtotal:   10000,
+		TaxRate:    1700,
+		Total:      11700,
+		IssuedAt:   "2026-06-01T00:00:00Z",
+		DueAt:      "2026-06-30T00:00:00Z",
+	})
+	if created.Status != invoice.StatusDraft || created.Currency != "ILS" || created.TenantID != "tenant-alpha" {
+		t.Fatalf("unexpected created invoice: %#v", created)
+	}
+
+	rec := doJSON(t, server, cookie, http.MethodGet, "/v1/invoices/"+created.ID, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected get 200, got %d", rec.Code)
+	}
+
+	rec = patchInvoiceForTest(t, server, cookie, "/v1/invoices/"+created.ID, map[string]any{
+		"subtotal": 20000,
+		"tax":      3400,
+		"total":    23400,
+	})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected patch 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var updated invoice.Invoice
+	if err := json.NewDecoder(rec.Body).Decode(&updated); err != nil {
+		t.Fatalf("decode updated invoice: %v", err)
+	}
+	if updated.Total != 23400 || updated.Number != "INV-0001" {
+		t.Fatalf("unexpected updated invoice: %#v", updated)
+	}
+
+	rec = doJSON(t, server, cookie, http.MethodDelete, "/v1/invoices/"+created.ID, nil)
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("expected delete 204, got %d", rec.Code)
+	}
+	rec = doJSON(t, server, cookie, http.MethodGet, "/v1/invoices/"+created.ID, nil)
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("expected deleted invoice 404, got %d", rec.Code)
+	}
+}
+
+func TestInvoiceWritesRejectMismatchedTotals(t *testing.T) {
+	server := NewServer()
+	cookie := loginForTest(t, server)
+
+	rec := doJSON(t, server, cookie, http.MethodPost, "/v1/invoices", InvoiceCreateRequest{
+		CustomerID: "cust-1",
+		Currency:   "USD",
+		Subtotal:   1000,
+		TaxRate:    1700,
+		Total:      1169,
+	})
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected create 400, got %d", rec.Code)
+	}
+
+	created := createInvoiceForTest(t, server, cookie, InvoiceCreateRequest{
+		CustomerID: "cust-1",
+		Currency:   "USD",
+		Subtotal:   1000,
+		TaxRate:    1700,
+		Total:      1170,
+		Status:     invoice.StatusOpen,
+	})
+	if created.Number == "" {
+		t.Fatal("expected server-assigned invoice number")
+	}
+	// Amounts of an issued invoice are locked, so the mismatch is checked
+	// on a draft.
+	draft := createInvoiceForTest(t, server, cookie, InvoiceCreateRequest{
+		CustomerID: "cust-1",
+		Currency:   "USD",
+		Subtotal:   1000,
+		TaxRate:    1700,
+		Total:      1170,
+	})
+	rec = patchInvoiceForTest(t, server, cookie, "/v1/invoices/"+draft.ID, map[string]any{"total": 1000})
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected patch 400, got %d", rec.Code)
+	}
+}
+
+func TestIssuedInvoicesLockCustomerCurrencyAndAmounts(t *testing.T) {
+	server := NewServer()
+	cookie := loginForTest(t, server)
+	seedCustomersForTest(t, server, "tenant-alpha", "cust-2")
+
+	open := createInvoiceForTest(t, server, cookie, InvoiceCreateRequest{CustomerID: "cust-1", Currency: "EUR", Subtotal: 500, Total: 500, Status: invoice.StatusOpen})
+	paid := createInvoiceForTest(t, server, cookie, InvoiceCreateRequest{CustomerID: "cust-1", Currency: "EUR", Subtotal: 500, Total: 500, Status: invoice.StatusPaid, PaidAt: "2026-06-02T09:30:00Z"})
+	changes := map[string]map[string]any{
+		"amounts and currency": {"subtotal": 5, "total": 5, "currency": "USD"},
+		"currency":             {"currency": "USD"},
+		"customer":             {"customerId": "cust-2"},
+		"subtotal":             {"subtotal": 400},
+		"tax":                  {"tax": 10},
+		"total":                {"total": 510},
+		"tax rate":             {"taxRate": 1700},
+		"tax rounding":         {"taxRounding": "invoice"},
+	}
+	for _, item := range []invoice.Invoice{open, paid} {
+		for name, change := range changes {
+			rec := patchInvoiceForTest(t, server, cookie, "/v1/invoices/"+item.ID, change)
+			if code := decodeErrorCodeForTest(t, rec); rec.Code != http.StatusConflict || code != apierror.CodeInvoiceNotDraft {
+				t.Errorf("%s invoice, %s: expected 409 %s, got %d %s", item.Status, name, apierror.CodeInvoiceNotDraft, rec.Code, code)
+			}
+		}
+		if stored := getInvoiceForTest(t, server, cookie, item.ID); stored.Currency != "EUR" || stored.CustomerID != "cust-1" || stored.Total != 500 {
+			t.Fatalf("expected the %s invoice unchanged, got %s %s %d", item.Status, stored.CustomerID, stored.Currency, stored.Total)
+		}
+		// Resending the current values, as a client echoing a read does, is
+		// not a change.
+		rec := patchInvoiceForTest(t, server, cookie, "/v1/invoices/"+item.ID, map[string]any{"currency": "eur", "subtotal": 500, "total": 500, "customerId": "cust-1", "dueAt": "2026-07-01T00:00:00Z"})
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected unchanged amounts on the %s invoice to be accepted, got %d: %s", item.Status, rec.Code, rec.Body.String())
+		}
+	}
+
+	draft := createInvoiceForTest(t, server, cookie, InvoiceCreateRequest{CustomerID: "cust-1", Currency: "EUR", Subtotal: 500, Total: 500})
+	if rec := patchInvoiceForTest(t, server, cookie, "/v1/invoices/"+draft.ID, changes["amounts and currency"]); rec.Code != http.StatusOK {
+		t.Fatalf("expected a draft's amounts to change, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestInvoiceWritesComputeTaxServerSide(t *testing.T) {
+	server := NewServer()
+	cookie := loginForTest(t, server)
+
+	// The client's tax is ignored; 17% of 1000 is what gets billed.
+	created := createInvoiceForTest(t, server, cookie, InvoiceCreateRequest{
+		CustomerID: "cust-1",
+		Currency:   "USD",
+		Subtotal:   1000,
+		Tax:        1,
+		TaxRate:    1700,
+	})
+	if created.Tax != 170 || created.Total != 1170 || created.TaxRounding != tax.RoundLine {
+		t.Fatalf("expected computed tax 170 with line rounding, got %#v", created)
+	}
+	rec := patchInvoiceForTest(t, server, cookie, "/v1/invoices/"+created.ID, map[string]any{"tax": 0, "subtotal": 2000})
+	var updated invoice.Invoice
+	if err := json.NewDecoder(rec.Body).Decode(&updated); err != nil {
+		t.Fatalf("decode invoice: %v", err)
+	}
+	if rec.Code != http.StatusOK || updated.Tax != 340 || updated.Total != 2340 {
+		t.Fatalf("expected recomputed tax 340, got %d %#v", rec.Code, updated)
+	}
+
+	// Three half units of tax: 3 when each line is rounded, 2 when the
+	// invoice is.
+	lines := []InvoiceLineItemRequest{
+		{Description: "a", Quantity: 1, UnitPrice: 1, TaxRate: 5000},
+		{Description: "b", Quantity: 1, UnitPrice: 1, TaxRate: 5000},
+		{Description: "c", Quantity: 1, UnitPrice: 1, TaxRate: 5000},
+	}
+	perLine := createInvoiceForTest(t, server, cookie, InvoiceCreateRequest{CustomerID: "cust-1", Currency: "USD", Items: lines})
+	perInvoice := createInvoiceForTest(t, server, cookie, InvoiceCreateRequest{CustomerID: "cust-1", Currency: "USD", Items: lines, TaxRounding: tax.RoundInvoice})
+	if perLine.Tax != 3 || perInvoice.Tax != 2 {
+		t.Fatalf("expected line rounding 3 and invoice rounding 2, got %d and %d", perLine.Tax, perInvoice.Tax)
+	}
+	rec = doJSON(t, server, cookie, http.MethodPost, "/v1/invoices/"+perInvoice.ID+"/items", InvoiceLineItemRequest{Description: "d", Quantity: 1, UnitPrice: 1, TaxRate: 5000})
+	var grown invoice.Invoice
+	if err := json.NewDecoder(rec.Body).Decode(&grown); err != nil {
+		t.Fatalf("decode invoice: %v", err)
+	}
+	if rec.Code != http.StatusCreated || grown.Tax != 2 || grown.Items[2].Tax != 0 || grown.Items[3].Tax != 0 {
+		t.Fatalf("expected four halves to round to 2 on the first lines, got %d %#v", rec.Code, grown)
+	}
+
+	if rec := doJSON(t, server, cookie, http.MethodPost, "/v1/invoices", InvoiceCreateRequest{CustomerID: "cust-1", Currency: "USD", TaxRounding: "banker"}); rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected unknown rounding 400, got %d", rec.Code)
+	}
+}
+
+func TestInvoiceForTaxExemptCustomerHasNoTax(t *testing.T) {
+	server := NewServer()
+	cookie := loginForTest(t, server)
+	exempt := createCustomerForTest(t, server, cookie, CustomerCreateRequest{Name: "Charity", TaxExempt: true})
+	taxed := createCustomerForTest(t, server, cookie, CustomerCreateRequest{Name: "Acme"})
+
+	created := createInvoiceForTest(t, server, cookie, InvoiceCreateRequest{
+		CustomerID: exempt.ID,
+		Currency:   "ILS",
+		Items:      []InvoiceLineItemRequest{{Description: "Hosting", Quantity: 2, UnitPrice: 5000, TaxRate: 1700}},
+	})
+	if !created.TaxExempt || created.Tax != 0 || created.Total != 10000 || created.Items[0].Tax != 0 {
+		t.Fatalf("expected exempt invoice without tax, got %#v", created)
+	}
+
+	rec := patchInvoiceForTest(t, server, cookie, "/v1/invoices/"+created.ID, map[string]any{"customerId": taxed.ID})
+	var moved invoice.Invoice
+	if err := json.NewDecoder(rec.Body).Decode(&moved); err != nil {
+		t.Fatalf("decode invoice: %v", err)
+	}
+	if rec.Code != http.StatusOK || moved.TaxExempt || moved.Tax != 1700 || moved.Total != 11700 {
+		t.Fatalf("expected tax once billed to a taxed customer, got %d %#v", rec.Code, moved)
+	}
+	rec = doJSON(t, server, cookie, http.MethodGet, "/v1/invoices/"+created.ID, nil)
+	var stored invoice.Invoice
+	if err := json.NewDecoder(rec.Body).Decode(&stored); err != nil {
+		t.Fatalf("decode invoice: %v", err)
+	}
+	if stored.Items[0].Tax != 1700 {
+		t.Fatalf("expected stored line tax to follow the invoice, got %#v", stored.Items)
+	}
+}
+
+func TestInvoiceLookupsAndPaidDeleteConflict(t *testing.T) {
+	server := NewServer()
+	cookie := loginForTest(t, server)
+	seedCustomersForTest(t, server, "tenant-alpha", "cust-1", "cust-2")
+
+	rec := doJSON(t, server, cookie, http.MethodGet, "/v1/invoices/inv-missing", nil)
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("expected unknown invoice 404, got %d", rec.Code)
+	}
+
+	paid := createInvoiceForTest(t, server, cookie, InvoiceCreateRequest{
+		Number:     "INV-PAID",
+		CustomerID: "cust-1",
+		Currency:   "EUR",
+		Subtotal:   500,
+		Total:      500,
+		Status:     invoice.StatusPaid,
+		PaidAt:     "2026-06-02T09:30:00Z",
+	})
+	rec = doJSON(t, server, cookie, http.MethodDelete, "/v1/invoices/"+paid.ID, nil)
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("expected paid delete 409, got %d", rec.Code)
+	}
+
+	rec = doJSON(t, server, cookie, http.MethodPost, "/v1/invoices", InvoiceCreateRequest{
+		Number:     "INV-PAID",
+		CustomerID: "cust-2",
+		Currency:   "EUR",
+	})
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("expected duplicate number 409, got %d", rec.Code)
+	}
+}
+
+// createInvoiceForTest creates the invoice, and first the customer it
+// bills when the test has not.
+func createInvoiceForTest(t *testing.T, server *Server, cookie *http.Cookie, req InvoiceCreateRequest) invoice.Invoice {
+	t.Helper()
+
+	seedCustomersForTest(t, server, "tenant-alpha", req.CustomerID)
+	rec := doJSON(t, server, cookie, http.MethodPost, "/v1/invoices", req)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected create 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var item invoice.Invoice
+	if err := json.NewDecoder(rec.Body).Decode(&item); err != nil {
+		t.Fatalf("decode invoice: %v", err)
+	}
+	return item
+}
+
+func doJSON(t *testing.T, server *Server, cookie *http.Cookie, method, path string, payload any) *httptest.ResponseRecorder {
+	t.Helper()
+
+	var body bytes.Buffer
+	if payload != nil {
+		if err := json.NewEncoder(&body).Encode(payload); err != nil {
+			t.Fatalf("encode payload: %v", err)
+		}
+	}
+	req := httptest.NewRequest(method, path, &body)
+	req.Header.Set("Content-Type", "application/json")
+	if cookie != nil {
+		req.AddCookie(cookie)
+	}
+	rec := httptest.NewRecorder()
+	server.Handler().ServeHTTP(rec, req)
+	return rec
+}
+
+// patchInvoiceForTest PATCHes the invoice at path with the If-Match of its
+// current version.
+func patchInvoiceForTest(t *testing.T, server *Server, cookie *http.Cookie, path string, payload any) *httptest.ResponseRecorder {
+	t.Helper()
+
+	current := doJSON(t, server, cookie, http.MethodGet, path, nil)
+	var body bytes.Buffer
+	if err := json.NewEncoder(&body).Encode(payload); err != nil {
+		t.Fatalf("encode payload: %v", err)
+	}
+	req := httptest.NewRequest(http.MethodPatch, path, &body)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("If-Match", current.Header().Get("ETag"))
+	req.AddCookie(cookie)
+	rec := httptest.NewRecorder()
+	server.Handler().ServeHTTP(rec, req)
+	return rec
+}
+
+func TestListInvoicesPaginatesWithCursor(t *testing.T) {
+	server := NewServer()
+	cookie := loginForTest(t, server)
+	for day := 1; day <= 5; day++ {
+		createInvoiceForTest(t, server, cookie, InvoiceCreateRequest{
+			CustomerID: "cust-1",
+			Currency:   "ILS",
+			Subtotal:   int64(day * 100),
+			Total:      int64(day * 100),
+			IssuedAt:   fmt.Sprintf("2026-06-%02dT09:00:00Z", day),
+		})
+	}
+
+	seen := []int64{}
+	cursor := ""
+	for page := 0; page < 5; page++ {
+		path := "/v1/invoices?limit=2"
+		if cursor != "" {
+			path += "&cursor=" + cursor
+		}
+		rec := doJSON(t, server, cookie, http.MethodGet, path, nil)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected list 200, got %d: %s", rec.Code, rec.Body.String())
+		}
+		var list InvoiceList
+		if err := json.NewDecoder(rec.Body).Decode(&list); err != nil {
+			t.Fatalf("decode list: %v", err)
+		}
+		for _, item := range list.Items {
+			seen = append(seen, item.Total)
+		}
+		cursor = list.NextCursor
+		if cursor == "" {
+			break
+		}
+	}
+	want := []int64{500, 400, 300, 200, 100}
+	if fmt.Sprint(seen) != fmt.Sprint(want) {
+		t.Fatalf("expected newest-first totals %v, got %v", want, seen)
+	}
+}
+
+func TestListInvoicesFiltersAndSorts(t *testing.T) {
+	server := NewServer()
+	cookie := loginForTest(t, server)
+	createInvoiceForTest(t, server, cookie, InvoiceCreateRequest{CustomerID: "cust-1", Currency: "ILS", Subtotal: 300, Total: 300, IssuedAt: "2026-05-31T23:00:00Z"})
+	createInvoiceForTest(t, server, cookie, InvoiceCreateRequest{CustomerID: "cust-1", Currency: "ILS", Subtotal: 100, Total: 100, IssuedAt: "2026-06-10T09:00:00Z", Status: invoice.StatusOpen})
+	createInvoiceForTest(t, server, cookie, InvoiceCreateRequest{CustomerID: "cust-2", Currency: "ILS", Subtotal: 200, Total: 200, IssuedAt: "2026-06-30T09:00:00Z"})
+
+	cases := map[string][]int64{
+		"/v1/invoices?customerId=cust-1&sort=total&order=asc":             {100, 300},
+		"/v1/invoices?issuedFrom=2026-06-01&issuedTo=2026-06-30":          {200, 100},
+		"/v1/invoices?status=open":                                        {100},
+		"/v1/invoices?issuedTo=2026-06-10T09:00:00Z&sort=total&order=asc": {100, 300},
+	}
+	for path, want := range cases {
+		rec := doJSON(t, server, cookie, http.MethodGet, path, nil)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: expected 200, got %d", path, rec.Code)
+		}
+		var list InvoiceList
+		if err := json.NewDecoder(rec.Body).Decode(&list); err != nil {
+			t.Fatalf("decode list: %v", err)
+		}
+		got := []int64{}
+		for _, item := range list.Items {
+			got = append(got, item.Total)
+		}
+		if fmt.Sprint(got) != fmt.Sprint(want) {
+			t.Fatalf("%s: expected %v, got %v", path, want, got)
+		}
+	}
+}
+
+func TestListInvoicesRejectsBadCursorsAndParams(t *testing.T) {
+	server := NewServer()
+	cookie := loginForTest(t, server)
+	for i := 0; i < 2; i++ {
+		createInvoiceForTest(t, server, cookie, InvoiceCreateRequest{CustomerID: "cust-1", Currency: "ILS"})
+	}
+	rec := doJSON(t, server, cookie, http.MethodGet, "/v1/invoices?limit=1&sort=total", nil)
+	var list InvoiceList
+	if err := json.NewDecoder(rec.Body).Decode(&list); err != nil {
+		t.Fatalf("decode list: %v", err)
+	}
+	if list.NextCursor == "" {
+		t.Fatal("expected next cursor")
+	}
+
+	for _, path := range []string{
+		"/v1/invoices?cursor=not-a-cursor",
+		"/v1/invoices?cursor=" + list.NextCursor,
+		"/v1/invoices?sort=amount",
+		"/v1/invoices?limit=0",
+		"/v1/invoices?issuedFrom=June",
+		"/v1/invoices?status=sent",
+	} {
+		rec := doJSON(t, server, cookie, http.MethodGet, path, nil)
+		if rec.Code != http.StatusBadRequest {
+			t.Fatalf("%s: expected 400, got %d", path, rec.Code)
+		}
+	}
+}
+
+func TestInvoicePDFDownload(t *testing.T) {
+	server := NewServer()
+	cookie := loginForTest(t, server)
+	created := createInvoiceForTest(t, server, cookie, InvoiceCreateRequest{
+		Number:     "INV-0042",
+		CustomerID: "cust-1",
+		Currency:   "ILS",
+		Subtotal:   10000,
+		TaxRate:    1700,
+		Total:      11700,
+		IssuedAt:   "2026-06-01T00:00:00Z",
+	})
+
+	first := doJSON(t, server, cookie, http.MethodGet, "/v1/invoices/"+created.ID+"/pdf", nil)
+	if first.Code != http.StatusOK {
+		t.Fatalf("expected pdf 200, got %d", first.Code)
+	}
+	if got := first.Header().Get("Content-Type"); got != "application/pdf" {
+		t.Fatalf("unexpected content type %q", got)
+	}
+	if got := first.Header().Get("Content-Disposition"); got != `attachment; filename=invoice-INV-0042.pdf` {
+		t.Fatalf("unexpected content disposition %q", got)
+	}
+	second := doJSON(t, server, cookie, http.MethodGet, "/v1/invoices/"+created.ID+"/pdf", nil)
+	if !bytes.Equal(first.Body.Bytes(), second.Body.Bytes()) {
+		t.Fatal("expected identical PDFs for an unchanged invoice")
+	}
+}
+
+func TestInvoicePDFLocale(t *testing.T) {
+	renderer := &capturingRenderer{}
+	server := NewServer(WithPDFRenderer(renderer))
+	cookie := loginForTest(t, server)
+	created := createInvoiceForTest(t, server, cookie, InvoiceCreateRequest{CustomerID: "cust-1", Currency: "ILS", Subtotal: 10000})
+
+	cases := []struct {
+		query, acceptLanguage, locale, contentLanguage string
+	}{
+		{"", "", "", "en"},
+		{"", "fr-CA, he-IL;q=0.8, de;q=0.5", "he-IL", "he"},
+		{"", "en;q=0.4, de-AT", "de-AT", "de"},
+		{"?locale=de", "he", "de", "de"},
+		{"?locale=fr", "", "fr", "en"},
+	}
+	for _, tc := range cases {
+		req := httptest.NewRequest(http.MethodGet, "/v1/invoices/"+created.ID+"/pdf"+tc.query, nil)
+		if tc.acceptLanguage != "" {
+			req.Header.Set("Accept-Language", tc.acceptLanguage)
+		}
+		req.AddCookie(cookie)
+		rec := httptest.NewRecorder()
+		server.Handler().ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK || renderer.doc.Locale != tc.locale || rec.Header().Get("Content-Language") != tc.contentLanguage {
+			t.Errorf("%q %q: expected locale %q printed as %q, got %d %q %q", tc.query, tc.acceptLanguage, tc.locale, tc.contentLanguage, rec.Code, renderer.doc.Locale, rec.Header().Get("Content-Language"))
+		}
+		if !slices.Contains(rec.Header().Values("Vary"), "Accept-Language") {
+			t.Errorf("%q %q: expected the PDF to vary on Accept-Language, got %v", tc.query, tc.acceptLanguage, rec.Header().Values("Vary"))
+		}
+	}
+}
+
+func TestInvoiceLineItemsRecomputeTotals(t *testing.T) {
+	server := NewServer()
+	cookie := loginForTest(t, server)
+	created := createInvoiceForTest(t, server, cookie, InvoiceCreateRequest{
+		CustomerID: "cust-1",
+		Currency:   "ILS",
+		Items: []InvoiceLineItemRequest{
+			{Description: "Hosting", Quantity: 2, UnitPrice: 5000, TaxRate: 1700},
+		},
+	})
+	if created.Subtotal != 10000 || created.Tax != 1700 || created.Total != 11700 || len(created.Items) != 1 {
+		t.Fatalf("unexpected nested create: %#v", created)
+	}
+
+	added := doJSON(t, server, cookie, http.MethodPost, "/v1/invoices/"+created.ID+"/items", InvoiceLineItemRequest{
+		Description: "Setup", Quantity: 1, UnitPrice: 2500,
+	})
+	if added.Code != http.StatusCreated {
+		t.Fatalf("expected add item 201, got %d: %s", added.Code, added.Body.String())
+	}
+	var withSetup invoice.Invoice
+	if err := json.NewDecoder(added.Body).Decode(&withSetup); err != nil {
+		t.Fatalf("decode invoice: %v", err)
+	}
+	if withSetup.Subtotal != 12500 || withSetup.Tax != 1700 || withSetup.Total != 14200 || len(withSetup.Items) != 2 {
+		t.Fatalf("unexpected totals after add: %#v", withSetup)
+	}
+	if withSetup.Items[1].Position != 2 || withSetup.Items[1].Amount != 2500 {
+		t.Fatalf("unexpected added item: %#v", withSetup.Items[1])
+	}
+
+	removed := doJSON(t, server, cookie, http.MethodDelete, "/v1/invoices/"+created.ID+"/items/"+created.Items[0].ID, nil)
+	if removed.Code != http.StatusOK {
+		t.Fatalf("expected remove item 200, got %d: %s", removed.Code, removed.Body.String())
+	}
+	fetched := doJSON(t, server, cookie, http.MethodGet, "/v1/invoices/"+created.ID, nil)
+	var got invoice.Invoice
+	if err := json.NewDecoder(fetched.Body).Decode(&got); err != nil {
+		t.Fatalf("decode invoice: %v", err)
+	}
+	if got.Subtotal != 2500 || got.Tax != 0 || got.Total != 2500 || len(got.Items) != 1 {
+		t.Fatalf("unexpected totals after remove: %#v", got)
+	}
+
+	patched := patchInvoiceForTest(t, server, cookie, "/v1/invoices/"+created.ID, map[string]any{"total": 1})
+	if patched.Code != http.StatusBadRequest {
+		t.Fatalf("expected derived totals to reject PATCH, got %d", patched.Code)
+	}
+	missing := doJSON(t, server, cookie, http.MethodDelete, "/v1/invoices/"+created.ID+"/items/item-missing", nil)
+	if missing.Code != http.StatusNotFound {
+		t.Fatalf("expected unknown item 404, got %d", missing.Code)
+	}
+}
+
+func TestInvoiceLineItemsRejectInvalidInput(t *testing.T) {
+	server := NewServer()
+	cookie := loginForTest(t, server)
+
+	rec := doJSON(t, server, cookie, http.MethodPost, "/v1/invoices", InvoiceCreateRequest{
+		CustomerID: "cust-1",
+		Currency:   "ILS",
+		Items:      []InvoiceLineItemRequest{{Description: "Hosting", Quantity: 0, UnitPrice: 5000}},
+	})
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected zero quantity 400, got %d", rec.Code)
+	}
+	rec = doJSON(t, server, cookie, http.MethodPost, "/v1/invoices", InvoiceCreateRequest{
+		CustomerID: "cust-1",
+		Currency:   "ILS",
+		Subtotal:   100,
+		Total:      100,
+		Items:      []InvoiceLineItemRequest{{Description: "Hosting", Quantity: 1, UnitPrice: 5000}},
+	})
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected mismatched totals 400, got %d", rec.Code)
+	}
+}
+
+func TestInvoiceLineItemsAreFrozenOutsideDraft(t *testing.T) {
+	server := NewServer()
+	cookie := loginForTest(t, server)
+	created := createInvoiceForTest(t, server, cookie, InvoiceCreateRequest{
+		CustomerID: "cust-1",
+		Currency:   "ILS",
+		Items:      []InvoiceLineItemRequest{{Description: "Hosting", Quantity: 1, UnitPrice: 5000}},
+	})
+	opened := patchInvoiceForTest(t, server, cookie, "/v1/invoices/"+created.ID, map[string]any{"status": "open"})
+	if opened.Code != http.StatusOK {
+		t.Fatalf("expected open 200, got %d: %s", opened.Code, opened.Body.String())
+	}
+
+	added := doJSON(t, server, cookie, http.MethodPost, "/v1/invoices/"+created.ID+"/items", InvoiceLineItemRequest{
+		Description: "Extra", Quantity: 1, UnitPrice: 100,
+	})
+	if added.Code != http.StatusConflict {
+		t.Fatalf("expected add to open invoice 409, got %d", added.Code)
+	}
+	removed := doJSON(t, server, cookie, http.MethodDelete, "/v1/invoices/"+created.ID+"/items/"+created.Items[0].ID, nil)
+	if removed.Code != http.StatusConflict {
+		t.Fatalf("expected remove from open invoice 409, got %d", removed.Code)
+	}
+}
+
+func TestInvoiceStatusTransitions(t *testing.T) {
+	server := NewServer()
+	cookie := loginForTest(t, server)
+	created := createInvoiceForTest(t, server, cookie, InvoiceCreateRequest{CustomerID: "cust-1", Currency: "ILS"})
+	path := "/v1/invoices/" + created.ID
+
+	rec := patchInvoiceForTest(t, server, cookie, path, map[string]any{"status": "paid", "paidAt": "2026-06-02T09:30:00Z"})
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected draft -> paid 422, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var rejected struct {
+		Message string `json:"error"`
+		Code    string `json:"code"`
+		Details struct {
+			From    invoice.Status   `json:"from"`
+			To      invoice.Status   `json:"to"`
+			Allowed []invoice.Status `json:"allowed"`
+		} `json:"details"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&rejected); err != nil {
+		t.Fatalf("decode transition error: %v", err)
+	}
+	if rejected.Code != apierror.CodeInvalidTransition || rejected.Details.From != invoice.StatusDraft || rejected.Details.To != invoice.StatusPaid ||
+		!slices.Equal(rejected.Details.Allowed, []invoice.Status{invoice.StatusOpen, invoice.StatusVoid}) || rejected.Message == "" {
+		t.Fatalf("unexpected transition error %#v", rejected)
+	}
+
+	for _, status := range []string{"open", "overdue"} {
+		if rec := patchInvoiceForTest(t, server, cookie, path, map[string]any{"status": status}); rec.Code != http.StatusOK {
+			t.Fatalf("expected move to %s 200, got %d: %s", status, rec.Code, rec.Body.String())
+		}
+	}
+	if rec := patchInvoiceForTest(t, server, cookie, path, map[string]any{"status": "paid"}); rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected paid without paidAt 400, got %d", rec.Code)
+	}
+	rec = patchInvoiceForTest(t, server, cookie, path, map[string]any{
+		"status":           "paid",
+		"paidAt":           "2026-06-02T09:30:00Z",
+		"paymentReference": " TRX-991 ",
+	})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected overdue -> paid 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var paid invoice.Invoice
+	if err := json.NewDecoder(rec.Body).Decode(&paid); err != nil {
+		t.Fatalf("decode paid invoice: %v", err)
+	}
+	if paid.Status != invoice.StatusPaid || paid.PaidAt != "2026-06-02T09:30:00Z" || paid.PaymentReference != "TRX-991" {
+		t.Fatalf("unexpected paid invoice %#v", paid)
+	}
+
+	if rec := patchInvoiceForTest(t, server, cookie, path, map[string]any{"status": "open"}); rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected paid -> open 422, got %d", rec.Code)
+	}
+	// Paid invoices are reversed with a credit note, not voided.
+	if rec := patchInvoiceForTest(t, server, cookie, path, map[string]any{"status": "void"}); rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected paid -> void 422, got %d", rec.Code)
+	}
+
+	voided := createInvoiceForTest(t, server, cookie, InvoiceCreateRequest{CustomerID: "cust-1", Currency: "ILS", Status: invoice.StatusOpen})
+	rec = patchInvoiceForTest(t, server, cookie, "/v1/invoices/"+voided.ID, map[string]any{"status": "void"})
+	var void invoice.Invoice
+	if err := json.NewDecoder(rec.Body).Decode(&void); err != nil || rec.Code != http.StatusOK || void.VoidedAt == "" {
+		t.Fatalf("expected open -> void 200 with voidedAt, got %d %#v", rec.Code, void)
+	}
+	if rec := patchInvoiceForTest(t, server, cookie, "/v1/invoices/"+voided.ID, map[string]any{"status": "draft"}); rec.Code != http.StatusConflict {
+		t.Fatalf("expected void to be terminal, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestDuplicateInvoiceCopiesItemsIntoNewDraft(t *testing.T) {
+	server := NewServer()
+	cookie := loginForTest(t, server)
+	source := createInvoiceForTest(t, server, cookie, InvoiceCreateRequest{
+		CustomerID:  "cust-1",
+		Currency:    "ILS",
+		TaxRounding: tax.RoundInvoice,
+		Status:      invoice.StatusOpen,
+		IssuedAt:    "2024-03-01T09:00:00Z",
+		DueAt:       "2024-03-31T09:00:00Z",
+		Items: []InvoiceLineItemRequest{
+			{Description: "Hosting", Quantity: 2, UnitPrice: 5000, TaxRate: 1700},
+			{Description: "Setup", Quantity: 1, UnitPrice: 2500},
+		},
+	})
+	recordPaymentForTest(t, server, cookie, source.ID, PaymentCreateRequest{Amount: source.Total, Method: invoice.PaymentCash})
+
+	rec := doJSON(t, server, cookie, http.MethodPost, "/v1/invoices/"+source.ID+"/duplicate", nil)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected duplicate 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var copied invoice.Invoice
+	if err := json.NewDecoder(rec.Body).Decode(&copied); err != nil {
+		t.Fatalf("decode invoice: %v", err)
+	}
+	if rec.Header().Get("Location") != "/v1/invoices/"+copied.ID {
+		t.Fatalf("expected Location of the copy, got %q", rec.Header().Get("Location"))
+	}
+	if copied.ID == source.ID || copied.Number != "" {
+		t.Fatalf("expected a new invoice numbered once it is issued, got %s %s", copied.ID, copied.Number)
+	}
+	if copied.Status != invoice.StatusDraft || copied.AmountPaid != 0 || copied.IssuedAt != "" || copied.DueAt != "" || copied.PaidAt != "" || copied.PaymentReference != "" || copied.Version != 1 {
+		t.Fatalf("expected an unpaid draft without dates, got %#v", copied)
+	}
+	if copied.CustomerID != source.CustomerID || copied.Currency != source.Currency || copied.TaxRounding != tax.RoundInvoice ||
+		copied.Subtotal != source.Subtotal || copied.Tax != source.Tax || copied.Total != source.Total || len(copied.Items) != 2 {
+		t.Fatalf("expected the source's customer, currency and amounts, got %#v", copied)
+	}
+	for i, line := range copied.Items {
+		want := source.Items[i]
+		if line.ID == want.ID || line.InvoiceID != copied.ID || line.Position != want.Position || line.Description != want.Description ||
+			line.Quantity != want.Quantity || line.UnitPrice != want.UnitPrice || line.TaxRate != want.TaxRate {
+			t.Fatalf("expected item %d copied, got %#v from %#v", i, line, want)
+		}
+	}
+	if item := getInvoiceForTest(t, server, cookie, source.ID); item.Status != invoice.StatusPaid || item.AmountPaid != source.Total {
+		t.Fatalf("expected the source left paid, got %#v", item)
+	}
+}
+
+func TestDuplicateInvoiceAppliesOverrides(t *testing.T) {
+	server := NewServer()
+	cookie := loginForTest(t, server)
+	seedCustomersForTest(t, server, "tenant-alpha", "cust-1", "cust-2")
+	source := createInvoiceForTest(t, server, cookie, InvoiceCreateRequest{CustomerID: "cust-1", Currency: "ILS", Subtotal: 1000, TaxRate: 1700, Status: invoice.StatusOpen})
+	if rec := doJSON(t, server, cookie, http.MethodPost, "/v1/invoices/"+source.ID+"/void", InvoiceVoidRequest{Reason: "sent twice"}); rec.Code != http.StatusOK {
+		t.Fatalf("expected void 200, got %d", rec.Code)
+	}
+
+	rec := doJSON(t, server, cookie, http.MethodPost, "/v1/invoices/"+source.ID+"/duplicate", map[string]any{
+		"customerId": "cust-2",
+		"dueAt":      "2024-05-31T09:00:00Z",
+	})
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected duplicate of a void invoice 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var copied invoice.Invoice
+	if err := json.NewDecoder(rec.Body).Decode(&copied); err != nil {
+		t.Fatalf("decode invoice: %v", err)
+	}
+	if copied.Status != invoice.StatusDraft || copied.VoidedAt != "" || copied.CustomerID != "cust-2" || copied.DueAt != "2024-05-31T09:00:00Z" ||
+		copied.Subtotal != 1000 || copied.Tax != 170 || copied.Total != 1170 {
+		t.Fatalf("expected a draft with the overrides, got %#v", copied)
+	}
+
+	rec = doJSON(t, server, cookie, http.MethodPost, "/v1/invoices/"+source.ID+"/duplicate", map[string]any{"number": source.Number})
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("expected a taken number 409, got %d", rec.Code)
+	}
+	rec = doJSON(t, server, cookie, http.MethodPost, "/v1/invoices/"+source.ID+"/duplicate", map[string]any{"status": "paid"})
+	if code := decodeErrorCodeForTest(t, rec); rec.Code != http.StatusBadRequest || code != apierror.CodeInvalidJSON {
+		t.Fatalf("expected status not to be overridable, got %d %s", rec.Code, code)
+	}
+	if rec := doJSON(t, server, cookie, http.MethodPost, "/v1/invoices/inv-missing/duplicate", nil); rec.Code != http.StatusNotFound {
+		t.Fatalf("expected an unknown source 404, got %d", rec.Code)
+	}
+}
+
+func TestCreateInvoiceWithIdempotencyKey(t *testing.T) {
+	store := NewMemoryStore()
+	server := NewServer(WithStore(store))
+	cookie := loginForTest(t, server)
+	seedCustomersForTest(t, server, "tenant-alpha", "cust-1")
+	create := func(key string, req InvoiceCreateRequest) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(req)
+		httpReq := httptest.NewRequest(http.MethodPost, "/v1/invoices", bytes.NewReader(body))
+		httpReq.Header.Set("Content-Type", "application/json")
+		httpReq.AddCookie(cookie)
+		httpReq.Header.Set("Content-Type", "application/json")
+		httpReq.Header.Set("Idempotency-Key", key)
+		rec := httptest.NewRecorder()
+		server.Handler().ServeHTTP(rec, httpReq)
+		return rec
+	}
+	req := InvoiceCreateRequest{CustomerID: "cust-1", Currency: "ILS", Subtotal: 100, Total: 100}
+
+	first := create("create-1", req)
+	second := create("create-1", req)
+	if first.Code != http.StatusCreated || second.Code != http.StatusCreated {
+		t.Fatalf("expected both calls 201, got %d and %d", first.Code, second.Code)
+	}
+	if first.Body.String() != second.Body.String() || second.Header().Get("Idempotency-Replayed") != "true" {
+		t.Fatalf("expected the first response replayed, got %s vs %s", first.Body.String(), second.Body.String())
+	}
+	if location := first.Header().Get("Location"); location == "" || second.Header().Get("Location") != location {
+		t.Fatalf("expected the replay to carry Location %q, got %q", location, second.Header().Get("Location"))
+	}
+
+	changed := req
+	changed.Total, changed.Subtotal = 200, 200
+	conflict := create("create-1", changed)
+	if conflict.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected reused key with another body 422, got %d: %s", conflict.Code, conflict.Body.String())
+	}
+
+	// Backdate the stored record past its TTL; the key is free again.
+	id := idempotencyRecordID("tenant-alpha", "POST /v1/invoices", "create-1")
+	record := store.idempotencyKeys[id]
+	record.ExpiresAt = time.Now().UTC().Add(-time.Second).Format(time.RFC3339)
+	store.idempotencyKeys[id] = record
+	if rec := create("create-1", changed); rec.Code != http.StatusCreated || rec.Header().Get("Idempotency-Replayed") != "" {
+		t.Fatalf("expected expired key to create a new invoice, got %d", rec.Code)
+	}
+
+	list := doJSON(t, server, cookie, http.MethodGet, "/v1/invoices", nil)
+	var page InvoiceList
+	if err := json.NewDecoder(list.Body).Decode(&page); err != nil {
+		t.Fatalf("decode list: %v", err)
+	}
+	if len(page.Items) != 2 {
+		t.Fatalf("expected two invoices, got %d", len(page.Items))
+	}
+}
+
+// announceFailingStore stores invoices but, once failing is set, fails the
+// activity and webhook writes that follow them.
+type announceFailingStore struct {
+	*MemoryStore
+	failing bool
+}
+
+func (s *announceFailingStore) CreateAuditEvent(ctx context.Context, event AuditEvent) error {
+	if s.failing {
+		return errors.New("audit log unavailable")
+	}
+	return s.MemoryStore.CreateAuditEvent(ctx, event)
+}
+
+func (s *announceFailingStore) CreateWebhookDeliveries(ctx context.Context, deliveries []WebhookDelivery) error {
+	if s.failing {
+		return errors.New("webhook queue unavailable")
+	}
+	return s.MemoryStore.CreateWebhookDeliveries(ctx, deliveries)
+}
+
+func TestCreateInvoiceSurvivesAFailedAnnouncement(t *testing.T) {
+	var logs bytes.Buffer
+	store := &announceFailingStore{MemoryStore: NewMemoryStore()}
+	server := NewServer(WithStore(store), WithLogger(logging.New(&logs, nil)))
+	cookie := loginForTest(t, server)
+	seedCustomersForTest(t, server, "tenant-alpha", "cust-1")
+	createWebhookForTest(t, server, cookie, WebhookSubscriptionCreateRequest{URL: "https://hooks.example.com/created", Events: []string{EventInvoiceCreated}})
+	req := InvoiceCreateRequest{CustomerID: "cust-1", Currency: "ILS", Subtotal: 100, Total: 100}
+	store.failing = true
+
+	first := conditionalRequestForTest(server, cookie, http.MethodPost, "/v1/invoices", "Idempotency-Key", "create-1", req)
+	if first.Code != http.StatusCreated || first.Header().Get("Location") == "" {
+		t.Fatalf("expected 201 with Location despite the failed announcement, got %d: %s", first.Code, first.Body.String())
+	}
+	retry := conditionalRequestForTest(server, cookie, http.MethodPost, "/v1/invoices", "Idempotency-Key", "create-1", req)
+	if retry.Code != http.StatusCreated || retry.Header().Get("Idempotency-Replayed") != "true" || retry.Body.String() != first.Body.String() {
+		t.Fatalf("expected the retry to replay the first response, got %d: %s", retry.Code, retry.Body.String())
+	}
+
+	var page InvoiceList
+	if err := json.NewDecoder(doJSON(t, server, cookie, http.MethodGet, "/v1/invoices", nil).Body).Decode(&page); err != nil {
+		t.Fatalf("decode list: %v", err)
+	}
+	if len(page.Items) != 1 {
+		t.Fatalf("expected a single invoice after the retry, got %d", len(page.Items))
+	}
+	if !strings.Contains(logs.String(), "record invoice created activity") || !strings.Contains(logs.String(), "publish invoice created webhook") {
+		t.Fatalf("expected both failures logged, got %s", logs.String())
+	}
+}
+
+func TestInvoicesAreIsolatedBetweenOrgs(t *testing.T) {
+	key, server := newBearerTestServer(t)
+	orgA := signBearerForTest(t, key, "user-a", "org-a", time.Hour)
+	orgB := signBearerForTest(t, key, "user-b", "org-b", time.Hour)
+	seedCustomersForTest(t, server, "org-a", "cust-1")
+	seedCustomersForTest(t, server, "org-b", "cust-9")
+
+	rec := doBearer(t, server, http.MethodPost, "/v1/invoices", orgA, InvoiceCreateRequest{
+		Number:     "INV-0001",
+		CustomerID: "cust-1",
+		Currency:   "ILS",
+		Items:      []InvoiceLineItemRequest{{Description: "Hosting", Quantity: 1, UnitPrice: 1000}},
+	})
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected create 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var created invoice.Invoice
+	if err := json.NewDecoder(rec.Body).Decode(&created); err != nil {
+		t.Fatalf("decode invoice: %v", err)
+	}
+	if created.TenantID != "org-a" {
+		t.Fatalf("expected invoice owned by org-a, got %q", created.TenantID)
+	}
+
+	base := "/v1/invoices/" + created.ID
+	total := int64(1)
+	attempts := []struct {
+		method, path string
+		payload      any
+	}{
+		{http.MethodGet, base, nil},
+		{http.MethodGet, base + "/pdf", nil},
+		{http.MethodPatch, base, InvoiceUpdateRequest{Total: &total}},
+		{http.MethodPost, base + "/items", InvoiceLineItemRequest{Description: "Leak", Quantity: 1, UnitPrice: 1}},
+		{http.MethodDelete, base + "/items/" + created.Items[0].ID, nil},
+		{http.MethodDelete, base, nil},
+	}
+	for _, attempt := range attempts {
+		if rec := doBearer(t, server, attempt.method, attempt.path, orgB, attempt.payload); rec.Code != http.StatusNotFound {
+			t.Fatalf("%s %s as org-b: expected 404, got %d: %s", attempt.method, attempt.path, rec.Code, rec.Body.String())
+		}
+	}
+
+	rec = doBearer(t, server, http.MethodGet, "/v1/invoices", orgB, nil)
+	var list InvoiceList
+	if err := json.NewDecoder(rec.Body).Decode(&list); err != nil {
+		t.Fatalf("decode list: %v", err)
+	}
+	if len(list.Items) != 0 {
+		t.Fatalf("expected org-b to see no invoices, got %#v", list.Items)
+	}
+
+	rec = doBearer(t, server, http.MethodGet, base, orgA, nil)
+	var unchanged invoice.Invoice
+	if err := json.NewDecoder(rec.Body).Decode(&unchanged); err != nil {
+		t.Fatalf("decode invoice: %v", err)
+	}
+	if rec.Code != http.StatusOK || unchanged.Total != created.Total || len(unchanged.Items) != 1 {
+		t.Fatalf("expected org-a invoice untouched, got %d %#v", rec.Code, unchanged)
+	}
+
+	if rec := doBearer(t, server, http.MethodPost, "/v1/invoices", orgB, InvoiceCreateRequest{Number: "INV-0001", CustomerID: "cust-9", Currency: "ILS"}); rec.Code != http.StatusCreated {
+		t.Fatalf("expected invoice numbers to be unique per org only, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if rec := doBearer(t, server, http.MethodPost, "/v1/invoices", orgB, InvoiceCreateRequest{Number: "INV-0002", CustomerID: "cust-1", Currency: "ILS"}); rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected org-a's customer to be unknown to org-b, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestInvoicesMustBillALiveCustomerOfTheTenant(t *testing.T) {
+	server := NewServer()
+	cookie := loginForTest(t, server)
+	customer := createCustomerForTest(t, server, cookie, CustomerCreateRequest{Name: "Acme"})
+	gone := createCustomerForTest(t, server, cookie, CustomerCreateRequest{Name: "Globex"})
+	if rec := doJSON(t, server, cookie, http.MethodDelete, "/v1/customers/"+gone.ID, nil); rec.Code != http.StatusNoContent {
+		t.Fatalf("expected customer delete 204, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	for _, customerID := range []string{"cust-missing", gone.ID} {
+		rec := doJSON(t, server, cookie, http.MethodPost, "/v1/invoices", InvoiceCreateRequest{CustomerID: customerID, Currency: "ILS"})
+		if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "customerId") {
+			t.Fatalf("%s: expected create 400 on customerId, got %d: %s", customerID, rec.Code, rec.Body.String())
+		}
+	}
+
+	created := createInvoiceForTest(t, server, cookie, InvoiceCreateRequest{CustomerID: customer.ID, Currency: "ILS"})
+	moved := gone.ID
+	if rec := patchInvoiceForTest(t, server, cookie, "/v1/invoices/"+created.ID, InvoiceUpdateRequest{CustomerID: &moved}); rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected moving to a deleted customer to be 400, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	rec := doJSON(t, server, cookie, http.MethodPost, "/v1/invoices/batch?mode=partial", InvoiceBatchRequest{Items: []InvoiceCreateRequest{
+		{CustomerID: customer.ID, Currency: "ILS"},
+		{CustomerID: "cust-missing", Currency: "ILS"},
+	}})
+	resp := decodeBatchResponseForTest(t, rec, http.StatusOK)
+	if resp.Created != 1 || resp.Results[1].Error == nil || resp.Results[1].Error.Details["customerId"] == nil {
+		t.Fatalf("expected the unknown customer's item rejected, got %#v", resp.Results)
+	}
+}
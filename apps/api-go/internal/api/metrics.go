@@ -0,0 +1,230 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const metricsPath = "/metrics"
+
+// serverMetrics owns every collector the API exports. They live on a
+// per-server registry instead of the global default so tests can build a
+// server and read exact values back.
+type serverMetrics struct {
+	registry          *prometheus.Registry
+	requests          *prometheus.CounterVec
+	requestDuration   *prometheus.HistogramVec
+	inFlight          prometheus.Gauge
+	invoicesCreated   prometheus.Counter
+	collectionJobs    *prometheus.CounterVec
+	webhookDeliveries *prometheus.CounterVec
+	handler           http.Handler
+}
+
+func newServerMetrics(registry *prometheus.Registry) *serverMetrics {
+	m := &serverMetrics{
+		registry: registry,
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "invoicer",
+			Name:      "http_requests_total",
+			Help:      "HTTP requests served, by route pattern, method and status code.",
+		}, []string{"route", "method", "status"}),
+		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: "invoicer",
+			Name:      "http_request_duration_seconds",
+			Help:      "HTTP request latency, by route pattern, method and status code.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"route", "method", "status"}),
+		inFlight: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: "invoicer",
+			Name:      "http_requests_in_flight",
+			Help:      "HTTP requests currently being served.",
+		}),
+		invoicesCreated: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: "invoicer",
+			Name:      "invoices_created_total",
+			Help:      "Invoices created.",
+		}),
+		collectionJobs: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "invoicer",
+			Name:      "collection_jobs_completed_total",
+			Help:      "Collection jobs that reached a final state, by status.",
+		}, []string{"status"}),
+		webhookDeliveries: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "invoicer",
+			Name:      "webhook_deliveries_completed_total",
+			Help:      "Webhook deliveries that reached a final state, by status.",
+		}, []string{"status"}),
+	}
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.requests,
+		m.requestDuration,
+		m.inFlight,
+		m.invoicesCreated,
+		m.collectionJobs,
+		m.webhookDeliveries,
+	)
+	m.handler = promhttp.HandlerFor(registry, promhttp.HandlerOpts{Registry: registry})
+	return m
+}
+
+// withMetrics records request counts, latency and concurrency. Scrapes of
+// /metrics are left out so the scraper does not inflate its own numbers.
+func (s *Server) withMetrics(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == metricsPath {
+			next.ServeHTTP(w, r)
+			return
+		}
+		s.metrics.inFlight.Inc()
+		defer s.metrics.inFlight.Dec()
+
+		started := time.Now()
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(recorder, r)
+
+		labels := prometheus.Labels{
+			"route":  routePattern(r.URL.Path),
+			"method": methodLabel(r.Method),
+			"status": strconv.Itoa(recorder.status),
+		}
+		s.metrics.requests.With(labels).Inc()
+		s.metrics.requestDuration.With(labels).Observe(time.Since(started).Seconds())
+	})
+}
+
+func (m *serverMetrics) recordCollectionJob(status string) {
+	if status == CollectionJobSucceeded || status == CollectionJobFailed {
+		m.collectionJobs.WithLabelValues(status).Inc()
+	}
+}
+
+var exactRoutes = map[string]bool{
+	"/healthz":             true,
+	"/readyz":              true,
+	"/info":                true,
+	"/openapi.json":        true,
+	"/docs":                true,
+	"/auth/login":          true,
+	"/auth/logout":         true,
+	"/auth/refresh":        true,
+	"/v1/me":               true,
+	"/v1/audit-events":     true,
+	"/v1/invoices/batch":   true,
+	"/v1/invoices/export":  true,
+	"/v1/invoices/import":  true,
+	"/v1/invoices/search":  true,
+	"/v1/invoices/summary": true,
+}
+
+// resourceRoutes are the /v1 collections with /{id} detail routes.
+var resourceRoutes = map[string]bool{
+	"provider-configs":   true,
+	"collection-jobs":    true,
+	"reports":            true,
+	"schedules":          true,
+	"invoices":           true,
+	"webhooks":           true,
+	"customers":          true,
+	"recurring-invoices": true,
+}
+
+// routeActions are the fixed path words below a resource ID. Any other
+// segment is treated as an ID when it follows a sub-resource name.
+var routeActions = map[string]bool{
+	"oauth":        true,
+	"start":        true,
+	"callback":     true,
+	"refresh":      true,
+	"revoke":       true,
+	"retry":        true,
+	"events":       true,
+	"pause":        true,
+	"resume":       true,
+	"pdf":          true,
+	"items":        true,
+	"deliveries":   true,
+	"payments":     true,
+	"pay":          true,
+	"history":      true,
+	"remind":       true,
+	"attachments":  true,
+	"download":     true,
+	"void":         true,
+	"credit-note":  true,
+	"credit-notes": true,
+	"duplicate":    true,
+	"share":        true,
+}
+
+// routeSubResources name the segments whose next segment is an ID.
+var routeSubResources = map[string]string{
+	"items":        "{itemId}",
+	"attachments":  "{attachmentId}",
+	"credit-notes": "{creditNoteId}",
+}
+
+// routePattern maps a request path to its route template, e.g.
+// /v1/invoices/inv-1/items/item-2 -> /v1/invoices/{id}/items/{itemId}, so
+// metric labels stay bounded no matter what clients send.
+func routePattern(path string) string {
+	if exactRoutes[path] {
+		return path
+	}
+	if strings.HasPrefix(path, paymentWebhooksPath) {
+		return paymentWebhooksPath + "{provider}"
+	}
+	if strings.HasPrefix(path, webhookDeliveriesPath) {
+		if _, action, _ := strings.Cut(strings.TrimPrefix(path, webhookDeliveriesPath), "/"); action == "replay" {
+			return webhookDeliveriesPath + "{deliveryId}/replay"
+		}
+		return "unmatched"
+	}
+	if strings.HasPrefix(path, publicInvoicesPath) {
+		switch _, action, _ := strings.Cut(strings.TrimPrefix(path, publicInvoicesPath), "/"); action {
+		case "":
+			return publicInvoicesPath + "{token}"
+		case "pdf":
+			return publicInvoicesPath + "{token}/pdf"
+		}
+		return "unmatched"
+	}
+	segments := strings.Split(strings.TrimPrefix(path, "/"), "/")
+	if len(segments) < 2 || segments[0] != "v1" || !resourceRoutes[segments[1]] {
+		return "unmatched"
+	}
+	pattern := "/v1/" + segments[1]
+	if len(segments) == 2 {
+		return pattern
+	}
+	pattern += "/{id}"
+	for i := 3; i < len(segments); i++ {
+		segment := segments[i]
+		if placeholder, ok := routeSubResources[segments[i-1]]; ok && i > 3 {
+			pattern += "/" + placeholder
+			continue
+		}
+		if !routeActions[segment] {
+			return "unmatched"
+		}
+		pattern += "/" + segment
+	}
+	return pattern
+}
+
+func methodLabel(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut,
+		http.MethodPatch, http.MethodDelete, http.MethodOptions:
+		return method
+	}
+	return "OTHER"
+}
@@ -0,0 +1,139 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestMetricsCountRequestsByRouteAndStatus(t *testing.T) {
+	server := NewServer()
+	cookie := loginForTest(t, server)
+
+	doJSON(t, server, cookie, http.MethodGet, "/v1/invoices/inv-missing", nil)
+	doJSON(t, server, cookie, http.MethodGet, "/v1/invoices/inv-other", nil)
+	doJSON(t, server, cookie, http.MethodGet, "/v1/nope/1", nil)
+
+	if got := testutil.ToFloat64(server.metrics.requests.WithLabelValues("/auth/login", "POST", "200")); got != 1 {
+		t.Fatalf("expected 1 login request, got %v", got)
+	}
+	if got := testutil.ToFloat64(server.metrics.requests.WithLabelValues("/v1/invoices/{id}", "GET", "404")); got != 2 {
+		t.Fatalf("expected IDs to collapse into one series, got %v", got)
+	}
+	if got := testutil.ToFloat64(server.metrics.requests.WithLabelValues("unmatched", "GET", "404")); got != 1 {
+		t.Fatalf("expected unknown paths under unmatched, got %v", got)
+	}
+	if got := testutil.CollectAndCount(server.metrics.requestDuration); got != 3 {
+		t.Fatalf("expected 3 latency series, got %d", got)
+	}
+	if got := testutil.ToFloat64(server.metrics.inFlight); got != 0 {
+		t.Fatalf("expected no requests in flight, got %v", got)
+	}
+}
+
+func TestMetricsEndpointDoesNotCountItself(t *testing.T) {
+	server := NewServer()
+
+	for i := 0; i < 2; i++ {
+		rec := httptest.NewRecorder()
+		server.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected metrics 200, got %d", rec.Code)
+		}
+		if !strings.Contains(rec.Body.String(), "invoicer_http_requests_in_flight") {
+			t.Fatalf("expected exposition to include server metrics: %s", rec.Body.String())
+		}
+	}
+	if got := testutil.CollectAndCount(server.metrics.requests); got != 0 {
+		t.Fatalf("expected scrapes to be excluded, got %d series", got)
+	}
+}
+
+func TestMetricsTrackBusinessEvents(t *testing.T) {
+	server := NewServer(WithCollectionRunner(fakeCollectionRunner{err: "provider timeout"}))
+	cookie := loginForTest(t, server)
+
+	createInvoiceForTest(t, server, cookie, InvoiceCreateRequest{
+		Number:     "INV-0001",
+		CustomerID: "cust-1",
+		Currency:   "ils",
+		Subtotal:   100,
+		Total:      100,
+		IssuedAt:   "2026-06-01T00:00:00Z",
+		DueAt:      "2026-06-30T00:00:00Z",
+	})
+	createCollectionJobForTest(t, server, cookie)
+
+	if got := testutil.ToFloat64(server.metrics.invoicesCreated); got != 1 {
+		t.Fatalf("expected 1 invoice created, got %v", got)
+	}
+	if got := testutil.ToFloat64(server.metrics.collectionJobs.WithLabelValues(CollectionJobFailed)); got != 1 {
+		t.Fatalf("expected 1 failed collection job, got %v", got)
+	}
+	if got := testutil.ToFloat64(server.metrics.collectionJobs.WithLabelValues(CollectionJobSucceeded)); got != 0 {
+		t.Fatalf("expected no succeeded collection jobs, got %v", got)
+	}
+}
+
+func TestRoutePattern(t *testing.T) {
+	cases := map[string]string{
+		"/healthz":                                        "/healthz",
+		"/v1/invoices":                                    "/v1/invoices",
+		"/v1/invoices/inv-1":                              "/v1/invoices/{id}",
+		"/v1/invoices/export":                             "/v1/invoices/export",
+		"/v1/invoices/import":                             "/v1/invoices/import",
+		"/v1/invoices/search":                             "/v1/invoices/search",
+		"/v1/invoices/inv-1/pdf":                          "/v1/invoices/{id}/pdf",
+		"/v1/invoices/inv-1/items":                        "/v1/invoices/{id}/items",
+		"/v1/invoices/inv-1/items/item-2":                 "/v1/invoices/{id}/items/{itemId}",
+		"/v1/invoices/inv-1/attachments/att-2/download":   "/v1/invoices/{id}/attachments/{attachmentId}/download",
+		"/v1/invoices/inv-1/payments":                     "/v1/invoices/{id}/payments",
+		"/v1/invoices/inv-1/void":                         "/v1/invoices/{id}/void",
+		"/v1/invoices/inv-1/credit-note":                  "/v1/invoices/{id}/credit-note",
+		"/v1/invoices/inv-1/pay":                          "/v1/invoices/{id}/pay",
+		"/v1/invoices/inv-1/duplicate":                    "/v1/invoices/{id}/duplicate",
+		"/v1/invoices/inv-1/share":                        "/v1/invoices/{id}/share",
+		"/v1/payments/webhooks/stripe":                    "/v1/payments/webhooks/{provider}",
+		"/v1/public/invoices/share-1.1700000000.c2ln":     "/v1/public/invoices/{token}",
+		"/v1/public/invoices/share-1.1700000000.c2ln/pdf": "/v1/public/invoices/{token}/pdf",
+		"/v1/public/invoices/share-1.1700000000.c2ln/x":   "unmatched",
+		"/v1/invoices/inv-1/credit-notes/cn-2/pdf":        "/v1/invoices/{id}/credit-notes/{creditNoteId}/pdf",
+		"/v1/collection-jobs/job-1/retry":                 "/v1/collection-jobs/{id}/retry",
+		"/v1/collection-jobs/job-1/events":                "/v1/collection-jobs/{id}/events",
+		"/v1/provider-configs/pc-1/oauth/start":           "/v1/provider-configs/{id}/oauth/start",
+		"/v1/invoices/inv-1/anything":                     "unmatched",
+		"/info":                                           "/info",
+		"/v1/webhooks/deliveries/whd-1/replay":            "/v1/webhooks/deliveries/{deliveryId}/replay",
+		"/v1/webhooks/deliveries/whd-1":                   "unmatched",
+		"/v1/unknown":                                     "unmatched",
+		"/":                                               "unmatched",
+	}
+	for path, want := range cases {
+		if got := routePattern(path); got != want {
+			t.Errorf("routePattern(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
+
+func TestMetricsExposePoolStats(t *testing.T) {
+	server := NewServer(WithPoolStats(func() PoolStats {
+		return PoolStats{MaxConns: 20, TotalConns: 5, InUse: 3, Idle: 2, WaitCount: 7, WaitDurationSeconds: 0.25}
+	}))
+
+	rec := httptest.NewRecorder()
+	server.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	for _, line := range []string{
+		"invoicer_db_pool_max_connections 20",
+		`invoicer_db_pool_connections{state="in_use"} 3`,
+		`invoicer_db_pool_connections{state="idle"} 2`,
+		"invoicer_db_pool_wait_count_total 7",
+		"invoicer_db_pool_wait_duration_seconds_total 0.25",
+	} {
+		if !strings.Contains(rec.Body.String(), line) {
+			t.Fatalf("expected %q in exposition: %s", line, rec.Body.String())
+		}
+	}
+}
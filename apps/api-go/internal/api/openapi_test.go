@@ -0,0 +1,202 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/apierror"
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/invoice"
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/openapi"
+)
+
+type specDocument struct {
+	Paths      map[string]map[string]json.RawMessage `json:"paths"`
+	Components struct {
+		Schemas map[string]struct {
+			Properties map[string]json.RawMessage `json:"properties"`
+		} `json:"schemas"`
+	} `json:"components"`
+}
+
+func loadSpecForTest(t *testing.T) specDocument {
+	t.Helper()
+
+	var spec specDocument
+	if err := json.Unmarshal(openapi.JSON, &spec); err != nil {
+		t.Fatalf("decode spec: %v", err)
+	}
+	return spec
+}
+
+func TestOpenAPIDocumentAndDocsAreServed(t *testing.T) {
+	server := NewServer()
+
+	rec := httptest.NewRecorder()
+	server.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "application/json" || !bytes.Equal(rec.Body.Bytes(), openapi.JSON) {
+		t.Fatalf("expected the embedded document, got %d %q", rec.Code, rec.Header().Get("Content-Type"))
+	}
+
+	rec = httptest.NewRecorder()
+	server.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/docs", nil))
+	if rec.Code != http.StatusOK || !strings.HasPrefix(rec.Header().Get("Content-Type"), "text/html") || !strings.Contains(rec.Body.String(), `url: "/openapi.json"`) {
+		t.Fatalf("expected Swagger UI pointing at /openapi.json, got %d %s", rec.Code, rec.Body.String())
+	}
+}
+
+// TestSpecOperationsAreRouted sends every documented operation to the
+// server. Whatever the answer, it must not be the router's own 404 or 405:
+// those mean the spec documents a route the server does not have.
+func TestSpecOperationsAreRouted(t *testing.T) {
+	server := NewServer()
+	cookie := loginForTest(t, server)
+	placeholder := regexp.MustCompile(`\{[^}]+\}`)
+
+	for path, operations := range loadSpecForTest(t).Paths {
+		for method := range operations {
+			if method == "parameters" {
+				continue
+			}
+			req := httptest.NewRequest(strings.ToUpper(method), placeholder.ReplaceAllString(path, "missing"), strings.NewReader("{}"))
+			req.Header.Set("Content-Type", "application/json")
+			req.AddCookie(cookie)
+			rec := httptest.NewRecorder()
+			server.Handler().ServeHTTP(rec, req)
+
+			var body apierror.Error
+			_ = json.Unmarshal(rec.Body.Bytes(), &body)
+			if rec.Code == http.StatusMethodNotAllowed || (rec.Code == http.StatusNotFound && body.Code == apierror.CodeNotFound) {
+				t.Errorf("%s %s is documented but not routed: %d %s", strings.ToUpper(method), path, rec.Code, rec.Body.String())
+			}
+		}
+	}
+}
+
+// TestServerRoutesAreDocumented checks the other direction, using the route
+// tables metrics label requests with.
+func TestServerRoutesAreDocumented(t *testing.T) {
+	spec := loadSpecForTest(t)
+	documented := func(path string) bool {
+		_, ok := spec.Paths[path]
+		return ok
+	}
+	for path := range exactRoutes {
+		if !documented(path) {
+			t.Errorf("route %s is not in the spec", path)
+		}
+	}
+	for resource := range resourceRoutes {
+		collection := "/v1/" + resource
+		if !documented(collection) {
+			t.Errorf("route %s is not in the spec", collection)
+		}
+		hasDetail := false
+		for path := range spec.Paths {
+			hasDetail = hasDetail || strings.HasPrefix(path, collection+"/{")
+		}
+		if !hasDetail {
+			t.Errorf("no route below %s/{id} is in the spec", collection)
+		}
+	}
+}
+
+// TestSpecSchemasMatchGoTypes compares the properties of each schema with
+// the JSON fields of the type the handlers encode or decode for it.
+func TestSpecSchemasMatchGoTypes(t *testing.T) {
+	spec := loadSpecForTest(t)
+	types := map[string]any{
+		"InfoResponse":                     InfoResponse{},
+		"Invoice":                          invoice.Invoice{},
+		"Dunning":                          invoice.Dunning{},
+		"Attachment":                       Attachment{},
+		"AttachmentList":                   AttachmentList{},
+		"StatusTotals":                     StatusTotals{},
+		"StatusBreakdown":                  StatusBreakdown{},
+		"CurrencySummary":                  CurrencySummary{},
+		"InvoiceSummary":                   InvoiceSummary{},
+		"InvoiceLineItem":                  invoice.LineItem{},
+		"InvoiceCreateRequest":             InvoiceCreateRequest{},
+		"InvoiceUpdateRequest":             InvoiceUpdateRequest{},
+		"InvoiceLineItemRequest":           InvoiceLineItemRequest{},
+		"InvoiceList":                      InvoiceList{},
+		"InvoiceBatchRequest":              InvoiceBatchRequest{},
+		"InvoiceBatchResult":               InvoiceBatchResult{},
+		"InvoiceBatchResponse":             InvoiceBatchResponse{},
+		"InvoiceImportResult":              InvoiceImportResult{},
+		"InvoiceImportResponse":            InvoiceImportResponse{},
+		"InvoiceSearchResult":              invoice.SearchResult{},
+		"InvoiceSearchResults":             InvoiceSearchResults{},
+		"SearchMatch":                      invoice.SearchMatch{},
+		"Payment":                          invoice.Payment{},
+		"PaymentCreateRequest":             PaymentCreateRequest{},
+		"PaymentList":                      PaymentList{},
+		"CreditNote":                       invoice.CreditNote{},
+		"CreditNoteList":                   CreditNoteList{},
+		"PaymentIntent":                    PaymentIntent{},
+		"InvoiceDuplicateRequest":          InvoiceDuplicateRequest{},
+		"InvoiceShareRequest":              InvoiceShareRequest{},
+		"InvoiceShare":                     InvoiceShare{},
+		"PublicInvoice":                    PublicInvoice{},
+		"PublicParty":                      PublicParty{},
+		"PublicLineItem":                   PublicLineItem{},
+		"CreditNoteCreateRequest":          CreditNoteCreateRequest{},
+		"InvoiceVoidRequest":               InvoiceVoidRequest{},
+		"Customer":                         Customer{},
+		"Address":                          Address{},
+		"CustomerCreateRequest":            CustomerCreateRequest{},
+		"CustomerUpdateRequest":            CustomerUpdateRequest{},
+		"CustomerList":                     CustomerList{},
+		"RecurringInvoice":                 RecurringInvoice{},
+		"Recurrence":                       invoice.Recurrence{},
+		"RecurringInvoiceCreateRequest":    RecurringInvoiceCreateRequest{},
+		"RecurringInvoiceList":             RecurringInvoiceList{},
+		"AuditLogEntry":                    AuditLogEntry{},
+		"AuditActor":                       AuditActor{},
+		"FieldChange":                      FieldChange{},
+		"AuditLogList":                     AuditLogList{},
+		"WebhookSubscription":              WebhookSubscription{},
+		"WebhookSubscriptionCreateRequest": WebhookSubscriptionCreateRequest{},
+		"WebhookDelivery":                  WebhookDelivery{},
+		"WebhookDeliveryAttempt":           WebhookDeliveryAttempt{},
+		"ErrorResponse":                    apierror.Error{},
+	}
+	for name, value := range types {
+		schema, ok := spec.Components.Schemas[name]
+		if !ok {
+			t.Errorf("schema %s is not in the spec", name)
+			continue
+		}
+		var documented []string
+		for property := range schema.Properties {
+			documented = append(documented, property)
+		}
+		slices.Sort(documented)
+		if fields := jsonFields(reflect.TypeOf(value)); !slices.Equal(fields, documented) {
+			t.Errorf("schema %s documents %v, %T encodes %v", name, documented, value, fields)
+		}
+	}
+}
+
+func jsonFields(typ reflect.Type) []string {
+	var fields []string
+	for i := range typ.NumField() {
+		field := typ.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" || !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		fields = append(fields, name)
+	}
+	slices.Sort(fields)
+	return fields
+}
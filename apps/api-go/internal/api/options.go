@@ -1,5 +1,20 @@
 package api
 
+import (
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/auth"
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/invoice"
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/notify"
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/payments"
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/pdf"
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/ratelimit"
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/webhook"
+)
+
 type Option func(*Server)
 
 func WithStore(store Store) Option {
@@ -25,3 +40,204 @@ func WithReportRunner(runner ReportRunner) Option {
 		}
 	}
 }
+
+func WithPDFRenderer(renderer pdf.Renderer) Option {
+	return func(server *Server) {
+		if renderer != nil {
+			server.pdfRenderer = renderer
+		}
+	}
+}
+
+func WithIdempotencyTTL(ttl time.Duration) Option {
+	return func(server *Server) {
+		if ttl > 0 {
+			server.idempotencyTTL = ttl
+		}
+	}
+}
+
+func WithLogger(logger *slog.Logger) Option {
+	return func(server *Server) {
+		if logger != nil {
+			server.logger = logger
+		}
+	}
+}
+
+func WithTokenVerifier(verifier *auth.Verifier) Option {
+	return func(server *Server) {
+		if verifier != nil {
+			server.tokenVerifier = verifier
+		}
+	}
+}
+
+func WithWebhookSender(sender webhook.Sender) Option {
+	return func(server *Server) {
+		if sender != nil {
+			server.webhookSender = sender
+		}
+	}
+}
+
+func WithWebhookRetryPolicy(policy webhook.RetryPolicy) Option {
+	return func(server *Server) {
+		if policy.MaxAttempts > 0 {
+			server.webhookRetry = policy
+		}
+	}
+}
+
+func WithRateLimit(perSecond float64, burst int) Option {
+	return func(server *Server) {
+		if perSecond > 0 && burst > 0 {
+			server.rateLimiter = ratelimit.New(perSecond, burst)
+		}
+	}
+}
+
+func WithMaxBodyBytes(limit int64) Option {
+	return func(server *Server) {
+		if limit > 0 {
+			server.maxBodyBytes = limit
+		}
+	}
+}
+
+func WithReadinessChecks(checks ...ReadinessCheck) Option {
+	return func(server *Server) {
+		for _, check := range checks {
+			if check != nil {
+				server.readinessChecks = append(server.readinessChecks, check)
+			}
+		}
+	}
+}
+
+// WithCORSOrigins sets the browser origins allowed to call the API. Without
+// it only same-origin browsers and non-browser clients are served.
+func WithCORSOrigins(origins ...string) Option {
+	return func(server *Server) {
+		server.corsOrigins = make(map[string]bool, len(origins))
+		for _, origin := range origins {
+			server.corsOrigins[origin] = true
+		}
+	}
+}
+
+func WithEmailSender(sender notify.EmailSender) Option {
+	return func(server *Server) {
+		if sender != nil {
+			server.emailSender = sender
+		}
+	}
+}
+
+// WithDunningSchedule sets the reminders sent for overdue invoices, as days
+// past the due date in ascending order.
+func WithDunningSchedule(days ...int) Option {
+	return func(server *Server) {
+		if len(days) > 0 {
+			server.dunningSchedule = slices.Clone(days)
+		}
+	}
+}
+
+// WithInvoiceNumberFormat sets how numbers are allocated to invoices
+// created without one. A format that does not validate is ignored.
+func WithInvoiceNumberFormat(format invoice.NumberFormat) Option {
+	return func(server *Server) {
+		if format.Validate() == nil {
+			server.invoiceNumbers = format
+		}
+	}
+}
+
+// WithObjectStore sets where attachment blobs are kept. It defaults to an
+// in-memory store.
+func WithObjectStore(store ObjectStore) Option {
+	return func(server *Server) {
+		if store != nil {
+			server.objectStore = store
+		}
+	}
+}
+
+// WithAttachmentLimits sets the largest attachment accepted, in bytes, and
+// the media types it may have. Zero or no types keep the defaults.
+func WithAttachmentLimits(maxBytes int64, contentTypes ...string) Option {
+	return func(server *Server) {
+		if maxBytes > 0 {
+			server.maxAttachmentBytes = maxBytes
+		}
+		if len(contentTypes) > 0 {
+			server.attachmentTypes = contentTypeSet(contentTypes)
+		}
+	}
+}
+
+// WithSummaryCacheTTL sets how long invoice summaries are served from cache.
+func WithSummaryCacheTTL(ttl time.Duration) Option {
+	return func(server *Server) {
+		if ttl > 0 {
+			server.summaryCache = newSummaryCache(ttl)
+		}
+	}
+}
+
+// WithRequestTimeout sets the deadline put on each request's context. Zero
+// or less leaves requests without one.
+func WithRequestTimeout(timeout time.Duration) Option {
+	return func(server *Server) {
+		server.requestTimeout = timeout
+	}
+}
+
+// WithPoolStats reports the database pool from stats in /readyz and as
+// invoicer_db_pool_* metrics.
+func WithPoolStats(stats func() PoolStats) Option {
+	return func(server *Server) {
+		if stats != nil {
+			server.poolStats = stats
+			server.metrics.registry.MustRegister(newPoolCollector(stats))
+		}
+	}
+}
+
+// WithPaymentProvider sets the provider invoices are paid through. Without
+// one, paying an invoice answers 501 and no payment webhooks are served.
+func WithPaymentProvider(provider payments.Provider) Option {
+	return func(server *Server) {
+		if provider != nil {
+			server.paymentProvider = provider
+		}
+	}
+}
+
+// WithShareLinks lets invoices be shared through public links signed with
+// secret. The links are prefixed with publicBaseURL, where the API is
+// reached from outside, or left relative when it is empty. Without a
+// secret, sharing answers 501.
+func WithShareLinks(secret []byte, publicBaseURL string) Option {
+	return func(server *Server) {
+		if len(secret) > 0 {
+			server.shareLinkSecret = secret
+			server.publicBaseURL = strings.TrimRight(publicBaseURL, "/")
+		}
+	}
+}
+
+// WithRetention sets how long soft-deleted invoices and customers, and
+// audit entries, are kept before PurgeDeletedRecords removes them.
+// Non-positive values keep the defaults.
+func WithRetention(deleted, audit time.Duration) Option {
+	return func(server *Server) {
+		if deleted > 0 {
+			server.deletedRetention = deleted
+		}
+		if audit > 0 {
+			server.auditRetention = audit
+		}
+	}
+}
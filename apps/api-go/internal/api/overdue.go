@@ -0,0 +1,62 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+const (
+	overdueBatchSize           = 100
+	defaultOverdueScanInterval = time.Hour
+)
+
+// MarkOverdueInvoices moves every open invoice whose due date has passed to
+// overdue, records an audit event and publishes invoice.overdue for each one,
+// and reports how many it moved. The store hands each invoice to exactly one
+// caller, so replicas can run the scan side by side.
+func (s *Server) MarkOverdueInvoices(ctx context.Context) (int, error) {
+	marked := 0
+	for {
+		items, err := s.store.MarkInvoicesOverdue(ctx, utcNow(), overdueBatchSize)
+		if err != nil {
+			return marked, err
+		}
+		for _, item := range items {
+			if err := s.recordAudit(ctx, item.TenantID, "", "invoice.overdue", "invoice", item.ID, fmt.Sprintf("Invoice %s is past due", item.Number)); err != nil {
+				return marked, err
+			}
+			if err := s.publishWebhookEvent(ctx, item.TenantID, EventInvoiceOverdue, item); err != nil {
+				return marked, err
+			}
+			marked++
+		}
+		if len(items) < overdueBatchSize {
+			return marked, nil
+		}
+	}
+}
+
+// RunOverdueScheduler calls MarkOverdueInvoices at startup and then every
+// interval until ctx is done.
+func (s *Server) RunOverdueScheduler(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = defaultOverdueScanInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		marked, err := s.MarkOverdueInvoices(ctx)
+		if err != nil && ctx.Err() == nil {
+			s.logger.ErrorContext(ctx, "mark overdue invoices", "error", err)
+		}
+		if marked > 0 {
+			s.logger.InfoContext(ctx, "marked invoices overdue", "count", marked)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/invoice"
+)
+
+func TestMarkOverdueInvoicesMovesPastDueOpenInvoices(t *testing.T) {
+	server := NewServer()
+	cookie := loginForTest(t, server)
+	hook := createWebhookForTest(t, server, cookie, WebhookSubscriptionCreateRequest{URL: "https://hooks.example.com/overdue", Events: []string{EventInvoiceOverdue}})
+
+	yesterday := time.Now().UTC().Add(-24 * time.Hour).Format(time.RFC3339)
+	tomorrow := time.Now().UTC().Add(24 * time.Hour).Format(time.RFC3339)
+	pastDue := createInvoiceForTest(t, server, cookie, InvoiceCreateRequest{Number: "INV-0001", CustomerID: "cust-1", Currency: "ILS", Status: invoice.StatusOpen, DueAt: yesterday})
+	notDue := createInvoiceForTest(t, server, cookie, InvoiceCreateRequest{Number: "INV-0002", CustomerID: "cust-1", Currency: "ILS", Status: invoice.StatusOpen, DueAt: tomorrow})
+	draft := createInvoiceForTest(t, server, cookie, InvoiceCreateRequest{Number: "INV-0003", CustomerID: "cust-1", Currency: "ILS", DueAt: yesterday})
+
+	if marked, err := server.MarkOverdueInvoices(context.Background()); err != nil || marked != 1 {
+		t.Fatalf("expected one invoice marked, got %d, %v", marked, err)
+	}
+	if marked, err := server.MarkOverdueInvoices(context.Background()); err != nil || marked != 0 {
+		t.Fatalf("expected a second scan to find nothing, got %d, %v", marked, err)
+	}
+
+	for id, want := range map[string]invoice.Status{pastDue.ID: invoice.StatusOverdue, notDue.ID: invoice.StatusOpen, draft.ID: invoice.StatusDraft} {
+		rec := doJSON(t, server, cookie, http.MethodGet, "/v1/invoices/"+id, nil)
+		var got invoice.Invoice
+		if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+			t.Fatalf("decode invoice: %v", err)
+		}
+		if got.Status != want {
+			t.Fatalf("expected %s to be %s, got %s", id, want, got.Status)
+		}
+	}
+
+	deliveries := listDeliveriesForTest(t, server, cookie, hook.ID)
+	if len(deliveries) != 1 || deliveries[0].Event != EventInvoiceOverdue {
+		t.Fatalf("expected a single invoice.overdue delivery, got %#v", deliveries)
+	}
+	events, err := server.store.ListAuditEvents(context.Background(), "tenant-alpha", "invoice", pastDue.ID)
+	if err != nil {
+		t.Fatalf("list audit events: %v", err)
+	}
+	found := false
+	for _, event := range events {
+		found = found || event.Action == "invoice.overdue"
+	}
+	if !found {
+		t.Fatalf("expected an invoice.overdue audit event, got %#v", events)
+	}
+}
+
+func TestRunOverdueSchedulerStopsWithContext(t *testing.T) {
+	server := NewServer()
+	cookie := loginForTest(t, server)
+	created := createInvoiceForTest(t, server, cookie, InvoiceCreateRequest{
+		CustomerID: "cust-1",
+		Currency:   "ILS",
+		Status:     invoice.StatusOpen,
+		DueAt:      time.Now().UTC().Add(-time.Hour).Format(time.RFC3339),
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		server.RunOverdueScheduler(ctx, time.Hour)
+		close(done)
+	}()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		item, err := server.store.GetInvoice(context.Background(), "tenant-alpha", created.ID)
+		if err != nil {
+			t.Fatalf("get invoice: %v", err)
+		}
+		if item.Status == invoice.StatusOverdue {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("expected the scheduler to scan once at startup")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected the scheduler to stop after cancellation")
+	}
+}
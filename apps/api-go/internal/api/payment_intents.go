@@ -0,0 +1,181 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/apierror"
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/invoice"
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/payments"
+)
+
+const paymentWebhooksPath = "/v1/payments/webhooks/"
+
+var (
+	errPaymentsNotConfigured = apierror.New(apierror.CodePaymentsNotConfigured, "no payment provider is configured")
+	errPaymentProviderFailed = apierror.New(apierror.CodePaymentProviderFailed, "the payment provider could not be reached; try again later")
+)
+
+// PaymentIntent is a payment started with the payment provider for the
+// amount due. The payer's browser completes it with ClientSecret; the
+// payment is recorded when the provider's webhook reports it succeeded.
+type PaymentIntent struct {
+	Provider     string `json:"provider"`
+	ID           string `json:"id"`
+	ClientSecret string `json:"clientSecret"`
+	Amount       int64  `json:"amount"`
+	Currency     string `json:"currency"`
+	Status       string `json:"status"`
+}
+
+// handleInvoicePay serves POST /v1/invoices/{id}/pay.
+func (s *Server) handleInvoicePay(w http.ResponseWriter, r *http.Request, session Session, item invoice.Invoice) {
+	if r.Method != http.MethodPost {
+		apierror.WriteError(w, http.StatusMethodNotAllowed, errMethodNotAllowed)
+		return
+	}
+	if s.paymentProvider == nil {
+		apierror.WriteError(w, http.StatusNotImplemented, errPaymentsNotConfigured)
+		return
+	}
+	if (item.Status != invoice.StatusOpen && item.Status != invoice.StatusOverdue) || item.AmountDue() == 0 {
+		apierror.WriteError(w, http.StatusConflict, apierror.New(apierror.CodeInvoiceNotPayable, "only open or overdue invoices with an amount due can be paid"))
+		return
+	}
+	s.withIdempotency(w, r, session, func(w http.ResponseWriter) {
+		// Keyed on the invoice version, so paying twice before anything
+		// changes hands out the same intent rather than a second one.
+		intent, err := s.paymentProvider.CreatePaymentIntent(r.Context(), payments.IntentRequest{
+			TenantID:       session.TenantID,
+			InvoiceID:      item.ID,
+			InvoiceNumber:  item.Number,
+			Amount:         item.AmountDue(),
+			Currency:       item.Currency,
+			IdempotencyKey: fmt.Sprintf("%s/%s/%d", session.TenantID, item.ID, item.Version),
+		})
+		if err != nil {
+			s.logger.ErrorContext(r.Context(), "create payment intent", "invoice_id", item.ID, "error", err)
+			apierror.WriteError(w, http.StatusBadGateway, errPaymentProviderFailed)
+			return
+		}
+		writeJSON(w, http.StatusCreated, PaymentIntent{
+			Provider:     s.paymentProvider.Name(),
+			ID:           intent.ID,
+			ClientSecret: intent.ClientSecret,
+			Amount:       intent.Amount,
+			Currency:     intent.Currency,
+			Status:       intent.Status,
+		})
+	})
+}
+
+// handlePaymentWebhook serves POST /v1/payments/webhooks/{provider}. It
+// records the payment of a succeeded intent against the invoice it was
+// created for. Providers redeliver until they get a 2xx, so every event
+// that can never be recorded is acknowledged, and only failures worth
+// retrying, such as an unreachable provider or database, answer 5xx. The
+// payment ID is derived from the intent, so a redelivered event finds its
+// payment recorded and is acknowledged without counting it twice.
+func (s *Server) handlePaymentWebhook(w http.ResponseWriter, r *http.Request) {
+	provider := s.paymentProvider
+	if provider == nil || strings.TrimPrefix(r.URL.Path, paymentWebhooksPath) != provider.Name() {
+		apierror.WriteError(w, http.StatusNotFound, apierror.NotFound("payment provider"))
+		return
+	}
+	if r.Method != http.MethodPost {
+		apierror.WriteError(w, http.StatusMethodNotAllowed, errMethodNotAllowed)
+		return
+	}
+	payload, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeBodyError(w, jsonBodyError(err))
+		return
+	}
+	event, err := provider.HandleWebhook(r.Context(), payload, r.Header)
+	switch {
+	case errors.Is(err, payments.ErrInvalidSignature):
+		apierror.WriteError(w, http.StatusBadRequest, apierror.New(apierror.CodeInvalidSignature, err.Error()))
+		return
+	case err != nil:
+		apierror.WriteError(w, http.StatusBadRequest, apierror.New(apierror.CodeInvalidRequest, err.Error()))
+		return
+	case event.Type != payments.EventPaymentSucceeded:
+		writeJSON(w, http.StatusOK, MessageResponse{Message: "event ignored"})
+		return
+	case event.Intent.ID == "":
+		apierror.WriteError(w, http.StatusBadRequest, apierror.New(apierror.CodeInvalidRequest, "the event names no payment intent"))
+		return
+	}
+
+	intent, err := provider.ConfirmPayment(r.Context(), event.Intent.ID)
+	if err != nil {
+		s.logger.ErrorContext(r.Context(), "confirm payment", "provider", provider.Name(), "intent_id", event.Intent.ID, "error", err)
+		apierror.WriteError(w, http.StatusBadGateway, errPaymentProviderFailed)
+		return
+	}
+	ignore := func(reason string) {
+		s.logger.WarnContext(r.Context(), "payment not recorded", "provider", provider.Name(), "event_id", event.ID, "intent_id", intent.ID, "invoice_id", intent.InvoiceID, "reason", reason)
+		writeJSON(w, http.StatusOK, MessageResponse{Message: "payment not recorded: " + reason})
+	}
+	if !intent.Succeeded() {
+		ignore("the provider reports the intent as " + intent.Status)
+		return
+	}
+	if intent.TenantID == "" || intent.InvoiceID == "" {
+		ignore("the intent was not created for an invoice")
+		return
+	}
+	item, err := s.store.GetInvoice(r.Context(), intent.TenantID, intent.InvoiceID)
+	switch {
+	case errors.Is(err, ErrNotFound) || (err == nil && item.DeletedAt != ""):
+		ignore("the invoice does not exist")
+		return
+	case err != nil:
+		s.writeInternalError(w, err)
+		return
+	case item.Currency != intent.Currency:
+		ignore(fmt.Sprintf("the intent is in %s but the invoice in %s", intent.Currency, item.Currency))
+		return
+	}
+
+	now := utcNow()
+	payment := invoice.Payment{
+		ID:         "pay-" + provider.Name() + "-" + intent.ID,
+		InvoiceID:  item.ID,
+		Amount:     intent.AmountReceived,
+		Method:     invoice.PaymentCard,
+		ReceivedAt: now,
+		Reference:  intent.ID,
+		CreatedAt:  now,
+	}
+	if err := payment.Validate(); err != nil {
+		ignore(err.Error())
+		return
+	}
+	// The money has been received, so it is recorded even when other
+	// payments have covered the invoice since the intent was created; a
+	// paid invoice takes it as an overpayment.
+	updated, err := s.store.RecordInvoicePayment(r.Context(), intent.TenantID, payment, true, now)
+	switch {
+	case errors.Is(err, ErrConflict):
+		writeJSON(w, http.StatusOK, MessageResponse{Message: "payment already recorded"})
+		return
+	case errors.Is(err, invoice.ErrNotPayable):
+		ignore("the invoice is no longer open, overdue or paid")
+		return
+	case errors.Is(err, ErrNotFound):
+		ignore("the invoice does not exist")
+		return
+	case err != nil:
+		s.writeInternalError(w, err)
+		return
+	}
+	if err := s.paymentRecorded(r.Context(), intent.TenantID, routeCtx(r).RequestID, item.Status, payment, updated); err != nil {
+		s.writeInternalError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, MessageResponse{Message: "payment recorded"})
+}
@@ -0,0 +1,244 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/apierror"
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/invoice"
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/payments"
+)
+
+func TestPayInvoiceCreatesPaymentIntentForAmountDue(t *testing.T) {
+	unconfigured := NewServer()
+	cookie := loginForTest(t, unconfigured)
+	created := createInvoiceForTest(t, unconfigured, cookie, InvoiceCreateRequest{CustomerID: "cust-1", Currency: "ILS", Subtotal: 1000, Status: invoice.StatusOpen})
+	rec := doJSON(t, unconfigured, cookie, http.MethodPost, "/v1/invoices/"+created.ID+"/pay", nil)
+	if code := decodeErrorCodeForTest(t, rec); rec.Code != http.StatusNotImplemented || code != apierror.CodePaymentsNotConfigured {
+		t.Fatalf("expected 501 %s without a provider, got %d %s", apierror.CodePaymentsNotConfigured, rec.Code, code)
+	}
+
+	provider := newFakePaymentProvider()
+	server := NewServer(WithPaymentProvider(provider))
+	cookie = loginForTest(t, server)
+	draft := createInvoiceForTest(t, server, cookie, InvoiceCreateRequest{CustomerID: "cust-1", Currency: "ILS", Subtotal: 1000})
+	rec = doJSON(t, server, cookie, http.MethodPost, "/v1/invoices/"+draft.ID+"/pay", nil)
+	if code := decodeErrorCodeForTest(t, rec); rec.Code != http.StatusConflict || code != apierror.CodeInvoiceNotPayable {
+		t.Fatalf("expected a draft to be 409 %s, got %d %s", apierror.CodeInvoiceNotPayable, rec.Code, code)
+	}
+
+	open := createInvoiceForTest(t, server, cookie, InvoiceCreateRequest{CustomerID: "cust-1", Currency: "ILS", Subtotal: 1000, Status: invoice.StatusOpen})
+	recordPaymentForTest(t, server, cookie, open.ID, PaymentCreateRequest{Amount: 400, Method: invoice.PaymentCash})
+	intent := payInvoiceForTest(t, server, cookie, open.ID)
+	want := PaymentIntent{Provider: "fake", ID: "pi_1", ClientSecret: "pi_1_secret", Amount: 600, Currency: "ILS", Status: "requires_payment_method"}
+	if intent != want {
+		t.Fatalf("expected %#v, got %#v", want, intent)
+	}
+	if got := provider.requests[0]; got.TenantID != "tenant-alpha" || got.InvoiceID != open.ID || got.InvoiceNumber != open.Number || got.IdempotencyKey == "" {
+		t.Fatalf("unexpected intent request %#v", got)
+	}
+
+	provider.createErr = errors.New("connection refused")
+	rec = doJSON(t, server, cookie, http.MethodPost, "/v1/invoices/"+open.ID+"/pay", nil)
+	if code := decodeErrorCodeForTest(t, rec); rec.Code != http.StatusBadGateway || code != apierror.CodePaymentProviderFailed {
+		t.Fatalf("expected 502 %s, got %d %s", apierror.CodePaymentProviderFailed, rec.Code, code)
+	}
+}
+
+func TestPaymentWebhookRecordsSucceededPaymentOnce(t *testing.T) {
+	provider := newFakePaymentProvider()
+	server := NewServer(WithPaymentProvider(provider))
+	cookie := loginForTest(t, server)
+	created := createInvoiceForTest(t, server, cookie, InvoiceCreateRequest{CustomerID: "cust-1", Currency: "ILS", Subtotal: 1000, Status: invoice.StatusOpen})
+	intent := payInvoiceForTest(t, server, cookie, created.ID)
+
+	rec := postPaymentWebhookForTest(server, "/v1/payments/webhooks/fake", "forged", intent.ID)
+	if code := decodeErrorCodeForTest(t, rec); rec.Code != http.StatusBadRequest || code != apierror.CodeInvalidSignature {
+		t.Fatalf("expected an unsigned event 400 %s, got %d %s", apierror.CodeInvalidSignature, rec.Code, code)
+	}
+	if rec := postPaymentWebhookForTest(server, "/v1/payments/webhooks/other", fakeSignature, intent.ID); rec.Code != http.StatusNotFound {
+		t.Fatalf("expected another provider's webhook 404, got %d", rec.Code)
+	}
+	// The event is only trusted once the provider confirms the intent.
+	if rec := postPaymentWebhookForTest(server, "/v1/payments/webhooks/fake", fakeSignature, intent.ID); rec.Code != http.StatusOK {
+		t.Fatalf("expected an unconfirmed event to be acknowledged, got %d", rec.Code)
+	}
+	if item := getInvoiceForTest(t, server, cookie, created.ID); item.AmountPaid != 0 {
+		t.Fatalf("expected no payment before the intent succeeded, got %#v", item)
+	}
+
+	provider.succeed(intent.ID)
+	// Providers retry deliveries, sometimes overlapping; each is counted once.
+	var wg sync.WaitGroup
+	codes := make([]int, 5)
+	for i := range codes {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			codes[i] = postPaymentWebhookForTest(server, "/v1/payments/webhooks/fake", fakeSignature, intent.ID).Code
+		}()
+	}
+	wg.Wait()
+	for _, code := range codes {
+		if code != http.StatusOK {
+			t.Fatalf("expected every delivery acknowledged, got %v", codes)
+		}
+	}
+
+	item := getInvoiceForTest(t, server, cookie, created.ID)
+	if item.Status != invoice.StatusPaid || item.AmountPaid != 1000 || item.PaymentReference != intent.ID {
+		t.Fatalf("expected the invoice paid by the intent, got %#v", item)
+	}
+	rec = doJSON(t, server, cookie, http.MethodGet, "/v1/invoices/"+created.ID+"/payments", nil)
+	var list PaymentList
+	if err := json.NewDecoder(rec.Body).Decode(&list); err != nil {
+		t.Fatalf("decode payments: %v", err)
+	}
+	if len(list.Items) != 1 || list.Items[0].Amount != 1000 || list.Items[0].Method != invoice.PaymentCard || list.Items[0].Reference != intent.ID {
+		t.Fatalf("expected one card payment, got %#v", list.Items)
+	}
+}
+
+func TestPaymentWebhookRecordsASecondIntentAsOverpayment(t *testing.T) {
+	provider := newFakePaymentProvider()
+	server := NewServer(WithPaymentProvider(provider))
+	cookie := loginForTest(t, server)
+	created := createInvoiceForTest(t, server, cookie, InvoiceCreateRequest{CustomerID: "cust-1", Currency: "ILS", Subtotal: 1000, Status: invoice.StatusOpen})
+	// The payer opened two checkouts and completed both.
+	first := payInvoiceForTest(t, server, cookie, created.ID)
+	second := payInvoiceForTest(t, server, cookie, created.ID)
+	for _, intent := range []PaymentIntent{first, second} {
+		provider.succeed(intent.ID)
+		if rec := postPaymentWebhookForTest(server, "/v1/payments/webhooks/fake", fakeSignature, intent.ID); rec.Code != http.StatusOK {
+			t.Fatalf("expected %s acknowledged, got %d", intent.ID, rec.Code)
+		}
+	}
+
+	item := getInvoiceForTest(t, server, cookie, created.ID)
+	if item.Status != invoice.StatusPaid || item.AmountPaid != 2000 || item.PaymentReference != first.ID {
+		t.Fatalf("expected the invoice paid by the first intent and overpaid by the second, got %#v", item)
+	}
+	rec := doJSON(t, server, cookie, http.MethodGet, "/v1/invoices/"+created.ID+"/payments", nil)
+	var list PaymentList
+	if err := json.NewDecoder(rec.Body).Decode(&list); err != nil {
+		t.Fatalf("decode payments: %v", err)
+	}
+	if len(list.Items) != 2 || list.Items[0].Reference != first.ID || list.Items[1].Reference != second.ID {
+		t.Fatalf("expected both card payments, got %#v", list.Items)
+	}
+}
+
+func TestPaymentWebhookAsksForRetryWhenProviderIsUnreachable(t *testing.T) {
+	provider := newFakePaymentProvider()
+	server := NewServer(WithPaymentProvider(provider))
+	cookie := loginForTest(t, server)
+	created := createInvoiceForTest(t, server, cookie, InvoiceCreateRequest{CustomerID: "cust-1", Currency: "ILS", Subtotal: 1000, Status: invoice.StatusOpen})
+	intent := payInvoiceForTest(t, server, cookie, created.ID)
+	provider.succeed(intent.ID)
+
+	provider.confirmErr = errors.New("timeout")
+	if rec := postPaymentWebhookForTest(server, "/v1/payments/webhooks/fake", fakeSignature, intent.ID); rec.Code != http.StatusBadGateway {
+		t.Fatalf("expected 502 so the provider retries, got %d", rec.Code)
+	}
+	provider.confirmErr = nil
+	if rec := postPaymentWebhookForTest(server, "/v1/payments/webhooks/fake", fakeSignature, intent.ID); rec.Code != http.StatusOK {
+		t.Fatalf("expected the retry to be recorded, got %d", rec.Code)
+	}
+	if item := getInvoiceForTest(t, server, cookie, created.ID); item.Status != invoice.StatusPaid {
+		t.Fatalf("expected the invoice paid, got %s", item.Status)
+	}
+}
+
+const fakeSignature = "signed"
+
+// fakePaymentProvider keeps intents in memory. Webhooks are signed when
+// their X-Fake-Signature header is fakeSignature, and their body is just
+// the ID of the intent that succeeded.
+type fakePaymentProvider struct {
+	mu         sync.Mutex
+	intents    map[string]payments.Intent
+	requests   []payments.IntentRequest
+	createErr  error
+	confirmErr error
+}
+
+func newFakePaymentProvider() *fakePaymentProvider {
+	return &fakePaymentProvider{intents: map[string]payments.Intent{}}
+}
+
+func (f *fakePaymentProvider) Name() string {
+	return "fake"
+}
+
+func (f *fakePaymentProvider) CreatePaymentIntent(_ context.Context, req payments.IntentRequest) (payments.Intent, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.createErr != nil {
+		return payments.Intent{}, f.createErr
+	}
+	f.requests = append(f.requests, req)
+	id := fmt.Sprintf("pi_%d", len(f.intents)+1)
+	intent := payments.Intent{ID: id, ClientSecret: id + "_secret", Status: "requires_payment_method", Amount: req.Amount, Currency: req.Currency, TenantID: req.TenantID, InvoiceID: req.InvoiceID}
+	f.intents[id] = intent
+	return intent, nil
+}
+
+func (f *fakePaymentProvider) ConfirmPayment(_ context.Context, intentID string) (payments.Intent, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.confirmErr != nil {
+		return payments.Intent{}, f.confirmErr
+	}
+	intent, ok := f.intents[intentID]
+	if !ok {
+		return payments.Intent{}, fmt.Errorf("no intent %s", intentID)
+	}
+	return intent, nil
+}
+
+func (f *fakePaymentProvider) HandleWebhook(_ context.Context, payload []byte, header http.Header) (payments.Event, error) {
+	if header.Get("X-Fake-Signature") != fakeSignature {
+		return payments.Event{}, payments.ErrInvalidSignature
+	}
+	intentID := string(payload)
+	return payments.Event{ID: "evt-" + intentID, Type: payments.EventPaymentSucceeded, Intent: payments.Intent{ID: intentID}}, nil
+}
+
+func (f *fakePaymentProvider) succeed(intentID string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	intent := f.intents[intentID]
+	intent.Status = payments.IntentSucceeded
+	intent.AmountReceived = intent.Amount
+	f.intents[intentID] = intent
+}
+
+func payInvoiceForTest(t *testing.T, server *Server, cookie *http.Cookie, invoiceID string) PaymentIntent {
+	t.Helper()
+
+	rec := doJSON(t, server, cookie, http.MethodPost, "/v1/invoices/"+invoiceID+"/pay", nil)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected pay 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var intent PaymentIntent
+	if err := json.NewDecoder(rec.Body).Decode(&intent); err != nil {
+		t.Fatalf("decode payment intent: %v", err)
+	}
+	return intent
+}
+
+func postPaymentWebhookForTest(server *Server, path, signature, intentID string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, path, bytes.NewReader([]byte(intentID)))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Fake-Signature", signature)
+	rec := httptest.NewRecorder()
+	server.Handler().ServeHTTP(rec, req)
+	return rec
+}
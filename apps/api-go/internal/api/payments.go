@@ -0,0 +1,122 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/apierror"
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/invoice"
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/money"
+)
+
+// PaymentCreateRequest records money received against an invoice.
+// ReceivedAt defaults to now. A payment that takes the amount paid above the
+// total is refused unless AllowOverpayment is set.
+type PaymentCreateRequest struct {
+	Amount           int64  `json:"amount"`
+	Method           string `json:"method"`
+	ReceivedAt       string `json:"receivedAt"`
+	Reference        string `json:"reference"`
+	AllowOverpayment bool   `json:"allowOverpayment"`
+}
+
+type PaymentList struct {
+	Items []invoice.Payment `json:"items"`
+}
+
+// PaymentReceivedEvent is the data of an invoice.payment_received webhook:
+// the payment and the invoice as it stands after it.
+type PaymentReceivedEvent struct {
+	Payment invoice.Payment `json:"payment"`
+	Invoice invoice.Invoice `json:"invoice"`
+}
+
+// handleInvoicePayments serves GET and POST /v1/invoices/{id}/payments.
+func (s *Server) handleInvoicePayments(w http.ResponseWriter, r *http.Request, session Session, item invoice.Invoice) {
+	switch r.Method {
+	case http.MethodGet:
+		items, err := s.store.ListInvoicePayments(r.Context(), session.TenantID, item.ID)
+		if err != nil {
+			s.writeInternalError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, PaymentList{Items: items})
+	case http.MethodPost:
+		s.withIdempotency(w, r, session, func(w http.ResponseWriter) {
+			s.recordPayment(w, r, session, item)
+		})
+	default:
+		apierror.WriteError(w, http.StatusMethodNotAllowed, errMethodNotAllowed)
+	}
+}
+
+func (s *Server) recordPayment(w http.ResponseWriter, r *http.Request, session Session, item invoice.Invoice) {
+	var req PaymentCreateRequest
+	if err := decodeJSON(r, &req); err != nil {
+		writeBodyError(w, err)
+		return
+	}
+	now := utcNow()
+	payment := invoice.Payment{
+		ID:         s.newID("pay"),
+		InvoiceID:  item.ID,
+		Amount:     req.Amount,
+		Method:     strings.TrimSpace(req.Method),
+		ReceivedAt: strings.TrimSpace(req.ReceivedAt),
+		Reference:  strings.TrimSpace(req.Reference),
+		CreatedAt:  now,
+	}
+	if payment.ReceivedAt == "" {
+		payment.ReceivedAt = now
+	}
+	if err := payment.Validate(); err != nil {
+		writeValidationError(w, err)
+		return
+	}
+
+	updated, err := s.store.RecordInvoicePayment(r.Context(), session.TenantID, payment, req.AllowOverpayment, now)
+	switch {
+	case errors.Is(err, invoice.ErrNotPayable):
+		apierror.WriteError(w, http.StatusConflict, apierror.New(apierror.CodeInvoiceNotPayable, "payments can only be recorded against open or overdue invoices, or paid ones with allowOverpayment"))
+		return
+	case errors.Is(err, invoice.ErrOverpayment):
+		// Reload so the amount due reflects payments recorded since item was read.
+		if current, err := s.store.GetInvoice(r.Context(), session.TenantID, item.ID); err == nil {
+			item = current
+		}
+		apierror.WriteError(w, http.StatusUnprocessableEntity, &apierror.Error{
+			Code:    apierror.CodeOverpayment,
+			Message: "payment exceeds the amount due; set allowOverpayment to record it anyway",
+			Details: map[string]any{"amountDue": item.AmountDue()},
+		})
+		return
+	case err != nil:
+		s.writeLookupError(w, err, "invoice")
+		return
+	}
+
+	if err := s.paymentRecorded(r.Context(), session.TenantID, routeCtx(r).RequestID, item.Status, payment, updated); err != nil {
+		s.writeInternalError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, payment)
+}
+
+// paymentRecorded audits a recorded payment and publishes its webhook
+// events, including the status change when the payment settled the invoice.
+func (s *Server) paymentRecorded(ctx context.Context, tenantID, requestID string, previousStatus invoice.Status, payment invoice.Payment, updated invoice.Invoice) error {
+	amount := money.Money{Amount: payment.Amount, Currency: updated.Currency}.Format()
+	if err := s.recordAudit(ctx, tenantID, requestID, "invoice.payment_received", "invoice", updated.ID, fmt.Sprintf("Recorded payment of %s", amount)); err != nil {
+		return err
+	}
+	if err := s.publishWebhookEvent(ctx, tenantID, EventInvoicePaymentReceived, PaymentReceivedEvent{Payment: payment, Invoice: updated}); err != nil {
+		return err
+	}
+	if event := invoiceStatusEvent(previousStatus, updated.Status); event != "" {
+		return s.publishWebhookEvent(ctx, tenantID, event, updated)
+	}
+	return nil
+}
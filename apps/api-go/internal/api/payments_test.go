@@ -0,0 +1,198 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/apierror"
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/invoice"
+)
+
+func TestInvoicePaymentsSettleInvoiceWhenTotalIsCovered(t *testing.T) {
+	server := NewServer()
+	cookie := loginForTest(t, server)
+	created := createInvoiceForTest(t, server, cookie, InvoiceCreateRequest{Number: "INV-0001", CustomerID: "cust-1", Currency: "ILS", Subtotal: 1000, Status: invoice.StatusOpen})
+
+	first := recordPaymentForTest(t, server, cookie, created.ID, PaymentCreateRequest{Amount: 400, Method: invoice.PaymentBankTransfer, ReceivedAt: "2026-03-01T10:00:00Z", Reference: "wire-1"})
+	if first.ID == "" || first.InvoiceID != created.ID || first.Amount != 400 {
+		t.Fatalf("unexpected payment %#v", first)
+	}
+	partial := getInvoiceForTest(t, server, cookie, created.ID)
+	if partial.Status != invoice.StatusOpen || partial.AmountPaid != 400 {
+		t.Fatalf("expected open invoice with 400 paid, got %s %d", partial.Status, partial.AmountPaid)
+	}
+
+	recordPaymentForTest(t, server, cookie, created.ID, PaymentCreateRequest{Amount: 600, Method: invoice.PaymentCard, ReceivedAt: "2026-03-04T10:00:00Z", Reference: "ch_123"})
+	paid := getInvoiceForTest(t, server, cookie, created.ID)
+	if paid.Status != invoice.StatusPaid || paid.AmountPaid != 1000 || paid.PaidAt != "2026-03-04T10:00:00Z" || paid.PaymentReference != "ch_123" {
+		t.Fatalf("expected invoice settled by the second payment, got %#v", paid)
+	}
+
+	rec := doJSON(t, server, cookie, http.MethodGet, "/v1/invoices/"+created.ID+"/payments", nil)
+	var list PaymentList
+	if err := json.NewDecoder(rec.Body).Decode(&list); err != nil {
+		t.Fatalf("decode payments: %v", err)
+	}
+	if rec.Code != http.StatusOK || len(list.Items) != 2 || list.Items[0].ID != first.ID {
+		t.Fatalf("expected both payments in order, got %d %#v", rec.Code, list.Items)
+	}
+
+	rec = doJSON(t, server, cookie, http.MethodPost, "/v1/invoices/"+created.ID+"/payments", PaymentCreateRequest{Amount: 1, Method: invoice.PaymentCash})
+	if code := decodeErrorCodeForTest(t, rec); rec.Code != http.StatusConflict || code != apierror.CodeInvoiceNotPayable {
+		t.Fatalf("expected paid invoice 409 %s, got %d %s", apierror.CodeInvoiceNotPayable, rec.Code, code)
+	}
+	if rec := doJSON(t, server, cookie, http.MethodDelete, "/v1/invoices/"+created.ID, nil); rec.Code != http.StatusConflict {
+		t.Fatalf("expected paid delete 409, got %d", rec.Code)
+	}
+}
+
+func TestInvoicePaymentsRejectOverpaymentUnlessAllowed(t *testing.T) {
+	server := NewServer()
+	cookie := loginForTest(t, server)
+	created := createInvoiceForTest(t, server, cookie, InvoiceCreateRequest{Number: "INV-0001", CustomerID: "cust-1", Currency: "ILS", Subtotal: 1000, Status: invoice.StatusOpen})
+	recordPaymentForTest(t, server, cookie, created.ID, PaymentCreateRequest{Amount: 700, Method: invoice.PaymentCard})
+
+	rec := doJSON(t, server, cookie, http.MethodPost, "/v1/invoices/"+created.ID+"/payments", PaymentCreateRequest{Amount: 500, Method: invoice.PaymentCard})
+	var body apierror.Error
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatalf("decode error: %v", err)
+	}
+	if rec.Code != http.StatusUnprocessableEntity || body.Code != apierror.CodeOverpayment || body.Details["amountDue"] != float64(300) {
+		t.Fatalf("expected 422 overpayment with 300 due, got %d %#v", rec.Code, body)
+	}
+
+	recordPaymentForTest(t, server, cookie, created.ID, PaymentCreateRequest{Amount: 500, Method: invoice.PaymentCard, AllowOverpayment: true})
+	if paid := getInvoiceForTest(t, server, cookie, created.ID); paid.Status != invoice.StatusPaid || paid.AmountPaid != 1200 {
+		t.Fatalf("expected overpaid invoice to be paid, got %s %d", paid.Status, paid.AmountPaid)
+	}
+}
+
+func TestInvoicePaymentsRejectInvalidInput(t *testing.T) {
+	server := NewServer()
+	cookie := loginForTest(t, server)
+	draft := createInvoiceForTest(t, server, cookie, InvoiceCreateRequest{Number: "INV-0001", CustomerID: "cust-1", Currency: "ILS", Subtotal: 1000})
+	open := createInvoiceForTest(t, server, cookie, InvoiceCreateRequest{Number: "INV-0002", CustomerID: "cust-1", Currency: "ILS", Subtotal: 1000, Status: invoice.StatusOpen})
+
+	for name, req := range map[string]PaymentCreateRequest{
+		"zero amount":    {Amount: 0, Method: invoice.PaymentCard},
+		"unknown method": {Amount: 100, Method: "barter"},
+		"bad time":       {Amount: 100, Method: invoice.PaymentCard, ReceivedAt: "yesterday"},
+	} {
+		if rec := doJSON(t, server, cookie, http.MethodPost, "/v1/invoices/"+open.ID+"/payments", req); rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", name, rec.Code)
+		}
+	}
+	rec := doJSON(t, server, cookie, http.MethodPost, "/v1/invoices/"+draft.ID+"/payments", PaymentCreateRequest{Amount: 100, Method: invoice.PaymentCard})
+	if code := decodeErrorCodeForTest(t, rec); rec.Code != http.StatusConflict || code != apierror.CodeInvoiceNotPayable {
+		t.Fatalf("expected draft 409 %s, got %d %s", apierror.CodeInvoiceNotPayable, rec.Code, code)
+	}
+	if rec := doJSON(t, server, cookie, http.MethodGet, "/v1/invoices/inv-missing/payments", nil); rec.Code != http.StatusNotFound {
+		t.Fatalf("expected unknown invoice 404, got %d", rec.Code)
+	}
+}
+
+func TestConcurrentInvoicePaymentsNeverExceedTotal(t *testing.T) {
+	server := NewServer()
+	cookie := loginForTest(t, server)
+	created := createInvoiceForTest(t, server, cookie, InvoiceCreateRequest{Number: "INV-0001", CustomerID: "cust-1", Currency: "ILS", Subtotal: 1000, Status: invoice.StatusOpen})
+
+	var wg sync.WaitGroup
+	statuses := make([]int, 8)
+	for i := range statuses {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			statuses[i] = doJSON(t, server, cookie, http.MethodPost, "/v1/invoices/"+created.ID+"/payments", PaymentCreateRequest{Amount: 250, Method: invoice.PaymentCard}).Code
+		}()
+	}
+	wg.Wait()
+
+	accepted := 0
+	for _, status := range statuses {
+		switch status {
+		case http.StatusCreated:
+			accepted++
+		case http.StatusUnprocessableEntity, http.StatusConflict:
+		default:
+			t.Fatalf("unexpected status %d", status)
+		}
+	}
+	paid := getInvoiceForTest(t, server, cookie, created.ID)
+	if accepted != 4 || paid.AmountPaid != 1000 || paid.Status != invoice.StatusPaid {
+		t.Fatalf("expected exactly four payments to settle the invoice, got %d accepted and %#v", accepted, paid)
+	}
+}
+
+func TestInvoicePaymentsPublishEvents(t *testing.T) {
+	server := NewServer()
+	cookie := loginForTest(t, server)
+	paymentHook := createWebhookForTest(t, server, cookie, WebhookSubscriptionCreateRequest{URL: "https://hooks.example.com/payments", Events: []string{EventInvoicePaymentReceived}})
+	paidHook := createWebhookForTest(t, server, cookie, WebhookSubscriptionCreateRequest{URL: "https://hooks.example.com/paid", Events: []string{EventInvoicePaid}})
+	created := createInvoiceForTest(t, server, cookie, InvoiceCreateRequest{Number: "INV-0001", CustomerID: "cust-1", Currency: "ILS", Subtotal: 1000, Status: invoice.StatusOpen})
+
+	recordPaymentForTest(t, server, cookie, created.ID, PaymentCreateRequest{Amount: 400, Method: invoice.PaymentCard})
+	payment := recordPaymentForTest(t, server, cookie, created.ID, PaymentCreateRequest{Amount: 600, Method: invoice.PaymentCard})
+
+	deliveries := listDeliveriesForTest(t, server, cookie, paymentHook.ID)
+	if len(deliveries) != 2 || deliveries[0].Event != EventInvoicePaymentReceived {
+		t.Fatalf("expected two payment deliveries, got %#v", deliveries)
+	}
+	var event struct {
+		Data PaymentReceivedEvent `json:"data"`
+	}
+	for _, delivery := range deliveries {
+		if err := json.Unmarshal(delivery.Payload, &event); err != nil {
+			t.Fatalf("decode payload: %v", err)
+		}
+		if event.Data.Payment.ID == payment.ID {
+			break
+		}
+	}
+	if event.Data.Payment.ID != payment.ID || event.Data.Invoice.AmountPaid != 1000 || event.Data.Invoice.Status != invoice.StatusPaid {
+		t.Fatalf("expected payload with the settling payment and paid invoice, got %#v", event.Data)
+	}
+	if paid := listDeliveriesForTest(t, server, cookie, paidHook.ID); len(paid) != 1 {
+		t.Fatalf("expected one invoice.paid delivery, got %#v", paid)
+	}
+}
+
+func recordPaymentForTest(t *testing.T, server *Server, cookie *http.Cookie, invoiceID string, req PaymentCreateRequest) invoice.Payment {
+	t.Helper()
+
+	rec := doJSON(t, server, cookie, http.MethodPost, "/v1/invoices/"+invoiceID+"/payments", req)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected payment 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var item invoice.Payment
+	if err := json.NewDecoder(rec.Body).Decode(&item); err != nil {
+		t.Fatalf("decode payment: %v", err)
+	}
+	return item
+}
+
+func getInvoiceForTest(t *testing.T, server *Server, cookie *http.Cookie, id string) invoice.Invoice {
+	t.Helper()
+
+	rec := doJSON(t, server, cookie, http.MethodGet, "/v1/invoices/"+id, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected invoice 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var item invoice.Invoice
+	if err := json.NewDecoder(rec.Body).Decode(&item); err != nil {
+		t.Fatalf("decode invoice: %v", err)
+	}
+	return item
+}
+
+func decodeErrorCodeForTest(t *testing.T, rec *httptest.ResponseRecorder) string {
+	t.Helper()
+
+	var body apierror.Error
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatalf("decode error: %v", err)
+	}
+	return body.Code
+}
@@ -0,0 +1,52 @@
+package api
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// PoolStats is a snapshot of the database connection pool. WaitCount and
+// WaitDurationSeconds count the acquires that found no idle connection and
+// how long they waited for one, since the pool was opened.
+type PoolStats struct {
+	MaxConns            int32   `json:"maxConns"`
+	TotalConns          int32   `json:"totalConns"`
+	InUse               int32   `json:"inUse"`
+	Idle                int32   `json:"idle"`
+	WaitCount           int64   `json:"waitCount"`
+	WaitDurationSeconds float64 `json:"waitDurationSeconds"`
+}
+
+// poolCollector exports PoolStats, taking one snapshot per scrape.
+type poolCollector struct {
+	stats        func() PoolStats
+	maxConns     *prometheus.Desc
+	conns        *prometheus.Desc
+	waitCount    *prometheus.Desc
+	waitDuration *prometheus.Desc
+}
+
+func newPoolCollector(stats func() PoolStats) *poolCollector {
+	return &poolCollector{
+		stats:        stats,
+		maxConns:     prometheus.NewDesc("invoicer_db_pool_max_connections", "Most connections the database pool opens.", nil, nil),
+		conns:        prometheus.NewDesc("invoicer_db_pool_connections", "Open database connections, by state (in_use or idle).", []string{"state"}, nil),
+		waitCount:    prometheus.NewDesc("invoicer_db_pool_wait_count_total", "Connection acquires that had to wait because none was idle.", nil, nil),
+		waitDuration: prometheus.NewDesc("invoicer_db_pool_wait_duration_seconds_total", "Time spent waiting for a database connection.", nil, nil),
+	}
+}
+
+func (c *poolCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.maxConns
+	ch <- c.conns
+	ch <- c.waitCount
+	ch <- c.waitDuration
+}
+
+func (c *poolCollector) Collect(ch chan<- prometheus.Metric) {
+	stats := c.stats()
+	ch <- prometheus.MustNewConstMetric(c.maxConns, prometheus.GaugeValue, float64(stats.MaxConns))
+	ch <- prometheus.MustNewConstMetric(c.conns, prometheus.GaugeValue, float64(stats.InUse), "in_use")
+	ch <- prometheus.MustNewConstMetric(c.conns, prometheus.GaugeValue, float64(stats.Idle), "idle")
+	ch <- prometheus.MustNewConstMetric(c.waitCount, prometheus.CounterValue, float64(stats.WaitCount))
+	ch <- prometheus.MustNewConstMetric(c.waitDuration, prometheus.CounterValue, stats.WaitDurationSeconds)
+}
@@ -0,0 +1,191 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	purgeBatchSize          = 100
+	defaultDeletedRetention = 90 * 24 * time.Hour
+	defaultAuditRetention   = 7 * 365 * 24 * time.Hour
+	defaultPurgeInterval    = 24 * time.Hour
+	// purgeLock is the name of the lock only one purge holds at a time.
+	purgeLock = "purge"
+)
+
+// ErrPurgeRunning means another process holds the purge lock.
+var ErrPurgeRunning = errors.New("another purge is running")
+
+// PurgeRecord is a soft-deleted invoice or customer old enough to purge.
+type PurgeRecord struct {
+	TenantID  string
+	ID        string
+	DeletedAt string
+}
+
+// PurgeSummary counts what a purge removed, or on a dry run would remove.
+// Attachments counts blobs deleted from the object store.
+type PurgeSummary struct {
+	Invoices        int
+	Customers       int
+	Attachments     int
+	AuditEntries    int
+	IdempotencyKeys int
+}
+
+// PurgeDeletedRecords permanently removes invoices and customers that were
+// soft-deleted longer than the deleted retention ago, the attachment blobs
+// no remaining invoice uses, audit entries older than the audit
+// retention, and expired idempotency keys. Each invoice and customer goes in its own transaction, so a
+// failure keeps what was purged before it. It holds the purge lock while it
+// runs and returns ErrPurgeRunning when another replica has it. With dryRun
+// set nothing is removed and the summary counts what would be.
+func (s *Server) PurgeDeletedRecords(ctx context.Context, dryRun bool) (PurgeSummary, error) {
+	now := time.Now().UTC()
+	deletedBefore := now.Add(-s.deletedRetention).Format(time.RFC3339)
+	auditBefore := now.Add(-s.auditRetention).Format(time.RFC3339)
+	expiredBefore := now.Format(time.RFC3339)
+	if dryRun {
+		return s.store.CountPurgeable(ctx, deletedBefore, auditBefore, expiredBefore)
+	}
+	var summary PurgeSummary
+	ran, err := s.store.TryExclusive(ctx, purgeLock, func(ctx context.Context) error {
+		return s.purge(ctx, deletedBefore, auditBefore, expiredBefore, &summary)
+	})
+	if err == nil && !ran {
+		err = ErrPurgeRunning
+	}
+	return summary, err
+}
+
+func (s *Server) purge(ctx context.Context, deletedBefore, auditBefore, expiredBefore string, summary *PurgeSummary) error {
+	// Invoices go first: a customer is only purged once no invoice is left
+	// billing to it.
+	for {
+		records, err := s.store.ListPurgeableInvoices(ctx, deletedBefore, purgeBatchSize)
+		if err != nil {
+			return err
+		}
+		purged := 0
+		for _, record := range records {
+			checksums, err := s.store.PurgeInvoice(ctx, record.TenantID, record.ID, deletedBefore)
+			if errors.Is(err, ErrNotFound) {
+				// Restored or purged by someone else since it was listed.
+				continue
+			}
+			if err != nil {
+				return fmt.Errorf("purge invoice %s: %w", record.ID, err)
+			}
+			purged++
+			summary.Invoices++
+			_ = s.recordAudit(ctx, record.TenantID, "", "invoice.purged", "invoice", record.ID, "Purged invoice deleted at "+record.DeletedAt)
+			for _, checksum := range checksums {
+				released, err := s.releaseAttachmentObject(ctx, record.TenantID, checksum)
+				if err != nil {
+					return fmt.Errorf("purge invoice %s: %w", record.ID, err)
+				}
+				if released {
+					summary.Attachments++
+				}
+			}
+		}
+		if len(records) < purgeBatchSize || purged == 0 {
+			break
+		}
+	}
+	for {
+		records, err := s.store.ListPurgeableCustomers(ctx, deletedBefore, purgeBatchSize)
+		if err != nil {
+			return err
+		}
+		purged := 0
+		for _, record := range records {
+			err := s.store.PurgeCustomer(ctx, record.TenantID, record.ID, deletedBefore)
+			if errors.Is(err, ErrNotFound) {
+				continue
+			}
+			if err != nil {
+				return fmt.Errorf("purge customer %s: %w", record.ID, err)
+			}
+			purged++
+			summary.Customers++
+			_ = s.recordAudit(ctx, record.TenantID, "", "customer.purged", "customer", record.ID, "Purged customer deleted at "+record.DeletedAt)
+		}
+		if len(records) < purgeBatchSize || purged == 0 {
+			break
+		}
+	}
+	for {
+		deleted, err := s.store.PurgeAuditEntries(ctx, auditBefore, purgeBatchSize)
+		if err != nil {
+			return err
+		}
+		summary.AuditEntries += deleted
+		if deleted < purgeBatchSize {
+			break
+		}
+	}
+	for {
+		deleted, err := s.store.PurgeIdempotencyKeys(ctx, expiredBefore, purgeBatchSize)
+		if err != nil {
+			return err
+		}
+		summary.IdempotencyKeys += deleted
+		if deleted < purgeBatchSize {
+			return nil
+		}
+	}
+}
+
+// releaseAttachmentObject deletes the tenant's blob for checksum unless an
+// attachment still points at it. It runs after the purge has committed, so
+// a failed commit never leaves rows without their blob, and under the lock
+// uploads take to reuse a blob, so one cannot reuse it as it goes. A crash
+// between the two leaves an unused blob behind, never a missing one.
+func (s *Server) releaseAttachmentObject(ctx context.Context, tenantID, checksum string) (bool, error) {
+	released := false
+	err := s.store.LockAttachmentObject(ctx, tenantID, checksum, func(ctx context.Context) error {
+		stored, err := s.store.AttachmentObjectStored(ctx, tenantID, checksum)
+		if err != nil || stored {
+			return err
+		}
+		if err := s.objectStore.Delete(ctx, attachmentObjectKey(tenantID, checksum)); err != nil {
+			return err
+		}
+		released = true
+		return nil
+	})
+	return released, err
+}
+
+// RunPurgeScheduler calls PurgeDeletedRecords at startup and then every
+// interval until ctx is done, logging what each run purged. A run another
+// replica is doing is skipped.
+func (s *Server) RunPurgeScheduler(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = defaultPurgeInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		summary, err := s.PurgeDeletedRecords(ctx, false)
+		switch {
+		case errors.Is(err, ErrPurgeRunning):
+			s.logger.DebugContext(ctx, "purge skipped", "reason", err.Error())
+		case err != nil && ctx.Err() == nil:
+			s.logger.ErrorContext(ctx, "purge deleted records", "error", err, "invoices", summary.Invoices, "customers", summary.Customers,
+				"attachments", summary.Attachments, "audit_entries", summary.AuditEntries, "idempotency_keys", summary.IdempotencyKeys)
+		case summary != PurgeSummary{}:
+			s.logger.InfoContext(ctx, "purged deleted records", "invoices", summary.Invoices, "customers", summary.Customers,
+				"attachments", summary.Attachments, "audit_entries", summary.AuditEntries, "idempotency_keys", summary.IdempotencyKeys)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
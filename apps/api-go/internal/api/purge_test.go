@@ -0,0 +1,112 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+)
+
+func TestPurgeDeletedRecordsRemovesWhatOutlivedRetention(t *testing.T) {
+	store := NewMemoryStore()
+	objects := NewMemoryObjectStore()
+	server := NewServer(WithStore(store), WithObjectStore(objects))
+	cookie := loginForTest(t, server)
+	kept := createCustomerForTest(t, server, cookie, CustomerCreateRequest{Name: "Acme"})
+	gone := createCustomerForTest(t, server, cookie, CustomerCreateRequest{Name: "Globex"})
+	old := createInvoiceForTest(t, server, cookie, InvoiceCreateRequest{Number: "INV-0001", CustomerID: kept.ID, Currency: "ILS"})
+	recent := createInvoiceForTest(t, server, cookie, InvoiceCreateRequest{Number: "INV-0002", CustomerID: kept.ID, Currency: "ILS"})
+	// The shared file stays for the recent invoice; the other one goes.
+	uploadAttachmentForTest(t, server, cookie, old.ID, "receipt.pdf", "application/pdf", pdfForTest)
+	uploadAttachmentForTest(t, server, cookie, recent.ID, "receipt.pdf", "application/pdf", pdfForTest)
+	uploadAttachmentForTest(t, server, cookie, old.ID, "notes.pdf", "application/pdf", append([]byte("%PDF-1.4\n%notes\n"), pdfForTest...))
+	for _, path := range []string{"/v1/invoices/" + old.ID, "/v1/invoices/" + recent.ID, "/v1/customers/" + kept.ID, "/v1/customers/" + gone.ID} {
+		if rec := doJSON(t, server, cookie, http.MethodDelete, path, nil); rec.Code != http.StatusNoContent {
+			t.Fatalf("delete %s: expected 204, got %d: %s", path, rec.Code, rec.Body.String())
+		}
+	}
+
+	// Only the recent invoice was deleted within the retention; every audit
+	// entry so far is older than its window.
+	const longAgo = "2020-01-01T00:00:00Z"
+	store.mu.Lock()
+	expired := store.invoices[old.ID]
+	expired.DeletedAt = longAgo
+	store.invoices[old.ID] = expired
+	for _, id := range []string{kept.ID, gone.ID} {
+		item := store.customers[id]
+		item.DeletedAt = longAgo
+		store.customers[id] = item
+	}
+	audited := len(store.auditEvents) + len(store.auditLog)
+	for id, item := range store.auditEvents {
+		item.CreatedAt = "2010-01-01T00:00:00Z"
+		store.auditEvents[id] = item
+	}
+	for i := range store.auditLog {
+		store.auditLog[i].CreatedAt = "2010-01-01T00:00:00Z"
+	}
+	// A lapsed claim and an expired response go; a live response stays.
+	for key, expiresAt := range map[string]string{"lapsed": longAgo, "expired": "2021-01-01T00:00:00Z", "live": "2099-01-01T00:00:00Z"} {
+		store.idempotencyKeys[idempotencyRecordID("tenant-alpha", "POST /v1/invoices", key)] = IdempotencyRecord{TenantID: "tenant-alpha", Scope: "POST /v1/invoices", Key: key, StatusCode: 201, CreatedAt: longAgo, ExpiresAt: expiresAt}
+	}
+	store.mu.Unlock()
+	want := PurgeSummary{Invoices: 1, Customers: 1, Attachments: 1, AuditEntries: audited, IdempotencyKeys: 2}
+
+	ctx := context.Background()
+	summary, err := server.PurgeDeletedRecords(ctx, true)
+	if err != nil || summary != want {
+		t.Fatalf("expected the dry run to count %#v, got %#v, %v", want, summary, err)
+	}
+	if objects.Len() != 2 || len(store.invoices) != 2 || len(store.customers) != 2 {
+		t.Fatalf("expected the dry run to remove nothing, got %d objects, %d invoices, %d customers", objects.Len(), len(store.invoices), len(store.customers))
+	}
+
+	summary, err = server.PurgeDeletedRecords(ctx, false)
+	if err != nil || summary != want {
+		t.Fatalf("expected the purge to remove %#v, got %#v, %v", want, summary, err)
+	}
+	if _, err := store.GetInvoice(ctx, "tenant-alpha", old.ID); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected the old invoice to be gone, got %v", err)
+	}
+	if _, err := store.GetInvoice(ctx, "tenant-alpha", recent.ID); err != nil {
+		t.Fatalf("expected the recently deleted invoice to stay, got %v", err)
+	}
+	// Acme is still billed by the invoice that stays.
+	if _, err := store.GetCustomer(ctx, "tenant-alpha", kept.ID); err != nil {
+		t.Fatalf("expected the still invoiced customer to stay, got %v", err)
+	}
+	if _, err := store.GetCustomer(ctx, "tenant-alpha", gone.ID); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected the old customer to be gone, got %v", err)
+	}
+	if objects.Len() != 1 || len(store.attachments[old.ID]) != 0 || len(store.attachments[recent.ID]) != 1 {
+		t.Fatalf("expected only the unshared blob removed, got %d objects", objects.Len())
+	}
+	for _, item := range store.auditEvents {
+		if item.Action != "invoice.purged" && item.Action != "customer.purged" {
+			t.Fatalf("expected only the purge audit entries left, got %#v", item)
+		}
+	}
+	if _, live := store.idempotencyKeys[idempotencyRecordID("tenant-alpha", "POST /v1/invoices", "live")]; !live || len(store.idempotencyKeys) != 1 {
+		t.Fatalf("expected only the live idempotency key left, got %d", len(store.idempotencyKeys))
+	}
+
+	if summary, err := server.PurgeDeletedRecords(ctx, false); err != nil || summary != (PurgeSummary{}) {
+		t.Fatalf("expected a second purge to find nothing, got %#v, %v", summary, err)
+	}
+}
+
+func TestPurgeDeletedRecordsSkipsWhileAnotherPurgeRuns(t *testing.T) {
+	store := NewMemoryStore()
+	server := NewServer(WithStore(store))
+	ran, err := store.TryExclusive(context.Background(), purgeLock, func(ctx context.Context) error {
+		_, err := server.PurgeDeletedRecords(ctx, false)
+		return err
+	})
+	if !ran || !errors.Is(err, ErrPurgeRunning) {
+		t.Fatalf("expected ErrPurgeRunning while the lock is held, got %v, %v", ran, err)
+	}
+	if _, err := server.PurgeDeletedRecords(context.Background(), false); err != nil {
+		t.Fatalf("expected a purge once the lock is released, got %v", err)
+	}
+}
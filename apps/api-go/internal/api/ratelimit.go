@@ -0,0 +1,37 @@
+package api
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/apierror"
+)
+
+// allowRequest takes a rate limit token for key and answers 429 with
+// Retry-After when there is none. Servers built without WithRateLimit do not
+// limit at all.
+func (s *Server) allowRequest(w http.ResponseWriter, key string) bool {
+	if s.rateLimiter == nil {
+		return true
+	}
+	ok, wait := s.rateLimiter.Allow(key)
+	if ok {
+		return true
+	}
+	seconds := int(min(math.Ceil(wait.Seconds()), math.MaxInt32))
+	w.Header().Set("Retry-After", strconv.Itoa(max(seconds, 1)))
+	apierror.WriteError(w, http.StatusTooManyRequests, apierror.New(apierror.CodeRateLimited, "rate limit exceeded"))
+	return false
+}
+
+// clientIPKey buckets unauthenticated requests by the connecting address.
+// Forwarded headers are ignored because any client can set them.
+func clientIPKey(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "ip:" + host
+}
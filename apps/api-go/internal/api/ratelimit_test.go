@@ -0,0 +1,70 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/auth"
+)
+
+func TestRateLimitIsPerOrganization(t *testing.T) {
+	key, _ := newBearerTestServer(t)
+	server := NewServer(WithTokenVerifier(auth.NewVerifier(&key.PublicKey, 0)), WithRateLimit(0.1, 2))
+	cookie := loginForTest(t, server)
+
+	for i := range 2 {
+		if rec := doJSON(t, server, cookie, http.MethodGet, "/v1/me", nil); rec.Code != http.StatusOK {
+			t.Fatalf("request %d: expected 200 within burst, got %d", i+1, rec.Code)
+		}
+	}
+	rec := doJSON(t, server, cookie, http.MethodGet, "/v1/me", nil)
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected 429 over the limit, got %d", rec.Code)
+	}
+	retryAfter, err := strconv.Atoi(rec.Header().Get("Retry-After"))
+	if err != nil || retryAfter < 1 || retryAfter > 10 {
+		t.Fatalf("expected Retry-After of up to 10 seconds, got %q", rec.Header().Get("Retry-After"))
+	}
+
+	// The same org is limited through a bearer token; another org is not.
+	if rec := doBearer(t, server, http.MethodGet, "/v1/me", signBearerForTest(t, key, "user-1", "tenant-alpha", time.Minute), nil); rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected tenant-alpha bearer request 429, got %d", rec.Code)
+	}
+	if rec := doBearer(t, server, http.MethodGet, "/v1/me", signBearerForTest(t, key, "user-2", "org-b", time.Minute), nil); rec.Code != http.StatusOK {
+		t.Fatalf("expected org-b to have its own budget, got %d", rec.Code)
+	}
+}
+
+func TestRateLimitFallsBackToClientIPForLogin(t *testing.T) {
+	server := NewServer(WithRateLimit(0.1, 1))
+	login := func(remoteAddr string) int {
+		payload, _ := json.Marshal(LoginRequest{Email: "operator@example.com", TenantID: "tenant-alpha"})
+		req := httptest.NewRequest(http.MethodPost, "/auth/login", bytes.NewReader(payload))
+		req.Header.Set("Content-Type", "application/json")
+		req.RemoteAddr = remoteAddr
+		rec := httptest.NewRecorder()
+		server.Handler().ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	if code := login("198.51.100.7:4000"); code != http.StatusOK {
+		t.Fatalf("expected first login 200, got %d", code)
+	}
+	if code := login("198.51.100.7:4001"); code != http.StatusTooManyRequests {
+		t.Fatalf("expected second login from the same IP 429, got %d", code)
+	}
+	if code := login("203.0.113.9:4000"); code != http.StatusOK {
+		t.Fatalf("expected another IP to be allowed, got %d", code)
+	}
+
+	rec := httptest.NewRecorder()
+	server.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected health checks to bypass the limiter, got %d", rec.Code)
+	}
+}
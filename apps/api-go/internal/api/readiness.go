@@ -0,0 +1,99 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const defaultReadinessCheckTimeout = 2 * time.Second
+
+// ReadinessCheck is a downstream dependency probed by /readyz.
+type ReadinessCheck interface {
+	Name() string
+	Check(ctx context.Context) error
+}
+
+// ReadinessResponse carries the database pool's stats when the server was
+// given them, so saturation shows next to the checks.
+type ReadinessResponse struct {
+	Status string                 `json:"status"`
+	Checks []ReadinessCheckResult `json:"checks"`
+	Pool   *PoolStats             `json:"pool,omitempty"`
+}
+
+type ReadinessCheckResult struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+type readinessCheckFunc struct {
+	name  string
+	check func(ctx context.Context) error
+}
+
+func (c readinessCheckFunc) Name() string { return c.name }
+
+func (c readinessCheckFunc) Check(ctx context.Context) error { return c.check(ctx) }
+
+// NewReadinessCheck adapts a ping-style function into a ReadinessCheck.
+func NewReadinessCheck(name string, check func(ctx context.Context) error) ReadinessCheck {
+	return readinessCheckFunc{name: name, check: check}
+}
+
+func (s *Server) handleReadyz(w http.ResponseWriter, r *http.Request) {
+	if s.draining.Load() {
+		writeJSON(w, http.StatusServiceUnavailable, ReadinessResponse{Status: "shutting_down", Checks: []ReadinessCheckResult{}})
+		return
+	}
+
+	results := s.runReadinessChecks(r.Context())
+	status := http.StatusOK
+	response := ReadinessResponse{Status: "ready", Checks: results}
+	if s.poolStats != nil {
+		stats := s.poolStats()
+		response.Pool = &stats
+	}
+	for _, result := range results {
+		if result.Status != "ok" {
+			status = http.StatusServiceUnavailable
+			response.Status = "not_ready"
+			break
+		}
+	}
+	writeJSON(w, status, response)
+}
+
+// runReadinessChecks probes every registered check in parallel, each bounded
+// by its own timeout so one slow dependency cannot stall the whole probe.
+func (s *Server) runReadinessChecks(ctx context.Context) []ReadinessCheckResult {
+	results := make([]ReadinessCheckResult, len(s.readinessChecks))
+	var wg sync.WaitGroup
+	for i, check := range s.readinessChecks {
+		wg.Add(1)
+		go func(i int, check ReadinessCheck) {
+			defer wg.Done()
+			checkCtx, cancel := context.WithTimeout(ctx, s.readinessTimeout)
+			defer cancel()
+
+			result := ReadinessCheckResult{Name: check.Name(), Status: "ok"}
+			errCh := make(chan error, 1)
+			go func() { errCh <- check.Check(checkCtx) }()
+			select {
+			case err := <-errCh:
+				if err != nil {
+					result.Status = "failed"
+					result.Error = err.Error()
+				}
+			case <-checkCtx.Done():
+				result.Status = "failed"
+				result.Error = "timed out after " + s.readinessTimeout.String()
+			}
+			results[i] = result
+		}(i, check)
+	}
+	wg.Wait()
+	return results
+}
@@ -0,0 +1,82 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestReadyzReportsAllChecksHealthy(t *testing.T) {
+	server := NewServer(WithReadinessChecks(
+		NewReadinessCheck("postgres", func(context.Context) error { return nil }),
+		NewReadinessCheck("object_store", func(context.Context) error { return nil }),
+	))
+
+	rec := httptest.NewRecorder()
+	server.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+	var body ReadinessResponse
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatalf("decode readiness: %v", err)
+	}
+	if body.Status != "ready" || len(body.Checks) != 2 {
+		t.Fatalf("unexpected readiness body: %#v", body)
+	}
+}
+
+func TestReadyzListsFailedAndSlowChecks(t *testing.T) {
+	server := NewServer(WithReadinessChecks(
+		NewReadinessCheck("postgres", func(context.Context) error { return errors.New("connection refused") }),
+		NewReadinessCheck("object_store", func(ctx context.Context) error {
+			<-ctx.Done()
+			return ctx.Err()
+		}),
+		NewReadinessCheck("cache", func(context.Context) error { return nil }),
+	))
+	server.readinessTimeout = 50 * time.Millisecond
+
+	started := time.Now()
+	rec := httptest.NewRecorder()
+	server.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+	if elapsed := time.Since(started); elapsed > time.Second {
+		t.Fatalf("expected checks to run in parallel under the timeout, took %s", elapsed)
+	}
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503, got %d", rec.Code)
+	}
+	var body ReadinessResponse
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatalf("decode readiness: %v", err)
+	}
+	statuses := map[string]string{}
+	for _, check := range body.Checks {
+		statuses[check.Name] = check.Status
+	}
+	if statuses["postgres"] != "failed" || statuses["object_store"] != "failed" || statuses["cache"] != "ok" {
+		t.Fatalf("unexpected check results: %#v", body.Checks)
+	}
+}
+
+func TestReadyzIncludesPoolStats(t *testing.T) {
+	stats := PoolStats{MaxConns: 20, TotalConns: 5, InUse: 3, Idle: 2, WaitCount: 7, WaitDurationSeconds: 0.25}
+	server := NewServer(WithPoolStats(func() PoolStats { return stats }))
+
+	rec := httptest.NewRecorder()
+	server.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+	var body ReadinessResponse
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatalf("decode readiness: %v", err)
+	}
+	if body.Pool == nil || *body.Pool != stats {
+		t.Fatalf("expected the pool stats in readiness, got %#v", body.Pool)
+	}
+}
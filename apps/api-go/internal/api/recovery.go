@@ -0,0 +1,54 @@
+package api
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/apierror"
+)
+
+// withRecovery turns a panic in next into a 500 internal_error and logs it
+// with its stack, so one broken handler cannot take the connection down
+// without a trace. http.ErrAbortHandler is re-raised because net/http uses
+// it to abort a response on purpose.
+func (s *Server) withRecovery(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			value := recover()
+			if value == nil {
+				return
+			}
+			if value == http.ErrAbortHandler {
+				panic(value)
+			}
+			s.logger.ErrorContext(r.Context(), "panic serving request",
+				slog.Any("panic", value),
+				slog.String("stack", string(debug.Stack())),
+			)
+			recordRequestError(w, fmt.Errorf("panic: %v", value))
+			if !responseStarted(w) {
+				apierror.WriteError(w, http.StatusInternalServerError, apierror.New(apierror.CodeInternal, "internal server error"))
+			}
+		}()
+		next.ServeHTTP(w, r)
+	})
+}
+
+// responseStarted reports whether the handler writing through w has already
+// sent the status line, after which an error response can no longer be
+// written.
+func responseStarted(w http.ResponseWriter) bool {
+	for w != nil {
+		if recorder, ok := w.(*statusRecorder); ok {
+			return recorder.wroteHeader
+		}
+		unwrapper, ok := w.(interface{ Unwrap() http.ResponseWriter })
+		if !ok {
+			return false
+		}
+		w = unwrapper.Unwrap()
+	}
+	return false
+}
@@ -0,0 +1,347 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/apierror"
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/invoice"
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/tax"
+)
+
+const (
+	recurringBatchSize           = 100
+	defaultRecurringScanInterval = time.Hour
+	maxRecurringDueDays          = 365
+
+	RecurringActive = "active"
+	RecurringPaused = "paused"
+)
+
+// RecurringInvoice is a template billed on a schedule. Each run of
+// Recurrence materializes an invoice with InvoiceStatus from the template
+// items, issued on the run date and due DueDays later.
+type RecurringInvoice struct {
+	ID            string                   `json:"id"`
+	TenantID      string                   `json:"tenantId"`
+	CustomerID    string                   `json:"customerId"`
+	Currency      string                   `json:"currency"`
+	Items         []InvoiceLineItemRequest `json:"items"`
+	TaxRounding   tax.Rounding             `json:"taxRounding"`
+	InvoiceStatus invoice.Status           `json:"invoiceStatus"`
+	DueDays       int                      `json:"dueDays"`
+	Recurrence    invoice.Recurrence       `json:"recurrence"`
+	Status        string                   `json:"status"`
+	NextRunAt     string                   `json:"nextRunAt"`
+	LastRunAt     string                   `json:"lastRunAt,omitempty"`
+	LastInvoiceID string                   `json:"lastInvoiceId,omitempty"`
+	CreatedAt     string                   `json:"createdAt"`
+	UpdatedAt     string                   `json:"updatedAt"`
+}
+
+type RecurringInvoiceCreateRequest struct {
+	CustomerID    string                   `json:"customerId"`
+	Currency      string                   `json:"currency"`
+	Items         []InvoiceLineItemRequest `json:"items"`
+	TaxRounding   tax.Rounding             `json:"taxRounding"`
+	InvoiceStatus invoice.Status           `json:"invoiceStatus"`
+	DueDays       int                      `json:"dueDays"`
+	Recurrence    invoice.Recurrence       `json:"recurrence"`
+}
+
+type RecurringInvoiceList struct {
+	Items []RecurringInvoice `json:"items"`
+}
+
+// RecurringRun is one billed period of a recurring invoice: the invoice
+// generated for the run at ScheduledAt and the run that follows it.
+type RecurringRun struct {
+	TenantID           string
+	RecurringInvoiceID string
+	Period             string
+	ScheduledAt        string
+	NextRunAt          string
+	Invoice            invoice.Invoice
+}
+
+func (s *Server) handleRecurringInvoices(w http.ResponseWriter, r *http.Request, session Session) {
+	switch r.Method {
+	case http.MethodGet:
+		items, err := s.store.ListRecurringInvoices(r.Context(), session.TenantID)
+		if err != nil {
+			s.writeInternalError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, RecurringInvoiceList{Items: items})
+	case http.MethodPost:
+		s.withIdempotency(w, r, session, func(w http.ResponseWriter) {
+			s.createRecurringInvoice(w, r, session)
+		})
+	default:
+		apierror.WriteError(w, http.StatusMethodNotAllowed, errMethodNotAllowed)
+	}
+}
+
+func (s *Server) createRecurringInvoice(w http.ResponseWriter, r *http.Request, session Session) {
+	var req RecurringInvoiceCreateRequest
+	if err := decodeJSON(r, &req); err != nil {
+		writeBodyError(w, err)
+		return
+	}
+	now := utcNow()
+	item := RecurringInvoice{
+		ID:            s.newID("rinv"),
+		TenantID:      session.TenantID,
+		CustomerID:    strings.TrimSpace(req.CustomerID),
+		Currency:      invoice.NormalizeCurrency(req.Currency),
+		Items:         req.Items,
+		TaxRounding:   req.TaxRounding,
+		InvoiceStatus: req.InvoiceStatus,
+		DueDays:       req.DueDays,
+		Recurrence:    req.Recurrence,
+		Status:        RecurringActive,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+	if item.TaxRounding == "" {
+		item.TaxRounding = tax.RoundLine
+	}
+	if item.InvoiceStatus == "" {
+		item.InvoiceStatus = invoice.StatusDraft
+	}
+	item.Recurrence.Normalize()
+	if err := s.validateRecurringInvoice(item); err != nil {
+		writeValidationError(w, err)
+		return
+	}
+	item.NextRunAt = item.Recurrence.Run(0).Format(time.RFC3339)
+	if err := s.store.CreateRecurringInvoice(r.Context(), item); err != nil {
+		s.writeInternalError(w, err)
+		return
+	}
+	if err := s.recordAudit(r.Context(), session.TenantID, routeCtx(r).RequestID, "recurring_invoice.created", "recurring_invoice", item.ID, fmt.Sprintf("Created %s recurring invoice for %s", item.Recurrence.Frequency, item.CustomerID)); err != nil {
+		s.writeInternalError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, item)
+}
+
+// validateRecurringInvoice checks the template by building an invoice from
+// it, so a template that is accepted also materializes.
+func (s *Server) validateRecurringInvoice(item RecurringInvoice) error {
+	if len(item.Items) == 0 {
+		return apierror.Field("items", "must have at least one line item")
+	}
+	if item.InvoiceStatus != invoice.StatusDraft && item.InvoiceStatus != invoice.StatusOpen {
+		return apierror.Field("invoiceStatus", "must be draft or open")
+	}
+	if item.DueDays < 0 || item.DueDays > maxRecurringDueDays {
+		return apierror.Field("dueDays", fmt.Sprintf("must be between 0 and %d", maxRecurringDueDays))
+	}
+	if err := item.Recurrence.Validate(); err != nil {
+		return err
+	}
+	_, err := s.materializeRecurringInvoice(item, time.Now().UTC(), false)
+	return err
+}
+
+func (s *Server) handleRecurringInvoiceDetail(w http.ResponseWriter, r *http.Request, session Session) {
+	id, action := trimPrefixID(r.URL.Path, "/v1/recurring-invoices/")
+	item, err := s.store.GetRecurringInvoice(r.Context(), session.TenantID, id)
+	if err != nil {
+		s.writeLookupError(w, err, "recurring invoice")
+		return
+	}
+	if action == "" {
+		if r.Method != http.MethodGet {
+			apierror.WriteError(w, http.StatusMethodNotAllowed, errMethodNotAllowed)
+			return
+		}
+		writeJSON(w, http.StatusOK, item)
+		return
+	}
+	if r.Method != http.MethodPost {
+		apierror.WriteError(w, http.StatusMethodNotAllowed, errMethodNotAllowed)
+		return
+	}
+	now := time.Now().UTC()
+	switch action {
+	case "pause":
+		item.Status = RecurringPaused
+	case "resume":
+		item.Status = RecurringActive
+		// Runs that fell due while paused are skipped, not billed late.
+		if nextRunAt, _ := time.Parse(time.RFC3339, item.NextRunAt); !nextRunAt.After(now) {
+			item.NextRunAt = item.Recurrence.Next(now).Format(time.RFC3339)
+		}
+	default:
+		apierror.WriteError(w, http.StatusNotFound, errRouteNotFound)
+		return
+	}
+	item.UpdatedAt = now.Format(time.RFC3339)
+	if err := s.store.UpdateRecurringInvoice(r.Context(), item); err != nil {
+		s.writeLookupError(w, err, "recurring invoice")
+		return
+	}
+	if err := s.recordAudit(r.Context(), session.TenantID, routeCtx(r).RequestID, "recurring_invoice."+action, "recurring_invoice", item.ID, strings.Title(action)+"d recurring invoice"); err != nil {
+		s.writeInternalError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, item)
+}
+
+// GenerateRecurringInvoices bills every active recurring invoice whose next
+// run has come, catching up one invoice per missed period, and reports how
+// many invoices it created. Each period is recorded with its invoice, so a
+// restarted or concurrent worker never bills a period twice.
+func (s *Server) GenerateRecurringInvoices(ctx context.Context) (int, error) {
+	now := time.Now().UTC()
+	generated := 0
+	for {
+		items, err := s.store.ListDueRecurringInvoices(ctx, now.Format(time.RFC3339), recurringBatchSize)
+		if err != nil {
+			return generated, err
+		}
+		for _, item := range items {
+			n, err := s.generateRecurringInvoice(ctx, item, now)
+			generated += n
+			if err != nil {
+				return generated, err
+			}
+		}
+		if len(items) < recurringBatchSize {
+			return generated, nil
+		}
+	}
+}
+
+func (s *Server) generateRecurringInvoice(ctx context.Context, item RecurringInvoice, now time.Time) (int, error) {
+	generated := 0
+	for {
+		runAt, err := time.Parse(time.RFC3339, item.NextRunAt)
+		if err != nil {
+			return generated, fmt.Errorf("recurring invoice %s: %w", item.ID, err)
+		}
+		if runAt.After(now) {
+			return generated, nil
+		}
+		exempt, err := s.invoiceCustomerTaxExempt(ctx, item.TenantID, item.CustomerID)
+		if errors.Is(err, errUnknownCustomer) {
+			return generated, s.pauseRecurringInvoice(ctx, item, now)
+		}
+		if err != nil {
+			return generated, err
+		}
+		generatedInvoice, err := s.materializeRecurringInvoice(item, runAt, exempt)
+		if err != nil {
+			return generated, fmt.Errorf("recurring invoice %s: %w", item.ID, err)
+		}
+		run := RecurringRun{
+			TenantID:           item.TenantID,
+			RecurringInvoiceID: item.ID,
+			Period:             invoice.Period(runAt),
+			ScheduledAt:        item.NextRunAt,
+			NextRunAt:          item.Recurrence.Next(runAt).Format(time.RFC3339),
+			Invoice:            generatedInvoice,
+		}
+		generatedInvoice, created, err := s.store.RecordRecurringRun(ctx, run, s.invoiceNumbers)
+		if errors.Is(err, ErrConflict) || errors.Is(err, ErrNotFound) {
+			// Paused, deleted, or billed by another worker since it was read.
+			return generated, nil
+		}
+		if err != nil {
+			return generated, err
+		}
+		item.NextRunAt = run.NextRunAt
+		if !created {
+			continue
+		}
+		s.metrics.invoicesCreated.Inc()
+		if err := s.recordAudit(ctx, item.TenantID, "", "invoice.created", "invoice", generatedInvoice.ID, fmt.Sprintf("Created invoice %s from recurring invoice %s for %s", invoiceLabel(generatedInvoice), item.ID, run.Period)); err != nil {
+			return generated, err
+		}
+		if err := s.publishWebhookEvent(ctx, item.TenantID, EventInvoiceCreated, generatedInvoice); err != nil {
+			return generated, err
+		}
+		generated++
+	}
+}
+
+// pauseRecurringInvoice stops a schedule whose customer has been deleted,
+// rather than billing an invoice nobody can be sent. Resuming it once the
+// customer is back skips the periods missed meanwhile.
+func (s *Server) pauseRecurringInvoice(ctx context.Context, item RecurringInvoice, now time.Time) error {
+	s.logger.WarnContext(ctx, "recurring invoice paused: customer not found", "recurring_invoice_id", item.ID, "customer_id", item.CustomerID)
+	item.Status = RecurringPaused
+	item.UpdatedAt = now.Format(time.RFC3339)
+	if err := s.store.UpdateRecurringInvoice(ctx, item); err != nil {
+		return err
+	}
+	return s.recordAudit(ctx, item.TenantID, "", "recurring_invoice.pause", "recurring_invoice", item.ID, fmt.Sprintf("Paused recurring invoice: customer %s no longer exists", item.CustomerID))
+}
+
+// materializeRecurringInvoice builds the invoice for the run at runAt.
+func (s *Server) materializeRecurringInvoice(item RecurringInvoice, runAt time.Time, taxExempt bool) (invoice.Invoice, error) {
+	now := utcNow()
+	generated := invoice.Invoice{
+		ID:          s.newID("inv"),
+		TenantID:    item.TenantID,
+		CustomerID:  item.CustomerID,
+		Currency:    item.Currency,
+		TaxRounding: item.TaxRounding,
+		TaxExempt:   taxExempt,
+		Status:      item.InvoiceStatus,
+		IssuedAt:    runAt.Format(time.RFC3339),
+		CreatedAt:   now,
+		UpdatedAt:   now,
+		Version:     1,
+	}
+	if item.DueDays > 0 {
+		generated.DueAt = runAt.AddDate(0, 0, item.DueDays).Format(time.RFC3339)
+	}
+	for i, line := range item.Items {
+		lineItem, err := s.newLineItem(generated.ID, line, now)
+		if err != nil {
+			var fieldErr *invoice.ValidationError
+			if errors.As(err, &fieldErr) {
+				err = &invoice.ValidationError{Field: fmt.Sprintf("items[%d].%s", i, fieldErr.Field), Message: fieldErr.Message}
+			}
+			return invoice.Invoice{}, err
+		}
+		lineItem.Position = i + 1
+		generated.Items = append(generated.Items, lineItem)
+	}
+	generated.ComputeTax()
+	if err := generated.ValidateUnnumbered(); err != nil {
+		return invoice.Invoice{}, err
+	}
+	return generated, nil
+}
+
+// RunRecurringScheduler calls GenerateRecurringInvoices at startup and then
+// every interval until ctx is done.
+func (s *Server) RunRecurringScheduler(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = defaultRecurringScanInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		generated, err := s.GenerateRecurringInvoices(ctx)
+		if err != nil && ctx.Err() == nil {
+			s.logger.ErrorContext(ctx, "generate recurring invoices", "error", err)
+		}
+		if generated > 0 {
+			s.logger.InfoContext(ctx, "generated recurring invoices", "count", generated)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,212 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/apierror"
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/invoice"
+)
+
+func TestGenerateRecurringInvoicesBillsEachPeriodOnce(t *testing.T) {
+	server := NewServer()
+	cookie := loginForTest(t, server)
+	hook := createWebhookForTest(t, server, cookie, WebhookSubscriptionCreateRequest{URL: "https://hooks.example.com/recurring", Events: []string{EventInvoiceCreated}})
+
+	thisMonth := time.Now().UTC().AddDate(0, 0, 1-time.Now().UTC().Day()).Truncate(24 * time.Hour)
+	start := thisMonth.AddDate(0, -2, 0)
+	created := createRecurringInvoiceForTest(t, server, cookie, RecurringInvoiceCreateRequest{
+		CustomerID:    "cust-1",
+		Currency:      "ils",
+		Items:         []InvoiceLineItemRequest{{Description: "Hosting", Quantity: 1, UnitPrice: 10000, TaxRate: 1700}},
+		InvoiceStatus: invoice.StatusOpen,
+		DueDays:       14,
+		Recurrence:    invoice.Recurrence{Frequency: invoice.FrequencyMonthly, StartDate: start.Format(time.DateOnly)},
+	})
+	if created.Status != RecurringActive || created.Recurrence.Interval != 1 || created.Recurrence.AnchorDay != 1 || created.NextRunAt != start.Format(time.RFC3339) {
+		t.Fatalf("unexpected recurring invoice %#v", created)
+	}
+
+	// Two months behind: the start month, last month and this month are due.
+	if generated, err := server.GenerateRecurringInvoices(context.Background()); err != nil || generated != 3 {
+		t.Fatalf("expected three invoices generated, got %d, %v", generated, err)
+	}
+	if generated, err := server.GenerateRecurringInvoices(context.Background()); err != nil || generated != 0 {
+		t.Fatalf("expected a second scan to bill nothing, got %d, %v", generated, err)
+	}
+
+	items, err := server.store.ListInvoices(context.Background(), "tenant-alpha", invoice.ListFilter{CustomerID: "cust-1", Sort: invoice.SortIssuedAt})
+	if err != nil {
+		t.Fatalf("list invoices: %v", err)
+	}
+	if len(items) != 3 {
+		t.Fatalf("expected three invoices, got %d", len(items))
+	}
+	for i, item := range items {
+		issuedAt := start.AddDate(0, i, 0)
+		if item.Status != invoice.StatusOpen || item.Currency != "ILS" || item.Total != 11700 || item.IssuedAt != issuedAt.Format(time.RFC3339) || item.DueAt != issuedAt.AddDate(0, 0, 14).Format(time.RFC3339) {
+			t.Fatalf("unexpected invoice %d: %#v", i, item)
+		}
+	}
+
+	current := getRecurringInvoiceForTest(t, server, cookie, created.ID)
+	if current.NextRunAt != thisMonth.AddDate(0, 1, 0).Format(time.RFC3339) || current.LastRunAt != thisMonth.Format(time.RFC3339) || current.LastInvoiceID != items[2].ID {
+		t.Fatalf("expected the next run next month, got %#v", current)
+	}
+	if deliveries := listDeliveriesForTest(t, server, cookie, hook.ID); len(deliveries) != 3 {
+		t.Fatalf("expected three invoice.created deliveries, got %d", len(deliveries))
+	}
+
+	// A worker that read the recurring invoice before the last run was
+	// recorded must not bill this month again.
+	current.NextRunAt = thisMonth.Format(time.RFC3339)
+	if err := server.store.UpdateRecurringInvoice(context.Background(), current); err != nil {
+		t.Fatalf("rewind recurring invoice: %v", err)
+	}
+	if generated, err := server.GenerateRecurringInvoices(context.Background()); err != nil || generated != 0 {
+		t.Fatalf("expected a billed period to be skipped, got %d, %v", generated, err)
+	}
+	if rewound := getRecurringInvoiceForTest(t, server, cookie, created.ID); rewound.NextRunAt != thisMonth.AddDate(0, 1, 0).Format(time.RFC3339) {
+		t.Fatalf("expected the skipped period to advance the schedule, got %s", rewound.NextRunAt)
+	}
+}
+
+func TestRecurringInvoicePauseAndResume(t *testing.T) {
+	server := NewServer()
+	cookie := loginForTest(t, server)
+	start := time.Now().UTC().Truncate(24*time.Hour).AddDate(0, 0, -14)
+	created := createRecurringInvoiceForTest(t, server, cookie, RecurringInvoiceCreateRequest{
+		CustomerID: "cust-1",
+		Currency:   "ILS",
+		Items:      []InvoiceLineItemRequest{{Description: "Support", Quantity: 2, UnitPrice: 5000}},
+		Recurrence: invoice.Recurrence{Frequency: invoice.FrequencyWeekly, StartDate: start.Format(time.DateOnly)},
+	})
+	if created.InvoiceStatus != invoice.StatusDraft {
+		t.Fatalf("expected draft invoices by default, got %s", created.InvoiceStatus)
+	}
+
+	rec := doJSON(t, server, cookie, http.MethodPost, "/v1/recurring-invoices/"+created.ID+"/pause", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected pause 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if generated, err := server.GenerateRecurringInvoices(context.Background()); err != nil || generated != 0 {
+		t.Fatalf("expected a paused recurring invoice to bill nothing, got %d, %v", generated, err)
+	}
+
+	rec = doJSON(t, server, cookie, http.MethodPost, "/v1/recurring-invoices/"+created.ID+"/resume", nil)
+	var resumed RecurringInvoice
+	if err := json.NewDecoder(rec.Body).Decode(&resumed); err != nil {
+		t.Fatalf("decode recurring invoice: %v", err)
+	}
+	nextRunAt, _ := time.Parse(time.RFC3339, resumed.NextRunAt)
+	if rec.Code != http.StatusOK || resumed.Status != RecurringActive || !nextRunAt.After(time.Now()) || nextRunAt.Sub(start)%(7*24*time.Hour) != 0 {
+		t.Fatalf("expected resume to skip to the next weekly run, got %d %#v", rec.Code, resumed)
+	}
+	if generated, err := server.GenerateRecurringInvoices(context.Background()); err != nil || generated != 0 {
+		t.Fatalf("expected runs missed while paused to be skipped, got %d, %v", generated, err)
+	}
+
+	events, err := server.store.ListAuditEvents(context.Background(), "tenant-alpha", "recurring_invoice", created.ID)
+	if err != nil {
+		t.Fatalf("list audit events: %v", err)
+	}
+	if len(events) != 3 {
+		t.Fatalf("expected created, paused and resumed audit events, got %#v", events)
+	}
+}
+
+func TestRecurringInvoicePausesWhenItsCustomerIsDeleted(t *testing.T) {
+	server := NewServer()
+	cookie := loginForTest(t, server)
+	created := createRecurringInvoiceForTest(t, server, cookie, RecurringInvoiceCreateRequest{
+		CustomerID: "cust-1",
+		Currency:   "ILS",
+		Items:      []InvoiceLineItemRequest{{Description: "Support", Quantity: 1, UnitPrice: 5000}},
+		Recurrence: invoice.Recurrence{Frequency: invoice.FrequencyWeekly, StartDate: time.Now().UTC().AddDate(0, 0, -1).Format(time.DateOnly)},
+	})
+	if rec := doJSON(t, server, cookie, http.MethodDelete, "/v1/customers/cust-1", nil); rec.Code != http.StatusNoContent {
+		t.Fatalf("expected customer delete 204, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	if generated, err := server.GenerateRecurringInvoices(context.Background()); err != nil || generated != 0 {
+		t.Fatalf("expected nothing billed for a deleted customer, got %d, %v", generated, err)
+	}
+	if paused := getRecurringInvoiceForTest(t, server, cookie, created.ID); paused.Status != RecurringPaused || paused.NextRunAt != created.NextRunAt {
+		t.Fatalf("expected the schedule paused at its due run, got %#v", paused)
+	}
+	items, err := server.store.ListInvoices(context.Background(), "tenant-alpha", invoice.ListFilter{CustomerID: "cust-1"})
+	if err != nil || len(items) != 0 {
+		t.Fatalf("expected no invoices, got %d, %v", len(items), err)
+	}
+	events, err := server.store.ListAuditEvents(context.Background(), "tenant-alpha", "recurring_invoice", created.ID)
+	if err != nil || len(events) != 2 {
+		t.Fatalf("expected created and paused audit events, got %#v, %v", events, err)
+	}
+}
+
+func TestRecurringInvoiceValidation(t *testing.T) {
+	server := NewServer()
+	cookie := loginForTest(t, server)
+	valid := func() RecurringInvoiceCreateRequest {
+		return RecurringInvoiceCreateRequest{
+			CustomerID: "cust-1",
+			Currency:   "ILS",
+			Items:      []InvoiceLineItemRequest{{Description: "Hosting", Quantity: 1, UnitPrice: 100}},
+			Recurrence: invoice.Recurrence{Frequency: invoice.FrequencyMonthly, StartDate: "2026-01-31"},
+		}
+	}
+	cases := map[string]func(*RecurringInvoiceCreateRequest){
+		"items":                func(r *RecurringInvoiceCreateRequest) { r.Items = nil },
+		"items[0].quantity":    func(r *RecurringInvoiceCreateRequest) { r.Items[0].Quantity = 0 },
+		"invoiceStatus":        func(r *RecurringInvoiceCreateRequest) { r.InvoiceStatus = invoice.StatusPaid },
+		"dueDays":              func(r *RecurringInvoiceCreateRequest) { r.DueDays = -1 },
+		"currency":             func(r *RecurringInvoiceCreateRequest) { r.Currency = "" },
+		"recurrence.frequency": func(r *RecurringInvoiceCreateRequest) { r.Recurrence.Frequency = "daily" },
+		"recurrence.anchorDay": func(r *RecurringInvoiceCreateRequest) { r.Recurrence.AnchorDay = 32 },
+		"recurrence.startDate": func(r *RecurringInvoiceCreateRequest) { r.Recurrence.StartDate = "" },
+	}
+	for field, mutate := range cases {
+		req := valid()
+		mutate(&req)
+		rec := doJSON(t, server, cookie, http.MethodPost, "/v1/recurring-invoices", req)
+		var body apierror.Error
+		if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+			t.Fatalf("%s: decode error: %v", field, err)
+		}
+		if _, ok := body.Details[field]; rec.Code != http.StatusBadRequest || !ok {
+			t.Fatalf("%s: expected 400 naming the field, got %d %#v", field, rec.Code, body.Details)
+		}
+	}
+}
+
+func createRecurringInvoiceForTest(t *testing.T, server *Server, cookie *http.Cookie, req RecurringInvoiceCreateRequest) RecurringInvoice {
+	t.Helper()
+
+	seedCustomersForTest(t, server, "tenant-alpha", req.CustomerID)
+	rec := doJSON(t, server, cookie, http.MethodPost, "/v1/recurring-invoices", req)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected create 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var item RecurringInvoice
+	if err := json.NewDecoder(rec.Body).Decode(&item); err != nil {
+		t.Fatalf("decode recurring invoice: %v", err)
+	}
+	return item
+}
+
+func getRecurringInvoiceForTest(t *testing.T, server *Server, cookie *http.Cookie, id string) RecurringInvoice {
+	t.Helper()
+
+	rec := doJSON(t, server, cookie, http.MethodGet, "/v1/recurring-invoices/"+id, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected get 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var item RecurringInvoice
+	if err := json.NewDecoder(rec.Body).Decode(&item); err != nil {
+		t.Fatalf("decode recurring invoice: %v", err)
+	}
+	return item
+}
@@ -0,0 +1,104 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/apierror"
+)
+
+const defaultMaxBodyBytes int64 = 1 << 20
+
+// bodyError is a request body the server refuses to read, with the status
+// and error code that say why.
+type bodyError struct {
+	status  int
+	code    string
+	message string
+}
+
+func (e *bodyError) Error() string {
+	return e.message
+}
+
+// limitRequestBody caps every request body at the configured size and
+// requires JSON on writes that carry a body. Attachment uploads and invoice
+// imports are the exception: they are multipart and may be as large as an
+// attachment. It reports false once it has written a rejection.
+func (s *Server) limitRequestBody(w http.ResponseWriter, r *http.Request) bool {
+	if r.Body == nil || r.Body == http.NoBody {
+		return true
+	}
+	limit, wantType := s.maxBodyBytes, "application/json"
+	if isFileUpload(r) {
+		limit, wantType = s.maxAttachmentBytes+multipartOverhead, "multipart/form-data"
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, limit)
+	switch r.Method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch:
+	default:
+		return true
+	}
+	if r.ContentLength == 0 {
+		return true
+	}
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || mediaType != wantType {
+		apierror.WriteError(w, http.StatusUnsupportedMediaType, apierror.New(apierror.CodeUnsupportedMediaType, "Content-Type must be "+wantType))
+		return false
+	}
+	return true
+}
+
+// decodeJSON reads exactly one JSON value into dst. Unknown fields are
+// rejected so a misspelt field name fails loudly instead of being dropped.
+func decodeJSON(r *http.Request, dst any) error {
+	defer r.Body.Close()
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(dst); err != nil {
+		return jsonBodyError(err)
+	}
+	if err := decoder.Decode(&json.RawMessage{}); !errors.Is(err, io.EOF) {
+		var maxBytes *http.MaxBytesError
+		if errors.As(err, &maxBytes) {
+			return jsonBodyError(err)
+		}
+		return &bodyError{status: http.StatusBadRequest, code: apierror.CodeInvalidJSON, message: "invalid JSON body: unexpected data after the JSON value"}
+	}
+	return nil
+}
+
+func jsonBodyError(err error) *bodyError {
+	var maxBytes *http.MaxBytesError
+	var syntax *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &maxBytes):
+		return &bodyError{status: http.StatusRequestEntityTooLarge, code: apierror.CodePayloadTooLarge, message: fmt.Sprintf("request body must be at most %d bytes", maxBytes.Limit)}
+	case errors.Is(err, io.EOF):
+		return &bodyError{status: http.StatusBadRequest, code: apierror.CodeInvalidJSON, message: "invalid JSON body: body is empty"}
+	case errors.As(err, &syntax), errors.Is(err, io.ErrUnexpectedEOF):
+		return &bodyError{status: http.StatusBadRequest, code: apierror.CodeInvalidJSON, message: "invalid JSON body: malformed JSON"}
+	case errors.As(err, &typeErr) && typeErr.Field != "":
+		return &bodyError{status: http.StatusBadRequest, code: apierror.CodeInvalidJSON, message: fmt.Sprintf("invalid JSON body: %s must be %s", typeErr.Field, typeErr.Type)}
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		return &bodyError{status: http.StatusBadRequest, code: apierror.CodeInvalidJSON, message: "invalid JSON body: unknown field " + strings.TrimPrefix(err.Error(), "json: unknown field ")}
+	}
+	return &bodyError{status: http.StatusBadRequest, code: apierror.CodeInvalidJSON, message: "invalid JSON body"}
+}
+
+// writeBodyError answers a failed decodeJSON with 413 for oversized bodies
+// and 400 for everything else.
+func writeBodyError(w http.ResponseWriter, err error) {
+	var bodyErr *bodyError
+	if !errors.As(err, &bodyErr) {
+		bodyErr = &bodyError{status: http.StatusBadRequest, code: apierror.CodeInvalidJSON, message: err.Error()}
+	}
+	apierror.WriteError(w, bodyErr.status, apierror.New(bodyErr.code, bodyErr.message))
+}
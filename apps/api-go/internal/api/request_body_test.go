@@ -0,0 +1,72 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/apierror"
+)
+
+func TestRequestBodyValidation(t *testing.T) {
+	server := NewServer(WithMaxBodyBytes(256))
+	cookie := loginForTest(t, server)
+	seedCustomersForTest(t, server, "tenant-alpha", "cust-1")
+	send := func(contentType, body string, headers ...string) (int, string) {
+		req := httptest.NewRequest(http.MethodPost, "/v1/invoices", strings.NewReader(body))
+		req.AddCookie(cookie)
+		if contentType != "" {
+			req.Header.Set("Content-Type", contentType)
+		}
+		for i := 0; i+1 < len(headers); i += 2 {
+			req.Header.Set(headers[i], headers[i+1])
+		}
+		rec := httptest.NewRecorder()
+		server.Handler().ServeHTTP(rec, req)
+		var resp apierror.Error
+		_ = json.NewDecoder(rec.Body).Decode(&resp)
+		return rec.Code, resp.Message
+	}
+	valid := `{"customerId":"cust-1","currency":"ILS"}`
+	huge := `{"customerId":"` + strings.Repeat("x", 300) + `","currency":"ILS"}`
+
+	cases := []struct {
+		name        string
+		contentType string
+		body        string
+		headers     []string
+		status      int
+		message     string
+	}{
+		{"json with charset", "application/json; charset=utf-8", valid, nil, http.StatusCreated, ""},
+		{"missing content type", "", valid, nil, http.StatusUnsupportedMediaType, "Content-Type must be application/json"},
+		{"form content type", "application/x-www-form-urlencoded", "customerId=cust-1", nil, http.StatusUnsupportedMediaType, "Content-Type must be application/json"},
+		{"too large", "application/json", huge, nil, http.StatusRequestEntityTooLarge, "request body must be at most 256 bytes"},
+		{"too large with idempotency key", "application/json", huge, []string{"Idempotency-Key", "big-1"}, http.StatusRequestEntityTooLarge, "request body must be at most 256 bytes"},
+		{"unknown field", "application/json", `{"customerId":"cust-1","currency":"ILS","totl":5}`, nil, http.StatusBadRequest, `invalid JSON body: unknown field "totl"`},
+		{"malformed", "application/json", `{"customerId":`, nil, http.StatusBadRequest, "invalid JSON body: malformed JSON"},
+		{"wrong type", "application/json", `{"customerId":"cust-1","currency":"ILS","total":"5"}`, nil, http.StatusBadRequest, "invalid JSON body: total must be int64"},
+		{"trailing data", "application/json", valid + `{}`, nil, http.StatusBadRequest, "invalid JSON body: unexpected data after the JSON value"},
+	}
+	for _, tc := range cases {
+		status, message := send(tc.contentType, tc.body, tc.headers...)
+		if status != tc.status || (tc.message != "" && message != tc.message) {
+			t.Errorf("%s: expected %d %q, got %d %q", tc.name, tc.status, tc.message, status, message)
+		}
+	}
+}
+
+func TestBodylessWritesNeedNoContentType(t *testing.T) {
+	server := NewServer()
+	cookie := loginForTest(t, server)
+
+	req := httptest.NewRequest(http.MethodPost, "/auth/logout", nil)
+	req.AddCookie(cookie)
+	rec := httptest.NewRecorder()
+	server.Handler().ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected logout without a body 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
@@ -0,0 +1,83 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/apierror"
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/invoice"
+)
+
+// InvoiceSearchResults is a page of search results. Mode says how the query
+// was matched, so clients know whether to highlight words or substrings.
+type InvoiceSearchResults struct {
+	Items      []invoice.SearchResult `json:"items"`
+	Mode       invoice.SearchMode     `json:"mode"`
+	NextCursor string                 `json:"nextCursor,omitempty"`
+}
+
+// handleInvoiceSearch serves GET /v1/invoices/search?q=. A full-text search
+// that finds nothing on its first page is retried as a substring search, so
+// partial words and invoice number fragments still find something.
+func (s *Server) handleInvoiceSearch(w http.ResponseWriter, r *http.Request, session Session) {
+	if r.Method != http.MethodGet {
+		apierror.WriteError(w, http.StatusMethodNotAllowed, errMethodNotAllowed)
+		return
+	}
+	query, err := parseInvoiceSearchQuery(r.URL.Query())
+	if err != nil {
+		writeValidationError(w, err)
+		return
+	}
+	pageSize := query.Limit
+	query.Limit = pageSize + 1
+	results, err := s.store.SearchInvoices(r.Context(), session.TenantID, query)
+	if err == nil && len(results) == 0 && query.Offset == 0 && query.Mode == invoice.SearchFullText {
+		query.Mode = invoice.SearchSubstring
+		results, err = s.store.SearchInvoices(r.Context(), session.TenantID, query)
+	}
+	if err != nil {
+		s.writeInternalError(w, err)
+		return
+	}
+	page := InvoiceSearchResults{Items: results, Mode: query.Mode}
+	if len(results) > pageSize {
+		page.Items = results[:pageSize]
+		page.NextCursor = invoice.SearchCursor{Text: query.Text, Mode: query.Mode, Offset: query.Offset + pageSize}.Encode()
+	}
+	writeJSON(w, http.StatusOK, page)
+}
+
+func parseInvoiceSearchQuery(values url.Values) (invoice.SearchQuery, error) {
+	query := invoice.SearchQuery{
+		Text:  strings.TrimSpace(values.Get("q")),
+		Limit: invoice.DefaultListLimit,
+	}
+	switch length := utf8.RuneCountInString(query.Text); {
+	case length == 0:
+		return query, apierror.Field("q", "is required")
+	case length > invoice.MaxSearchLength:
+		return query, apierror.Field("q", fmt.Sprintf("must be at most %d characters", invoice.MaxSearchLength))
+	}
+	query.Mode = invoice.SearchModeFor(query.Text)
+	if value := values.Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit <= 0 {
+			return query, apierror.Field("limit", "must be a positive integer")
+		}
+		query.Limit = min(limit, invoice.MaxListLimit)
+	}
+	if value := values.Get("cursor"); value != "" {
+		cursor, err := invoice.DecodeSearchCursor(value, query.Text)
+		if err != nil {
+			return query, err
+		}
+		query.Mode = cursor.Mode
+		query.Offset = cursor.Offset
+	}
+	return query, nil
+}
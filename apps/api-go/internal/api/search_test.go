@@ -0,0 +1,152 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/invoice"
+)
+
+func TestInvoiceSearchRanksAndReportsMatchedFields(t *testing.T) {
+	server := NewServer()
+	cookie := loginForTest(t, server)
+	customer := createCustomerForTest(t, server, cookie, CustomerCreateRequest{Name: "Acme Widgets Ltd"})
+	byNumber := createInvoiceForTest(t, server, cookie, InvoiceCreateRequest{Number: "WIDGETS-1", CustomerID: "cust-other", Currency: "ILS"})
+	byCustomer := createInvoiceForTest(t, server, cookie, InvoiceCreateRequest{Number: "INV-0002", CustomerID: customer.ID, Currency: "ILS"})
+	byItem := createInvoiceForTest(t, server, cookie, InvoiceCreateRequest{Number: "INV-0003", CustomerID: "cust-other", Currency: "ILS", Items: []InvoiceLineItemRequest{
+		{Description: "Blue widgets", Quantity: 2, UnitPrice: 500},
+		{Description: "Shipping", Quantity: 1, UnitPrice: 100},
+	}})
+	createInvoiceForTest(t, server, cookie, InvoiceCreateRequest{Number: "INV-0004", CustomerID: "cust-other", Currency: "ILS"})
+
+	page := searchInvoicesForTest(t, server, cookie, url.Values{"q": {"widgets"}})
+	if page.Mode != invoice.SearchFullText || len(page.Items) != 3 {
+		t.Fatalf("expected three full-text results, got %s %#v", page.Mode, page.Items)
+	}
+	order := []string{byNumber.ID, byCustomer.ID, byItem.ID}
+	for i, result := range page.Items {
+		if result.Invoice.ID != order[i] {
+			t.Fatalf("result %d: expected %s, got %s", i, order[i], result.Invoice.ID)
+		}
+	}
+	wantMatches := map[string]invoice.SearchMatch{
+		byNumber.ID:   {Field: invoice.SearchFieldNumber, Value: "WIDGETS-1"},
+		byCustomer.ID: {Field: invoice.SearchFieldCustomerName, Value: "Acme Widgets Ltd"},
+		byItem.ID:     {Field: invoice.SearchFieldItem, Value: "Blue widgets"},
+	}
+	for _, result := range page.Items {
+		if len(result.Matches) != 1 || result.Matches[0] != wantMatches[result.Invoice.ID] {
+			t.Fatalf("%s: expected match %#v, got %#v", result.Invoice.ID, wantMatches[result.Invoice.ID], result.Matches)
+		}
+		if result.Invoice.Items != nil {
+			t.Fatalf("expected search results without line items, got %#v", result.Invoice.Items)
+		}
+	}
+}
+
+func TestInvoiceSearchFallsBackToSubstringMatching(t *testing.T) {
+	server := NewServer()
+	cookie := loginForTest(t, server)
+	created := createInvoiceForTest(t, server, cookie, InvoiceCreateRequest{Number: "INV-20260042", CustomerID: "cust-1", Currency: "ILS"})
+
+	for _, q := range []string{"42", "2026004"} {
+		page := searchInvoicesForTest(t, server, cookie, url.Values{"q": {q}})
+		if page.Mode != invoice.SearchSubstring || len(page.Items) != 1 || page.Items[0].Invoice.ID != created.ID {
+			t.Fatalf("q=%s: expected a substring match, got %s %#v", q, page.Mode, page.Items)
+		}
+	}
+	if page := searchInvoicesForTest(t, server, cookie, url.Values{"q": {"globex"}}); len(page.Items) != 0 {
+		t.Fatalf("expected no results, got %#v", page.Items)
+	}
+}
+
+func TestInvoiceSearchPaginates(t *testing.T) {
+	server := NewServer()
+	cookie := loginForTest(t, server)
+	for i := range 5 {
+		createInvoiceForTest(t, server, cookie, InvoiceCreateRequest{Number: fmt.Sprintf("ACME-%d", i), CustomerID: "cust-1", Currency: "ILS"})
+	}
+
+	seen := map[string]bool{}
+	query := url.Values{"q": {"acme"}, "limit": {"2"}}
+	for pages := 0; ; pages++ {
+		page := searchInvoicesForTest(t, server, cookie, query)
+		for _, result := range page.Items {
+			seen[result.Invoice.ID] = true
+		}
+		if page.NextCursor == "" {
+			if pages != 2 {
+				t.Fatalf("expected three pages, got %d", pages+1)
+			}
+			break
+		}
+		query.Set("cursor", page.NextCursor)
+	}
+	if len(seen) != 5 {
+		t.Fatalf("expected every invoice once, got %d", len(seen))
+	}
+
+	query.Set("q", "globex")
+	if rec := doJSON(t, server, cookie, http.MethodGet, "/v1/invoices/search?"+query.Encode(), nil); rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected a cursor from another query to be rejected, got %d", rec.Code)
+	}
+}
+
+func TestInvoiceSearchRejectsInvalidQueries(t *testing.T) {
+	server := NewServer()
+	cookie := loginForTest(t, server)
+
+	for name, query := range map[string]url.Values{
+		"missing q": {},
+		"blank q":   {"q": {"   "}},
+		"long q":    {"q": {strings.Repeat("a", invoice.MaxSearchLength+1)}},
+		"bad limit": {"q": {"acme"}, "limit": {"0"}},
+	} {
+		if rec := doJSON(t, server, cookie, http.MethodGet, "/v1/invoices/search?"+query.Encode(), nil); rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", name, rec.Code)
+		}
+	}
+	if rec := doJSON(t, server, cookie, http.MethodPost, "/v1/invoices/search?q=acme", nil); rec.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("expected POST 405, got %d", rec.Code)
+	}
+}
+
+func TestInvoiceSearchIsScopedToOrg(t *testing.T) {
+	key, server := newBearerTestServer(t)
+	orgA := signBearerForTest(t, key, "user-a", "org-a", time.Hour)
+	orgB := signBearerForTest(t, key, "user-b", "org-b", time.Hour)
+	seedCustomersForTest(t, server, "org-a", "cust-1")
+	if rec := doBearer(t, server, http.MethodPost, "/v1/invoices", orgA, InvoiceCreateRequest{Number: "ACME-1", CustomerID: "cust-1", Currency: "ILS"}); rec.Code != http.StatusCreated {
+		t.Fatalf("expected create 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	for token, want := range map[string]int{orgA: 1, orgB: 0} {
+		rec := doBearer(t, server, http.MethodGet, "/v1/invoices/search?q=acme", token, nil)
+		var page InvoiceSearchResults
+		if err := json.NewDecoder(rec.Body).Decode(&page); err != nil {
+			t.Fatalf("decode search results: %v", err)
+		}
+		if rec.Code != http.StatusOK || len(page.Items) != want {
+			t.Fatalf("expected %d results, got %d %#v", want, rec.Code, page.Items)
+		}
+	}
+}
+
+func searchInvoicesForTest(t *testing.T, server *Server, cookie *http.Cookie, query url.Values) InvoiceSearchResults {
+	t.Helper()
+
+	rec := doJSON(t, server, cookie, http.MethodGet, "/v1/invoices/search?"+query.Encode(), nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected search 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var page InvoiceSearchResults
+	if err := json.NewDecoder(rec.Body).Decode(&page); err != nil {
+		t.Fatalf("decode search results: %v", err)
+	}
+	return page
+}
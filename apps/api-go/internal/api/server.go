@@ -7,19 +7,78 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"log/slog"
 	"net/http"
 	"strings"
+	"sync"
 	"sync/atomic"
 	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/apierror"
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/auth"
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/invoice"
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/logging"
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/notify"
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/payments"
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/pdf"
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/ratelimit"
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/webhook"
 )
 
 const sessionCookieName = "invplatform_session"
 
 type Server struct {
 	idCounter        uint64
+	draining         atomic.Bool
 	store            Store
 	collectionRunner CollectionRunner
 	reportRunner     ReportRunner
+	pdfRenderer      pdf.Renderer
+	idempotencyTTL   time.Duration
+	background       sync.WaitGroup
+	logger           *slog.Logger
+	readinessChecks  []ReadinessCheck
+	readinessTimeout time.Duration
+	metrics          *serverMetrics
+	tokenVerifier    *auth.Verifier
+	webhookSender    webhook.Sender
+	webhookRetry     webhook.RetryPolicy
+	rateLimiter      *ratelimit.Limiter
+	maxBodyBytes     int64
+	corsOrigins      map[string]bool
+	emailSender      notify.EmailSender
+	dunningSchedule  []int
+	objectStore      ObjectStore
+	// maxAttachmentBytes and attachmentTypes limit uploaded attachments.
+	maxAttachmentBytes int64
+	attachmentTypes    map[string]bool
+	summaryCache       *summaryCache
+	requestTimeout     time.Duration
+	poolStats          func() PoolStats
+	invoiceNumbers     invoice.NumberFormat
+	paymentProvider    payments.Provider
+	// shareLinkSecret signs invoice share links, which are prefixed with
+	// publicBaseURL.
+	shareLinkSecret []byte
+	publicBaseURL   string
+	// jobWatchers wakes collection job event streams, which send a
+	// heartbeat every eventHeartbeat and reread the job every
+	// eventPollInterval.
+	jobWatchers       *jobWatchers
+	eventHeartbeat    time.Duration
+	eventPollInterval time.Duration
+	// deletedRetention is how long soft-deleted invoices and customers are
+	// kept before the purge removes them, and auditRetention how long audit
+	// entries are.
+	deletedRetention time.Duration
+	auditRetention   time.Duration
+	// startedAt is when the server was built, which /info reports the
+	// uptime from.
+	startedAt time.Time
+	// allowPrivateWebhooks skips the subscription URL address check, so
+	// tests can subscribe receivers listening on loopback.
+	allowPrivateWebhooks bool
 }
 
 type Session struct {
@@ -56,10 +115,6 @@ type MessageResponse struct {
 	Message string `json:"message"`
 }
 
-type ErrorResponse struct {
-	Error string `json:"error"`
-}
-
 type HealthResponse struct {
 	Status string `json:"status"`
 }
@@ -110,6 +165,7 @@ type CollectionJob struct {
 	ID              string   `json:"id"`
 	TenantID        string   `json:"tenantId"`
 	Status          string   `json:"status"`
+	Attempt         int      `json:"attempt"`
 	Providers       []string `json:"providers"`
 	Month           int      `json:"month"`
 	Year            int      `json:"year"`
@@ -122,6 +178,7 @@ type CollectionJob struct {
 	InvoicesDir     string   `json:"invoicesDir,omitempty"`
 	RetryOf         string   `json:"retryOf,omitempty"`
 	Error           string   `json:"error,omitempty"`
+	QueuedAt        string   `json:"queuedAt,omitempty"`
 	StartedAt       string   `json:"startedAt,omitempty"`
 	FinishedAt      string   `json:"finishedAt,omitempty"`
 	CreatedAt       string   `json:"createdAt"`
@@ -241,9 +298,31 @@ var routeContextKey = contextKey("route-context")
 
 func NewServer(opts ...Option) *Server {
 	server := &Server{
-		store:            NewMemoryStore(),
-		collectionRunner: noopCollectionRunner{},
-		reportRunner:     noopReportRunner{},
+		store:              NewMemoryStore(),
+		collectionRunner:   noopCollectionRunner{},
+		reportRunner:       noopReportRunner{},
+		pdfRenderer:        pdf.NewRenderer(pdf.Config{}),
+		idempotencyTTL:     defaultIdempotencyTTL,
+		logger:             slog.Default(),
+		readinessTimeout:   defaultReadinessCheckTimeout,
+		metrics:            newServerMetrics(prometheus.NewRegistry()),
+		webhookSender:      webhook.NewHTTPSender(webhookSendTimeout),
+		webhookRetry:       webhook.DefaultRetryPolicy,
+		maxBodyBytes:       defaultMaxBodyBytes,
+		emailSender:        notify.NoopSender{},
+		dunningSchedule:    defaultDunningSchedule,
+		objectStore:        NewMemoryObjectStore(),
+		maxAttachmentBytes: defaultMaxAttachmentBytes,
+		attachmentTypes:    contentTypeSet(defaultAttachmentContentTypes),
+		summaryCache:       newSummaryCache(defaultSummaryCacheTTL),
+		requestTimeout:     defaultRequestTimeout,
+		invoiceNumbers:     invoice.DefaultNumberFormat,
+		jobWatchers:        newJobWatchers(),
+		eventHeartbeat:     defaultEventHeartbeat,
+		eventPollInterval:  defaultEventPollInterval,
+		deletedRetention:   defaultDeletedRetention,
+		auditRetention:     defaultAuditRetention,
+		startedAt:          time.Now(),
 	}
 	for _, opt := range opts {
 		if opt != nil {
@@ -253,49 +332,77 @@ func NewServer(opts ...Option) *Server {
 	return server
 }
 
+// WaitForBackground blocks until background work such as retried collection
+// jobs has finished or ctx is done.
+func (s *Server) WaitForBackground(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		s.background.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// BeginShutdown flips /readyz to 503 so load balancers stop routing new
+// traffic while in-flight requests drain, and ends open event streams,
+// whose clients reconnect to another instance.
+func (s *Server) BeginShutdown() {
+	s.draining.Store(true)
+	s.jobWatchers.close()
+}
+
 func (s *Server) Handler() http.Handler {
 	return s.withMiddleware(http.HandlerFunc(s.route))
 }
 
 func (s *Server) withMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		requestID := r.Header.Get("X-Request-ID")
-		if requestID == "" {
-			requestID = s.newID("req")
+	logged := s.withAccessLog(s.withMetrics(s.withCompression(s.withRecovery(s.withRequestTimeout(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !s.handleCORS(w, r) {
+			return
 		}
-		w.Header().Set("X-Request-ID", requestID)
-		s.applyCORS(w, r)
 		if r.Method == http.MethodOptions {
 			w.WriteHeader(http.StatusNoContent)
 			return
 		}
-		next.ServeHTTP(w, withRouteContext(r, routeContext{RequestID: requestID}))
+		if !s.limitRequestBody(w, r) {
+			return
+		}
+		next.ServeHTTP(w, r)
+	}))))))
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID := r.Header.Get("X-Request-ID")
+		if !validRequestID(requestID) {
+			requestID = s.newID("req")
+		}
+		w.Header().Set("X-Request-ID", requestID)
+		r = r.WithContext(logging.WithRequestID(r.Context(), requestID))
+		logged.ServeHTTP(w, withRouteContext(r, routeContext{RequestID: requestID}))
 	})
 }
 
-func (s *Server) applyCORS(w http.ResponseWriter, r *http.Request) {
-	origin := r.Header.Get("Origin")
-	allowed := map[string]bool{
-		"http://localhost:5173": true,
-		"http://127.0.0.1:5173": true,
-		"http://localhost:4173": true,
-		"http://127.0.0.1:4173": true,
-	}
-	if allowed[origin] {
-		w.Header().Set("Access-Control-Allow-Origin", origin)
-		w.Header().Set("Access-Control-Allow-Credentials", "true")
-		w.Header().Set("Vary", "Origin")
-	}
-	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, X-Request-ID")
-	w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PATCH, OPTIONS")
-}
-
 func (s *Server) route(w http.ResponseWriter, r *http.Request) {
 	switch {
 	case r.URL.Path == "/healthz" && r.Method == http.MethodGet:
 		writeJSON(w, http.StatusOK, HealthResponse{Status: "ok"})
+	case r.URL.Path == "/readyz" && r.Method == http.MethodGet:
+		s.handleReadyz(w, r)
+	case r.URL.Path == "/info" && r.Method == http.MethodGet:
+		s.requireSession(w, r, s.handleInfo)
+	case r.URL.Path == metricsPath && r.Method == http.MethodGet:
+		s.metrics.handler.ServeHTTP(w, r)
+	case r.URL.Path == "/openapi.json" && r.Method == http.MethodGet:
+		s.handleOpenAPI(w, r)
+	case r.URL.Path == "/docs" && r.Method == http.MethodGet:
+		s.handleDocs(w, r)
 	case r.URL.Path == "/auth/login" && r.Method == http.MethodPost:
-		s.handleLogin(w, r)
+		if s.allowRequest(w, clientIPKey(r)) {
+			s.handleLogin(w, r)
+		}
 	case r.URL.Path == "/auth/logout" && r.Method == http.MethodPost:
 		s.requireSession(w, r, s.handleLogout)
 	case r.URL.Path == "/auth/refresh" && r.Method == http.MethodPost:
@@ -318,21 +425,54 @@ func (s *Server) route(w http.ResponseWriter, r *http.Request) {
 		s.requireSession(w, r, s.handleSchedules)
 	case strings.HasPrefix(r.URL.Path, "/v1/schedules/"):
 		s.requireSession(w, r, s.handleScheduleDetail)
+	case r.URL.Path == "/v1/invoices":
+		s.requireSession(w, r, s.handleInvoices)
+	case r.URL.Path == "/v1/invoices/batch":
+		s.requireSession(w, r, s.handleInvoiceBatch)
+	case r.URL.Path == "/v1/invoices/export":
+		s.requireSession(w, r, s.handleInvoiceExport)
+	case r.URL.Path == "/v1/invoices/import":
+		s.requireSession(w, r, s.handleInvoiceImport)
+	case r.URL.Path == "/v1/invoices/summary":
+		s.requireSession(w, r, s.handleInvoiceSummary)
+	case r.URL.Path == "/v1/invoices/search":
+		s.requireSession(w, r, s.handleInvoiceSearch)
+	case strings.HasPrefix(r.URL.Path, "/v1/invoices/"):
+		s.requireSession(w, r, s.handleInvoiceDetail)
+	case r.URL.Path == "/v1/webhooks":
+		s.requireSession(w, r, s.handleWebhooks)
+	case strings.HasPrefix(r.URL.Path, "/v1/webhooks/"):
+		s.requireSession(w, r, s.handleWebhookDetail)
+	case r.URL.Path == "/v1/customers":
+		s.requireSession(w, r, s.handleCustomers)
+	case strings.HasPrefix(r.URL.Path, "/v1/customers/"):
+		s.requireSession(w, r, s.handleCustomerDetail)
+	case r.URL.Path == "/v1/recurring-invoices":
+		s.requireSession(w, r, s.handleRecurringInvoices)
+	case strings.HasPrefix(r.URL.Path, "/v1/recurring-invoices/"):
+		s.requireSession(w, r, s.handleRecurringInvoiceDetail)
 	case r.URL.Path == "/v1/audit-events" && r.Method == http.MethodGet:
 		s.requireSession(w, r, s.handleAuditEvents)
+	case strings.HasPrefix(r.URL.Path, paymentWebhooksPath):
+		s.handlePaymentWebhook(w, r)
+	case strings.HasPrefix(r.URL.Path, publicInvoicesPath):
+		s.handlePublicInvoice(w, r)
 	default:
-		writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "not found"})
+		apierror.WriteError(w, http.StatusNotFound, errRouteNotFound)
 	}
 }
 
 func (s *Server) handleLogin(w http.ResponseWriter, r *http.Request) {
 	var req LoginRequest
 	if err := decodeJSON(r, &req); err != nil {
-		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		writeBodyError(w, err)
 		return
 	}
 	if req.Email == "" || req.TenantID == "" {
-		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "email and tenantId are required"})
+		apierror.WriteError(w, http.StatusBadRequest, requiredFields("email and tenantId are required", map[string]bool{
+			"email":    req.Email == "",
+			"tenantId": req.TenantID == "",
+		}))
 		return
 	}
 
@@ -343,13 +483,7 @@ func (s *Server) handleLogin(w http.ResponseWriter, r *http.Request) {
 	}
 	permissions := req.Permissions
 	if len(permissions) == 0 {
-		permissions = []string{
-			"providers:read", "providers:write",
-			"collections:read", "collections:write",
-			"reports:read", "reports:write",
-			"schedules:read", "schedules:write",
-			"audit:read",
-		}
+		permissions = defaultPermissions()
 	}
 
 	session := Session{
@@ -379,6 +513,10 @@ func (s *Server) handleLogin(w http.ResponseWriter, r *http.Request) {
 }
 
 func (s *Server) handleLogout(w http.ResponseWriter, r *http.Request, session Session) {
+	if session.ID == "" {
+		apierror.WriteError(w, http.StatusBadRequest, apierror.New(apierror.CodeInvalidRequest, "bearer tokens are managed by their issuer"))
+		return
+	}
 	if err := s.store.DeleteSession(r.Context(), session.ID); err != nil {
 		s.writeInternalError(w, err)
 		return
@@ -395,6 +533,10 @@ func (s *Server) handleLogout(w http.ResponseWriter, r *http.Request, session Se
 }
 
 func (s *Server) handleRefresh(w http.ResponseWriter, r *http.Request, session Session) {
+	if session.ID == "" {
+		apierror.WriteError(w, http.StatusBadRequest, apierror.New(apierror.CodeInvalidRequest, "bearer tokens are managed by their issuer"))
+		return
+	}
 	refreshed := session
 	refreshed.LastSeenAt = utcNow()
 	if err := s.store.UpdateSession(r.Context(), refreshed); err != nil {
@@ -420,11 +562,11 @@ func (s *Server) handleProviderConfigs(w http.ResponseWriter, r *http.Request, s
 	case http.MethodPost:
 		var req ProviderConfigCreateRequest
 		if err := decodeJSON(r, &req); err != nil {
-			writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+			writeBodyError(w, err)
 			return
 		}
 		if req.Provider == "" {
-			writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "provider is required"})
+			apierror.WriteError(w, http.StatusBadRequest, apierror.Field("provider", "is required"))
 			return
 		}
 		now := utcNow()
@@ -450,7 +592,7 @@ func (s *Server) handleProviderConfigs(w http.ResponseWriter, r *http.Request, s
 		}
 		writeJSON(w, http.StatusCreated, item)
 	default:
-		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "method not allowed"})
+		apierror.WriteError(w, http.StatusMethodNotAllowed, errMethodNotAllowed)
 	}
 }
 
@@ -458,7 +600,7 @@ func (s *Server) handleProviderConfigDetail(w http.ResponseWriter, r *http.Reque
 	id, action := trimPrefixID(r.URL.Path, "/v1/provider-configs/")
 	item, err := s.store.GetProviderConfig(r.Context(), session.TenantID, id)
 	if err != nil {
-		s.writeLookupError(w, err, "provider config not found")
+		s.writeLookupError(w, err, "provider config")
 		return
 	}
 
@@ -469,7 +611,7 @@ func (s *Server) handleProviderConfigDetail(w http.ResponseWriter, r *http.Reque
 		case http.MethodPatch:
 			var req ProviderConfigUpdateRequest
 			if err := decodeJSON(r, &req); err != nil {
-				writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+				writeBodyError(w, err)
 				return
 			}
 			if req.AccountEmail != "" {
@@ -487,7 +629,7 @@ func (s *Server) handleProviderConfigDetail(w http.ResponseWriter, r *http.Reque
 			}
 			item.UpdatedAt = utcNow()
 			if err := s.store.UpdateProviderConfig(r.Context(), item); err != nil {
-				s.writeLookupError(w, err, "provider config not found")
+				s.writeLookupError(w, err, "provider config")
 				return
 			}
 			if err := s.recordAudit(r.Context(), session.TenantID, routeCtx(r).RequestID, "provider.updated", "provider_config", item.ID, "Updated provider config"); err != nil {
@@ -496,13 +638,13 @@ func (s *Server) handleProviderConfigDetail(w http.ResponseWriter, r *http.Reque
 			}
 			writeJSON(w, http.StatusOK, item)
 		default:
-			writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "method not allowed"})
+			apierror.WriteError(w, http.StatusMethodNotAllowed, errMethodNotAllowed)
 		}
 		return
 	}
 
 	if r.Method != http.MethodPost {
-		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "method not allowed"})
+		apierror.WriteError(w, http.StatusMethodNotAllowed, errMethodNotAllowed)
 		return
 	}
 
@@ -517,11 +659,11 @@ func (s *Server) handleProviderConfigDetail(w http.ResponseWriter, r *http.Reque
 	case "oauth/callback":
 		var req OAuthCallbackRequest
 		if err := decodeJSON(r, &req); err != nil {
-			writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+			writeBodyError(w, err)
 			return
 		}
 		if req.Code == "" {
-			writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "code is required"})
+			apierror.WriteError(w, http.StatusBadRequest, apierror.Field("code", "is required"))
 			return
 		}
 		item.State = "connected"
@@ -530,7 +672,7 @@ func (s *Server) handleProviderConfigDetail(w http.ResponseWriter, r *http.Reque
 		item.LastSyncAt = utcNow()
 		item.UpdatedAt = item.LastSyncAt
 		if err := s.store.UpdateProviderConfig(r.Context(), item); err != nil {
-			s.writeLookupError(w, err, "provider config not found")
+			s.writeLookupError(w, err, "provider config")
 			return
 		}
 		if err := s.recordAudit(r.Context(), session.TenantID, routeCtx(r).RequestID, "provider.oauth.callback", "provider_config", item.ID, "Completed provider OAuth flow"); err != nil {
@@ -545,7 +687,7 @@ func (s *Server) handleProviderConfigDetail(w http.ResponseWriter, r *http.Reque
 		item.LastSyncAt = utcNow()
 		item.UpdatedAt = item.LastSyncAt
 		if err := s.store.UpdateProviderConfig(r.Context(), item); err != nil {
-			s.writeLookupError(w, err, "provider config not found")
+			s.writeLookupError(w, err, "provider config")
 			return
 		}
 		if err := s.recordAudit(r.Context(), session.TenantID, routeCtx(r).RequestID, "provider.oauth.refresh", "provider_config", item.ID, "Refreshed provider connection"); err != nil {
@@ -559,7 +701,7 @@ func (s *Server) handleProviderConfigDetail(w http.ResponseWriter, r *http.Reque
 		item.Health = "revoked"
 		item.UpdatedAt = utcNow()
 		if err := s.store.UpdateProviderConfig(r.Context(), item); err != nil {
-			s.writeLookupError(w, err, "provider config not found")
+			s.writeLookupError(w, err, "provider config")
 			return
 		}
 		if err := s.recordAudit(r.Context(), session.TenantID, routeCtx(r).RequestID, "provider.oauth.revoke", "provider_config", item.ID, "Revoked provider connection"); err != nil {
@@ -568,14 +710,19 @@ func (s *Server) handleProviderConfigDetail(w http.ResponseWriter, r *http.Reque
 		}
 		writeJSON(w, http.StatusOK, item)
 	default:
-		writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "not found"})
+		apierror.WriteError(w, http.StatusNotFound, errRouteNotFound)
 	}
 }
 
 func (s *Server) handleCollectionJobs(w http.ResponseWriter, r *http.Request, session Session) {
 	switch r.Method {
 	case http.MethodGet:
-		items, err := s.store.ListCollectionJobs(r.Context(), session.TenantID)
+		status := r.URL.Query().Get("status")
+		if status != "" && !validCollectionJobStatus(status) {
+			apierror.WriteError(w, http.StatusBadRequest, apierror.Field("status", "must be one of queued, running, succeeded, failed"))
+			return
+		}
+		items, err := s.store.ListCollectionJobs(r.Context(), session.TenantID, status)
 		if err != nil {
 			s.writeInternalError(w, err)
 			return
@@ -584,18 +731,23 @@ func (s *Server) handleCollectionJobs(w http.ResponseWriter, r *http.Request, se
 	case http.MethodPost:
 		var req CollectionJobCreateRequest
 		if err := decodeJSON(r, &req); err != nil {
-			writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+			writeBodyError(w, err)
 			return
 		}
 		if len(req.Providers) == 0 || req.Month == 0 || req.Year == 0 {
-			writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "providers, month, and year are required"})
+			apierror.WriteError(w, http.StatusBadRequest, requiredFields("providers, month, and year are required", map[string]bool{
+				"providers": len(req.Providers) == 0,
+				"month":     req.Month == 0,
+				"year":      req.Year == 0,
+			}))
 			return
 		}
 		now := utcNow()
 		item := CollectionJob{
 			ID:              s.newID("job"),
 			TenantID:        session.TenantID,
-			Status:          "queued",
+			Status:          CollectionJobQueued,
+			Attempt:         1,
 			Providers:       req.Providers,
 			Month:           req.Month,
 			Year:            req.Year,
@@ -604,6 +756,7 @@ func (s *Server) handleCollectionJobs(w http.ResponseWriter, r *http.Request, se
 			GraphTokenCache: req.GraphTokenCache,
 			InteractiveAuth: req.InteractiveAuth,
 			RequestID:       routeCtx(r).RequestID,
+			QueuedAt:        now,
 			CreatedAt:       now,
 			UpdatedAt:       now,
 		}
@@ -618,7 +771,7 @@ func (s *Server) handleCollectionJobs(w http.ResponseWriter, r *http.Request, se
 		item = s.runCollectionJob(r.Context(), session, routeCtx(r).RequestID, item, req)
 		writeJSON(w, http.StatusCreated, item)
 	default:
-		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "method not allowed"})
+		apierror.WriteError(w, http.StatusMethodNotAllowed, errMethodNotAllowed)
 	}
 }
 
@@ -626,7 +779,7 @@ func (s *Server) handleCollectionJobDetail(w http.ResponseWriter, r *http.Reques
 	id, action := trimPrefixID(r.URL.Path, "/v1/collection-jobs/")
 	item, err := s.store.GetCollectionJob(r.Context(), session.TenantID, id)
 	if err != nil {
-		s.writeLookupError(w, err, "collection job not found")
+		s.writeLookupError(w, err, "collection job")
 		return
 	}
 	if action == "" && r.Method == http.MethodGet {
@@ -634,28 +787,55 @@ func (s *Server) handleCollectionJobDetail(w http.ResponseWriter, r *http.Reques
 		return
 	}
 	if action == "retry" && r.Method == http.MethodPost {
-		now := utcNow()
-		retry := item
-		retry.ID = s.newID("job")
-		retry.Status = "queued"
-		retry.RetryOf = item.ID
-		retry.RequestID = routeCtx(r).RequestID
-		retry.RunSummaryPath = ""
-		retry.InvoicesDir = ""
-		retry.Error = ""
-		retry.StartedAt = ""
-		retry.FinishedAt = ""
-		retry.CreatedAt = now
-		retry.UpdatedAt = now
-		if err := s.store.CreateCollectionJob(r.Context(), retry); err != nil {
-			s.writeInternalError(w, err)
-			return
-		}
-		if err := s.recordAudit(r.Context(), session.TenantID, routeCtx(r).RequestID, "collection.retried", "collection_job", retry.ID, fmt.Sprintf("Retried collection job %s", item.ID)); err != nil {
-			s.writeInternalError(w, err)
-			return
-		}
-		retry = s.runCollectionJob(r.Context(), session, routeCtx(r).RequestID, retry, CollectionJobCreateRequest{
+		s.withIdempotency(w, r, session, func(w http.ResponseWriter) {
+			s.retryCollectionJob(w, r, session, item)
+		})
+		return
+	}
+	if action == "events" && r.Method == http.MethodGet {
+		s.streamCollectionJobEvents(w, r, session, item)
+		return
+	}
+	apierror.WriteError(w, http.StatusMethodNotAllowed, errMethodNotAllowed)
+}
+
+// retryCollectionJob enqueues a new attempt of a failed job and runs it in
+// the background. The failed job is left untouched so its history survives;
+// the new job points back at it through RetryOf.
+func (s *Server) retryCollectionJob(w http.ResponseWriter, r *http.Request, session Session, item CollectionJob) {
+	if item.Status != CollectionJobFailed {
+		apierror.WriteError(w, http.StatusConflict, apierror.New(apierror.CodeJobNotRetryable, fmt.Sprintf("only failed collection jobs can be retried; job is %s", item.Status)))
+		return
+	}
+	now := utcNow()
+	retry := item
+	retry.ID = s.newID("job")
+	retry.Status = CollectionJobQueued
+	retry.Attempt = item.Attempt + 1
+	retry.RetryOf = item.ID
+	retry.RequestID = routeCtx(r).RequestID
+	retry.RunSummaryPath = ""
+	retry.InvoicesDir = ""
+	retry.Error = ""
+	retry.StartedAt = ""
+	retry.FinishedAt = ""
+	retry.QueuedAt = now
+	retry.CreatedAt = now
+	retry.UpdatedAt = now
+	if err := s.store.CreateCollectionJob(r.Context(), retry); err != nil {
+		s.writeInternalError(w, err)
+		return
+	}
+	if err := s.recordAudit(r.Context(), session.TenantID, routeCtx(r).RequestID, "collection.retried", "collection_job", retry.ID, fmt.Sprintf("Retried collection job %s", item.ID)); err != nil {
+		s.writeInternalError(w, err)
+		return
+	}
+	ctx := context.WithoutCancel(r.Context())
+	requestID := routeCtx(r).RequestID
+	s.background.Add(1)
+	go func() {
+		defer s.background.Done()
+		s.runCollectionJob(ctx, session, requestID, retry, CollectionJobCreateRequest{
 			Providers:       retry.Providers,
 			Month:           retry.Month,
 			Year:            retry.Year,
@@ -664,10 +844,8 @@ func (s *Server) handleCollectionJobDetail(w http.ResponseWriter, r *http.Reques
 			GraphTokenCache: retry.GraphTokenCache,
 			InteractiveAuth: retry.InteractiveAuth,
 		})
-		writeJSON(w, http.StatusCreated, retry)
-		return
-	}
-	writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "method not allowed"})
+	}()
+	writeJSON(w, http.StatusAccepted, retry)
 }
 
 func (s *Server) handleReports(w http.ResponseWriter, r *http.Request, session Session) {
@@ -682,11 +860,14 @@ func (s *Server) handleReports(w http.ResponseWriter, r *http.Request, session S
 	case http.MethodPost:
 		var req ReportCreateRequest
 		if err := decodeJSON(r, &req); err != nil {
-			writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+			writeBodyError(w, err)
 			return
 		}
 		if req.InputDir == "" || len(req.Formats) == 0 {
-			writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "inputDir and formats are required"})
+			apierror.WriteError(w, http.StatusBadRequest, requiredFields("inputDir and formats are required", map[string]bool{
+				"inputDir": req.InputDir == "",
+				"formats":  len(req.Formats) == 0,
+			}))
 			return
 		}
 		now := utcNow()
@@ -711,7 +892,7 @@ func (s *Server) handleReports(w http.ResponseWriter, r *http.Request, session S
 		item = s.runReport(r.Context(), session, routeCtx(r).RequestID, item, req)
 		writeJSON(w, http.StatusCreated, item)
 	default:
-		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "method not allowed"})
+		apierror.WriteError(w, http.StatusMethodNotAllowed, errMethodNotAllowed)
 	}
 }
 
@@ -719,7 +900,7 @@ func (s *Server) handleReportDetail(w http.ResponseWriter, r *http.Request, sess
 	id, action := trimPrefixID(r.URL.Path, "/v1/reports/")
 	item, err := s.store.GetReport(r.Context(), session.TenantID, id)
 	if err != nil {
-		s.writeLookupError(w, err, "report not found")
+		s.writeLookupError(w, err, "report")
 		return
 	}
 	if action == "" && r.Method == http.MethodGet {
@@ -734,10 +915,10 @@ func (s *Server) handleReportDetail(w http.ResponseWriter, r *http.Request, sess
 				return
 			}
 		}
-		writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "artifact not found"})
+		apierror.WriteError(w, http.StatusNotFound, apierror.NotFound("artifact"))
 		return
 	}
-	writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "method not allowed"})
+	apierror.WriteError(w, http.StatusMethodNotAllowed, errMethodNotAllowed)
 }
 
 func (s *Server) handleSchedules(w http.ResponseWriter, r *http.Request, session Session) {
@@ -752,11 +933,16 @@ func (s *Server) handleSchedules(w http.ResponseWriter, r *http.Request, session
 	case http.MethodPost:
 		var req ScheduleCreateRequest
 		if err := decodeJSON(r, &req); err != nil {
-			writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+			writeBodyError(w, err)
 			return
 		}
 		if req.Name == "" || req.Timezone == "" || req.Cron == "" || len(req.Providers) == 0 {
-			writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "name, timezone, cron, and providers are required"})
+			apierror.WriteError(w, http.StatusBadRequest, requiredFields("name, timezone, cron, and providers are required", map[string]bool{
+				"name":      req.Name == "",
+				"timezone":  req.Timezone == "",
+				"cron":      req.Cron == "",
+				"providers": len(req.Providers) == 0,
+			}))
 			return
 		}
 		now := utcNow()
@@ -781,7 +967,7 @@ func (s *Server) handleSchedules(w http.ResponseWriter, r *http.Request, session
 		}
 		writeJSON(w, http.StatusCreated, item)
 	default:
-		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "method not allowed"})
+		apierror.WriteError(w, http.StatusMethodNotAllowed, errMethodNotAllowed)
 	}
 }
 
@@ -789,7 +975,7 @@ func (s *Server) handleScheduleDetail(w http.ResponseWriter, r *http.Request, se
 	id, action := trimPrefixID(r.URL.Path, "/v1/schedules/")
 	item, err := s.store.GetSchedule(r.Context(), session.TenantID, id)
 	if err != nil {
-		s.writeLookupError(w, err, "schedule not found")
+		s.writeLookupError(w, err, "schedule")
 		return
 	}
 	if action == "" {
@@ -799,7 +985,7 @@ func (s *Server) handleScheduleDetail(w http.ResponseWriter, r *http.Request, se
 		case http.MethodPatch:
 			var req ScheduleUpdateRequest
 			if err := decodeJSON(r, &req); err != nil {
-				writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+				writeBodyError(w, err)
 				return
 			}
 			if req.Name != "" {
@@ -819,7 +1005,7 @@ func (s *Server) handleScheduleDetail(w http.ResponseWriter, r *http.Request, se
 			}
 			item.UpdatedAt = utcNow()
 			if err := s.store.UpdateSchedule(r.Context(), item); err != nil {
-				s.writeLookupError(w, err, "schedule not found")
+				s.writeLookupError(w, err, "schedule")
 				return
 			}
 			if err := s.recordAudit(r.Context(), session.TenantID, routeCtx(r).RequestID, "schedule.updated", "schedule", item.ID, "Updated schedule"); err != nil {
@@ -828,12 +1014,12 @@ func (s *Server) handleScheduleDetail(w http.ResponseWriter, r *http.Request, se
 			}
 			writeJSON(w, http.StatusOK, item)
 		default:
-			writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "method not allowed"})
+			apierror.WriteError(w, http.StatusMethodNotAllowed, errMethodNotAllowed)
 		}
 		return
 	}
 	if r.Method != http.MethodPost {
-		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "method not allowed"})
+		apierror.WriteError(w, http.StatusMethodNotAllowed, errMethodNotAllowed)
 		return
 	}
 	switch action {
@@ -842,12 +1028,12 @@ func (s *Server) handleScheduleDetail(w http.ResponseWriter, r *http.Request, se
 	case "resume":
 		item.Status = "active"
 	default:
-		writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "not found"})
+		apierror.WriteError(w, http.StatusNotFound, errRouteNotFound)
 		return
 	}
 	item.UpdatedAt = utcNow()
 	if err := s.store.UpdateSchedule(r.Context(), item); err != nil {
-		s.writeLookupError(w, err, "schedule not found")
+		s.writeLookupError(w, err, "schedule")
 		return
 	}
 	if err := s.recordAudit(r.Context(), session.TenantID, routeCtx(r).RequestID, "schedule."+action, "schedule", item.ID, strings.Title(action)+"d schedule"); err != nil {
@@ -867,23 +1053,25 @@ func (s *Server) handleAuditEvents(w http.ResponseWriter, r *http.Request, sessi
 }
 
 func (s *Server) requireSession(w http.ResponseWriter, r *http.Request, next func(http.ResponseWriter, *http.Request, Session)) {
+	if r.Header.Get("Authorization") != "" {
+		s.requireBearerToken(w, r, next)
+		return
+	}
 	cookie, err := r.Cookie(sessionCookieName)
 	if err != nil || cookie.Value == "" {
-		writeJSON(w, http.StatusUnauthorized, ErrorResponse{Error: "unauthorized"})
+		apierror.WriteError(w, http.StatusUnauthorized, errUnauthorized)
 		return
 	}
 	session, err := s.store.GetSession(r.Context(), cookie.Value)
 	if err != nil {
 		if errors.Is(err, ErrNotFound) {
-			writeJSON(w, http.StatusUnauthorized, ErrorResponse{Error: "unauthorized"})
+			apierror.WriteError(w, http.StatusUnauthorized, errUnauthorized)
 			return
 		}
 		s.writeInternalError(w, err)
 		return
 	}
-	ctx := routeCtx(r)
-	ctx.Session = &session
-	next(w, withRouteContext(r, ctx), session)
+	s.serveAuthenticated(w, r, session, next)
 }
 
 func (s *Server) recordAudit(ctx context.Context, tenantID, requestID, action, entityType, entityID, message string) error {
@@ -900,13 +1088,13 @@ func (s *Server) recordAudit(ctx context.Context, tenantID, requestID, action, e
 }
 
 func (s *Server) runCollectionJob(ctx context.Context, session Session, requestID string, item CollectionJob, req CollectionJobCreateRequest) CollectionJob {
-	started := utcNow()
-	item.Status = "running"
-	item.StartedAt = started
-	item.UpdatedAt = started
-	item.Error = ""
-	if err := s.store.UpdateCollectionJob(ctx, item); err != nil {
-		item.Status = "failed"
+	if err := s.transitionCollectionJob(ctx, &item, CollectionJobRunning); err != nil {
+		s.logger.WarnContext(ctx, "collection job could not start", "job_id", item.ID, "error", err)
+		// Another worker may already own the job; report what is stored.
+		if current, getErr := s.store.GetCollectionJob(ctx, item.TenantID, item.ID); getErr == nil && current.Status != item.Status {
+			return current
+		}
+		item.Status = CollectionJobFailed
 		item.Error = "failed to persist running collection state"
 		item.FinishedAt = utcNow()
 		item.UpdatedAt = item.FinishedAt
@@ -925,23 +1113,24 @@ func (s *Server) runCollectionJob(ctx context.Context, session Session, requestI
 
 	item.RunSummaryPath = result.RunSummaryPath
 	item.InvoicesDir = result.InvoicesDir
-	item.FinishedAt = utcNow()
-	item.UpdatedAt = item.FinishedAt
 	item.Error = strings.TrimSpace(result.Error)
+	final := CollectionJobSucceeded
 	if err != nil {
 		if item.Error == "" {
 			item.Error = err.Error()
 		}
-		item.Status = "failed"
-	} else if result.Status == "failed" {
-		item.Status = "failed"
+		final = CollectionJobFailed
+	} else if result.Status == CollectionJobFailed {
+		final = CollectionJobFailed
 	} else {
-		item.Status = "succeeded"
 		item.Error = ""
 	}
 
-	if storeErr := s.store.UpdateCollectionJob(ctx, item); storeErr != nil {
-		item.Status = "failed"
+	if storeErr := s.transitionCollectionJob(ctx, &item, final); storeErr != nil {
+		s.logger.ErrorContext(ctx, "persist collection job result", "job_id", item.ID, "status", final, "error", storeErr)
+		item.Status = CollectionJobFailed
+		item.FinishedAt = utcNow()
+		item.UpdatedAt = item.FinishedAt
 		if item.Error == "" {
 			item.Error = "failed to persist completed collection state"
 		}
@@ -949,7 +1138,7 @@ func (s *Server) runCollectionJob(ctx context.Context, session Session, requestI
 
 	action := "collection.succeeded"
 	message := "Collection job completed successfully"
-	if item.Status == "failed" {
+	if item.Status == CollectionJobFailed {
 		action = "collection.failed"
 		message = pick(item.Error, "Collection job failed")
 	}
@@ -1015,16 +1204,30 @@ func (s *Server) runReport(ctx context.Context, session Session, requestID strin
 	return item
 }
 
-func (s *Server) writeLookupError(w http.ResponseWriter, err error, notFoundMessage string) {
+// writeLookupError answers a failed lookup of resource with 404 and its
+// "<resource>_not_found" code, or 500 for anything but ErrNotFound.
+func (s *Server) writeLookupError(w http.ResponseWriter, err error, resource string) {
 	if errors.Is(err, ErrNotFound) {
-		writeJSON(w, http.StatusNotFound, ErrorResponse{Error: notFoundMessage})
+		apierror.WriteError(w, http.StatusNotFound, apierror.NotFound(resource))
 		return
 	}
 	s.writeInternalError(w, err)
 }
 
-func (s *Server) writeInternalError(w http.ResponseWriter, _ error) {
-	writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "internal server error"})
+// writeInternalError answers 500, or 503 when the request ran out of time,
+// since whatever failed then most likely failed because its context did.
+func (s *Server) writeInternalError(w http.ResponseWriter, err error) {
+	recordRequestError(w, err)
+	if requestTimedOut(w) {
+		apierror.WriteError(w, http.StatusServiceUnavailable, errRequestTimeout)
+		return
+	}
+	if errors.Is(err, ErrUnavailable) {
+		w.Header().Set("Retry-After", "1")
+		apierror.WriteError(w, http.StatusServiceUnavailable, errServiceUnavailable)
+		return
+	}
+	apierror.WriteError(w, http.StatusInternalServerError, err)
 }
 
 func buildArtifacts(req ReportCreateRequest) []ReportArtifact {
@@ -1044,14 +1247,6 @@ func buildArtifacts(req ReportCreateRequest) []ReportArtifact {
 	return artifacts
 }
 
-func decodeJSON(r *http.Request, dst any) error {
-	defer r.Body.Close()
-	if err := json.NewDecoder(r.Body).Decode(dst); err != nil {
-		return errors.New("invalid JSON body")
-	}
-	return nil
-}
-
 func writeJSON(w http.ResponseWriter, status int, payload any) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(status)
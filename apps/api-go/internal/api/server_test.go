@@ -21,6 +21,30 @@ func TestHealthz(t *testing.T) {
 	}
 }
 
+func TestReadyzFlipsToUnavailableOnShutdown(t *testing.T) {
+	server := NewServer()
+
+	rec := httptest.NewRecorder()
+	server.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected ready 200, got %d", rec.Code)
+	}
+
+	server.BeginShutdown()
+
+	rec = httptest.NewRecorder()
+	server.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected draining 503, got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	server.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected liveness to stay 200 while draining, got %d", rec.Code)
+	}
+}
+
 func TestLoginAndGetMe(t *testing.T) {
 	server := NewServer()
 	loginBody, _ := json.Marshal(LoginRequest{
@@ -28,6 +52,7 @@ func TestLoginAndGetMe(t *testing.T) {
 		TenantID: "tenant-alpha",
 	})
 	loginReq := httptest.NewRequest(http.MethodPost, "/auth/login", bytes.NewReader(loginBody))
+	loginReq.Header.Set("Content-Type", "application/json")
 	loginRec := httptest.NewRecorder()
 
 	server.Handler().ServeHTTP(loginRec, loginReq)
@@ -61,6 +86,7 @@ func TestCreateProviderConfigAndAuditTrail(t *testing.T) {
 		Scopes:       []string{"gmail.readonly"},
 	})
 	req := httptest.NewRequest(http.MethodPost, "/v1/provider-configs", bytes.NewReader(payload))
+	req.Header.Set("Content-Type", "application/json")
 	req.AddCookie(cookie)
 	rec := httptest.NewRecorder()
 
@@ -98,6 +124,7 @@ func TestCollectionJobRunsAndPersistsFinalState(t *testing.T) {
 		Year:      2026,
 	})
 	req := httptest.NewRequest(http.MethodPost, "/v1/collection-jobs", bytes.NewReader(payload))
+	req.Header.Set("Content-Type", "application/json")
 	req.AddCookie(cookie)
 	rec := httptest.NewRecorder()
 	server.Handler().ServeHTTP(rec, req)
@@ -135,6 +162,7 @@ func TestCollectionJobFailurePersistsErrorAndSupportsRetry(t *testing.T) {
 		Year:      2026,
 	})
 	req := httptest.NewRequest(http.MethodPost, "/v1/collection-jobs", bytes.NewReader(payload))
+	req.Header.Set("Content-Type", "application/json")
 	req.AddCookie(cookie)
 	rec := httptest.NewRecorder()
 	server.Handler().ServeHTTP(rec, req)
@@ -152,16 +180,26 @@ func TestCollectionJobFailurePersistsErrorAndSupportsRetry(t *testing.T) {
 	retryRec := httptest.NewRecorder()
 	server.Handler().ServeHTTP(retryRec, retryReq)
 
-	if retryRec.Code != http.StatusCreated {
-		t.Fatalf("expected retry 201, got %d", retryRec.Code)
+	if retryRec.Code != http.StatusAccepted {
+		t.Fatalf("expected retry 202, got %d", retryRec.Code)
 	}
-	var retried CollectionJob
-	if err := json.NewDecoder(retryRec.Body).Decode(&retried); err != nil {
+	var accepted CollectionJob
+	if err := json.NewDecoder(retryRec.Body).Decode(&accepted); err != nil {
 		t.Fatalf("decode retried job: %v", err)
 	}
+	if accepted.ID == failedJob.ID || accepted.Status != "queued" {
+		t.Fatalf("expected a new queued run, got %#v", accepted)
+	}
+	if err := server.WaitForBackground(context.Background()); err != nil {
+		t.Fatalf("wait for retry: %v", err)
+	}
+	retried := getCollectionJobForTest(t, server, cookie, accepted.ID)
 	if retried.RetryOf != failedJob.ID || retried.Status != "failed" {
 		t.Fatalf("expected failed retry linked to original job, got %#v", retried)
 	}
+	if original := getCollectionJobForTest(t, server, cookie, failedJob.ID); original.Status != "failed" || original.Error != failedJob.Error {
+		t.Fatalf("expected original run history to be preserved, got %#v", original)
+	}
 }
 
 func TestReportRunPersistsArtifactsAndTotals(t *testing.T) {
@@ -184,6 +222,7 @@ func TestReportRunPersistsArtifactsAndTotals(t *testing.T) {
 		Formats:  []string{"json", "pdf"},
 	})
 	req := httptest.NewRequest(http.MethodPost, "/v1/reports", bytes.NewReader(payload))
+	req.Header.Set("Content-Type", "application/json")
 	req.AddCookie(cookie)
 	rec := httptest.NewRecorder()
 	server.Handler().ServeHTTP(rec, req)
@@ -208,6 +247,7 @@ func loginForTest(t *testing.T, server *Server) *http.Cookie {
 		TenantID: "tenant-alpha",
 	})
 	req := httptest.NewRequest(http.MethodPost, "/auth/login", bytes.NewReader(payload))
+	req.Header.Set("Content-Type", "application/json")
 	rec := httptest.NewRecorder()
 	server.Handler().ServeHTTP(rec, req)
 	if rec.Code != http.StatusOK {
@@ -216,6 +256,22 @@ func loginForTest(t *testing.T, server *Server) *http.Cookie {
 	return rec.Result().Cookies()[0]
 }
 
+// seedCustomersForTest gives the tenant customers with the given IDs, so
+// tests that are not about customers can bill one by ID.
+func seedCustomersForTest(t *testing.T, server *Server, tenantID string, ids ...string) {
+	t.Helper()
+
+	for _, id := range ids {
+		if _, err := server.store.GetCustomer(context.Background(), tenantID, id); id == "" || err == nil {
+			continue
+		}
+		now := utcNow()
+		if err := server.store.CreateCustomer(context.Background(), Customer{ID: id, TenantID: tenantID, Name: id, CreatedAt: now, UpdatedAt: now}); err != nil {
+			t.Fatalf("seed customer %s: %v", id, err)
+		}
+	}
+}
+
 type fakeCollectionRunner struct {
 	result CollectionRunResult
 	err    string
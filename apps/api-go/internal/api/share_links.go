@@ -0,0 +1,308 @@
+package api
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/apierror"
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/invoice"
+)
+
+const (
+	publicInvoicesPath = "/v1/public/invoices/"
+	// defaultShareLinkTTL is how long a link works when the request does not
+	// say, and maxShareLinkTTL the longest one may be made to work.
+	defaultShareLinkTTL = 30 * 24 * time.Hour
+	maxShareLinkTTL     = 90 * 24 * time.Hour
+	// maxShareTokenLength bounds the tokens worth checking: every token
+	// minted is far shorter.
+	maxShareTokenLength = 256
+)
+
+var (
+	errSharingNotConfigured = apierror.New(apierror.CodeSharingNotConfigured, "invoice sharing is not configured")
+	errInvoiceNotShareable  = apierror.New(apierror.CodeInvoiceNotShareable, "draft invoices cannot be shared; issue the invoice first")
+)
+
+// InvoiceShareLink is a stored public link to an invoice. The link works
+// until ExpiresAt or until the invoice's links are revoked.
+type InvoiceShareLink struct {
+	ID        string
+	TenantID  string
+	InvoiceID string
+	ExpiresAt string
+	CreatedBy string
+	CreatedAt string
+}
+
+// InvoiceShareRequest optionally sets when a new link stops working.
+type InvoiceShareRequest struct {
+	ExpiresAt string `json:"expiresAt"`
+}
+
+// InvoiceShare is a link minted for an invoice. Token is the secret part of
+// URL; anyone holding either can read the invoice until it expires.
+type InvoiceShare struct {
+	URL       string `json:"url"`
+	Token     string `json:"token"`
+	ExpiresAt string `json:"expiresAt"`
+	CreatedAt string `json:"createdAt"`
+}
+
+// PublicInvoice is what a share link shows: the invoice as its customer
+// sees it on the PDF, and nothing the organization keeps to itself such as
+// IDs, payment references, void reasons or versions.
+type PublicInvoice struct {
+	Number         string           `json:"number"`
+	Status         invoice.Status   `json:"status"`
+	Currency       string           `json:"currency"`
+	IssuedAt       string           `json:"issuedAt,omitempty"`
+	DueAt          string           `json:"dueAt,omitempty"`
+	PaidAt         string           `json:"paidAt,omitempty"`
+	BillTo         PublicParty      `json:"billTo"`
+	Items          []PublicLineItem `json:"items"`
+	Subtotal       int64            `json:"subtotal"`
+	Tax            int64            `json:"tax"`
+	Total          int64            `json:"total"`
+	AmountPaid     int64            `json:"amountPaid"`
+	AmountCredited int64            `json:"amountCredited"`
+	AmountDue      int64            `json:"amountDue"`
+	ExpiresAt      string           `json:"expiresAt"`
+}
+
+// PublicParty is the billing block of a shared invoice.
+type PublicParty struct {
+	Name  string   `json:"name"`
+	Lines []string `json:"lines"`
+	TaxID string   `json:"taxId,omitempty"`
+}
+
+type PublicLineItem struct {
+	Description string `json:"description"`
+	Quantity    int64  `json:"quantity"`
+	UnitPrice   int64  `json:"unitPrice"`
+	TaxRate     int64  `json:"taxRate"`
+	Amount      int64  `json:"amount"`
+	Tax         int64  `json:"tax"`
+}
+
+// handleInvoiceShare serves POST and DELETE /v1/invoices/{id}/share. POST
+// mints a new link, leaving earlier ones working; DELETE revokes them all.
+func (s *Server) handleInvoiceShare(w http.ResponseWriter, r *http.Request, session Session, item invoice.Invoice) {
+	if r.Method != http.MethodPost && r.Method != http.MethodDelete {
+		apierror.WriteError(w, http.StatusMethodNotAllowed, errMethodNotAllowed)
+		return
+	}
+	if len(s.shareLinkSecret) == 0 {
+		apierror.WriteError(w, http.StatusNotImplemented, errSharingNotConfigured)
+		return
+	}
+	if r.Method == http.MethodDelete {
+		revoked, err := s.store.RevokeInvoiceShareLinks(r.Context(), session.TenantID, item.ID)
+		if err != nil {
+			s.writeInternalError(w, err)
+			return
+		}
+		if err := s.recordAudit(r.Context(), session.TenantID, routeCtx(r).RequestID, "invoice.share_revoked", "invoice", item.ID, fmt.Sprintf("Revoked %d share links to invoice %s", revoked, item.Number)); err != nil {
+			s.writeInternalError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	if item.Status == invoice.StatusDraft {
+		apierror.WriteError(w, http.StatusConflict, errInvoiceNotShareable)
+		return
+	}
+	s.withIdempotency(w, r, session, func(w http.ResponseWriter) {
+		var req InvoiceShareRequest
+		if r.ContentLength != 0 {
+			if err := decodeJSON(r, &req); err != nil {
+				writeBodyError(w, err)
+				return
+			}
+		}
+		now := time.Now().UTC().Truncate(time.Second)
+		expiresAt := now.Add(defaultShareLinkTTL)
+		if req.ExpiresAt != "" {
+			parsed, err := time.Parse(time.RFC3339, req.ExpiresAt)
+			if err != nil {
+				writeValidationError(w, apierror.Field("expiresAt", "must be an RFC 3339 timestamp"))
+				return
+			}
+			expiresAt = parsed.UTC().Truncate(time.Second)
+			if !expiresAt.After(now) || expiresAt.Sub(now) > maxShareLinkTTL {
+				writeValidationError(w, apierror.Field("expiresAt", fmt.Sprintf("must be in the future and at most %d days away", int(maxShareLinkTTL/(24*time.Hour)))))
+				return
+			}
+		}
+		link := InvoiceShareLink{
+			ID:        s.newID("share"),
+			TenantID:  session.TenantID,
+			InvoiceID: item.ID,
+			ExpiresAt: expiresAt.Format(time.RFC3339),
+			CreatedBy: session.UserID,
+			CreatedAt: now.Format(time.RFC3339),
+		}
+		if err := s.store.CreateInvoiceShareLink(r.Context(), link); err != nil {
+			s.writeLookupError(w, err, "invoice")
+			return
+		}
+		if err := s.recordAudit(r.Context(), session.TenantID, routeCtx(r).RequestID, "invoice.shared", "invoice", item.ID, fmt.Sprintf("Shared invoice %s until %s", item.Number, link.ExpiresAt)); err != nil {
+			s.writeInternalError(w, err)
+			return
+		}
+		token := s.shareToken(link.ID, expiresAt)
+		writeJSON(w, http.StatusCreated, InvoiceShare{
+			URL:       s.publicBaseURL + publicInvoicesPath + token,
+			Token:     token,
+			ExpiresAt: link.ExpiresAt,
+			CreatedAt: link.CreatedAt,
+		})
+	})
+}
+
+// handlePublicInvoice serves GET /v1/public/invoices/{token} and its /pdf
+// without a session. The token alone picks the invoice, so every way a
+// token can fail, whether malformed, forged, expired, revoked or pointing
+// at a deleted invoice, answers the same 404, and lookups are rate limited
+// per client IP like logins.
+func (s *Server) handlePublicInvoice(w http.ResponseWriter, r *http.Request) {
+	if !s.allowRequest(w, clientIPKey(r)) {
+		return
+	}
+	header := w.Header()
+	header.Set("Cache-Control", "no-store")
+	header.Set("Referrer-Policy", "no-referrer")
+	header.Set("X-Robots-Tag", "noindex")
+	token, action, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, publicInvoicesPath), "/")
+	if action != "" && action != "pdf" {
+		apierror.WriteError(w, http.StatusNotFound, errRouteNotFound)
+		return
+	}
+	if r.Method != http.MethodGet {
+		apierror.WriteError(w, http.StatusMethodNotAllowed, errMethodNotAllowed)
+		return
+	}
+	link, item, err := s.sharedInvoice(r, token)
+	if errors.Is(err, ErrNotFound) {
+		apierror.WriteError(w, http.StatusNotFound, apierror.NotFound("share link"))
+		return
+	}
+	if err != nil {
+		s.writeInternalError(w, err)
+		return
+	}
+	if action == "pdf" {
+		s.writeInvoicePDF(w, r, item)
+		return
+	}
+	customer, err := s.billingCustomer(r.Context(), item)
+	if err != nil {
+		s.writeInternalError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, publicInvoice(item, customer, link))
+}
+
+// sharedInvoice returns the live invoice a valid, unexpired and unrevoked
+// token links to, or ErrNotFound.
+func (s *Server) sharedInvoice(r *http.Request, token string) (InvoiceShareLink, invoice.Invoice, error) {
+	if len(s.shareLinkSecret) == 0 {
+		return InvoiceShareLink{}, invoice.Invoice{}, ErrNotFound
+	}
+	id, expiresAt, ok := s.verifyShareToken(token)
+	if !ok || !time.Now().Before(expiresAt) {
+		return InvoiceShareLink{}, invoice.Invoice{}, ErrNotFound
+	}
+	link, err := s.store.GetInvoiceShareLink(r.Context(), id)
+	if err != nil {
+		return InvoiceShareLink{}, invoice.Invoice{}, err
+	}
+	if stored, err := time.Parse(time.RFC3339, link.ExpiresAt); err != nil || !stored.Equal(expiresAt) {
+		return InvoiceShareLink{}, invoice.Invoice{}, ErrNotFound
+	}
+	item, err := s.store.GetInvoice(r.Context(), link.TenantID, link.InvoiceID)
+	if err == nil && item.DeletedAt != "" {
+		err = ErrNotFound
+	}
+	return link, item, err
+}
+
+// shareToken signs the link's ID together with its expiry, as
+// "<id>.<unix expiry>.<signature>", so a token cannot be altered to point
+// at another link or to last longer.
+func (s *Server) shareToken(id string, expiresAt time.Time) string {
+	payload := id + "." + strconv.FormatInt(expiresAt.Unix(), 10)
+	return payload + "." + base64.RawURLEncoding.EncodeToString(s.shareSignature(payload))
+}
+
+// verifyShareToken returns the link ID and expiry of a token signed by
+// shareToken.
+func (s *Server) verifyShareToken(token string) (string, time.Time, bool) {
+	if len(token) > maxShareTokenLength {
+		return "", time.Time{}, false
+	}
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 || parts[0] == "" {
+		return "", time.Time{}, false
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil || !hmac.Equal(signature, s.shareSignature(parts[0]+"."+parts[1])) {
+		return "", time.Time{}, false
+	}
+	unix, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return "", time.Time{}, false
+	}
+	return parts[0], time.Unix(unix, 0).UTC(), true
+}
+
+func (s *Server) shareSignature(payload string) []byte {
+	mac := hmac.New(sha256.New, s.shareLinkSecret)
+	mac.Write([]byte("invoice-share-link\x00" + payload))
+	return mac.Sum(nil)
+}
+
+func publicInvoice(item invoice.Invoice, customer *Customer, link InvoiceShareLink) PublicInvoice {
+	party := billTo(item, customer)
+	lines := party.Lines
+	if lines == nil {
+		lines = []string{}
+	}
+	items := make([]PublicLineItem, 0, len(item.Items))
+	for _, line := range item.Items {
+		items = append(items, PublicLineItem{
+			Description: line.Description,
+			Quantity:    line.Quantity,
+			UnitPrice:   line.UnitPrice,
+			TaxRate:     line.TaxRate,
+			Amount:      line.Amount,
+			Tax:         line.Tax,
+		})
+	}
+	return PublicInvoice{
+		Number:         item.Number,
+		Status:         item.Status,
+		Currency:       item.Currency,
+		IssuedAt:       item.IssuedAt,
+		DueAt:          item.DueAt,
+		PaidAt:         item.PaidAt,
+		BillTo:         PublicParty{Name: party.Name, Lines: lines, TaxID: party.TaxID},
+		Items:          items,
+		Subtotal:       item.Subtotal,
+		Tax:            item.Tax,
+		Total:          item.Total,
+		AmountPaid:     item.AmountPaid,
+		AmountCredited: item.AmountCredited,
+		AmountDue:      item.AmountDue(),
+		ExpiresAt:      link.ExpiresAt,
+	}
+}
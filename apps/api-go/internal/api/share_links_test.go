@@ -0,0 +1,214 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/apierror"
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/invoice"
+)
+
+var shareLinkSecretForTest = []byte("0123456789abcdef0123456789abcdef")
+
+func TestShareLinkShowsInvoiceWithoutLogin(t *testing.T) {
+	server := NewServer(WithShareLinks(shareLinkSecretForTest, "https://invoices.example.com/"))
+	cookie := loginForTest(t, server)
+	customer := createCustomerForTest(t, server, cookie, CustomerCreateRequest{Name: "Acme Ltd", Email: "billing@acme.example", TaxID: "IL-123"})
+	created := createInvoiceForTest(t, server, cookie, InvoiceCreateRequest{
+		CustomerID: customer.ID, Currency: "ILS", Status: invoice.StatusOpen, PaymentReference: "internal-ref-7",
+		Items: []InvoiceLineItemRequest{{Description: "Consulting", Quantity: 2, UnitPrice: 5000, TaxRate: 1700}},
+	})
+
+	share := shareInvoiceForTest(t, server, cookie, created.ID, nil)
+	if !strings.HasPrefix(share.URL, "https://invoices.example.com"+publicInvoicesPath) || !strings.HasSuffix(share.URL, share.Token) {
+		t.Fatalf("expected an absolute public URL ending in the token, got %#v", share)
+	}
+	if expires, err := time.Parse(time.RFC3339, share.ExpiresAt); err != nil || time.Until(expires) < 29*24*time.Hour {
+		t.Fatalf("expected the link to last 30 days by default, got %q", share.ExpiresAt)
+	}
+
+	rec := getPublicForTest(server, publicInvoicesPath+share.Token)
+	if rec.Code != http.StatusOK || rec.Header().Get("Cache-Control") != "no-store" || rec.Header().Get("Referrer-Policy") != "no-referrer" {
+		t.Fatalf("expected an uncached public view, got %d %v: %s", rec.Code, rec.Header(), rec.Body.String())
+	}
+	var fields map[string]any
+	if err := json.Unmarshal(rec.Body.Bytes(), &fields); err != nil {
+		t.Fatalf("decode public invoice: %v", err)
+	}
+	for _, internal := range []string{"id", "tenantId", "customerId", "paymentReference", "version", "createdAt", "updatedAt", "deletedAt", "voidReason", "dunning"} {
+		if _, ok := fields[internal]; ok {
+			t.Errorf("expected the public view to leave out %s, got %v", internal, fields)
+		}
+	}
+	var view PublicInvoice
+	if err := json.Unmarshal(rec.Body.Bytes(), &view); err != nil {
+		t.Fatalf("decode public invoice: %v", err)
+	}
+	if view.Number != created.Number || view.BillTo.Name != "Acme Ltd" || view.BillTo.TaxID != "IL-123" || len(view.Items) != 1 ||
+		view.Total != created.Total || view.AmountDue != created.Total || view.ExpiresAt != share.ExpiresAt {
+		t.Fatalf("unexpected public invoice %#v", view)
+	}
+	if strings.Contains(rec.Body.String(), created.ID) || strings.Contains(rec.Body.String(), "internal-ref-7") {
+		t.Fatalf("expected no internal identifiers in the public view: %s", rec.Body.String())
+	}
+
+	pdf := getPublicForTest(server, publicInvoicesPath+share.Token+"/pdf")
+	if pdf.Code != http.StatusOK || pdf.Header().Get("Content-Type") != "application/pdf" || !bytes.HasPrefix(pdf.Body.Bytes(), []byte("%PDF")) {
+		t.Fatalf("expected the invoice PDF, got %d %v", pdf.Code, pdf.Header())
+	}
+	post := httptest.NewRequest(http.MethodPost, publicInvoicesPath+share.Token, nil)
+	postRec := httptest.NewRecorder()
+	server.Handler().ServeHTTP(postRec, post)
+	if postRec.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("expected the public view to be read-only, got %d", postRec.Code)
+	}
+}
+
+func TestShareLinkTokensCannotBeForged(t *testing.T) {
+	server := NewServer(WithShareLinks(shareLinkSecretForTest, ""))
+	cookie := loginForTest(t, server)
+	first := createInvoiceForTest(t, server, cookie, InvoiceCreateRequest{CustomerID: "cust-1", Currency: "ILS", Subtotal: 1000, Status: invoice.StatusOpen})
+	second := createInvoiceForTest(t, server, cookie, InvoiceCreateRequest{CustomerID: "cust-2", Currency: "ILS", Subtotal: 2000, Status: invoice.StatusOpen})
+	share := shareInvoiceForTest(t, server, cookie, first.ID, nil)
+	other := shareInvoiceForTest(t, server, cookie, second.ID, nil)
+	if share.URL != publicInvoicesPath+share.Token {
+		t.Fatalf("expected a relative URL without a public base URL, got %q", share.URL)
+	}
+
+	parts := strings.Split(share.Token, ".")
+	otherParts := strings.Split(other.Token, ".")
+	foreign := NewServer(WithShareLinks([]byte("fedcba9876543210fedcba9876543210"), ""))
+	forged := []string{
+		"",
+		"garbage",
+		first.ID,
+		otherParts[0] + "." + parts[1] + "." + parts[2],
+		parts[0] + "." + otherParts[1] + "." + otherParts[2],
+		parts[0] + "." + "4102444800" + "." + parts[2],
+		parts[0] + "." + parts[1] + "." + parts[2][:len(parts[2])-2],
+		parts[0] + "." + parts[1],
+		foreign.shareToken(parts[0], time.Now().Add(time.Hour)),
+		share.Token + "." + parts[2],
+		strings.Repeat("a", maxShareTokenLength+1),
+	}
+	for _, token := range forged {
+		rec := getPublicForTest(server, publicInvoicesPath+token)
+		if code := decodeErrorCodeForTest(t, rec); rec.Code != http.StatusNotFound || code != "share_link_not_found" {
+			t.Errorf("token %q: expected 404 share_link_not_found, got %d %s", token, rec.Code, code)
+		}
+	}
+
+	// The public path reaches nothing but the linked invoice.
+	for _, path := range []string{publicInvoicesPath + share.Token + "/payments", publicInvoicesPath + share.Token + "/../" + second.ID} {
+		if rec := getPublicForTest(server, path); rec.Code != http.StatusNotFound {
+			t.Errorf("%s: expected 404, got %d", path, rec.Code)
+		}
+	}
+	if rec := getPublicForTest(server, publicInvoicesPath+other.Token); rec.Code != http.StatusOK || strings.Contains(rec.Body.String(), first.Number) {
+		t.Fatalf("expected each token to show its own invoice, got %d", rec.Code)
+	}
+}
+
+func TestShareLinksExpireAndCanBeRevoked(t *testing.T) {
+	server := NewServer(WithShareLinks(shareLinkSecretForTest, ""))
+	cookie := loginForTest(t, server)
+	created := createInvoiceForTest(t, server, cookie, InvoiceCreateRequest{CustomerID: "cust-1", Currency: "ILS", Subtotal: 1000, Status: invoice.StatusOpen})
+	first := shareInvoiceForTest(t, server, cookie, created.ID, nil)
+	soon := time.Now().UTC().Add(time.Hour).Truncate(time.Second).Format(time.RFC3339)
+	second := shareInvoiceForTest(t, server, cookie, created.ID, &InvoiceShareRequest{ExpiresAt: soon})
+	if second.ExpiresAt != soon {
+		t.Fatalf("expected the requested expiry, got %q", second.ExpiresAt)
+	}
+
+	// A link whose expiry has passed stops working even while stored.
+	past := time.Now().UTC().Add(-time.Minute).Truncate(time.Second)
+	if err := server.store.CreateInvoiceShareLink(context.Background(), InvoiceShareLink{ID: "share-old", TenantID: "tenant-alpha", InvoiceID: created.ID, ExpiresAt: past.Format(time.RFC3339), CreatedAt: utcNow()}); err != nil {
+		t.Fatalf("store expired link: %v", err)
+	}
+	if rec := getPublicForTest(server, publicInvoicesPath+server.shareToken("share-old", past)); rec.Code != http.StatusNotFound {
+		t.Fatalf("expected an expired link to be gone, got %d", rec.Code)
+	}
+
+	for _, token := range []string{first.Token, second.Token} {
+		if rec := getPublicForTest(server, publicInvoicesPath+token); rec.Code != http.StatusOK {
+			t.Fatalf("expected the link to work before revoking, got %d", rec.Code)
+		}
+	}
+	if rec := doJSON(t, server, cookie, http.MethodDelete, "/v1/invoices/"+created.ID+"/share", nil); rec.Code != http.StatusNoContent {
+		t.Fatalf("expected revoke 204, got %d: %s", rec.Code, rec.Body.String())
+	}
+	for _, token := range []string{first.Token, second.Token} {
+		if rec := getPublicForTest(server, publicInvoicesPath+token); rec.Code != http.StatusNotFound {
+			t.Fatalf("expected a revoked link to be gone, got %d", rec.Code)
+		}
+	}
+
+	// Issued invoices cannot be deleted through the API, but one deleted
+	// before that rule keeps its links.
+	third := shareInvoiceForTest(t, server, cookie, created.ID, nil)
+	if err := server.store.SoftDeleteInvoice(context.Background(), "tenant-alpha", created.ID, utcNow()); err != nil {
+		t.Fatalf("soft-delete invoice: %v", err)
+	}
+	if rec := getPublicForTest(server, publicInvoicesPath+third.Token); rec.Code != http.StatusNotFound {
+		t.Fatalf("expected a deleted invoice's link to be gone, got %d", rec.Code)
+	}
+}
+
+func TestShareInvoiceRejections(t *testing.T) {
+	server := NewServer(WithShareLinks(shareLinkSecretForTest, ""))
+	cookie := loginForTest(t, server)
+	draft := createInvoiceForTest(t, server, cookie, InvoiceCreateRequest{CustomerID: "cust-1", Currency: "ILS", Subtotal: 1000})
+	open := createInvoiceForTest(t, server, cookie, InvoiceCreateRequest{CustomerID: "cust-1", Currency: "ILS", Subtotal: 1000, Status: invoice.StatusOpen})
+
+	rec := doJSON(t, server, cookie, http.MethodPost, "/v1/invoices/"+draft.ID+"/share", nil)
+	if code := decodeErrorCodeForTest(t, rec); rec.Code != http.StatusConflict || code != apierror.CodeInvoiceNotShareable {
+		t.Fatalf("expected 409 %s for a draft, got %d %s", apierror.CodeInvoiceNotShareable, rec.Code, code)
+	}
+	for _, expiresAt := range []string{"tomorrow", "2020-01-01T00:00:00Z", time.Now().Add(100 * 24 * time.Hour).UTC().Format(time.RFC3339)} {
+		rec := doJSON(t, server, cookie, http.MethodPost, "/v1/invoices/"+open.ID+"/share", InvoiceShareRequest{ExpiresAt: expiresAt})
+		if code := decodeErrorCodeForTest(t, rec); rec.Code != http.StatusBadRequest || code != apierror.CodeValidationFailed {
+			t.Fatalf("expiresAt %q: expected 400 %s, got %d %s", expiresAt, apierror.CodeValidationFailed, rec.Code, code)
+		}
+	}
+	if rec := doJSON(t, server, nil, http.MethodPost, "/v1/invoices/"+open.ID+"/share", nil); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("expected sharing to need a session, got %d", rec.Code)
+	}
+
+	unconfigured := NewServer()
+	cookie = loginForTest(t, unconfigured)
+	open = createInvoiceForTest(t, unconfigured, cookie, InvoiceCreateRequest{CustomerID: "cust-1", Currency: "ILS", Subtotal: 1000, Status: invoice.StatusOpen})
+	rec = doJSON(t, unconfigured, cookie, http.MethodPost, "/v1/invoices/"+open.ID+"/share", nil)
+	if code := decodeErrorCodeForTest(t, rec); rec.Code != http.StatusNotImplemented || code != apierror.CodeSharingNotConfigured {
+		t.Fatalf("expected 501 %s, got %d %s", apierror.CodeSharingNotConfigured, rec.Code, code)
+	}
+}
+
+func shareInvoiceForTest(t *testing.T, server *Server, cookie *http.Cookie, id string, req *InvoiceShareRequest) InvoiceShare {
+	t.Helper()
+
+	var payload any
+	if req != nil {
+		payload = req
+	}
+	rec := doJSON(t, server, cookie, http.MethodPost, "/v1/invoices/"+id+"/share", payload)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected share 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var share InvoiceShare
+	if err := json.NewDecoder(rec.Body).Decode(&share); err != nil {
+		t.Fatalf("decode share: %v", err)
+	}
+	return share
+}
+
+func getPublicForTest(server *Server, path string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	server.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+	return rec
+}
@@ -3,11 +3,24 @@ package api
 import (
 	"context"
 	"errors"
+	"maps"
+	"slices"
 	"sort"
 	"sync"
+	"time"
+
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/invoice"
 )
 
-var ErrNotFound = errors.New("not found")
+var (
+	ErrNotFound = errors.New("not found")
+	ErrConflict = errors.New("conflict")
+	// ErrVersionMismatch is a write based on an outdated read of a record.
+	ErrVersionMismatch = errors.New("version mismatch")
+	// ErrUnavailable is a store that kept failing transiently, such as a
+	// database failing over; the same request may succeed shortly.
+	ErrUnavailable = errors.New("temporarily unavailable")
+)
 
 type Store interface {
 	CreateSession(ctx context.Context, session Session) error
@@ -20,10 +33,12 @@ type Store interface {
 	CreateProviderConfig(ctx context.Context, item ProviderConfig) error
 	UpdateProviderConfig(ctx context.Context, item ProviderConfig) error
 
-	ListCollectionJobs(ctx context.Context, tenantID string) ([]CollectionJob, error)
+	ListCollectionJobs(ctx context.Context, tenantID, status string) ([]CollectionJob, error)
 	GetCollectionJob(ctx context.Context, tenantID, id string) (CollectionJob, error)
 	CreateCollectionJob(ctx context.Context, item CollectionJob) error
-	UpdateCollectionJob(ctx context.Context, item CollectionJob) error
+	// TransitionCollectionJob stores item only if the job is still in the
+	// from status, returning ErrConflict when another writer got there first.
+	TransitionCollectionJob(ctx context.Context, item CollectionJob, from string) error
 
 	ListReports(ctx context.Context, tenantID string) ([]Report, error)
 	GetReport(ctx context.Context, tenantID, id string) (Report, error)
@@ -37,16 +52,229 @@ type Store interface {
 
 	ListAuditEvents(ctx context.Context, tenantID, entityType, entityID string) ([]AuditEvent, error)
 	CreateAuditEvent(ctx context.Context, item AuditEvent) error
+	// ListAuditLog returns the audit log of one entity, oldest first. The
+	// invoice and customer writes below append to it in the same
+	// transaction, taking the actor from the auth claims in ctx.
+	ListAuditLog(ctx context.Context, tenantID, entityType, entityID string) ([]AuditLogEntry, error)
+
+	ListInvoices(ctx context.Context, tenantID string, filter invoice.ListFilter) ([]invoice.Invoice, error)
+	// SearchInvoices matches query against the numbers and customer IDs of
+	// live invoices, the names of live customers, and line item
+	// descriptions, best match first. Results carry the invoice header
+	// without line items.
+	SearchInvoices(ctx context.Context, tenantID string, query invoice.SearchQuery) ([]invoice.SearchResult, error)
+	// GetInvoice also returns soft-deleted invoices, with DeletedAt set. The
+	// writes below treat them as missing.
+	GetInvoice(ctx context.Context, tenantID, id string) (invoice.Invoice, error)
+	// CreateInvoice returns the stored invoice. An issued invoice without a
+	// number (see invoice.NeedsNumber) gets the next one of its tenant's
+	// sequence for numbering, allocated in the same transaction, so a failed
+	// create leaves no gap. Drafts stay unnumbered until they are issued.
+	// Numbers already taken by invoices created with an explicit number are
+	// skipped.
+	CreateInvoice(ctx context.Context, item invoice.Invoice, numbering invoice.NumberFormat) (invoice.Invoice, error)
+	// CreateInvoices writes a batch in one transaction and returns the
+	// indexes of the items whose number was taken, by a stored invoice or
+	// an earlier item. Those items are skipped; when atomic is set, any
+	// taken number leaves the whole batch unwritten. Issued items without a
+	// number are numbered in order as CreateInvoice does, and the numbers are
+	// written back into items.
+	CreateInvoices(ctx context.Context, items []invoice.Invoice, numbering invoice.NumberFormat, atomic bool) ([]int, error)
+	// StoredInvoiceNumbers returns which of numbers invoices of the tenant,
+	// soft-deleted ones included, already have.
+	StoredInvoiceNumbers(ctx context.Context, tenantID string, numbers []string) (map[string]bool, error)
+	// UpdateInvoice writes the header and the recomputed tax of the loaded
+	// line items; lines are otherwise only changed by the methods below.
+	// AmountPaid and AmountCredited are left alone: only RecordInvoicePayment
+	// and IssueCreditNote change them.
+	// item.Version must be the stored version, else ErrVersionMismatch; the
+	// invoice is stored as the next version. Every other invoice write also
+	// moves the version on. A draft issued by the update is numbered as
+	// CreateInvoice does, and the number is written back into item.
+	UpdateInvoice(ctx context.Context, item *invoice.Invoice, numbering invoice.NumberFormat) error
+	// SoftDeleteInvoice sets DeletedAt; the invoice keeps its number, lines
+	// and payments.
+	SoftDeleteInvoice(ctx context.Context, tenantID, id, deletedAt string) error
+	// AddInvoiceLineItem and DeleteInvoiceLineItem change one row and
+	// recompute the invoice totals atomically. They return
+	// invoice.ErrNotDraft once the invoice has left draft.
+	AddInvoiceLineItem(ctx context.Context, tenantID string, item invoice.LineItem) (invoice.Invoice, error)
+	DeleteInvoiceLineItem(ctx context.Context, tenantID, invoiceID, itemID, updatedAt string) (invoice.Invoice, error)
+	// MarkInvoicesOverdue moves up to limit open invoices, across all
+	// tenants, whose due date is before now to overdue and returns them.
+	// Concurrent callers never receive the same invoice.
+	MarkInvoicesOverdue(ctx context.Context, now string, limit int) ([]invoice.Invoice, error)
+	// RecordInvoicePayment applies payment with invoice.ApplyPayment while
+	// holding the invoice, so concurrent payments each see the others'
+	// amounts, and stores the payment with the updated invoice. It returns
+	// invoice.ErrNotPayable or invoice.ErrOverpayment when refused, and
+	// ErrConflict when a payment with the same ID is recorded already, so
+	// a payment reported twice is only counted once.
+	RecordInvoicePayment(ctx context.Context, tenantID string, payment invoice.Payment, allowOverpayment bool, updatedAt string) (invoice.Invoice, error)
+	// ListInvoicePayments returns an invoice's payments in the order they
+	// were recorded.
+	ListInvoicePayments(ctx context.Context, tenantID, invoiceID string) ([]invoice.Payment, error)
+	// VoidInvoice applies invoice.Void while holding the invoice and stores
+	// it as the next version. It returns invoice.ErrAlreadyVoid or
+	// invoice.ErrPaymentsReceived when refused.
+	VoidInvoice(ctx context.Context, tenantID, id, reason, voidedAt string) (invoice.Invoice, error)
+	// IssueCreditNote applies invoice.ApplyCreditNote while holding the
+	// invoice, numbers the note from the tenant's credit note sequence and
+	// stores it with the updated invoice. It returns
+	// invoice.ErrNotCreditable or invoice.ErrCreditExceeded when refused.
+	IssueCreditNote(ctx context.Context, note invoice.CreditNote, amount int64) (invoice.CreditNote, invoice.Invoice, error)
+	// ListCreditNotes returns an invoice's credit notes in number order.
+	ListCreditNotes(ctx context.Context, tenantID, invoiceID string) ([]invoice.CreditNote, error)
+	GetCreditNote(ctx context.Context, tenantID, invoiceID, id string) (invoice.CreditNote, error)
+	// ListDueInvoiceReminders returns up to limit live overdue invoices,
+	// across tenants, whose next reminder under schedule is due at now and
+	// whose customer has an email address.
+	ListDueInvoiceReminders(ctx context.Context, now string, schedule []int, limit int) ([]DueReminder, error)
+	// ListInvoiceReminders returns an invoice's reminders in step order.
+	ListInvoiceReminders(ctx context.Context, tenantID, invoiceID string) ([]InvoiceReminder, error)
+	// RecordInvoiceReminder claims reminder.Step for the invoice before the
+	// email goes out, returning ErrConflict when the step is taken, so a
+	// reminder is sent at most once across replicas and restarts.
+	RecordInvoiceReminder(ctx context.Context, reminder InvoiceReminder) error
+	// DeleteInvoiceReminder releases a step whose email could not be sent.
+	DeleteInvoiceReminder(ctx context.Context, tenantID, invoiceID string, step int) error
+	// SummarizeInvoices aggregates the tenant's live invoices issued between
+	// the inclusive bounds from and to, and the payments received and credit
+	// notes issued between them, per currency in currency order.
+	SummarizeInvoices(ctx context.Context, tenantID, from, to string) ([]CurrencySummary, error)
+	// ListInvoiceAttachments returns an invoice's attachments, oldest first.
+	ListInvoiceAttachments(ctx context.Context, tenantID, invoiceID string) ([]Attachment, error)
+	GetInvoiceAttachment(ctx context.Context, tenantID, invoiceID, id string) (Attachment, error)
+	// CreateInvoiceAttachment stores item unless the invoice already has an
+	// attachment with its checksum, which is returned with created false.
+	CreateInvoiceAttachment(ctx context.Context, item Attachment) (saved Attachment, created bool, err error)
+	// AttachmentObjectStored reports whether any attachment of the tenant
+	// already points at the object for checksum.
+	AttachmentObjectStored(ctx context.Context, tenantID, checksum string) (bool, error)
+	// LockAttachmentObject runs fn holding a lock on the tenant's blob for
+	// checksum, so an upload deciding to reuse the blob and a purge deciding
+	// to delete it cannot interleave.
+	LockAttachmentObject(ctx context.Context, tenantID, checksum string, fn func(context.Context) error) error
+	// CreateInvoiceShareLink stores link, returning ErrNotFound when the
+	// tenant has no such invoice.
+	CreateInvoiceShareLink(ctx context.Context, link InvoiceShareLink) error
+	// GetInvoiceShareLink looks a link up by ID alone, across tenants: the
+	// public view has nothing but the link's token to go on.
+	GetInvoiceShareLink(ctx context.Context, id string) (InvoiceShareLink, error)
+	// RevokeInvoiceShareLinks deletes every link to the invoice and returns
+	// how many there were.
+	RevokeInvoiceShareLinks(ctx context.Context, tenantID, invoiceID string) (int, error)
+
+	// ClaimIdempotencyKey stores record unless an unexpired record already
+	// holds the key, in which case that record is returned with claimed false.
+	ClaimIdempotencyKey(ctx context.Context, record IdempotencyRecord) (existing IdempotencyRecord, claimed bool, err error)
+	// CompleteIdempotencyKey stores the response of a claim and moves its
+	// expiry from the claim lease to record.ExpiresAt.
+	CompleteIdempotencyKey(ctx context.Context, record IdempotencyRecord) error
+	ReleaseIdempotencyKey(ctx context.Context, tenantID, scope, key string) error
+
+	ListWebhookSubscriptions(ctx context.Context, tenantID string) ([]WebhookSubscription, error)
+	GetWebhookSubscription(ctx context.Context, tenantID, id string) (WebhookSubscription, error)
+	CreateWebhookSubscription(ctx context.Context, item WebhookSubscription) error
+	CreateWebhookDeliveries(ctx context.Context, items []WebhookDelivery) error
+	// ClaimWebhookDeliveries returns up to limit pending deliveries due at
+	// now, across tenants, and pushes their next attempt to leaseUntil so
+	// concurrent workers do not send the same delivery twice.
+	ClaimWebhookDeliveries(ctx context.Context, now, leaseUntil string, limit int) ([]WebhookDelivery, error)
+	// RecordWebhookAttempt stores the delivery's new state together with
+	// the attempt that produced it.
+	RecordWebhookAttempt(ctx context.Context, delivery WebhookDelivery, attempt WebhookDeliveryAttempt) error
+	// ListWebhookDeliveries returns the page of a subscription's deliveries
+	// filter selects, newest first, with their attempt logs.
+	ListWebhookDeliveries(ctx context.Context, tenantID, subscriptionID string, filter WebhookDeliveryFilter) ([]WebhookDelivery, error)
+	// GetWebhookDelivery returns a delivery without its attempt log.
+	GetWebhookDelivery(ctx context.Context, tenantID, id string) (WebhookDelivery, error)
+
+	// ListCustomers leaves out soft-deleted customers unless includeDeleted
+	// is set; GetCustomer always returns them so invoices can print their
+	// billing block.
+	ListCustomers(ctx context.Context, tenantID string, includeDeleted bool) ([]Customer, error)
+	GetCustomer(ctx context.Context, tenantID, id string) (Customer, error)
+	CreateCustomer(ctx context.Context, item Customer) error
+	UpdateCustomer(ctx context.Context, item Customer) error
+	SoftDeleteCustomer(ctx context.Context, tenantID, id, deletedAt string) error
+
+	ListRecurringInvoices(ctx context.Context, tenantID string) ([]RecurringInvoice, error)
+	GetRecurringInvoice(ctx context.Context, tenantID, id string) (RecurringInvoice, error)
+	CreateRecurringInvoice(ctx context.Context, item RecurringInvoice) error
+	// UpdateRecurringInvoice writes Status and NextRunAt, which pause and
+	// resume change; the template is fixed once created.
+	UpdateRecurringInvoice(ctx context.Context, item RecurringInvoice) error
+	// ListDueRecurringInvoices returns up to limit active recurring
+	// invoices, across tenants, whose next run is at or before now.
+	ListDueRecurringInvoices(ctx context.Context, now string, limit int) ([]RecurringInvoice, error)
+	// RecordRecurringRun stores the run's invoice, numbered as CreateInvoice
+	// does, and advances the recurring invoice to run.NextRunAt atomically.
+	// It returns the stored invoice. A period that was already billed only
+	// advances, and created is false. It returns ErrConflict when the
+	// recurring invoice is no longer active and due at run.ScheduledAt.
+	RecordRecurringRun(ctx context.Context, run RecurringRun, numbering invoice.NumberFormat) (stored invoice.Invoice, created bool, err error)
+
+	// ListPurgeableInvoices returns up to limit invoices, across tenants,
+	// soft-deleted before deletedBefore, longest deleted first.
+	ListPurgeableInvoices(ctx context.Context, deletedBefore string, limit int) ([]PurgeRecord, error)
+	// PurgeInvoice permanently deletes an invoice soft-deleted before
+	// deletedBefore, with its lines, payments, credit notes, reminders,
+	// attachments and share links, in one transaction, and returns
+	// ErrNotFound for any other invoice. It returns the checksums of the
+	// invoice's attachments, whose blobs the caller deletes after the
+	// commit if nothing else uses them.
+	PurgeInvoice(ctx context.Context, tenantID, id, deletedBefore string) (checksums []string, err error)
+	// ListPurgeableCustomers returns up to limit customers, across tenants,
+	// soft-deleted before deletedBefore that no recurring invoice bills to,
+	// and no invoice either but ones ListPurgeableInvoices returns.
+	ListPurgeableCustomers(ctx context.Context, deletedBefore string, limit int) ([]PurgeRecord, error)
+	// PurgeCustomer permanently deletes a customer ListPurgeableCustomers
+	// would return, and returns ErrNotFound for any other.
+	PurgeCustomer(ctx context.Context, tenantID, id, deletedBefore string) error
+	// PurgeAuditEntries deletes up to limit audit events and audit log
+	// entries, across tenants, created before createdBefore, and returns how
+	// many it deleted.
+	PurgeAuditEntries(ctx context.Context, createdBefore string, limit int) (int, error)
+	// PurgeIdempotencyKeys deletes up to limit idempotency records, across
+	// tenants, that expired before expiredBefore, lapsed claims included,
+	// and returns how many it deleted.
+	PurgeIdempotencyKeys(ctx context.Context, expiredBefore string, limit int) (int, error)
+	// CountPurgeable counts what purging with these cutoffs would remove.
+	CountPurgeable(ctx context.Context, deletedBefore, auditBefore, expiredBefore string) (PurgeSummary, error)
+	// TryExclusive runs fn holding the lock called name unless someone else
+	// holds it, and reports whether fn ran. The Postgres store takes an
+	// advisory lock, so replicas sharing the database take turns.
+	TryExclusive(ctx context.Context, name string, fn func(context.Context) error) (ran bool, err error)
 }
 
 type MemoryStore struct {
-	mu              sync.RWMutex
+	mu sync.RWMutex
+	// objectMu is LockAttachmentObject's lock, apart from mu so fn can use
+	// the store.
+	objectMu        sync.Mutex
 	sessions        map[string]Session
 	providerConfigs map[string]ProviderConfig
 	collectionJobs  map[string]CollectionJob
 	reports         map[string]Report
 	schedules       map[string]Schedule
 	auditEvents     map[string]AuditEvent
+	invoices        map[string]invoice.Invoice
+	payments        map[string][]invoice.Payment
+	creditNotes     map[string][]invoice.CreditNote
+	creditNoteSeq   map[string]int64
+	invoiceSeq      map[invoiceSeries]int64
+	reminders       map[string][]InvoiceReminder
+	attachments     map[string][]Attachment
+	shareLinks      map[string]InvoiceShareLink
+	idempotencyKeys map[string]IdempotencyRecord
+	webhooks        map[string]WebhookSubscription
+	deliveries      map[string]WebhookDelivery
+	customers       map[string]Customer
+	recurring       map[string]RecurringInvoice
+	recurringRuns   map[string]string
+	auditLog        []AuditLogEntry
+	exclusive       map[string]bool
 }
 
 func NewMemoryStore() *MemoryStore {
@@ -57,6 +285,21 @@ func NewMemoryStore() *MemoryStore {
 		reports:         make(map[string]Report),
 		schedules:       make(map[string]Schedule),
 		auditEvents:     make(map[string]AuditEvent),
+		invoices:        make(map[string]invoice.Invoice),
+		payments:        make(map[string][]invoice.Payment),
+		creditNotes:     make(map[string][]invoice.CreditNote),
+		creditNoteSeq:   make(map[string]int64),
+		invoiceSeq:      make(map[invoiceSeries]int64),
+		reminders:       make(map[string][]InvoiceReminder),
+		attachments:     make(map[string][]Attachment),
+		shareLinks:      make(map[string]InvoiceShareLink),
+		idempotencyKeys: make(map[string]IdempotencyRecord),
+		webhooks:        make(map[string]WebhookSubscription),
+		deliveries:      make(map[string]WebhookDelivery),
+		customers:       make(map[string]Customer),
+		recurring:       make(map[string]RecurringInvoice),
+		recurringRuns:   make(map[string]string),
+		exclusive:       make(map[string]bool),
 	}
 }
 
@@ -136,12 +379,12 @@ func (m *MemoryStore) UpdateProviderConfig(_ context.Context, item ProviderConfi
 	return nil
 }
 
-func (m *MemoryStore) ListCollectionJobs(_ context.Context, tenantID string) ([]CollectionJob, error) {
+func (m *MemoryStore) ListCollectionJobs(_ context.Context, tenantID, status string) ([]CollectionJob, error) {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 	items := make([]CollectionJob, 0, len(m.collectionJobs))
 	for _, item := range m.collectionJobs {
-		if item.TenantID == tenantID {
+		if item.TenantID == tenantID && (status == "" || item.Status == status) {
 			items = append(items, item)
 		}
 	}
@@ -168,12 +411,16 @@ func (m *MemoryStore) CreateCollectionJob(_ context.Context, item CollectionJob)
 	return nil
 }
 
-func (m *MemoryStore) UpdateCollectionJob(_ context.Context, item CollectionJob) error {
+func (m *MemoryStore) TransitionCollectionJob(_ context.Context, item CollectionJob, from string) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
-	if _, ok := m.collectionJobs[item.ID]; !ok {
+	existing, ok := m.collectionJobs[item.ID]
+	if !ok || existing.TenantID != item.TenantID {
 		return ErrNotFound
 	}
+	if existing.Status != from {
+		return ErrConflict
+	}
 	m.collectionJobs[item.ID] = item
 	return nil
 }
@@ -290,3 +537,1253 @@ func (m *MemoryStore) CreateAuditEvent(_ context.Context, item AuditEvent) error
 	m.auditEvents[item.ID] = item
 	return nil
 }
+
+func (m *MemoryStore) ListAuditLog(_ context.Context, tenantID, entityType, entityID string) ([]AuditLogEntry, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	items := make([]AuditLogEntry, 0)
+	for _, item := range m.auditLog {
+		if item.TenantID == tenantID && item.EntityType == entityType && item.EntityID == entityID {
+			items = append(items, item)
+		}
+	}
+	return items, nil
+}
+
+// logChangeLocked appends the audit log entry of a change the caller makes
+// while holding m.mu. Callers log before writing, so a change that cannot be
+// logged is not made either.
+func (m *MemoryStore) logChangeLocked(ctx context.Context, tenantID, entityType, entityID, action string, before, after any) error {
+	entry, err := NewAuditLogEntry(ctx, tenantID, entityType, entityID, action, before, after)
+	if err != nil {
+		return err
+	}
+	m.auditLog = append(m.auditLog, entry)
+	return nil
+}
+
+func (m *MemoryStore) ListInvoices(_ context.Context, tenantID string, filter invoice.ListFilter) ([]invoice.Invoice, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	items := make([]invoice.Invoice, 0)
+	for _, item := range m.invoices {
+		if item.TenantID != tenantID || !matchesInvoiceFilter(item, filter) {
+			continue
+		}
+		item.Items = nil
+		items = append(items, item)
+	}
+	sort.Slice(items, func(i, j int) bool {
+		return invoice.Less(items[i], items[j], filter.Sort, filter.Descending)
+	})
+	if filter.Limit > 0 && len(items) > filter.Limit {
+		items = items[:filter.Limit]
+	}
+	return items, nil
+}
+
+func matchesInvoiceFilter(item invoice.Invoice, filter invoice.ListFilter) bool {
+	if item.DeletedAt != "" && !filter.IncludeDeleted {
+		return false
+	}
+	if filter.Status != "" && item.Status != filter.Status {
+		return false
+	}
+	if filter.CustomerID != "" && item.CustomerID != filter.CustomerID {
+		return false
+	}
+	if filter.IssuedFrom != "" && (item.IssuedAt == "" || item.IssuedAt < filter.IssuedFrom) {
+		return false
+	}
+	if filter.IssuedTo != "" && (item.IssuedAt == "" || item.IssuedAt > filter.IssuedTo) {
+		return false
+	}
+	if filter.After != nil && !filter.After.IsAfter(item) {
+		return false
+	}
+	return true
+}
+
+// searchWeights rank memory search results the way the Postgres search
+// weights its vectors: invoice numbers first, line items last.
+var searchWeights = map[string]float64{
+	invoice.SearchFieldNumber:       1,
+	invoice.SearchFieldCustomerID:   0.4,
+	invoice.SearchFieldCustomerName: 0.4,
+	invoice.SearchFieldItem:         0.2,
+}
+
+func (m *MemoryStore) SearchInvoices(_ context.Context, tenantID string, query invoice.SearchQuery) ([]invoice.SearchResult, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	results := make([]invoice.SearchResult, 0)
+	for _, item := range m.invoices {
+		if item.TenantID != tenantID || item.DeletedAt != "" {
+			continue
+		}
+		fields := []invoice.SearchMatch{
+			{Field: invoice.SearchFieldNumber, Value: item.Number},
+			{Field: invoice.SearchFieldCustomerID, Value: item.CustomerID},
+		}
+		if customer, ok := m.customers[item.CustomerID]; ok && customer.TenantID == tenantID && customer.DeletedAt == "" {
+			fields = append(fields, invoice.SearchMatch{Field: invoice.SearchFieldCustomerName, Value: customer.Name})
+		}
+		for _, line := range item.Items {
+			fields = append(fields, invoice.SearchMatch{Field: invoice.SearchFieldItem, Value: line.Description})
+		}
+		result := invoice.SearchResult{Matches: []invoice.SearchMatch{}}
+		for _, field := range fields {
+			if query.Matches(field.Value) {
+				result.Matches = append(result.Matches, field)
+				result.Rank += searchWeights[field.Field]
+			}
+		}
+		if len(result.Matches) == 0 {
+			continue
+		}
+		item.Items = nil
+		result.Invoice = item
+		results = append(results, result)
+	}
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Rank != results[j].Rank {
+			return results[i].Rank > results[j].Rank
+		}
+		return results[i].Invoice.ID < results[j].Invoice.ID
+	})
+	results = results[min(query.Offset, len(results)):]
+	if query.Limit > 0 && len(results) > query.Limit {
+		results = results[:query.Limit]
+	}
+	return results, nil
+}
+
+func (m *MemoryStore) GetInvoice(_ context.Context, tenantID, id string) (invoice.Invoice, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	item, ok := m.invoices[id]
+	if !ok || item.TenantID != tenantID {
+		return invoice.Invoice{}, ErrNotFound
+	}
+	item.Items = slices.Clone(item.Items)
+	return item, nil
+}
+
+func (m *MemoryStore) CreateInvoice(ctx context.Context, item invoice.Invoice, numbering invoice.NumberFormat) (invoice.Invoice, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.invoiceNumberTakenLocked(item) {
+		return invoice.Invoice{}, ErrConflict
+	}
+	allocated := m.numberInvoicesLocked([]*invoice.Invoice{&item}, numbering, nil)
+	item.Items = slices.Clone(item.Items)
+	if err := m.logChangeLocked(ctx, item.TenantID, AuditEntityInvoice, item.ID, AuditInvoiceCreated, nil, item); err != nil {
+		return invoice.Invoice{}, err
+	}
+	m.invoices[item.ID] = item
+	maps.Copy(m.invoiceSeq, allocated)
+	item.Items = slices.Clone(item.Items)
+	return item, nil
+}
+
+func (m *MemoryStore) CreateInvoices(ctx context.Context, items []invoice.Invoice, numbering invoice.NumberFormat, atomic bool) ([]int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	taken := TakenInvoiceNumbers(items, m.invoiceNumberTakenLocked)
+	if len(taken) > 0 && atomic {
+		return taken, nil
+	}
+	var unnumbered []*invoice.Invoice
+	reserved := map[[2]string]bool{}
+	for i := range items {
+		if slices.Contains(taken, i) {
+			continue
+		}
+		if items[i].Number == "" {
+			unnumbered = append(unnumbered, &items[i])
+		} else {
+			reserved[[2]string{items[i].TenantID, items[i].Number}] = true
+		}
+	}
+	allocated := m.numberInvoicesLocked(unnumbered, numbering, reserved)
+	for i, item := range items {
+		if slices.Contains(taken, i) {
+			continue
+		}
+		item.Items = slices.Clone(item.Items)
+		if err := m.logChangeLocked(ctx, item.TenantID, AuditEntityInvoice, item.ID, AuditInvoiceCreated, nil, item); err != nil {
+			return nil, err
+		}
+		m.invoices[item.ID] = item
+	}
+	maps.Copy(m.invoiceSeq, allocated)
+	return taken, nil
+}
+
+func (m *MemoryStore) StoredInvoiceNumbers(_ context.Context, tenantID string, numbers []string) (map[string]bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	stored := map[string]bool{}
+	for _, item := range m.invoices {
+		if item.TenantID == tenantID && slices.Contains(numbers, item.Number) {
+			stored[item.Number] = true
+		}
+	}
+	return stored, nil
+}
+
+// invoiceSeries names one numbering sequence of a tenant; year is 0 unless
+// numbering restarts yearly.
+type invoiceSeries struct {
+	tenantID string
+	year     int
+}
+
+// numberInvoicesLocked gives each invoice that needs a number the next one of
+// its series, skipping numbers that are stored or reserved by the batch. It
+// returns the advanced sequences, which the caller keeps once the invoices
+// are stored.
+func (m *MemoryStore) numberInvoicesLocked(items []*invoice.Invoice, numbering invoice.NumberFormat, reserved map[[2]string]bool) map[invoiceSeries]int64 {
+	allocated := map[invoiceSeries]int64{}
+	for _, item := range items {
+		if !item.NeedsNumber() {
+			continue
+		}
+		series := invoiceSeries{tenantID: item.TenantID, year: numbering.Series(*item)}
+		value, ok := allocated[series]
+		if !ok {
+			value = m.invoiceSeq[series]
+		}
+		for {
+			value++
+			item.Number = numbering.Format(series.year, value)
+			if !reserved[[2]string{item.TenantID, item.Number}] && !m.invoiceNumberTakenLocked(*item) {
+				break
+			}
+		}
+		allocated[series] = value
+	}
+	return allocated
+}
+
+func (m *MemoryStore) UpdateInvoice(ctx context.Context, update *invoice.Invoice, numbering invoice.NumberFormat) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	item := *update
+	existing, ok := m.invoices[item.ID]
+	if !ok || existing.TenantID != item.TenantID || existing.DeletedAt != "" {
+		return ErrNotFound
+	}
+	if existing.Version != item.Version {
+		return ErrVersionMismatch
+	}
+	if m.invoiceNumberTakenLocked(item) {
+		return ErrConflict
+	}
+	allocated := m.numberInvoicesLocked([]*invoice.Invoice{&item}, numbering, nil)
+	items := slices.Clone(existing.Items)
+	for i := range items {
+		if i < len(item.Items) && item.Items[i].ID == items[i].ID {
+			items[i].Tax = item.Items[i].Tax
+		}
+	}
+	item.Items = items
+	item.AmountPaid = existing.AmountPaid
+	item.AmountCredited = existing.AmountCredited
+	item.DeletedAt = existing.DeletedAt
+	item.Version++
+	if err := m.logChangeLocked(ctx, item.TenantID, AuditEntityInvoice, item.ID, AuditInvoiceUpdated, existing, item); err != nil {
+		return err
+	}
+	m.invoices[item.ID] = item
+	maps.Copy(m.invoiceSeq, allocated)
+	update.Number = item.Number
+	return nil
+}
+
+func (m *MemoryStore) SoftDeleteInvoice(ctx context.Context, tenantID, id, deletedAt string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	existing, ok := m.invoices[id]
+	if !ok || existing.TenantID != tenantID || existing.DeletedAt != "" {
+		return ErrNotFound
+	}
+	item := existing
+	item.DeletedAt = deletedAt
+	item.Version++
+	if err := m.logChangeLocked(ctx, tenantID, AuditEntityInvoice, id, AuditInvoiceDeleted, existing, item); err != nil {
+		return err
+	}
+	m.invoices[id] = item
+	return nil
+}
+
+func (m *MemoryStore) AddInvoiceLineItem(ctx context.Context, tenantID string, item invoice.LineItem) (invoice.Invoice, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	existing, ok := m.invoices[item.InvoiceID]
+	if !ok || existing.TenantID != tenantID || existing.DeletedAt != "" {
+		return invoice.Invoice{}, ErrNotFound
+	}
+	if existing.Status != invoice.StatusDraft {
+		return invoice.Invoice{}, invoice.ErrNotDraft
+	}
+	item.Position = 1
+	if n := len(existing.Items); n > 0 {
+		item.Position = existing.Items[n-1].Position + 1
+	}
+	updated := existing
+	updated.SetLineItems(append(slices.Clone(existing.Items), item))
+	updated.UpdatedAt = item.CreatedAt
+	updated.Version++
+	if err := m.logChangeLocked(ctx, tenantID, AuditEntityInvoice, existing.ID, AuditInvoiceItemAdded, existing, updated); err != nil {
+		return invoice.Invoice{}, err
+	}
+	m.invoices[updated.ID] = updated
+	updated.Items = slices.Clone(updated.Items)
+	return updated, nil
+}
+
+func (m *MemoryStore) DeleteInvoiceLineItem(ctx context.Context, tenantID, invoiceID, itemID, updatedAt string) (invoice.Invoice, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	existing, ok := m.invoices[invoiceID]
+	if !ok || existing.TenantID != tenantID || existing.DeletedAt != "" {
+		return invoice.Invoice{}, ErrNotFound
+	}
+	index := slices.IndexFunc(existing.Items, func(item invoice.LineItem) bool { return item.ID == itemID })
+	if index < 0 {
+		return invoice.Invoice{}, ErrNotFound
+	}
+	if existing.Status != invoice.StatusDraft {
+		return invoice.Invoice{}, invoice.ErrNotDraft
+	}
+	updated := existing
+	updated.SetLineItems(slices.Delete(slices.Clone(existing.Items), index, index+1))
+	updated.UpdatedAt = updatedAt
+	updated.Version++
+	if err := m.logChangeLocked(ctx, tenantID, AuditEntityInvoice, existing.ID, AuditInvoiceItemRemoved, existing, updated); err != nil {
+		return invoice.Invoice{}, err
+	}
+	m.invoices[updated.ID] = updated
+	updated.Items = slices.Clone(updated.Items)
+	return updated, nil
+}
+
+func (m *MemoryStore) MarkInvoicesOverdue(ctx context.Context, now string, limit int) ([]invoice.Invoice, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	cutoff, err := time.Parse(time.RFC3339, now)
+	if err != nil {
+		return nil, err
+	}
+	due := make([]invoice.Invoice, 0)
+	for _, item := range m.invoices {
+		if item.Status != invoice.StatusOpen || item.DueAt == "" || item.DeletedAt != "" {
+			continue
+		}
+		if dueAt, err := time.Parse(time.RFC3339, item.DueAt); err == nil && dueAt.Before(cutoff) {
+			due = append(due, item)
+		}
+	}
+	sort.Slice(due, func(i, j int) bool {
+		return due[i].DueAt < due[j].DueAt
+	})
+	if len(due) > limit {
+		due = due[:limit]
+	}
+	for i, item := range due {
+		due[i].Status = invoice.StatusOverdue
+		due[i].UpdatedAt = now
+		due[i].Version++
+		if err := m.logChangeLocked(ctx, item.TenantID, AuditEntityInvoice, item.ID, AuditInvoiceOverdue, item, due[i]); err != nil {
+			return nil, err
+		}
+		m.invoices[due[i].ID] = due[i]
+		due[i].Items = nil
+	}
+	return due, nil
+}
+
+func (m *MemoryStore) RecordInvoicePayment(ctx context.Context, tenantID string, payment invoice.Payment, allowOverpayment bool, updatedAt string) (invoice.Invoice, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	existing, ok := m.invoices[payment.InvoiceID]
+	if !ok || existing.TenantID != tenantID || existing.DeletedAt != "" {
+		return invoice.Invoice{}, ErrNotFound
+	}
+	for _, payments := range m.payments {
+		if slices.ContainsFunc(payments, func(p invoice.Payment) bool { return p.ID == payment.ID }) {
+			return invoice.Invoice{}, ErrConflict
+		}
+	}
+	updated := existing
+	if err := updated.ApplyPayment(payment, allowOverpayment); err != nil {
+		return invoice.Invoice{}, err
+	}
+	updated.UpdatedAt = updatedAt
+	updated.Version++
+	if err := m.logChangeLocked(ctx, tenantID, AuditEntityInvoice, existing.ID, AuditInvoicePaymentReceived, existing, updated); err != nil {
+		return invoice.Invoice{}, err
+	}
+	m.invoices[updated.ID] = updated
+	m.payments[updated.ID] = append(m.payments[updated.ID], payment)
+	updated.Items = slices.Clone(updated.Items)
+	return updated, nil
+}
+
+func (m *MemoryStore) ListInvoicePayments(_ context.Context, tenantID, invoiceID string) ([]invoice.Payment, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if item, ok := m.invoices[invoiceID]; !ok || item.TenantID != tenantID {
+		return []invoice.Payment{}, nil
+	}
+	return append([]invoice.Payment{}, m.payments[invoiceID]...), nil
+}
+
+func (m *MemoryStore) VoidInvoice(ctx context.Context, tenantID, id, reason, voidedAt string) (invoice.Invoice, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	existing, ok := m.invoices[id]
+	if !ok || existing.TenantID != tenantID || existing.DeletedAt != "" {
+		return invoice.Invoice{}, ErrNotFound
+	}
+	updated := existing
+	if err := updated.Void(reason, voidedAt); err != nil {
+		return invoice.Invoice{}, err
+	}
+	updated.UpdatedAt = voidedAt
+	updated.Version++
+	if err := m.logChangeLocked(ctx, tenantID, AuditEntityInvoice, existing.ID, AuditInvoiceVoided, existing, updated); err != nil {
+		return invoice.Invoice{}, err
+	}
+	m.invoices[updated.ID] = updated
+	updated.Items = slices.Clone(updated.Items)
+	return updated, nil
+}
+
+func (m *MemoryStore) IssueCreditNote(ctx context.Context, note invoice.CreditNote, amount int64) (invoice.CreditNote, invoice.Invoice, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	existing, ok := m.invoices[note.InvoiceID]
+	if !ok || existing.TenantID != note.TenantID || existing.DeletedAt != "" {
+		return invoice.CreditNote{}, invoice.Invoice{}, ErrNotFound
+	}
+	updated := existing
+	if err := updated.ApplyCreditNote(&note, amount); err != nil {
+		return invoice.CreditNote{}, invoice.Invoice{}, err
+	}
+	note.Number = invoice.CreditNoteNumber(m.creditNoteSeq[note.TenantID] + 1)
+	updated.UpdatedAt = note.CreatedAt
+	updated.Version++
+	if err := m.logChangeLocked(ctx, note.TenantID, AuditEntityInvoice, existing.ID, AuditInvoiceCredited, existing, updated); err != nil {
+		return invoice.CreditNote{}, invoice.Invoice{}, err
+	}
+	m.creditNoteSeq[note.TenantID]++
+	m.invoices[updated.ID] = updated
+	m.creditNotes[updated.ID] = append(m.creditNotes[updated.ID], note)
+	updated.Items = slices.Clone(updated.Items)
+	return note, updated, nil
+}
+
+func (m *MemoryStore) ListCreditNotes(_ context.Context, tenantID, invoiceID string) ([]invoice.CreditNote, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if item, ok := m.invoices[invoiceID]; !ok || item.TenantID != tenantID {
+		return []invoice.CreditNote{}, nil
+	}
+	return append([]invoice.CreditNote{}, m.creditNotes[invoiceID]...), nil
+}
+
+func (m *MemoryStore) GetCreditNote(_ context.Context, tenantID, invoiceID, id string) (invoice.CreditNote, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if item, ok := m.invoices[invoiceID]; !ok || item.TenantID != tenantID {
+		return invoice.CreditNote{}, ErrNotFound
+	}
+	for _, note := range m.creditNotes[invoiceID] {
+		if note.ID == id {
+			return note, nil
+		}
+	}
+	return invoice.CreditNote{}, ErrNotFound
+}
+
+func (m *MemoryStore) ListDueInvoiceReminders(_ context.Context, now string, schedule []int, limit int) ([]DueReminder, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	cutoff, err := time.Parse(time.RFC3339, now)
+	if err != nil {
+		return nil, err
+	}
+	due := make([]DueReminder, 0)
+	for _, item := range m.invoices {
+		if item.Status != invoice.StatusOverdue || item.DeletedAt != "" {
+			continue
+		}
+		customer, ok := m.customers[item.CustomerID]
+		if !ok || customer.TenantID != item.TenantID || customer.Email == "" {
+			continue
+		}
+		reminders := m.reminders[item.ID]
+		lastReminderAt := ""
+		if len(reminders) > 0 {
+			lastReminderAt = reminders[len(reminders)-1].SentAt
+		}
+		sent := len(reminders)
+		if next, ok := item.NextReminderAt(schedule, sent, lastReminderAt); ok && !next.After(cutoff) {
+			item.Items = nil
+			due = append(due, DueReminder{Invoice: item, Step: sent + 1, Email: customer.Email})
+		}
+	}
+	sort.Slice(due, func(i, j int) bool {
+		if due[i].Invoice.DueAt != due[j].Invoice.DueAt {
+			return due[i].Invoice.DueAt < due[j].Invoice.DueAt
+		}
+		return due[i].Invoice.ID < due[j].Invoice.ID
+	})
+	if len(due) > limit {
+		due = due[:limit]
+	}
+	return due, nil
+}
+
+func (m *MemoryStore) ListInvoiceReminders(_ context.Context, tenantID, invoiceID string) ([]InvoiceReminder, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if item, ok := m.invoices[invoiceID]; !ok || item.TenantID != tenantID {
+		return []InvoiceReminder{}, nil
+	}
+	return append([]InvoiceReminder{}, m.reminders[invoiceID]...), nil
+}
+
+func (m *MemoryStore) RecordInvoiceReminder(_ context.Context, reminder InvoiceReminder) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if item, ok := m.invoices[reminder.InvoiceID]; !ok || item.TenantID != reminder.TenantID {
+		return ErrNotFound
+	}
+	for _, existing := range m.reminders[reminder.InvoiceID] {
+		if existing.Step == reminder.Step {
+			return ErrConflict
+		}
+	}
+	reminders := append(m.reminders[reminder.InvoiceID], reminder)
+	sort.Slice(reminders, func(i, j int) bool {
+		return reminders[i].Step < reminders[j].Step
+	})
+	m.reminders[reminder.InvoiceID] = reminders
+	return nil
+}
+
+func (m *MemoryStore) DeleteInvoiceReminder(_ context.Context, tenantID, invoiceID string, step int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if item, ok := m.invoices[invoiceID]; !ok || item.TenantID != tenantID {
+		return nil
+	}
+	m.reminders[invoiceID] = slices.DeleteFunc(m.reminders[invoiceID], func(reminder InvoiceReminder) bool {
+		return reminder.Step == step
+	})
+	return nil
+}
+
+func (m *MemoryStore) SummarizeInvoices(_ context.Context, tenantID, from, to string) ([]CurrencySummary, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	byCurrency := make(map[string]*CurrencySummary)
+	summaryFor := func(currency string) *CurrencySummary {
+		if byCurrency[currency] == nil {
+			byCurrency[currency] = &CurrencySummary{Currency: currency}
+		}
+		return byCurrency[currency]
+	}
+	for _, item := range m.invoices {
+		if item.TenantID != tenantID || item.DeletedAt != "" {
+			continue
+		}
+		for _, payment := range m.payments[item.ID] {
+			if payment.ReceivedAt >= from && payment.ReceivedAt <= to {
+				summaryFor(item.Currency).Collected += payment.Amount
+			}
+		}
+		for _, note := range m.creditNotes[item.ID] {
+			if note.IssuedAt >= from && note.IssuedAt <= to {
+				summary := summaryFor(item.Currency)
+				summary.Credited -= note.Total
+				summary.Collected += note.Total
+			}
+		}
+		if item.IssuedAt == "" || item.IssuedAt < from || item.IssuedAt > to {
+			continue
+		}
+		summary := summaryFor(item.Currency)
+		var totals *StatusTotals
+		switch item.Status {
+		case invoice.StatusDraft:
+			totals = &summary.ByStatus.Draft
+		case invoice.StatusOpen:
+			totals = &summary.ByStatus.Open
+		case invoice.StatusPaid:
+			totals = &summary.ByStatus.Paid
+		case invoice.StatusOverdue:
+			totals = &summary.ByStatus.Overdue
+		case invoice.StatusVoid:
+			totals = &summary.ByStatus.Void
+		}
+		totals.Count++
+		totals.Total += item.Total
+		if item.Status == invoice.StatusOpen || item.Status == invoice.StatusOverdue {
+			summary.Outstanding += item.Total - item.AmountPaid
+		}
+	}
+	summaries := make([]CurrencySummary, 0, len(byCurrency))
+	for _, summary := range byCurrency {
+		summaries = append(summaries, *summary)
+	}
+	sort.Slice(summaries, func(i, j int) bool {
+		return summaries[i].Currency < summaries[j].Currency
+	})
+	return summaries, nil
+}
+
+func (m *MemoryStore) ListInvoiceAttachments(_ context.Context, tenantID, invoiceID string) ([]Attachment, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if item, ok := m.invoices[invoiceID]; !ok || item.TenantID != tenantID {
+		return []Attachment{}, nil
+	}
+	return append([]Attachment{}, m.attachments[invoiceID]...), nil
+}
+
+func (m *MemoryStore) GetInvoiceAttachment(_ context.Context, tenantID, invoiceID, id string) (Attachment, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if item, ok := m.invoices[invoiceID]; !ok || item.TenantID != tenantID {
+		return Attachment{}, ErrNotFound
+	}
+	for _, attachment := range m.attachments[invoiceID] {
+		if attachment.ID == id {
+			return attachment, nil
+		}
+	}
+	return Attachment{}, ErrNotFound
+}
+
+func (m *MemoryStore) CreateInvoiceAttachment(_ context.Context, item Attachment) (Attachment, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if existing, ok := m.invoices[item.InvoiceID]; !ok || existing.TenantID != item.TenantID {
+		return Attachment{}, false, ErrNotFound
+	}
+	for _, attachment := range m.attachments[item.InvoiceID] {
+		if attachment.Checksum == item.Checksum {
+			return attachment, false, nil
+		}
+	}
+	m.attachments[item.InvoiceID] = append(m.attachments[item.InvoiceID], item)
+	return item, true, nil
+}
+
+// LockAttachmentObject serializes every blob decision of the store; the
+// memory store only backs tests and development.
+func (m *MemoryStore) LockAttachmentObject(ctx context.Context, _, _ string, fn func(context.Context) error) error {
+	m.objectMu.Lock()
+	defer m.objectMu.Unlock()
+	return fn(ctx)
+}
+
+func (m *MemoryStore) AttachmentObjectStored(_ context.Context, tenantID, checksum string) (bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for _, attachments := range m.attachments {
+		for _, attachment := range attachments {
+			if attachment.TenantID == tenantID && attachment.Checksum == checksum {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+func (m *MemoryStore) CreateInvoiceShareLink(_ context.Context, link InvoiceShareLink) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if item, ok := m.invoices[link.InvoiceID]; !ok || item.TenantID != link.TenantID {
+		return ErrNotFound
+	}
+	if _, ok := m.shareLinks[link.ID]; ok {
+		return ErrConflict
+	}
+	m.shareLinks[link.ID] = link
+	return nil
+}
+
+func (m *MemoryStore) GetInvoiceShareLink(_ context.Context, id string) (InvoiceShareLink, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	link, ok := m.shareLinks[id]
+	if !ok {
+		return InvoiceShareLink{}, ErrNotFound
+	}
+	return link, nil
+}
+
+func (m *MemoryStore) RevokeInvoiceShareLinks(_ context.Context, tenantID, invoiceID string) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	revoked := 0
+	for id, link := range m.shareLinks {
+		if link.TenantID == tenantID && link.InvoiceID == invoiceID {
+			delete(m.shareLinks, id)
+			revoked++
+		}
+	}
+	return revoked, nil
+}
+
+func (m *MemoryStore) invoiceNumberTakenLocked(item invoice.Invoice) bool {
+	if item.Number == "" {
+		return false
+	}
+	for _, existing := range m.invoices {
+		if existing.ID != item.ID && existing.TenantID == item.TenantID && existing.Number == item.Number {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *MemoryStore) ClaimIdempotencyKey(_ context.Context, record IdempotencyRecord) (IdempotencyRecord, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	id := idempotencyRecordID(record.TenantID, record.Scope, record.Key)
+	if existing, ok := m.idempotencyKeys[id]; ok && existing.ExpiresAt > record.CreatedAt {
+		return existing, false, nil
+	}
+	m.idempotencyKeys[id] = record
+	return record, true, nil
+}
+
+func (m *MemoryStore) CompleteIdempotencyKey(_ context.Context, record IdempotencyRecord) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	id := idempotencyRecordID(record.TenantID, record.Scope, record.Key)
+	if _, ok := m.idempotencyKeys[id]; !ok {
+		return ErrNotFound
+	}
+	m.idempotencyKeys[id] = record
+	return nil
+}
+
+func (m *MemoryStore) ReleaseIdempotencyKey(_ context.Context, tenantID, scope, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.idempotencyKeys, idempotencyRecordID(tenantID, scope, key))
+	return nil
+}
+
+func idempotencyRecordID(tenantID, scope, key string) string {
+	return tenantID + "\x00" + scope + "\x00" + key
+}
+
+func (m *MemoryStore) ListWebhookSubscriptions(_ context.Context, tenantID string) ([]WebhookSubscription, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	items := make([]WebhookSubscription, 0)
+	for _, item := range m.webhooks {
+		if item.TenantID == tenantID {
+			item.Events = slices.Clone(item.Events)
+			items = append(items, item)
+		}
+	}
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].CreatedAt > items[j].CreatedAt
+	})
+	return items, nil
+}
+
+func (m *MemoryStore) GetWebhookSubscription(_ context.Context, tenantID, id string) (WebhookSubscription, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	item, ok := m.webhooks[id]
+	if !ok || item.TenantID != tenantID {
+		return WebhookSubscription{}, ErrNotFound
+	}
+	item.Events = slices.Clone(item.Events)
+	return item, nil
+}
+
+func (m *MemoryStore) CreateWebhookSubscription(_ context.Context, item WebhookSubscription) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	item.Events = slices.Clone(item.Events)
+	m.webhooks[item.ID] = item
+	return nil
+}
+
+func (m *MemoryStore) CreateWebhookDeliveries(_ context.Context, items []WebhookDelivery) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, item := range items {
+		m.deliveries[item.ID] = item
+	}
+	return nil
+}
+
+func (m *MemoryStore) ClaimWebhookDeliveries(_ context.Context, now, leaseUntil string, limit int) ([]WebhookDelivery, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	due := make([]WebhookDelivery, 0)
+	for _, item := range m.deliveries {
+		if item.Status == WebhookDeliveryPending && item.NextAttemptAt <= now {
+			due = append(due, item)
+		}
+	}
+	sort.Slice(due, func(i, j int) bool {
+		return due[i].NextAttemptAt < due[j].NextAttemptAt
+	})
+	if len(due) > limit {
+		due = due[:limit]
+	}
+	for i := range due {
+		stored := m.deliveries[due[i].ID]
+		stored.NextAttemptAt = leaseUntil
+		m.deliveries[stored.ID] = stored
+		due[i].AttemptLog = nil
+	}
+	return due, nil
+}
+
+func (m *MemoryStore) RecordWebhookAttempt(_ context.Context, delivery WebhookDelivery, attempt WebhookDeliveryAttempt) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	stored, ok := m.deliveries[delivery.ID]
+	if !ok || stored.TenantID != delivery.TenantID {
+		return ErrNotFound
+	}
+	delivery.AttemptLog = append(slices.Clone(stored.AttemptLog), attempt)
+	m.deliveries[delivery.ID] = delivery
+	return nil
+}
+
+func (m *MemoryStore) ListWebhookDeliveries(_ context.Context, tenantID, subscriptionID string, filter WebhookDeliveryFilter) ([]WebhookDelivery, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	items := make([]WebhookDelivery, 0)
+	for _, item := range m.deliveries {
+		if item.TenantID != tenantID || item.SubscriptionID != subscriptionID || (filter.Status != "" && item.Status != filter.Status) {
+			continue
+		}
+		if filter.After != nil && !filter.After.IsAfter(item) {
+			continue
+		}
+		item.AttemptLog = slices.Clone(item.AttemptLog)
+		items = append(items, item)
+	}
+	sort.Slice(items, func(i, j int) bool {
+		if items[i].CreatedAt != items[j].CreatedAt {
+			return items[i].CreatedAt > items[j].CreatedAt
+		}
+		return items[i].ID > items[j].ID
+	})
+	if filter.Limit > 0 && len(items) > filter.Limit {
+		items = items[:filter.Limit]
+	}
+	return items, nil
+}
+
+func (m *MemoryStore) GetWebhookDelivery(_ context.Context, tenantID, id string) (WebhookDelivery, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	item, ok := m.deliveries[id]
+	if !ok || item.TenantID != tenantID {
+		return WebhookDelivery{}, ErrNotFound
+	}
+	item.AttemptLog = nil
+	return item, nil
+}
+
+func (m *MemoryStore) ListCustomers(_ context.Context, tenantID string, includeDeleted bool) ([]Customer, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	items := make([]Customer, 0)
+	for _, item := range m.customers {
+		if item.TenantID == tenantID && (item.DeletedAt == "" || includeDeleted) {
+			items = append(items, item)
+		}
+	}
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].CreatedAt > items[j].CreatedAt
+	})
+	return items, nil
+}
+
+func (m *MemoryStore) GetCustomer(_ context.Context, tenantID, id string) (Customer, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	item, ok := m.customers[id]
+	if !ok || item.TenantID != tenantID {
+		return Customer{}, ErrNotFound
+	}
+	return item, nil
+}
+
+func (m *MemoryStore) CreateCustomer(ctx context.Context, item Customer) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.logChangeLocked(ctx, item.TenantID, AuditEntityCustomer, item.ID, AuditCustomerCreated, nil, item); err != nil {
+		return err
+	}
+	m.customers[item.ID] = item
+	return nil
+}
+
+func (m *MemoryStore) UpdateCustomer(ctx context.Context, item Customer) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	existing, ok := m.customers[item.ID]
+	if !ok || existing.TenantID != item.TenantID || existing.DeletedAt != "" {
+		return ErrNotFound
+	}
+	if err := m.logChangeLocked(ctx, item.TenantID, AuditEntityCustomer, item.ID, AuditCustomerUpdated, existing, item); err != nil {
+		return err
+	}
+	m.customers[item.ID] = item
+	return nil
+}
+
+func (m *MemoryStore) SoftDeleteCustomer(ctx context.Context, tenantID, id, deletedAt string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	existing, ok := m.customers[id]
+	if !ok || existing.TenantID != tenantID || existing.DeletedAt != "" {
+		return ErrNotFound
+	}
+	item := existing
+	item.DeletedAt = deletedAt
+	if err := m.logChangeLocked(ctx, tenantID, AuditEntityCustomer, id, AuditCustomerDeleted, existing, item); err != nil {
+		return err
+	}
+	m.customers[id] = item
+	return nil
+}
+
+func (m *MemoryStore) ListRecurringInvoices(_ context.Context, tenantID string) ([]RecurringInvoice, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	items := make([]RecurringInvoice, 0)
+	for _, item := range m.recurring {
+		if item.TenantID == tenantID {
+			items = append(items, cloneRecurringInvoice(item))
+		}
+	}
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].CreatedAt > items[j].CreatedAt
+	})
+	return items, nil
+}
+
+func (m *MemoryStore) GetRecurringInvoice(_ context.Context, tenantID, id string) (RecurringInvoice, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	item, ok := m.recurring[id]
+	if !ok || item.TenantID != tenantID {
+		return RecurringInvoice{}, ErrNotFound
+	}
+	return cloneRecurringInvoice(item), nil
+}
+
+func (m *MemoryStore) CreateRecurringInvoice(_ context.Context, item RecurringInvoice) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.recurring[item.ID] = cloneRecurringInvoice(item)
+	return nil
+}
+
+func (m *MemoryStore) UpdateRecurringInvoice(_ context.Context, item RecurringInvoice) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	existing, ok := m.recurring[item.ID]
+	if !ok || existing.TenantID != item.TenantID {
+		return ErrNotFound
+	}
+	existing.Status = item.Status
+	existing.NextRunAt = item.NextRunAt
+	existing.UpdatedAt = item.UpdatedAt
+	m.recurring[item.ID] = existing
+	return nil
+}
+
+func (m *MemoryStore) ListDueRecurringInvoices(_ context.Context, now string, limit int) ([]RecurringInvoice, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	due := make([]RecurringInvoice, 0)
+	for _, item := range m.recurring {
+		if item.Status == RecurringActive && item.NextRunAt <= now {
+			due = append(due, cloneRecurringInvoice(item))
+		}
+	}
+	sort.Slice(due, func(i, j int) bool {
+		return due[i].NextRunAt < due[j].NextRunAt
+	})
+	if len(due) > limit {
+		due = due[:limit]
+	}
+	return due, nil
+}
+
+func (m *MemoryStore) RecordRecurringRun(ctx context.Context, run RecurringRun, numbering invoice.NumberFormat) (invoice.Invoice, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	item, ok := m.recurring[run.RecurringInvoiceID]
+	if !ok || item.TenantID != run.TenantID {
+		return invoice.Invoice{}, false, ErrNotFound
+	}
+	if item.Status != RecurringActive || item.NextRunAt != run.ScheduledAt {
+		return invoice.Invoice{}, false, ErrConflict
+	}
+	key := run.TenantID + "/" + run.RecurringInvoiceID + "/" + run.Period
+	_, billed := m.recurringRuns[key]
+	generated := run.Invoice
+	if !billed {
+		if m.invoiceNumberTakenLocked(generated) {
+			return invoice.Invoice{}, false, ErrConflict
+		}
+		allocated := m.numberInvoicesLocked([]*invoice.Invoice{&generated}, numbering, nil)
+		generated.Items = slices.Clone(generated.Items)
+		if err := m.logChangeLocked(ctx, generated.TenantID, AuditEntityInvoice, generated.ID, AuditInvoiceCreated, nil, generated); err != nil {
+			return invoice.Invoice{}, false, err
+		}
+		m.invoices[generated.ID] = generated
+		maps.Copy(m.invoiceSeq, allocated)
+		m.recurringRuns[key] = generated.ID
+		item.LastInvoiceID = generated.ID
+	}
+	item.LastRunAt = run.ScheduledAt
+	item.NextRunAt = run.NextRunAt
+	item.UpdatedAt = run.Invoice.CreatedAt
+	m.recurring[item.ID] = item
+	return generated, !billed, nil
+}
+
+func cloneRecurringInvoice(item RecurringInvoice) RecurringInvoice {
+	item.Items = slices.Clone(item.Items)
+	return item
+}
+
+// purgeableInvoice reports whether item was soft-deleted before
+// deletedBefore.
+func purgeableInvoice(item invoice.Invoice, deletedBefore string) bool {
+	return item.DeletedAt != "" && item.DeletedAt < deletedBefore
+}
+
+func (m *MemoryStore) ListPurgeableInvoices(_ context.Context, deletedBefore string, limit int) ([]PurgeRecord, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	items := make([]PurgeRecord, 0)
+	for _, item := range m.invoices {
+		if purgeableInvoice(item, deletedBefore) {
+			items = append(items, PurgeRecord{TenantID: item.TenantID, ID: item.ID, DeletedAt: item.DeletedAt})
+		}
+	}
+	return oldestPurgeRecords(items, limit), nil
+}
+
+func (m *MemoryStore) PurgeInvoice(_ context.Context, tenantID, id, deletedBefore string) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	item, ok := m.invoices[id]
+	if !ok || item.TenantID != tenantID || !purgeableInvoice(item, deletedBefore) {
+		return nil, ErrNotFound
+	}
+	checksums := make([]string, 0)
+	for _, attachment := range m.attachments[id] {
+		if !slices.Contains(checksums, attachment.Checksum) {
+			checksums = append(checksums, attachment.Checksum)
+		}
+	}
+	sort.Strings(checksums)
+	delete(m.invoices, id)
+	delete(m.payments, id)
+	delete(m.creditNotes, id)
+	delete(m.reminders, id)
+	delete(m.attachments, id)
+	for linkID, link := range m.shareLinks {
+		if link.InvoiceID == id {
+			delete(m.shareLinks, linkID)
+		}
+	}
+	return checksums, nil
+}
+
+// orphanedObjectsLocked returns the object keys of the attachments of the
+// purged invoices that no other invoice of the tenant has.
+func (m *MemoryStore) orphanedObjectsLocked(tenantID string, purged map[string]bool) []string {
+	kept := map[string]bool{}
+	for invoiceID, attachments := range m.attachments {
+		for _, attachment := range attachments {
+			if attachment.TenantID == tenantID && !purged[invoiceID] {
+				kept[attachment.Checksum] = true
+			}
+		}
+	}
+	keys := make([]string, 0)
+	for invoiceID := range purged {
+		for _, attachment := range m.attachments[invoiceID] {
+			if !kept[attachment.Checksum] && !slices.Contains(keys, attachment.ObjectKey) {
+				keys = append(keys, attachment.ObjectKey)
+			}
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (m *MemoryStore) ListPurgeableCustomers(_ context.Context, deletedBefore string, limit int) ([]PurgeRecord, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	items := make([]PurgeRecord, 0)
+	for _, item := range m.customers {
+		if m.customerPurgeableLocked(item, deletedBefore) {
+			items = append(items, PurgeRecord{TenantID: item.TenantID, ID: item.ID, DeletedAt: item.DeletedAt})
+		}
+	}
+	return oldestPurgeRecords(items, limit), nil
+}
+
+func (m *MemoryStore) PurgeCustomer(_ context.Context, tenantID, id, deletedBefore string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	item, ok := m.customers[id]
+	if !ok || item.TenantID != tenantID || !m.customerPurgeableLocked(item, deletedBefore) {
+		return ErrNotFound
+	}
+	delete(m.customers, id)
+	return nil
+}
+
+func (m *MemoryStore) customerPurgeableLocked(item Customer, deletedBefore string) bool {
+	if item.DeletedAt == "" || item.DeletedAt >= deletedBefore {
+		return false
+	}
+	for _, bill := range m.invoices {
+		if bill.TenantID == item.TenantID && bill.CustomerID == item.ID && !purgeableInvoice(bill, deletedBefore) {
+			return false
+		}
+	}
+	for _, recurring := range m.recurring {
+		if recurring.TenantID == item.TenantID && recurring.CustomerID == item.ID {
+			return false
+		}
+	}
+	return true
+}
+
+func oldestPurgeRecords(items []PurgeRecord, limit int) []PurgeRecord {
+	sort.Slice(items, func(i, j int) bool {
+		if items[i].DeletedAt != items[j].DeletedAt {
+			return items[i].DeletedAt < items[j].DeletedAt
+		}
+		return items[i].ID < items[j].ID
+	})
+	if len(items) > limit {
+		items = items[:limit]
+	}
+	return items
+}
+
+func (m *MemoryStore) PurgeAuditEntries(_ context.Context, createdBefore string, limit int) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	deleted := 0
+	for id, item := range m.auditEvents {
+		if deleted == limit {
+			return deleted, nil
+		}
+		if item.CreatedAt < createdBefore {
+			delete(m.auditEvents, id)
+			deleted++
+		}
+	}
+	m.auditLog = slices.DeleteFunc(m.auditLog, func(item AuditLogEntry) bool {
+		if deleted == limit || item.CreatedAt >= createdBefore {
+			return false
+		}
+		deleted++
+		return true
+	})
+	return deleted, nil
+}
+
+func (m *MemoryStore) PurgeIdempotencyKeys(_ context.Context, expiredBefore string, limit int) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	deleted := 0
+	for id, record := range m.idempotencyKeys {
+		if deleted == limit {
+			break
+		}
+		if record.ExpiresAt < expiredBefore {
+			delete(m.idempotencyKeys, id)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+func (m *MemoryStore) CountPurgeable(_ context.Context, deletedBefore, auditBefore, expiredBefore string) (PurgeSummary, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var summary PurgeSummary
+	purged := map[string]map[string]bool{}
+	for _, item := range m.invoices {
+		if purgeableInvoice(item, deletedBefore) {
+			summary.Invoices++
+			if purged[item.TenantID] == nil {
+				purged[item.TenantID] = map[string]bool{}
+			}
+			purged[item.TenantID][item.ID] = true
+		}
+	}
+	for tenantID, invoices := range purged {
+		summary.Attachments += len(m.orphanedObjectsLocked(tenantID, invoices))
+	}
+	for _, item := range m.customers {
+		if m.customerPurgeableLocked(item, deletedBefore) {
+			summary.Customers++
+		}
+	}
+	for _, item := range m.auditEvents {
+		if item.CreatedAt < auditBefore {
+			summary.AuditEntries++
+		}
+	}
+	for _, item := range m.auditLog {
+		if item.CreatedAt < auditBefore {
+			summary.AuditEntries++
+		}
+	}
+	for _, record := range m.idempotencyKeys {
+		if record.ExpiresAt < expiredBefore {
+			summary.IdempotencyKeys++
+		}
+	}
+	return summary, nil
+}
+
+func (m *MemoryStore) TryExclusive(ctx context.Context, name string, fn func(context.Context) error) (bool, error) {
+	m.mu.Lock()
+	if m.exclusive[name] {
+		m.mu.Unlock()
+		return false, nil
+	}
+	m.exclusive[name] = true
+	m.mu.Unlock()
+	defer func() {
+		m.mu.Lock()
+		delete(m.exclusive, name)
+		m.mu.Unlock()
+	}()
+	return true, fn(ctx)
+}
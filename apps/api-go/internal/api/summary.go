@@ -0,0 +1,131 @@
+package api
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/apierror"
+)
+
+const defaultSummaryCacheTTL = 30 * time.Second
+
+// StatusTotals counts the invoices in one status and sums their totals.
+type StatusTotals struct {
+	Count int64 `json:"count"`
+	Total int64 `json:"total"`
+}
+
+type StatusBreakdown struct {
+	Draft   StatusTotals `json:"draft"`
+	Open    StatusTotals `json:"open"`
+	Paid    StatusTotals `json:"paid"`
+	Overdue StatusTotals `json:"overdue"`
+	Void    StatusTotals `json:"void"`
+}
+
+// CurrencySummary aggregates one currency's invoices. Outstanding is the
+// unpaid balance of the open and overdue invoices issued in the range;
+// Collected is what payments received in the range brought in, whenever
+// their invoices were issued, less the credit notes issued in the range.
+// Credited is the amount of those credit notes.
+type CurrencySummary struct {
+	Currency    string          `json:"currency"`
+	ByStatus    StatusBreakdown `json:"byStatus"`
+	Outstanding int64           `json:"outstanding"`
+	Collected   int64           `json:"collected"`
+	Credited    int64           `json:"credited"`
+}
+
+// InvoiceSummary is the dashboard view of a date range, with money kept
+// apart per currency.
+type InvoiceSummary struct {
+	IssuedFrom string            `json:"issuedFrom"`
+	IssuedTo   string            `json:"issuedTo"`
+	Currencies []CurrencySummary `json:"currencies"`
+}
+
+// handleInvoiceSummary serves GET /v1/invoices/summary. Results are cached
+// per tenant and range for a short while, so a dashboard refreshing every
+// few seconds costs one aggregate query per TTL.
+func (s *Server) handleInvoiceSummary(w http.ResponseWriter, r *http.Request, session Session) {
+	if r.Method != http.MethodGet {
+		apierror.WriteError(w, http.StatusMethodNotAllowed, errMethodNotAllowed)
+		return
+	}
+	query := r.URL.Query()
+	from, err := parseDateBound(query.Get("issuedFrom"), false)
+	if err != nil {
+		writeValidationError(w, apierror.Field("issuedFrom", err.Error()))
+		return
+	}
+	to, err := parseDateBound(query.Get("issuedTo"), true)
+	if err != nil {
+		writeValidationError(w, apierror.Field("issuedTo", err.Error()))
+		return
+	}
+	if from == "" || to == "" {
+		apierror.WriteError(w, http.StatusBadRequest, requiredFields("summaries require issuedFrom and issuedTo", map[string]bool{
+			"issuedFrom": from == "",
+			"issuedTo":   to == "",
+		}))
+		return
+	}
+	if to < from {
+		writeValidationError(w, apierror.Field("issuedTo", "must not be before issuedFrom"))
+		return
+	}
+
+	key := session.TenantID + "|" + from + "|" + to
+	summary, ok := s.summaryCache.get(key)
+	if !ok {
+		currencies, err := s.store.SummarizeInvoices(r.Context(), session.TenantID, from, to)
+		if err != nil {
+			s.writeInternalError(w, err)
+			return
+		}
+		summary = InvoiceSummary{IssuedFrom: from, IssuedTo: to, Currencies: currencies}
+		s.summaryCache.put(key, summary)
+	}
+	writeJSON(w, http.StatusOK, summary)
+}
+
+// summaryCache keeps recent summaries until they are ttl old.
+type summaryCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	entries map[string]summaryCacheEntry
+}
+
+type summaryCacheEntry struct {
+	summary   InvoiceSummary
+	expiresAt time.Time
+}
+
+func newSummaryCache(ttl time.Duration) *summaryCache {
+	return &summaryCache{ttl: ttl, entries: make(map[string]summaryCacheEntry)}
+}
+
+func (c *summaryCache) get(key string) (InvoiceSummary, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[key]
+	if !ok || !time.Now().Before(entry.expiresAt) {
+		return InvoiceSummary{}, false
+	}
+	return entry.summary, true
+}
+
+// put stores summary and drops the entries that have expired, so the cache
+// only ever holds the ranges asked for within the last ttl.
+func (c *summaryCache) put(key string, summary InvoiceSummary) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	for existing, entry := range c.entries {
+		if !now.Before(entry.expiresAt) {
+			delete(c.entries, existing)
+		}
+	}
+	c.entries[key] = summaryCacheEntry{summary: summary, expiresAt: now.Add(c.ttl)}
+}
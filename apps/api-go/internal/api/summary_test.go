@@ -0,0 +1,96 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/invoice"
+)
+
+func TestInvoiceSummaryAggregatesPerCurrency(t *testing.T) {
+	server := NewServer()
+	cookie := loginForTest(t, server)
+	customer := createCustomerForTest(t, server, cookie, CustomerCreateRequest{Name: "Acme"})
+	inRange := "2026-03-10T09:00:00Z"
+	create := func(number, currency string, total int64, status invoice.Status, issuedAt string) invoice.Invoice {
+		req := InvoiceCreateRequest{Number: number, CustomerID: customer.ID, Currency: currency, Subtotal: total, Total: total, Status: status, IssuedAt: issuedAt}
+		if status == invoice.StatusPaid {
+			req.PaidAt = issuedAt
+		}
+		return createInvoiceForTest(t, server, cookie, req)
+	}
+	create("INV-0001", "ILS", 1000, invoice.StatusDraft, inRange)
+	open := create("INV-0002", "ILS", 5000, invoice.StatusOpen, inRange)
+	create("INV-0003", "ILS", 2500, invoice.StatusPaid, inRange)
+	create("INV-0004", "USD", 700, invoice.StatusOpen, inRange)
+	create("INV-0005", "USD", 300, invoice.StatusVoid, inRange)
+	earlier := create("INV-0006", "EUR", 900, invoice.StatusOpen, "2026-01-15T09:00:00Z")
+	recordPaymentForTest(t, server, cookie, open.ID, PaymentCreateRequest{Amount: 1500, Method: "bank_transfer", ReceivedAt: "2026-03-20T12:00:00Z"})
+	// Collected counts payments received in the range, whenever the
+	// invoice was issued, but not those received after it.
+	recordPaymentForTest(t, server, cookie, earlier.ID, PaymentCreateRequest{Amount: 400, Method: "card", ReceivedAt: "2026-03-02T12:00:00Z"})
+	recordPaymentForTest(t, server, cookie, earlier.ID, PaymentCreateRequest{Amount: 100, Method: "card", ReceivedAt: "2026-04-02T12:00:00Z"})
+
+	summary := getInvoiceSummaryForTest(t, server, cookie, "issuedFrom=2026-03-01&issuedTo=2026-03-31")
+	if summary.IssuedFrom != "2026-03-01T00:00:00Z" || summary.IssuedTo != "2026-03-31T23:59:59Z" || len(summary.Currencies) != 3 {
+		t.Fatalf("unexpected summary %#v", summary)
+	}
+	eur, ils, usd := summary.Currencies[0], summary.Currencies[1], summary.Currencies[2]
+	if eur.Currency != "EUR" || eur.ByStatus.Open.Count != 0 || eur.Outstanding != 0 || eur.Collected != 400 {
+		t.Fatalf("expected EUR to only carry the collected payment, got %#v", eur)
+	}
+	if ils.Currency != "ILS" || ils.ByStatus.Draft != (StatusTotals{Count: 1, Total: 1000}) || ils.ByStatus.Open != (StatusTotals{Count: 1, Total: 5000}) ||
+		ils.ByStatus.Paid != (StatusTotals{Count: 1, Total: 2500}) || ils.Outstanding != 3500 || ils.Collected != 1500 {
+		t.Fatalf("unexpected ILS summary %#v", ils)
+	}
+	if usd.Currency != "USD" || usd.ByStatus.Open.Total != 700 || usd.ByStatus.Void != (StatusTotals{Count: 1, Total: 300}) || usd.Outstanding != 700 || usd.Collected != 0 {
+		t.Fatalf("unexpected USD summary %#v", usd)
+	}
+
+	rec := doJSON(t, server, cookie, http.MethodGet, "/v1/invoices/summary?issuedFrom=2026-03-01", nil)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 without issuedTo, got %d", rec.Code)
+	}
+	rec = doJSON(t, server, cookie, http.MethodGet, "/v1/invoices/summary?issuedFrom=2026-03-31&issuedTo=2026-03-01", nil)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for a reversed range, got %d", rec.Code)
+	}
+}
+
+func TestInvoiceSummaryIsCachedBriefly(t *testing.T) {
+	cached := NewServer()
+	uncached := NewServer(WithStore(cached.store), WithSummaryCacheTTL(time.Nanosecond))
+	cookie := loginForTest(t, cached)
+	customer := createCustomerForTest(t, cached, cookie, CustomerCreateRequest{Name: "Acme"})
+	query := "issuedFrom=2026-03-01&issuedTo=2026-03-31"
+	if summary := getInvoiceSummaryForTest(t, cached, cookie, query); len(summary.Currencies) != 0 {
+		t.Fatalf("expected an empty summary, got %#v", summary)
+	}
+	createInvoiceForTest(t, cached, cookie, InvoiceCreateRequest{Number: "INV-0001", CustomerID: customer.ID, Currency: "ILS", Subtotal: 100, Total: 100, Status: invoice.StatusOpen, IssuedAt: "2026-03-10T09:00:00Z"})
+
+	if summary := getInvoiceSummaryForTest(t, cached, cookie, query); len(summary.Currencies) != 0 {
+		t.Fatalf("expected the cached summary within the TTL, got %#v", summary)
+	}
+	if summary := getInvoiceSummaryForTest(t, cached, cookie, "issuedFrom=2026-03-01&issuedTo=2026-03-30"); len(summary.Currencies) != 1 {
+		t.Fatalf("expected another range to be aggregated afresh, got %#v", summary)
+	}
+	if summary := getInvoiceSummaryForTest(t, uncached, cookie, query); len(summary.Currencies) != 1 || summary.Currencies[0].Outstanding != 100 {
+		t.Fatalf("expected an expired summary to be aggregated again, got %#v", summary)
+	}
+}
+
+func getInvoiceSummaryForTest(t *testing.T, server *Server, cookie *http.Cookie, query string) InvoiceSummary {
+	t.Helper()
+
+	rec := doJSON(t, server, cookie, http.MethodGet, "/v1/invoices/summary?"+query, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected summary 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var summary InvoiceSummary
+	if err := json.NewDecoder(rec.Body).Decode(&summary); err != nil {
+		t.Fatalf("decode summary: %v", err)
+	}
+	return summary
+}
@@ -0,0 +1,75 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/apierror"
+)
+
+const defaultRequestTimeout = 30 * time.Second
+
+var errRequestTimeout = apierror.New(apierror.CodeRequestTimeout, "the request took too long and was cancelled")
+
+// withRequestTimeout puts a deadline on the request's context, so the
+// queries a handler runs are cancelled in Postgres once the client can no
+// longer be answered in time. Streamed exports, imports, attachment
+// transfers and job event streams are left without one: their length
+// depends on the data or the job, not on a stuck query.
+func (s *Server) withRequestTimeout(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.requestTimeout <= 0 || isStreamingRequest(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		ctx, cancel := context.WithTimeout(r.Context(), s.requestTimeout)
+		defer cancel()
+		next.ServeHTTP(&deadlineWriter{ResponseWriter: w, ctx: ctx}, r.WithContext(ctx))
+	})
+}
+
+func isStreamingRequest(r *http.Request) bool {
+	if r.URL.Path == "/v1/invoices/export" || r.URL.Path == "/v1/invoices/import" || isEventStreamRequest(r) {
+		return true
+	}
+	id, action := trimPrefixID(r.URL.Path, "/v1/invoices/")
+	sub, _, _ := strings.Cut(action, "/")
+	return strings.HasPrefix(r.URL.Path, "/v1/invoices/") && id != "" && sub == "attachments"
+}
+
+// deadlineWriter carries the request's deadline to writeInternalError,
+// which is only handed the writer.
+type deadlineWriter struct {
+	http.ResponseWriter
+	ctx context.Context
+}
+
+func (w *deadlineWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (w *deadlineWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// requestTimedOut reports whether the deadline of the request served
+// through w has passed. Like recordRequestError it looks through every
+// wrapping writer.
+func requestTimedOut(w http.ResponseWriter) bool {
+	for w != nil {
+		if deadline, ok := w.(*deadlineWriter); ok {
+			return errors.Is(deadline.ctx.Err(), context.DeadlineExceeded)
+		}
+		unwrapper, ok := w.(interface{ Unwrap() http.ResponseWriter })
+		if !ok {
+			return false
+		}
+		w = unwrapper.Unwrap()
+	}
+	return false
+}
@@ -0,0 +1,58 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/apierror"
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/invoice"
+)
+
+// slowStore blocks listings until their context is done and then fails the
+// way Postgres does for a cancelled statement.
+type slowStore struct {
+	*MemoryStore
+}
+
+func (s slowStore) ListInvoices(ctx context.Context, _ string, _ invoice.ListFilter) ([]invoice.Invoice, error) {
+	<-ctx.Done()
+	return nil, errors.New("ERROR: canceling statement due to user request (SQLSTATE 57014)")
+}
+
+func TestRequestTimeoutCancelsSlowRequests(t *testing.T) {
+	server := NewServer(WithStore(slowStore{NewMemoryStore()}), WithRequestTimeout(20*time.Millisecond))
+	cookie := loginForTest(t, server)
+
+	started := time.Now()
+	rec := doJSON(t, server, cookie, http.MethodGet, "/v1/invoices", nil)
+	if code := decodeErrorCodeForTest(t, rec); rec.Code != http.StatusServiceUnavailable || code != apierror.CodeRequestTimeout {
+		t.Fatalf("expected 503 %s, got %d %s", apierror.CodeRequestTimeout, rec.Code, code)
+	}
+	if elapsed := time.Since(started); elapsed > time.Second {
+		t.Fatalf("expected the request to give up at its deadline, took %s", elapsed)
+	}
+}
+
+func TestRequestTimeoutLeavesStreamsAlone(t *testing.T) {
+	server := NewServer(WithRequestTimeout(time.Millisecond))
+	for path, streaming := range map[string]bool{
+		"/v1/invoices/export":                         true,
+		"/v1/invoices/inv-1/attachments":              true,
+		"/v1/invoices/inv-1/attachments/a-1/download": true,
+		"/v1/invoices":                                false,
+		"/v1/invoices/inv-1/pdf":                      false,
+	} {
+		var deadline bool
+		handler := server.withRequestTimeout(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+			_, deadline = r.Context().Deadline()
+		}))
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+		if deadline == streaming {
+			t.Fatalf("%s: expected a deadline %v, got %v", path, !streaming, deadline)
+		}
+	}
+}
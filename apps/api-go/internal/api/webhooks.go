@@ -0,0 +1,535 @@
+package api
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/apierror"
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/invoice"
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/webhook"
+)
+
+const (
+	EventInvoiceCreated         = "invoice.created"
+	EventInvoicePaid            = "invoice.paid"
+	EventInvoiceOverdue         = "invoice.overdue"
+	EventInvoicePaymentReceived = "invoice.payment_received"
+)
+
+var webhookEvents = []string{EventInvoiceCreated, EventInvoicePaid, EventInvoiceOverdue, EventInvoicePaymentReceived}
+
+const (
+	WebhookDeliveryPending   = "pending"
+	WebhookDeliverySucceeded = "succeeded"
+	WebhookDeliveryFailed    = "failed"
+)
+
+var webhookDeliveryStatuses = []string{WebhookDeliveryPending, WebhookDeliverySucceeded, WebhookDeliveryFailed}
+
+const webhookDeliveriesPath = "/v1/webhooks/deliveries/"
+
+const (
+	minWebhookSecretLength     = 16
+	webhookBatchSize           = 50
+	webhookSendTimeout         = 10 * time.Second
+	defaultWebhookPollInterval = 5 * time.Second
+	// webhookLease is how long a claimed delivery stays invisible to other
+	// workers. It must outlast a send, so a crashed worker's deliveries come
+	// back instead of being stuck.
+	webhookLease = 2 * webhookSendTimeout
+	// defaultWebhookDeliveryLimit and maxWebhookDeliveryLimit bound a page
+	// of GET /v1/webhooks/{id}/deliveries, as for the invoice list.
+	defaultWebhookDeliveryLimit = 50
+	maxWebhookDeliveryLimit     = 200
+)
+
+// WebhookSubscription is a receiver URL for a set of events. Secret is only
+// returned when the subscription is created.
+type WebhookSubscription struct {
+	ID        string   `json:"id"`
+	TenantID  string   `json:"tenantId"`
+	URL       string   `json:"url"`
+	Events    []string `json:"events"`
+	Secret    string   `json:"secret,omitempty"`
+	CreatedAt string   `json:"createdAt"`
+	UpdatedAt string   `json:"updatedAt"`
+}
+
+type WebhookSubscriptionCreateRequest struct {
+	URL    string   `json:"url"`
+	Events []string `json:"events"`
+	Secret string   `json:"secret"`
+}
+
+type WebhookSubscriptionList struct {
+	Items []WebhookSubscription `json:"items"`
+}
+
+// WebhookEvent is the JSON body POSTed to receivers.
+type WebhookEvent struct {
+	ID        string `json:"id"`
+	Type      string `json:"type"`
+	CreatedAt string `json:"createdAt"`
+	Data      any    `json:"data"`
+}
+
+// WebhookDelivery is one event queued for one subscription. It stays
+// pending until the receiver answers 2xx or the retry policy gives up. A
+// replay is a new delivery of the same payload, pointing back at the one it
+// re-sends through ReplayOf.
+type WebhookDelivery struct {
+	ID             string                   `json:"id"`
+	TenantID       string                   `json:"tenantId"`
+	SubscriptionID string                   `json:"subscriptionId"`
+	EventID        string                   `json:"eventId"`
+	Event          string                   `json:"event"`
+	Payload        json.RawMessage          `json:"payload"`
+	Status         string                   `json:"status"`
+	Attempts       int                      `json:"attempts"`
+	NextAttemptAt  string                   `json:"nextAttemptAt,omitempty"`
+	LastStatusCode int                      `json:"lastStatusCode,omitempty"`
+	LastError      string                   `json:"lastError,omitempty"`
+	ReplayOf       string                   `json:"replayOf,omitempty"`
+	AttemptLog     []WebhookDeliveryAttempt `json:"attemptLog,omitempty"`
+	CreatedAt      string                   `json:"createdAt"`
+	UpdatedAt      string                   `json:"updatedAt"`
+}
+
+// WebhookDeliveryAttempt records a single send, successful or not, with
+// the start of whatever the receiver answered.
+type WebhookDeliveryAttempt struct {
+	ID              string `json:"id"`
+	DeliveryID      string `json:"deliveryId"`
+	Attempt         int    `json:"attempt"`
+	StatusCode      int    `json:"statusCode,omitempty"`
+	Error           string `json:"error,omitempty"`
+	ResponseSnippet string `json:"responseSnippet,omitempty"`
+	DurationMs      int64  `json:"durationMs"`
+	CreatedAt       string `json:"createdAt"`
+}
+
+type WebhookDeliveryList struct {
+	Items      []WebhookDelivery `json:"items"`
+	NextCursor string            `json:"nextCursor,omitempty"`
+}
+
+// WebhookDeliveryFilter selects a page of a subscription's deliveries,
+// newest first. A non-empty Status keeps only the deliveries in it.
+type WebhookDeliveryFilter struct {
+	Status string
+	Limit  int
+	After  *WebhookDeliveryCursor
+}
+
+// WebhookDeliveryCursor is the keyset position after the last delivery of a
+// page. IDs compare bytewise, which breaks ties between deliveries created
+// in the same second.
+type WebhookDeliveryCursor struct {
+	CreatedAt string `json:"createdAt"`
+	ID        string `json:"id"`
+}
+
+func webhookDeliveryCursorAfter(item WebhookDelivery) WebhookDeliveryCursor {
+	return WebhookDeliveryCursor{CreatedAt: item.CreatedAt, ID: item.ID}
+}
+
+// Encode returns the opaque form of c handed to clients.
+func (c WebhookDeliveryCursor) Encode() string {
+	payload, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(payload)
+}
+
+// IsAfter reports whether item comes after c in newest-first order.
+func (c WebhookDeliveryCursor) IsAfter(item WebhookDelivery) bool {
+	if item.CreatedAt != c.CreatedAt {
+		return item.CreatedAt < c.CreatedAt
+	}
+	return item.ID < c.ID
+}
+
+func decodeWebhookDeliveryCursor(value string) (WebhookDeliveryCursor, error) {
+	var cursor WebhookDeliveryCursor
+	payload, err := base64.RawURLEncoding.DecodeString(value)
+	if err == nil {
+		err = json.Unmarshal(payload, &cursor)
+	}
+	if err == nil && cursor.ID != "" {
+		_, err = time.Parse(time.RFC3339, cursor.CreatedAt)
+	}
+	if err != nil || cursor.ID == "" {
+		return WebhookDeliveryCursor{}, apierror.Field("cursor", "is not a valid cursor")
+	}
+	return cursor, nil
+}
+
+// parseWebhookDeliveryFilter reads the status, limit and cursor parameters
+// of a delivery listing.
+func parseWebhookDeliveryFilter(query url.Values) (WebhookDeliveryFilter, error) {
+	filter := WebhookDeliveryFilter{Status: query.Get("status"), Limit: defaultWebhookDeliveryLimit}
+	if filter.Status != "" && !slices.Contains(webhookDeliveryStatuses, filter.Status) {
+		return filter, apierror.Field("status", "must be one of "+strings.Join(webhookDeliveryStatuses, ", "))
+	}
+	if value := query.Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit <= 0 {
+			return filter, apierror.Field("limit", "must be a positive integer")
+		}
+		filter.Limit = min(limit, maxWebhookDeliveryLimit)
+	}
+	if value := query.Get("cursor"); value != "" {
+		cursor, err := decodeWebhookDeliveryCursor(value)
+		if err != nil {
+			return filter, err
+		}
+		filter.After = &cursor
+	}
+	return filter, nil
+}
+
+func (s *Server) handleWebhooks(w http.ResponseWriter, r *http.Request, session Session) {
+	switch r.Method {
+	case http.MethodGet:
+		items, err := s.store.ListWebhookSubscriptions(r.Context(), session.TenantID)
+		if err != nil {
+			s.writeInternalError(w, err)
+			return
+		}
+		for i := range items {
+			items[i].Secret = ""
+		}
+		writeJSON(w, http.StatusOK, WebhookSubscriptionList{Items: items})
+	case http.MethodPost:
+		var req WebhookSubscriptionCreateRequest
+		if err := decodeJSON(r, &req); err != nil {
+			writeBodyError(w, err)
+			return
+		}
+		item, err := s.newWebhookSubscription(session.TenantID, req)
+		if err != nil {
+			writeValidationError(w, err)
+			return
+		}
+		if err := s.store.CreateWebhookSubscription(r.Context(), item); err != nil {
+			s.writeInternalError(w, err)
+			return
+		}
+		if err := s.recordAudit(r.Context(), session.TenantID, routeCtx(r).RequestID, "webhook.created", "webhook", item.ID, "Subscribed "+item.URL); err != nil {
+			s.writeInternalError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusCreated, item)
+	default:
+		apierror.WriteError(w, http.StatusMethodNotAllowed, errMethodNotAllowed)
+	}
+}
+
+func (s *Server) handleWebhookDetail(w http.ResponseWriter, r *http.Request, session Session) {
+	// Subscription IDs start with "whk-", so they cannot be "deliveries".
+	if strings.HasPrefix(r.URL.Path, webhookDeliveriesPath) {
+		s.handleWebhookDeliveryDetail(w, r, session)
+		return
+	}
+	id, action := trimPrefixID(r.URL.Path, "/v1/webhooks/")
+	if action != "deliveries" {
+		apierror.WriteError(w, http.StatusNotFound, errRouteNotFound)
+		return
+	}
+	if r.Method != http.MethodGet {
+		apierror.WriteError(w, http.StatusMethodNotAllowed, errMethodNotAllowed)
+		return
+	}
+	filter, err := parseWebhookDeliveryFilter(r.URL.Query())
+	if err != nil {
+		writeValidationError(w, err)
+		return
+	}
+	if _, err := s.store.GetWebhookSubscription(r.Context(), session.TenantID, id); err != nil {
+		s.writeLookupError(w, err, "webhook")
+		return
+	}
+	// One row past the page tells whether another page follows.
+	pageSize := filter.Limit
+	filter.Limit = pageSize + 1
+	items, err := s.store.ListWebhookDeliveries(r.Context(), session.TenantID, id, filter)
+	if err != nil {
+		s.writeInternalError(w, err)
+		return
+	}
+	page := WebhookDeliveryList{Items: items}
+	if len(items) > pageSize {
+		page.Items = items[:pageSize]
+		page.NextCursor = webhookDeliveryCursorAfter(page.Items[pageSize-1]).Encode()
+	}
+	writeJSON(w, http.StatusOK, page)
+}
+
+// handleWebhookDeliveryDetail serves /v1/webhooks/deliveries/{id}/..., whose
+// only route is the replay.
+func (s *Server) handleWebhookDeliveryDetail(w http.ResponseWriter, r *http.Request, session Session) {
+	id, action := trimPrefixID(r.URL.Path, webhookDeliveriesPath)
+	if id == "" || action != "replay" {
+		apierror.WriteError(w, http.StatusNotFound, errRouteNotFound)
+		return
+	}
+	if r.Method != http.MethodPost {
+		apierror.WriteError(w, http.StatusMethodNotAllowed, errMethodNotAllowed)
+		return
+	}
+	item, err := s.store.GetWebhookDelivery(r.Context(), session.TenantID, id)
+	if err != nil {
+		s.writeLookupError(w, err, "webhook delivery")
+		return
+	}
+	s.withIdempotency(w, r, session, func(w http.ResponseWriter) {
+		s.replayWebhookDelivery(w, r, session, item)
+	})
+}
+
+// replayWebhookDelivery queues the payload of a finished delivery again for
+// the worker to send. The replay carries the same event, ID included, so
+// receivers can drop it if they already have it; only X-Webhook-Delivery
+// differs. Pending deliveries are refused: they are still being retried.
+func (s *Server) replayWebhookDelivery(w http.ResponseWriter, r *http.Request, session Session, item WebhookDelivery) {
+	if item.Status == WebhookDeliveryPending {
+		apierror.WriteError(w, http.StatusConflict, apierror.New(apierror.CodeDeliveryNotReplayable, "pending deliveries are still being retried and cannot be replayed"))
+		return
+	}
+	now := utcNow()
+	replay := WebhookDelivery{
+		ID:             s.newID("whd"),
+		TenantID:       item.TenantID,
+		SubscriptionID: item.SubscriptionID,
+		EventID:        item.EventID,
+		Event:          item.Event,
+		Payload:        item.Payload,
+		Status:         WebhookDeliveryPending,
+		NextAttemptAt:  now,
+		ReplayOf:       item.ID,
+		CreatedAt:      now,
+		UpdatedAt:      now,
+	}
+	if err := s.store.CreateWebhookDeliveries(r.Context(), []WebhookDelivery{replay}); err != nil {
+		s.writeInternalError(w, err)
+		return
+	}
+	if err := s.recordAudit(r.Context(), session.TenantID, routeCtx(r).RequestID, "webhook.delivery_replayed", "webhook", item.SubscriptionID, fmt.Sprintf("Replayed %s delivery %s", item.Event, item.ID)); err != nil {
+		s.writeInternalError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusAccepted, replay)
+}
+
+func (s *Server) newWebhookSubscription(tenantID string, req WebhookSubscriptionCreateRequest) (WebhookSubscription, error) {
+	target, err := url.Parse(strings.TrimSpace(req.URL))
+	if err != nil || (target.Scheme != "https" && target.Scheme != "http") || target.Host == "" {
+		return WebhookSubscription{}, apierror.Field("url", "must be an absolute http or https URL")
+	}
+	if !s.allowPrivateWebhooks {
+		if err := webhook.CheckHost(target.Hostname()); err != nil {
+			return WebhookSubscription{}, apierror.Field("url", "must not point at a loopback, private, link-local or unspecified address")
+		}
+	}
+	if len(req.Events) == 0 {
+		return WebhookSubscription{}, apierror.Field("events", "must not be empty")
+	}
+	events := make([]string, 0, len(req.Events))
+	for _, event := range req.Events {
+		if !slices.Contains(webhookEvents, event) {
+			return WebhookSubscription{}, apierror.Field("events", fmt.Sprintf("contains unknown event %q (expected one of %s)", event, strings.Join(webhookEvents, ", ")))
+		}
+		if !slices.Contains(events, event) {
+			events = append(events, event)
+		}
+	}
+	secret := req.Secret
+	if secret == "" {
+		secret, err = newWebhookSecret()
+		if err != nil {
+			return WebhookSubscription{}, err
+		}
+	} else if len(secret) < minWebhookSecretLength {
+		return WebhookSubscription{}, apierror.Field("secret", fmt.Sprintf("must be at least %d characters", minWebhookSecretLength))
+	}
+	now := utcNow()
+	return WebhookSubscription{
+		ID:        s.newID("whk"),
+		TenantID:  tenantID,
+		URL:       target.String(),
+		Events:    events,
+		Secret:    secret,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}, nil
+}
+
+func newWebhookSecret() (string, error) {
+	var buf [24]byte
+	if _, err := rand.Read(buf[:]); err != nil {
+		return "", fmt.Errorf("generate webhook secret: %w", err)
+	}
+	return "whsec_" + hex.EncodeToString(buf[:]), nil
+}
+
+// publishWebhookEvent queues event for every subscription of the tenant that
+// asked for it. Sending happens later, in DeliverWebhooks.
+func (s *Server) publishWebhookEvent(ctx context.Context, tenantID, event string, data any) error {
+	subscriptions, err := s.store.ListWebhookSubscriptions(ctx, tenantID)
+	if err != nil {
+		return err
+	}
+	eventID := s.newID("evt")
+	now := utcNow()
+	var deliveries []WebhookDelivery
+	var payload []byte
+	for _, subscription := range subscriptions {
+		if !slices.Contains(subscription.Events, event) {
+			continue
+		}
+		if payload == nil {
+			payload, err = json.Marshal(WebhookEvent{ID: eventID, Type: event, CreatedAt: now, Data: data})
+			if err != nil {
+				return fmt.Errorf("marshal webhook event: %w", err)
+			}
+		}
+		deliveries = append(deliveries, WebhookDelivery{
+			ID:             s.newID("whd"),
+			TenantID:       tenantID,
+			SubscriptionID: subscription.ID,
+			EventID:        eventID,
+			Event:          event,
+			Payload:        payload,
+			Status:         WebhookDeliveryPending,
+			NextAttemptAt:  now,
+			CreatedAt:      now,
+			UpdatedAt:      now,
+		})
+	}
+	if len(deliveries) == 0 {
+		return nil
+	}
+	return s.store.CreateWebhookDeliveries(ctx, deliveries)
+}
+
+// invoiceStatusEvent names the webhook event for a status change, or "" when
+// the change is not one subscribers hear about.
+func invoiceStatusEvent(from, to invoice.Status) string {
+	if from == to {
+		return ""
+	}
+	switch to {
+	case invoice.StatusPaid:
+		return EventInvoicePaid
+	case invoice.StatusOverdue:
+		return EventInvoiceOverdue
+	}
+	return ""
+}
+
+// DeliverWebhooks sends every delivery that is due, once, and reports how
+// many it attempted. Failed sends are rescheduled with exponential backoff
+// until the retry policy is exhausted.
+func (s *Server) DeliverWebhooks(ctx context.Context) (int, error) {
+	now := time.Now().UTC()
+	due, err := s.store.ClaimWebhookDeliveries(ctx, now.Format(time.RFC3339), now.Add(webhookLease).Format(time.RFC3339), webhookBatchSize)
+	if err != nil {
+		return 0, err
+	}
+	for _, delivery := range due {
+		if err := s.attemptWebhookDelivery(ctx, delivery); err != nil {
+			return 0, err
+		}
+	}
+	return len(due), nil
+}
+
+// RunWebhookWorker calls DeliverWebhooks every interval until ctx is done.
+func (s *Server) RunWebhookWorker(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = defaultWebhookPollInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		for {
+			sent, err := s.DeliverWebhooks(ctx)
+			if err != nil {
+				s.logger.ErrorContext(ctx, "deliver webhooks", "error", err)
+			}
+			if err != nil || sent < webhookBatchSize {
+				break
+			}
+		}
+	}
+}
+
+func (s *Server) attemptWebhookDelivery(ctx context.Context, delivery WebhookDelivery) error {
+	attempt := WebhookDeliveryAttempt{
+		ID:         s.newID("wha"),
+		DeliveryID: delivery.ID,
+		Attempt:    delivery.Attempts + 1,
+	}
+	// A delivery whose subscription is gone can never succeed.
+	permanent := false
+	subscription, err := s.store.GetWebhookSubscription(ctx, delivery.TenantID, delivery.SubscriptionID)
+	switch {
+	case errors.Is(err, ErrNotFound):
+		attempt.Error = "subscription no longer exists"
+		permanent = true
+	case err != nil:
+		return err
+	default:
+		result, sendErr := s.webhookSender.Send(ctx, webhook.Request{
+			URL:        subscription.URL,
+			Secret:     subscription.Secret,
+			Event:      delivery.Event,
+			DeliveryID: delivery.ID,
+			Body:       delivery.Payload,
+		})
+		attempt.StatusCode = result.StatusCode
+		attempt.ResponseSnippet = result.Snippet
+		attempt.DurationMs = result.Duration.Milliseconds()
+		if sendErr != nil {
+			attempt.Error = sendErr.Error()
+		}
+	}
+
+	now := time.Now().UTC()
+	attempt.CreatedAt = now.Format(time.RFC3339)
+	delivery.Attempts = attempt.Attempt
+	delivery.LastStatusCode = attempt.StatusCode
+	delivery.LastError = attempt.Error
+	delivery.UpdatedAt = attempt.CreatedAt
+	switch {
+	case attempt.Error == "":
+		delivery.Status = WebhookDeliverySucceeded
+		delivery.NextAttemptAt = ""
+	case permanent || s.webhookRetry.Exhausted(attempt.Attempt):
+		delivery.Status = WebhookDeliveryFailed
+		delivery.NextAttemptAt = ""
+	default:
+		delivery.NextAttemptAt = now.Add(s.webhookRetry.Delay(attempt.Attempt)).Format(time.RFC3339)
+	}
+	if delivery.Status != WebhookDeliveryPending {
+		s.metrics.webhookDeliveries.WithLabelValues(delivery.Status).Inc()
+	}
+	if attempt.Error != "" {
+		s.logger.WarnContext(ctx, "webhook delivery failed", "delivery_id", delivery.ID, "attempt", attempt.Attempt, "status", delivery.Status, "error", attempt.Error)
+	}
+	return s.store.RecordWebhookAttempt(ctx, delivery, attempt)
+}
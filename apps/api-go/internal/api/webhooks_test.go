@@ -0,0 +1,363 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/apierror"
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/invoice"
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/webhook"
+)
+
+func TestWebhookSubscriptionsCreateAndList(t *testing.T) {
+	server := NewServer()
+	cookie := loginForTest(t, server)
+
+	created := createWebhookForTest(t, server, cookie, WebhookSubscriptionCreateRequest{
+		URL:    "https://hooks.example.com/invoices",
+		Events: []string{EventInvoiceCreated, EventInvoicePaid, EventInvoiceCreated},
+	})
+	if created.Secret == "" || len(created.Events) != 2 || created.TenantID != "tenant-alpha" {
+		t.Fatalf("expected generated secret and deduplicated events, got %#v", created)
+	}
+
+	rec := doJSON(t, server, cookie, http.MethodGet, "/v1/webhooks", nil)
+	var list WebhookSubscriptionList
+	if err := json.NewDecoder(rec.Body).Decode(&list); err != nil {
+		t.Fatalf("decode list: %v", err)
+	}
+	if rec.Code != http.StatusOK || len(list.Items) != 1 || list.Items[0].ID != created.ID || list.Items[0].Secret != "" {
+		t.Fatalf("expected one subscription without its secret, got %d %#v", rec.Code, list.Items)
+	}
+
+	for name, req := range map[string]WebhookSubscriptionCreateRequest{
+		"relative url":  {URL: "/hooks", Events: []string{EventInvoiceCreated}},
+		"bad scheme":    {URL: "ftp://hooks.example.com", Events: []string{EventInvoiceCreated}},
+		"no events":     {URL: "https://hooks.example.com"},
+		"unknown event": {URL: "https://hooks.example.com", Events: []string{"invoice.deleted"}},
+		"short secret":  {URL: "https://hooks.example.com", Events: []string{EventInvoiceCreated}, Secret: "short"},
+		"loopback":      {URL: "http://127.0.0.1:8080/hooks", Events: []string{EventInvoiceCreated}},
+		"loopback v6":   {URL: "http://[::1]/hooks", Events: []string{EventInvoiceCreated}},
+		"localhost":     {URL: "http://localhost:9000/hooks", Events: []string{EventInvoiceCreated}},
+		"private":       {URL: "https://10.0.0.7/hooks", Events: []string{EventInvoiceCreated}},
+		"link-local":    {URL: "http://169.254.169.254/latest/meta-data", Events: []string{EventInvoiceCreated}},
+		"unspecified":   {URL: "http://0.0.0.0/hooks", Events: []string{EventInvoiceCreated}},
+	} {
+		if rec := doJSON(t, server, cookie, http.MethodPost, "/v1/webhooks", req); rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", name, rec.Code)
+		}
+	}
+}
+
+func TestWebhookDeliverySignsPayloadAndRetriesUntilAccepted(t *testing.T) {
+	receiver := newWebhookReceiver(http.StatusInternalServerError, http.StatusBadGateway, http.StatusOK)
+	defer receiver.Close()
+	server := newLoopbackWebhookServer(WithWebhookRetryPolicy(webhook.RetryPolicy{MaxAttempts: 5}))
+	cookie := loginForTest(t, server)
+	subscription := createWebhookForTest(t, server, cookie, WebhookSubscriptionCreateRequest{
+		URL:    receiver.URL,
+		Events: []string{EventInvoiceCreated},
+		Secret: "whsec_0123456789abcdef",
+	})
+
+	created := createInvoiceForTest(t, server, cookie, InvoiceCreateRequest{Number: "INV-0001", CustomerID: "cust-1", Currency: "ILS"})
+	for i := 0; i < 3; i++ {
+		if sent, err := server.DeliverWebhooks(context.Background()); err != nil || sent != 1 {
+			t.Fatalf("round %d: expected one delivery attempt, got %d, %v", i+1, sent, err)
+		}
+	}
+	if sent, err := server.DeliverWebhooks(context.Background()); err != nil || sent != 0 {
+		t.Fatalf("expected nothing left to send, got %d, %v", sent, err)
+	}
+
+	requests := receiver.received()
+	if len(requests) != 3 {
+		t.Fatalf("expected 3 requests, got %d", len(requests))
+	}
+	for _, req := range requests {
+		if !webhook.Verify(subscription.Secret, req.body, req.signature) {
+			t.Fatalf("signature %q does not match body %s", req.signature, req.body)
+		}
+	}
+	var event struct {
+		ID   string          `json:"id"`
+		Type string          `json:"type"`
+		Data invoice.Invoice `json:"data"`
+	}
+	if err := json.Unmarshal(requests[0].body, &event); err != nil {
+		t.Fatalf("decode event: %v", err)
+	}
+	if event.Type != EventInvoiceCreated || event.Data.ID != created.ID || event.ID == "" || requests[0].event != EventInvoiceCreated {
+		t.Fatalf("unexpected event %#v", event)
+	}
+	if string(requests[0].body) != string(requests[2].body) {
+		t.Fatal("expected retries to resend the same payload")
+	}
+
+	deliveries := listDeliveriesForTest(t, server, cookie, subscription.ID)
+	if len(deliveries) != 1 {
+		t.Fatalf("expected one delivery, got %d", len(deliveries))
+	}
+	delivery := deliveries[0]
+	if delivery.Status != WebhookDeliverySucceeded || delivery.Attempts != 3 || delivery.LastStatusCode != http.StatusOK || len(delivery.AttemptLog) != 3 {
+		t.Fatalf("unexpected delivery %#v", delivery)
+	}
+	if delivery.AttemptLog[0].StatusCode != http.StatusInternalServerError || delivery.AttemptLog[0].Error == "" || delivery.AttemptLog[2].Error != "" {
+		t.Fatalf("expected each attempt recorded, got %#v", delivery.AttemptLog)
+	}
+}
+
+func TestWebhookDeliveryBacksOffAndGivesUp(t *testing.T) {
+	receiver := newWebhookReceiver(http.StatusServiceUnavailable)
+	defer receiver.Close()
+
+	server := newLoopbackWebhookServer(WithWebhookRetryPolicy(webhook.RetryPolicy{MaxAttempts: 3, BaseDelay: time.Hour, MaxDelay: time.Hour}))
+	cookie := loginForTest(t, server)
+	subscription := createWebhookForTest(t, server, cookie, WebhookSubscriptionCreateRequest{URL: receiver.URL, Events: []string{EventInvoiceCreated}})
+	createInvoiceForTest(t, server, cookie, InvoiceCreateRequest{Number: "INV-0001", CustomerID: "cust-1", Currency: "ILS"})
+
+	if sent, _ := server.DeliverWebhooks(context.Background()); sent != 1 {
+		t.Fatalf("expected first attempt, got %d", sent)
+	}
+	if sent, _ := server.DeliverWebhooks(context.Background()); sent != 0 {
+		t.Fatalf("expected backoff to defer the retry, got %d", sent)
+	}
+	delivery := listDeliveriesForTest(t, server, cookie, subscription.ID)[0]
+	next, err := time.Parse(time.RFC3339, delivery.NextAttemptAt)
+	if err != nil || time.Until(next) < 59*time.Minute {
+		t.Fatalf("expected next attempt about an hour out, got %q", delivery.NextAttemptAt)
+	}
+
+	server = newLoopbackWebhookServer(WithWebhookRetryPolicy(webhook.RetryPolicy{MaxAttempts: 2}))
+	cookie = loginForTest(t, server)
+	subscription = createWebhookForTest(t, server, cookie, WebhookSubscriptionCreateRequest{URL: receiver.URL, Events: []string{EventInvoiceCreated}})
+	createInvoiceForTest(t, server, cookie, InvoiceCreateRequest{Number: "INV-0001", CustomerID: "cust-1", Currency: "ILS"})
+	for i := 0; i < 3; i++ {
+		if _, err := server.DeliverWebhooks(context.Background()); err != nil {
+			t.Fatalf("deliver: %v", err)
+		}
+	}
+	delivery = listDeliveriesForTest(t, server, cookie, subscription.ID)[0]
+	if delivery.Status != WebhookDeliveryFailed || delivery.Attempts != 2 || delivery.NextAttemptAt != "" {
+		t.Fatalf("expected delivery to fail after 2 attempts, got %#v", delivery)
+	}
+}
+
+func TestWebhookDeliveriesFilterAndReplay(t *testing.T) {
+	receiver := newWebhookReceiver(http.StatusServiceUnavailable, http.StatusOK)
+	defer receiver.Close()
+	server := newLoopbackWebhookServer(WithWebhookRetryPolicy(webhook.RetryPolicy{MaxAttempts: 1}))
+	cookie := loginForTest(t, server)
+	subscription := createWebhookForTest(t, server, cookie, WebhookSubscriptionCreateRequest{URL: receiver.URL, Events: []string{EventInvoiceCreated}})
+	for _, number := range []string{"INV-0001", "INV-0002"} {
+		createInvoiceForTest(t, server, cookie, InvoiceCreateRequest{Number: number, CustomerID: "cust-1", Currency: "ILS"})
+		if sent, err := server.DeliverWebhooks(context.Background()); err != nil || sent != 1 {
+			t.Fatalf("expected one delivery attempt, got %d, %v", sent, err)
+		}
+	}
+
+	var failed WebhookDeliveryList
+	rec := doJSON(t, server, cookie, http.MethodGet, "/v1/webhooks/"+subscription.ID+"/deliveries?status=failed", nil)
+	if err := json.NewDecoder(rec.Body).Decode(&failed); err != nil || rec.Code != http.StatusOK {
+		t.Fatalf("expected failed deliveries 200, got %d, %v", rec.Code, err)
+	}
+	if len(failed.Items) != 1 || failed.Items[0].Status != WebhookDeliveryFailed || failed.Items[0].AttemptLog[0].ResponseSnippet != "Service Unavailable" {
+		t.Fatalf("expected the failed delivery with the receiver's reply, got %#v", failed.Items)
+	}
+	if rec := doJSON(t, server, cookie, http.MethodGet, "/v1/webhooks/"+subscription.ID+"/deliveries?status=lost", nil); rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected unknown status 400, got %d", rec.Code)
+	}
+
+	original := failed.Items[0]
+	rec = doJSON(t, server, cookie, http.MethodPost, "/v1/webhooks/deliveries/"+original.ID+"/replay", nil)
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("expected replay 202, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var replay WebhookDelivery
+	if err := json.NewDecoder(rec.Body).Decode(&replay); err != nil {
+		t.Fatalf("decode replay: %v", err)
+	}
+	if replay.ID == original.ID || replay.ReplayOf != original.ID || replay.EventID != original.EventID || replay.Status != WebhookDeliveryPending {
+		t.Fatalf("expected a pending replay of %s, got %#v", original.ID, replay)
+	}
+	rec = doJSON(t, server, cookie, http.MethodPost, "/v1/webhooks/deliveries/"+replay.ID+"/replay", nil)
+	if rec.Code != http.StatusConflict || !strings.Contains(rec.Body.String(), apierror.CodeDeliveryNotReplayable) {
+		t.Fatalf("expected a pending delivery replay 409, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	if sent, err := server.DeliverWebhooks(context.Background()); err != nil || sent != 1 {
+		t.Fatalf("expected the replay to be sent, got %d, %v", sent, err)
+	}
+	requests := receiver.received()
+	if len(requests) != 3 || string(requests[2].body) != string(requests[0].body) {
+		t.Fatalf("expected the replay to resend the first event, got %d requests", len(requests))
+	}
+	rec = doJSON(t, server, cookie, http.MethodGet, "/v1/webhooks/"+subscription.ID+"/deliveries?status=succeeded", nil)
+	var succeeded WebhookDeliveryList
+	if err := json.NewDecoder(rec.Body).Decode(&succeeded); err != nil || len(succeeded.Items) != 2 {
+		t.Fatalf("expected the replay to succeed alongside the second event, got %#v, %v", succeeded.Items, err)
+	}
+
+	if rec := doJSON(t, server, cookie, http.MethodPost, "/v1/webhooks/deliveries/whd-missing/replay", nil); rec.Code != http.StatusNotFound {
+		t.Fatalf("expected unknown delivery 404, got %d", rec.Code)
+	}
+	if rec := doJSON(t, server, cookie, http.MethodGet, "/v1/webhooks/deliveries/"+replay.ID+"/replay", nil); rec.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("expected GET replay 405, got %d", rec.Code)
+	}
+}
+
+func TestWebhookDeliveriesPaginateWithCursor(t *testing.T) {
+	server := NewServer()
+	cookie := loginForTest(t, server)
+	subscription := createWebhookForTest(t, server, cookie, WebhookSubscriptionCreateRequest{URL: "https://hooks.example.com/invoices", Events: []string{EventInvoiceCreated}})
+	for range 5 {
+		createInvoiceForTest(t, server, cookie, InvoiceCreateRequest{CustomerID: "cust-1", Currency: "ILS"})
+	}
+	all := listDeliveriesForTest(t, server, cookie, subscription.ID)
+	if len(all) != 5 {
+		t.Fatalf("expected 5 deliveries, got %d", len(all))
+	}
+
+	var seen []string
+	cursor := ""
+	for page := 0; ; page++ {
+		path := "/v1/webhooks/" + subscription.ID + "/deliveries?limit=2"
+		if cursor != "" {
+			path += "&cursor=" + cursor
+		}
+		rec := doJSON(t, server, cookie, http.MethodGet, path, nil)
+		var list WebhookDeliveryList
+		if rec.Code != http.StatusOK || json.NewDecoder(rec.Body).Decode(&list) != nil || len(list.Items) > 2 {
+			t.Fatalf("page %d: expected at most 2 deliveries, got %d: %s", page, rec.Code, rec.Body.String())
+		}
+		for _, item := range list.Items {
+			seen = append(seen, item.ID)
+		}
+		if cursor = list.NextCursor; cursor == "" {
+			break
+		}
+	}
+	if len(seen) != len(all) {
+		t.Fatalf("expected the pages to cover all %d deliveries, got %v", len(all), seen)
+	}
+	for i, item := range all {
+		if seen[i] != item.ID {
+			t.Fatalf("expected pages in list order, got %v", seen)
+		}
+	}
+
+	for _, query := range []string{"limit=0", "limit=many", "cursor=not-a-cursor", "status=lost"} {
+		if rec := doJSON(t, server, cookie, http.MethodGet, "/v1/webhooks/"+subscription.ID+"/deliveries?"+query, nil); rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", query, rec.Code)
+		}
+	}
+}
+
+func TestInvoiceStatusChangesPublishMatchingEvents(t *testing.T) {
+	server := NewServer()
+	cookie := loginForTest(t, server)
+	paidHook := createWebhookForTest(t, server, cookie, WebhookSubscriptionCreateRequest{URL: "https://hooks.example.com/paid", Events: []string{EventInvoicePaid}})
+	overdueHook := createWebhookForTest(t, server, cookie, WebhookSubscriptionCreateRequest{URL: "https://hooks.example.com/overdue", Events: []string{EventInvoiceOverdue}})
+
+	created := createInvoiceForTest(t, server, cookie, InvoiceCreateRequest{Number: "INV-0001", CustomerID: "cust-1", Currency: "ILS", Status: invoice.StatusOpen})
+	paid := invoice.StatusPaid
+	paidAt := "2026-06-02T09:30:00Z"
+	if rec := patchInvoiceForTest(t, server, cookie, "/v1/invoices/"+created.ID, InvoiceUpdateRequest{Status: &paid, PaidAt: &paidAt}); rec.Code != http.StatusOK {
+		t.Fatalf("expected patch 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if rec := patchInvoiceForTest(t, server, cookie, "/v1/invoices/"+created.ID, InvoiceUpdateRequest{Status: &paid}); rec.Code != http.StatusOK {
+		t.Fatalf("expected repeat patch 200, got %d", rec.Code)
+	}
+
+	paidDeliveries := listDeliveriesForTest(t, server, cookie, paidHook.ID)
+	if len(paidDeliveries) != 1 || paidDeliveries[0].Event != EventInvoicePaid {
+		t.Fatalf("expected a single invoice.paid delivery, got %#v", paidDeliveries)
+	}
+	if overdue := listDeliveriesForTest(t, server, cookie, overdueHook.ID); len(overdue) != 0 {
+		t.Fatalf("expected no overdue deliveries, got %#v", overdue)
+	}
+	if rec := doJSON(t, server, cookie, http.MethodGet, "/v1/webhooks/whk-missing/deliveries", nil); rec.Code != http.StatusNotFound {
+		t.Fatalf("expected unknown webhook 404, got %d", rec.Code)
+	}
+}
+
+func createWebhookForTest(t *testing.T, server *Server, cookie *http.Cookie, req WebhookSubscriptionCreateRequest) WebhookSubscription {
+	t.Helper()
+
+	rec := doJSON(t, server, cookie, http.MethodPost, "/v1/webhooks", req)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected create 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var item WebhookSubscription
+	if err := json.NewDecoder(rec.Body).Decode(&item); err != nil {
+		t.Fatalf("decode webhook: %v", err)
+	}
+	return item
+}
+
+func listDeliveriesForTest(t *testing.T, server *Server, cookie *http.Cookie, subscriptionID string) []WebhookDelivery {
+	t.Helper()
+
+	rec := doJSON(t, server, cookie, http.MethodGet, "/v1/webhooks/"+subscriptionID+"/deliveries", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected deliveries 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var list WebhookDeliveryList
+	if err := json.NewDecoder(rec.Body).Decode(&list); err != nil {
+		t.Fatalf("decode deliveries: %v", err)
+	}
+	return list.Items
+}
+
+// newLoopbackWebhookServer returns a server that may subscribe and deliver to
+// httptest receivers, which listen on loopback.
+func newLoopbackWebhookServer(opts ...Option) *Server {
+	server := NewServer(append([]Option{WithWebhookSender(&webhook.HTTPSender{Client: &http.Client{Timeout: time.Second}})}, opts...)...)
+	server.allowPrivateWebhooks = true
+	return server
+}
+
+type receivedWebhook struct {
+	body      []byte
+	signature string
+	event     string
+}
+
+type webhookReceiver struct {
+	*httptest.Server
+	mu       sync.Mutex
+	statuses []int
+	requests []receivedWebhook
+}
+
+// newWebhookReceiver answers with statuses in order, repeating the last one.
+func newWebhookReceiver(statuses ...int) *webhookReceiver {
+	receiver := &webhookReceiver{statuses: statuses}
+	receiver.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		receiver.mu.Lock()
+		receiver.requests = append(receiver.requests, receivedWebhook{
+			body:      body,
+			signature: r.Header.Get(webhook.SignatureHeader),
+			event:     r.Header.Get(webhook.EventHeader),
+		})
+		status := receiver.statuses[min(len(receiver.requests), len(receiver.statuses))-1]
+		receiver.mu.Unlock()
+		w.WriteHeader(status)
+		io.WriteString(w, http.StatusText(status))
+	}))
+	return receiver
+}
+
+func (r *webhookReceiver) received() []receivedWebhook {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]receivedWebhook(nil), r.requests...)
+}
@@ -0,0 +1,149 @@
+// Package apierror defines the JSON body of every API error response. Each
+// error carries a stable machine-readable code that clients can switch on,
+// a human-readable message, and optional details such as per-field
+// validation messages.
+package apierror
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// Codes shared across resources. Resource lookups that miss use
+// "<resource>_not_found", see NotFound.
+const (
+	CodeInvalidRequest       = "invalid_request"
+	CodeValidationFailed     = "validation_failed"
+	CodeInvalidJSON          = "invalid_json"
+	CodeUnauthorized         = "unauthorized"
+	CodeForbidden            = "forbidden"
+	CodeNotFound             = "not_found"
+	CodeMethodNotAllowed     = "method_not_allowed"
+	CodeConflict             = "conflict"
+	CodePreconditionFailed   = "precondition_failed"
+	CodePreconditionRequired = "precondition_required"
+	CodePayloadTooLarge      = "payload_too_large"
+	CodeUnsupportedMediaType = "unsupported_media_type"
+	CodeRateLimited          = "rate_limited"
+	CodeRequestTimeout       = "request_timeout"
+	CodeServiceUnavailable   = "service_unavailable"
+	CodeInternal             = "internal_error"
+)
+
+// Codes for specific business rules.
+const (
+	CodeInvoiceNumberTaken     = "invoice_number_taken"
+	CodeInvoiceNotDraft        = "invoice_not_draft"
+	CodeInvoicePaid            = "invoice_paid"
+	CodeInvalidTransition      = "invalid_transition"
+	CodeInvalidIdempotencyKey  = "invalid_idempotency_key"
+	CodeIdempotencyKeyReused   = "idempotency_key_reused"
+	CodeIdempotencyKeyInFlight = "idempotency_key_in_progress"
+	CodeJobNotRetryable        = "collection_job_not_retryable"
+	CodeInvoiceNotPayable      = "invoice_not_payable"
+	CodeOverpayment            = "overpayment"
+	CodeBatchRejected          = "batch_rejected"
+	CodeInvoiceNotRemindable   = "invoice_not_remindable"
+	CodeCustomerEmailMissing   = "customer_email_missing"
+	CodeEmailNotSent           = "email_not_sent"
+	CodeInvoiceVoid            = "invoice_void"
+	CodeInvoiceNotCreditable   = "invoice_not_creditable"
+	CodeCreditExceeded         = "credit_exceeded"
+	CodePaymentsNotConfigured  = "payments_not_configured"
+	CodePaymentProviderFailed  = "payment_provider_failed"
+	CodeInvalidSignature       = "invalid_signature"
+	CodeSharingNotConfigured   = "sharing_not_configured"
+	CodeInvoiceNotShareable    = "invoice_not_shareable"
+	CodeDeliveryNotReplayable  = "webhook_delivery_not_replayable"
+	CodeImportRowNotProcessed  = "import_row_not_processed"
+)
+
+// Error is an API error response. The message is serialized as "error" so
+// clients written against the original {"error": "..."} body keep working.
+type Error struct {
+	Message string         `json:"error"`
+	Code    string         `json:"code"`
+	Details map[string]any `json:"details,omitempty"`
+}
+
+// New returns an error with the given code and message.
+func New(code, message string) *Error {
+	return &Error{Code: code, Message: message}
+}
+
+// Validation returns a validation_failed error whose details map each
+// offending field to what is wrong with it.
+func Validation(message string, fields map[string]string) *Error {
+	details := make(map[string]any, len(fields))
+	for field, problem := range fields {
+		details[field] = problem
+	}
+	return &Error{Code: CodeValidationFailed, Message: message, Details: details}
+}
+
+// Field returns a validation_failed error for a single field, with the
+// message "<field> <problem>".
+func Field(field, problem string) *Error {
+	return Validation(field+" "+problem, map[string]string{field: problem})
+}
+
+// NotFound returns the error for a missing resource, such as
+// "invoice_not_found" for NotFound("invoice").
+func NotFound(resource string) *Error {
+	return New(strings.ReplaceAll(resource, " ", "_")+"_not_found", resource+" not found")
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// WriteError writes err as a JSON error response. Errors that are not an
+// *Error get the generic code for status, and on 5xx their message is
+// replaced so internals never reach the client.
+func WriteError(w http.ResponseWriter, status int, err error) {
+	var apiErr *Error
+	if !errors.As(err, &apiErr) {
+		apiErr = New(codeForStatus(status), err.Error())
+		if status >= http.StatusInternalServerError {
+			apiErr.Message = "internal server error"
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(apiErr)
+}
+
+func codeForStatus(status int) string {
+	switch status {
+	case http.StatusBadRequest:
+		return CodeInvalidRequest
+	case http.StatusUnauthorized:
+		return CodeUnauthorized
+	case http.StatusForbidden:
+		return CodeForbidden
+	case http.StatusNotFound:
+		return CodeNotFound
+	case http.StatusMethodNotAllowed:
+		return CodeMethodNotAllowed
+	case http.StatusConflict:
+		return CodeConflict
+	case http.StatusPreconditionFailed:
+		return CodePreconditionFailed
+	case http.StatusPreconditionRequired:
+		return CodePreconditionRequired
+	case http.StatusRequestEntityTooLarge:
+		return CodePayloadTooLarge
+	case http.StatusUnsupportedMediaType:
+		return CodeUnsupportedMediaType
+	case http.StatusUnprocessableEntity:
+		return CodeValidationFailed
+	case http.StatusTooManyRequests:
+		return CodeRateLimited
+	}
+	if status >= http.StatusInternalServerError {
+		return CodeInternal
+	}
+	return CodeInvalidRequest
+}
@@ -0,0 +1,55 @@
+package apierror
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWriteError(t *testing.T) {
+	cases := []struct {
+		name    string
+		status  int
+		err     error
+		code    string
+		message string
+	}{
+		{"api error", http.StatusConflict, New(CodeInvoiceNumberTaken, "invoice number already exists"), CodeInvoiceNumberTaken, "invoice number already exists"},
+		{"not found", http.StatusNotFound, NotFound("line item"), "line_item_not_found", "line item not found"},
+		{"plain client error", http.StatusBadRequest, errors.New("bad cursor"), CodeInvalidRequest, "bad cursor"},
+		{"plain server error", http.StatusServiceUnavailable, errors.New("dial tcp: refused"), CodeInternal, "internal server error"},
+	}
+	for _, tc := range cases {
+		rec := httptest.NewRecorder()
+		WriteError(rec, tc.status, tc.err)
+		var body map[string]any
+		if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+			t.Fatalf("%s: decode: %v", tc.name, err)
+		}
+		if rec.Code != tc.status || rec.Header().Get("Content-Type") != "application/json" ||
+			body["code"] != tc.code || body["error"] != tc.message {
+			t.Errorf("%s: unexpected response %d %v", tc.name, rec.Code, body)
+		}
+		if _, ok := body["details"]; ok {
+			t.Errorf("%s: expected details to be omitted, got %v", tc.name, body)
+		}
+	}
+}
+
+func TestValidationListsFields(t *testing.T) {
+	rec := httptest.NewRecorder()
+	WriteError(rec, http.StatusBadRequest, Validation("email and tenantId are required", map[string]string{"email": "is required"}))
+	var body Error
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if body.Code != CodeValidationFailed || len(body.Details) != 1 || body.Details["email"] != "is required" {
+		t.Fatalf("unexpected validation error %#v", body)
+	}
+
+	if err := Field("currency", "must be a 3-letter ISO 4217 code"); err.Error() != "currency must be a 3-letter ISO 4217 code" || err.Details["currency"] == nil {
+		t.Fatalf("unexpected field error %#v", err)
+	}
+}
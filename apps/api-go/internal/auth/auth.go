@@ -0,0 +1,133 @@
+// Package auth verifies RS256 bearer tokens and carries the verified caller
+// identity through request contexts.
+package auth
+
+import (
+	"context"
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var (
+	// ErrMissingToken means the request carried no bearer token.
+	ErrMissingToken = errors.New("missing bearer token")
+	// ErrExpiredToken means the token was well formed and signed but is
+	// past its exp claim.
+	ErrExpiredToken = errors.New("token expired")
+	// ErrInvalidToken covers every other verification failure.
+	ErrInvalidToken = errors.New("invalid token")
+)
+
+// Claims is the caller identity taken from a verified token.
+type Claims struct {
+	Subject     string
+	OrgID       string
+	Email       string
+	Name        string
+	Permissions []string
+	ExpiresAt   time.Time
+}
+
+type tokenClaims struct {
+	jwt.RegisteredClaims
+	OrgID       string   `json:"org_id"`
+	Email       string   `json:"email,omitempty"`
+	Name        string   `json:"name,omitempty"`
+	Permissions []string `json:"permissions,omitempty"`
+}
+
+// Verifier checks token signatures against a single RSA public key.
+type Verifier struct {
+	key    *rsa.PublicKey
+	parser *jwt.Parser
+}
+
+// NewVerifier returns a Verifier for RS256 tokens signed by key. Tokens
+// must carry exp; nbf is honoured when present. leeway absorbs clock skew
+// between the issuer and this service.
+func NewVerifier(key *rsa.PublicKey, leeway time.Duration) *Verifier {
+	return &Verifier{
+		key: key,
+		parser: jwt.NewParser(
+			jwt.WithValidMethods([]string{jwt.SigningMethodRS256.Alg()}),
+			jwt.WithExpirationRequired(),
+			jwt.WithLeeway(leeway),
+		),
+	}
+}
+
+// ParsePublicKeyPEM decodes a PEM encoded RSA public key, either PKIX or
+// PKCS#1.
+func ParsePublicKeyPEM(data []byte) (*rsa.PublicKey, error) {
+	key, err := jwt.ParseRSAPublicKeyFromPEM(data)
+	if err != nil {
+		return nil, fmt.Errorf("parse RSA public key: %w", err)
+	}
+	return key, nil
+}
+
+// Verify checks raw and returns its claims. The error wraps ErrExpiredToken
+// or ErrInvalidToken.
+func (v *Verifier) Verify(raw string) (Claims, error) {
+	var parsed tokenClaims
+	_, err := v.parser.ParseWithClaims(raw, &parsed, func(*jwt.Token) (any, error) {
+		return v.key, nil
+	})
+	switch {
+	case errors.Is(err, jwt.ErrTokenExpired):
+		return Claims{}, ErrExpiredToken
+	case err != nil:
+		return Claims{}, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	case parsed.Subject == "" || parsed.OrgID == "":
+		return Claims{}, fmt.Errorf("%w: sub and org_id are required", ErrInvalidToken)
+	}
+	return Claims{
+		Subject:     parsed.Subject,
+		OrgID:       parsed.OrgID,
+		Email:       parsed.Email,
+		Name:        parsed.Name,
+		Permissions: parsed.Permissions,
+		ExpiresAt:   parsed.ExpiresAt.Time,
+	}, nil
+}
+
+// BearerToken returns the token from an "Authorization: Bearer" header, or
+// ErrMissingToken.
+func BearerToken(r *http.Request) (string, error) {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") || strings.TrimSpace(token) == "" {
+		return "", ErrMissingToken
+	}
+	return strings.TrimSpace(token), nil
+}
+
+// Challenge is the WWW-Authenticate value for a failed authentication, so
+// clients can tell an expired token (refresh and retry) from a bad one.
+func Challenge(err error) string {
+	switch {
+	case errors.Is(err, ErrExpiredToken):
+		return `Bearer error="invalid_token", error_description="token expired"`
+	case errors.Is(err, ErrInvalidToken):
+		return `Bearer error="invalid_token"`
+	}
+	return "Bearer"
+}
+
+type contextKey struct{}
+
+// WithClaims returns a copy of ctx carrying claims.
+func WithClaims(ctx context.Context, claims Claims) context.Context {
+	return context.WithValue(ctx, contextKey{}, claims)
+}
+
+// FromContext returns the caller identity stored by the auth middleware.
+func FromContext(ctx context.Context) (Claims, bool) {
+	claims, ok := ctx.Value(contextKey{}).(Claims)
+	return claims, ok
+}
@@ -0,0 +1,138 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestVerifyAcceptsValidToken(t *testing.T) {
+	key := newTestKey(t)
+	verifier := NewVerifier(&key.PublicKey, 0)
+
+	claims, err := verifier.Verify(signTestToken(t, key, jwt.MapClaims{
+		"sub":         "user-1",
+		"org_id":      "org-a",
+		"email":       "ada@example.com",
+		"permissions": []string{"invoices:read"},
+		"exp":         time.Now().Add(time.Hour).Unix(),
+	}))
+	if err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+	if claims.Subject != "user-1" || claims.OrgID != "org-a" || claims.Email != "ada@example.com" || len(claims.Permissions) != 1 {
+		t.Fatalf("unexpected claims: %#v", claims)
+	}
+}
+
+func TestVerifyRejectsBadTokens(t *testing.T) {
+	key := newTestKey(t)
+	other := newTestKey(t)
+	verifier := NewVerifier(&key.PublicKey, 0)
+	valid := jwt.MapClaims{"sub": "user-1", "org_id": "org-a", "exp": time.Now().Add(time.Hour).Unix()}
+
+	hmacToken, err := jwt.NewWithClaims(jwt.SigningMethodHS256, valid).SignedString(x509.MarshalPKCS1PublicKey(&key.PublicKey))
+	if err != nil {
+		t.Fatalf("sign hmac token: %v", err)
+	}
+	cases := map[string]struct {
+		token string
+		want  error
+	}{
+		"expired":      {signTestToken(t, key, jwt.MapClaims{"sub": "user-1", "org_id": "org-a", "exp": time.Now().Add(-time.Minute).Unix()}), ErrExpiredToken},
+		"not yet":      {signTestToken(t, key, jwt.MapClaims{"sub": "user-1", "org_id": "org-a", "exp": time.Now().Add(time.Hour).Unix(), "nbf": time.Now().Add(time.Minute).Unix()}), ErrInvalidToken},
+		"no exp":       {signTestToken(t, key, jwt.MapClaims{"sub": "user-1", "org_id": "org-a"}), ErrInvalidToken},
+		"no org":       {signTestToken(t, key, jwt.MapClaims{"sub": "user-1", "exp": time.Now().Add(time.Hour).Unix()}), ErrInvalidToken},
+		"wrong key":    {signTestToken(t, other, valid), ErrInvalidToken},
+		"hmac confuse": {hmacToken, ErrInvalidToken},
+		"garbage":      {"not.a.token", ErrInvalidToken},
+	}
+	for name, tc := range cases {
+		if _, err := verifier.Verify(tc.token); !errors.Is(err, tc.want) {
+			t.Errorf("%s: expected %v, got %v", name, tc.want, err)
+		}
+	}
+}
+
+func TestVerifyLeewayAbsorbsClockSkew(t *testing.T) {
+	key := newTestKey(t)
+	token := signTestToken(t, key, jwt.MapClaims{"sub": "user-1", "org_id": "org-a", "exp": time.Now().Add(-10 * time.Second).Unix()})
+
+	if _, err := NewVerifier(&key.PublicKey, time.Minute).Verify(token); err != nil {
+		t.Fatalf("expected leeway to accept recently expired token, got %v", err)
+	}
+}
+
+func TestParsePublicKeyPEM(t *testing.T) {
+	key := newTestKey(t)
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatalf("marshal key: %v", err)
+	}
+	parsed, err := ParsePublicKeyPEM(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+	if err != nil || !parsed.Equal(&key.PublicKey) {
+		t.Fatalf("expected round trip, got %v", err)
+	}
+	if _, err := ParsePublicKeyPEM([]byte("nope")); err == nil {
+		t.Fatal("expected invalid PEM to fail")
+	}
+}
+
+func TestBearerTokenAndChallenge(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	if _, err := BearerToken(req); !errors.Is(err, ErrMissingToken) {
+		t.Fatalf("expected missing token, got %v", err)
+	}
+	req.Header.Set("Authorization", "Basic abc")
+	if _, err := BearerToken(req); !errors.Is(err, ErrMissingToken) {
+		t.Fatalf("expected non-bearer scheme to be ignored, got %v", err)
+	}
+	req.Header.Set("Authorization", "bearer abc.def.ghi")
+	if token, err := BearerToken(req); err != nil || token != "abc.def.ghi" {
+		t.Fatalf("unexpected token %q, %v", token, err)
+	}
+
+	if got := Challenge(ErrExpiredToken); got != `Bearer error="invalid_token", error_description="token expired"` {
+		t.Fatalf("unexpected expired challenge %q", got)
+	}
+	if got := Challenge(ErrMissingToken); got != "Bearer" {
+		t.Fatalf("unexpected missing challenge %q", got)
+	}
+}
+
+func TestClaimsContext(t *testing.T) {
+	if _, ok := FromContext(context.Background()); ok {
+		t.Fatal("expected no claims in empty context")
+	}
+	ctx := WithClaims(context.Background(), Claims{Subject: "user-1", OrgID: "org-a"})
+	if claims, ok := FromContext(ctx); !ok || claims.OrgID != "org-a" {
+		t.Fatalf("unexpected claims %#v, %v", claims, ok)
+	}
+}
+
+func newTestKey(t *testing.T) *rsa.PrivateKey {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	return key
+}
+
+func signTestToken(t *testing.T, key *rsa.PrivateKey, claims jwt.MapClaims) string {
+	t.Helper()
+	token, err := jwt.NewWithClaims(jwt.SigningMethodRS256, claims).SignedString(key)
+	if err != nil {
+		t.Fatalf("sign token: %v", err)
+	}
+	return token
+}
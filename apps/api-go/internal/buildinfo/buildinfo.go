@@ -0,0 +1,33 @@
+// Package buildinfo identifies the running binary. Release builds set
+// Version and Commit at link time:
+//
+//	go build -ldflags "-X github.com/vgeshiktor/invoices-platform/apps/api-go/internal/buildinfo.Version=v1.4.0 \
+//		-X github.com/vgeshiktor/invoices-platform/apps/api-go/internal/buildinfo.Commit=$(git rev-parse HEAD)" ./cmd/invoicer
+package buildinfo
+
+import "runtime/debug"
+
+var (
+	// Version is the release the binary was built as; "dev" for local
+	// builds.
+	Version = "dev"
+	// Commit is the git commit the binary was built from.
+	Commit = ""
+)
+
+// Revision returns Commit or, for a build that did not set it, the VCS
+// revision the Go toolchain stamps into binaries built inside a checkout.
+// It is "unknown" when neither is there.
+func Revision() string {
+	if Commit != "" {
+		return Commit
+	}
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range info.Settings {
+			if setting.Key == "vcs.revision" && setting.Value != "" {
+				return setting.Value
+			}
+		}
+	}
+	return "unknown"
+}
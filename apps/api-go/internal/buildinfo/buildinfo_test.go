@@ -0,0 +1,17 @@
+package buildinfo
+
+import "testing"
+
+func TestRevisionPrefersTheInjectedCommit(t *testing.T) {
+	previous := Commit
+	t.Cleanup(func() { Commit = previous })
+
+	Commit = "0123abc"
+	if got := Revision(); got != "0123abc" {
+		t.Fatalf("expected the injected commit, got %q", got)
+	}
+	Commit = ""
+	if got := Revision(); got == "" {
+		t.Fatal("expected a revision without an injected commit")
+	}
+}
@@ -0,0 +1,527 @@
+// Package config loads the API's settings from environment variables, so
+// every knob is parsed and validated once at startup instead of being read
+// ad hoc wherever it is needed.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/invoice"
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/logging"
+)
+
+const (
+	DefaultListenAddr        = ":8080"
+	DefaultShutdownTimeout   = 15 * time.Second
+	DefaultJWTLeeway         = 30 * time.Second
+	DefaultWebhookInterval   = 5 * time.Second
+	DefaultOverdueInterval   = time.Hour
+	DefaultRecurringInterval = 15 * time.Minute
+	DefaultDunningInterval   = time.Hour
+	DefaultPurgeInterval     = 24 * time.Hour
+	DefaultSummaryCacheTTL   = 30 * time.Second
+	DefaultRequestTimeout    = 30 * time.Second
+	DefaultDBMaxConns        = 20
+	DefaultDBMinIdleConns    = 2
+	DefaultDBConnMaxLifetime = time.Hour
+	DefaultDBConnMaxIdleTime = 10 * time.Minute
+	DefaultRateLimitRPS      = 20
+	DefaultRateLimitBurst    = 40
+	DefaultMaxBodyBytes      = 1 << 20
+	DefaultAttachmentBytes   = 10 << 20
+	DefaultAttachmentDir     = "data/attachments"
+	// DefaultDeletedRetentionDays is how long soft-deleted invoices and
+	// customers are kept, and DefaultAuditRetentionDays, seven years, how
+	// long audit entries are.
+	DefaultDeletedRetentionDays = 90
+	DefaultAuditRetentionDays   = 7 * 365
+	// MinShareLinkSecretBytes is the shortest SHARE_LINK_SECRET accepted:
+	// the secret keys an HMAC-SHA256, which wants 32 random bytes.
+	MinShareLinkSecretBytes = 32
+)
+
+// DefaultCORSAllowedOrigins are the web app's Vite dev and preview servers.
+var DefaultCORSAllowedOrigins = []string{
+	"http://localhost:5173",
+	"http://127.0.0.1:5173",
+	"http://localhost:4173",
+	"http://127.0.0.1:4173",
+}
+
+// DefaultDunningSchedule reminds customers one, seven and fourteen days past
+// the due date.
+var DefaultDunningSchedule = []int{1, 7, 14}
+
+// DefaultAttachmentContentTypes are the documents and images invoices accept
+// as attachments.
+var DefaultAttachmentContentTypes = []string{
+	"application/pdf",
+	"image/jpeg",
+	"image/png",
+	"image/webp",
+	"text/csv",
+	"text/plain",
+}
+
+// Config is the validated process configuration.
+type Config struct {
+	ListenAddr      string
+	DatabaseURL     string
+	LogLevel        slog.Level
+	ShutdownTimeout time.Duration
+	// RequestTimeout is the deadline put on each request; queries still
+	// running when it passes are cancelled.
+	RequestTimeout time.Duration
+	PDFCompanyName string
+
+	// DBMaxConns caps the Postgres pool and DBMinIdleConns keeps that many
+	// idle connections warm. Connections are recycled after
+	// DBConnMaxLifetime, or after DBConnMaxIdleTime unused.
+	DBMaxConns        int
+	DBMinIdleConns    int
+	DBConnMaxLifetime time.Duration
+	DBConnMaxIdleTime time.Duration
+
+	// JWTPublicKeyFile is a PEM RSA public key. When empty, bearer tokens
+	// are rejected and only session cookies authenticate.
+	JWTPublicKeyFile string
+	JWTLeeway        time.Duration
+
+	// WebhookPollInterval is how often the delivery worker looks for due
+	// webhook deliveries.
+	WebhookPollInterval time.Duration
+
+	// OverdueScanInterval is how often open invoices past their due date
+	// are moved to overdue.
+	OverdueScanInterval time.Duration
+
+	// RecurringScanInterval is how often recurring invoices are checked for
+	// runs that have come due.
+	RecurringScanInterval time.Duration
+
+	// DunningSchedule lists the days past the due date at which overdue
+	// invoices get a reminder email, and DunningScanInterval how often the
+	// worker looks for reminders that have come due.
+	DunningSchedule     []int
+	DunningScanInterval time.Duration
+
+	// DeletedRetention is how long soft-deleted invoices and customers are
+	// kept before the purge job removes them with their attachments, and
+	// AuditRetention, which is no shorter, how long audit entries are.
+	// PurgeInterval is how often the job runs.
+	DeletedRetention time.Duration
+	AuditRetention   time.Duration
+	PurgeInterval    time.Duration
+
+	// InvoiceNumbers is how invoices created without a number are numbered,
+	// from a sequence per organization, e.g. INV-0042 or, with the yearly
+	// reset, INV-2024-000123.
+	InvoiceNumbers invoice.NumberFormat
+
+	// SummaryCacheTTL is how long an invoice summary is served from cache
+	// before it is aggregated again.
+	SummaryCacheTTL time.Duration
+
+	// SMTPAddr is the host:port of the mail server reminders go through.
+	// When empty, reminder emails are dropped.
+	SMTPAddr     string
+	SMTPFrom     string
+	SMTPUsername string
+	SMTPPassword string
+
+	// RateLimitRPS and RateLimitBurst size the token bucket each
+	// organization (or, before login, each client IP) draws from.
+	RateLimitRPS   float64
+	RateLimitBurst int
+
+	// MaxBodyBytes caps request bodies; larger requests get 413.
+	MaxBodyBytes int64
+
+	// CORSAllowedOrigins are the browser origins, such as
+	// https://app.example.com, allowed to call the API with credentials.
+	CORSAllowedOrigins []string
+
+	// AttachmentMaxBytes caps each uploaded attachment, and
+	// AttachmentContentTypes lists the media types it may have.
+	AttachmentMaxBytes     int64
+	AttachmentContentTypes []string
+
+	// AttachmentDir is where attachment files are kept on local disk. When
+	// S3Bucket is set they go to that bucket instead; S3Endpoint points at
+	// an S3-compatible service such as MinIO and is empty for AWS.
+	AttachmentDir     string
+	S3Bucket          string
+	S3Region          string
+	S3Endpoint        string
+	S3AccessKeyID     string
+	S3SecretAccessKey string
+
+	// StripeSecretKey and StripeWebhookSecret let invoices be paid through
+	// Stripe; the webhook secret is the signing secret of the endpoint
+	// registered for /v1/payments/webhooks/stripe. When both are empty,
+	// online payment is off.
+	StripeSecretKey     string
+	StripeWebhookSecret string
+
+	// ShareLinkSecret signs the public links invoices are shared through;
+	// when empty, invoices cannot be shared. PublicBaseURL, such as
+	// https://invoices.example.com, is where the API is reached from
+	// outside and prefixes the links; when empty they are relative.
+	ShareLinkSecret string
+	PublicBaseURL   string
+
+	// Worker runtime settings, passed through to the subprocess runners.
+	WorkspaceRoot       string
+	FilesDir            string
+	GraphClientID       string
+	GraphAuthority      string
+	GraphTokenCachePath string
+	MonthlyGmailArgs    string
+	MonthlyGraphArgs    string
+}
+
+// Load reads the configuration from the environment. It reports every
+// missing or malformed variable at once, each error naming the variable.
+func Load() (*Config, error) {
+	cfg := &Config{
+		ListenAddr:          stringEnv("LISTEN_ADDR", DefaultListenAddr),
+		DatabaseURL:         stringEnv("DATABASE_URL", ""),
+		PDFCompanyName:      stringEnv("PDF_COMPANY_NAME", ""),
+		JWTPublicKeyFile:    stringEnv("JWT_PUBLIC_KEY_FILE", ""),
+		SMTPAddr:            stringEnv("SMTP_ADDR", ""),
+		SMTPFrom:            stringEnv("SMTP_FROM", ""),
+		SMTPUsername:        stringEnv("SMTP_USERNAME", ""),
+		SMTPPassword:        os.Getenv("SMTP_PASSWORD"),
+		AttachmentDir:       stringEnv("ATTACHMENT_DIR", DefaultAttachmentDir),
+		S3Bucket:            stringEnv("S3_BUCKET", ""),
+		S3Region:            stringEnv("S3_REGION", ""),
+		S3Endpoint:          stringEnv("S3_ENDPOINT", ""),
+		S3AccessKeyID:       stringEnv("S3_ACCESS_KEY_ID", ""),
+		S3SecretAccessKey:   os.Getenv("S3_SECRET_ACCESS_KEY"),
+		StripeSecretKey:     os.Getenv("STRIPE_SECRET_KEY"),
+		StripeWebhookSecret: os.Getenv("STRIPE_WEBHOOK_SECRET"),
+		ShareLinkSecret:     os.Getenv("SHARE_LINK_SECRET"),
+		PublicBaseURL:       strings.TrimRight(stringEnv("PUBLIC_BASE_URL", ""), "/"),
+		WorkspaceRoot:       stringEnv("WORKSPACE_ROOT", ""),
+		FilesDir:            stringEnv("FILES_DIR", ""),
+		GraphClientID:       stringEnv("GRAPH_CLIENT_ID", ""),
+		GraphAuthority:      stringEnv("GRAPH_AUTHORITY", ""),
+		GraphTokenCachePath: stringEnv("GRAPH_TOKEN_CACHE_PATH", ""),
+		MonthlyGmailArgs:    stringEnv("MONTHLY_GMAIL_ARGS", ""),
+		MonthlyGraphArgs:    stringEnv("MONTHLY_GRAPH_ARGS", ""),
+	}
+
+	var errs []error
+	if cfg.DatabaseURL == "" {
+		errs = append(errs, errors.New("DATABASE_URL is required"))
+	}
+	if _, _, err := net.SplitHostPort(cfg.ListenAddr); err != nil {
+		errs = append(errs, fmt.Errorf("LISTEN_ADDR must be host:port (e.g. :8080), got %q", cfg.ListenAddr))
+	}
+	level, err := logging.ParseLevel(os.Getenv("LOG_LEVEL"))
+	if err != nil {
+		errs = append(errs, err)
+	}
+	cfg.LogLevel = level
+	timeout, err := durationEnv("SHUTDOWN_TIMEOUT", DefaultShutdownTimeout)
+	if err != nil {
+		errs = append(errs, err)
+	}
+	cfg.ShutdownTimeout = timeout
+	requestTimeout, err := durationEnv("REQUEST_TIMEOUT", DefaultRequestTimeout)
+	if err != nil {
+		errs = append(errs, err)
+	}
+	cfg.RequestTimeout = requestTimeout
+	maxConns, err := intEnv("DB_MAX_CONNS", DefaultDBMaxConns)
+	if err != nil {
+		errs = append(errs, err)
+	}
+	cfg.DBMaxConns = maxConns
+	minIdleConns, err := intEnv("DB_MIN_IDLE_CONNS", DefaultDBMinIdleConns)
+	if err != nil {
+		errs = append(errs, err)
+	}
+	cfg.DBMinIdleConns = minIdleConns
+	if cfg.DBMinIdleConns > cfg.DBMaxConns {
+		errs = append(errs, fmt.Errorf("DB_MIN_IDLE_CONNS must be at most DB_MAX_CONNS (%d), got %d", cfg.DBMaxConns, cfg.DBMinIdleConns))
+	}
+	connLifetime, err := durationEnv("DB_CONN_MAX_LIFETIME", DefaultDBConnMaxLifetime)
+	if err != nil {
+		errs = append(errs, err)
+	}
+	cfg.DBConnMaxLifetime = connLifetime
+	connIdleTime, err := durationEnv("DB_CONN_MAX_IDLE_TIME", DefaultDBConnMaxIdleTime)
+	if err != nil {
+		errs = append(errs, err)
+	}
+	cfg.DBConnMaxIdleTime = connIdleTime
+	leeway, err := durationEnv("JWT_LEEWAY", DefaultJWTLeeway)
+	if err != nil {
+		errs = append(errs, err)
+	}
+	cfg.JWTLeeway = leeway
+	webhookInterval, err := durationEnv("WEBHOOK_POLL_INTERVAL", DefaultWebhookInterval)
+	if err != nil {
+		errs = append(errs, err)
+	}
+	cfg.WebhookPollInterval = webhookInterval
+	overdueInterval, err := durationEnv("OVERDUE_SCAN_INTERVAL", DefaultOverdueInterval)
+	if err != nil {
+		errs = append(errs, err)
+	}
+	cfg.OverdueScanInterval = overdueInterval
+	recurringInterval, err := durationEnv("RECURRING_SCAN_INTERVAL", DefaultRecurringInterval)
+	if err != nil {
+		errs = append(errs, err)
+	}
+	cfg.RecurringScanInterval = recurringInterval
+	dunningInterval, err := durationEnv("DUNNING_SCAN_INTERVAL", DefaultDunningInterval)
+	if err != nil {
+		errs = append(errs, err)
+	}
+	cfg.DunningScanInterval = dunningInterval
+	schedule, err := daysEnv("DUNNING_SCHEDULE", DefaultDunningSchedule)
+	if err != nil {
+		errs = append(errs, err)
+	}
+	cfg.DunningSchedule = schedule
+	deletedDays, err := intEnv("DELETED_RETENTION_DAYS", DefaultDeletedRetentionDays)
+	if err != nil {
+		errs = append(errs, err)
+	}
+	auditDays, err := intEnv("AUDIT_RETENTION_DAYS", DefaultAuditRetentionDays)
+	if err != nil {
+		errs = append(errs, err)
+	}
+	if auditDays < deletedDays {
+		errs = append(errs, fmt.Errorf("AUDIT_RETENTION_DAYS must be at least DELETED_RETENTION_DAYS (%d), got %d", deletedDays, auditDays))
+	}
+	cfg.DeletedRetention = time.Duration(deletedDays) * 24 * time.Hour
+	cfg.AuditRetention = time.Duration(auditDays) * 24 * time.Hour
+	purgeInterval, err := durationEnv("PURGE_INTERVAL", DefaultPurgeInterval)
+	if err != nil {
+		errs = append(errs, err)
+	}
+	cfg.PurgeInterval = purgeInterval
+	numbers, err := invoiceNumberEnv()
+	if err != nil {
+		errs = append(errs, err)
+	}
+	cfg.InvoiceNumbers = numbers
+	summaryTTL, err := durationEnv("SUMMARY_CACHE_TTL", DefaultSummaryCacheTTL)
+	if err != nil {
+		errs = append(errs, err)
+	}
+	cfg.SummaryCacheTTL = summaryTTL
+	if cfg.SMTPAddr != "" {
+		if _, _, err := net.SplitHostPort(cfg.SMTPAddr); err != nil {
+			errs = append(errs, fmt.Errorf("SMTP_ADDR must be host:port (e.g. smtp.example.com:587), got %q", cfg.SMTPAddr))
+		}
+		if cfg.SMTPFrom == "" {
+			errs = append(errs, errors.New("SMTP_FROM is required when SMTP_ADDR is set"))
+		}
+	}
+	rps, err := floatEnv("RATE_LIMIT_RPS", DefaultRateLimitRPS)
+	if err != nil {
+		errs = append(errs, err)
+	}
+	cfg.RateLimitRPS = rps
+	burst, err := intEnv("RATE_LIMIT_BURST", DefaultRateLimitBurst)
+	if err != nil {
+		errs = append(errs, err)
+	}
+	cfg.RateLimitBurst = burst
+	maxBody, err := intEnv("MAX_BODY_BYTES", DefaultMaxBodyBytes)
+	if err != nil {
+		errs = append(errs, err)
+	}
+	cfg.MaxBodyBytes = int64(maxBody)
+	origins, err := originsEnv("CORS_ALLOWED_ORIGINS", DefaultCORSAllowedOrigins)
+	if err != nil {
+		errs = append(errs, err)
+	}
+	cfg.CORSAllowedOrigins = origins
+	attachmentBytes, err := intEnv("ATTACHMENT_MAX_BYTES", DefaultAttachmentBytes)
+	if err != nil {
+		errs = append(errs, err)
+	}
+	cfg.AttachmentMaxBytes = int64(attachmentBytes)
+	contentTypes, err := mediaTypesEnv("ATTACHMENT_CONTENT_TYPES", DefaultAttachmentContentTypes)
+	if err != nil {
+		errs = append(errs, err)
+	}
+	cfg.AttachmentContentTypes = contentTypes
+	if cfg.S3Bucket != "" {
+		if cfg.S3Region == "" {
+			errs = append(errs, errors.New("S3_REGION is required when S3_BUCKET is set"))
+		}
+		if cfg.S3AccessKeyID == "" || cfg.S3SecretAccessKey == "" {
+			errs = append(errs, errors.New("S3_ACCESS_KEY_ID and S3_SECRET_ACCESS_KEY are required when S3_BUCKET is set"))
+		}
+		if cfg.S3Endpoint != "" {
+			if parsed, err := url.Parse(cfg.S3Endpoint); err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+				errs = append(errs, fmt.Errorf("S3_ENDPOINT must be an http or https URL, got %q", cfg.S3Endpoint))
+			}
+		}
+	}
+	if (cfg.StripeSecretKey == "") != (cfg.StripeWebhookSecret == "") {
+		errs = append(errs, errors.New("STRIPE_SECRET_KEY and STRIPE_WEBHOOK_SECRET must be set together"))
+	}
+	if cfg.ShareLinkSecret != "" && len(cfg.ShareLinkSecret) < MinShareLinkSecretBytes {
+		errs = append(errs, fmt.Errorf("SHARE_LINK_SECRET must be at least %d bytes, got %d", MinShareLinkSecretBytes, len(cfg.ShareLinkSecret)))
+	}
+	if cfg.PublicBaseURL != "" {
+		if parsed, err := url.Parse(cfg.PublicBaseURL); err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" || parsed.RawQuery != "" || parsed.Fragment != "" {
+			errs = append(errs, fmt.Errorf("PUBLIC_BASE_URL must be an http or https URL, got %q", cfg.PublicBaseURL))
+		}
+	}
+
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return cfg, nil
+}
+
+func stringEnv(name, fallback string) string {
+	if value := strings.TrimSpace(os.Getenv(name)); value != "" {
+		return value
+	}
+	return fallback
+}
+
+func durationEnv(name string, fallback time.Duration) (time.Duration, error) {
+	value := strings.TrimSpace(os.Getenv(name))
+	if value == "" {
+		return fallback, nil
+	}
+	parsed, err := time.ParseDuration(value)
+	if err != nil || parsed <= 0 {
+		return fallback, fmt.Errorf("%s must be a positive duration (e.g. 15s), got %q", name, value)
+	}
+	return parsed, nil
+}
+
+// invoiceNumberEnv reads INVOICE_NUMBER_PREFIX, INVOICE_NUMBER_WIDTH and
+// INVOICE_NUMBER_YEARLY_RESET.
+func invoiceNumberEnv() (invoice.NumberFormat, error) {
+	format := invoice.DefaultNumberFormat
+	format.Prefix = stringEnv("INVOICE_NUMBER_PREFIX", format.Prefix)
+	var errs []error
+	if len(format.Prefix) > invoice.MaxNumberPrefixLength {
+		errs = append(errs, fmt.Errorf("INVOICE_NUMBER_PREFIX must be at most %d characters, got %q", invoice.MaxNumberPrefixLength, format.Prefix))
+	}
+	width, err := intEnv("INVOICE_NUMBER_WIDTH", format.Width)
+	if err == nil && width > invoice.MaxNumberWidth {
+		err = fmt.Errorf("INVOICE_NUMBER_WIDTH must be at most %d, got %d", invoice.MaxNumberWidth, width)
+	}
+	if err != nil {
+		errs = append(errs, err)
+	}
+	format.Width = width
+	if format.YearlyReset, err = boolEnv("INVOICE_NUMBER_YEARLY_RESET", false); err != nil {
+		errs = append(errs, err)
+	}
+	return format, errors.Join(errs...)
+}
+
+func boolEnv(name string, fallback bool) (bool, error) {
+	value := strings.TrimSpace(os.Getenv(name))
+	if value == "" {
+		return fallback, nil
+	}
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		return fallback, fmt.Errorf("%s must be true or false, got %q", name, value)
+	}
+	return parsed, nil
+}
+
+func floatEnv(name string, fallback float64) (float64, error) {
+	value := strings.TrimSpace(os.Getenv(name))
+	if value == "" {
+		return fallback, nil
+	}
+	parsed, err := strconv.ParseFloat(value, 64)
+	if err != nil || parsed <= 0 || math.IsInf(parsed, 0) {
+		return fallback, fmt.Errorf("%s must be a positive number, got %q", name, value)
+	}
+	return parsed, nil
+}
+
+func intEnv(name string, fallback int) (int, error) {
+	value := strings.TrimSpace(os.Getenv(name))
+	if value == "" {
+		return fallback, nil
+	}
+	parsed, err := strconv.Atoi(value)
+	if err != nil || parsed <= 0 {
+		return fallback, fmt.Errorf("%s must be a positive integer, got %q", name, value)
+	}
+	return parsed, nil
+}
+
+// daysEnv reads a comma-separated, strictly ascending list of day counts,
+// such as "1,7,14".
+func daysEnv(name string, fallback []int) ([]int, error) {
+	value := strings.TrimSpace(os.Getenv(name))
+	if value == "" {
+		return fallback, nil
+	}
+	var days []int
+	for _, field := range strings.Split(value, ",") {
+		day, err := strconv.Atoi(strings.TrimSpace(field))
+		if err != nil || day < 0 || (len(days) > 0 && day <= days[len(days)-1]) {
+			return fallback, fmt.Errorf("%s must be ascending days past due (e.g. 1,7,14), got %q", name, value)
+		}
+		days = append(days, day)
+	}
+	return days, nil
+}
+
+// mediaTypesEnv reads a comma-separated list of media types such as
+// "application/pdf,image/png", without parameters.
+func mediaTypesEnv(name string, fallback []string) ([]string, error) {
+	value := strings.TrimSpace(os.Getenv(name))
+	if value == "" {
+		return fallback, nil
+	}
+	var mediaTypes []string
+	for _, field := range strings.Split(value, ",") {
+		mediaType := strings.ToLower(strings.TrimSpace(field))
+		kind, subtype, ok := strings.Cut(mediaType, "/")
+		if !ok || kind == "" || subtype == "" || strings.ContainsAny(mediaType, " ;*") || strings.Contains(subtype, "/") {
+			return fallback, fmt.Errorf("%s must list media types like application/pdf, got %q", name, field)
+		}
+		mediaTypes = append(mediaTypes, mediaType)
+	}
+	return mediaTypes, nil
+}
+
+// originsEnv reads a comma-separated list of origins. Each must be a bare
+// scheme://host[:port]: a wildcard cannot be combined with credentials.
+func originsEnv(name string, fallback []string) ([]string, error) {
+	value := strings.TrimSpace(os.Getenv(name))
+	if value == "" {
+		return fallback, nil
+	}
+	var origins []string
+	for _, origin := range strings.Split(value, ",") {
+		origin = strings.TrimSuffix(strings.TrimSpace(origin), "/")
+		parsed, err := url.Parse(origin)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" || parsed.Path != "" || parsed.RawQuery != "" || parsed.User != nil {
+			return fallback, fmt.Errorf("%s must list origins like https://app.example.com, got %q", name, origin)
+		}
+		origins = append(origins, parsed.Scheme+"://"+strings.ToLower(parsed.Host))
+	}
+	return origins, nil
+}
@@ -0,0 +1,196 @@
+package config
+
+import (
+	"log/slog"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/invoice"
+)
+
+func TestLoadDefaults(t *testing.T) {
+	clearEnv(t)
+	t.Setenv("DATABASE_URL", "postgres://localhost/invoices")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if cfg.ListenAddr != DefaultListenAddr || cfg.ShutdownTimeout != DefaultShutdownTimeout || cfg.LogLevel != slog.LevelInfo ||
+		cfg.OverdueScanInterval != DefaultOverdueInterval || cfg.RecurringScanInterval != DefaultRecurringInterval || cfg.RateLimitRPS != DefaultRateLimitRPS || cfg.RateLimitBurst != DefaultRateLimitBurst ||
+		cfg.MaxBodyBytes != DefaultMaxBodyBytes || !slices.Equal(cfg.CORSAllowedOrigins, DefaultCORSAllowedOrigins) ||
+		!slices.Equal(cfg.DunningSchedule, DefaultDunningSchedule) || cfg.DunningScanInterval != DefaultDunningInterval || cfg.SMTPAddr != "" ||
+		cfg.AttachmentMaxBytes != DefaultAttachmentBytes || !slices.Equal(cfg.AttachmentContentTypes, DefaultAttachmentContentTypes) || cfg.AttachmentDir != DefaultAttachmentDir || cfg.S3Bucket != "" || cfg.SummaryCacheTTL != DefaultSummaryCacheTTL || cfg.RequestTimeout != DefaultRequestTimeout ||
+		cfg.DBMaxConns != DefaultDBMaxConns || cfg.DBMinIdleConns != DefaultDBMinIdleConns || cfg.DBConnMaxLifetime != DefaultDBConnMaxLifetime || cfg.DBConnMaxIdleTime != DefaultDBConnMaxIdleTime ||
+		cfg.InvoiceNumbers != invoice.DefaultNumberFormat || cfg.StripeSecretKey != "" || cfg.ShareLinkSecret != "" || cfg.PublicBaseURL != "" ||
+		cfg.DeletedRetention != DefaultDeletedRetentionDays*24*time.Hour || cfg.AuditRetention != DefaultAuditRetentionDays*24*time.Hour || cfg.PurgeInterval != DefaultPurgeInterval {
+		t.Fatalf("unexpected defaults: %#v", cfg)
+	}
+	if cfg.DatabaseURL != "postgres://localhost/invoices" {
+		t.Fatalf("unexpected database url %q", cfg.DatabaseURL)
+	}
+}
+
+func TestLoadReadsOverrides(t *testing.T) {
+	clearEnv(t)
+	t.Setenv("DATABASE_URL", " postgres://db/invoices ")
+	t.Setenv("LISTEN_ADDR", "127.0.0.1:9090")
+	t.Setenv("LOG_LEVEL", "debug")
+	t.Setenv("SHUTDOWN_TIMEOUT", "30s")
+	t.Setenv("PDF_COMPANY_NAME", "Acme Ltd")
+	t.Setenv("RATE_LIMIT_RPS", "2.5")
+	t.Setenv("RATE_LIMIT_BURST", "10")
+	t.Setenv("MAX_BODY_BYTES", "65536")
+	t.Setenv("CORS_ALLOWED_ORIGINS", "https://App.example.com/, http://localhost:3000")
+	t.Setenv("DUNNING_SCHEDULE", "3, 10,30")
+	t.Setenv("SUMMARY_CACHE_TTL", "5s")
+	t.Setenv("REQUEST_TIMEOUT", "10s")
+	t.Setenv("DB_MAX_CONNS", "50")
+	t.Setenv("DB_MIN_IDLE_CONNS", "5")
+	t.Setenv("DB_CONN_MAX_LIFETIME", "30m")
+	t.Setenv("DB_CONN_MAX_IDLE_TIME", "2m")
+	t.Setenv("INVOICE_NUMBER_PREFIX", "ACME-")
+	t.Setenv("INVOICE_NUMBER_WIDTH", "6")
+	t.Setenv("INVOICE_NUMBER_YEARLY_RESET", "true")
+	t.Setenv("SMTP_ADDR", "smtp.example.com:587")
+	t.Setenv("SMTP_FROM", "billing@example.com")
+	t.Setenv("ATTACHMENT_MAX_BYTES", "2097152")
+	t.Setenv("ATTACHMENT_CONTENT_TYPES", "application/pdf, Image/PNG")
+	t.Setenv("S3_BUCKET", "invoices")
+	t.Setenv("S3_REGION", "eu-west-1")
+	t.Setenv("S3_ACCESS_KEY_ID", "key-id")
+	t.Setenv("S3_SECRET_ACCESS_KEY", "secret")
+	t.Setenv("STRIPE_SECRET_KEY", "sk_test_1")
+	t.Setenv("STRIPE_WEBHOOK_SECRET", "whsec_1")
+	t.Setenv("SHARE_LINK_SECRET", "0123456789abcdef0123456789abcdef")
+	t.Setenv("PUBLIC_BASE_URL", "https://invoices.example.com/")
+	t.Setenv("DELETED_RETENTION_DAYS", "30")
+	t.Setenv("AUDIT_RETENTION_DAYS", "365")
+	t.Setenv("PURGE_INTERVAL", "6h")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if cfg.DatabaseURL != "postgres://db/invoices" || cfg.ListenAddr != "127.0.0.1:9090" ||
+		cfg.LogLevel != slog.LevelDebug || cfg.ShutdownTimeout != 30*time.Second || cfg.PDFCompanyName != "Acme Ltd" ||
+		cfg.RateLimitRPS != 2.5 || cfg.RateLimitBurst != 10 || cfg.MaxBodyBytes != 65536 ||
+		!slices.Equal(cfg.CORSAllowedOrigins, []string{"https://app.example.com", "http://localhost:3000"}) ||
+		!slices.Equal(cfg.DunningSchedule, []int{3, 10, 30}) || cfg.SMTPAddr != "smtp.example.com:587" || cfg.SMTPFrom != "billing@example.com" ||
+		cfg.AttachmentMaxBytes != 2097152 || !slices.Equal(cfg.AttachmentContentTypes, []string{"application/pdf", "image/png"}) ||
+		cfg.S3Bucket != "invoices" || cfg.S3Region != "eu-west-1" || cfg.S3AccessKeyID != "key-id" || cfg.S3SecretAccessKey != "secret" || cfg.SummaryCacheTTL != 5*time.Second || cfg.RequestTimeout != 10*time.Second ||
+		cfg.DBMaxConns != 50 || cfg.DBMinIdleConns != 5 || cfg.DBConnMaxLifetime != 30*time.Minute || cfg.DBConnMaxIdleTime != 2*time.Minute ||
+		cfg.InvoiceNumbers != (invoice.NumberFormat{Prefix: "ACME-", Width: 6, YearlyReset: true}) || cfg.StripeSecretKey != "sk_test_1" || cfg.StripeWebhookSecret != "whsec_1" ||
+		cfg.ShareLinkSecret != "0123456789abcdef0123456789abcdef" || cfg.PublicBaseURL != "https://invoices.example.com" ||
+		cfg.DeletedRetention != 30*24*time.Hour || cfg.AuditRetention != 365*24*time.Hour || cfg.PurgeInterval != 6*time.Hour {
+		t.Fatalf("unexpected config: %#v", cfg)
+	}
+}
+
+func TestLoadRequiresDatabaseURL(t *testing.T) {
+	clearEnv(t)
+
+	_, err := Load()
+	if err == nil || !strings.Contains(err.Error(), "DATABASE_URL") {
+		t.Fatalf("expected missing DATABASE_URL error, got %v", err)
+	}
+}
+
+func TestLoadRejectsMalformedValues(t *testing.T) {
+	cases := map[string]string{
+		"LISTEN_ADDR":                 "8080",
+		"LOG_LEVEL":                   "verbose",
+		"SHUTDOWN_TIMEOUT":            "soon",
+		"JWT_LEEWAY":                  "0s",
+		"OVERDUE_SCAN_INTERVAL":       "hourly",
+		"RECURRING_SCAN_INTERVAL":     "0s",
+		"RATE_LIMIT_RPS":              "-1",
+		"RATE_LIMIT_BURST":            "1.5",
+		"MAX_BODY_BYTES":              "1MB",
+		"CORS_ALLOWED_ORIGINS":        "*",
+		"DUNNING_SCHEDULE":            "7,1",
+		"DUNNING_SCAN_INTERVAL":       "-1h",
+		"REQUEST_TIMEOUT":             "0",
+		"DB_MAX_CONNS":                "many",
+		"DB_MIN_IDLE_CONNS":           "40",
+		"DB_CONN_MAX_LIFETIME":        "forever",
+		"DB_CONN_MAX_IDLE_TIME":       "0s",
+		"SUMMARY_CACHE_TTL":           "briefly",
+		"SMTP_ADDR":                   "smtp.example.com",
+		"ATTACHMENT_MAX_BYTES":        "10MB",
+		"ATTACHMENT_CONTENT_TYPES":    "application/*",
+		"INVOICE_NUMBER_PREFIX":       "INVOICES-FOR-ACME-LIMITED-",
+		"INVOICE_NUMBER_WIDTH":        "13",
+		"INVOICE_NUMBER_YEARLY_RESET": "yearly",
+		"SHARE_LINK_SECRET":           "too-short",
+		"PUBLIC_BASE_URL":             "invoices.example.com",
+		"DELETED_RETENTION_DAYS":      "0",
+		"AUDIT_RETENTION_DAYS":        "30",
+		"PURGE_INTERVAL":              "daily",
+	}
+	for name, value := range cases {
+		t.Run(name, func(t *testing.T) {
+			clearEnv(t)
+			t.Setenv("DATABASE_URL", "postgres://localhost/invoices")
+			t.Setenv(name, value)
+
+			_, err := Load()
+			if err == nil || !strings.Contains(err.Error(), name) {
+				t.Fatalf("expected error naming %s, got %v", name, err)
+			}
+		})
+	}
+}
+
+func TestLoadRequiresSMTPFromWithSMTPAddr(t *testing.T) {
+	clearEnv(t)
+	t.Setenv("DATABASE_URL", "postgres://localhost/invoices")
+	t.Setenv("SMTP_ADDR", "smtp.example.com:587")
+
+	_, err := Load()
+	if err == nil || !strings.Contains(err.Error(), "SMTP_FROM") {
+		t.Fatalf("expected missing SMTP_FROM error, got %v", err)
+	}
+}
+
+func TestLoadRequiresS3Settings(t *testing.T) {
+	clearEnv(t)
+	t.Setenv("DATABASE_URL", "postgres://localhost/invoices")
+	t.Setenv("S3_BUCKET", "invoices")
+	t.Setenv("S3_ENDPOINT", "minio:9000")
+
+	_, err := Load()
+	if err == nil || !strings.Contains(err.Error(), "S3_REGION") || !strings.Contains(err.Error(), "S3_ACCESS_KEY_ID") || !strings.Contains(err.Error(), "S3_ENDPOINT") {
+		t.Fatalf("expected the missing S3 settings reported, got %v", err)
+	}
+}
+
+func TestLoadRequiresBothStripeSecrets(t *testing.T) {
+	clearEnv(t)
+	t.Setenv("DATABASE_URL", "postgres://localhost/invoices")
+	t.Setenv("STRIPE_SECRET_KEY", "sk_test_1")
+
+	_, err := Load()
+	if err == nil || !strings.Contains(err.Error(), "STRIPE_WEBHOOK_SECRET") {
+		t.Fatalf("expected missing STRIPE_WEBHOOK_SECRET error, got %v", err)
+	}
+}
+
+func TestLoadReportsEveryProblem(t *testing.T) {
+	clearEnv(t)
+	t.Setenv("SHUTDOWN_TIMEOUT", "-5s")
+
+	_, err := Load()
+	if err == nil || !strings.Contains(err.Error(), "DATABASE_URL") || !strings.Contains(err.Error(), "SHUTDOWN_TIMEOUT") {
+		t.Fatalf("expected both problems reported, got %v", err)
+	}
+}
+
+func clearEnv(t *testing.T) {
+	t.Helper()
+	for _, name := range []string{"DATABASE_URL", "LISTEN_ADDR", "LOG_LEVEL", "SHUTDOWN_TIMEOUT", "PDF_COMPANY_NAME", "JWT_PUBLIC_KEY_FILE", "JWT_LEEWAY", "WEBHOOK_POLL_INTERVAL", "OVERDUE_SCAN_INTERVAL", "RECURRING_SCAN_INTERVAL", "RATE_LIMIT_RPS", "RATE_LIMIT_BURST", "MAX_BODY_BYTES", "CORS_ALLOWED_ORIGINS", "DUNNING_SCHEDULE", "DUNNING_SCAN_INTERVAL", "SMTP_ADDR", "SMTP_FROM", "SMTP_USERNAME", "SMTP_PASSWORD", "ATTACHMENT_MAX_BYTES", "ATTACHMENT_CONTENT_TYPES", "ATTACHMENT_DIR", "S3_BUCKET", "S3_REGION", "S3_ENDPOINT", "S3_ACCESS_KEY_ID", "S3_SECRET_ACCESS_KEY", "SUMMARY_CACHE_TTL", "REQUEST_TIMEOUT", "DB_MAX_CONNS", "DB_MIN_IDLE_CONNS", "DB_CONN_MAX_LIFETIME", "DB_CONN_MAX_IDLE_TIME", "INVOICE_NUMBER_PREFIX", "INVOICE_NUMBER_WIDTH", "INVOICE_NUMBER_YEARLY_RESET", "STRIPE_SECRET_KEY", "STRIPE_WEBHOOK_SECRET", "SHARE_LINK_SECRET", "PUBLIC_BASE_URL", "DELETED_RETENTION_DAYS", "AUDIT_RETENTION_DAYS", "PURGE_INTERVAL"} {
+		t.Setenv(name, "")
+	}
+}
@@ -0,0 +1,79 @@
+package invoice
+
+import "time"
+
+// DunningStatus is where an invoice stands in its reminder schedule.
+type DunningStatus string
+
+const (
+	// DunningPending is an open invoice that is not yet past due.
+	DunningPending DunningStatus = "pending"
+	// DunningActive is an overdue invoice with scheduled reminders left.
+	DunningActive DunningStatus = "active"
+	// DunningCompleted is an overdue invoice that has had every scheduled
+	// reminder; only manual reminders follow.
+	DunningCompleted DunningStatus = "completed"
+	// DunningStopped is an invoice that was paid, voided or deleted after
+	// reminders went out.
+	DunningStopped DunningStatus = "stopped"
+)
+
+// Dunning is the reminder state shown on an invoice. It is derived from the
+// reminders sent so far and is never stored with the invoice.
+type Dunning struct {
+	Status         DunningStatus `json:"status"`
+	RemindersSent  int           `json:"remindersSent"`
+	LastReminderAt string        `json:"lastReminderAt,omitempty"`
+	NextReminderAt string        `json:"nextReminderAt,omitempty"`
+}
+
+// NextReminderAt is when the reminder after the sent ones is due: the due
+// date plus the schedule's days past due for that step, and no sooner after
+// lastReminderAt than the schedule spaces the two steps, so an invoice that
+// is already weeks overdue does not get its reminders in a burst. It
+// reports false once the schedule is used up or the invoice has no due
+// date. Manual reminders count as sent, so they move the schedule on.
+func (inv Invoice) NextReminderAt(schedule []int, sent int, lastReminderAt string) (time.Time, bool) {
+	if sent >= len(schedule) || inv.DueAt == "" {
+		return time.Time{}, false
+	}
+	dueAt, err := time.Parse(time.RFC3339, inv.DueAt)
+	if err != nil {
+		return time.Time{}, false
+	}
+	next := dueAt.AddDate(0, 0, schedule[sent])
+	if last, err := time.Parse(time.RFC3339, lastReminderAt); err == nil && sent > 0 {
+		if spaced := last.AddDate(0, 0, schedule[sent]-schedule[sent-1]); spaced.After(next) {
+			next = spaced
+		}
+	}
+	return next, true
+}
+
+// DunningState describes the invoice's reminders under schedule, or returns
+// nil when it has none and never will: drafts, invoices without a due date,
+// and invoices settled before any reminder.
+func (inv Invoice) DunningState(schedule []int, sent int, lastReminderAt string) *Dunning {
+	dunning := &Dunning{RemindersSent: sent, LastReminderAt: lastReminderAt}
+	live := inv.DeletedAt == "" && (inv.Status == StatusOpen || inv.Status == StatusOverdue)
+	switch {
+	case !live && sent > 0:
+		dunning.Status = DunningStopped
+		return dunning
+	case !live, inv.DueAt == "" && sent == 0:
+		return nil
+	}
+	next, ok := inv.NextReminderAt(schedule, sent, lastReminderAt)
+	switch {
+	case ok && inv.Status == StatusOpen && sent == 0:
+		dunning.Status = DunningPending
+	case !ok && inv.DueAt != "":
+		dunning.Status = DunningCompleted
+	default:
+		dunning.Status = DunningActive
+	}
+	if ok {
+		dunning.NextReminderAt = next.UTC().Format(time.RFC3339)
+	}
+	return dunning
+}
@@ -0,0 +1,34 @@
+package invoice
+
+import "testing"
+
+func TestDunningStateFollowsSchedule(t *testing.T) {
+	schedule := []int{1, 7, 14}
+	overdue := Invoice{Status: StatusOverdue, DueAt: "2026-03-01T00:00:00Z"}
+	cases := map[string]struct {
+		item Invoice
+		sent int
+		last string
+		want *Dunning
+	}{
+		"draft":                        {item: Invoice{Status: StatusDraft, DueAt: overdue.DueAt}, want: nil},
+		"no due":                       {item: Invoice{Status: StatusOpen}, want: nil},
+		"pending":                      {item: Invoice{Status: StatusOpen, DueAt: overdue.DueAt}, want: &Dunning{Status: DunningPending, NextReminderAt: "2026-03-02T00:00:00Z"}},
+		"active":                       {item: overdue, sent: 1, want: &Dunning{Status: DunningActive, RemindersSent: 1, NextReminderAt: "2026-03-08T00:00:00Z"}},
+		"spaced after a late reminder": {item: overdue, sent: 1, last: "2026-03-05T00:00:00Z", want: &Dunning{Status: DunningActive, RemindersSent: 1, LastReminderAt: "2026-03-05T00:00:00Z", NextReminderAt: "2026-03-11T00:00:00Z"}},
+		"completed":                    {item: overdue, sent: 3, want: &Dunning{Status: DunningCompleted, RemindersSent: 3}},
+		"manual beyond schedule":       {item: overdue, sent: 4, want: &Dunning{Status: DunningCompleted, RemindersSent: 4}},
+		"paid after reminders":         {item: Invoice{Status: StatusPaid, DueAt: overdue.DueAt}, sent: 2, want: &Dunning{Status: DunningStopped, RemindersSent: 2}},
+		"paid before reminders":        {item: Invoice{Status: StatusPaid, DueAt: overdue.DueAt}, want: nil},
+		"deleted":                      {item: Invoice{Status: StatusOverdue, DueAt: overdue.DueAt, DeletedAt: "2026-03-03T00:00:00Z"}, sent: 1, want: &Dunning{Status: DunningStopped, RemindersSent: 1}},
+	}
+	for name, tc := range cases {
+		got := tc.item.DunningState(schedule, tc.sent, tc.last)
+		switch {
+		case tc.want == nil && got != nil:
+			t.Errorf("%s: expected no dunning, got %+v", name, *got)
+		case tc.want != nil && (got == nil || *got != *tc.want):
+			t.Errorf("%s: got %+v, want %+v", name, got, *tc.want)
+		}
+	}
+}
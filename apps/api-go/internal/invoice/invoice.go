@@ -0,0 +1,193 @@
+// Package invoice holds the invoice domain model and its write-time
+// invariants. Money is always carried in integer minor units (cents, agorot,
+// ...) so totals never pick up float rounding drift.
+package invoice
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/tax"
+)
+
+type Status string
+
+const maxPaymentReferenceLength = 255
+
+const (
+	StatusDraft   Status = "draft"
+	StatusOpen    Status = "open"
+	StatusPaid    Status = "paid"
+	StatusOverdue Status = "overdue"
+	StatusVoid    Status = "void"
+)
+
+var knownStatuses = map[Status]bool{
+	StatusDraft:   true,
+	StatusOpen:    true,
+	StatusPaid:    true,
+	StatusOverdue: true,
+	StatusVoid:    true,
+}
+
+func (s Status) Valid() bool {
+	return knownStatuses[s]
+}
+
+// Invoice is the stored invoice header. Items is only loaded for single
+// invoice reads; listings leave it empty. PaidAt is required once the
+// invoice is paid; PaymentReference is the optional bank or processor
+// reference that settled it.
+//
+// Tax is always computed, see ComputeTax. TaxRate only applies to invoices
+// without line items; TaxExempt is copied from the customer when the
+// invoice is written. AmountPaid is the sum of the recorded payments.
+// DeletedAt is set once the invoice is soft-deleted. Version starts at 1
+// and goes up by one with every write, so a client can tell whether the
+// invoice changed since it read it. Dunning is only filled in on single
+// invoice reads. VoidedAt and VoidReason are set once the invoice is voided,
+// and AmountCredited is the sum of its credit notes.
+type Invoice struct {
+	ID               string       `json:"id"`
+	TenantID         string       `json:"tenantId"`
+	Number           string       `json:"number"`
+	CustomerID       string       `json:"customerId"`
+	Currency         string       `json:"currency"`
+	Subtotal         int64        `json:"subtotal"`
+	Tax              int64        `json:"tax"`
+	Total            int64        `json:"total"`
+	TaxRate          int64        `json:"taxRate"`
+	TaxRounding      tax.Rounding `json:"taxRounding"`
+	TaxExempt        bool         `json:"taxExempt"`
+	AmountPaid       int64        `json:"amountPaid"`
+	Status           Status       `json:"status"`
+	IssuedAt         string       `json:"issuedAt,omitempty"`
+	DueAt            string       `json:"dueAt,omitempty"`
+	PaidAt           string       `json:"paidAt,omitempty"`
+	PaymentReference string       `json:"paymentReference,omitempty"`
+	Items            []LineItem   `json:"items,omitempty"`
+	CreatedAt        string       `json:"createdAt"`
+	UpdatedAt        string       `json:"updatedAt"`
+	DeletedAt        string       `json:"deletedAt,omitempty"`
+	VoidedAt         string       `json:"voidedAt,omitempty"`
+	VoidReason       string       `json:"voidReason,omitempty"`
+	AmountCredited   int64        `json:"amountCredited"`
+	Version          int64        `json:"version"`
+	Dunning          *Dunning     `json:"dunning,omitempty"`
+}
+
+// ValidationError describes why an invoice failed its write-time checks.
+type ValidationError struct {
+	Field   string
+	Message string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("%s %s", e.Field, e.Message)
+}
+
+// IsValidationError reports whether err came from Validate.
+func IsValidationError(err error) bool {
+	var validationErr *ValidationError
+	return errors.As(err, &validationErr)
+}
+
+// Validate checks the invariants every persisted invoice must satisfy.
+// Drafts, and drafts voided before they were issued, need no number.
+func (inv Invoice) Validate() error {
+	if inv.NeedsNumber() {
+		return &ValidationError{Field: "number", Message: "is required"}
+	}
+	return inv.ValidateUnnumbered()
+}
+
+// NeedsNumber reports whether the invoice is issued, open, overdue or paid,
+// without a number yet. The store gives it the next number of its series
+// when it writes it.
+func (inv Invoice) NeedsNumber() bool {
+	switch inv.Status {
+	case StatusOpen, StatusOverdue, StatusPaid:
+		return strings.TrimSpace(inv.Number) == ""
+	}
+	return false
+}
+
+// ValidateUnnumbered checks everything Validate does except the number, for
+// an invoice whose number the store allocates when it is issued.
+func (inv Invoice) ValidateUnnumbered() error {
+	if strings.TrimSpace(inv.CustomerID) == "" {
+		return &ValidationError{Field: "customerId", Message: "is required"}
+	}
+	if !validCurrencyCode(inv.Currency) {
+		return &ValidationError{Field: "currency", Message: "must be a 3-letter ISO 4217 code"}
+	}
+	if inv.Subtotal < 0 {
+		return &ValidationError{Field: "subtotal", Message: "must not be negative"}
+	}
+	if inv.Tax < 0 {
+		return &ValidationError{Field: "tax", Message: "must not be negative"}
+	}
+	if inv.TaxRate < 0 || inv.TaxRate > MaxTaxRate {
+		return &ValidationError{Field: "taxRate", Message: fmt.Sprintf("must be between 0 and %d basis points", MaxTaxRate)}
+	}
+	if inv.TaxRounding != "" && !inv.TaxRounding.Valid() {
+		return &ValidationError{Field: "taxRounding", Message: fmt.Sprintf("must be %s or %s", tax.RoundLine, tax.RoundInvoice)}
+	}
+	if inv.Subtotal > math.MaxInt64/MaxTaxRate {
+		return &ValidationError{Field: "subtotal", Message: "is too large"}
+	}
+	if inv.Subtotal+inv.Tax != inv.Total {
+		return &ValidationError{Field: "total", Message: fmt.Sprintf("must equal subtotal + tax (%d), got %d", inv.Subtotal+inv.Tax, inv.Total)}
+	}
+	if !inv.Status.Valid() {
+		return &ValidationError{Field: "status", Message: fmt.Sprintf("is not a known status: %q", inv.Status)}
+	}
+	issuedAt, err := parseOptionalTime(inv.IssuedAt)
+	if err != nil {
+		return &ValidationError{Field: "issuedAt", Message: "must be an RFC3339 timestamp"}
+	}
+	dueAt, err := parseOptionalTime(inv.DueAt)
+	if err != nil {
+		return &ValidationError{Field: "dueAt", Message: "must be an RFC3339 timestamp"}
+	}
+	if !issuedAt.IsZero() && !dueAt.IsZero() && dueAt.Before(issuedAt) {
+		return &ValidationError{Field: "dueAt", Message: "must not be before issuedAt"}
+	}
+	if _, err := parseOptionalTime(inv.PaidAt); err != nil {
+		return &ValidationError{Field: "paidAt", Message: "must be an RFC3339 timestamp"}
+	}
+	if inv.Status == StatusPaid && inv.PaidAt == "" {
+		return &ValidationError{Field: "paidAt", Message: "is required when status is paid"}
+	}
+	if len(inv.PaymentReference) > maxPaymentReferenceLength {
+		return &ValidationError{Field: "paymentReference", Message: fmt.Sprintf("must be at most %d characters", maxPaymentReferenceLength)}
+	}
+	return nil
+}
+
+// NormalizeCurrency upper-cases and trims a currency code.
+func NormalizeCurrency(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+func validCurrencyCode(code string) bool {
+	if len(code) != 3 {
+		return false
+	}
+	for _, r := range code {
+		if r < 'A' || r > 'Z' {
+			return false
+		}
+	}
+	return true
+}
+
+func parseOptionalTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
@@ -0,0 +1,66 @@
+package invoice
+
+import "testing"
+
+func validInvoice() Invoice {
+	return Invoice{
+		ID:         "inv-1",
+		TenantID:   "tenant-alpha",
+		Number:     "INV-0001",
+		CustomerID: "cust-1",
+		Currency:   "ILS",
+		Subtotal:   10000,
+		Tax:        1700,
+		Total:      11700,
+		Status:     StatusDraft,
+		IssuedAt:   "2026-06-01T00:00:00Z",
+		DueAt:      "2026-06-30T00:00:00Z",
+	}
+}
+
+func TestValidateAcceptsConsistentInvoice(t *testing.T) {
+	if err := validInvoice().Validate(); err != nil {
+		t.Fatalf("expected valid invoice, got %v", err)
+	}
+}
+
+func TestValidateAcceptsDraftWithoutNumber(t *testing.T) {
+	inv := validInvoice()
+	inv.Number = ""
+	if err := inv.Validate(); err != nil {
+		t.Fatalf("expected a draft without number valid, got %v", err)
+	}
+	inv.Status = StatusPaid
+	inv.PaidAt = "2026-06-10T00:00:00Z"
+	if !inv.NeedsNumber() {
+		t.Fatal("expected a paid invoice without number to need one")
+	}
+}
+
+func TestValidateRejectsInvalidInvoices(t *testing.T) {
+	cases := map[string]func(*Invoice){
+		"missing number":     func(inv *Invoice) { inv.Number = ""; inv.Status = StatusOpen },
+		"missing customer":   func(inv *Invoice) { inv.CustomerID = " " },
+		"lowercase currency": func(inv *Invoice) { inv.Currency = "ils" },
+		"negative tax":       func(inv *Invoice) { inv.Tax = -1; inv.Total = inv.Subtotal - 1 },
+		"total mismatch":     func(inv *Invoice) { inv.Total = 11701 },
+		"unknown status":     func(inv *Invoice) { inv.Status = "sent" },
+		"bad issued date":    func(inv *Invoice) { inv.IssuedAt = "2026-06-01" },
+		"due before issued":  func(inv *Invoice) { inv.DueAt = "2026-05-01T00:00:00Z" },
+		"paid without date":  func(inv *Invoice) { inv.Status = StatusPaid },
+		"bad paid date":      func(inv *Invoice) { inv.Status = StatusPaid; inv.PaidAt = "yesterday" },
+	}
+	for name, mutate := range cases {
+		t.Run(name, func(t *testing.T) {
+			inv := validInvoice()
+			mutate(&inv)
+			err := inv.Validate()
+			if err == nil {
+				t.Fatal("expected validation error")
+			}
+			if !IsValidationError(err) {
+				t.Fatalf("expected ValidationError, got %T", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,98 @@
+package invoice
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/tax"
+)
+
+// MaxTaxRate is 100% expressed in basis points.
+const MaxTaxRate = tax.MaxRate
+
+// ErrNotDraft is returned when line items of an issued invoice are changed.
+var ErrNotDraft = errors.New("invoice is not a draft")
+
+// LineItem is one billed row of an invoice. TaxRate is in basis points
+// (1700 = 17%); Amount and Tax are derived from the other fields and never
+// taken from clients. Under invoice-level rounding the invoice reassigns Tax
+// so the lines add up to its total.
+type LineItem struct {
+	ID          string `json:"id"`
+	InvoiceID   string `json:"invoiceId"`
+	Position    int    `json:"position"`
+	Description string `json:"description"`
+	Quantity    int64  `json:"quantity"`
+	UnitPrice   int64  `json:"unitPrice"`
+	TaxRate     int64  `json:"taxRate"`
+	Amount      int64  `json:"amount"`
+	Tax         int64  `json:"tax"`
+	CreatedAt   string `json:"createdAt"`
+}
+
+// Validate checks the client-supplied fields of a line item. It must run
+// before Normalize so an overflowing amount is reported instead of wrapped.
+func (li LineItem) Validate() error {
+	if strings.TrimSpace(li.Description) == "" {
+		return &ValidationError{Field: "description", Message: "is required"}
+	}
+	if li.Quantity <= 0 {
+		return &ValidationError{Field: "quantity", Message: "must be positive"}
+	}
+	if li.UnitPrice < 0 {
+		return &ValidationError{Field: "unitPrice", Message: "must not be negative"}
+	}
+	if li.TaxRate < 0 || li.TaxRate > MaxTaxRate {
+		return &ValidationError{Field: "taxRate", Message: fmt.Sprintf("must be between 0 and %d basis points", MaxTaxRate)}
+	}
+	if li.UnitPrice > 0 && li.Quantity > math.MaxInt64/li.UnitPrice/MaxTaxRate {
+		return &ValidationError{Field: "unitPrice", Message: "quantity * unitPrice is too large"}
+	}
+	return nil
+}
+
+// Normalize fills in the derived Amount and Tax. Line tax is rounded half up
+// to the nearest minor unit.
+func (li *LineItem) Normalize() {
+	li.Description = strings.TrimSpace(li.Description)
+	li.Amount = li.Quantity * li.UnitPrice
+	li.Tax = tax.LineTax(li.Amount, li.TaxRate)
+}
+
+// SetLineItems replaces the invoice's items and recomputes its totals from
+// them, so Subtotal, Tax and Total always agree with the stored rows.
+func (inv *Invoice) SetLineItems(items []LineItem) {
+	inv.Items = items
+	inv.Subtotal = 0
+	inv.ComputeTax()
+}
+
+// ComputeTax derives the totals under the invoice's tax policy. With line
+// items, Subtotal and Tax come from the lines; without them, Tax is Subtotal
+// at TaxRate. Items must be loaded. Any tax the client sent is overwritten.
+func (inv *Invoice) ComputeTax() {
+	lines := make([]tax.Line, 0, max(len(inv.Items), 1))
+	if len(inv.Items) > 0 {
+		inv.Subtotal = 0
+	}
+	for _, item := range inv.Items {
+		inv.Subtotal += item.Amount
+		lines = append(lines, tax.Line{Amount: item.Amount, Rate: item.TaxRate})
+	}
+	if len(lines) == 0 {
+		lines = append(lines, tax.Line{Amount: inv.Subtotal, Rate: inv.TaxRate})
+	}
+	result := inv.TaxPolicy().Compute(lines)
+	for i := range inv.Items {
+		inv.Items[i].Tax = result.Lines[i]
+	}
+	inv.Tax = result.Total
+	inv.Total = inv.Subtotal + inv.Tax
+}
+
+// TaxPolicy returns how tax is computed for the invoice.
+func (inv Invoice) TaxPolicy() tax.Policy {
+	return tax.Policy{Rounding: inv.TaxRounding, Exempt: inv.TaxExempt}
+}
@@ -0,0 +1,79 @@
+package invoice
+
+import (
+	"testing"
+
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/tax"
+)
+
+func TestNormalizeRoundsLineTaxHalfUp(t *testing.T) {
+	item := LineItem{Description: "Consulting", Quantity: 3, UnitPrice: 333, TaxRate: 1750}
+	item.Normalize()
+	// 999 * 17.5% = 174.825 -> 175
+	if item.Amount != 999 || item.Tax != 175 {
+		t.Fatalf("unexpected amounts: amount=%d tax=%d", item.Amount, item.Tax)
+	}
+}
+
+func TestSetLineItemsRecomputesTotals(t *testing.T) {
+	inv := validInvoice()
+	first := LineItem{Description: "Hosting", Quantity: 2, UnitPrice: 5000, TaxRate: 1700}
+	second := LineItem{Description: "Setup", Quantity: 1, UnitPrice: 2500}
+	first.Normalize()
+	second.Normalize()
+
+	inv.SetLineItems([]LineItem{first, second})
+	if inv.Subtotal != 12500 || inv.Tax != 1700 || inv.Total != 14200 {
+		t.Fatalf("unexpected totals: %d + %d = %d", inv.Subtotal, inv.Tax, inv.Total)
+	}
+	if err := inv.Validate(); err != nil {
+		t.Fatalf("expected recomputed invoice to validate, got %v", err)
+	}
+
+	inv.SetLineItems(nil)
+	if inv.Subtotal != 0 || inv.Tax != 0 || inv.Total != 0 {
+		t.Fatalf("expected zero totals without items, got %d/%d/%d", inv.Subtotal, inv.Tax, inv.Total)
+	}
+}
+
+func TestLineItemValidateRejectsInvalidItems(t *testing.T) {
+	cases := map[string]LineItem{
+		"missing description": {Quantity: 1, UnitPrice: 100},
+		"zero quantity":       {Description: "x", Quantity: 0, UnitPrice: 100},
+		"negative price":      {Description: "x", Quantity: 1, UnitPrice: -1},
+		"tax above 100%":      {Description: "x", Quantity: 1, UnitPrice: 100, TaxRate: 10001},
+		"overflowing amount":  {Description: "x", Quantity: 1 << 40, UnitPrice: 1 << 40},
+	}
+	for name, item := range cases {
+		t.Run(name, func(t *testing.T) {
+			if err := item.Validate(); !IsValidationError(err) {
+				t.Fatalf("expected ValidationError, got %v", err)
+			}
+		})
+	}
+}
+
+func TestComputeTaxFollowsInvoicePolicy(t *testing.T) {
+	half := LineItem{Description: "x", Quantity: 1, UnitPrice: 1, TaxRate: 5000}
+	half.Normalize()
+
+	inv := validInvoice()
+	inv.TaxRounding = tax.RoundInvoice
+	inv.SetLineItems([]LineItem{half, half, half})
+	if inv.Tax != 2 || inv.Items[0].Tax+inv.Items[1].Tax+inv.Items[2].Tax != 2 {
+		t.Fatalf("expected invoice rounding to bill 2 across the lines, got %d %#v", inv.Tax, inv.Items)
+	}
+
+	inv.TaxExempt = true
+	inv.ComputeTax()
+	if inv.Tax != 0 || inv.Total != inv.Subtotal {
+		t.Fatalf("expected no tax when exempt, got %d", inv.Tax)
+	}
+
+	bare := validInvoice()
+	bare.Subtotal, bare.Tax, bare.TaxRate = 10000, 1, 1700
+	bare.ComputeTax()
+	if bare.Tax != 1700 || bare.Total != 11700 {
+		t.Fatalf("expected tax from the invoice rate, got %d + %d = %d", bare.Subtotal, bare.Tax, bare.Total)
+	}
+}
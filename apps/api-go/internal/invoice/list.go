@@ -0,0 +1,144 @@
+package invoice
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+const (
+	DefaultListLimit = 50
+	MaxListLimit     = 200
+)
+
+type SortField string
+
+const (
+	SortIssuedAt SortField = "issued_at"
+	SortTotal    SortField = "total"
+)
+
+func (f SortField) Valid() bool {
+	return f == SortIssuedAt || f == SortTotal
+}
+
+// ListFilter narrows and orders an invoice listing. IssuedFrom and IssuedTo
+// are inclusive RFC3339 bounds; After resumes a keyset scan. Soft-deleted
+// invoices are left out unless IncludeDeleted is set.
+type ListFilter struct {
+	Status         Status
+	CustomerID     string
+	IssuedFrom     string
+	IssuedTo       string
+	Sort           SortField
+	Descending     bool
+	Limit          int
+	After          *Cursor
+	IncludeDeleted bool
+}
+
+// Cursor is the keyset position after the last row of a page: the sort key of
+// that row plus its ID as a tiebreaker. It also pins the sort it was issued
+// for so a client cannot replay it against a different ordering.
+type Cursor struct {
+	Sort       SortField `json:"s"`
+	Descending bool      `json:"d"`
+	IssuedAt   string    `json:"i,omitempty"`
+	Total      int64     `json:"t,omitempty"`
+	ID         string    `json:"id"`
+}
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// CursorAfter builds the cursor that resumes a listing after item.
+func CursorAfter(item Invoice, sort SortField, descending bool) Cursor {
+	cursor := Cursor{Sort: sort, Descending: descending, ID: item.ID}
+	switch sort {
+	case SortTotal:
+		cursor.Total = item.Total
+	default:
+		cursor.IssuedAt = item.IssuedAt
+	}
+	return cursor
+}
+
+func (c Cursor) Encode() string {
+	payload, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(payload)
+}
+
+// DecodeCursor parses an opaque cursor and checks it was issued for the
+// requested ordering.
+func DecodeCursor(value string, sort SortField, descending bool) (Cursor, error) {
+	payload, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return Cursor{}, ErrInvalidCursor
+	}
+	var cursor Cursor
+	if err := json.Unmarshal(payload, &cursor); err != nil {
+		return Cursor{}, ErrInvalidCursor
+	}
+	if !cursor.Sort.Valid() || cursor.ID == "" {
+		return Cursor{}, ErrInvalidCursor
+	}
+	if cursor.Sort != sort || cursor.Descending != descending {
+		return Cursor{}, fmt.Errorf("%w: issued for a different sort order", ErrInvalidCursor)
+	}
+	if cursor.Sort == SortTotal && cursor.IssuedAt != "" {
+		return Cursor{}, ErrInvalidCursor
+	}
+	if cursor.Sort == SortIssuedAt && cursor.Total != 0 {
+		return Cursor{}, ErrInvalidCursor
+	}
+	if _, err := parseOptionalTime(cursor.IssuedAt); err != nil {
+		return Cursor{}, ErrInvalidCursor
+	}
+	return cursor, nil
+}
+
+// Less reports whether a sorts before b under the given ordering, using the
+// invoice ID as the tiebreaker so keyset pagination is stable.
+func Less(a, b Invoice, sort SortField, descending bool) bool {
+	cmp := compareSortKey(a, b, sort)
+	if cmp == 0 {
+		cmp = compareStrings(a.ID, b.ID)
+	}
+	if descending {
+		return cmp > 0
+	}
+	return cmp < 0
+}
+
+// IsAfter reports whether item sorts strictly after the cursor position.
+func (c Cursor) IsAfter(item Invoice) bool {
+	anchor := Invoice{ID: c.ID, IssuedAt: c.IssuedAt, Total: c.Total}
+	return Less(anchor, item, c.Sort, c.Descending)
+}
+
+func compareSortKey(a, b Invoice, sort SortField) int {
+	switch sort {
+	case SortTotal:
+		switch {
+		case a.Total < b.Total:
+			return -1
+		case a.Total > b.Total:
+			return 1
+		}
+		return 0
+	default:
+		// RFC3339 UTC timestamps order lexically; unissued invoices ("")
+		// sort before every issued one.
+		return compareStrings(a.IssuedAt, b.IssuedAt)
+	}
+}
+
+func compareStrings(a, b string) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
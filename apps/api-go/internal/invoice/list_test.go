@@ -0,0 +1,55 @@
+package invoice
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	item := Invoice{ID: "inv-7", IssuedAt: "2026-06-01T00:00:00Z", Total: 500}
+	encoded := CursorAfter(item, SortIssuedAt, true).Encode()
+
+	cursor, err := DecodeCursor(encoded, SortIssuedAt, true)
+	if err != nil {
+		t.Fatalf("decode cursor: %v", err)
+	}
+	if cursor.ID != item.ID || cursor.IssuedAt != item.IssuedAt {
+		t.Fatalf("unexpected cursor: %#v", cursor)
+	}
+}
+
+func TestDecodeCursorRejectsMalformedAndMismatchedValues(t *testing.T) {
+	totalCursor := CursorAfter(Invoice{ID: "inv-1", Total: 100}, SortTotal, false).Encode()
+	cases := map[string]struct {
+		value      string
+		sort       SortField
+		descending bool
+	}{
+		"not base64":       {value: "%%%", sort: SortIssuedAt, descending: true},
+		"not json":         {value: "bm90LWpzb24", sort: SortIssuedAt, descending: true},
+		"missing id":       {value: Cursor{Sort: SortTotal}.Encode(), sort: SortTotal},
+		"different sort":   {value: totalCursor, sort: SortIssuedAt},
+		"different order":  {value: totalCursor, sort: SortTotal, descending: true},
+		"bad issued value": {value: Cursor{Sort: SortIssuedAt, ID: "x", IssuedAt: "yesterday"}.Encode(), sort: SortIssuedAt},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			if _, err := DecodeCursor(tc.value, tc.sort, tc.descending); !errors.Is(err, ErrInvalidCursor) {
+				t.Fatalf("expected ErrInvalidCursor, got %v", err)
+			}
+		})
+	}
+}
+
+func TestCursorIsAfterUsesIDTiebreaker(t *testing.T) {
+	cursor := CursorAfter(Invoice{ID: "inv-2", Total: 100}, SortTotal, false)
+	if cursor.IsAfter(Invoice{ID: "inv-1", Total: 100}) {
+		t.Fatal("expected lower ID with equal total to sort before cursor")
+	}
+	if !cursor.IsAfter(Invoice{ID: "inv-3", Total: 100}) {
+		t.Fatal("expected higher ID with equal total to sort after cursor")
+	}
+	if !cursor.IsAfter(Invoice{ID: "inv-0", Total: 101}) {
+		t.Fatal("expected larger total to sort after cursor")
+	}
+}
@@ -0,0 +1,61 @@
+package invoice
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	// MaxNumberPrefixLength keeps allocated numbers readable on a PDF.
+	MaxNumberPrefixLength = 20
+	// MaxNumberWidth is the most digits a sequence value is padded to.
+	MaxNumberWidth = 12
+)
+
+// NumberFormat renders the numbers allocated to invoices created without
+// one: Prefix, then the year and a dash when YearlyReset is set, then the
+// sequence value zero-padded to Width digits, e.g. INV-2024-000123. Each
+// tenant has its own sequence, and with YearlyReset one per year, starting
+// at 1.
+type NumberFormat struct {
+	Prefix      string
+	Width       int
+	YearlyReset bool
+}
+
+// DefaultNumberFormat numbers invoices INV-0001, INV-0002, ...
+var DefaultNumberFormat = NumberFormat{Prefix: "INV-", Width: 4}
+
+// Validate checks the format before it is used to number invoices.
+func (f NumberFormat) Validate() error {
+	if len(f.Prefix) > MaxNumberPrefixLength {
+		return fmt.Errorf("prefix must be at most %d characters", MaxNumberPrefixLength)
+	}
+	if f.Width < 1 || f.Width > MaxNumberWidth {
+		return fmt.Errorf("width must be between 1 and %d", MaxNumberWidth)
+	}
+	return nil
+}
+
+// Series is the sequence inv draws its number from: the UTC year it was
+// issued in, or created in while it has no issue date, when numbering
+// restarts yearly, and 0 otherwise.
+func (f NumberFormat) Series(inv Invoice) int {
+	if !f.YearlyReset {
+		return 0
+	}
+	for _, value := range []string{inv.IssuedAt, inv.CreatedAt} {
+		if at, err := time.Parse(time.RFC3339, value); err == nil {
+			return at.UTC().Year()
+		}
+	}
+	return time.Now().UTC().Year()
+}
+
+// Format renders the value'th number of series.
+func (f NumberFormat) Format(series int, value int64) string {
+	if f.YearlyReset {
+		return fmt.Sprintf("%s%d-%0*d", f.Prefix, series, f.Width, value)
+	}
+	return fmt.Sprintf("%s%0*d", f.Prefix, f.Width, value)
+}
@@ -0,0 +1,51 @@
+package invoice
+
+import "testing"
+
+func TestNumberFormatFormat(t *testing.T) {
+	cases := []struct {
+		format NumberFormat
+		series int
+		value  int64
+		want   string
+	}{
+		{DefaultNumberFormat, 0, 42, "INV-0042"},
+		{DefaultNumberFormat, 0, 123456, "INV-123456"},
+		{NumberFormat{Prefix: "INV-", Width: 6, YearlyReset: true}, 2024, 123, "INV-2024-000123"},
+		{NumberFormat{Width: 3}, 0, 7, "007"},
+	}
+	for _, tc := range cases {
+		if got := tc.format.Format(tc.series, tc.value); got != tc.want {
+			t.Errorf("%+v.Format(%d, %d) = %q, want %q", tc.format, tc.series, tc.value, got, tc.want)
+		}
+	}
+}
+
+func TestNumberFormatSeries(t *testing.T) {
+	yearly := NumberFormat{Prefix: "INV-", Width: 6, YearlyReset: true}
+	issued := Invoice{IssuedAt: "2024-12-31T23:30:00-02:00", CreatedAt: "2024-06-01T00:00:00Z"}
+	if got := yearly.Series(issued); got != 2025 {
+		t.Fatalf("issued series = %d, want the UTC year of issue 2025", got)
+	}
+	if got := yearly.Series(Invoice{CreatedAt: "2023-03-01T00:00:00Z"}); got != 2023 {
+		t.Fatalf("draft series = %d, want the year created 2023", got)
+	}
+	if got := DefaultNumberFormat.Series(issued); got != 0 {
+		t.Fatalf("series without yearly reset = %d, want 0", got)
+	}
+}
+
+func TestNumberFormatValidate(t *testing.T) {
+	for _, format := range []NumberFormat{
+		{Prefix: "INV-", Width: 0},
+		{Prefix: "INV-", Width: MaxNumberWidth + 1},
+		{Prefix: "INVOICES-FOR-ACME-LIMITED-", Width: 4},
+	} {
+		if err := format.Validate(); err == nil {
+			t.Errorf("%+v validated", format)
+		}
+	}
+	if err := DefaultNumberFormat.Validate(); err != nil {
+		t.Fatalf("default format: %v", err)
+	}
+}
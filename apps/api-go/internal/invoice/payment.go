@@ -0,0 +1,92 @@
+package invoice
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrNotPayable is returned when a payment is recorded against an
+	// invoice that is not open or overdue.
+	ErrNotPayable = errors.New("invoice is not payable")
+	// ErrOverpayment is returned when a payment would take the amount paid
+	// above the invoice total and overpayment was not allowed.
+	ErrOverpayment = errors.New("payment exceeds the amount due")
+)
+
+// Payment methods clients may record.
+const (
+	PaymentBankTransfer = "bank_transfer"
+	PaymentCard         = "card"
+	PaymentCash         = "cash"
+	PaymentCheck        = "check"
+	PaymentOther        = "other"
+)
+
+var paymentMethods = map[string]bool{
+	PaymentBankTransfer: true,
+	PaymentCard:         true,
+	PaymentCash:         true,
+	PaymentCheck:        true,
+	PaymentOther:        true,
+}
+
+// Payment is money received against an invoice, in the invoice currency's
+// minor units. An invoice can be settled by several payments.
+type Payment struct {
+	ID         string `json:"id"`
+	InvoiceID  string `json:"invoiceId"`
+	Amount     int64  `json:"amount"`
+	Method     string `json:"method"`
+	ReceivedAt string `json:"receivedAt"`
+	Reference  string `json:"reference,omitempty"`
+	CreatedAt  string `json:"createdAt"`
+}
+
+// Validate checks the client-supplied fields of a payment.
+func (p Payment) Validate() error {
+	if p.Amount <= 0 {
+		return &ValidationError{Field: "amount", Message: "must be positive"}
+	}
+	if !paymentMethods[p.Method] {
+		return &ValidationError{Field: "method", Message: fmt.Sprintf("must be one of %s, %s, %s, %s or %s",
+			PaymentBankTransfer, PaymentCard, PaymentCash, PaymentCheck, PaymentOther)}
+	}
+	if _, err := parseOptionalTime(p.ReceivedAt); err != nil || p.ReceivedAt == "" {
+		return &ValidationError{Field: "receivedAt", Message: "must be an RFC3339 timestamp"}
+	}
+	if len(p.Reference) > maxPaymentReferenceLength {
+		return &ValidationError{Field: "reference", Message: fmt.Sprintf("must be at most %d characters", maxPaymentReferenceLength)}
+	}
+	return nil
+}
+
+// ApplyPayment adds p to the amount paid and marks the invoice paid once
+// the total is covered, taking the payment's time and reference. It is the
+// one place the rules for recording a payment live; stores call it while
+// holding the invoice row. With allowOverpayment set a paid invoice takes
+// the payment too, as an overpayment that leaves its status and paidAt
+// alone.
+func (inv *Invoice) ApplyPayment(p Payment, allowOverpayment bool) error {
+	payable := inv.Status == StatusOpen || inv.Status == StatusOverdue
+	if !payable && (inv.Status != StatusPaid || !allowOverpayment) {
+		return ErrNotPayable
+	}
+	if inv.AmountPaid+p.Amount > inv.Total && !allowOverpayment {
+		return ErrOverpayment
+	}
+	inv.AmountPaid += p.Amount
+	if payable && inv.AmountPaid >= inv.Total {
+		inv.Status = StatusPaid
+		inv.PaidAt = p.ReceivedAt
+		if inv.PaymentReference == "" {
+			inv.PaymentReference = p.Reference
+		}
+	}
+	return nil
+}
+
+// AmountDue is what is left to pay, never below zero.
+func (inv Invoice) AmountDue() int64 {
+	return max(inv.Total-inv.AmountPaid, 0)
+}
@@ -0,0 +1,100 @@
+package invoice
+
+import (
+	"errors"
+	"testing"
+)
+
+func openInvoice(total int64) Invoice {
+	return Invoice{ID: "inv_1", Status: StatusOpen, Subtotal: total, Total: total}
+}
+
+func TestApplyPaymentSettlesInvoiceWhenTotalIsCovered(t *testing.T) {
+	item := openInvoice(1000)
+	if err := item.ApplyPayment(Payment{Amount: 400, ReceivedAt: "2026-03-01T00:00:00Z", Reference: "wire-1"}, false); err != nil {
+		t.Fatalf("first payment: %v", err)
+	}
+	if item.Status != StatusOpen || item.AmountPaid != 400 || item.AmountDue() != 600 || item.PaidAt != "" {
+		t.Fatalf("after partial payment = %+v", item)
+	}
+
+	if err := item.ApplyPayment(Payment{Amount: 600, ReceivedAt: "2026-03-05T00:00:00Z", Reference: "wire-2"}, false); err != nil {
+		t.Fatalf("second payment: %v", err)
+	}
+	if item.Status != StatusPaid || item.AmountPaid != 1000 || item.AmountDue() != 0 {
+		t.Fatalf("after full payment = %+v", item)
+	}
+	if item.PaidAt != "2026-03-05T00:00:00Z" || item.PaymentReference != "wire-2" {
+		t.Fatalf("paidAt = %q, reference = %q", item.PaidAt, item.PaymentReference)
+	}
+}
+
+func TestApplyPaymentRejectsOverpaymentUnlessAllowed(t *testing.T) {
+	item := openInvoice(1000)
+	if err := item.ApplyPayment(Payment{Amount: 1001, ReceivedAt: "2026-03-01T00:00:00Z"}, false); !errors.Is(err, ErrOverpayment) {
+		t.Fatalf("err = %v, want ErrOverpayment", err)
+	}
+	if item.AmountPaid != 0 || item.Status != StatusOpen {
+		t.Fatalf("refused payment changed invoice: %+v", item)
+	}
+
+	if err := item.ApplyPayment(Payment{Amount: 1001, ReceivedAt: "2026-03-01T00:00:00Z"}, true); err != nil {
+		t.Fatalf("allowed overpayment: %v", err)
+	}
+	if item.Status != StatusPaid || item.AmountPaid != 1001 || item.AmountDue() != 0 {
+		t.Fatalf("after overpayment = %+v", item)
+	}
+}
+
+func TestApplyPaymentRequiresOpenOrOverdueInvoice(t *testing.T) {
+	for _, status := range []Status{StatusDraft, StatusVoid} {
+		item := openInvoice(1000)
+		item.Status = status
+		if err := item.ApplyPayment(Payment{Amount: 1, ReceivedAt: "2026-03-01T00:00:00Z"}, true); !errors.Is(err, ErrNotPayable) {
+			t.Fatalf("%s: err = %v, want ErrNotPayable", status, err)
+		}
+	}
+	paid := openInvoice(1000)
+	paid.Status = StatusPaid
+	if err := paid.ApplyPayment(Payment{Amount: 1, ReceivedAt: "2026-03-01T00:00:00Z"}, false); !errors.Is(err, ErrNotPayable) {
+		t.Fatalf("paid invoice without overpayment: err = %v, want ErrNotPayable", err)
+	}
+	item := openInvoice(1000)
+	item.Status = StatusOverdue
+	if err := item.ApplyPayment(Payment{Amount: 1, ReceivedAt: "2026-03-01T00:00:00Z"}, false); err != nil {
+		t.Fatalf("overdue invoice: %v", err)
+	}
+}
+
+func TestApplyPaymentOverpaysPaidInvoiceWhenAllowed(t *testing.T) {
+	item := openInvoice(1000)
+	if err := item.ApplyPayment(Payment{Amount: 1000, ReceivedAt: "2026-03-01T00:00:00Z", Reference: "pi_1"}, true); err != nil {
+		t.Fatalf("settling payment: %v", err)
+	}
+	if err := item.ApplyPayment(Payment{Amount: 1000, ReceivedAt: "2026-03-02T00:00:00Z", Reference: "pi_2"}, true); err != nil {
+		t.Fatalf("second payment: %v", err)
+	}
+	if item.Status != StatusPaid || item.AmountPaid != 2000 || item.PaidAt != "2026-03-01T00:00:00Z" || item.PaymentReference != "pi_1" {
+		t.Fatalf("after overpaying a paid invoice = %+v", item)
+	}
+}
+
+func TestPaymentValidate(t *testing.T) {
+	valid := Payment{Amount: 100, Method: PaymentCard, ReceivedAt: "2026-03-01T00:00:00Z"}
+	if err := valid.Validate(); err != nil {
+		t.Fatalf("valid payment: %v", err)
+	}
+	cases := map[string]func(*Payment){
+		"amount":     func(p *Payment) { p.Amount = 0 },
+		"method":     func(p *Payment) { p.Method = "barter" },
+		"receivedAt": func(p *Payment) { p.ReceivedAt = "" },
+	}
+	for field, mutate := range cases {
+		p := valid
+		mutate(&p)
+		var validation *ValidationError
+		if err := p.Validate(); !errors.As(err, &validation) || validation.Field != field {
+			t.Fatalf("%s: err = %v", field, err)
+		}
+	}
+}
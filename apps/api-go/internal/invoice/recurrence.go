@@ -0,0 +1,109 @@
+package invoice
+
+import (
+	"fmt"
+	"time"
+)
+
+// MaxRecurrenceInterval bounds Recurrence.Interval: at most one run every
+// ten years, or every 520 weeks.
+const MaxRecurrenceInterval = 120
+
+// Frequency is the unit a recurrence repeats in.
+type Frequency string
+
+const (
+	FrequencyWeekly  Frequency = "weekly"
+	FrequencyMonthly Frequency = "monthly"
+)
+
+func (f Frequency) Valid() bool {
+	return f == FrequencyWeekly || f == FrequencyMonthly
+}
+
+// Recurrence is when a recurring invoice runs: every Interval weeks or
+// months from StartDate, a YYYY-MM-DD date. Runs happen at midnight UTC.
+//
+// Monthly runs fall on AnchorDay, defaulting to the start date's day. When a
+// month is shorter than the anchor, the run is clamped to its last day, and
+// later months go back to the anchor: the 31st runs on Feb 28 and then on
+// Mar 31.
+type Recurrence struct {
+	Frequency Frequency `json:"frequency"`
+	Interval  int       `json:"interval"`
+	AnchorDay int       `json:"anchorDay,omitempty"`
+	StartDate string    `json:"startDate"`
+}
+
+// Normalize fills in the defaults of an otherwise valid recurrence.
+func (r *Recurrence) Normalize() {
+	if r.Interval == 0 {
+		r.Interval = 1
+	}
+	if start, err := time.Parse(time.DateOnly, r.StartDate); err == nil && r.Frequency == FrequencyMonthly && r.AnchorDay == 0 {
+		r.AnchorDay = start.Day()
+	}
+}
+
+// Validate checks a recurrence after Normalize.
+func (r Recurrence) Validate() error {
+	if !r.Frequency.Valid() {
+		return &ValidationError{Field: "recurrence.frequency", Message: fmt.Sprintf("must be %s or %s", FrequencyWeekly, FrequencyMonthly)}
+	}
+	if r.Interval < 1 || r.Interval > MaxRecurrenceInterval {
+		return &ValidationError{Field: "recurrence.interval", Message: fmt.Sprintf("must be between 1 and %d", MaxRecurrenceInterval)}
+	}
+	switch {
+	case r.Frequency == FrequencyWeekly && r.AnchorDay != 0:
+		return &ValidationError{Field: "recurrence.anchorDay", Message: "only applies to monthly recurrences"}
+	case r.AnchorDay < 0 || r.AnchorDay > 31:
+		return &ValidationError{Field: "recurrence.anchorDay", Message: "must be between 1 and 31"}
+	}
+	if _, err := time.Parse(time.DateOnly, r.StartDate); err != nil {
+		return &ValidationError{Field: "recurrence.startDate", Message: "must be a YYYY-MM-DD date"}
+	}
+	return nil
+}
+
+// Run returns the nth run, counting from 0. Monthly runs are computed from
+// the start month rather than the previous run, so a clamped month does not
+// pull later runs off the anchor day.
+func (r Recurrence) Run(n int) time.Time {
+	start, _ := time.Parse(time.DateOnly, r.StartDate)
+	if r.Frequency == FrequencyWeekly {
+		return start.AddDate(0, 0, 7*r.Interval*n)
+	}
+	month := time.Date(start.Year(), start.Month()+time.Month(r.Interval*n), 1, 0, 0, 0, 0, time.UTC)
+	return month.AddDate(0, 0, min(r.AnchorDay, daysIn(month))-1)
+}
+
+// Next returns the first run strictly after t.
+func (r Recurrence) Next(t time.Time) time.Time {
+	start, _ := time.Parse(time.DateOnly, r.StartDate)
+	if t.Before(start) {
+		return r.Run(0)
+	}
+	// Start a period before the estimate, which a clamped or anchored run
+	// can trail, and step forward.
+	var n int
+	if r.Frequency == FrequencyWeekly {
+		n = int(t.Sub(start)/(7*24*time.Hour)) / r.Interval
+	} else {
+		n = ((t.Year()-start.Year())*12 + int(t.Month()-start.Month())) / r.Interval
+	}
+	n = max(n-1, 0)
+	for !r.Run(n).After(t) {
+		n++
+	}
+	return r.Run(n)
+}
+
+// Period names the billing period of a run, its YYYY-MM-DD date. A
+// recurring invoice bills each period once.
+func Period(run time.Time) string {
+	return run.UTC().Format(time.DateOnly)
+}
+
+func daysIn(month time.Time) int {
+	return time.Date(month.Year(), month.Month()+1, 0, 0, 0, 0, 0, time.UTC).Day()
+}
@@ -0,0 +1,88 @@
+package invoice
+
+import (
+	"testing"
+	"time"
+)
+
+func dates(r Recurrence, n int) []string {
+	runs := make([]string, n)
+	for i := range runs {
+		runs[i] = Period(r.Run(i))
+	}
+	return runs
+}
+
+func TestMonthlyRecurrenceClampsToMonthEnd(t *testing.T) {
+	r := Recurrence{Frequency: FrequencyMonthly, StartDate: "2027-01-31"}
+	r.Normalize()
+	if err := r.Validate(); err != nil {
+		t.Fatalf("validate: %v", err)
+	}
+	want := []string{"2027-01-31", "2027-02-28", "2027-03-31", "2027-04-30"}
+	for i, got := range dates(r, len(want)) {
+		if got != want[i] {
+			t.Fatalf("runs = %v, want %v", dates(r, len(want)), want)
+		}
+	}
+
+	leap := Recurrence{Frequency: FrequencyMonthly, Interval: 12, AnchorDay: 29, StartDate: "2028-02-29"}
+	if got := dates(leap, 2); got[0] != "2028-02-29" || got[1] != "2029-02-28" {
+		t.Fatalf("leap year runs = %v", got)
+	}
+}
+
+func TestMonthlyRecurrenceUsesAnchorDay(t *testing.T) {
+	r := Recurrence{Frequency: FrequencyMonthly, Interval: 2, AnchorDay: 30, StartDate: "2026-12-15"}
+	want := []string{"2026-12-30", "2027-02-28", "2027-04-30"}
+	for i, got := range dates(r, len(want)) {
+		if got != want[i] {
+			t.Fatalf("runs = %v, want %v", dates(r, len(want)), want)
+		}
+	}
+}
+
+func TestRecurrenceNextIsStrictlyAfter(t *testing.T) {
+	weekly := Recurrence{Frequency: FrequencyWeekly, Interval: 2, StartDate: "2026-03-02"}
+	weekly.Normalize()
+	monthly := Recurrence{Frequency: FrequencyMonthly, StartDate: "2026-01-31"}
+	monthly.Normalize()
+
+	cases := []struct {
+		r    Recurrence
+		t    string
+		want string
+	}{
+		{weekly, "2026-01-01T00:00:00Z", "2026-03-02"},
+		{weekly, "2026-03-02T00:00:00Z", "2026-03-16"},
+		{weekly, "2026-03-20T12:00:00Z", "2026-03-30"},
+		{monthly, "2026-02-27T23:59:59Z", "2026-02-28"},
+		{monthly, "2026-02-28T00:00:00Z", "2026-03-31"},
+		{monthly, "2027-06-15T00:00:00Z", "2027-06-30"},
+	}
+	for _, tc := range cases {
+		after, _ := time.Parse(time.RFC3339, tc.t)
+		if got := Period(tc.r.Next(after)); got != tc.want {
+			t.Fatalf("%s Next(%s) = %s, want %s", tc.r.Frequency, tc.t, got, tc.want)
+		}
+	}
+}
+
+func TestRecurrenceValidate(t *testing.T) {
+	cases := map[string]Recurrence{
+		"recurrence.frequency": {Frequency: "daily", Interval: 1, StartDate: "2026-01-01"},
+		"recurrence.interval":  {Frequency: FrequencyWeekly, Interval: MaxRecurrenceInterval + 1, StartDate: "2026-01-01"},
+		"recurrence.anchorDay": {Frequency: FrequencyWeekly, Interval: 1, AnchorDay: 3, StartDate: "2026-01-01"},
+		"recurrence.startDate": {Frequency: FrequencyMonthly, Interval: 1, AnchorDay: 1, StartDate: "01/02/2026"},
+	}
+	for field, r := range cases {
+		err := r.Validate()
+		validationErr, ok := err.(*ValidationError)
+		if !ok || validationErr.Field != field {
+			t.Fatalf("%+v: err = %v, want a %s error", r, err, field)
+		}
+	}
+	if err := (Recurrence{Frequency: FrequencyMonthly, Interval: 1, AnchorDay: 32, StartDate: "2026-01-01"}).Validate(); err == nil {
+		t.Fatal("expected anchor day 32 to be rejected")
+	}
+}
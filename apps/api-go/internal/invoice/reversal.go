@@ -0,0 +1,127 @@
+package invoice
+
+import (
+	"errors"
+	"fmt"
+	"math/bits"
+	"strings"
+)
+
+const maxReversalReasonLength = 500
+
+var (
+	// ErrAlreadyVoid is returned when a void invoice is voided or changed.
+	ErrAlreadyVoid = errors.New("invoice is void")
+	// ErrPaymentsReceived is returned when an invoice that has taken money
+	// is voided; it has to be reversed with a credit note instead.
+	ErrPaymentsReceived = errors.New("invoice has payments")
+	// ErrNotCreditable is returned when a credit note is issued against an
+	// invoice with nothing left to credit.
+	ErrNotCreditable = errors.New("invoice has nothing to credit")
+	// ErrCreditExceeded is returned when a credit note would credit more
+	// than the invoice has left to credit.
+	ErrCreditExceeded = errors.New("credit exceeds the amount that can be credited")
+)
+
+// CreditNote reverses money received against an invoice. Its amounts are
+// negative, in the invoice's currency, and its number comes from the
+// tenant's credit note sequence rather than the invoice numbers.
+type CreditNote struct {
+	ID        string `json:"id"`
+	TenantID  string `json:"tenantId"`
+	InvoiceID string `json:"invoiceId"`
+	Number    string `json:"number"`
+	Currency  string `json:"currency"`
+	Subtotal  int64  `json:"subtotal"`
+	Tax       int64  `json:"tax"`
+	Total     int64  `json:"total"`
+	Reason    string `json:"reason,omitempty"`
+	IssuedAt  string `json:"issuedAt"`
+	CreatedAt string `json:"createdAt"`
+}
+
+// CreditNoteNumber formats the nth credit note of a tenant.
+func CreditNoteNumber(n int64) string {
+	return fmt.Sprintf("CN-%04d", n)
+}
+
+// ValidateReason checks the reason given for a void or a credit note.
+func ValidateReason(reason string, required bool) error {
+	if required && strings.TrimSpace(reason) == "" {
+		return &ValidationError{Field: "reason", Message: "is required"}
+	}
+	if len(reason) > maxReversalReasonLength {
+		return &ValidationError{Field: "reason", Message: fmt.Sprintf("must be at most %d characters", maxReversalReasonLength)}
+	}
+	return nil
+}
+
+// Void marks the invoice void at voidedAt. The invoice keeps its number,
+// amounts and lines. Only invoices that have not taken any money can be
+// voided; stores call it while holding the invoice row.
+func (inv *Invoice) Void(reason, voidedAt string) error {
+	if inv.Status == StatusVoid {
+		return ErrAlreadyVoid
+	}
+	if inv.amountReceived() > 0 {
+		return ErrPaymentsReceived
+	}
+	inv.Status = StatusVoid
+	inv.VoidedAt = voidedAt
+	inv.VoidReason = reason
+	return nil
+}
+
+// Creditable is how much of the money received can still be credited:
+// what was paid, up to the total, less the credit notes already issued.
+func (inv Invoice) Creditable() int64 {
+	return max(min(inv.amountReceived(), inv.Total)-inv.AmountCredited, 0)
+}
+
+// ApplyCreditNote credits amount, or everything creditable when amount is
+// zero, and fills in the note's negative amounts with the invoice's tax in
+// proportion. Stores call it while holding the invoice row, so concurrent
+// credit notes each see the others.
+func (inv *Invoice) ApplyCreditNote(note *CreditNote, amount int64) error {
+	creditable := inv.Creditable()
+	if inv.Status == StatusVoid || creditable == 0 {
+		return ErrNotCreditable
+	}
+	if amount == 0 {
+		amount = creditable
+	}
+	if amount > creditable {
+		return ErrCreditExceeded
+	}
+	tax := inv.Tax
+	if amount < inv.Total {
+		tax = roundedShare(inv.Tax, amount, inv.Total)
+	}
+	note.Currency = inv.Currency
+	note.Subtotal = -(amount - tax)
+	note.Tax = -tax
+	note.Total = -amount
+	inv.AmountCredited += amount
+	return nil
+}
+
+// amountReceived is what the customer paid. Invoices created as paid have
+// no recorded payments and count as paid in full.
+func (inv Invoice) amountReceived() int64 {
+	if inv.Status == StatusPaid {
+		return max(inv.AmountPaid, inv.Total)
+	}
+	return inv.AmountPaid
+}
+
+// roundedShare is value * part / whole rounded half up, for non-negative
+// operands with part < whole. The product is taken in 128 bits so large
+// totals do not overflow.
+func roundedShare(value, part, whole int64) int64 {
+	hi, lo := bits.Mul64(uint64(value), uint64(part))
+	quotient, remainder := bits.Div64(hi, lo, uint64(whole))
+	if remainder >= uint64(whole)-remainder {
+		quotient++
+	}
+	return int64(quotient)
+}
@@ -0,0 +1,96 @@
+package invoice
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestVoidRefusesInvoicesThatTookMoney(t *testing.T) {
+	item := openInvoice(1000)
+	if err := item.Void("Issued in error", "2026-03-01T00:00:00Z"); err != nil {
+		t.Fatalf("void: %v", err)
+	}
+	if item.Status != StatusVoid || item.VoidedAt != "2026-03-01T00:00:00Z" || item.VoidReason != "Issued in error" {
+		t.Fatalf("after void = %+v", item)
+	}
+	if err := item.Void("again", "2026-03-02T00:00:00Z"); !errors.Is(err, ErrAlreadyVoid) {
+		t.Fatalf("err = %v, want ErrAlreadyVoid", err)
+	}
+
+	partial := openInvoice(1000)
+	partial.AmountPaid = 1
+	paid := Invoice{Status: StatusPaid, Total: 1000, PaidAt: "2026-03-01T00:00:00Z"}
+	for _, item := range []Invoice{partial, paid} {
+		if err := item.Void("", "2026-03-01T00:00:00Z"); !errors.Is(err, ErrPaymentsReceived) {
+			t.Fatalf("%s invoice: err = %v, want ErrPaymentsReceived", item.Status, err)
+		}
+	}
+}
+
+func TestApplyCreditNoteSplitsTaxAndTracksCredit(t *testing.T) {
+	item := Invoice{Status: StatusPaid, Currency: "ILS", Subtotal: 10000, Tax: 1700, Total: 11700, AmountPaid: 11700}
+
+	var first CreditNote
+	if err := item.ApplyCreditNote(&first, 5850); err != nil {
+		t.Fatalf("partial credit: %v", err)
+	}
+	if first.Total != -5850 || first.Tax != -850 || first.Subtotal != -5000 || first.Currency != "ILS" || item.AmountCredited != 5850 {
+		t.Fatalf("partial credit note = %+v, credited %d", first, item.AmountCredited)
+	}
+	var over CreditNote
+	if err := item.ApplyCreditNote(&over, 5851); !errors.Is(err, ErrCreditExceeded) {
+		t.Fatalf("err = %v, want ErrCreditExceeded", err)
+	}
+	var rest CreditNote
+	if err := item.ApplyCreditNote(&rest, 0); err != nil || rest.Total != -5850 || item.Creditable() != 0 {
+		t.Fatalf("crediting the rest = %+v, %v, creditable %d", rest, err, item.Creditable())
+	}
+	if err := item.ApplyCreditNote(&CreditNote{}, 0); !errors.Is(err, ErrNotCreditable) {
+		t.Fatalf("err = %v, want ErrNotCreditable", err)
+	}
+}
+
+func TestCreditableIsLimitedToMoneyReceived(t *testing.T) {
+	cases := []struct {
+		name string
+		item Invoice
+		want int64
+	}{
+		{"unpaid", openInvoice(1000), 0},
+		{"partially paid", Invoice{Status: StatusOpen, Total: 1000, AmountPaid: 400}, 400},
+		{"overpaid", Invoice{Status: StatusPaid, Total: 1000, AmountPaid: 1200}, 1000},
+		{"created as paid", Invoice{Status: StatusPaid, Total: 1000}, 1000},
+		{"partly credited", Invoice{Status: StatusPaid, Total: 1000, AmountPaid: 1000, AmountCredited: 300}, 700},
+	}
+	for _, tc := range cases {
+		if got := tc.item.Creditable(); got != tc.want {
+			t.Errorf("%s: Creditable = %d, want %d", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestRoundedShareDoesNotOverflow(t *testing.T) {
+	if got := roundedShare(math.MaxInt64/2, math.MaxInt64/4, math.MaxInt64/2); got != math.MaxInt64/4 {
+		t.Fatalf("roundedShare = %d", got)
+	}
+	if got := roundedShare(3, 1, 2); got != 2 {
+		t.Fatalf("expected halves to round up, got %d", got)
+	}
+}
+
+func TestValidateReason(t *testing.T) {
+	if err := ValidateReason(" ", true); err == nil {
+		t.Fatal("expected a blank required reason to be rejected")
+	}
+	if err := ValidateReason("", false); err != nil {
+		t.Fatalf("expected an optional reason to be accepted, got %v", err)
+	}
+	long := make([]byte, maxReversalReasonLength+1)
+	for i := range long {
+		long[i] = 'x'
+	}
+	if err := ValidateReason(string(long), false); err == nil {
+		t.Fatal("expected an overlong reason to be rejected")
+	}
+}
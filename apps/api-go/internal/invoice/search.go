@@ -0,0 +1,139 @@
+package invoice
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	// MaxSearchLength bounds the query text, in characters.
+	MaxSearchLength = 200
+	// MinFullTextLength is the shortest query matched by full-text search.
+	// Shorter queries are mostly fragments of a word or number, which
+	// full-text search only matches whole, so they use substring matching.
+	MinFullTextLength = 4
+)
+
+// Fields a search can match, as reported in SearchMatch.Field.
+const (
+	SearchFieldNumber       = "number"
+	SearchFieldCustomerID   = "customerId"
+	SearchFieldCustomerName = "customerName"
+	SearchFieldItem         = "items.description"
+)
+
+// SearchMode is how query text is matched against invoices.
+type SearchMode string
+
+const (
+	// SearchFullText matches whole words, every word of the query in the
+	// same field.
+	SearchFullText SearchMode = "fulltext"
+	// SearchSubstring matches the query anywhere in a field, ignoring case.
+	SearchSubstring SearchMode = "substring"
+)
+
+func (m SearchMode) Valid() bool {
+	return m == SearchFullText || m == SearchSubstring
+}
+
+// SearchModeFor picks the mode for query text by its length.
+func SearchModeFor(text string) SearchMode {
+	if utf8.RuneCountInString(text) < MinFullTextLength {
+		return SearchSubstring
+	}
+	return SearchFullText
+}
+
+// SearchQuery is a ranked invoice search. Results are paged by offset:
+// there is no stored sort key to resume from, since ranks are computed per
+// query.
+type SearchQuery struct {
+	Text   string
+	Mode   SearchMode
+	Limit  int
+	Offset int
+}
+
+// Matches reports whether value matches the query in its mode. Stores that
+// cannot push matching down to the database use it.
+func (q SearchQuery) Matches(value string) bool {
+	if value == "" {
+		return false
+	}
+	if q.Mode == SearchSubstring {
+		return strings.Contains(strings.ToLower(value), strings.ToLower(q.Text))
+	}
+	words := searchWords(q.Text)
+	if len(words) == 0 {
+		return false
+	}
+	have := make(map[string]bool)
+	for _, word := range searchWords(value) {
+		have[word] = true
+	}
+	for _, word := range words {
+		if !have[word] {
+			return false
+		}
+	}
+	return true
+}
+
+func searchWords(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// SearchMatch is a field a result matched on and the matched text, so
+// clients can highlight it.
+type SearchMatch struct {
+	Field string `json:"field"`
+	Value string `json:"value"`
+}
+
+// SearchResult is an invoice found by a search. Rank orders results, higher
+// first; it is only comparable within one search.
+type SearchResult struct {
+	Invoice Invoice       `json:"invoice"`
+	Rank    float64       `json:"rank"`
+	Matches []SearchMatch `json:"matches"`
+}
+
+// SearchCursor resumes a search at Offset. It pins the query text and mode
+// so a later page is not taken from a different search.
+type SearchCursor struct {
+	Text   string     `json:"q"`
+	Mode   SearchMode `json:"m"`
+	Offset int        `json:"o"`
+}
+
+func (c SearchCursor) Encode() string {
+	payload, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(payload)
+}
+
+// DecodeSearchCursor parses an opaque search cursor and checks it was issued
+// for text.
+func DecodeSearchCursor(value, text string) (SearchCursor, error) {
+	payload, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return SearchCursor{}, ErrInvalidCursor
+	}
+	var cursor SearchCursor
+	if err := json.Unmarshal(payload, &cursor); err != nil {
+		return SearchCursor{}, ErrInvalidCursor
+	}
+	if !cursor.Mode.Valid() || cursor.Offset <= 0 {
+		return SearchCursor{}, ErrInvalidCursor
+	}
+	if cursor.Text != text {
+		return SearchCursor{}, fmt.Errorf("%w: issued for a different query", ErrInvalidCursor)
+	}
+	return cursor, nil
+}
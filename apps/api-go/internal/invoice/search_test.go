@@ -0,0 +1,54 @@
+package invoice
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestSearchModeForShortQueriesIsSubstring(t *testing.T) {
+	for text, want := range map[string]SearchMode{
+		"ac":        SearchSubstring,
+		"001":       SearchSubstring,
+		"אבג":       SearchSubstring,
+		"acme":      SearchFullText,
+		"acme corp": SearchFullText,
+	} {
+		if got := SearchModeFor(text); got != want {
+			t.Errorf("SearchModeFor(%q) = %s, want %s", text, got, want)
+		}
+	}
+}
+
+func TestSearchQueryMatches(t *testing.T) {
+	fullText := SearchQuery{Text: "Acme widgets", Mode: SearchFullText}
+	if !fullText.Matches("Widgets for ACME, Ltd") {
+		t.Fatal("expected every word to match regardless of case and order")
+	}
+	if fullText.Matches("Acme widget") {
+		t.Fatal("expected full text to match whole words only")
+	}
+
+	substring := SearchQuery{Text: "v-00", Mode: SearchSubstring}
+	if !substring.Matches("INV-0001") || substring.Matches("INV-1000") {
+		t.Fatal("expected substring match on the raw text")
+	}
+	if substring.Matches("") {
+		t.Fatal("expected empty values never to match")
+	}
+}
+
+func TestSearchCursorRoundTripAndPinsQuery(t *testing.T) {
+	encoded := SearchCursor{Text: "acme", Mode: SearchFullText, Offset: 50}.Encode()
+	cursor, err := DecodeSearchCursor(encoded, "acme")
+	if err != nil || cursor.Offset != 50 || cursor.Mode != SearchFullText {
+		t.Fatalf("unexpected cursor %#v, %v", cursor, err)
+	}
+	if _, err := DecodeSearchCursor(encoded, "globex"); !errors.Is(err, ErrInvalidCursor) {
+		t.Fatalf("expected a cursor for another query to be rejected, got %v", err)
+	}
+	for _, value := range []string{"%%%", SearchCursor{Text: "acme", Mode: "fuzzy", Offset: 1}.Encode(), SearchCursor{Text: "acme", Mode: SearchFullText}.Encode()} {
+		if _, err := DecodeSearchCursor(value, "acme"); !errors.Is(err, ErrInvalidCursor) {
+			t.Errorf("DecodeSearchCursor(%q) = %v, want ErrInvalidCursor", value, err)
+		}
+	}
+}
@@ -0,0 +1,32 @@
+package invoice
+
+// transitions is the invoice lifecycle. Drafts are issued as open; open
+// invoices are paid or fall overdue, and overdue ones can still be paid.
+// Invoices that are not paid can be voided; paid ones are reversed with a
+// credit note instead. Void is terminal.
+var transitions = map[Status][]Status{
+	StatusDraft:   {StatusOpen, StatusVoid},
+	StatusOpen:    {StatusPaid, StatusOverdue, StatusVoid},
+	StatusOverdue: {StatusPaid, StatusVoid},
+	StatusPaid:    {},
+	StatusVoid:    {},
+}
+
+// CanTransitionTo reports whether an invoice in status s may move to next.
+// Staying in the same status is not a transition and is always allowed.
+func (s Status) CanTransitionTo(next Status) bool {
+	if s == next {
+		return s.Valid()
+	}
+	for _, allowed := range transitions[s] {
+		if allowed == next {
+			return true
+		}
+	}
+	return false
+}
+
+// NextStatuses lists the statuses s may move to, in lifecycle order.
+func (s Status) NextStatuses() []Status {
+	return append([]Status{}, transitions[s]...)
+}
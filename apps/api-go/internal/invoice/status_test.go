@@ -0,0 +1,48 @@
+package invoice
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestStatusTransitions(t *testing.T) {
+	legal := map[Status][]Status{
+		StatusDraft:   {StatusOpen, StatusVoid},
+		StatusOpen:    {StatusPaid, StatusOverdue, StatusVoid},
+		StatusOverdue: {StatusPaid, StatusVoid},
+		StatusPaid:    nil,
+		StatusVoid:    nil,
+	}
+	all := []Status{StatusDraft, StatusOpen, StatusPaid, StatusOverdue, StatusVoid}
+	for _, from := range all {
+		for _, to := range all {
+			want := from == to || slices.Contains(legal[from], to)
+			if got := from.CanTransitionTo(to); got != want {
+				t.Errorf("%s -> %s: CanTransitionTo = %v, want %v", from, to, got, want)
+			}
+		}
+		if got := from.NextStatuses(); !slices.Equal(got, legal[from]) {
+			t.Errorf("%s: NextStatuses = %v, want %v", from, got, legal[from])
+		}
+	}
+}
+
+func TestStatusTransitionsRejectUnknownStatuses(t *testing.T) {
+	if Status("sent").CanTransitionTo("sent") {
+		t.Fatal("expected an unknown status not to transition to itself")
+	}
+	if StatusDraft.CanTransitionTo("sent") || Status("sent").CanTransitionTo(StatusOpen) {
+		t.Fatal("expected transitions involving unknown statuses to be rejected")
+	}
+	if next := Status("sent").NextStatuses(); len(next) != 0 {
+		t.Fatalf("expected no next statuses, got %v", next)
+	}
+}
+
+func TestNextStatusesReturnsACopy(t *testing.T) {
+	next := StatusOpen.NextStatuses()
+	next[0] = StatusDraft
+	if StatusOpen.NextStatuses()[0] != StatusPaid {
+		t.Fatal("expected callers not to be able to change the lifecycle")
+	}
+}
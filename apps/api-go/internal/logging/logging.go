@@ -0,0 +1,76 @@
+// Package logging builds the service's JSON slog logger and carries the
+// request ID through contexts, so every line logged with a request's context
+// can be correlated without threading the ID by hand.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+)
+
+// RequestIDKey is the attribute name used for the correlation ID.
+const RequestIDKey = "request_id"
+
+type contextKey struct{}
+
+// WithRequestID returns a copy of ctx that tags log records with id.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, contextKey{}, id)
+}
+
+// RequestID returns the request ID stored in ctx, or "".
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(contextKey{}).(string)
+	return id
+}
+
+// ParseLevel reads a LOG_LEVEL value: debug, info, warn or error. Empty
+// means info.
+func ParseLevel(value string) (slog.Level, error) {
+	var level slog.Level
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return slog.LevelInfo, nil
+	}
+	if err := level.UnmarshalText([]byte(value)); err != nil {
+		return slog.LevelInfo, fmt.Errorf("LOG_LEVEL must be one of debug, info, warn, error: %q", value)
+	}
+	return level, nil
+}
+
+// New returns a JSON logger writing to w that adds request_id to records
+// logged with a context from WithRequestID.
+func New(w io.Writer, level slog.Leveler) *slog.Logger {
+	return slog.New(NewContextHandler(slog.NewJSONHandler(w, &slog.HandlerOptions{Level: level})))
+}
+
+// NewContextHandler wraps next so records pick up the context's request ID.
+func NewContextHandler(next slog.Handler) slog.Handler {
+	return contextHandler{next: next}
+}
+
+type contextHandler struct {
+	next slog.Handler
+}
+
+func (h contextHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+func (h contextHandler) Handle(ctx context.Context, record slog.Record) error {
+	if id := RequestID(ctx); id != "" {
+		record.AddAttrs(slog.String(RequestIDKey, id))
+	}
+	return h.next.Handle(ctx, record)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{next: h.next.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{next: h.next.WithGroup(name)}
+}
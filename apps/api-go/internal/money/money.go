@@ -0,0 +1,99 @@
+// Package money formats and parses amounts held in integer minor units. The
+// number of decimal places comes from the ISO 4217 minor unit of the
+// currency, so 1500 JPY is "1500" while 1500 USD is "15.00".
+package money
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Money is an amount in the currency's minor units.
+type Money struct {
+	Amount   int64
+	Currency string
+}
+
+// scales lists the ISO 4217 currencies whose minor unit is not a hundredth.
+var scales = map[string]int{
+	"BIF": 0, "CLP": 0, "DJF": 0, "GNF": 0, "ISK": 0, "JPY": 0, "KMF": 0, "KRW": 0,
+	"PYG": 0, "RWF": 0, "UGX": 0, "VND": 0, "VUV": 0, "XAF": 0, "XOF": 0, "XPF": 0,
+	"BHD": 3, "IQD": 3, "JOD": 3, "KWD": 3, "LYD": 3, "OMR": 3, "TND": 3,
+}
+
+var ErrInvalidAmount = errors.New("invalid amount")
+
+// Scale returns the number of decimal places for an upper-case currency
+// code. Codes not listed use two.
+func Scale(currency string) int {
+	if scale, ok := scales[currency]; ok {
+		return scale
+	}
+	return 2
+}
+
+// Format renders m for display, e.g. "ILS 117.00" or "-JPY 1500".
+func (m Money) Format() string {
+	if decimal, negative := strings.CutPrefix(m.Decimal(), "-"); negative {
+		return "-" + m.Currency + " " + decimal
+	}
+	return m.Currency + " " + m.Decimal()
+}
+
+// Decimal renders m as a plain decimal string in the currency's scale, e.g.
+// "117.00", for machine-readable output such as CSV.
+func (m Money) Decimal() string {
+	scale := Scale(m.Currency)
+	sign := ""
+	// Work in uint64 so math.MinInt64 does not overflow when negated.
+	minor := uint64(m.Amount)
+	if m.Amount < 0 {
+		sign = "-"
+		minor = -minor
+	}
+	if scale == 0 {
+		return sign + strconv.FormatUint(minor, 10)
+	}
+	unit := uint64(1)
+	for range scale {
+		unit *= 10
+	}
+	return fmt.Sprintf("%s%d.%0*d", sign, minor/unit, scale, minor%unit)
+}
+
+// ParseDecimal reads a decimal string such as "117.5" into minor units of
+// currency. It rejects more decimal places than the currency has, signs
+// other than a leading minus, separators and exponents.
+func ParseDecimal(value, currency string) (Money, error) {
+	scale := Scale(currency)
+	value = strings.TrimSpace(value)
+	negative := strings.HasPrefix(value, "-")
+	digits := strings.TrimPrefix(value, "-")
+	whole, frac, hasPoint := strings.Cut(digits, ".")
+	if whole == "" || !allDigits(whole) || !allDigits(frac) || (hasPoint && frac == "") {
+		return Money{}, fmt.Errorf("%w: %q is not a decimal number", ErrInvalidAmount, value)
+	}
+	if len(frac) > scale {
+		return Money{}, fmt.Errorf("%w: %s allows at most %d decimal places, got %q", ErrInvalidAmount, currency, scale, value)
+	}
+	frac += strings.Repeat("0", scale-len(frac))
+	amount, err := strconv.ParseInt(whole+frac, 10, 64)
+	if err != nil {
+		return Money{}, fmt.Errorf("%w: %q is out of range", ErrInvalidAmount, value)
+	}
+	if negative {
+		amount = -amount
+	}
+	return Money{Amount: amount, Currency: currency}, nil
+}
+
+func allDigits(value string) bool {
+	for _, r := range value {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
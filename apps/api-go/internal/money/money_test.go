@@ -0,0 +1,100 @@
+package money
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestDecimalAndFormatUseCurrencyScale(t *testing.T) {
+	cases := []struct {
+		money   Money
+		decimal string
+		display string
+	}{
+		{Money{11700, "ILS"}, "117.00", "ILS 117.00"},
+		{Money{5, "USD"}, "0.05", "USD 0.05"},
+		{Money{-250, "EUR"}, "-2.50", "-EUR 2.50"},
+		{Money{1500, "JPY"}, "1500", "JPY 1500"},
+		{Money{-7, "KRW"}, "-7", "-KRW 7"},
+		{Money{1, "KWD"}, "0.001", "KWD 0.001"},
+		{Money{12345, "BHD"}, "12.345", "BHD 12.345"},
+		{Money{math.MinInt64, "USD"}, "-92233720368547758.08", "-USD 92233720368547758.08"},
+	}
+	for _, tc := range cases {
+		if got := tc.money.Decimal(); got != tc.decimal {
+			t.Errorf("%v.Decimal() = %q, want %q", tc.money, got, tc.decimal)
+		}
+		if got := tc.money.Format(); got != tc.display {
+			t.Errorf("%v.Format() = %q, want %q", tc.money, got, tc.display)
+		}
+	}
+}
+
+func TestParseDecimal(t *testing.T) {
+	cases := []struct {
+		value    string
+		currency string
+		want     int64
+	}{
+		{"117", "ILS", 11700},
+		{"117.5", "ILS", 11750},
+		{" 0.05 ", "USD", 5},
+		{"-2.50", "EUR", -250},
+		{"1500", "JPY", 1500},
+		{"12.345", "BHD", 12345},
+		{"0.1", "KWD", 100},
+	}
+	for _, tc := range cases {
+		got, err := ParseDecimal(tc.value, tc.currency)
+		if err != nil || got != (Money{tc.want, tc.currency}) {
+			t.Errorf("ParseDecimal(%q, %s) = %v, %v, want %d", tc.value, tc.currency, got, err, tc.want)
+		}
+	}
+}
+
+func TestParseDecimalRejectsMalformedAmounts(t *testing.T) {
+	cases := []struct {
+		value    string
+		currency string
+	}{
+		{"12.5", "JPY"},
+		{"12.", "JPY"},
+		{"1.234", "USD"},
+		{"1.2345", "BHD"},
+		{"", "USD"},
+		{".50", "USD"},
+		{"+1.00", "USD"},
+		{"1,000.00", "USD"},
+		{"1e3", "USD"},
+		{"--1", "USD"},
+		{"99999999999999999999", "USD"},
+	}
+	for _, tc := range cases {
+		if got, err := ParseDecimal(tc.value, tc.currency); !errors.Is(err, ErrInvalidAmount) {
+			t.Errorf("ParseDecimal(%q, %s) = %v, %v, want ErrInvalidAmount", tc.value, tc.currency, got, err)
+		}
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	for _, m := range []Money{{0, "JPY"}, {123456789, "USD"}, {-1, "BHD"}, {42, "CLP"}} {
+		got, err := ParseDecimal(m.Decimal(), m.Currency)
+		if err != nil || got != m {
+			t.Errorf("round trip of %v gave %v, %v", m, got, err)
+		}
+	}
+}
+
+func TestIsCurrency(t *testing.T) {
+	for _, code := range []string{"ILS", "USD", "EUR", "JPY", "KWD"} {
+		if !IsCurrency(code) {
+			t.Errorf("expected %s to be a currency", code)
+		}
+	}
+	for _, code := range []string{"", "usd", "XAU", "XXX", "ABC", "USDT"} {
+		if IsCurrency(code) {
+			t.Errorf("expected %q to be rejected", code)
+		}
+	}
+}
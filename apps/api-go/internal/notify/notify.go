@@ -0,0 +1,132 @@
+// Package notify sends plain-text email to customers. It knows nothing about
+// invoices; the API server decides what to send and when.
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"mime"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+// Email is one message to a single recipient.
+type Email struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// EmailSender delivers email. Send returns once the message has been handed
+// to the mail server, not once it reaches the inbox.
+type EmailSender interface {
+	Send(ctx context.Context, email Email) error
+}
+
+// NoopSender discards every message. It is the default when no mail server
+// is configured, and what tests use when they do not inspect the mail.
+type NoopSender struct{}
+
+func (NoopSender) Send(context.Context, Email) error {
+	return nil
+}
+
+// SMTPSender sends email through a mail server, upgrading to TLS when the
+// server offers STARTTLS.
+type SMTPSender struct {
+	Addr string
+	From string
+	// Auth is nil for servers that accept mail without logging in.
+	Auth smtp.Auth
+}
+
+// NewSMTPSender returns a sender for the server at addr (host:port). It logs
+// in with PLAIN auth when username is set.
+func NewSMTPSender(addr, from, username, password string) *SMTPSender {
+	sender := &SMTPSender{Addr: addr, From: from}
+	if username != "" {
+		host, _, _ := net.SplitHostPort(addr)
+		sender.Auth = smtp.PlainAuth("", username, password, host)
+	}
+	return sender
+}
+
+func (s *SMTPSender) Send(ctx context.Context, email Email) error {
+	msg, err := s.message(email, time.Now())
+	if err != nil {
+		return err
+	}
+	host, _, err := net.SplitHostPort(s.Addr)
+	if err != nil {
+		return fmt.Errorf("smtp address %q: %w", s.Addr, err)
+	}
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", s.Addr)
+	if err != nil {
+		return err
+	}
+	// net/smtp has no context support, so the deadline bounds the exchange.
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+	client, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return err
+		}
+	}
+	if s.Auth != nil {
+		if err := client.Auth(s.Auth); err != nil {
+			return err
+		}
+	}
+	if err := client.Mail(s.From); err != nil {
+		return err
+	}
+	if err := client.Rcpt(email.To); err != nil {
+		return err
+	}
+	body, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := body.Write(msg); err != nil {
+		return err
+	}
+	if err := body.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+// message renders email as an RFC 5322 message with CRLF line endings.
+func (s *SMTPSender) message(email Email, now time.Time) ([]byte, error) {
+	if _, err := mail.ParseAddress(email.To); err != nil {
+		return nil, fmt.Errorf("recipient %q: %w", email.To, err)
+	}
+	if strings.ContainsAny(email.To+email.Subject, "\r\n") {
+		return nil, errors.New("email headers must not contain line breaks")
+	}
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", s.From)
+	fmt.Fprintf(&buf, "To: %s\r\n", email.To)
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", email.Subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", now.Format(time.RFC1123Z))
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	buf.WriteString("Content-Transfer-Encoding: 8bit\r\n\r\n")
+	body := strings.ReplaceAll(email.Body, "\r\n", "\n")
+	buf.WriteString(strings.ReplaceAll(body, "\n", "\r\n"))
+	return buf.Bytes(), nil
+}
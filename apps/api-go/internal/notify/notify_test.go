@@ -0,0 +1,86 @@
+package notify
+
+import (
+	"context"
+	"net"
+	"net/textproto"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSMTPSenderDeliversMessage(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer listener.Close()
+	received := make(chan []string, 1)
+	go serveOneSMTPSession(listener, received)
+
+	sender := NewSMTPSender(listener.Addr().String(), "billing@example.com", "", "")
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	err = sender.Send(ctx, Email{To: "ap@acme.test", Subject: "Reminder: INV-0001", Body: "Amount due: ILS 117.00\nThanks"})
+	if err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	lines := <-received
+	transcript := strings.Join(lines, "\n")
+	for _, want := range []string{"MAIL FROM:<billing@example.com>", "RCPT TO:<ap@acme.test>", "To: ap@acme.test", "Subject: Reminder: INV-0001", "Amount due: ILS 117.00"} {
+		if !strings.Contains(transcript, want) {
+			t.Fatalf("expected the session to contain %q, got:\n%s", want, transcript)
+		}
+	}
+}
+
+func TestSMTPSenderRejectsHeaderInjection(t *testing.T) {
+	sender := &SMTPSender{Addr: "127.0.0.1:1", From: "billing@example.com"}
+	if _, err := sender.message(Email{To: "ap@acme.test", Subject: "Hi\r\nBcc: victim@example.com"}, time.Now()); err == nil {
+		t.Fatal("expected a subject with a line break to be refused")
+	}
+	if _, err := sender.message(Email{To: "not an address", Subject: "Hi"}, time.Now()); err == nil {
+		t.Fatal("expected an invalid recipient to be refused")
+	}
+}
+
+// serveOneSMTPSession speaks just enough SMTP for one message and sends every
+// line the client wrote to received.
+func serveOneSMTPSession(listener net.Listener, received chan<- []string) {
+	conn, err := listener.Accept()
+	if err != nil {
+		received <- nil
+		return
+	}
+	defer conn.Close()
+	text := textproto.NewConn(conn)
+	var lines []string
+	_ = text.PrintfLine("220 localhost ESMTP")
+	for {
+		line, err := text.ReadLine()
+		if err != nil {
+			received <- lines
+			return
+		}
+		lines = append(lines, line)
+		switch verb := strings.ToUpper(strings.SplitN(line, " ", 2)[0]); verb {
+		case "EHLO", "HELO":
+			_ = text.PrintfLine("250 localhost")
+		case "DATA":
+			_ = text.PrintfLine("354 go ahead")
+			body, err := text.ReadDotLines()
+			if err != nil {
+				received <- lines
+				return
+			}
+			lines = append(lines, body...)
+			_ = text.PrintfLine("250 queued")
+		case "QUIT":
+			_ = text.PrintfLine("221 bye")
+			received <- lines
+			return
+		default:
+			_ = text.PrintfLine("250 ok")
+		}
+	}
+}
@@ -0,0 +1,32 @@
+// Command gen converts the YAML API contract to the JSON the server embeds.
+//
+//	go run ./gen <contract.yaml> <out.json>
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/openapi"
+)
+
+func main() {
+	if len(os.Args) != 3 {
+		fmt.Fprintln(os.Stderr, "usage: gen <contract.yaml> <out.json>")
+		os.Exit(2)
+	}
+	source, err := os.ReadFile(os.Args[1])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	doc, err := openapi.FromYAML(source)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", os.Args[1], err)
+		os.Exit(1)
+	}
+	if err := os.WriteFile(os.Args[2], doc, 0o644); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
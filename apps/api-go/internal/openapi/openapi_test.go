@@ -0,0 +1,81 @@
+package openapi
+
+import (
+	"bytes"
+	"errors"
+	"io/fs"
+	"os"
+	"testing"
+
+	"github.com/pb33f/libopenapi"
+	"github.com/pb33f/libopenapi-validator/schema_validation"
+)
+
+const contractPath = "../../../../integrations/openapi/invoices.yaml"
+
+func TestEmbeddedSpecMatchesContract(t *testing.T) {
+	source, err := os.ReadFile(contractPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		t.Skip("contract is outside this checkout")
+	}
+	if err != nil {
+		t.Fatalf("read contract: %v", err)
+	}
+	want, err := FromYAML(source)
+	if err != nil {
+		t.Fatalf("convert contract: %v", err)
+	}
+	if !bytes.Equal(JSON, want) {
+		t.Fatal("openapi.json is stale; run go generate ./internal/openapi")
+	}
+}
+
+func TestSpecIsValidOpenAPI31(t *testing.T) {
+	doc, err := libopenapi.NewDocument(JSON)
+	if err != nil {
+		t.Fatalf("parse spec: %v", err)
+	}
+	if version := doc.GetVersion(); version != "3.1.0" {
+		t.Fatalf("expected OpenAPI 3.1.0, got %s", version)
+	}
+	if valid, validationErrors := schema_validation.ValidateOpenAPIDocument(doc); !valid {
+		for _, validationErr := range validationErrors {
+			for _, failure := range validationErr.SchemaValidationErrors {
+				t.Errorf("%s: %s", failure.Location, failure.Reason)
+			}
+			t.Errorf("%s: %s", validationErr.Message, validationErr.Reason)
+		}
+	}
+	// Building the model resolves every $ref, which the schema check does not.
+	if _, errs := doc.BuildV3Model(); len(errs) > 0 {
+		for _, err := range errs {
+			t.Errorf("build model: %v", err)
+		}
+	}
+}
+
+func TestFromYAMLKeepsKeyOrderAndTypes(t *testing.T) {
+	got, err := FromYAML([]byte("b: 1\na: [true, null, '2', 1.5]\nc: &x {z: text}\nd: *x\n"))
+	if err != nil {
+		t.Fatalf("convert: %v", err)
+	}
+	want := `{
+  "b": 1,
+  "a": [
+    true,
+    null,
+    "2",
+    1.5
+  ],
+  "c": {
+    "z": "text"
+  },
+  "d": {
+    "z": "text"
+  }
+}
+`
+	if string(got) != want {
+		t.Fatalf("unexpected JSON:\n%s", got)
+	}
+}
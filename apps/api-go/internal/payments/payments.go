@@ -0,0 +1,81 @@
+// Package payments takes invoice payments through an external payment
+// provider such as Stripe. It knows nothing about storage; the API server
+// records the payments a provider reports.
+package payments
+
+import (
+	"context"
+	"errors"
+	"net/http"
+)
+
+// ErrInvalidSignature is returned by HandleWebhook when a webhook was not
+// signed by the provider, or was signed too long ago to be trusted.
+var ErrInvalidSignature = errors.New("webhook signature is invalid")
+
+// IntentSucceeded is the status of an intent whose money was received.
+const IntentSucceeded = "succeeded"
+
+// EventType is what a webhook event means to the platform, whatever the
+// provider calls it.
+type EventType string
+
+// EventPaymentSucceeded reports that an intent's money was received.
+const EventPaymentSucceeded EventType = "payment.succeeded"
+
+// IntentRequest asks for a payment of Amount, in the minor units of
+// Currency, towards one invoice. IdempotencyKey, when set, makes the
+// provider answer a repeated request with the intent it already created.
+type IntentRequest struct {
+	TenantID       string
+	InvoiceID      string
+	InvoiceNumber  string
+	Amount         int64
+	Currency       string
+	IdempotencyKey string
+}
+
+// Intent is a payment the provider is collecting. ClientSecret lets the
+// payer's browser complete it with the provider directly, so card details
+// never reach the platform. TenantID and InvoiceID are the ones the intent
+// was created for.
+type Intent struct {
+	ID             string
+	ClientSecret   string
+	Status         string
+	Amount         int64
+	AmountReceived int64
+	Currency       string
+	TenantID       string
+	InvoiceID      string
+}
+
+// Succeeded reports whether the intent's money was received.
+func (i Intent) Succeeded() bool {
+	return i.Status == IntentSucceeded
+}
+
+// Event is a verified webhook event. Type is empty for events the platform
+// does not act on.
+type Event struct {
+	ID     string
+	Type   EventType
+	Intent Intent
+}
+
+// Provider is a payment provider. Implementations must be safe for
+// concurrent use.
+type Provider interface {
+	// Name is the provider's path segment in webhook URLs, e.g. "stripe".
+	Name() string
+	// CreatePaymentIntent starts collecting a payment.
+	CreatePaymentIntent(ctx context.Context, req IntentRequest) (Intent, error)
+	// ConfirmPayment fetches the intent's current state from the provider,
+	// so a payment is only recorded once the provider itself confirms the
+	// money was received.
+	ConfirmPayment(ctx context.Context, intentID string) (Intent, error)
+	// HandleWebhook verifies a webhook request's signature and decodes its
+	// event. It returns ErrInvalidSignature for requests the provider did
+	// not sign.
+	HandleWebhook(ctx context.Context, payload []byte, header http.Header) (Event, error)
+}
@@ -0,0 +1,244 @@
+package payments
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	StripeSignatureHeader = "Stripe-Signature"
+	// DefaultStripeTolerance is how old a webhook signature may be, which
+	// is what Stripe's own libraries allow. It bounds replays of a captured
+	// request.
+	DefaultStripeTolerance = 5 * time.Minute
+
+	stripeAPIURL = "https://api.stripe.com"
+	// stripeAPIVersion pins the shape of requests and responses, so an
+	// upgrade of the account's default version cannot change them.
+	stripeAPIVersion       = "2024-06-20"
+	stripeRequestTimeout   = 30 * time.Second
+	stripeMaxResponseBytes = 1 << 20
+)
+
+type StripeConfig struct {
+	SecretKey     string
+	WebhookSecret string
+	// BaseURL is the API's base URL; empty means api.stripe.com.
+	BaseURL string
+	// Tolerance defaults to DefaultStripeTolerance.
+	Tolerance time.Duration
+}
+
+// Stripe takes payments with Stripe PaymentIntents, calling the REST API
+// directly.
+type Stripe struct {
+	config StripeConfig
+	client *http.Client
+	now    func() time.Time
+}
+
+func NewStripe(config StripeConfig) (*Stripe, error) {
+	switch {
+	case config.SecretKey == "":
+		return nil, errors.New("stripe secret key is required")
+	case config.WebhookSecret == "":
+		return nil, errors.New("stripe webhook secret is required")
+	}
+	if config.BaseURL == "" {
+		config.BaseURL = stripeAPIURL
+	}
+	if parsed, err := url.Parse(config.BaseURL); err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return nil, fmt.Errorf("stripe base URL %q must be an http or https URL", config.BaseURL)
+	}
+	config.BaseURL = strings.TrimSuffix(config.BaseURL, "/")
+	if config.Tolerance <= 0 {
+		config.Tolerance = DefaultStripeTolerance
+	}
+	return &Stripe{config: config, client: &http.Client{Timeout: stripeRequestTimeout}, now: time.Now}, nil
+}
+
+func (s *Stripe) Name() string {
+	return "stripe"
+}
+
+// CreatePaymentIntent creates a PaymentIntent that accepts whatever payment
+// methods the Stripe account has enabled. The tenant and invoice go into
+// its metadata, where webhooks find them again.
+func (s *Stripe) CreatePaymentIntent(ctx context.Context, req IntentRequest) (Intent, error) {
+	form := url.Values{
+		"amount":                             {strconv.FormatInt(req.Amount, 10)},
+		"currency":                           {strings.ToLower(req.Currency)},
+		"automatic_payment_methods[enabled]": {"true"},
+		"metadata[tenant_id]":                {req.TenantID},
+		"metadata[invoice_id]":               {req.InvoiceID},
+	}
+	if req.InvoiceNumber != "" {
+		form.Set("description", "Invoice "+req.InvoiceNumber)
+		form.Set("metadata[invoice_number]", req.InvoiceNumber)
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, s.config.BaseURL+"/v1/payment_intents", strings.NewReader(form.Encode()))
+	if err != nil {
+		return Intent{}, err
+	}
+	httpReq.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if req.IdempotencyKey != "" {
+		httpReq.Header.Set("Idempotency-Key", req.IdempotencyKey)
+	}
+	intent, err := s.do(httpReq)
+	if err != nil {
+		return Intent{}, fmt.Errorf("create payment intent: %w", err)
+	}
+	return intent, nil
+}
+
+func (s *Stripe) ConfirmPayment(ctx context.Context, intentID string) (Intent, error) {
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodGet, s.config.BaseURL+"/v1/payment_intents/"+url.PathEscape(intentID), nil)
+	if err != nil {
+		return Intent{}, err
+	}
+	intent, err := s.do(httpReq)
+	if err != nil {
+		return Intent{}, fmt.Errorf("retrieve payment intent %s: %w", intentID, err)
+	}
+	return intent, nil
+}
+
+// HandleWebhook checks the Stripe-Signature header: a v1 HMAC-SHA256 of
+// the timestamp and the exact payload, keyed with the endpoint's signing
+// secret, made within the tolerance of now.
+func (s *Stripe) HandleWebhook(_ context.Context, payload []byte, header http.Header) (Event, error) {
+	if !s.verifySignature(payload, header.Get(StripeSignatureHeader)) {
+		return Event{}, ErrInvalidSignature
+	}
+	var event struct {
+		ID   string `json:"id"`
+		Type string `json:"type"`
+		Data struct {
+			Object json.RawMessage `json:"object"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return Event{}, fmt.Errorf("decode stripe event: %w", err)
+	}
+	result := Event{ID: event.ID}
+	if event.Type != "payment_intent.succeeded" {
+		return result, nil
+	}
+	var object stripeIntent
+	if err := json.Unmarshal(event.Data.Object, &object); err != nil {
+		return Event{}, fmt.Errorf("decode stripe event %s: %w", event.ID, err)
+	}
+	result.Type = EventPaymentSucceeded
+	result.Intent = object.intent()
+	return result, nil
+}
+
+func (s *Stripe) verifySignature(payload []byte, header string) bool {
+	var timestamp string
+	var signatures []string
+	for _, part := range strings.Split(header, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch key {
+		case "t":
+			timestamp = value
+		case "v1":
+			signatures = append(signatures, value)
+		}
+	}
+	signedAt, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil || len(signatures) == 0 {
+		return false
+	}
+	if age := s.now().Sub(time.Unix(signedAt, 0)); age > s.config.Tolerance || age < -s.config.Tolerance {
+		return false
+	}
+	want := signStripePayload(s.config.WebhookSecret, timestamp, payload)
+	for _, signature := range signatures {
+		if hmac.Equal([]byte(want), []byte(signature)) {
+			return true
+		}
+	}
+	return false
+}
+
+func signStripePayload(secret, timestamp string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (s *Stripe) do(req *http.Request) (Intent, error) {
+	req.SetBasicAuth(s.config.SecretKey, "")
+	req.Header.Set("Stripe-Version", stripeAPIVersion)
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return Intent{}, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, stripeMaxResponseBytes))
+	if err != nil {
+		return Intent{}, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return Intent{}, stripeError(resp.StatusCode, body)
+	}
+	var object stripeIntent
+	if err := json.Unmarshal(body, &object); err != nil {
+		return Intent{}, fmt.Errorf("decode stripe response: %w", err)
+	}
+	return object.intent(), nil
+}
+
+// stripeIntent is the part of a Stripe PaymentIntent object the platform
+// reads.
+type stripeIntent struct {
+	ID             string            `json:"id"`
+	ClientSecret   string            `json:"client_secret"`
+	Status         string            `json:"status"`
+	Amount         int64             `json:"amount"`
+	AmountReceived int64             `json:"amount_received"`
+	Currency       string            `json:"currency"`
+	Metadata       map[string]string `json:"metadata"`
+}
+
+func (o stripeIntent) intent() Intent {
+	return Intent{
+		ID:             o.ID,
+		ClientSecret:   o.ClientSecret,
+		Status:         o.Status,
+		Amount:         o.Amount,
+		AmountReceived: o.AmountReceived,
+		Currency:       strings.ToUpper(o.Currency),
+		TenantID:       o.Metadata["tenant_id"],
+		InvoiceID:      o.Metadata["invoice_id"],
+	}
+}
+
+// stripeError describes a failed API call, with Stripe's own message when
+// the body carries one.
+func stripeError(status int, body []byte) error {
+	var payload struct {
+		Error struct {
+			Type    string `json:"type"`
+			Code    string `json:"code"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if json.Unmarshal(body, &payload) == nil && payload.Error.Message != "" {
+		return fmt.Errorf("stripe answered %d (%s): %s", status, payload.Error.Type, payload.Error.Message)
+	}
+	return fmt.Errorf("stripe answered %d", status)
+}
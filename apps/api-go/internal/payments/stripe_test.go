@@ -0,0 +1,118 @@
+package payments
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestStripeCreatesPaymentIntent(t *testing.T) {
+	var got *http.Request
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			t.Errorf("parse form: %v", err)
+		}
+		got = r
+		fmt.Fprint(w, `{"id":"pi_1","client_secret":"pi_1_secret_x","status":"requires_payment_method","amount":11700,"currency":"ils","metadata":{"tenant_id":"tenant-a","invoice_id":"inv-1"}}`)
+	}))
+	defer api.Close()
+	stripe := newStripeForTest(t, api.URL)
+
+	intent, err := stripe.CreatePaymentIntent(context.Background(), IntentRequest{
+		TenantID: "tenant-a", InvoiceID: "inv-1", InvoiceNumber: "INV-0001", Amount: 11700, Currency: "ILS", IdempotencyKey: "key-1",
+	})
+	if err != nil {
+		t.Fatalf("create intent: %v", err)
+	}
+	want := Intent{ID: "pi_1", ClientSecret: "pi_1_secret_x", Status: "requires_payment_method", Amount: 11700, Currency: "ILS", TenantID: "tenant-a", InvoiceID: "inv-1"}
+	if intent != want {
+		t.Fatalf("expected %#v, got %#v", want, intent)
+	}
+	if got.Method != http.MethodPost || got.URL.Path != "/v1/payment_intents" {
+		t.Fatalf("unexpected request %s %s", got.Method, got.URL.Path)
+	}
+	if key, _, _ := got.BasicAuth(); key != "sk_test_1" {
+		t.Fatalf("expected the secret key as the username, got %q", key)
+	}
+	if got.Header.Get("Idempotency-Key") != "key-1" || got.Header.Get("Stripe-Version") != stripeAPIVersion {
+		t.Fatalf("unexpected headers %v", got.Header)
+	}
+	for field, value := range map[string]string{"amount": "11700", "currency": "ils", "metadata[tenant_id]": "tenant-a", "metadata[invoice_id]": "inv-1", "description": "Invoice INV-0001"} {
+		if got.PostForm.Get(field) != value {
+			t.Errorf("expected %s=%q, got %q", field, value, got.PostForm.Get(field))
+		}
+	}
+}
+
+func TestStripeReportsAPIErrors(t *testing.T) {
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, `{"error":{"type":"invalid_request_error","message":"No such payment_intent: 'pi_x'"}}`)
+	}))
+	defer api.Close()
+
+	_, err := newStripeForTest(t, api.URL).ConfirmPayment(context.Background(), "pi_x")
+	if err == nil || err.Error() != "retrieve payment intent pi_x: stripe answered 404 (invalid_request_error): No such payment_intent: 'pi_x'" {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
+
+func TestStripeVerifiesWebhookSignatures(t *testing.T) {
+	stripe := newStripeForTest(t, "")
+	now := time.Unix(1_700_000_000, 0)
+	stripe.now = func() time.Time { return now }
+	payload := []byte(`{"id":"evt_1","type":"payment_intent.succeeded","data":{"object":{"id":"pi_1","status":"succeeded","amount":11700,"amount_received":11700,"currency":"ils","metadata":{"tenant_id":"tenant-a","invoice_id":"inv-1"}}}}`)
+	header := func(signedAt time.Time, secret string) http.Header {
+		timestamp := strconv.FormatInt(signedAt.Unix(), 10)
+		return http.Header{StripeSignatureHeader: {"t=" + timestamp + ",v1=" + signStripePayload(secret, timestamp, payload) + ",v0=ignored"}}
+	}
+
+	event, err := stripe.HandleWebhook(context.Background(), payload, header(now.Add(-time.Minute), "whsec_1"))
+	if err != nil {
+		t.Fatalf("handle webhook: %v", err)
+	}
+	want := Event{ID: "evt_1", Type: EventPaymentSucceeded, Intent: Intent{ID: "pi_1", Status: IntentSucceeded, Amount: 11700, AmountReceived: 11700, Currency: "ILS", TenantID: "tenant-a", InvoiceID: "inv-1"}}
+	if event != want {
+		t.Fatalf("expected %#v, got %#v", want, event)
+	}
+
+	for name, h := range map[string]http.Header{
+		"missing":      {},
+		"wrong secret": header(now, "whsec_other"),
+		"too old":      header(now.Add(-DefaultStripeTolerance-time.Second), "whsec_1"),
+		"malformed":    {StripeSignatureHeader: {"v1=abc"}},
+	} {
+		if _, err := stripe.HandleWebhook(context.Background(), payload, h); !errors.Is(err, ErrInvalidSignature) {
+			t.Errorf("%s: expected ErrInvalidSignature, got %v", name, err)
+		}
+	}
+	tampered := append([]byte{}, payload...)
+	tampered[len(tampered)-3] = ' '
+	if _, err := stripe.HandleWebhook(context.Background(), tampered, header(now, "whsec_1")); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("tampered payload: expected ErrInvalidSignature, got %v", err)
+	}
+}
+
+func TestStripeIgnoresOtherEvents(t *testing.T) {
+	stripe := newStripeForTest(t, "")
+	payload := []byte(`{"id":"evt_2","type":"charge.refunded","data":{"object":{"id":"ch_1"}}}`)
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	event, err := stripe.HandleWebhook(context.Background(), payload, http.Header{StripeSignatureHeader: {"t=" + timestamp + ",v1=" + signStripePayload("whsec_1", timestamp, payload)}})
+	if err != nil || event != (Event{ID: "evt_2"}) {
+		t.Fatalf("expected an event without a type, got %#v, %v", event, err)
+	}
+}
+
+func newStripeForTest(t *testing.T, baseURL string) *Stripe {
+	t.Helper()
+	stripe, err := NewStripe(StripeConfig{SecretKey: "sk_test_1", WebhookSecret: "whsec_1", BaseURL: baseURL})
+	if err != nil {
+		t.Fatalf("new stripe: %v", err)
+	}
+	return stripe
+}
@@ -0,0 +1,108 @@
+package pdf
+
+import (
+	"slices"
+	"unicode"
+)
+
+// visualOrder reorders a line of text written in logical order for a
+// right-to-left page, where it is drawn left to right: Hebrew runs are
+// reversed while runs of Latin letters and numbers, such as "INV-0042" or
+// "1,170.00 ILS", keep their order. The line reads in the direction of its
+// first letter, so a Latin customer name stays as it is. Spaces and
+// punctuation between two runs of one direction join them; elsewhere they
+// follow the line. This covers the single lines a document prints, not the
+// full Unicode bidirectional algorithm.
+func visualOrder(text string) string {
+	runes := []rune(text)
+	const (
+		neutral = iota
+		rightToLeft
+		leftToRight
+	)
+	classes := make([]int, len(runes))
+	for i, r := range runes {
+		switch {
+		case unicode.Is(unicode.Hebrew, r):
+			classes[i] = rightToLeft
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			classes[i] = leftToRight
+		case (r == '-' || r == '+') && i+1 < len(runes) && unicode.IsDigit(runes[i+1]):
+			// A sign belongs to the number it precedes.
+			classes[i] = leftToRight
+		}
+	}
+	base := rightToLeft
+	for _, class := range classes {
+		if class != neutral {
+			base = class
+			break
+		}
+	}
+	ltr := make([]bool, len(runes))
+	for i := 0; i < len(runes); {
+		if classes[i] != neutral {
+			ltr[i] = classes[i] == leftToRight
+			i++
+			continue
+		}
+		end := i
+		for end < len(runes) && classes[end] == neutral {
+			end++
+		}
+		resolved := base
+		if i > 0 && end < len(runes) && classes[i-1] == classes[end] {
+			resolved = classes[end]
+		}
+		for j := i; j < end; j++ {
+			ltr[j] = resolved == leftToRight
+		}
+		i = end
+	}
+
+	// A right-to-left line is reversed whole, which puts its runs in
+	// display order, and its left-to-right runs are turned back around. A
+	// left-to-right line only has its right-to-left runs reversed.
+	out := make([]rune, len(runes))
+	copy(out, runes)
+	if base == rightToLeft {
+		slices.Reverse(out)
+		slices.Reverse(ltr)
+	}
+	for i := 0; i < len(out); {
+		end := i + 1
+		for end < len(out) && ltr[end] == ltr[i] {
+			end++
+		}
+		if ltr[i] == (base == rightToLeft) {
+			slices.Reverse(out[i:end])
+		}
+		i = end
+	}
+	for i, r := range out {
+		if !ltr[i] {
+			out[i] = mirror(r)
+		}
+	}
+	return string(out)
+}
+
+// mirror swaps a paired bracket for its counterpart, as right-to-left text
+// displays them.
+func mirror(r rune) rune {
+	switch r {
+	case '(':
+		return ')'
+	case ')':
+		return '('
+	case '[':
+		return ']'
+	case ']':
+		return '['
+	case '<':
+		return '>'
+	case '>':
+		return '<'
+	}
+	return r
+}
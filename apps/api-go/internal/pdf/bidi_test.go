@@ -0,0 +1,22 @@
+package pdf
+
+import "testing"
+
+func TestVisualOrder(t *testing.T) {
+	cases := map[string]string{
+		"":                       "",
+		"Acme Ltd.":              "Acme Ltd.",
+		"סה״כ":                   "כ״הס",
+		"מס׳ INV-0042":           "INV-0042 ׳סמ",
+		"תאריך הפקה: 01/06/2026": "01/06/2026 :הקפה ךיראת",
+		"-1,170.00 ILS":          "-1,170.00 ILS",
+		"ייעוץ (2 ימים)":         "(םימי 2) ץועיי",
+		"Hosting (חודשי)":        "Hosting (ישדוח)",
+		"זיכוי לחשבונית INV-0042: ביטול": "לוטיב :INV-0042 תינובשחל יוכיז",
+	}
+	for logical, want := range cases {
+		if got := visualOrder(logical); got != want {
+			t.Errorf("visualOrder(%q) = %q, want %q", logical, got, want)
+		}
+	}
+}
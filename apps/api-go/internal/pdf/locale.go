@@ -0,0 +1,193 @@
+package pdf
+
+import (
+	"strings"
+	"time"
+
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/money"
+)
+
+// DefaultLocale is the locale documents are printed in when theirs is
+// empty or not supported.
+const DefaultLocale = "en"
+
+// locale is the language and formatting a document is printed in.
+type locale struct {
+	tag string
+	// rtl lays the page out right to left and reorders text for display.
+	rtl bool
+	// unicode selects the embedded Unicode font: the core PDF fonts only
+	// cover Western European scripts.
+	unicode    bool
+	dateLayout string
+	decimal    string
+	group      string
+	// currencyAfter prints "1.170,00 ILS" rather than "ILS 1,170.00".
+	currencyAfter bool
+	labels        labels
+}
+
+// labels are the static texts of a document. number, credits and the ones
+// ending in Line are format strings for a document number.
+type labels struct {
+	invoice     string
+	creditNote  string
+	number      string
+	issued      string
+	due         string
+	billTo      string
+	taxID       string
+	description string
+	quantity    string
+	unitPrice   string
+	amount      string
+	subtotal    string
+	tax         string
+	total       string
+	invoiceLine string
+	creditLine  string
+	credits     string
+}
+
+var locales = map[string]locale{
+	"en": {
+		tag:        "en",
+		dateLayout: "2006-01-02",
+		decimal:    ".",
+		group:      ",",
+		labels: labels{
+			invoice:     "INVOICE",
+			creditNote:  "CREDIT NOTE",
+			number:      "No. %s",
+			issued:      "Issued",
+			due:         "Due",
+			billTo:      "Bill to",
+			taxID:       "Tax ID",
+			description: "Description",
+			quantity:    "Qty",
+			unitPrice:   "Unit price",
+			amount:      "Amount",
+			subtotal:    "Subtotal",
+			tax:         "Tax",
+			total:       "Total",
+			invoiceLine: "Invoice %s",
+			creditLine:  "Credit for invoice %s",
+			credits:     "Credits invoice %s",
+		},
+	},
+	"de": {
+		tag:           "de",
+		dateLayout:    "02.01.2006",
+		decimal:       ",",
+		group:         ".",
+		currencyAfter: true,
+		labels: labels{
+			invoice:     "RECHNUNG",
+			creditNote:  "GUTSCHRIFT",
+			number:      "Nr. %s",
+			issued:      "Datum",
+			due:         "Fällig am",
+			billTo:      "Rechnungsempfänger",
+			taxID:       "USt-IdNr.",
+			description: "Beschreibung",
+			quantity:    "Menge",
+			unitPrice:   "Einzelpreis",
+			amount:      "Betrag",
+			subtotal:    "Zwischensumme",
+			tax:         "MwSt.",
+			total:       "Gesamtbetrag",
+			invoiceLine: "Rechnung %s",
+			creditLine:  "Gutschrift zu Rechnung %s",
+			credits:     "Zu Rechnung %s",
+		},
+	},
+	"he": {
+		tag:           "he",
+		rtl:           true,
+		unicode:       true,
+		dateLayout:    "02/01/2006",
+		decimal:       ".",
+		group:         ",",
+		currencyAfter: true,
+		labels: labels{
+			invoice:     "חשבונית",
+			creditNote:  "הודעת זיכוי",
+			number:      "מס׳ %s",
+			issued:      "תאריך הפקה",
+			due:         "לתשלום עד",
+			billTo:      "לכבוד",
+			taxID:       "מס׳ עוסק",
+			description: "תיאור",
+			quantity:    "כמות",
+			unitPrice:   "מחיר ליחידה",
+			amount:      "סכום",
+			subtotal:    "סכום ביניים",
+			tax:         "מע״מ",
+			total:       "סה״כ",
+			invoiceLine: "חשבונית %s",
+			creditLine:  "זיכוי לחשבונית %s",
+			credits:     "מזכה את חשבונית %s",
+		},
+	},
+}
+
+// ResolveLocale returns the supported locale documents in tag are printed
+// in: its language, so "de-AT" prints in German, or DefaultLocale.
+func ResolveLocale(tag string) string {
+	return lookupLocale(tag).tag
+}
+
+// SupportsLocale reports whether tag names a language the renderer prints
+// in rather than falling back to DefaultLocale.
+func SupportsLocale(tag string) bool {
+	_, ok := locales[language(tag)]
+	return ok
+}
+
+func lookupLocale(tag string) locale {
+	if loc, ok := locales[language(tag)]; ok {
+		return loc
+	}
+	return locales[DefaultLocale]
+}
+
+// language is the primary language subtag of a BCP 47 tag such as "he-IL",
+// also accepting POSIX forms such as "de_DE.UTF-8".
+func language(tag string) string {
+	lang, _, _ := strings.Cut(strings.TrimSpace(tag), "-")
+	lang, _, _ = strings.Cut(lang, "_")
+	return strings.ToLower(lang)
+}
+
+func (l locale) formatAmount(minor int64, currency string) string {
+	decimal := money.Money{Amount: minor, Currency: currency}.Decimal()
+	decimal, negative := strings.CutPrefix(decimal, "-")
+	whole, frac, hasFrac := strings.Cut(decimal, ".")
+	var grouped strings.Builder
+	for i, digit := range whole {
+		if i > 0 && (len(whole)-i)%3 == 0 {
+			grouped.WriteString(l.group)
+		}
+		grouped.WriteRune(digit)
+	}
+	number := grouped.String()
+	if hasFrac {
+		number += l.decimal + frac
+	}
+	sign := ""
+	if negative {
+		sign = "-"
+	}
+	if l.currencyAfter {
+		return sign + number + " " + currency
+	}
+	return sign + currency + " " + number
+}
+
+func (l locale) formatDate(value string) string {
+	ts, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return value
+	}
+	return ts.UTC().Format(l.dateLayout)
+}
@@ -0,0 +1,47 @@
+package pdf
+
+import "testing"
+
+func TestLocaleFormatsAmountsAndDates(t *testing.T) {
+	cases := []struct {
+		locale string
+		minor  int64
+		amount string
+		date   string
+	}{
+		{"en", 136890, "ILS 1,368.90", "2026-06-30"},
+		{"en", -123456789, "-ILS 1,234,567.89", "2026-06-30"},
+		{"de", 136890, "1.368,90 ILS", "30.06.2026"},
+		{"he", -19890, "-198.90 ILS", "30/06/2026"},
+	}
+	for _, tc := range cases {
+		loc := lookupLocale(tc.locale)
+		if got := loc.formatAmount(tc.minor, "ILS"); got != tc.amount {
+			t.Errorf("%s: formatAmount(%d) = %q, want %q", tc.locale, tc.minor, got, tc.amount)
+		}
+		if got := loc.formatDate("2026-06-30T00:00:00Z"); got != tc.date {
+			t.Errorf("%s: formatDate = %q, want %q", tc.locale, got, tc.date)
+		}
+	}
+	if got := lookupLocale("en").formatAmount(150000, "JPY"); got != "JPY 150,000" {
+		t.Errorf("expected a currency without minor units grouped, got %q", got)
+	}
+}
+
+func TestResolveLocale(t *testing.T) {
+	cases := map[string]string{
+		"":            "en",
+		"en-US":       "en",
+		"de":          "de",
+		"DE-at":       "de",
+		"de_DE.UTF-8": "de",
+		"he-IL":       "he",
+		"fr-CA":       "en",
+		"iw":          "en",
+	}
+	for tag, want := range cases {
+		if got := ResolveLocale(tag); got != want {
+			t.Errorf("ResolveLocale(%q) = %q, want %q", tag, got, want)
+		}
+	}
+}
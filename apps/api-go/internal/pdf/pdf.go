@@ -0,0 +1,275 @@
+// Package pdf renders invoice documents into A4 PDFs.
+package pdf
+
+import (
+	_ "embed"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/go-pdf/fpdf"
+)
+
+// Renderer turns a Document into PDF bytes.
+type Renderer interface {
+	Render(w io.Writer, doc Document) error
+}
+
+type Config struct {
+	CompanyName    string
+	CompanyAddress []string
+}
+
+// Party is a billed customer. TaxID is printed under the lines with the
+// locale's label.
+type Party struct {
+	Name  string
+	Lines []string
+	TaxID string
+}
+
+type Line struct {
+	Description string
+	Quantity    int64
+	UnitPrice   int64
+	Amount      int64
+}
+
+// Kind is the type of document, which sets its title.
+type Kind int
+
+const (
+	KindInvoice Kind = iota
+	KindCreditNote
+)
+
+// Document is the print view of an invoice or credit note. Amounts are in
+// currency minor units. Credits is the number of the invoice a credit note
+// credits. A document without lines prints one summary row for its
+// subtotal, followed by Note when there is one. Locale is a BCP 47 tag such
+// as "de" or "he-IL" that sets the language, date and number formats and,
+// for Hebrew, a right-to-left layout; unsupported locales print in
+// DefaultLocale. GeneratedAt is written into the PDF metadata instead of
+// the wall clock so the same document always renders to identical bytes.
+type Document struct {
+	Kind        Kind
+	Number      string
+	Credits     string
+	Note        string
+	Locale      string
+	Currency    string
+	IssuedAt    string
+	DueAt       string
+	BillTo      Party
+	Lines       []Line
+	Subtotal    int64
+	Tax         int64
+	Total       int64
+	GeneratedAt time.Time
+}
+
+type FPDFRenderer struct {
+	cfg Config
+}
+
+func NewRenderer(cfg Config) *FPDFRenderer {
+	if cfg.CompanyName == "" {
+		cfg.CompanyName = "Invoices Platform"
+	}
+	return &FPDFRenderer{cfg: cfg}
+}
+
+// unicodeFamily is the embedded font used for locales whose script the
+// core PDF fonts lack; see fonts/README.md.
+const unicodeFamily = "DejaVuSansCondensed"
+
+var (
+	//go:embed fonts/DejaVuSansCondensed.ttf
+	unicodeRegular []byte
+	//go:embed fonts/DejaVuSansCondensed-Bold.ttf
+	unicodeBold []byte
+)
+
+const (
+	pageMargin  = 15.0
+	contentW    = 210.0 - 2*pageMargin
+	lineHeight  = 6.0
+	descColumnW = 90.0
+	numColumnW  = (contentW - descColumnW) / 3
+)
+
+func (r *FPDFRenderer) Render(w io.Writer, doc Document) error {
+	loc := lookupLocale(doc.Locale)
+	labels := loc.labels
+	title := labels.invoice
+	if doc.Kind == KindCreditNote {
+		title = labels.creditNote
+	}
+
+	pdf := fpdf.New("P", "mm", "A4", "")
+	pdf.SetMargins(pageMargin, pageMargin, pageMargin)
+	pdf.SetAutoPageBreak(true, pageMargin)
+	pdf.SetCompression(true)
+	pdf.SetCatalogSort(true)
+	pdf.SetCreationDate(doc.GeneratedAt.UTC())
+	pdf.SetModificationDate(doc.GeneratedAt.UTC())
+	pdf.SetCreator(r.cfg.CompanyName, true)
+	pdf.SetTitle(fmt.Sprintf("%s %s", title, doc.Number), true)
+	pdf.SetLang(loc.tag)
+	p := newPage(pdf, loc)
+	pdf.AddPage()
+
+	// Company header at the start of the line, document title and dates at
+	// its end.
+	top := pdf.GetY()
+	p.place(0, top, contentW/2)
+	p.font("B", 16)
+	p.cell(contentW/2, 8, r.cfg.CompanyName, "", 2, "L", false)
+	p.font("", 10)
+	for _, line := range r.cfg.CompanyAddress {
+		p.cell(contentW/2, 5, line, "", 2, "L", false)
+	}
+	headerBottom := pdf.GetY()
+
+	p.place(contentW/2, top, contentW/2)
+	p.font("B", 20)
+	p.cell(contentW/2, 10, title, "", 2, "R", false)
+	p.font("", 10)
+	p.cell(contentW/2, 5, fmt.Sprintf(labels.number, doc.Number), "", 2, "R", false)
+	if doc.Credits != "" {
+		p.cell(contentW/2, 5, fmt.Sprintf(labels.credits, doc.Credits), "", 2, "R", false)
+	}
+	if doc.IssuedAt != "" {
+		p.cell(contentW/2, 5, labels.issued+": "+loc.formatDate(doc.IssuedAt), "", 2, "R", false)
+	}
+	if doc.DueAt != "" {
+		p.cell(contentW/2, 5, labels.due+": "+loc.formatDate(doc.DueAt), "", 2, "R", false)
+	}
+	p.place(0, max(headerBottom, pdf.GetY())+10, contentW)
+
+	// Customer billing block.
+	p.font("B", 10)
+	p.cell(contentW, 5, labels.billTo, "", 1, "L", false)
+	p.font("", 10)
+	p.cell(contentW, 5, doc.BillTo.Name, "", 1, "L", false)
+	for _, line := range doc.BillTo.Lines {
+		p.cell(contentW, 5, line, "", 1, "L", false)
+	}
+	if doc.BillTo.TaxID != "" {
+		p.cell(contentW, 5, labels.taxID+": "+doc.BillTo.TaxID, "", 1, "L", false)
+	}
+	pdf.Ln(8)
+
+	// Line items table.
+	p.font("B", 10)
+	pdf.SetFillColor(235, 235, 235)
+	p.row(lineHeight+1, "B", true,
+		column{descColumnW, labels.description, "L"},
+		column{numColumnW, labels.quantity, "R"},
+		column{numColumnW, labels.unitPrice, "R"},
+		column{numColumnW, labels.amount, "R"})
+	p.font("", 10)
+	lines := doc.Lines
+	if len(lines) == 0 {
+		lines = []Line{summaryLine(doc, labels)}
+	}
+	for _, line := range lines {
+		p.row(lineHeight, "", false,
+			column{descColumnW, line.Description, "L"},
+			column{numColumnW, strconv.FormatInt(line.Quantity, 10), "R"},
+			column{numColumnW, loc.formatAmount(line.UnitPrice, doc.Currency), "R"},
+			column{numColumnW, loc.formatAmount(line.Amount, doc.Currency), "R"})
+	}
+	pdf.Ln(4)
+
+	// Totals, aligned under the amount column.
+	labelW := contentW - numColumnW
+	p.row(lineHeight, "", false, column{labelW, labels.subtotal, "R"}, column{numColumnW, loc.formatAmount(doc.Subtotal, doc.Currency), "R"})
+	p.row(lineHeight, "", false, column{labelW, labels.tax, "R"}, column{numColumnW, loc.formatAmount(doc.Tax, doc.Currency), "R"})
+	p.font("B", 11)
+	p.row(lineHeight+1, "T", false, column{labelW, labels.total, "R"}, column{numColumnW, loc.formatAmount(doc.Total, doc.Currency), "R"})
+
+	return pdf.Output(w)
+}
+
+// summaryLine is the row printed for a document without lines.
+func summaryLine(doc Document, labels labels) Line {
+	description := fmt.Sprintf(labels.invoiceLine, doc.Number)
+	if doc.Kind == KindCreditNote {
+		description = fmt.Sprintf(labels.creditLine, doc.Credits)
+	}
+	if doc.Note != "" {
+		description += ": " + doc.Note
+	}
+	return Line{Description: description, Quantity: 1, UnitPrice: doc.Subtotal, Amount: doc.Subtotal}
+}
+
+// page draws on a PDF page in a locale. Text is encoded for the font in
+// use and, on right-to-left pages, reordered for display; positions and
+// alignments are given for left-to-right pages and mirrored on the others.
+type page struct {
+	*fpdf.Fpdf
+	loc    locale
+	family string
+	encode func(string) string
+}
+
+func newPage(pdf *fpdf.Fpdf, loc locale) *page {
+	p := &page{Fpdf: pdf, loc: loc, family: "Helvetica", encode: pdf.UnicodeTranslatorFromDescriptor("")}
+	if loc.unicode {
+		pdf.AddUTF8FontFromBytes(unicodeFamily, "", unicodeRegular)
+		pdf.AddUTF8FontFromBytes(unicodeFamily, "B", unicodeBold)
+		p.family = unicodeFamily
+		p.encode = func(text string) string { return text }
+	}
+	return p
+}
+
+func (p *page) font(style string, size float64) {
+	p.SetFont(p.family, style, size)
+}
+
+// place moves to a block width wide, offset from the start of the line.
+func (p *page) place(offset, y, width float64) {
+	x := pageMargin + offset
+	if p.loc.rtl {
+		x = pageMargin + contentW - offset - width
+	}
+	p.SetXY(x, y)
+}
+
+func (p *page) cell(w, h float64, text, border string, ln int, align string, fill bool) {
+	if p.loc.rtl {
+		text = visualOrder(text)
+		switch align {
+		case "L":
+			align = "R"
+		case "R":
+			align = "L"
+		}
+	}
+	p.CellFormat(w, h, p.encode(text), border, ln, align, fill, 0, "")
+}
+
+type column struct {
+	width float64
+	text  string
+	align string
+}
+
+// row draws one table row in reading order and moves to the next line.
+func (p *page) row(h float64, border string, fill bool, columns ...column) {
+	if p.loc.rtl {
+		columns = slices.Clone(columns)
+		slices.Reverse(columns)
+	}
+	for i, c := range columns {
+		ln := 0
+		if i == len(columns)-1 {
+			ln = 1
+		}
+		p.cell(c.width, h, c.text, border, ln, c.align, fill)
+	}
+}
@@ -0,0 +1,131 @@
+package pdf
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var update = flag.Bool("update", false, "rewrite golden PDF files")
+
+func sampleDocument() Document {
+	return Document{
+		Number:   "INV-0042",
+		Currency: "ILS",
+		IssuedAt: "2026-06-01T09:00:00Z",
+		DueAt:    "2026-06-30T00:00:00Z",
+		BillTo: Party{
+			Name:  "Acme Ltd.",
+			Lines: []string{"1 Herzl St.", "Tel Aviv"},
+		},
+		Lines: []Line{
+			{Description: "Consulting", Quantity: 2, UnitPrice: 50000, Amount: 100000},
+			{Description: "Hosting", Quantity: 1, UnitPrice: 17000, Amount: 17000},
+		},
+		Subtotal:    117000,
+		Tax:         19890,
+		Total:       136890,
+		GeneratedAt: time.Date(2026, 6, 1, 9, 0, 0, 0, time.UTC),
+	}
+}
+
+func TestRenderIsDeterministic(t *testing.T) {
+	renderer := NewRenderer(Config{CompanyName: "Invoices Platform", CompanyAddress: []string{"Jerusalem"}})
+
+	var first, second bytes.Buffer
+	if err := renderer.Render(&first, sampleDocument()); err != nil {
+		t.Fatalf("render first: %v", err)
+	}
+	if err := renderer.Render(&second, sampleDocument()); err != nil {
+		t.Fatalf("render second: %v", err)
+	}
+	if !bytes.HasPrefix(first.Bytes(), []byte("%PDF-")) {
+		t.Fatal("expected PDF header")
+	}
+	if !bytes.Equal(first.Bytes(), second.Bytes()) {
+		t.Fatal("expected byte-identical output for the same document")
+	}
+}
+
+func TestRenderMatchesGolden(t *testing.T) {
+	assertGolden(t, "invoice.golden.pdf", sampleDocument())
+}
+
+func TestRenderCreditNoteMatchesGolden(t *testing.T) {
+	assertGolden(t, "credit_note.golden.pdf", sampleCreditNote())
+}
+
+func TestRenderLocalizedMatchesGolden(t *testing.T) {
+	german := sampleDocument()
+	german.Locale = "de-DE"
+	german.BillTo = Party{Name: "Müller & Söhne GmbH", Lines: []string{"Königstraße 12", "70173 Stuttgart"}, TaxID: "DE123456789"}
+	german.Lines[0].Description = "Beratung"
+	assertGolden(t, "invoice.de.golden.pdf", german)
+
+	hebrew := sampleDocument()
+	hebrew.Locale = "he-IL"
+	hebrew.BillTo = Party{Name: "אקמה בע״מ", Lines: []string{"הרצל 1", "תל אביב"}, TaxID: "514000000"}
+	hebrew.Lines = []Line{
+		{Description: "ייעוץ (2 ימים)", Quantity: 2, UnitPrice: 50000, Amount: 100000},
+		{Description: "Hosting", Quantity: 1, UnitPrice: 17000, Amount: 17000},
+	}
+	assertGolden(t, "invoice.he.golden.pdf", hebrew)
+
+	note := sampleCreditNote()
+	note.Locale = "he"
+	assertGolden(t, "credit_note.he.golden.pdf", note)
+}
+
+func TestRenderFallsBackToEnglish(t *testing.T) {
+	renderer := NewRenderer(Config{CompanyName: "Invoices Platform"})
+	var english, unsupported bytes.Buffer
+	if err := renderer.Render(&english, sampleDocument()); err != nil {
+		t.Fatalf("render english: %v", err)
+	}
+	doc := sampleDocument()
+	doc.Locale = "fr-CA"
+	if err := renderer.Render(&unsupported, doc); err != nil {
+		t.Fatalf("render fr-CA: %v", err)
+	}
+	if !bytes.Equal(unsupported.Bytes(), english.Bytes()) {
+		t.Fatal("expected an unsupported locale to print as English")
+	}
+}
+
+func sampleCreditNote() Document {
+	doc := sampleDocument()
+	doc.Kind = KindCreditNote
+	doc.Number = "CN-0007"
+	doc.Credits = "INV-0042"
+	doc.Note = "Hosting cancelled"
+	doc.DueAt = ""
+	doc.Lines = nil
+	doc.Subtotal, doc.Tax, doc.Total = -17000, -2890, -19890
+	return doc
+}
+
+func assertGolden(t *testing.T, name string, doc Document) {
+	t.Helper()
+
+	renderer := NewRenderer(Config{CompanyName: "Invoices Platform", CompanyAddress: []string{"Jerusalem"}})
+	var out bytes.Buffer
+	if err := renderer.Render(&out, doc); err != nil {
+		t.Fatalf("render: %v", err)
+	}
+	golden := filepath.Join("testdata", name)
+	if *update {
+		if err := os.WriteFile(golden, out.Bytes(), 0o644); err != nil {
+			t.Fatalf("write golden: %v", err)
+		}
+	}
+	want, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatalf("read golden (run with -update to create it): %v", err)
+	}
+	if !bytes.Equal(out.Bytes(), want) {
+		t.Fatal("rendered PDF differs from golden; run go test ./internal/pdf -update if the layout change is intended")
+	}
+}
@@ -0,0 +1,92 @@
+// Package ratelimit keeps one token bucket per key in memory. Buckets that
+// have not been used for a while are dropped, so the number of keys seen
+// over the life of the process does not grow the map without bound.
+package ratelimit
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// DefaultIdleTimeout is how long an unused bucket is kept. A bucket idle
+// for longer has refilled anyway, so dropping it loses nothing.
+const DefaultIdleTimeout = 10 * time.Minute
+
+// Limiter hands out tokens per key at a steady rate with a burst allowance.
+type Limiter struct {
+	limit rate.Limit
+	burst int
+	idle  time.Duration
+	now   func() time.Time
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+type bucket struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+// New returns a limiter allowing perSecond requests per key on average and
+// up to burst at once.
+func New(perSecond float64, burst int) *Limiter {
+	return &Limiter{
+		limit:   rate.Limit(perSecond),
+		burst:   burst,
+		idle:    DefaultIdleTimeout,
+		now:     time.Now,
+		buckets: make(map[string]*bucket),
+	}
+}
+
+// Allow takes a token for key. When none is left it reports how long the
+// caller should wait before trying again.
+func (l *Limiter) Allow(key string) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	l.sweepLocked(now)
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{limiter: rate.NewLimiter(l.limit, l.burst)}
+		l.buckets[key] = b
+	}
+	b.lastSeen = now
+
+	reservation := b.limiter.ReserveN(now, 1)
+	if !reservation.OK() {
+		return false, time.Duration(math.MaxInt64)
+	}
+	if delay := reservation.DelayFrom(now); delay > 0 {
+		reservation.CancelAt(now)
+		return false, delay
+	}
+	return true, 0
+}
+
+// Len reports how many buckets are currently held.
+func (l *Limiter) Len() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return len(l.buckets)
+}
+
+// sweepLocked drops idle buckets, at most once per idle period so the cost
+// is spread thinly over requests.
+func (l *Limiter) sweepLocked(now time.Time) {
+	if now.Sub(l.lastSweep) < l.idle {
+		return
+	}
+	l.lastSweep = now
+	for key, b := range l.buckets {
+		if now.Sub(b.lastSeen) >= l.idle {
+			delete(l.buckets, key)
+		}
+	}
+}
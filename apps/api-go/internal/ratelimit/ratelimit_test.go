@@ -0,0 +1,63 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+type fakeClock struct{ t time.Time }
+
+func (c *fakeClock) now() time.Time          { return c.t }
+func (c *fakeClock) advance(d time.Duration) { c.t = c.t.Add(d) }
+
+func newTestLimiter(perSecond float64, burst int) (*Limiter, *fakeClock) {
+	clock := &fakeClock{t: time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)}
+	l := New(perSecond, burst)
+	l.now = clock.now
+	return l, clock
+}
+
+func TestAllowSpendsBurstThenRefills(t *testing.T) {
+	l, clock := newTestLimiter(2, 3)
+	for i := range 3 {
+		if ok, _ := l.Allow("org-a"); !ok {
+			t.Fatalf("request %d: expected burst to be allowed", i+1)
+		}
+	}
+	ok, retryAfter := l.Allow("org-a")
+	if ok || retryAfter != 500*time.Millisecond {
+		t.Fatalf("expected over-limit request to wait 500ms, got ok=%v retryAfter=%v", ok, retryAfter)
+	}
+	if ok, _ := l.Allow("org-b"); !ok {
+		t.Fatal("expected other keys to have their own bucket")
+	}
+
+	// A rejected request must not consume a token.
+	clock.advance(500 * time.Millisecond)
+	if ok, _ := l.Allow("org-a"); !ok {
+		t.Fatal("expected a token after the refill interval")
+	}
+	if ok, _ := l.Allow("org-a"); ok {
+		t.Fatal("expected the refilled token to be spent")
+	}
+}
+
+func TestIdleBucketsAreSwept(t *testing.T) {
+	l, clock := newTestLimiter(1, 1)
+	l.Allow("org-a")
+	l.Allow("org-b")
+	clock.advance(DefaultIdleTimeout / 2)
+	l.Allow("org-b")
+	if got := l.Len(); got != 2 {
+		t.Fatalf("expected 2 buckets, got %d", got)
+	}
+
+	clock.advance(DefaultIdleTimeout/2 + time.Second)
+	l.Allow("org-c")
+	if got := l.Len(); got != 2 {
+		t.Fatalf("expected org-a swept and org-b kept, got %d buckets", got)
+	}
+	if _, ok := l.buckets["org-a"]; ok {
+		t.Fatal("expected idle org-a bucket to be dropped")
+	}
+}
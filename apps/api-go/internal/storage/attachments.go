@@ -0,0 +1,108 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/api"
+)
+
+const attachmentColumns = `id, tenant_id, invoice_id, filename, size, content_type, checksum, object_key, created_at`
+
+func (s *PostgresStore) ListInvoiceAttachments(ctx context.Context, tenantID, invoiceID string) ([]api.Attachment, error) {
+	rows, err := s.pool.Query(ctx, `
+		select `+attachmentColumns+`
+		from invoice_attachments
+		where tenant_id = $1 and invoice_id = $2
+		order by created_at, id
+	`, tenantID, invoiceID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []api.Attachment{}
+	for rows.Next() {
+		item, err := scanAttachment(rows)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+func (s *PostgresStore) GetInvoiceAttachment(ctx context.Context, tenantID, invoiceID, id string) (api.Attachment, error) {
+	return scanAttachment(s.pool.QueryRow(ctx, `
+		select `+attachmentColumns+`
+		from invoice_attachments
+		where tenant_id = $1 and invoice_id = $2 and id = $3
+	`, tenantID, invoiceID, id))
+}
+
+// CreateInvoiceAttachment inserts item, or reads back the invoice's
+// attachment with the same checksum when the insert is skipped because one
+// exists.
+func (s *PostgresStore) CreateInvoiceAttachment(ctx context.Context, item api.Attachment) (api.Attachment, bool, error) {
+	saved, err := scanAttachment(s.pool.QueryRow(ctx, `
+		insert into invoice_attachments (`+attachmentColumns+`)
+		values ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		on conflict (tenant_id, invoice_id, checksum) do nothing
+		returning `+attachmentColumns,
+		item.ID, item.TenantID, item.InvoiceID, item.Filename, item.Size, item.ContentType, item.Checksum, item.ObjectKey, parseTime(item.CreatedAt)))
+	var pgErr *pgconn.PgError
+	switch {
+	case err == nil:
+		return saved, true, nil
+	case errors.As(err, &pgErr) && pgErr.Code == "23503":
+		return api.Attachment{}, false, api.ErrNotFound
+	case !errors.Is(err, api.ErrNotFound):
+		return api.Attachment{}, false, mapWriteError(err)
+	}
+	existing, err := scanAttachment(s.pool.QueryRow(ctx, `
+		select `+attachmentColumns+`
+		from invoice_attachments
+		where tenant_id = $1 and invoice_id = $2 and checksum = $3
+	`, item.TenantID, item.InvoiceID, item.Checksum))
+	return existing, false, err
+}
+
+func (s *PostgresStore) AttachmentObjectStored(ctx context.Context, tenantID, checksum string) (bool, error) {
+	var stored bool
+	err := s.pool.QueryRow(ctx, `
+		select exists (
+			select 1 from invoice_attachments
+			where tenant_id = $1 and checksum = $2
+		)
+	`, tenantID, checksum).Scan(&stored)
+	return stored, err
+}
+
+// LockAttachmentObject holds a transaction-scoped advisory lock on the
+// tenant's blob for checksum while fn runs. It is not retried: fn talks to
+// the object store, and the transaction itself writes nothing.
+func (s *PostgresStore) LockAttachmentObject(ctx context.Context, tenantID, checksum string, fn func(context.Context) error) error {
+	return runTx(ctx, s.pool, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, `select pg_advisory_xact_lock(hashtextextended($1, 0))`, exclusiveLockPrefix+"attachment:"+tenantID+":"+checksum); err != nil {
+			return err
+		}
+		return fn(ctx)
+	})
+}
+
+type attachmentScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanAttachment(row attachmentScanner) (api.Attachment, error) {
+	var item api.Attachment
+	var createdAt time.Time
+	if err := row.Scan(&item.ID, &item.TenantID, &item.InvoiceID, &item.Filename, &item.Size, &item.ContentType, &item.Checksum, &item.ObjectKey, &createdAt); err != nil {
+		return api.Attachment{}, mapScanError(err)
+	}
+	item.CreatedAt = createdAt.UTC().Format(time.RFC3339)
+	return item, nil
+}
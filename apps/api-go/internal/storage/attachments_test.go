@@ -0,0 +1,85 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/api"
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/invoice"
+)
+
+func TestPostgresStoreDeduplicatesInvoiceAttachments(t *testing.T) {
+	databaseURL := os.Getenv("DATABASE_URL")
+	if databaseURL == "" {
+		t.Skip("DATABASE_URL is required for postgres integration tests")
+	}
+
+	testDatabaseURL := createTestDatabase(t, databaseURL)
+	ctx := context.Background()
+	store, err := Open(ctx, testDatabaseURL)
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	defer store.Close()
+	if err := store.Migrate(ctx); err != nil {
+		t.Fatalf("migrate store: %v", err)
+	}
+
+	for _, id := range []string{"inv-1", "inv-2"} {
+		if _, err := store.CreateInvoice(ctx, invoice.Invoice{ID: id, TenantID: "tenant-a", Number: id, Currency: "ILS", Status: invoice.StatusDraft, CreatedAt: nowRFC3339(), UpdatedAt: nowRFC3339()}, invoice.DefaultNumberFormat); err != nil {
+			t.Fatalf("create invoice: %v", err)
+		}
+	}
+	attachment := api.Attachment{
+		ID:          "att-1",
+		TenantID:    "tenant-a",
+		InvoiceID:   "inv-1",
+		Filename:    "receipt.pdf",
+		Size:        5,
+		ContentType: "application/pdf",
+		Checksum:    "abc",
+		ObjectKey:   "attachments/tenant-a/abc",
+		CreatedAt:   nowRFC3339(),
+	}
+	if stored, err := store.AttachmentObjectStored(ctx, "tenant-a", "abc"); err != nil || stored {
+		t.Fatalf("expected no stored object yet, got %v, %v", stored, err)
+	}
+	if saved, created, err := store.CreateInvoiceAttachment(ctx, attachment); err != nil || !created || saved.ID != "att-1" {
+		t.Fatalf("expected the attachment to be created, got %#v, %v, %v", saved, created, err)
+	}
+	again := attachment
+	again.ID = "att-2"
+	again.Filename = "copy.pdf"
+	if saved, created, err := store.CreateInvoiceAttachment(ctx, again); err != nil || created || saved.ID != "att-1" || saved.Filename != "receipt.pdf" {
+		t.Fatalf("expected the existing attachment back, got %#v, %v, %v", saved, created, err)
+	}
+	other := again
+	other.InvoiceID = "inv-2"
+	if _, created, err := store.CreateInvoiceAttachment(ctx, other); err != nil || !created {
+		t.Fatalf("expected the same file on another invoice to be created, got %v, %v", created, err)
+	}
+	if stored, err := store.AttachmentObjectStored(ctx, "tenant-a", "abc"); err != nil || !stored {
+		t.Fatalf("expected the object to be stored, got %v, %v", stored, err)
+	}
+	if stored, err := store.AttachmentObjectStored(ctx, "tenant-b", "abc"); err != nil || stored {
+		t.Fatalf("expected another tenant not to share the object, got %v, %v", stored, err)
+	}
+
+	items, err := store.ListInvoiceAttachments(ctx, "tenant-a", "inv-1")
+	if err != nil || len(items) != 1 || items[0].ObjectKey != attachment.ObjectKey {
+		t.Fatalf("expected one attachment on inv-1, got %#v, %v", items, err)
+	}
+	if got, err := store.GetInvoiceAttachment(ctx, "tenant-a", "inv-1", "att-1"); err != nil || got.Size != 5 || got.ContentType != "application/pdf" {
+		t.Fatalf("expected the attachment, got %#v, %v", got, err)
+	}
+	if _, err := store.GetInvoiceAttachment(ctx, "tenant-a", "inv-2", "att-1"); !errors.Is(err, api.ErrNotFound) {
+		t.Fatalf("expected another invoice's attachment to be not found, got %v", err)
+	}
+	missing := attachment
+	missing.ID, missing.InvoiceID = "att-3", "inv-missing"
+	if _, _, err := store.CreateInvoiceAttachment(ctx, missing); !errors.Is(err, api.ErrNotFound) {
+		t.Fatalf("expected an attachment for a missing invoice to be not found, got %v", err)
+	}
+}
@@ -0,0 +1,75 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/api"
+)
+
+const auditLogColumns = `id, tenant_id, entity_type, entity_id, action, actor_type, actor_id, actor_email, request_id, changes, created_at`
+
+func (s *PostgresStore) ListAuditLog(ctx context.Context, tenantID, entityType, entityID string) ([]api.AuditLogEntry, error) {
+	rows, err := s.pool.Query(ctx, `
+		select `+auditLogColumns+`
+		from audit_log
+		where tenant_id = $1 and entity_type = $2 and entity_id = $3
+		order by seq
+	`, tenantID, entityType, entityID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []api.AuditLogEntry{}
+	for rows.Next() {
+		var item api.AuditLogEntry
+		var actorID, actorEmail, requestID sql.NullString
+		var changes []byte
+		var createdAt time.Time
+		if err := rows.Scan(&item.ID, &item.TenantID, &item.EntityType, &item.EntityID, &item.Action, &item.Actor.Type, &actorID, &actorEmail, &requestID, &changes, &createdAt); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(changes, &item.Changes); err != nil {
+			return nil, fmt.Errorf("unmarshal audit log changes: %w", err)
+		}
+		item.Actor.ID = actorID.String
+		item.Actor.Email = actorEmail.String
+		item.RequestID = requestID.String
+		item.CreatedAt = createdAt.UTC().Format(time.RFC3339)
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+// logChange writes the audit log entry of a change made in tx, so the entry
+// commits or rolls back with it.
+func logChange(ctx context.Context, tx pgx.Tx, tenantID, entityType, entityID, action string, before, after any) error {
+	values, err := auditLogValues(ctx, tenantID, entityType, entityID, action, before, after)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(ctx, `
+		insert into audit_log (`+auditLogColumns+`)
+		values ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10::jsonb, $11)
+	`, values...)
+	return err
+}
+
+// auditLogValues builds the audit_log row, in auditLogColumns order, of a
+// change by the caller in ctx.
+func auditLogValues(ctx context.Context, tenantID, entityType, entityID, action string, before, after any) ([]any, error) {
+	entry, err := api.NewAuditLogEntry(ctx, tenantID, entityType, entityID, action, before, after)
+	if err != nil {
+		return nil, err
+	}
+	changes, err := json.Marshal(entry.Changes)
+	if err != nil {
+		return nil, fmt.Errorf("marshal audit log changes: %w", err)
+	}
+	return []any{entry.ID, entry.TenantID, entry.EntityType, entry.EntityID, entry.Action, entry.Actor.Type, nullString(entry.Actor.ID), nullString(entry.Actor.Email),
+		nullString(entry.RequestID), changes, parseTime(entry.CreatedAt)}, nil
+}
@@ -0,0 +1,150 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/api"
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/invoice"
+)
+
+const creditNoteColumns = `id, tenant_id, invoice_id, number, currency, subtotal, tax, total, reason, issued_at, created_at`
+
+// VoidInvoice locks the invoice row before applying the void, so a payment
+// recorded at the same time either lands first and refuses the void or
+// waits and finds the invoice void.
+func (s *PostgresStore) VoidInvoice(ctx context.Context, tenantID, id, reason, voidedAt string) (invoice.Invoice, error) {
+	var item invoice.Invoice
+	err := s.inTx(ctx, func(tx pgx.Tx) error {
+		var err error
+		item, err = lockLiveInvoice(ctx, tx, tenantID, id)
+		if err != nil {
+			return err
+		}
+		before := item
+		if err := item.Void(reason, voidedAt); err != nil {
+			return err
+		}
+		item.UpdatedAt = voidedAt
+		item.Version++
+		if _, err := tx.Exec(ctx, `
+			update invoices
+			set status = $3, voided_at = $4, void_reason = $5, updated_at = $4, version = version + 1
+			where tenant_id = $1 and id = $2
+		`, tenantID, id, string(item.Status), parseTime(voidedAt), reason); err != nil {
+			return err
+		}
+		if err := logChange(ctx, tx, tenantID, api.AuditEntityInvoice, id, api.AuditInvoiceVoided, before, item); err != nil {
+			return err
+		}
+		item.Items, err = listLineItems(ctx, tx, tenantID, id)
+		return err
+	})
+	if err != nil {
+		return invoice.Invoice{}, err
+	}
+	return item, nil
+}
+
+// IssueCreditNote locks the invoice row, then takes the next number by
+// upserting the tenant's credit_note_sequences row. The upsert holds that
+// row until commit, so concurrent notes of a tenant are numbered one after
+// the other and a rolled back note gives its number back.
+func (s *PostgresStore) IssueCreditNote(ctx context.Context, note invoice.CreditNote, amount int64) (invoice.CreditNote, invoice.Invoice, error) {
+	var item invoice.Invoice
+	var issued invoice.CreditNote
+	err := s.inTx(ctx, func(tx pgx.Tx) error {
+		note := note
+		var err error
+		item, err = lockLiveInvoice(ctx, tx, note.TenantID, note.InvoiceID)
+		if err != nil {
+			return err
+		}
+		before := item
+		if err := item.ApplyCreditNote(&note, amount); err != nil {
+			return err
+		}
+		var sequence int64
+		if err := tx.QueryRow(ctx, `
+			insert into credit_note_sequences (tenant_id, last_value)
+			values ($1, 1)
+			on conflict (tenant_id) do update set last_value = credit_note_sequences.last_value + 1
+			returning last_value
+		`, note.TenantID).Scan(&sequence); err != nil {
+			return err
+		}
+		note.Number = invoice.CreditNoteNumber(sequence)
+		if _, err := tx.Exec(ctx, `
+			insert into credit_notes (`+creditNoteColumns+`)
+			values ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		`, note.ID, note.TenantID, note.InvoiceID, note.Number, note.Currency, note.Subtotal, note.Tax, note.Total, nullString(note.Reason),
+			parseTime(note.IssuedAt), parseTime(note.CreatedAt)); err != nil {
+			return mapWriteError(err)
+		}
+		item.UpdatedAt = note.CreatedAt
+		item.Version++
+		if _, err := tx.Exec(ctx, `
+			update invoices
+			set amount_credited = $3, updated_at = $4, version = version + 1
+			where tenant_id = $1 and id = $2
+		`, note.TenantID, item.ID, item.AmountCredited, parseTime(note.CreatedAt)); err != nil {
+			return err
+		}
+		if err := logChange(ctx, tx, note.TenantID, api.AuditEntityInvoice, item.ID, api.AuditInvoiceCredited, before, item); err != nil {
+			return err
+		}
+		issued = note
+		item.Items, err = listLineItems(ctx, tx, note.TenantID, item.ID)
+		return err
+	})
+	if err != nil {
+		return invoice.CreditNote{}, invoice.Invoice{}, err
+	}
+	return issued, item, nil
+}
+
+func (s *PostgresStore) ListCreditNotes(ctx context.Context, tenantID, invoiceID string) ([]invoice.CreditNote, error) {
+	rows, err := s.pool.Query(ctx, `
+		select `+creditNoteColumns+`
+		from credit_notes
+		where tenant_id = $1 and invoice_id = $2
+		order by created_at, number
+	`, tenantID, invoiceID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []invoice.CreditNote{}
+	for rows.Next() {
+		item, err := scanCreditNote(rows)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+func (s *PostgresStore) GetCreditNote(ctx context.Context, tenantID, invoiceID, id string) (invoice.CreditNote, error) {
+	return scanCreditNote(s.pool.QueryRow(ctx, `
+		select `+creditNoteColumns+`
+		from credit_notes
+		where tenant_id = $1 and invoice_id = $2 and id = $3
+	`, tenantID, invoiceID, id))
+}
+
+func scanCreditNote(row invoiceScanner) (invoice.CreditNote, error) {
+	var item invoice.CreditNote
+	var reason sql.NullString
+	var issuedAt, createdAt time.Time
+	if err := row.Scan(&item.ID, &item.TenantID, &item.InvoiceID, &item.Number, &item.Currency, &item.Subtotal, &item.Tax, &item.Total, &reason, &issuedAt, &createdAt); err != nil {
+		return invoice.CreditNote{}, mapScanError(err)
+	}
+	item.Reason = reason.String
+	item.IssuedAt = issuedAt.UTC().Format(time.RFC3339)
+	item.CreatedAt = createdAt.UTC().Format(time.RFC3339)
+	return item, nil
+}
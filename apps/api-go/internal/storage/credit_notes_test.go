@@ -0,0 +1,115 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/invoice"
+)
+
+func TestPostgresStoreVoidsAndCreditsInvoices(t *testing.T) {
+	databaseURL := os.Getenv("DATABASE_URL")
+	if databaseURL == "" {
+		t.Skip("DATABASE_URL is required for postgres integration tests")
+	}
+
+	testDatabaseURL := createTestDatabase(t, databaseURL)
+	ctx := context.Background()
+	store, err := Open(ctx, testDatabaseURL)
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	defer store.Close()
+	if err := store.Migrate(ctx); err != nil {
+		t.Fatalf("migrate store: %v", err)
+	}
+
+	newInvoice := func(id, number string) invoice.Invoice {
+		item := invoice.Invoice{
+			ID:         id,
+			TenantID:   "tenant-a",
+			Number:     number,
+			CustomerID: "cust-1",
+			Currency:   "ILS",
+			Subtotal:   1000,
+			Tax:        170,
+			Total:      1170,
+			Status:     invoice.StatusOpen,
+			CreatedAt:  nowRFC3339(),
+			UpdatedAt:  nowRFC3339(),
+		}
+		if _, err := store.CreateInvoice(ctx, item, invoice.DefaultNumberFormat); err != nil {
+			t.Fatalf("create invoice: %v", err)
+		}
+		return item
+	}
+
+	unpaid := newInvoice("inv-1", "INV-0001")
+	voided, err := store.VoidInvoice(ctx, "tenant-a", unpaid.ID, "Duplicate", nowRFC3339())
+	if err != nil || voided.Status != invoice.StatusVoid || voided.VoidReason != "Duplicate" || voided.Version != 2 {
+		t.Fatalf("expected the invoice voided, got %#v, %v", voided, err)
+	}
+	if got, err := store.GetInvoice(ctx, "tenant-a", unpaid.ID); err != nil || got.VoidedAt != voided.VoidedAt || got.VoidReason != "Duplicate" {
+		t.Fatalf("expected the void to be stored, got %#v, %v", got, err)
+	}
+	if _, err := store.VoidInvoice(ctx, "tenant-a", unpaid.ID, "Again", nowRFC3339()); !errors.Is(err, invoice.ErrAlreadyVoid) {
+		t.Fatalf("expected a second void to be refused, got %v", err)
+	}
+
+	paid := newInvoice("inv-2", "INV-0002")
+	payment := invoice.Payment{ID: "pay-1", InvoiceID: paid.ID, Amount: 1170, Method: invoice.PaymentCard, ReceivedAt: nowRFC3339(), CreatedAt: nowRFC3339()}
+	if _, err := store.RecordInvoicePayment(ctx, "tenant-a", payment, false, nowRFC3339()); err != nil {
+		t.Fatalf("record payment: %v", err)
+	}
+	if _, err := store.VoidInvoice(ctx, "tenant-a", paid.ID, "Duplicate", nowRFC3339()); !errors.Is(err, invoice.ErrPaymentsReceived) {
+		t.Fatalf("expected a paid invoice to refuse the void, got %v", err)
+	}
+
+	// Concurrent notes are numbered one after the other and together never
+	// credit more than was paid.
+	var wg sync.WaitGroup
+	errs := make([]error, 6)
+	for i := range errs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			note := invoice.CreditNote{ID: fmt.Sprintf("cn-%d", i), TenantID: "tenant-a", InvoiceID: paid.ID, IssuedAt: nowRFC3339(), CreatedAt: nowRFC3339()}
+			_, _, errs[i] = store.IssueCreditNote(ctx, note, 390)
+		}()
+	}
+	wg.Wait()
+	accepted := 0
+	for _, err := range errs {
+		switch {
+		case err == nil:
+			accepted++
+		case errors.Is(err, invoice.ErrCreditExceeded), errors.Is(err, invoice.ErrNotCreditable):
+		default:
+			t.Fatalf("unexpected error %v", err)
+		}
+	}
+	notes, err := store.ListCreditNotes(ctx, "tenant-a", paid.ID)
+	if err != nil || accepted != 3 || len(notes) != 3 {
+		t.Fatalf("expected three credit notes, got %d accepted, %#v, %v", accepted, notes, err)
+	}
+	numbers := map[string]bool{}
+	for _, note := range notes {
+		numbers[note.Number] = true
+		if note.Total != -390 || note.Subtotal+note.Tax != note.Total {
+			t.Fatalf("unexpected credit note amounts %#v", note)
+		}
+	}
+	if !numbers["CN-0001"] || !numbers["CN-0002"] || !numbers["CN-0003"] {
+		t.Fatalf("expected gap-free numbers, got %v", numbers)
+	}
+	if got, err := store.GetInvoice(ctx, "tenant-a", paid.ID); err != nil || got.AmountCredited != 1170 || got.Status != invoice.StatusPaid {
+		t.Fatalf("expected the paid invoice fully credited, got %#v, %v", got, err)
+	}
+	if got, err := store.GetCreditNote(ctx, "tenant-a", paid.ID, notes[0].ID); err != nil || got != notes[0] {
+		t.Fatalf("expected the credit note by id, got %#v, %v", got, err)
+	}
+}
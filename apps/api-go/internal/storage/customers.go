@@ -0,0 +1,137 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/api"
+)
+
+const customerColumns = `id, tenant_id, name, email, billing_address, tax_id, default_currency, created_at, updated_at, deleted_at, tax_exempt`
+
+func (s *PostgresStore) ListCustomers(ctx context.Context, tenantID string, includeDeleted bool) ([]api.Customer, error) {
+	rows, err := s.pool.Query(ctx, `
+		select `+customerColumns+`
+		from customers
+		where tenant_id = $1 and (deleted_at is null or $2)
+		order by created_at desc
+	`, tenantID, includeDeleted)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []api.Customer{}
+	for rows.Next() {
+		item, err := scanCustomer(rows)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+func (s *PostgresStore) GetCustomer(ctx context.Context, tenantID, id string) (api.Customer, error) {
+	return scanCustomer(s.pool.QueryRow(ctx, `
+		select `+customerColumns+`
+		from customers
+		where tenant_id = $1 and id = $2
+	`, tenantID, id))
+}
+
+func (s *PostgresStore) CreateCustomer(ctx context.Context, item api.Customer) error {
+	address, err := json.Marshal(item.BillingAddress)
+	if err != nil {
+		return fmt.Errorf("marshal billing address: %w", err)
+	}
+	return s.inTx(ctx, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, `
+			insert into customers (id, tenant_id, name, email, billing_address, tax_id, default_currency, created_at, updated_at, tax_exempt)
+			values ($1, $2, $3, $4, $5::jsonb, $6, $7, $8, $9, $10)
+		`, item.ID, item.TenantID, item.Name, item.Email, address, item.TaxID, item.DefaultCurrency, parseTime(item.CreatedAt), parseTime(item.UpdatedAt), item.TaxExempt); err != nil {
+			return mapWriteError(err)
+		}
+		return logChange(ctx, tx, item.TenantID, api.AuditEntityCustomer, item.ID, api.AuditCustomerCreated, nil, item)
+	})
+}
+
+func (s *PostgresStore) UpdateCustomer(ctx context.Context, item api.Customer) error {
+	address, err := json.Marshal(item.BillingAddress)
+	if err != nil {
+		return fmt.Errorf("marshal billing address: %w", err)
+	}
+	return s.inTx(ctx, func(tx pgx.Tx) error {
+		before, err := lockLiveCustomer(ctx, tx, item.TenantID, item.ID)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(ctx, `
+			update customers
+			set name = $3,
+				email = $4,
+				billing_address = $5::jsonb,
+				tax_id = $6,
+				default_currency = $7,
+				updated_at = $8,
+				tax_exempt = $9
+			where tenant_id = $1 and id = $2
+		`, item.TenantID, item.ID, item.Name, item.Email, address, item.TaxID, item.DefaultCurrency, parseTime(item.UpdatedAt), item.TaxExempt); err != nil {
+			return mapWriteError(err)
+		}
+		return logChange(ctx, tx, item.TenantID, api.AuditEntityCustomer, item.ID, api.AuditCustomerUpdated, before, item)
+	})
+}
+
+func (s *PostgresStore) SoftDeleteCustomer(ctx context.Context, tenantID, id, deletedAt string) error {
+	return s.inTx(ctx, func(tx pgx.Tx) error {
+		before, err := lockLiveCustomer(ctx, tx, tenantID, id)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(ctx, `
+			update customers
+			set deleted_at = $3
+			where tenant_id = $1 and id = $2
+		`, tenantID, id, parseTime(deletedAt)); err != nil {
+			return err
+		}
+		after := before
+		after.DeletedAt = deletedAt
+		return logChange(ctx, tx, tenantID, api.AuditEntityCustomer, id, api.AuditCustomerDeleted, before, after)
+	})
+}
+
+// lockLiveCustomer reads a customer that is not soft-deleted for update.
+func lockLiveCustomer(ctx context.Context, tx pgx.Tx, tenantID, id string) (api.Customer, error) {
+	return scanCustomer(tx.QueryRow(ctx, `
+		select `+customerColumns+`
+		from customers
+		where tenant_id = $1 and id = $2 and deleted_at is null
+		for update
+	`, tenantID, id))
+}
+
+type customerScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanCustomer(row customerScanner) (api.Customer, error) {
+	var item api.Customer
+	var address []byte
+	var createdAt, updatedAt time.Time
+	var deletedAt sql.NullTime
+	if err := row.Scan(&item.ID, &item.TenantID, &item.Name, &item.Email, &address, &item.TaxID, &item.DefaultCurrency, &createdAt, &updatedAt, &deletedAt, &item.TaxExempt); err != nil {
+		return api.Customer{}, mapScanError(err)
+	}
+	if err := json.Unmarshal(address, &item.BillingAddress); err != nil {
+		return api.Customer{}, fmt.Errorf("unmarshal billing address: %w", err)
+	}
+	item.CreatedAt = createdAt.UTC().Format(time.RFC3339)
+	item.UpdatedAt = updatedAt.UTC().Format(time.RFC3339)
+	item.DeletedAt = nullableTimeString(deletedAt)
+	return item, nil
+}
@@ -0,0 +1,104 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"os"
+	"slices"
+	"testing"
+
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/api"
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/invoice"
+)
+
+func TestPostgresStoreCustomers(t *testing.T) {
+	databaseURL := os.Getenv("DATABASE_URL")
+	if databaseURL == "" {
+		t.Skip("DATABASE_URL is required for postgres integration tests")
+	}
+
+	testDatabaseURL := createTestDatabase(t, databaseURL)
+	ctx := context.Background()
+	store, err := Open(ctx, testDatabaseURL)
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	defer store.Close()
+	if err := store.Migrate(ctx); err != nil {
+		t.Fatalf("migrate store: %v", err)
+	}
+
+	for _, id := range []string{"cust-1", "cust-2"} {
+		customer := api.Customer{
+			ID:              id,
+			TenantID:        "tenant-a",
+			Name:            "Acme Ltd",
+			Email:           "billing@acme.example",
+			BillingAddress:  api.Address{Line1: "1 Main St", City: "Tel Aviv", Country: "IL"},
+			DefaultCurrency: "ILS",
+			CreatedAt:       nowRFC3339(),
+			UpdatedAt:       nowRFC3339(),
+		}
+		if err := store.CreateCustomer(ctx, customer); err != nil {
+			t.Fatalf("create customer %s: %v", id, err)
+		}
+	}
+	got, err := store.GetCustomer(ctx, "tenant-a", "cust-1")
+	if err != nil || got.BillingAddress.City != "Tel Aviv" || got.DeletedAt != "" {
+		t.Fatalf("unexpected customer %#v, %v", got, err)
+	}
+	if _, err := store.GetCustomer(ctx, "tenant-b", "cust-1"); !errors.Is(err, api.ErrNotFound) {
+		t.Fatalf("expected other tenant lookup to miss, got %v", err)
+	}
+	got.TaxID = "IL-514000000"
+	if err := store.UpdateCustomer(ctx, got); err != nil {
+		t.Fatalf("update customer: %v", err)
+	}
+
+	if _, err := store.CreateInvoice(ctx, invoice.Invoice{
+		ID:         "inv-1",
+		TenantID:   "tenant-a",
+		Number:     "INV-0001",
+		CustomerID: "cust-1",
+		Currency:   "ILS",
+		Status:     invoice.StatusDraft,
+		CreatedAt:  nowRFC3339(),
+		UpdatedAt:  nowRFC3339(),
+	}, invoice.DefaultNumberFormat); err != nil {
+		t.Fatalf("create invoice: %v", err)
+	}
+	if err := store.SoftDeleteCustomer(ctx, "tenant-a", "cust-1", nowRFC3339()); err != nil {
+		t.Fatalf("soft delete customer: %v", err)
+	}
+	if got, err := store.GetCustomer(ctx, "tenant-a", "cust-1"); err != nil || got.DeletedAt == "" || got.TaxID != "IL-514000000" {
+		t.Fatalf("expected soft-deleted customer to stay readable, got %#v, %v", got, err)
+	}
+	if err := store.SoftDeleteCustomer(ctx, "tenant-a", "cust-1", nowRFC3339()); !errors.Is(err, api.ErrNotFound) {
+		t.Fatalf("expected a second delete to miss, got %v", err)
+	}
+	if err := store.UpdateCustomer(ctx, got); !errors.Is(err, api.ErrNotFound) {
+		t.Fatalf("expected soft-deleted customer update to miss, got %v", err)
+	}
+	items, err := store.ListCustomers(ctx, "tenant-a", false)
+	if err != nil || len(items) != 1 || items[0].ID != "cust-2" {
+		t.Fatalf("expected only the live customer, got %#v, %v", items, err)
+	}
+	if items, err := store.ListCustomers(ctx, "tenant-a", true); err != nil || len(items) != 2 {
+		t.Fatalf("expected deleted customers to be included, got %#v, %v", items, err)
+	}
+
+	history, err := store.ListAuditLog(ctx, "tenant-a", api.AuditEntityCustomer, "cust-1")
+	if err != nil {
+		t.Fatalf("list audit log: %v", err)
+	}
+	actions := make([]string, len(history))
+	for i, entry := range history {
+		actions[i] = entry.Action
+	}
+	if !slices.Equal(actions, []string{api.AuditCustomerCreated, api.AuditCustomerUpdated, api.AuditCustomerDeleted}) {
+		t.Fatalf("unexpected customer history %v", actions)
+	}
+	if change := history[1].Changes["taxId"]; change.Before != "" || change.After != "IL-514000000" || len(history[1].Changes) != 1 {
+		t.Fatalf("expected the update to change only taxId, got %#v", history[1].Changes)
+	}
+}
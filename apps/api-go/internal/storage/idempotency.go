@@ -0,0 +1,87 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/api"
+)
+
+// ClaimIdempotencyKey inserts the claim, or takes over a record whose TTL has
+// lapsed. The conditional upsert makes concurrent claims of the same key
+// race-free: exactly one caller gets a row back.
+func (s *PostgresStore) ClaimIdempotencyKey(ctx context.Context, record api.IdempotencyRecord) (api.IdempotencyRecord, bool, error) {
+	tag, err := s.pool.Exec(ctx, `
+		insert into idempotency_keys (tenant_id, scope, key, request_hash, status_code, content_type, response_body, created_at, expires_at)
+		values ($1, $2, $3, $6, 0, null, null, $4, $5)
+		on conflict (tenant_id, scope, key) do update
+		set request_hash = excluded.request_hash,
+			status_code = 0,
+			content_type = null,
+			response_headers = '{}',
+			response_body = null,
+			created_at = excluded.created_at,
+			expires_at = excluded.expires_at
+		where idempotency_keys.expires_at <= excluded.created_at
+	`, record.TenantID, record.Scope, record.Key, parseTime(record.CreatedAt), parseTime(record.ExpiresAt), record.RequestHash)
+	if err != nil {
+		return api.IdempotencyRecord{}, false, err
+	}
+	if tag.RowsAffected() == 1 {
+		return record, true, nil
+	}
+
+	existing := api.IdempotencyRecord{TenantID: record.TenantID, Scope: record.Scope, Key: record.Key}
+	var contentType sql.NullString
+	var headers []byte
+	var createdAt, expiresAt time.Time
+	err = s.pool.QueryRow(ctx, `
+		select request_hash, status_code, content_type, response_headers, response_body, created_at, expires_at
+		from idempotency_keys
+		where tenant_id = $1 and scope = $2 and key = $3
+	`, record.TenantID, record.Scope, record.Key).Scan(&existing.RequestHash, &existing.StatusCode, &contentType, &headers, &existing.Body, &createdAt, &expiresAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		// Released between our insert attempt and the read; let the caller
+		// try again rather than proceeding unguarded.
+		return api.IdempotencyRecord{}, false, api.ErrConflict
+	}
+	if err != nil {
+		return api.IdempotencyRecord{}, false, err
+	}
+	existing.ContentType = contentType.String
+	if err := json.Unmarshal(headers, &existing.Headers); err != nil {
+		return api.IdempotencyRecord{}, false, err
+	}
+	existing.CreatedAt = createdAt.UTC().Format(time.RFC3339)
+	existing.ExpiresAt = expiresAt.UTC().Format(time.RFC3339)
+	return existing, false, nil
+}
+
+func (s *PostgresStore) CompleteIdempotencyKey(ctx context.Context, record api.IdempotencyRecord) error {
+	headers := record.Headers
+	if headers == nil {
+		headers = map[string]string{}
+	}
+	headersJSON, err := json.Marshal(headers)
+	if err != nil {
+		return err
+	}
+	tag, err := s.pool.Exec(ctx, `
+		update idempotency_keys
+		set status_code = $4, content_type = $5, response_headers = $6, response_body = $7, expires_at = $8
+		where tenant_id = $1 and scope = $2 and key = $3
+	`, record.TenantID, record.Scope, record.Key, record.StatusCode, nullString(record.ContentType), headersJSON, record.Body, parseTime(record.ExpiresAt))
+	return rowsAffectedOrNotFound(tag, err)
+}
+
+func (s *PostgresStore) ReleaseIdempotencyKey(ctx context.Context, tenantID, scope, key string) error {
+	_, err := s.pool.Exec(ctx, `
+		delete from idempotency_keys
+		where tenant_id = $1 and scope = $2 and key = $3 and status_code = 0
+	`, tenantID, scope, key)
+	return err
+}
@@ -0,0 +1,64 @@
+package storage
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/api"
+)
+
+func TestPostgresStoreIdempotencyKeys(t *testing.T) {
+	databaseURL := os.Getenv("DATABASE_URL")
+	if databaseURL == "" {
+		t.Skip("DATABASE_URL is required for postgres integration tests")
+	}
+
+	testDatabaseURL := createTestDatabase(t, databaseURL)
+	ctx := context.Background()
+	store, err := Open(ctx, testDatabaseURL)
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	defer store.Close()
+	if err := store.Migrate(ctx); err != nil {
+		t.Fatalf("migrate store: %v", err)
+	}
+
+	now := time.Now().UTC()
+	claim := api.IdempotencyRecord{
+		TenantID:    "tenant-a",
+		Scope:       "POST /v1/collection-jobs/job-1/retry",
+		Key:         "key-1",
+		RequestHash: "3f2a",
+		CreatedAt:   now.Format(time.RFC3339),
+		ExpiresAt:   now.Add(24 * time.Hour).Format(time.RFC3339),
+	}
+	if _, claimed, err := store.ClaimIdempotencyKey(ctx, claim); err != nil || !claimed {
+		t.Fatalf("expected first claim to win, claimed=%v err=%v", claimed, err)
+	}
+	if existing, claimed, err := store.ClaimIdempotencyKey(ctx, claim); err != nil || claimed || existing.StatusCode != 0 {
+		t.Fatalf("expected pending claim to block, got %#v claimed=%v err=%v", existing, claimed, err)
+	}
+
+	claim.StatusCode = 202
+	claim.ContentType = "application/json"
+	claim.Headers = map[string]string{"Location": "/v1/collection-jobs/job-2"}
+	claim.Body = []byte(`{"id":"job-2"}`)
+	if err := store.CompleteIdempotencyKey(ctx, claim); err != nil {
+		t.Fatalf("complete claim: %v", err)
+	}
+	existing, claimed, err := store.ClaimIdempotencyKey(ctx, claim)
+	if err != nil || claimed || existing.StatusCode != 202 || string(existing.Body) != `{"id":"job-2"}` || existing.RequestHash != "3f2a" ||
+		existing.Headers["Location"] != "/v1/collection-jobs/job-2" {
+		t.Fatalf("expected stored response, got %#v claimed=%v err=%v", existing, claimed, err)
+	}
+
+	later := claim
+	later.CreatedAt = now.Add(25 * time.Hour).Format(time.RFC3339)
+	later.ExpiresAt = now.Add(49 * time.Hour).Format(time.RFC3339)
+	if _, claimed, err := store.ClaimIdempotencyKey(ctx, later); err != nil || !claimed {
+		t.Fatalf("expected expired key to be reclaimable, claimed=%v err=%v", claimed, err)
+	}
+}
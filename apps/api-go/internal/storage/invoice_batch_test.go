@@ -0,0 +1,120 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"testing"
+
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/api"
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/invoice"
+)
+
+func TestPostgresStoreCreatesInvoiceBatches(t *testing.T) {
+	store := openBatchTestStore(t)
+	ctx := context.Background()
+
+	if _, err := store.CreateInvoice(ctx, batchInvoice("inv-0", "INV-0000"), invoice.DefaultNumberFormat); err != nil {
+		t.Fatalf("create invoice: %v", err)
+	}
+	batch := []invoice.Invoice{batchInvoice("inv-1", "INV-0001"), batchInvoice("inv-2", "INV-0000"), batchInvoice("inv-3", "INV-0001"), batchInvoice("inv-4", "INV-0004")}
+
+	taken, err := store.CreateInvoices(ctx, batch, invoice.DefaultNumberFormat, true)
+	if err != nil || !slices.Equal(taken, []int{1, 2}) {
+		t.Fatalf("expected the stored and repeated numbers taken, got %v, %v", taken, err)
+	}
+	if _, err := store.GetInvoice(ctx, "tenant-a", "inv-1"); !errors.Is(err, api.ErrNotFound) {
+		t.Fatalf("expected an atomic batch with taken numbers to write nothing, got %v", err)
+	}
+
+	taken, err = store.CreateInvoices(ctx, batch, invoice.DefaultNumberFormat, false)
+	if err != nil || !slices.Equal(taken, []int{1, 2}) {
+		t.Fatalf("expected the same numbers taken in a partial batch, got %v, %v", taken, err)
+	}
+	for _, id := range []string{"inv-1", "inv-4"} {
+		got, err := store.GetInvoice(ctx, "tenant-a", id)
+		if err != nil || len(got.Items) != 1 || got.Items[0].Description != "Hosting" {
+			t.Fatalf("expected %s stored with its line, got %#v, %v", id, got, err)
+		}
+		history, err := store.ListAuditLog(ctx, "tenant-a", api.AuditEntityInvoice, id)
+		if err != nil || len(history) != 1 || history[0].Action != api.AuditInvoiceCreated {
+			t.Fatalf("expected %s creation logged, got %#v, %v", id, history, err)
+		}
+	}
+	if _, err := store.GetInvoice(ctx, "tenant-a", "inv-3"); !errors.Is(err, api.ErrNotFound) {
+		t.Fatalf("expected the repeated number skipped, got %v", err)
+	}
+}
+
+// BenchmarkPostgresStoreCreateInvoices compares one batch of 500 invoices
+// with creating them one by one.
+func BenchmarkPostgresStoreCreateInvoices(b *testing.B) {
+	store := openBatchTestStore(b)
+	ctx := context.Background()
+	created := 0
+	batch := func() []invoice.Invoice {
+		items := make([]invoice.Invoice, api.MaxInvoiceBatchSize)
+		for i := range items {
+			created++
+			id := fmt.Sprintf("inv-%d", created)
+			items[i] = batchInvoice(id, id)
+		}
+		return items
+	}
+
+	b.Run("batch", func(b *testing.B) {
+		for range b.N {
+			if _, err := store.CreateInvoices(ctx, batch(), invoice.DefaultNumberFormat, true); err != nil {
+				b.Fatalf("create invoices: %v", err)
+			}
+		}
+	})
+	b.Run("loop", func(b *testing.B) {
+		for range b.N {
+			for _, item := range batch() {
+				if _, err := store.CreateInvoice(ctx, item, invoice.DefaultNumberFormat); err != nil {
+					b.Fatalf("create invoice: %v", err)
+				}
+			}
+		}
+	})
+}
+
+func openBatchTestStore(t testing.TB) *PostgresStore {
+	t.Helper()
+
+	databaseURL := os.Getenv("DATABASE_URL")
+	if databaseURL == "" {
+		t.Skip("DATABASE_URL is required for postgres integration tests")
+	}
+	ctx := context.Background()
+	store, err := Open(ctx, createTestDatabase(t, databaseURL))
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	t.Cleanup(store.Close)
+	if err := store.Migrate(ctx); err != nil {
+		t.Fatalf("migrate store: %v", err)
+	}
+	return store
+}
+
+func batchInvoice(id, number string) invoice.Invoice {
+	return invoice.Invoice{
+		ID:         id,
+		TenantID:   "tenant-a",
+		Number:     number,
+		CustomerID: "cust-1",
+		Currency:   "ILS",
+		Subtotal:   1000,
+		Total:      1000,
+		Status:     invoice.StatusDraft,
+		Items: []invoice.LineItem{{
+			ID: id + "-line-1", InvoiceID: id, Position: 1, Description: "Hosting", Quantity: 1, UnitPrice: 1000, Amount: 1000, CreatedAt: nowRFC3339(),
+		}},
+		CreatedAt: nowRFC3339(),
+		UpdatedAt: nowRFC3339(),
+	}
+}
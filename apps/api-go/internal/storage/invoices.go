@@ -0,0 +1,607 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/api"
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/invoice"
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/tax"
+)
+
+const invoiceColumns = `id, tenant_id, number, customer_id, currency, subtotal, tax, total, status, issued_at, due_at, paid_at, payment_reference, created_at, updated_at, tax_rate, tax_rounding, tax_exempt, amount_paid, deleted_at, version, voided_at, void_reason, amount_credited`
+
+// invoiceIssuedSortKey must match the expression index in
+// 003_invoice_list_indexes.sql so keyset scans stay index-only ordered.
+const invoiceIssuedSortKey = `coalesce(issued_at, '-infinity'::timestamptz)`
+
+func (s *PostgresStore) ListInvoices(ctx context.Context, tenantID string, filter invoice.ListFilter) ([]invoice.Invoice, error) {
+	query, args := buildInvoiceListQuery(tenantID, filter)
+	rows, err := s.pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []invoice.Invoice{}
+	for rows.Next() {
+		item, err := scanInvoice(rows)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+func buildInvoiceListQuery(tenantID string, filter invoice.ListFilter) (string, []any) {
+	args := []any{tenantID}
+	where := []string{"tenant_id = $1"}
+	arg := func(value any) string {
+		args = append(args, value)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	if !filter.IncludeDeleted {
+		where = append(where, "deleted_at is null")
+	}
+	if filter.Status != "" {
+		where = append(where, "status = "+arg(string(filter.Status)))
+	}
+	if filter.CustomerID != "" {
+		where = append(where, "customer_id = "+arg(filter.CustomerID))
+	}
+	if filter.IssuedFrom != "" {
+		where = append(where, "issued_at >= "+arg(parseTime(filter.IssuedFrom)))
+	}
+	if filter.IssuedTo != "" {
+		where = append(where, "issued_at <= "+arg(parseTime(filter.IssuedTo)))
+	}
+
+	sortKey := invoiceIssuedSortKey
+	if filter.Sort == invoice.SortTotal {
+		sortKey = "total"
+	}
+	direction, comparator := "asc", ">"
+	if filter.Descending {
+		direction, comparator = "desc", "<"
+	}
+	if cursor := filter.After; cursor != nil {
+		var key any = cursor.Total
+		if filter.Sort != invoice.SortTotal {
+			issuedAt := pgtype.Timestamptz{InfinityModifier: pgtype.NegativeInfinity, Valid: true}
+			if cursor.IssuedAt != "" {
+				issuedAt = pgtype.Timestamptz{Time: parseTime(cursor.IssuedAt), Valid: true}
+			}
+			key = issuedAt
+		}
+		where = append(where, fmt.Sprintf(`(%s, id collate "C") %s (%s, %s)`, sortKey, comparator, arg(key), arg(cursor.ID)))
+	}
+
+	query := `select ` + invoiceColumns + `
+		from invoices
+		where ` + strings.Join(where, " and ") + `
+		order by ` + sortKey + ` ` + direction + `, id collate "C" ` + direction
+	if filter.Limit > 0 {
+		query += ` limit ` + arg(filter.Limit)
+	}
+	return query, args
+}
+
+func (s *PostgresStore) GetInvoice(ctx context.Context, tenantID, id string) (invoice.Invoice, error) {
+	row := s.pool.QueryRow(ctx, `
+		select `+invoiceColumns+`
+		from invoices
+		where tenant_id = $1 and id = $2
+	`, tenantID, id)
+	item, err := scanInvoice(row)
+	if err != nil {
+		return invoice.Invoice{}, err
+	}
+	item.Items, err = listLineItems(ctx, s.pool, tenantID, id)
+	if err != nil {
+		return invoice.Invoice{}, err
+	}
+	return item, nil
+}
+
+func (s *PostgresStore) CreateInvoice(ctx context.Context, item invoice.Invoice, numbering invoice.NumberFormat) (invoice.Invoice, error) {
+	var created invoice.Invoice
+	err := s.inTx(ctx, func(tx pgx.Tx) error {
+		created = item
+		if err := numberInvoices(ctx, tx, []*invoice.Invoice{&created}, numbering, nil); err != nil {
+			return err
+		}
+		return insertInvoice(ctx, tx, created)
+	})
+	if err != nil {
+		return invoice.Invoice{}, err
+	}
+	return created, nil
+}
+
+// insertInvoice writes a new invoice with its lines and logs its creation.
+func insertInvoice(ctx context.Context, tx pgx.Tx, item invoice.Invoice) error {
+	_, err := tx.Exec(ctx, `
+		insert into invoices (`+invoiceColumns+`)
+		values ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21, $22, $23, $24)
+	`, invoiceValues(item)...)
+	if err != nil {
+		return mapWriteError(err)
+	}
+	for _, line := range item.Items {
+		if err := insertLineItem(ctx, tx, item.TenantID, line); err != nil {
+			return err
+		}
+	}
+	return logChange(ctx, tx, item.TenantID, api.AuditEntityInvoice, item.ID, api.AuditInvoiceCreated, nil, item)
+}
+
+func (s *PostgresStore) StoredInvoiceNumbers(ctx context.Context, tenantID string, numbers []string) (map[string]bool, error) {
+	rows, err := s.pool.Query(ctx, `
+		select number
+		from invoices
+		where tenant_id = $1 and number = any($2)
+	`, tenantID, numbers)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	stored := map[string]bool{}
+	for rows.Next() {
+		var number string
+		if err := rows.Scan(&number); err != nil {
+			return nil, err
+		}
+		stored[number] = true
+	}
+	return stored, rows.Err()
+}
+
+// CreateInvoices first looks up which numbers are taken and numbers the
+// unnumbered invoices, then copies the remaining invoices, their lines and
+// their audit log entries in with one COPY per table. A number taken by a
+// concurrent writer after the lookup fails the whole copy with ErrConflict.
+func (s *PostgresStore) CreateInvoices(ctx context.Context, items []invoice.Invoice, numbering invoice.NumberFormat, atomic bool) ([]int, error) {
+	var taken []int
+	original := slices.Clone(items)
+	err := s.inTx(ctx, func(tx pgx.Tx) error {
+		// Numbers handed out by an attempt that rolled back are taken
+		// back, so every attempt numbers the invoices as passed.
+		copy(items, original)
+		tenants := make([]string, len(items))
+		numbers := make([]string, len(items))
+		for i, item := range items {
+			tenants[i], numbers[i] = item.TenantID, item.Number
+		}
+		rows, err := tx.Query(ctx, `
+			select tenant_id, number
+			from invoices
+			where (tenant_id, number) in (select * from unnest($1::text[], $2::text[]))
+		`, tenants, numbers)
+		if err != nil {
+			return err
+		}
+		stored := map[[2]string]bool{}
+		for rows.Next() {
+			var key [2]string
+			if err := rows.Scan(&key[0], &key[1]); err != nil {
+				return err
+			}
+			stored[key] = true
+		}
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		taken = api.TakenInvoiceNumbers(items, func(item invoice.Invoice) bool {
+			return stored[[2]string{item.TenantID, item.Number}]
+		})
+		if len(taken) > 0 && atomic {
+			return nil
+		}
+		var unnumbered []*invoice.Invoice
+		reserved := map[[2]string]bool{}
+		for i := range items {
+			switch {
+			case slices.Contains(taken, i):
+			case items[i].Number == "":
+				unnumbered = append(unnumbered, &items[i])
+			default:
+				reserved[[2]string{items[i].TenantID, items[i].Number}] = true
+			}
+		}
+		if err := numberInvoices(ctx, tx, unnumbered, numbering, reserved); err != nil {
+			return err
+		}
+
+		var invoiceRows, lineRows, auditRows [][]any
+		for i, item := range items {
+			if slices.Contains(taken, i) {
+				continue
+			}
+			invoiceRows = append(invoiceRows, invoiceValues(item))
+			for _, line := range item.Items {
+				lineRows = append(lineRows, lineItemValues(item.TenantID, line))
+			}
+			entry, err := auditLogValues(ctx, item.TenantID, api.AuditEntityInvoice, item.ID, api.AuditInvoiceCreated, nil, item)
+			if err != nil {
+				return err
+			}
+			auditRows = append(auditRows, entry)
+		}
+		for _, table := range []struct {
+			name    string
+			columns string
+			rows    [][]any
+		}{
+			{"invoices", invoiceColumns, invoiceRows},
+			{"invoice_line_items", "tenant_id, " + lineItemColumns, lineRows},
+			{"audit_log", auditLogColumns, auditRows},
+		} {
+			if len(table.rows) == 0 {
+				continue
+			}
+			if _, err := tx.CopyFrom(ctx, pgx.Identifier{table.name}, strings.Split(table.columns, ", "), pgx.CopyFromRows(table.rows)); err != nil {
+				return mapWriteError(err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return taken, nil
+}
+
+func invoiceValues(item invoice.Invoice) []any {
+	return []any{item.ID, item.TenantID, item.Number, item.CustomerID, item.Currency, item.Subtotal, item.Tax, item.Total, string(item.Status), nullTime(item.IssuedAt), nullTime(item.DueAt),
+		nullTime(item.PaidAt), nullString(item.PaymentReference), parseTime(item.CreatedAt), parseTime(item.UpdatedAt), item.TaxRate, taxRounding(item.TaxRounding), item.TaxExempt, item.AmountPaid,
+		nullTime(item.DeletedAt), item.Version, nullTime(item.VoidedAt), nullString(item.VoidReason), item.AmountCredited}
+}
+
+// UpdateInvoice locks the stored invoice first so the audit log diffs
+// against the row this update replaces.
+func (s *PostgresStore) UpdateInvoice(ctx context.Context, update *invoice.Invoice, numbering invoice.NumberFormat) error {
+	var number string
+	err := s.inTx(ctx, func(tx pgx.Tx) error {
+		item := *update
+		before, err := lockLiveInvoice(ctx, tx, item.TenantID, item.ID)
+		if err != nil {
+			return err
+		}
+		if before.Version != item.Version {
+			return api.ErrVersionMismatch
+		}
+		if err := numberInvoices(ctx, tx, []*invoice.Invoice{&item}, numbering, nil); err != nil {
+			return err
+		}
+		number = item.Number
+		if before.Items, err = listLineItems(ctx, tx, item.TenantID, item.ID); err != nil {
+			return err
+		}
+		item.Version++
+		if err := updateInvoiceHeader(ctx, tx, item); err != nil {
+			return err
+		}
+		for _, line := range item.Items {
+			if _, err := tx.Exec(ctx, `
+				update invoice_line_items set tax = $4
+				where tenant_id = $1 and invoice_id = $2 and id = $3
+			`, item.TenantID, item.ID, line.ID, line.Tax); err != nil {
+				return err
+			}
+		}
+		item.AmountPaid = before.AmountPaid
+		item.AmountCredited = before.AmountCredited
+		item.DeletedAt = before.DeletedAt
+		return logChange(ctx, tx, item.TenantID, api.AuditEntityInvoice, item.ID, api.AuditInvoiceUpdated, before, item)
+	})
+	if err != nil {
+		return err
+	}
+	update.Number = number
+	return nil
+}
+
+// lockLiveInvoice reads an invoice that is not soft-deleted for update,
+// without its line items.
+func lockLiveInvoice(ctx context.Context, tx pgx.Tx, tenantID, id string) (invoice.Invoice, error) {
+	return scanInvoice(tx.QueryRow(ctx, `
+		select `+invoiceColumns+`
+		from invoices
+		where tenant_id = $1 and id = $2 and deleted_at is null
+		for update
+	`, tenantID, id))
+}
+
+func updateInvoiceHeader(ctx context.Context, tx pgx.Tx, item invoice.Invoice) error {
+	tag, err := tx.Exec(ctx, `
+		update invoices
+		set number = $3,
+			customer_id = $4,
+			currency = $5,
+			subtotal = $6,
+			tax = $7,
+			total = $8,
+			status = $9,
+			issued_at = $10,
+			due_at = $11,
+			paid_at = $12,
+			payment_reference = $13,
+			updated_at = $14,
+			tax_rate = $15,
+			tax_rounding = $16,
+			tax_exempt = $17,
+			version = $18,
+			voided_at = $19,
+			void_reason = $20
+		where id = $1 and tenant_id = $2 and deleted_at is null
+	`, item.ID, item.TenantID, item.Number, item.CustomerID, item.Currency, item.Subtotal, item.Tax, item.Total, string(item.Status), nullTime(item.IssuedAt), nullTime(item.DueAt),
+		nullTime(item.PaidAt), nullString(item.PaymentReference), parseTime(item.UpdatedAt), item.TaxRate, taxRounding(item.TaxRounding), item.TaxExempt, item.Version,
+		nullTime(item.VoidedAt), nullString(item.VoidReason))
+	return rowsAffectedOrNotFound(tag, mapWriteError(err))
+}
+
+// MarkInvoicesOverdue locks the due rows with for update skip locked, so
+// replicas running the scan at the same time each move a disjoint set. The
+// update returns each row's previous updated_at for the audit log.
+func (s *PostgresStore) MarkInvoicesOverdue(ctx context.Context, now string, limit int) ([]invoice.Invoice, error) {
+	var items []invoice.Invoice
+	err := s.inTx(ctx, func(tx pgx.Tx) error {
+		rows, err := tx.Query(ctx, `
+			with due as (
+				select tenant_id as due_tenant_id, id as due_id, updated_at as due_updated_at
+				from invoices
+				where status = 'open' and due_at < $1 and deleted_at is null
+				order by due_at
+				limit $2
+				for update skip locked
+			)
+			update invoices
+			set status = 'overdue', updated_at = $1, version = version + 1
+			from due
+			where tenant_id = due.due_tenant_id and id = due.due_id
+			returning `+invoiceColumns+`, due.due_updated_at
+		`, parseTime(now), limit)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		items = []invoice.Invoice{}
+		var befores []invoice.Invoice
+		for rows.Next() {
+			var previousUpdatedAt time.Time
+			item, err := scanInvoice(extraScanner{row: rows, extra: []any{&previousUpdatedAt}})
+			if err != nil {
+				return err
+			}
+			before := item
+			before.Status = invoice.StatusOpen
+			before.UpdatedAt = previousUpdatedAt.UTC().Format(time.RFC3339)
+			before.Version--
+			items = append(items, item)
+			befores = append(befores, before)
+		}
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		rows.Close()
+		for i, item := range items {
+			if err := logChange(ctx, tx, item.TenantID, api.AuditEntityInvoice, item.ID, api.AuditInvoiceOverdue, befores[i], item); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+func (s *PostgresStore) SoftDeleteInvoice(ctx context.Context, tenantID, id, deletedAt string) error {
+	return s.inTx(ctx, func(tx pgx.Tx) error {
+		before, err := lockLiveInvoice(ctx, tx, tenantID, id)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(ctx, `
+			update invoices set deleted_at = $3, version = version + 1
+			where tenant_id = $1 and id = $2
+		`, tenantID, id, parseTime(deletedAt)); err != nil {
+			return err
+		}
+		after := before
+		after.DeletedAt = deletedAt
+		after.Version++
+		return logChange(ctx, tx, tenantID, api.AuditEntityInvoice, id, api.AuditInvoiceDeleted, before, after)
+	})
+}
+
+func (s *PostgresStore) AddInvoiceLineItem(ctx context.Context, tenantID string, item invoice.LineItem) (invoice.Invoice, error) {
+	return s.changeLineItems(ctx, tenantID, item.InvoiceID, item.CreatedAt, api.AuditInvoiceItemAdded, func(tx pgx.Tx, current []invoice.LineItem) error {
+		item.Position = 1
+		if n := len(current); n > 0 {
+			item.Position = current[n-1].Position + 1
+		}
+		return insertLineItem(ctx, tx, tenantID, item)
+	})
+}
+
+func (s *PostgresStore) DeleteInvoiceLineItem(ctx context.Context, tenantID, invoiceID, itemID, updatedAt string) (invoice.Invoice, error) {
+	return s.changeLineItems(ctx, tenantID, invoiceID, updatedAt, api.AuditInvoiceItemRemoved, func(tx pgx.Tx, _ []invoice.LineItem) error {
+		tag, err := tx.Exec(ctx, `
+			delete from invoice_line_items
+			where tenant_id = $1 and invoice_id = $2 and id = $3
+		`, tenantID, invoiceID, itemID)
+		return rowsAffectedOrNotFound(tag, err)
+	})
+}
+
+// changeLineItems runs change against a row-locked draft invoice and then
+// rewrites the invoice totals from the resulting items in the same
+// transaction, so concurrent edits can neither skip the draft check nor leave
+// totals that disagree with the stored lines. The change is logged as action.
+func (s *PostgresStore) changeLineItems(ctx context.Context, tenantID, invoiceID, updatedAt, action string, change func(pgx.Tx, []invoice.LineItem) error) (invoice.Invoice, error) {
+	var item invoice.Invoice
+	err := s.inTx(ctx, func(tx pgx.Tx) error {
+		var err error
+		item, err = lockLiveInvoice(ctx, tx, tenantID, invoiceID)
+		if err != nil {
+			return err
+		}
+		if item.Status != invoice.StatusDraft {
+			return invoice.ErrNotDraft
+		}
+		current, err := listLineItems(ctx, tx, tenantID, invoiceID)
+		if err != nil {
+			return err
+		}
+		before := item
+		before.Items = current
+		if err := change(tx, current); err != nil {
+			return err
+		}
+		items, err := listLineItems(ctx, tx, tenantID, invoiceID)
+		if err != nil {
+			return err
+		}
+		stored := make([]int64, len(items))
+		for i, line := range items {
+			stored[i] = line.Tax
+		}
+		item.SetLineItems(items)
+		// Invoice-level rounding can move a unit of tax between lines when
+		// one is added or removed.
+		for i, line := range item.Items {
+			if line.Tax == stored[i] {
+				continue
+			}
+			if _, err := tx.Exec(ctx, `
+				update invoice_line_items set tax = $3
+				where tenant_id = $1 and id = $2
+			`, tenantID, line.ID, line.Tax); err != nil {
+				return err
+			}
+		}
+		item.UpdatedAt = updatedAt
+		item.Version++
+		_, err = tx.Exec(ctx, `
+			update invoices
+			set subtotal = $3, tax = $4, total = $5, updated_at = $6, version = version + 1
+			where tenant_id = $1 and id = $2
+		`, tenantID, invoiceID, item.Subtotal, item.Tax, item.Total, parseTime(updatedAt))
+		if err != nil {
+			return err
+		}
+		return logChange(ctx, tx, tenantID, api.AuditEntityInvoice, invoiceID, action, before, item)
+	})
+	if err != nil {
+		return invoice.Invoice{}, err
+	}
+	return item, nil
+}
+
+const lineItemColumns = `id, invoice_id, position, description, quantity, unit_price, tax_rate, amount, tax, created_at`
+
+type queryer interface {
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+}
+
+func listLineItems(ctx context.Context, db queryer, tenantID, invoiceID string) ([]invoice.LineItem, error) {
+	rows, err := db.Query(ctx, `
+		select `+lineItemColumns+`
+		from invoice_line_items
+		where tenant_id = $1 and invoice_id = $2
+		order by position
+	`, tenantID, invoiceID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []invoice.LineItem
+	for rows.Next() {
+		var item invoice.LineItem
+		var createdAt time.Time
+		if err := rows.Scan(&item.ID, &item.InvoiceID, &item.Position, &item.Description, &item.Quantity, &item.UnitPrice, &item.TaxRate, &item.Amount, &item.Tax, &createdAt); err != nil {
+			return nil, err
+		}
+		item.CreatedAt = createdAt.UTC().Format(time.RFC3339)
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+func insertLineItem(ctx context.Context, tx pgx.Tx, tenantID string, item invoice.LineItem) error {
+	_, err := tx.Exec(ctx, `
+		insert into invoice_line_items (tenant_id, `+lineItemColumns+`)
+		values ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+	`, lineItemValues(tenantID, item)...)
+	return mapWriteError(err)
+}
+
+func lineItemValues(tenantID string, item invoice.LineItem) []any {
+	return []any{tenantID, item.ID, item.InvoiceID, item.Position, item.Description, item.Quantity, item.UnitPrice, item.TaxRate, item.Amount, item.Tax, parseTime(item.CreatedAt)}
+}
+
+type invoiceScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanInvoice(row invoiceScanner) (invoice.Invoice, error) {
+	var item invoice.Invoice
+	var status string
+	var issuedAt sql.NullTime
+	var dueAt sql.NullTime
+	var paidAt sql.NullTime
+	var paymentReference sql.NullString
+	var createdAt time.Time
+	var updatedAt time.Time
+	var rounding string
+	var deletedAt sql.NullTime
+	var voidedAt sql.NullTime
+	var voidReason sql.NullString
+	err := row.Scan(&item.ID, &item.TenantID, &item.Number, &item.CustomerID, &item.Currency, &item.Subtotal, &item.Tax, &item.Total, &status, &issuedAt, &dueAt, &paidAt, &paymentReference, &createdAt, &updatedAt,
+		&item.TaxRate, &rounding, &item.TaxExempt, &item.AmountPaid, &deletedAt, &item.Version, &voidedAt, &voidReason, &item.AmountCredited)
+	if err != nil {
+		return invoice.Invoice{}, mapScanError(err)
+	}
+	item.Status = invoice.Status(status)
+	item.TaxRounding = tax.Rounding(rounding)
+	item.IssuedAt = nullableTimeString(issuedAt)
+	item.DueAt = nullableTimeString(dueAt)
+	item.PaidAt = nullableTimeString(paidAt)
+	item.PaymentReference = paymentReference.String
+	item.CreatedAt = createdAt.UTC().Format(time.RFC3339)
+	item.UpdatedAt = updatedAt.UTC().Format(time.RFC3339)
+	item.DeletedAt = nullableTimeString(deletedAt)
+	item.VoidedAt = nullableTimeString(voidedAt)
+	item.VoidReason = voidReason.String
+	return item, nil
+}
+
+// mapWriteError turns unique-constraint violations into api.ErrConflict so
+// handlers can answer 409 without knowing about Postgres error codes.
+func mapWriteError(err error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+		return api.ErrConflict
+	}
+	return err
+}
+
+// taxRounding stores an unset rounding as line rounding, which is what the
+// tax engine applies to it.
+func taxRounding(rounding tax.Rounding) string {
+	if rounding == "" {
+		return string(tax.RoundLine)
+	}
+	return string(rounding)
+}
@@ -0,0 +1,53 @@
+package storage
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/invoice"
+)
+
+func TestBuildInvoiceListQueryUsesKeysetPredicate(t *testing.T) {
+	cursor := invoice.CursorAfter(invoice.Invoice{ID: "inv-9", Total: 1200}, invoice.SortTotal, true)
+	query, args := buildInvoiceListQuery("tenant-a", invoice.ListFilter{
+		Status:     invoice.StatusOpen,
+		CustomerID: "cust-1",
+		Sort:       invoice.SortTotal,
+		Descending: true,
+		Limit:      51,
+		After:      &cursor,
+	})
+
+	for _, fragment := range []string{
+		"tenant_id = $1",
+		"status = $2",
+		"customer_id = $3",
+		`(total, id collate "C") < ($4, $5)`,
+		`order by total desc, id collate "C" desc`,
+		"limit $6",
+	} {
+		if !strings.Contains(query, fragment) {
+			t.Fatalf("expected query to contain %q:\n%s", fragment, query)
+		}
+	}
+	if strings.Contains(query, "offset") {
+		t.Fatalf("expected keyset pagination without offset:\n%s", query)
+	}
+	if len(args) != 6 || args[3] != int64(1200) || args[4] != "inv-9" || args[5] != 51 {
+		t.Fatalf("unexpected args: %#v", args)
+	}
+}
+
+func TestBuildInvoiceListQueryAscendingIssuedAt(t *testing.T) {
+	query, args := buildInvoiceListQuery("tenant-a", invoice.ListFilter{
+		IssuedFrom: "2026-06-01T00:00:00Z",
+		IssuedTo:   "2026-06-30T23:59:59Z",
+		Sort:       invoice.SortIssuedAt,
+	})
+	if !strings.Contains(query, "order by "+invoiceIssuedSortKey+" asc") || strings.Contains(query, "limit") {
+		t.Fatalf("unexpected query:\n%s", query)
+	}
+	if len(args) != 3 {
+		t.Fatalf("expected tenant and date bound args, got %#v", args)
+	}
+}
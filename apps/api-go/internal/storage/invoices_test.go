@@ -0,0 +1,374 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/api"
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/invoice"
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/tax"
+)
+
+func TestPostgresStorePersistsInvoices(t *testing.T) {
+	databaseURL := os.Getenv("DATABASE_URL")
+	if databaseURL == "" {
+		t.Skip("DATABASE_URL is required for postgres integration tests")
+	}
+
+	testDatabaseURL := createTestDatabase(t, databaseURL)
+	ctx := context.Background()
+	store, err := Open(ctx, testDatabaseURL)
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	defer store.Close()
+	if err := store.Migrate(ctx); err != nil {
+		t.Fatalf("migrate store: %v", err)
+	}
+
+	item := invoice.Invoice{
+		ID:         "inv-1",
+		TenantID:   "tenant-a",
+		Number:     "INV-0001",
+		CustomerID: "cust-1",
+		Currency:   "ILS",
+		Subtotal:   10000,
+		Tax:        1700,
+		Total:      11700,
+		Status:     invoice.StatusDraft,
+		IssuedAt:   nowRFC3339(),
+		CreatedAt:  nowRFC3339(),
+		UpdatedAt:  nowRFC3339(),
+		Version:    1,
+	}
+	if _, err := store.CreateInvoice(ctx, item, invoice.DefaultNumberFormat); err != nil {
+		t.Fatalf("create invoice: %v", err)
+	}
+	duplicate := item
+	duplicate.ID = "inv-2"
+	if _, err := store.CreateInvoice(ctx, duplicate, invoice.DefaultNumberFormat); !errors.Is(err, api.ErrConflict) {
+		t.Fatalf("expected duplicate number conflict, got %v", err)
+	}
+
+	item.Status = invoice.StatusOpen
+	item.UpdatedAt = nowRFC3339()
+	if err := store.UpdateInvoice(ctx, &item, invoice.DefaultNumberFormat); err != nil {
+		t.Fatalf("update invoice: %v", err)
+	}
+	got, err := store.GetInvoice(ctx, "tenant-a", item.ID)
+	if err != nil {
+		t.Fatalf("get invoice: %v", err)
+	}
+	if got.Status != invoice.StatusOpen || got.Total != 11700 || got.IssuedAt == "" || got.Version != 2 {
+		t.Fatalf("unexpected invoice: %#v", got)
+	}
+	if err := store.UpdateInvoice(ctx, &item, invoice.DefaultNumberFormat); !errors.Is(err, api.ErrVersionMismatch) {
+		t.Fatalf("expected an update of the old version to be refused, got %v", err)
+	}
+	if _, err := store.GetInvoice(ctx, "tenant-b", item.ID); !errors.Is(err, api.ErrNotFound) {
+		t.Fatalf("expected other tenant lookup to miss, got %v", err)
+	}
+
+	if err := store.SoftDeleteInvoice(ctx, "tenant-a", item.ID, nowRFC3339()); err != nil {
+		t.Fatalf("delete invoice: %v", err)
+	}
+	if err := store.SoftDeleteInvoice(ctx, "tenant-a", item.ID, nowRFC3339()); !errors.Is(err, api.ErrNotFound) {
+		t.Fatalf("expected second delete to miss, got %v", err)
+	}
+	if got, err := store.GetInvoice(ctx, "tenant-a", item.ID); err != nil || got.DeletedAt == "" {
+		t.Fatalf("expected soft-deleted invoice to stay readable, got %#v, %v", got, err)
+	}
+	if err := store.UpdateInvoice(ctx, &item, invoice.DefaultNumberFormat); !errors.Is(err, api.ErrNotFound) {
+		t.Fatalf("expected soft-deleted invoice update to miss, got %v", err)
+	}
+	if items, err := store.ListInvoices(ctx, "tenant-a", invoice.ListFilter{}); err != nil || len(items) != 0 {
+		t.Fatalf("expected soft-deleted invoice to be left out, got %#v, %v", items, err)
+	}
+	if items, err := store.ListInvoices(ctx, "tenant-a", invoice.ListFilter{IncludeDeleted: true}); err != nil || len(items) != 1 {
+		t.Fatalf("expected soft-deleted invoice to be included, got %#v, %v", items, err)
+	}
+
+	history, err := store.ListAuditLog(ctx, "tenant-a", api.AuditEntityInvoice, item.ID)
+	if err != nil || len(history) != 3 {
+		t.Fatalf("expected created, updated and deleted entries, got %#v, %v", history, err)
+	}
+	if change := history[1].Changes["status"]; history[1].Action != api.AuditInvoiceUpdated || change.Before != "draft" || change.After != "open" {
+		t.Fatalf("expected the update to record the status change, got %#v", history[1])
+	}
+	if history[2].Action != api.AuditInvoiceDeleted || history[2].Actor.Type != api.ActorSystem {
+		t.Fatalf("unexpected delete entry %#v", history[2])
+	}
+}
+
+func TestPostgresStoreRecomputesTotalsFromLineItems(t *testing.T) {
+	databaseURL := os.Getenv("DATABASE_URL")
+	if databaseURL == "" {
+		t.Skip("DATABASE_URL is required for postgres integration tests")
+	}
+
+	testDatabaseURL := createTestDatabase(t, databaseURL)
+	ctx := context.Background()
+	store, err := Open(ctx, testDatabaseURL)
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	defer store.Close()
+	if err := store.Migrate(ctx); err != nil {
+		t.Fatalf("migrate store: %v", err)
+	}
+
+	hosting := invoice.LineItem{ID: "item-1", InvoiceID: "inv-1", Position: 1, Description: "Hosting", Quantity: 2, UnitPrice: 5000, TaxRate: 1700, CreatedAt: nowRFC3339()}
+	hosting.Normalize()
+	item := invoice.Invoice{
+		ID:         "inv-1",
+		TenantID:   "tenant-a",
+		Number:     "INV-0001",
+		CustomerID: "cust-1",
+		Currency:   "ILS",
+		Status:     invoice.StatusDraft,
+		CreatedAt:  nowRFC3339(),
+		UpdatedAt:  nowRFC3339(),
+	}
+	item.SetLineItems([]invoice.LineItem{hosting})
+	if _, err := store.CreateInvoice(ctx, item, invoice.DefaultNumberFormat); err != nil {
+		t.Fatalf("create invoice: %v", err)
+	}
+
+	setup := invoice.LineItem{ID: "item-2", InvoiceID: "inv-1", Description: "Setup", Quantity: 1, UnitPrice: 2500, CreatedAt: nowRFC3339()}
+	setup.Normalize()
+	added, err := store.AddInvoiceLineItem(ctx, "tenant-a", setup)
+	if err != nil {
+		t.Fatalf("add line item: %v", err)
+	}
+	if added.Total != 14200 || len(added.Items) != 2 || added.Items[1].Position != 2 {
+		t.Fatalf("unexpected invoice after add: %#v", added)
+	}
+
+	removed, err := store.DeleteInvoiceLineItem(ctx, "tenant-a", "inv-1", "item-1", nowRFC3339())
+	if err != nil {
+		t.Fatalf("delete line item: %v", err)
+	}
+	got, err := store.GetInvoice(ctx, "tenant-a", "inv-1")
+	if err != nil {
+		t.Fatalf("get invoice: %v", err)
+	}
+	if removed.Total != 2500 || got.Total != 2500 || len(got.Items) != 1 {
+		t.Fatalf("unexpected invoice after delete: %#v / %#v", removed, got)
+	}
+	if _, err := store.DeleteInvoiceLineItem(ctx, "tenant-b", "inv-1", "item-2", nowRFC3339()); !errors.Is(err, api.ErrNotFound) {
+		t.Fatalf("expected other tenant to miss, got %v", err)
+	}
+
+	got.Status = invoice.StatusOpen
+	if err := store.UpdateInvoice(ctx, &got, invoice.DefaultNumberFormat); err != nil {
+		t.Fatalf("open invoice: %v", err)
+	}
+	if _, err := store.AddInvoiceLineItem(ctx, "tenant-a", setup); !errors.Is(err, invoice.ErrNotDraft) {
+		t.Fatalf("expected ErrNotDraft, got %v", err)
+	}
+}
+
+func TestPostgresStoreKeepsInvoiceRoundedLineTaxes(t *testing.T) {
+	databaseURL := os.Getenv("DATABASE_URL")
+	if databaseURL == "" {
+		t.Skip("DATABASE_URL is required for postgres integration tests")
+	}
+
+	testDatabaseURL := createTestDatabase(t, databaseURL)
+	ctx := context.Background()
+	store, err := Open(ctx, testDatabaseURL)
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	defer store.Close()
+	if err := store.Migrate(ctx); err != nil {
+		t.Fatalf("migrate store: %v", err)
+	}
+
+	half := func(id string) invoice.LineItem {
+		line := invoice.LineItem{ID: id, InvoiceID: "inv-1", Description: id, Quantity: 1, UnitPrice: 1, TaxRate: 5000, CreatedAt: nowRFC3339()}
+		line.Normalize()
+		return line
+	}
+	first := half("item-1")
+	first.Position = 1
+	item := invoice.Invoice{
+		ID:          "inv-1",
+		TenantID:    "tenant-a",
+		Number:      "INV-0001",
+		CustomerID:  "cust-1",
+		Currency:    "ILS",
+		Status:      invoice.StatusDraft,
+		TaxRounding: tax.RoundInvoice,
+		CreatedAt:   nowRFC3339(),
+		UpdatedAt:   nowRFC3339(),
+	}
+	item.SetLineItems([]invoice.LineItem{first})
+	if _, err := store.CreateInvoice(ctx, item, invoice.DefaultNumberFormat); err != nil {
+		t.Fatalf("create invoice: %v", err)
+	}
+	// Two half units round to one, which stays on the first line.
+	if _, err := store.AddInvoiceLineItem(ctx, "tenant-a", half("item-2")); err != nil {
+		t.Fatalf("add line item: %v", err)
+	}
+	got, err := store.GetInvoice(ctx, "tenant-a", "inv-1")
+	if err != nil {
+		t.Fatalf("get invoice: %v", err)
+	}
+	if got.TaxRounding != tax.RoundInvoice || got.Tax != 1 || got.Items[0].Tax != 1 || got.Items[1].Tax != 0 {
+		t.Fatalf("unexpected invoice-rounded taxes: %#v", got)
+	}
+
+	got.TaxExempt = true
+	got.ComputeTax()
+	if err := store.UpdateInvoice(ctx, &got, invoice.DefaultNumberFormat); err != nil {
+		t.Fatalf("update invoice: %v", err)
+	}
+	got, err = store.GetInvoice(ctx, "tenant-a", "inv-1")
+	if err != nil || !got.TaxExempt || got.Tax != 0 || got.Items[0].Tax != 0 {
+		t.Fatalf("expected exempt invoice to store zero line taxes, got %#v, %v", got, err)
+	}
+}
+
+func TestPostgresStoreRejectsCrossTenantReferences(t *testing.T) {
+	databaseURL := os.Getenv("DATABASE_URL")
+	if databaseURL == "" {
+		t.Skip("DATABASE_URL is required for postgres integration tests")
+	}
+
+	testDatabaseURL := createTestDatabase(t, databaseURL)
+	ctx := context.Background()
+	store, err := Open(ctx, testDatabaseURL)
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	defer store.Close()
+	if err := store.Migrate(ctx); err != nil {
+		t.Fatalf("migrate store: %v", err)
+	}
+
+	item := invoice.Invoice{
+		ID:         "inv-1",
+		TenantID:   "tenant-a",
+		Number:     "INV-0001",
+		CustomerID: "cust-1",
+		Currency:   "ILS",
+		Status:     invoice.StatusDraft,
+		CreatedAt:  nowRFC3339(),
+		UpdatedAt:  nowRFC3339(),
+	}
+	if _, err := store.CreateInvoice(ctx, item, invoice.DefaultNumberFormat); err != nil {
+		t.Fatalf("create invoice: %v", err)
+	}
+
+	foreign := item
+	foreign.TenantID = "tenant-b"
+	foreign.Total = 1
+	if err := store.UpdateInvoice(ctx, &foreign, invoice.DefaultNumberFormat); !errors.Is(err, api.ErrNotFound) {
+		t.Fatalf("expected other tenant update to miss, got %v", err)
+	}
+	if err := store.SoftDeleteInvoice(ctx, "tenant-b", item.ID, nowRFC3339()); !errors.Is(err, api.ErrNotFound) {
+		t.Fatalf("expected other tenant delete to miss, got %v", err)
+	}
+
+	_, err = store.pool.Exec(ctx, `
+		insert into invoice_line_items (tenant_id, id, invoice_id, position, description, quantity, unit_price, tax_rate, amount, tax, created_at)
+		values ('tenant-b', 'item-x', 'inv-1', 1, 'Leak', 1, 100, 0, 100, 0, now())
+	`)
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) || pgErr.Code != "23503" {
+		t.Fatalf("expected foreign key violation for cross-tenant line item, got %v", err)
+	}
+}
+
+func TestPostgresStoreMarksInvoicesOverdueOnce(t *testing.T) {
+	databaseURL := os.Getenv("DATABASE_URL")
+	if databaseURL == "" {
+		t.Skip("DATABASE_URL is required for postgres integration tests")
+	}
+
+	testDatabaseURL := createTestDatabase(t, databaseURL)
+	ctx := context.Background()
+	store, err := Open(ctx, testDatabaseURL)
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	defer store.Close()
+	if err := store.Migrate(ctx); err != nil {
+		t.Fatalf("migrate store: %v", err)
+	}
+
+	past := time.Now().UTC().Add(-48 * time.Hour).Format(time.RFC3339)
+	future := time.Now().UTC().Add(48 * time.Hour).Format(time.RFC3339)
+	for i, tc := range []struct {
+		status invoice.Status
+		dueAt  string
+	}{
+		{invoice.StatusOpen, past},
+		{invoice.StatusOpen, past},
+		{invoice.StatusOpen, past},
+		{invoice.StatusOpen, future},
+		{invoice.StatusDraft, past},
+	} {
+		item := invoice.Invoice{
+			ID:         fmt.Sprintf("inv-%d", i+1),
+			TenantID:   []string{"tenant-a", "tenant-b"}[i%2],
+			Number:     fmt.Sprintf("INV-%04d", i+1),
+			CustomerID: "cust-1",
+			Currency:   "ILS",
+			Status:     tc.status,
+			DueAt:      tc.dueAt,
+			CreatedAt:  nowRFC3339(),
+			UpdatedAt:  nowRFC3339(),
+		}
+		if _, err := store.CreateInvoice(ctx, item, invoice.DefaultNumberFormat); err != nil {
+			t.Fatalf("create invoice: %v", err)
+		}
+	}
+
+	// Two scans racing in small batches must split the due invoices
+	// between them without overlap.
+	var mu sync.Mutex
+	seen := map[string]int{}
+	var wg sync.WaitGroup
+	for range 2 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				items, err := store.MarkInvoicesOverdue(ctx, nowRFC3339(), 1)
+				if err != nil {
+					t.Errorf("mark overdue: %v", err)
+					return
+				}
+				if len(items) == 0 {
+					return
+				}
+				mu.Lock()
+				for _, item := range items {
+					seen[item.ID]++
+					if item.Status != invoice.StatusOverdue {
+						t.Errorf("expected %s returned as overdue, got %s", item.ID, item.Status)
+					}
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if len(seen) != 3 || seen["inv-1"] != 1 || seen["inv-2"] != 1 || seen["inv-3"] != 1 {
+		t.Fatalf("expected each past-due open invoice marked once, got %v", seen)
+	}
+	if got, err := store.GetInvoice(ctx, "tenant-b", "inv-4"); err != nil || got.Status != invoice.StatusOpen {
+		t.Fatalf("expected invoice not yet due to stay open, got %#v, %v", got, err)
+	}
+	if got, err := store.GetInvoice(ctx, "tenant-a", "inv-5"); err != nil || got.Status != invoice.StatusDraft {
+		t.Fatalf("expected draft to be left alone, got %#v, %v", got, err)
+	}
+}
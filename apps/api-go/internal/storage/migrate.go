@@ -2,92 +2,215 @@ package storage
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"os"
-	"path/filepath"
-	"runtime"
-	"sort"
+	"io/fs"
+	"strconv"
 	"strings"
+
+	"github.com/golang-migrate/migrate/v4"
+	pgxmigrate "github.com/golang-migrate/migrate/v4/database/pgx/v5"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
+	"github.com/jackc/pgx/v5/stdlib"
+
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/migrations"
 )
 
+// migrationsTable records the applied schema version. It is not the
+// library's default name because schema_migrations belongs to the runner
+// that came before it, see adoptLegacyVersion.
+const migrationsTable = "schema_version"
+
+// ErrSchemaAhead means the database was migrated by a newer binary than
+// this one. Running against it could corrupt data, so startup stops.
+var ErrSchemaAhead = errors.New("database schema is newer than this binary")
+
+// ErrSchemaDirty means a migration failed halfway. The schema has to be
+// repaired by hand and the version set with `invoicer migrate force`.
+var ErrSchemaDirty = errors.New("database schema is dirty")
+
+// Migrate applies every pending migration. The migration driver holds a
+// Postgres advisory lock while it runs, so replicas starting together apply
+// each migration once and the rest wait for it.
 func (s *PostgresStore) Migrate(ctx context.Context) error {
-	if _, err := s.pool.Exec(ctx, `
-		create table if not exists schema_migrations (
-			version text primary key,
-			applied_at timestamptz not null default now()
-		)
-	`); err != nil {
-		return fmt.Errorf("create schema_migrations: %w", err)
+	return s.withMigrator(ctx, func(m *migrate.Migrate) error {
+		if err := s.adoptLegacyVersion(ctx, m); err != nil {
+			return err
+		}
+		if err := checkSchemaVersion(m); err != nil {
+			return err
+		}
+		if err := m.Up(); err != nil && !errors.Is(err, migrate.ErrNoChange) {
+			return fmt.Errorf("migrate up: %w", err)
+		}
+		return nil
+	})
+}
+
+// MigrateDown reverts the last steps migrations.
+func (s *PostgresStore) MigrateDown(ctx context.Context, steps int) error {
+	if steps <= 0 {
+		return fmt.Errorf("migrate down: steps must be positive, got %d", steps)
 	}
+	return s.withMigrator(ctx, func(m *migrate.Migrate) error {
+		if err := checkSchemaVersion(m); err != nil {
+			return err
+		}
+		if err := m.Steps(-steps); err != nil {
+			return fmt.Errorf("migrate down %d: %w", steps, err)
+		}
+		return nil
+	})
+}
 
-	dir, err := migrationsDir()
-	if err != nil {
+// ForceSchemaVersion records version as applied and clears the dirty flag
+// without running anything. It is the way out after a failed migration has
+// been repaired by hand.
+func (s *PostgresStore) ForceSchemaVersion(ctx context.Context, version int) error {
+	return s.withMigrator(ctx, func(m *migrate.Migrate) error {
+		if err := m.Force(version); err != nil {
+			return fmt.Errorf("force schema version %d: %w", version, err)
+		}
+		return nil
+	})
+}
+
+// SchemaVersion returns the applied schema version, 0 before the first
+// migration, and whether the last migration failed halfway.
+func (s *PostgresStore) SchemaVersion(ctx context.Context) (version uint, dirty bool, err error) {
+	err = s.withMigrator(ctx, func(m *migrate.Migrate) error {
+		version, dirty, err = m.Version()
+		if errors.Is(err, migrate.ErrNilVersion) {
+			return nil
+		}
 		return err
-	}
-	entries, err := os.ReadDir(dir)
+	})
+	return version, dirty, err
+}
+
+// LatestSchemaVersion is the newest migration embedded in the binary.
+func LatestSchemaVersion() (uint, error) {
+	source, err := iofs.New(migrations.FS, ".")
 	if err != nil {
-		return fmt.Errorf("read migrations dir: %w", err)
+		return 0, fmt.Errorf("open embedded migrations: %w", err)
 	}
+	defer source.Close()
 
-	files := make([]string, 0, len(entries))
-	for _, entry := range entries {
-		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".sql") {
-			continue
-		}
-		files = append(files, entry.Name())
+	version, err := source.First()
+	if err != nil {
+		return 0, fmt.Errorf("read embedded migrations: %w", err)
 	}
-	sort.Strings(files)
-
-	for _, name := range files {
-		applied, err := s.isApplied(ctx, name)
-		if err != nil {
-			return err
-		}
-		if applied {
-			continue
+	for {
+		next, err := source.Next(version)
+		if errors.Is(err, fs.ErrNotExist) {
+			return version, nil
 		}
-		if err := s.applyMigration(ctx, filepath.Join(dir, name), name); err != nil {
-			return err
+		if err != nil {
+			return 0, fmt.Errorf("read embedded migrations: %w", err)
 		}
+		version = next
 	}
-	return nil
 }
 
-func (s *PostgresStore) isApplied(ctx context.Context, version string) (bool, error) {
-	var exists bool
-	if err := s.pool.QueryRow(ctx, `select exists(select 1 from schema_migrations where version = $1)`, version).Scan(&exists); err != nil {
-		return false, fmt.Errorf("check migration %s: %w", version, err)
+func (s *PostgresStore) withMigrator(ctx context.Context, run func(*migrate.Migrate) error) error {
+	source, err := iofs.New(migrations.FS, ".")
+	if err != nil {
+		return fmt.Errorf("open embedded migrations: %w", err)
 	}
-	return exists, nil
-}
-
-func (s *PostgresStore) applyMigration(ctx context.Context, path, version string) error {
-	body, err := os.ReadFile(path)
+	// Closing this handle leaves the pool open.
+	db := stdlib.OpenDBFromPool(s.pool)
+	driver, err := pgxmigrate.WithInstance(db, &pgxmigrate.Config{MigrationsTable: migrationsTable})
 	if err != nil {
-		return fmt.Errorf("read migration %s: %w", version, err)
+		_ = source.Close()
+		_ = db.Close()
+		return fmt.Errorf("open migration driver: %w", err)
 	}
-	tx, err := s.pool.Begin(ctx)
+	m, err := migrate.NewWithInstance("iofs", source, "pgx5", driver)
 	if err != nil {
-		return fmt.Errorf("begin migration %s: %w", version, err)
+		_ = source.Close()
+		_ = driver.Close()
+		return fmt.Errorf("create migrator: %w", err)
 	}
-	defer tx.Rollback(ctx)
+	defer m.Close()
 
-	if _, err := tx.Exec(ctx, string(body)); err != nil {
-		return fmt.Errorf("exec migration %s: %w", version, err)
+	stop := context.AfterFunc(ctx, func() { m.GracefulStop <- true })
+	defer stop()
+	return run(m)
+}
+
+// checkSchemaVersion refuses to touch a schema that is dirty or newer than
+// the embedded migrations.
+func checkSchemaVersion(m *migrate.Migrate) error {
+	version, dirty, err := m.Version()
+	if errors.Is(err, migrate.ErrNilVersion) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("read schema version: %w", err)
 	}
-	if _, err := tx.Exec(ctx, `insert into schema_migrations (version) values ($1)`, version); err != nil {
-		return fmt.Errorf("record migration %s: %w", version, err)
+	if dirty {
+		return fmt.Errorf("%w: migration %d did not finish", ErrSchemaDirty, version)
+	}
+	latest, err := LatestSchemaVersion()
+	if err != nil {
+		return err
 	}
-	if err := tx.Commit(ctx); err != nil {
-		return fmt.Errorf("commit migration %s: %w", version, err)
+	if version > latest {
+		return fmt.Errorf("%w: database is at version %d, binary knows up to %d", ErrSchemaAhead, version, latest)
 	}
 	return nil
 }
 
-func migrationsDir() (string, error) {
-	_, currentFile, _, ok := runtime.Caller(0)
-	if !ok {
-		return "", fmt.Errorf("resolve migrations dir: runtime caller unavailable")
+// adoptLegacyVersion carries databases migrated by the previous in-house
+// runner over to the migration library. That runner recorded file names
+// such as "011_idempotency_request_hash.sql" in schema_migrations; the
+// highest number becomes the starting version so nothing is applied twice.
+func (s *PostgresStore) adoptLegacyVersion(ctx context.Context, m *migrate.Migrate) error {
+	if _, _, err := m.Version(); !errors.Is(err, migrate.ErrNilVersion) {
+		return nil
 	}
-	return filepath.Join(filepath.Dir(currentFile), "..", "..", "migrations"), nil
+	var legacy bool
+	if err := s.pool.QueryRow(ctx, `
+		select exists (
+			select 1 from information_schema.columns
+			where table_schema = current_schema()
+				and table_name = 'schema_migrations'
+				and column_name = 'version'
+				and data_type = 'text'
+		)
+	`).Scan(&legacy); err != nil {
+		return fmt.Errorf("check legacy schema_migrations: %w", err)
+	}
+	if !legacy {
+		return nil
+	}
+
+	rows, err := s.pool.Query(ctx, `select version from schema_migrations`)
+	if err != nil {
+		return fmt.Errorf("read legacy schema_migrations: %w", err)
+	}
+	defer rows.Close()
+	applied := 0
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return fmt.Errorf("read legacy schema_migrations: %w", err)
+		}
+		prefix, _, _ := strings.Cut(name, "_")
+		version, err := strconv.Atoi(prefix)
+		if err != nil {
+			return fmt.Errorf("read legacy schema_migrations: unexpected version %q", name)
+		}
+		applied = max(applied, version)
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("read legacy schema_migrations: %w", err)
+	}
+	if applied == 0 {
+		return nil
+	}
+	if err := m.Force(applied); err != nil {
+		return fmt.Errorf("adopt legacy schema version %d: %w", applied, err)
+	}
+	return nil
 }
@@ -0,0 +1,140 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/migrations"
+)
+
+func TestEmbeddedMigrationsArePaired(t *testing.T) {
+	names, err := fs.Glob(migrations.FS, "*.sql")
+	if err != nil || len(names) == 0 {
+		t.Fatalf("expected embedded migrations, got %v, %v", names, err)
+	}
+	pattern := regexp.MustCompile(`^(\d{3})_[a-z0-9_]+\.(up|down)\.sql$`)
+	ups := map[string]bool{}
+	downs := map[string]bool{}
+	for _, name := range names {
+		match := pattern.FindStringSubmatch(name)
+		if match == nil {
+			t.Fatalf("migration %q is not named NNN_description.up.sql or .down.sql", name)
+		}
+		base := strings.TrimSuffix(strings.TrimSuffix(name, ".up.sql"), ".down.sql")
+		if match[2] == "up" {
+			ups[base] = true
+		} else {
+			downs[base] = true
+		}
+	}
+	for base := range ups {
+		if !downs[base] {
+			t.Errorf("migration %s has no down file", base)
+		}
+	}
+	for base := range downs {
+		if !ups[base] {
+			t.Errorf("migration %s has no up file", base)
+		}
+	}
+
+	latest, err := LatestSchemaVersion()
+	if err != nil || latest != uint(len(ups)) {
+		t.Fatalf("expected versions numbered 1..%d, latest is %d: %v", len(ups), latest, err)
+	}
+}
+
+func TestMigrateDownUpAndVersionGuards(t *testing.T) {
+	databaseURL := os.Getenv("DATABASE_URL")
+	if databaseURL == "" {
+		t.Skip("DATABASE_URL is required for postgres integration tests")
+	}
+
+	testDatabaseURL := createTestDatabase(t, databaseURL)
+	ctx := context.Background()
+	store, err := Open(ctx, testDatabaseURL)
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	defer store.Close()
+	if err := store.Migrate(ctx); err != nil {
+		t.Fatalf("migrate store: %v", err)
+	}
+	latest, _ := LatestSchemaVersion()
+
+	if err := store.MigrateDown(ctx, int(latest)); err != nil {
+		t.Fatalf("migrate all the way down: %v", err)
+	}
+	var invoices *string
+	if err := store.pool.QueryRow(ctx, `select to_regclass('invoices')::text`).Scan(&invoices); err != nil || invoices != nil {
+		t.Fatalf("expected invoices table dropped, got %v, %v", invoices, err)
+	}
+	if err := store.Migrate(ctx); err != nil {
+		t.Fatalf("migrate back up: %v", err)
+	}
+	if err := store.Migrate(ctx); err != nil {
+		t.Fatalf("expected a second migrate to be a no-op, got %v", err)
+	}
+
+	if err := store.ForceSchemaVersion(ctx, int(latest)+1); err != nil {
+		t.Fatalf("force version: %v", err)
+	}
+	if err := store.Migrate(ctx); !errors.Is(err, ErrSchemaAhead) {
+		t.Fatalf("expected ErrSchemaAhead, got %v", err)
+	}
+	if _, err := store.pool.Exec(ctx, `update schema_version set version = $1, dirty = true`, latest); err != nil {
+		t.Fatalf("mark dirty: %v", err)
+	}
+	if err := store.Migrate(ctx); !errors.Is(err, ErrSchemaDirty) {
+		t.Fatalf("expected ErrSchemaDirty, got %v", err)
+	}
+}
+
+func TestMigrateAdoptsLegacyRunnerVersion(t *testing.T) {
+	databaseURL := os.Getenv("DATABASE_URL")
+	if databaseURL == "" {
+		t.Skip("DATABASE_URL is required for postgres integration tests")
+	}
+
+	testDatabaseURL := createTestDatabase(t, databaseURL)
+	ctx := context.Background()
+	store, err := Open(ctx, testDatabaseURL)
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	defer store.Close()
+	if err := store.Migrate(ctx); err != nil {
+		t.Fatalf("migrate store: %v", err)
+	}
+	// Rewind to what the old runner left behind after applying 001-010.
+	if err := store.MigrateDown(ctx, 1); err != nil {
+		t.Fatalf("migrate down: %v", err)
+	}
+	if _, err := store.pool.Exec(ctx, `
+		drop table schema_version;
+		create table schema_migrations (version text primary key, applied_at timestamptz not null default now());
+		insert into schema_migrations (version) values ('001_control_plane_tables.sql'), ('010_invoice_overdue_index.sql');
+	`); err != nil {
+		t.Fatalf("recreate legacy table: %v", err)
+	}
+
+	if err := store.Migrate(ctx); err != nil {
+		t.Fatalf("migrate legacy database: %v", err)
+	}
+	version, dirty, err := store.SchemaVersion(ctx)
+	latest, _ := LatestSchemaVersion()
+	if err != nil || dirty || version != latest {
+		t.Fatalf("expected adopted schema at version %d, got %d dirty=%t: %v", latest, version, dirty, err)
+	}
+	var hasHash bool
+	if err := store.pool.QueryRow(ctx, `
+		select exists (select 1 from information_schema.columns where table_name = 'idempotency_keys' and column_name = 'request_hash')
+	`).Scan(&hasHash); err != nil || !hasHash {
+		t.Fatalf("expected migration 011 applied on top of the legacy version, got %t, %v", hasHash, err)
+	}
+}
@@ -0,0 +1,94 @@
+package storage
+
+import (
+	"cmp"
+	"context"
+	"slices"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/invoice"
+)
+
+type invoiceSeries struct {
+	tenantID string
+	year     int
+}
+
+// numberInvoices gives each invoice that needs a number the next numbers of
+// its series, in the order of items. Advancing a series upserts its
+// invoice_sequences row, which stays locked until tx ends: concurrent
+// creates of a tenant take their numbers one after the other, and a rolled
+// back create hands its numbers to the next one. Numbers that are stored
+// already, or reserved by explicitly numbered items of the same batch, are
+// skipped. Series are advanced in key order so two batches cannot deadlock.
+func numberInvoices(ctx context.Context, tx pgx.Tx, items []*invoice.Invoice, numbering invoice.NumberFormat, reserved map[[2]string]bool) error {
+	groups := map[invoiceSeries][]*invoice.Invoice{}
+	for _, item := range items {
+		if item.NeedsNumber() {
+			key := invoiceSeries{tenantID: item.TenantID, year: numbering.Series(*item)}
+			groups[key] = append(groups[key], item)
+		}
+	}
+	keys := make([]invoiceSeries, 0, len(groups))
+	for key := range groups {
+		keys = append(keys, key)
+	}
+	slices.SortFunc(keys, func(a, b invoiceSeries) int {
+		return cmp.Or(cmp.Compare(a.tenantID, b.tenantID), cmp.Compare(a.year, b.year))
+	})
+
+	for _, key := range keys {
+		pending := groups[key]
+		for len(pending) > 0 {
+			var last int64
+			if err := tx.QueryRow(ctx, `
+				insert into invoice_sequences (tenant_id, year, last_value)
+				values ($1, $2, $3)
+				on conflict (tenant_id, year) do update set last_value = invoice_sequences.last_value + excluded.last_value
+				returning last_value
+			`, key.tenantID, key.year, len(pending)).Scan(&last); err != nil {
+				return err
+			}
+			numbers := make([]string, len(pending))
+			for i := range numbers {
+				numbers[i] = numbering.Format(key.year, last-int64(len(pending)-1-i))
+			}
+			stored, err := storedInvoiceNumbers(ctx, tx, key.tenantID, numbers)
+			if err != nil {
+				return err
+			}
+			assigned := 0
+			for _, number := range numbers {
+				if stored[number] || reserved[[2]string{key.tenantID, number}] {
+					continue
+				}
+				pending[assigned].Number = number
+				assigned++
+			}
+			pending = pending[assigned:]
+		}
+	}
+	return nil
+}
+
+func storedInvoiceNumbers(ctx context.Context, tx pgx.Tx, tenantID string, numbers []string) (map[string]bool, error) {
+	rows, err := tx.Query(ctx, `
+		select number
+		from invoices
+		where tenant_id = $1 and number = any($2)
+	`, tenantID, numbers)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	stored := map[string]bool{}
+	for rows.Next() {
+		var number string
+		if err := rows.Scan(&number); err != nil {
+			return nil, err
+		}
+		stored[number] = true
+	}
+	return stored, rows.Err()
+}
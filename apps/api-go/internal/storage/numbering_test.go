@@ -0,0 +1,111 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"sync"
+	"testing"
+
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/api"
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/invoice"
+)
+
+func TestPostgresStoreNumbersConcurrentInvoicesWithoutGaps(t *testing.T) {
+	databaseURL := os.Getenv("DATABASE_URL")
+	if databaseURL == "" {
+		t.Skip("DATABASE_URL is required for postgres integration tests")
+	}
+
+	testDatabaseURL := createTestDatabase(t, databaseURL)
+	ctx := context.Background()
+	store, err := OpenWithPool(ctx, testDatabaseURL, PoolConfig{MaxConns: 16})
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	defer store.Close()
+	if err := store.Migrate(ctx); err != nil {
+		t.Fatalf("migrate store: %v", err)
+	}
+	unnumbered := func(id, tenantID string) invoice.Invoice {
+		return invoice.Invoice{ID: id, TenantID: tenantID, CustomerID: "cust-1", Currency: "ILS", Status: invoice.StatusOpen, CreatedAt: nowRFC3339(), UpdatedAt: nowRFC3339(), Version: 1}
+	}
+
+	const creates = 40
+	var wg sync.WaitGroup
+	numbers := make([]string, creates)
+	errs := make([]error, creates)
+	for i := range numbers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var item invoice.Invoice
+			item, errs[i] = store.CreateInvoice(ctx, unnumbered(fmt.Sprintf("inv-%d", i), "tenant-a"), invoice.DefaultNumberFormat)
+			numbers[i] = item.Number
+		}()
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			t.Fatalf("create invoice: %v", err)
+		}
+	}
+	slices.Sort(numbers)
+	for i, number := range numbers {
+		if want := fmt.Sprintf("INV-%04d", i+1); number != want {
+			t.Fatalf("expected INV-0001 to INV-%04d without duplicates or gaps, got %v", creates, numbers)
+		}
+	}
+
+	// A create that rolls back hands its number to the next one.
+	if _, err := store.CreateInvoice(ctx, unnumbered("inv-0", "tenant-a"), invoice.DefaultNumberFormat); !errors.Is(err, api.ErrConflict) {
+		t.Fatalf("expected a duplicate id to conflict, got %v", err)
+	}
+	if _, err := store.CreateInvoice(ctx, invoice.Invoice{ID: "inv-explicit", TenantID: "tenant-a", Number: "INV-0042", CustomerID: "cust-1", Currency: "ILS", Status: invoice.StatusDraft, CreatedAt: nowRFC3339(), UpdatedAt: nowRFC3339(), Version: 1}, invoice.DefaultNumberFormat); err != nil {
+		t.Fatalf("create explicitly numbered invoice: %v", err)
+	}
+	next, err := store.CreateInvoice(ctx, unnumbered("inv-next", "tenant-a"), invoice.DefaultNumberFormat)
+	if err != nil || next.Number != "INV-0041" {
+		t.Fatalf("expected INV-0041 after the rollback, got %q, %v", next.Number, err)
+	}
+	skipped, err := store.CreateInvoice(ctx, unnumbered("inv-skip", "tenant-a"), invoice.DefaultNumberFormat)
+	if err != nil || skipped.Number != "INV-0043" {
+		t.Fatalf("expected the explicit INV-0042 skipped, got %q, %v", skipped.Number, err)
+	}
+	if stored, err := store.GetInvoice(ctx, "tenant-a", skipped.ID); err != nil || stored.Number != "INV-0043" {
+		t.Fatalf("expected the number stored, got %#v, %v", stored, err)
+	}
+
+	yearly := invoice.NumberFormat{Prefix: "INV-", Width: 6, YearlyReset: true}
+	batch := []invoice.Invoice{unnumbered("inv-b1", "tenant-b"), unnumbered("inv-b2", "tenant-b"), unnumbered("inv-b3", "tenant-b")}
+	batch[0].IssuedAt, batch[1].IssuedAt, batch[2].IssuedAt = "2024-05-01T00:00:00Z", "2025-01-10T00:00:00Z", "2024-06-01T00:00:00Z"
+	if taken, err := store.CreateInvoices(ctx, batch, yearly, true); err != nil || len(taken) != 0 {
+		t.Fatalf("create batch: %v, %v", taken, err)
+	}
+	got := []string{batch[0].Number, batch[1].Number, batch[2].Number}
+	if want := []string{"INV-2024-000001", "INV-2025-000001", "INV-2024-000002"}; !slices.Equal(got, want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+
+	// Drafts stay unnumbered, side by side, until they are issued.
+	var drafts []invoice.Invoice
+	for _, id := range []string{"inv-d1", "inv-d2"} {
+		draft := unnumbered(id, "tenant-a")
+		draft.Status = invoice.StatusDraft
+		created, err := store.CreateInvoice(ctx, draft, invoice.DefaultNumberFormat)
+		if err != nil || created.Number != "" {
+			t.Fatalf("expected an unnumbered draft, got %q, %v", created.Number, err)
+		}
+		drafts = append(drafts, created)
+	}
+	issued := drafts[1]
+	issued.Status = invoice.StatusOpen
+	if err := store.UpdateInvoice(ctx, &issued, invoice.DefaultNumberFormat); err != nil || issued.Number != "INV-0044" {
+		t.Fatalf("expected the issued draft to take INV-0044, got %q, %v", issued.Number, err)
+	}
+	if stored, err := store.GetInvoice(ctx, "tenant-a", issued.ID); err != nil || stored.Number != "INV-0044" {
+		t.Fatalf("expected the number stored, got %#v, %v", stored, err)
+	}
+}
@@ -0,0 +1,98 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/api"
+)
+
+// FilesystemObjectStore keeps objects as files below Root, one per key.
+type FilesystemObjectStore struct {
+	Root string
+}
+
+func NewFilesystemObjectStore(root string) (*FilesystemObjectStore, error) {
+	if err := os.MkdirAll(root, 0o750); err != nil {
+		return nil, fmt.Errorf("create object store root: %w", err)
+	}
+	return &FilesystemObjectStore{Root: root}, nil
+}
+
+// Put writes body to a temporary file next to the object and renames it into
+// place, so a reader never sees a partly written object.
+func (s *FilesystemObjectStore) Put(_ context.Context, key string, body io.Reader, size int64, _ string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		return fmt.Errorf("create object directory: %w", err)
+	}
+	file, err := os.CreateTemp(filepath.Dir(path), ".upload-*")
+	if err != nil {
+		return fmt.Errorf("create object %s: %w", key, err)
+	}
+	defer os.Remove(file.Name())
+	written, err := io.Copy(file, body)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("write object %s: %w", key, err)
+	}
+	if written != size {
+		return fmt.Errorf("write object %s: wrote %d bytes, expected %d", key, written, size)
+	}
+	if err := os.Rename(file.Name(), path); err != nil {
+		return fmt.Errorf("store object %s: %w", key, err)
+	}
+	return nil
+}
+
+func (s *FilesystemObjectStore) Get(_ context.Context, key string) (io.ReadCloser, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, api.ErrNotFound
+	}
+	return file, err
+}
+
+func (s *FilesystemObjectStore) Delete(_ context.Context, key string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("delete object %s: %w", key, err)
+	}
+	return nil
+}
+
+// Ping checks that Root still takes writes by creating and removing a
+// temporary file in it.
+func (s *FilesystemObjectStore) Ping(context.Context) error {
+	file, err := os.CreateTemp(s.Root, ".ping-*")
+	if err != nil {
+		return fmt.Errorf("write to object store root: %w", err)
+	}
+	file.Close()
+	return os.Remove(file.Name())
+}
+
+// path maps key below Root, refusing keys that would leave it.
+func (s *FilesystemObjectStore) path(key string) (string, error) {
+	if !fs.ValidPath(key) || key == "." {
+		return "", fmt.Errorf("invalid object key %q", key)
+	}
+	return filepath.Join(s.Root, filepath.FromSlash(key)), nil
+}
@@ -0,0 +1,67 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/api"
+)
+
+func TestFilesystemObjectStoreRoundTrip(t *testing.T) {
+	root := filepath.Join(t.TempDir(), "objects")
+	store, err := NewFilesystemObjectStore(root)
+	if err != nil {
+		t.Fatalf("new object store: %v", err)
+	}
+	ctx := context.Background()
+	if err := store.Ping(ctx); err != nil {
+		t.Fatalf("expected a writable root to answer a ping, got %v", err)
+	}
+	if err := store.Put(ctx, "attachments/tenant-a/abc", strings.NewReader("hello"), 5, "text/plain"); err != nil {
+		t.Fatalf("put object: %v", err)
+	}
+	body, err := store.Get(ctx, "attachments/tenant-a/abc")
+	if err != nil {
+		t.Fatalf("get object: %v", err)
+	}
+	data, err := io.ReadAll(body)
+	body.Close()
+	if err != nil || string(data) != "hello" {
+		t.Fatalf("expected the stored bytes, got %q, %v", data, err)
+	}
+	if _, err := store.Get(ctx, "attachments/tenant-a/missing"); !errors.Is(err, api.ErrNotFound) {
+		t.Fatalf("expected a missing object to be not found, got %v", err)
+	}
+	if err := store.Delete(ctx, "attachments/tenant-a/abc"); err != nil {
+		t.Fatalf("delete object: %v", err)
+	}
+	if _, err := store.Get(ctx, "attachments/tenant-a/abc"); !errors.Is(err, api.ErrNotFound) {
+		t.Fatalf("expected a deleted object to be not found, got %v", err)
+	}
+	if err := store.Delete(ctx, "attachments/tenant-a/abc"); err != nil {
+		t.Fatalf("expected deleting a missing object to succeed, got %v", err)
+	}
+	if err := store.Put(ctx, "attachments/short", strings.NewReader("hi"), 5, "text/plain"); err == nil {
+		t.Fatal("expected a short body to be refused")
+	}
+	if _, err := os.Stat(filepath.Join(root, "attachments", "short")); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("expected no object left from the short body, got %v", err)
+	}
+	for _, key := range []string{"", "../escape", "/etc/passwd", "attachments/../../escape"} {
+		if err := store.Put(ctx, key, strings.NewReader("x"), 1, "text/plain"); err == nil {
+			t.Fatalf("expected key %q to be refused", key)
+		}
+	}
+
+	if err := os.RemoveAll(root); err != nil {
+		t.Fatalf("remove root: %v", err)
+	}
+	if err := store.Ping(ctx); err == nil {
+		t.Fatal("expected a missing root to fail the ping")
+	}
+}
@@ -0,0 +1,92 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/api"
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/invoice"
+)
+
+const paymentColumns = `id, invoice_id, amount, method, received_at, reference, created_at`
+
+// RecordInvoicePayment locks the invoice row with for update before applying
+// the payment, so a concurrent payment waits and then sees this one's amount.
+func (s *PostgresStore) RecordInvoicePayment(ctx context.Context, tenantID string, payment invoice.Payment, allowOverpayment bool, updatedAt string) (invoice.Invoice, error) {
+	var item invoice.Invoice
+	err := s.inTx(ctx, func(tx pgx.Tx) error {
+		var err error
+		item, err = lockLiveInvoice(ctx, tx, tenantID, payment.InvoiceID)
+		if err != nil {
+			return err
+		}
+		// The invoice row is held, so a payment reported twice at once is
+		// found by whichever report comes second.
+		var recorded bool
+		if err := tx.QueryRow(ctx, `select exists (select 1 from invoice_payments where id = $1)`, payment.ID).Scan(&recorded); err != nil {
+			return err
+		}
+		if recorded {
+			return api.ErrConflict
+		}
+		before := item
+		if err := item.ApplyPayment(payment, allowOverpayment); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(ctx, `
+			insert into invoice_payments (tenant_id, `+paymentColumns+`)
+			values ($1, $2, $3, $4, $5, $6, $7, $8)
+		`, tenantID, payment.ID, payment.InvoiceID, payment.Amount, payment.Method, parseTime(payment.ReceivedAt), nullString(payment.Reference), parseTime(payment.CreatedAt)); err != nil {
+			return mapWriteError(err)
+		}
+		item.UpdatedAt = updatedAt
+		item.Version++
+		if _, err := tx.Exec(ctx, `
+			update invoices
+			set amount_paid = $3, status = $4, paid_at = $5, payment_reference = $6, updated_at = $7, version = version + 1
+			where tenant_id = $1 and id = $2
+		`, tenantID, item.ID, item.AmountPaid, string(item.Status), nullTime(item.PaidAt), nullString(item.PaymentReference), parseTime(updatedAt)); err != nil {
+			return err
+		}
+		if err := logChange(ctx, tx, tenantID, api.AuditEntityInvoice, item.ID, api.AuditInvoicePaymentReceived, before, item); err != nil {
+			return err
+		}
+		item.Items, err = listLineItems(ctx, tx, tenantID, item.ID)
+		return err
+	})
+	if err != nil {
+		return invoice.Invoice{}, err
+	}
+	return item, nil
+}
+
+func (s *PostgresStore) ListInvoicePayments(ctx context.Context, tenantID, invoiceID string) ([]invoice.Payment, error) {
+	rows, err := s.pool.Query(ctx, `
+		select `+paymentColumns+`
+		from invoice_payments
+		where tenant_id = $1 and invoice_id = $2
+		order by created_at, id
+	`, tenantID, invoiceID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []invoice.Payment{}
+	for rows.Next() {
+		var item invoice.Payment
+		var receivedAt time.Time
+		var reference sql.NullString
+		var createdAt time.Time
+		if err := rows.Scan(&item.ID, &item.InvoiceID, &item.Amount, &item.Method, &receivedAt, &reference, &createdAt); err != nil {
+			return nil, err
+		}
+		item.ReceivedAt = receivedAt.UTC().Format(time.RFC3339)
+		item.Reference = reference.String
+		item.CreatedAt = createdAt.UTC().Format(time.RFC3339)
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
@@ -0,0 +1,105 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/api"
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/invoice"
+)
+
+func TestPostgresStoreRecordsInvoicePaymentsUnderRowLock(t *testing.T) {
+	databaseURL := os.Getenv("DATABASE_URL")
+	if databaseURL == "" {
+		t.Skip("DATABASE_URL is required for postgres integration tests")
+	}
+
+	testDatabaseURL := createTestDatabase(t, databaseURL)
+	ctx := context.Background()
+	store, err := Open(ctx, testDatabaseURL)
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	defer store.Close()
+	if err := store.Migrate(ctx); err != nil {
+		t.Fatalf("migrate store: %v", err)
+	}
+
+	item := invoice.Invoice{
+		ID:         "inv-1",
+		TenantID:   "tenant-a",
+		Number:     "INV-0001",
+		CustomerID: "cust-1",
+		Currency:   "ILS",
+		Subtotal:   1000,
+		Total:      1000,
+		Status:     invoice.StatusOpen,
+		CreatedAt:  nowRFC3339(),
+		UpdatedAt:  nowRFC3339(),
+	}
+	if _, err := store.CreateInvoice(ctx, item, invoice.DefaultNumberFormat); err != nil {
+		t.Fatalf("create invoice: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	errs := make([]error, 8)
+	for i := range errs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			payment := invoice.Payment{
+				ID:         fmt.Sprintf("pay-%d", i),
+				InvoiceID:  item.ID,
+				Amount:     250,
+				Method:     invoice.PaymentCard,
+				ReceivedAt: nowRFC3339(),
+				CreatedAt:  nowRFC3339(),
+			}
+			_, errs[i] = store.RecordInvoicePayment(ctx, item.TenantID, payment, false, nowRFC3339())
+		}()
+	}
+	wg.Wait()
+
+	accepted := 0
+	for _, err := range errs {
+		switch {
+		case err == nil:
+			accepted++
+		case errors.Is(err, invoice.ErrOverpayment), errors.Is(err, invoice.ErrNotPayable):
+		default:
+			t.Fatalf("record payment: %v", err)
+		}
+	}
+	got, err := store.GetInvoice(ctx, item.TenantID, item.ID)
+	if err != nil {
+		t.Fatalf("get invoice: %v", err)
+	}
+	if accepted != 4 || got.AmountPaid != 1000 || got.Status != invoice.StatusPaid || got.PaidAt == "" {
+		t.Fatalf("expected four payments to settle the invoice, got %d accepted and %#v", accepted, got)
+	}
+	payments, err := store.ListInvoicePayments(ctx, item.TenantID, item.ID)
+	if err != nil || len(payments) != 4 {
+		t.Fatalf("expected four stored payments, got %#v, %v", payments, err)
+	}
+	if others, err := store.ListInvoicePayments(ctx, "tenant-b", item.ID); err != nil || len(others) != 0 {
+		t.Fatalf("expected no payments for another tenant, got %#v, %v", others, err)
+	}
+	// A payment reported again, even once the invoice is settled, is
+	// recognised rather than refused as unpayable.
+	if _, err := store.RecordInvoicePayment(ctx, item.TenantID, payments[0], true, nowRFC3339()); !errors.Is(err, api.ErrConflict) {
+		t.Fatalf("expected a recorded payment ID to conflict, got %v", err)
+	}
+
+	// Payments own amount_paid: a header update must not reset it.
+	got.Number = "INV-0001-A"
+	if err := store.UpdateInvoice(ctx, &got, invoice.DefaultNumberFormat); err != nil {
+		t.Fatalf("update invoice: %v", err)
+	}
+	if after, err := store.GetInvoice(ctx, item.TenantID, item.ID); err != nil || after.AmountPaid != 1000 {
+		t.Fatalf("expected amount paid to survive update, got %#v, %v", after, err)
+	}
+}
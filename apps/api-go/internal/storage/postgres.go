@@ -10,30 +10,112 @@ import (
 
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgconn/ctxwatch"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/api"
 )
 
+// cancelDeadlineDelay is how long a cancelled query gets to stop after the
+// cancel request is sent before its connection is closed instead.
+const cancelDeadlineDelay = 5 * time.Second
+
+const (
+	initialPingBackoff = 250 * time.Millisecond
+	maxPingBackoff     = 5 * time.Second
+)
+
 type PostgresStore struct {
 	pool *pgxpool.Pool
 }
 
+// PoolConfig sizes the connection pool. Zero fields keep pgx's defaults,
+// or whatever the DATABASE_URL's pool_* parameters set.
+type PoolConfig struct {
+	MaxConns        int32
+	MinIdleConns    int32
+	MaxConnLifetime time.Duration
+	MaxConnIdleTime time.Duration
+}
+
 func Open(ctx context.Context, databaseURL string) (*PostgresStore, error) {
+	return OpenWithPool(ctx, databaseURL, PoolConfig{})
+}
+
+// OpenWithPool opens a pool sized by poolCfg and pings the database until
+// it answers or ctx is done, so the server can start alongside a database
+// that is still coming up.
+func OpenWithPool(ctx context.Context, databaseURL string, poolCfg PoolConfig) (*PostgresStore, error) {
 	cfg, err := pgxpool.ParseConfig(databaseURL)
 	if err != nil {
 		return nil, fmt.Errorf("parse DATABASE_URL: %w", err)
 	}
+	if poolCfg.MaxConns > 0 {
+		cfg.MaxConns = poolCfg.MaxConns
+	}
+	if poolCfg.MinIdleConns > 0 {
+		cfg.MinIdleConns = poolCfg.MinIdleConns
+	}
+	if poolCfg.MaxConnLifetime > 0 {
+		cfg.MaxConnLifetime = poolCfg.MaxConnLifetime
+	}
+	if poolCfg.MaxConnIdleTime > 0 {
+		cfg.MaxConnIdleTime = poolCfg.MaxConnIdleTime
+	}
+	// By default pgx only drops the socket when a context is done, and
+	// Postgres keeps running the statement. A cancel request stops it on
+	// the server and keeps the connection usable.
+	cfg.ConnConfig.BuildContextWatcherHandler = func(conn *pgconn.PgConn) ctxwatch.Handler {
+		return &pgconn.CancelRequestContextWatcherHandler{Conn: conn, DeadlineDelay: cancelDeadlineDelay}
+	}
 	pool, err := pgxpool.NewWithConfig(ctx, cfg)
 	if err != nil {
 		return nil, fmt.Errorf("open postgres pool: %w", err)
 	}
-	if err := pool.Ping(ctx); err != nil {
+	if err := pingWithBackoff(ctx, pool); err != nil {
 		pool.Close()
 		return nil, fmt.Errorf("ping postgres: %w", err)
 	}
 	return &PostgresStore{pool: pool}, nil
 }
 
+// pingWithBackoff retries the ping with doubling waits up to maxPingBackoff
+// and returns the last ping error once ctx is done.
+func pingWithBackoff(ctx context.Context, pool *pgxpool.Pool) error {
+	backoff := initialPingBackoff
+	for {
+		err := pool.Ping(ctx)
+		if err == nil {
+			return nil
+		}
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+		backoff = min(2*backoff, maxPingBackoff)
+	}
+}
+
+// PoolStats reports pgx's pool counters. Waits are the acquires that found
+// no idle connection.
+func (s *PostgresStore) PoolStats() api.PoolStats {
+	stat := s.pool.Stat()
+	return api.PoolStats{
+		MaxConns:            stat.MaxConns(),
+		TotalConns:          stat.TotalConns(),
+		InUse:               stat.AcquiredConns(),
+		Idle:                stat.IdleConns(),
+		WaitCount:           stat.EmptyAcquireCount(),
+		WaitDurationSeconds: stat.EmptyAcquireWaitTime().Seconds(),
+	}
+}
+
+func (s *PostgresStore) Ping(ctx context.Context) error {
+	return s.pool.Ping(ctx)
+}
+
 func (s *PostgresStore) Close() {
 	if s.pool != nil {
 		s.pool.Close()
@@ -142,15 +224,17 @@ func (s *PostgresStore) UpdateProviderConfig(ctx context.Context, item api.Provi
 	return rowsAffectedOrNotFound(tag, err)
 }
 
-func (s *PostgresStore) ListCollectionJobs(ctx context.Context, tenantID string) ([]api.CollectionJob, error) {
+const collectionJobColumns = `id, tenant_id, status, attempt, providers_json, month, year, graph_client_id, graph_authority, graph_token_cache_path, interactive_auth, ` +
+	`request_id, run_summary_path, invoices_dir, retry_of, error_text, queued_at, started_at, finished_at, created_at, updated_at`
+
+func (s *PostgresStore) ListCollectionJobs(ctx context.Context, tenantID, status string) ([]api.CollectionJob, error) {
 	rows, err := s.pool.Query(ctx, `
-		select id, tenant_id, status, providers_json, month, year, graph_client_id, graph_authority, graph_token_cache_path, interactive_auth,
-		       request_id, run_summary_path, invoices_dir, retry_of, error_text,
-		       started_at, finished_at, created_at, updated_at
+		select `+collectionJobColumns+`
 		from collection_jobs
 		where tenant_id = $1
+		  and ($2 = '' or status = $2)
 		order by updated_at desc
-	`, tenantID)
+	`, tenantID, status)
 	if err != nil {
 		return nil, err
 	}
@@ -160,9 +244,7 @@ func (s *PostgresStore) ListCollectionJobs(ctx context.Context, tenantID string)
 
 func (s *PostgresStore) GetCollectionJob(ctx context.Context, tenantID, id string) (api.CollectionJob, error) {
 	row := s.pool.QueryRow(ctx, `
-		select id, tenant_id, status, providers_json, month, year, graph_client_id, graph_authority, graph_token_cache_path, interactive_auth,
-		       request_id, run_summary_path, invoices_dir, retry_of, error_text,
-		       started_at, finished_at, created_at, updated_at
+		select `+collectionJobColumns+`
 		from collection_jobs
 		where tenant_id = $1 and id = $2
 	`, tenantID, id)
@@ -175,41 +257,44 @@ func (s *PostgresStore) CreateCollectionJob(ctx context.Context, item api.Collec
 		return fmt.Errorf("marshal collection providers: %w", err)
 	}
 	_, err = s.pool.Exec(ctx, `
-		insert into collection_jobs (
-			id, tenant_id, status, providers_json, month, year, graph_client_id, graph_authority, graph_token_cache_path, interactive_auth,
-			request_id, run_summary_path, invoices_dir, retry_of, error_text, started_at, finished_at, created_at, updated_at
-		) values ($1, $2, $3, $4::jsonb, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19)
-	`, item.ID, item.TenantID, item.Status, providersJSON, item.Month, item.Year, nullString(item.GraphClientID), nullString(item.GraphAuthority), nullString(item.GraphTokenCache), item.InteractiveAuth, nullString(item.RequestID), nullString(item.RunSummaryPath), nullString(item.InvoicesDir), nullString(item.RetryOf), nullString(item.Error), nullTime(item.StartedAt), nullTime(item.FinishedAt), parseTime(item.CreatedAt), parseTime(item.UpdatedAt))
+		insert into collection_jobs (`+collectionJobColumns+`)
+		values ($1, $2, $3, $4, $5::jsonb, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21)
+	`, item.ID, item.TenantID, item.Status, item.Attempt, providersJSON, item.Month, item.Year, nullString(item.GraphClientID), nullString(item.GraphAuthority), nullString(item.GraphTokenCache), item.InteractiveAuth, nullString(item.RequestID), nullString(item.RunSummaryPath), nullString(item.InvoicesDir), nullString(item.RetryOf), nullString(item.Error), nullTime(item.QueuedAt), nullTime(item.StartedAt), nullTime(item.FinishedAt), parseTime(item.CreatedAt), parseTime(item.UpdatedAt))
 	return err
 }
 
-func (s *PostgresStore) UpdateCollectionJob(ctx context.Context, item api.CollectionJob) error {
-	providersJSON, err := json.Marshal(item.Providers)
-	if err != nil {
-		return fmt.Errorf("marshal collection providers: %w", err)
-	}
+// TransitionCollectionJob writes the lifecycle fields in one statement guarded
+// by the expected current status, so a transition either fully lands or
+// reports the conflict.
+func (s *PostgresStore) TransitionCollectionJob(ctx context.Context, item api.CollectionJob, from string) error {
 	tag, err := s.pool.Exec(ctx, `
 		update collection_jobs
-		set status = $3,
-			providers_json = $4::jsonb,
-			month = $5,
-			year = $6,
-			graph_client_id = $7,
-			graph_authority = $8,
-			graph_token_cache_path = $9,
-			interactive_auth = $10,
-			request_id = $11,
-			run_summary_path = $12,
-			invoices_dir = $13,
-			retry_of = $14,
-			error_text = $15,
-			started_at = $16,
-			finished_at = $17,
-			created_at = $18,
-			updated_at = $19
-		where id = $1 and tenant_id = $2
-	`, item.ID, item.TenantID, item.Status, providersJSON, item.Month, item.Year, nullString(item.GraphClientID), nullString(item.GraphAuthority), nullString(item.GraphTokenCache), item.InteractiveAuth, nullString(item.RequestID), nullString(item.RunSummaryPath), nullString(item.InvoicesDir), nullString(item.RetryOf), nullString(item.Error), nullTime(item.StartedAt), nullTime(item.FinishedAt), parseTime(item.CreatedAt), parseTime(item.UpdatedAt))
-	return rowsAffectedOrNotFound(tag, err)
+		set status = $4,
+			attempt = $5,
+			run_summary_path = $6,
+			invoices_dir = $7,
+			error_text = $8,
+			started_at = $9,
+			finished_at = $10,
+			updated_at = $11
+		where id = $1 and tenant_id = $2 and status = $3
+	`, item.ID, item.TenantID, from, item.Status, item.Attempt, nullString(item.RunSummaryPath), nullString(item.InvoicesDir), nullString(item.Error), nullTime(item.StartedAt), nullTime(item.FinishedAt), parseTime(item.UpdatedAt))
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 1 {
+		return nil
+	}
+	var exists bool
+	if err := s.pool.QueryRow(ctx, `
+		select exists (select 1 from collection_jobs where id = $1 and tenant_id = $2)
+	`, item.ID, item.TenantID).Scan(&exists); err != nil {
+		return err
+	}
+	if exists {
+		return api.ErrConflict
+	}
+	return api.ErrNotFound
 }
 
 func (s *PostgresStore) ListReports(ctx context.Context, tenantID string) ([]api.Report, error) {
@@ -448,11 +533,12 @@ func scanCollectionJob(row collectionJobScanner) (api.CollectionJob, error) {
 	var invoicesDir sql.NullString
 	var retryOf sql.NullString
 	var errorText sql.NullString
+	var queuedAt sql.NullTime
 	var startedAt sql.NullTime
 	var finishedAt sql.NullTime
 	var createdAt time.Time
 	var updatedAt time.Time
-	err := row.Scan(&item.ID, &item.TenantID, &item.Status, &providersJSON, &item.Month, &item.Year, &graphClientID, &graphAuthority, &graphTokenCache, &interactiveAuth, &requestID, &runSummaryPath, &invoicesDir, &retryOf, &errorText, &startedAt, &finishedAt, &createdAt, &updatedAt)
+	err := row.Scan(&item.ID, &item.TenantID, &item.Status, &item.Attempt, &providersJSON, &item.Month, &item.Year, &graphClientID, &graphAuthority, &graphTokenCache, &interactiveAuth, &requestID, &runSummaryPath, &invoicesDir, &retryOf, &errorText, &queuedAt, &startedAt, &finishedAt, &createdAt, &updatedAt)
 	if err != nil {
 		return api.CollectionJob{}, mapScanError(err)
 	}
@@ -468,6 +554,7 @@ func scanCollectionJob(row collectionJobScanner) (api.CollectionJob, error) {
 	item.GraphAuthority = graphAuthority.String
 	item.GraphTokenCache = graphTokenCache.String
 	item.InteractiveAuth = interactiveAuth
+	item.QueuedAt = nullableTimeString(queuedAt)
 	item.StartedAt = nullableTimeString(startedAt)
 	item.FinishedAt = nullableTimeString(finishedAt)
 	item.CreatedAt = createdAt.UTC().Format(time.RFC3339)
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"os"
+	"strconv"
 	"strings"
 	"testing"
 	"time"
@@ -30,12 +31,10 @@ func TestMigrateBootstrapsSchema(t *testing.T) {
 		t.Fatalf("migrate store: %v", err)
 	}
 
-	var count int
-	if err := store.pool.QueryRow(ctx, `select count(*) from schema_migrations`).Scan(&count); err != nil {
-		t.Fatalf("query schema_migrations: %v", err)
-	}
-	if count == 0 {
-		t.Fatal("expected at least one applied migration")
+	version, dirty, err := store.SchemaVersion(ctx)
+	latest, _ := LatestSchemaVersion()
+	if err != nil || dirty || version != latest {
+		t.Fatalf("expected clean schema at version %d, got %d dirty=%t: %v", latest, version, dirty, err)
 	}
 }
 
@@ -120,7 +119,8 @@ func TestPostgresStorePersistsTenantScopedResources(t *testing.T) {
 	job := api.CollectionJob{
 		ID:              "job-1",
 		TenantID:        "tenant-a",
-		Status:          "queued",
+		Status:          api.CollectionJobQueued,
+		Attempt:         1,
 		Providers:       []string{"gmail", "outlook"},
 		Month:           6,
 		Year:            2026,
@@ -135,11 +135,25 @@ func TestPostgresStorePersistsTenantScopedResources(t *testing.T) {
 	if err := store.CreateCollectionJob(ctx, job); err != nil {
 		t.Fatalf("create collection job: %v", err)
 	}
-	job.Status = "running"
+	job.Status = api.CollectionJobRunning
 	job.StartedAt = nowRFC3339()
 	job.UpdatedAt = nowRFC3339()
-	if err := store.UpdateCollectionJob(ctx, job); err != nil {
-		t.Fatalf("update collection job: %v", err)
+	if err := store.TransitionCollectionJob(ctx, job, api.CollectionJobQueued); err != nil {
+		t.Fatalf("transition collection job: %v", err)
+	}
+	if err := store.TransitionCollectionJob(ctx, job, api.CollectionJobQueued); !errors.Is(err, api.ErrConflict) {
+		t.Fatalf("expected stale transition to conflict, got %v", err)
+	}
+	running, err := store.ListCollectionJobs(ctx, "tenant-a", api.CollectionJobRunning)
+	if err != nil {
+		t.Fatalf("list running collection jobs: %v", err)
+	}
+	queued, err := store.ListCollectionJobs(ctx, "tenant-a", api.CollectionJobQueued)
+	if err != nil {
+		t.Fatalf("list queued collection jobs: %v", err)
+	}
+	if len(running) != 1 || len(queued) != 0 {
+		t.Fatalf("unexpected status filter results: running=%d queued=%d", len(running), len(queued))
 	}
 	gotJob, err := store.GetCollectionJob(ctx, "tenant-a", job.ID)
 	if err != nil {
@@ -233,7 +247,7 @@ func TestPostgresStorePersistsTenantScopedResources(t *testing.T) {
 	}
 }
 
-func createTestDatabase(t *testing.T, databaseURL string) string {
+func createTestDatabase(t testing.TB, databaseURL string) string {
 	t.Helper()
 
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
@@ -250,7 +264,7 @@ func createTestDatabase(t *testing.T, databaseURL string) string {
 		t.Fatalf("connect admin database: %v", err)
 	}
 
-	dbName := "invoices_api_test_" + strings.ReplaceAll(nowRFC3339(), ":", "_")
+	dbName := "invoices_api_test_" + strconv.FormatInt(time.Now().UnixNano(), 10)
 	if _, err := adminConn.Exec(ctx, `create database "`+dbName+`"`); err != nil {
 		t.Fatalf("create test database: %v", err)
 	}
@@ -286,3 +300,79 @@ func createTestDatabase(t *testing.T, databaseURL string) string {
 func nowRFC3339() string {
 	return time.Now().UTC().Format(time.RFC3339)
 }
+
+func TestPostgresStoreCancelsQueriesWhenContextIsDone(t *testing.T) {
+	databaseURL := os.Getenv("DATABASE_URL")
+	if databaseURL == "" {
+		t.Skip("DATABASE_URL is required for postgres integration tests")
+	}
+
+	testDatabaseURL := createTestDatabase(t, databaseURL)
+	store, err := Open(context.Background(), testDatabaseURL)
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	defer store.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	started := time.Now()
+	_, err = store.pool.Exec(ctx, "select pg_sleep(30)")
+	if err == nil {
+		t.Fatal("expected the slow query to fail once its context was done")
+	}
+	if elapsed := time.Since(started); elapsed > 5*time.Second {
+		t.Fatalf("expected the query to stop at its deadline, took %s", elapsed)
+	}
+
+	// The cancel request must stop the statement in Postgres, not just
+	// abandon it there.
+	var running int
+	if err := store.pool.QueryRow(context.Background(), `
+		select count(*) from pg_stat_activity
+		where state = 'active' and query = 'select pg_sleep(30)'
+	`).Scan(&running); err != nil {
+		t.Fatalf("read pg_stat_activity: %v", err)
+	}
+	if running != 0 {
+		t.Fatalf("expected the slow query to be cancelled in postgres, %d still running", running)
+	}
+}
+
+func TestOpenWithPoolAppliesPoolConfig(t *testing.T) {
+	databaseURL := os.Getenv("DATABASE_URL")
+	if databaseURL == "" {
+		t.Skip("DATABASE_URL is required for postgres integration tests")
+	}
+
+	testDatabaseURL := createTestDatabase(t, databaseURL)
+	store, err := OpenWithPool(context.Background(), testDatabaseURL, PoolConfig{MaxConns: 3, MaxConnLifetime: time.Minute})
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	defer store.Close()
+
+	conn, err := store.pool.Acquire(context.Background())
+	if err != nil {
+		t.Fatalf("acquire connection: %v", err)
+	}
+	stats := store.PoolStats()
+	conn.Release()
+	if stats.MaxConns != 3 || stats.InUse != 1 || stats.TotalConns < 1 {
+		t.Fatalf("unexpected pool stats %#v", stats)
+	}
+}
+
+func TestOpenWithPoolStopsRetryingWhenContextIsDone(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 600*time.Millisecond)
+	defer cancel()
+
+	started := time.Now()
+	_, err := OpenWithPool(ctx, "postgres://invoicer@127.0.0.1:1/invoices?connect_timeout=1", PoolConfig{})
+	if err == nil || !strings.Contains(err.Error(), "ping postgres") {
+		t.Fatalf("expected the ping to fail, got %v", err)
+	}
+	if elapsed := time.Since(started); elapsed > 3*time.Second {
+		t.Fatalf("expected retries to stop with the context, took %s", elapsed)
+	}
+}
@@ -0,0 +1,238 @@
+package storage
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/api"
+)
+
+// purgeableCustomer is the condition, on customers c with the deletion
+// cutoff in $1, under which a customer may be purged: no recurring invoice
+// bills to it, and no invoice the purge keeps.
+const purgeableCustomer = `
+	c.deleted_at < $1
+	and not exists (
+		select 1 from invoices i
+		where i.tenant_id = c.tenant_id and i.customer_id = c.id
+			and (i.deleted_at is null or i.deleted_at >= $1)
+	)
+	and not exists (
+		select 1 from recurring_invoices r
+		where r.tenant_id = c.tenant_id and r.customer_id = c.id
+	)`
+
+// exclusiveLockPrefix keeps TryExclusive's advisory lock keys apart from
+// those of other applications sharing the database.
+const exclusiveLockPrefix = "invoicer:"
+
+func (s *PostgresStore) ListPurgeableInvoices(ctx context.Context, deletedBefore string, limit int) ([]api.PurgeRecord, error) {
+	return s.listPurgeRecords(ctx, `
+		select tenant_id, id, deleted_at
+		from invoices
+		where deleted_at < $1
+		order by deleted_at, id
+		limit $2
+	`, deletedBefore, limit)
+}
+
+func (s *PostgresStore) PurgeInvoice(ctx context.Context, tenantID, id, deletedBefore string) ([]string, error) {
+	var checksums []string
+	err := s.inTx(ctx, func(tx pgx.Tx) error {
+		var deletedAt time.Time
+		if err := tx.QueryRow(ctx, `
+			select deleted_at
+			from invoices
+			where tenant_id = $1 and id = $2 and deleted_at < $3
+			for update
+		`, tenantID, id, parseTime(deletedBefore)).Scan(&deletedAt); err != nil {
+			return mapScanError(err)
+		}
+		rows, err := tx.Query(ctx, `
+			select distinct checksum
+			from invoice_attachments
+			where tenant_id = $1 and invoice_id = $2
+			order by checksum
+		`, tenantID, id)
+		if err != nil {
+			return err
+		}
+		checksums = []string{}
+		for rows.Next() {
+			var checksum string
+			if err := rows.Scan(&checksum); err != nil {
+				rows.Close()
+				return err
+			}
+			checksums = append(checksums, checksum)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		// Lines, payments, credit notes, reminders, attachments and share
+		// links go with the invoice through their cascading foreign keys.
+		if _, err := tx.Exec(ctx, `
+			delete from invoices
+			where tenant_id = $1 and id = $2
+		`, tenantID, id); err != nil {
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return checksums, nil
+}
+
+func (s *PostgresStore) ListPurgeableCustomers(ctx context.Context, deletedBefore string, limit int) ([]api.PurgeRecord, error) {
+	return s.listPurgeRecords(ctx, `
+		select c.tenant_id, c.id, c.deleted_at
+		from customers c
+		where `+purgeableCustomer+`
+		order by c.deleted_at, c.id
+		limit $2
+	`, deletedBefore, limit)
+}
+
+func (s *PostgresStore) PurgeCustomer(ctx context.Context, tenantID, id, deletedBefore string) error {
+	tag, err := s.pool.Exec(ctx, `
+		delete from customers c
+		where c.tenant_id = $2 and c.id = $3 and `+purgeableCustomer,
+		parseTime(deletedBefore), tenantID, id)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return api.ErrNotFound
+	}
+	return nil
+}
+
+func (s *PostgresStore) PurgeAuditEntries(ctx context.Context, createdBefore string, limit int) (int, error) {
+	var deleted int
+	err := s.inTx(ctx, func(tx pgx.Tx) error {
+		events, err := tx.Exec(ctx, `
+			delete from audit_events
+			where id in (
+				select id from audit_events
+				where created_at < $1
+				order by created_at
+				limit $2
+			)
+		`, parseTime(createdBefore), limit)
+		if err != nil {
+			return err
+		}
+		deleted = int(events.RowsAffected())
+		if deleted == limit {
+			return nil
+		}
+		entries, err := tx.Exec(ctx, `
+			delete from audit_log
+			where seq in (
+				select seq from audit_log
+				where created_at < $1
+				order by seq
+				limit $2
+			)
+		`, parseTime(createdBefore), limit-deleted)
+		if err != nil {
+			return err
+		}
+		deleted += int(entries.RowsAffected())
+		return nil
+	})
+	return deleted, err
+}
+
+func (s *PostgresStore) PurgeIdempotencyKeys(ctx context.Context, expiredBefore string, limit int) (int, error) {
+	tag, err := s.pool.Exec(ctx, `
+		delete from idempotency_keys
+		where (tenant_id, scope, key) in (
+			select tenant_id, scope, key from idempotency_keys
+			where expires_at < $1
+			order by expires_at
+			limit $2
+		)
+	`, parseTime(expiredBefore), limit)
+	if err != nil {
+		return 0, err
+	}
+	return int(tag.RowsAffected()), nil
+}
+
+func (s *PostgresStore) CountPurgeable(ctx context.Context, deletedBefore, auditBefore, expiredBefore string) (api.PurgeSummary, error) {
+	var summary api.PurgeSummary
+	err := s.pool.QueryRow(ctx, `
+		select
+			(select count(*) from invoices where deleted_at < $1),
+			(select count(*) from customers c where `+purgeableCustomer+`),
+			(
+				select count(distinct a.object_key)
+				from invoice_attachments a
+				join invoices i on i.tenant_id = a.tenant_id and i.id = a.invoice_id
+				where i.deleted_at < $1
+					and not exists (
+						select 1
+						from invoice_attachments other
+						join invoices kept on kept.tenant_id = other.tenant_id and kept.id = other.invoice_id
+						where other.tenant_id = a.tenant_id and other.checksum = a.checksum
+							and (kept.deleted_at is null or kept.deleted_at >= $1)
+					)
+			),
+			(select count(*) from audit_events where created_at < $2)
+				+ (select count(*) from audit_log where created_at < $2),
+			(select count(*) from idempotency_keys where expires_at < $3)
+	`, parseTime(deletedBefore), parseTime(auditBefore), parseTime(expiredBefore)).Scan(&summary.Invoices, &summary.Customers, &summary.Attachments, &summary.AuditEntries, &summary.IdempotencyKeys)
+	return summary, err
+}
+
+// TryExclusive holds a session advisory lock on a connection of its own
+// while fn runs. Should the connection break, Postgres releases the lock
+// with it.
+func (s *PostgresStore) TryExclusive(ctx context.Context, name string, fn func(context.Context) error) (bool, error) {
+	conn, err := s.pool.Acquire(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer conn.Release()
+	key := exclusiveLockPrefix + name
+	var locked bool
+	if err := conn.QueryRow(ctx, `select pg_try_advisory_lock(hashtextextended($1, 0))`, key).Scan(&locked); err != nil {
+		return false, err
+	}
+	if !locked {
+		return false, nil
+	}
+	defer func() {
+		if _, err := conn.Exec(context.WithoutCancel(ctx), `select pg_advisory_unlock(hashtextextended($1, 0))`, key); err != nil {
+			// Closing the connection releases the lock instead, so it is
+			// not handed back to the pool still held.
+			_ = conn.Conn().Close(context.WithoutCancel(ctx))
+		}
+	}()
+	return true, fn(ctx)
+}
+
+func (s *PostgresStore) listPurgeRecords(ctx context.Context, query, deletedBefore string, limit int) ([]api.PurgeRecord, error) {
+	rows, err := s.pool.Query(ctx, query, parseTime(deletedBefore), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []api.PurgeRecord{}
+	for rows.Next() {
+		var item api.PurgeRecord
+		var deletedAt time.Time
+		if err := rows.Scan(&item.TenantID, &item.ID, &deletedAt); err != nil {
+			return nil, err
+		}
+		item.DeletedAt = deletedAt.UTC().Format(time.RFC3339)
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
@@ -0,0 +1,127 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"os"
+	"slices"
+	"testing"
+
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/api"
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/invoice"
+)
+
+func TestPostgresStorePurgesExpiredRecords(t *testing.T) {
+	databaseURL := os.Getenv("DATABASE_URL")
+	if databaseURL == "" {
+		t.Skip("DATABASE_URL is required for postgres integration tests")
+	}
+
+	testDatabaseURL := createTestDatabase(t, databaseURL)
+	ctx := context.Background()
+	store, err := Open(ctx, testDatabaseURL)
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	defer store.Close()
+	if err := store.Migrate(ctx); err != nil {
+		t.Fatalf("migrate store: %v", err)
+	}
+
+	for _, id := range []string{"cust-kept", "cust-gone"} {
+		if err := store.CreateCustomer(ctx, api.Customer{ID: id, TenantID: "tenant-a", Name: id, CreatedAt: nowRFC3339(), UpdatedAt: nowRFC3339()}); err != nil {
+			t.Fatalf("create customer: %v", err)
+		}
+	}
+	for _, id := range []string{"inv-old", "inv-recent"} {
+		if _, err := store.CreateInvoice(ctx, invoice.Invoice{ID: id, TenantID: "tenant-a", Number: id, CustomerID: "cust-kept", Currency: "ILS", Status: invoice.StatusDraft, CreatedAt: nowRFC3339(), UpdatedAt: nowRFC3339()}, invoice.DefaultNumberFormat); err != nil {
+			t.Fatalf("create invoice: %v", err)
+		}
+	}
+	for _, attachment := range []api.Attachment{
+		{ID: "att-shared-old", InvoiceID: "inv-old", Checksum: "shared", ObjectKey: "attachments/tenant-a/shared"},
+		{ID: "att-shared-recent", InvoiceID: "inv-recent", Checksum: "shared", ObjectKey: "attachments/tenant-a/shared"},
+		{ID: "att-own", InvoiceID: "inv-old", Checksum: "own", ObjectKey: "attachments/tenant-a/own"},
+	} {
+		attachment.TenantID, attachment.Filename, attachment.Size, attachment.ContentType, attachment.CreatedAt = "tenant-a", "file.pdf", 5, "application/pdf", nowRFC3339()
+		if _, _, err := store.CreateInvoiceAttachment(ctx, attachment); err != nil {
+			t.Fatalf("create attachment: %v", err)
+		}
+	}
+	if _, err := store.pool.Exec(ctx, `
+		update invoices set deleted_at = case id when 'inv-old' then timestamptz '2020-01-01T00:00:00Z' else now() end
+	`); err != nil {
+		t.Fatalf("soft-delete invoices: %v", err)
+	}
+	if _, err := store.pool.Exec(ctx, `update customers set deleted_at = '2020-01-01T00:00:00Z'`); err != nil {
+		t.Fatalf("soft-delete customers: %v", err)
+	}
+	if err := store.CreateAuditEvent(ctx, api.AuditEvent{ID: "audit-old", TenantID: "tenant-a", Action: "invoice.created", EntityType: "invoice", EntityID: "inv-old", Message: "old", CreatedAt: "2010-01-01T00:00:00Z"}); err != nil {
+		t.Fatalf("record audit event: %v", err)
+	}
+	for _, record := range []api.IdempotencyRecord{
+		{TenantID: "tenant-a", Scope: "POST /v1/invoices", Key: "expired", CreatedAt: "2020-01-01T00:00:00Z", ExpiresAt: "2020-01-02T00:00:00Z"},
+		{TenantID: "tenant-a", Scope: "POST /v1/invoices", Key: "live", CreatedAt: nowRFC3339(), ExpiresAt: "2099-01-01T00:00:00Z"},
+	} {
+		if _, _, err := store.ClaimIdempotencyKey(ctx, record); err != nil {
+			t.Fatalf("claim idempotency key: %v", err)
+		}
+	}
+
+	const cutoff = "2021-01-01T00:00:00Z"
+	summary, err := store.CountPurgeable(ctx, cutoff, cutoff, cutoff)
+	if want := (api.PurgeSummary{Invoices: 1, Customers: 1, Attachments: 1, AuditEntries: 1, IdempotencyKeys: 1}); err != nil || summary != want {
+		t.Fatalf("expected %#v purgeable, got %#v, %v", want, summary, err)
+	}
+	invoices, err := store.ListPurgeableInvoices(ctx, cutoff, 10)
+	if err != nil || len(invoices) != 1 || invoices[0].ID != "inv-old" || invoices[0].DeletedAt != "2020-01-01T00:00:00Z" {
+		t.Fatalf("expected inv-old purgeable, got %#v, %v", invoices, err)
+	}
+	// The customer is still billed by inv-old until it goes.
+	if customers, err := store.ListPurgeableCustomers(ctx, cutoff, 10); err != nil || len(customers) != 1 || customers[0].ID != "cust-gone" {
+		t.Fatalf("expected only cust-gone purgeable, got %#v, %v", customers, err)
+	}
+
+	checksums, err := store.PurgeInvoice(ctx, "tenant-a", "inv-old", cutoff)
+	if err != nil || !slices.Equal(checksums, []string{"own", "shared"}) {
+		t.Fatalf("expected the purged invoice's checksums, got %v, %v", checksums, err)
+	}
+	if _, err := store.GetInvoice(ctx, "tenant-a", "inv-old"); !errors.Is(err, api.ErrNotFound) {
+		t.Fatalf("expected the invoice to be gone, got %v", err)
+	}
+	for checksum, want := range map[string]bool{"own": false, "shared": true} {
+		var stored bool
+		if err := store.LockAttachmentObject(ctx, "tenant-a", checksum, func(ctx context.Context) error {
+			stored, err = store.AttachmentObjectStored(ctx, "tenant-a", checksum)
+			return err
+		}); err != nil || stored != want {
+			t.Fatalf("%s: expected stored %v after the purge, got %v, %v", checksum, want, stored, err)
+		}
+	}
+	if _, err := store.PurgeInvoice(ctx, "tenant-a", "inv-recent", cutoff); !errors.Is(err, api.ErrNotFound) {
+		t.Fatalf("expected a recently deleted invoice to be refused, got %v", err)
+	}
+
+	if err := store.PurgeCustomer(ctx, "tenant-a", "cust-gone", cutoff); err != nil {
+		t.Fatalf("purge customer: %v", err)
+	}
+	if err := store.PurgeCustomer(ctx, "tenant-a", "cust-kept", cutoff); !errors.Is(err, api.ErrNotFound) {
+		t.Fatalf("expected a customer with a kept invoice to be refused, got %v", err)
+	}
+	if deleted, err := store.PurgeAuditEntries(ctx, cutoff, 10); err != nil || deleted != 1 {
+		t.Fatalf("expected one audit entry purged, got %d, %v", deleted, err)
+	}
+	if deleted, err := store.PurgeIdempotencyKeys(ctx, cutoff, 10); err != nil || deleted != 1 {
+		t.Fatalf("expected one idempotency key purged, got %d, %v", deleted, err)
+	}
+
+	ran, err := store.TryExclusive(ctx, "purge", func(ctx context.Context) error {
+		if again, err := store.TryExclusive(ctx, "purge", func(context.Context) error { return nil }); err != nil || again {
+			t.Fatalf("expected the held lock to refuse a second holder, got %v, %v", again, err)
+		}
+		return nil
+	})
+	if err != nil || !ran {
+		t.Fatalf("expected the lock to be taken, got %v, %v", ran, err)
+	}
+}
@@ -0,0 +1,176 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/api"
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/invoice"
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/tax"
+)
+
+const recurringInvoiceColumns = `id, tenant_id, customer_id, currency, items, tax_rounding, invoice_status, due_days, frequency, interval_count, anchor_day, start_date, status, next_run_at, last_run_at, last_invoice_id, created_at, updated_at`
+
+func (s *PostgresStore) ListRecurringInvoices(ctx context.Context, tenantID string) ([]api.RecurringInvoice, error) {
+	rows, err := s.pool.Query(ctx, `
+		select `+recurringInvoiceColumns+`
+		from recurring_invoices
+		where tenant_id = $1
+		order by created_at desc
+	`, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return collectRecurringInvoices(rows)
+}
+
+func (s *PostgresStore) GetRecurringInvoice(ctx context.Context, tenantID, id string) (api.RecurringInvoice, error) {
+	return scanRecurringInvoice(s.pool.QueryRow(ctx, `
+		select `+recurringInvoiceColumns+`
+		from recurring_invoices
+		where tenant_id = $1 and id = $2
+	`, tenantID, id))
+}
+
+func (s *PostgresStore) CreateRecurringInvoice(ctx context.Context, item api.RecurringInvoice) error {
+	items, err := json.Marshal(item.Items)
+	if err != nil {
+		return fmt.Errorf("marshal recurring invoice items: %w", err)
+	}
+	_, err = s.pool.Exec(ctx, `
+		insert into recurring_invoices (`+recurringInvoiceColumns+`)
+		values ($1, $2, $3, $4, $5::jsonb, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18)
+	`, item.ID, item.TenantID, item.CustomerID, item.Currency, items, taxRounding(item.TaxRounding), string(item.InvoiceStatus), item.DueDays,
+		string(item.Recurrence.Frequency), item.Recurrence.Interval, item.Recurrence.AnchorDay, item.Recurrence.StartDate, item.Status,
+		parseTime(item.NextRunAt), nullTime(item.LastRunAt), nullString(item.LastInvoiceID), parseTime(item.CreatedAt), parseTime(item.UpdatedAt))
+	return mapWriteError(err)
+}
+
+// UpdateRecurringInvoice writes the fields pause and resume change; the
+// template and recurrence are fixed once created.
+func (s *PostgresStore) UpdateRecurringInvoice(ctx context.Context, item api.RecurringInvoice) error {
+	tag, err := s.pool.Exec(ctx, `
+		update recurring_invoices
+		set status = $3, next_run_at = $4, updated_at = $5
+		where tenant_id = $1 and id = $2
+	`, item.TenantID, item.ID, item.Status, parseTime(item.NextRunAt), parseTime(item.UpdatedAt))
+	return rowsAffectedOrNotFound(tag, err)
+}
+
+func (s *PostgresStore) ListDueRecurringInvoices(ctx context.Context, now string, limit int) ([]api.RecurringInvoice, error) {
+	rows, err := s.pool.Query(ctx, `
+		select `+recurringInvoiceColumns+`
+		from recurring_invoices
+		where status = 'active' and next_run_at <= $1
+		order by next_run_at
+		limit $2
+	`, parseTime(now), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return collectRecurringInvoices(rows)
+}
+
+// RecordRecurringRun locks the recurring invoice so a concurrent pause or
+// worker waits, then claims the period in recurring_invoice_runs. Only the
+// transaction that inserts the run row writes the invoice.
+func (s *PostgresStore) RecordRecurringRun(ctx context.Context, run api.RecurringRun, numbering invoice.NumberFormat) (invoice.Invoice, bool, error) {
+	var generated invoice.Invoice
+	created := false
+	err := s.inTx(ctx, func(tx pgx.Tx) error {
+		generated = run.Invoice
+		var status string
+		var nextRunAt time.Time
+		err := tx.QueryRow(ctx, `
+			select status, next_run_at
+			from recurring_invoices
+			where tenant_id = $1 and id = $2
+			for update
+		`, run.TenantID, run.RecurringInvoiceID).Scan(&status, &nextRunAt)
+		if err != nil {
+			return mapScanError(err)
+		}
+		if status != api.RecurringActive || !nextRunAt.Equal(parseTime(run.ScheduledAt)) {
+			return api.ErrConflict
+		}
+		tag, err := tx.Exec(ctx, `
+			insert into recurring_invoice_runs (tenant_id, recurring_invoice_id, period, invoice_id, created_at)
+			values ($1, $2, $3, $4, $5)
+			on conflict do nothing
+		`, run.TenantID, run.RecurringInvoiceID, run.Period, run.Invoice.ID, parseTime(run.Invoice.CreatedAt))
+		if err != nil {
+			return err
+		}
+		created = tag.RowsAffected() == 1
+		if created {
+			if err := numberInvoices(ctx, tx, []*invoice.Invoice{&generated}, numbering, nil); err != nil {
+				return err
+			}
+			if err := insertInvoice(ctx, tx, generated); err != nil {
+				return err
+			}
+		}
+		_, err = tx.Exec(ctx, `
+			update recurring_invoices
+			set next_run_at = $3,
+				last_run_at = $4,
+				last_invoice_id = case when $5 then $6 else last_invoice_id end,
+				updated_at = $7
+			where tenant_id = $1 and id = $2
+		`, run.TenantID, run.RecurringInvoiceID, parseTime(run.NextRunAt), parseTime(run.ScheduledAt), created, run.Invoice.ID, parseTime(run.Invoice.CreatedAt))
+		return err
+	})
+	if err != nil {
+		return invoice.Invoice{}, false, err
+	}
+	return generated, created, nil
+}
+
+type recurringInvoiceScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanRecurringInvoice(row recurringInvoiceScanner) (api.RecurringInvoice, error) {
+	var item api.RecurringInvoice
+	var items []byte
+	var rounding, invoiceStatus, frequency string
+	var startDate, nextRunAt, createdAt, updatedAt time.Time
+	var lastRunAt sql.NullTime
+	var lastInvoiceID sql.NullString
+	err := row.Scan(&item.ID, &item.TenantID, &item.CustomerID, &item.Currency, &items, &rounding, &invoiceStatus, &item.DueDays,
+		&frequency, &item.Recurrence.Interval, &item.Recurrence.AnchorDay, &startDate, &item.Status, &nextRunAt, &lastRunAt, &lastInvoiceID, &createdAt, &updatedAt)
+	if err != nil {
+		return api.RecurringInvoice{}, mapScanError(err)
+	}
+	if err := json.Unmarshal(items, &item.Items); err != nil {
+		return api.RecurringInvoice{}, fmt.Errorf("unmarshal recurring invoice items: %w", err)
+	}
+	item.TaxRounding = tax.Rounding(rounding)
+	item.InvoiceStatus = invoice.Status(invoiceStatus)
+	item.Recurrence.Frequency = invoice.Frequency(frequency)
+	item.Recurrence.StartDate = startDate.Format(time.DateOnly)
+	item.NextRunAt = nextRunAt.UTC().Format(time.RFC3339)
+	item.LastRunAt = nullableTimeString(lastRunAt)
+	item.LastInvoiceID = lastInvoiceID.String
+	item.CreatedAt = createdAt.UTC().Format(time.RFC3339)
+	item.UpdatedAt = updatedAt.UTC().Format(time.RFC3339)
+	return item, nil
+}
+
+func collectRecurringInvoices(rows pgx.Rows) ([]api.RecurringInvoice, error) {
+	items := []api.RecurringInvoice{}
+	for rows.Next() {
+		item, err := scanRecurringInvoice(rows)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
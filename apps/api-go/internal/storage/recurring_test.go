@@ -0,0 +1,101 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/api"
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/invoice"
+)
+
+func TestPostgresStoreRecordsRecurringRunsOncePerPeriod(t *testing.T) {
+	databaseURL := os.Getenv("DATABASE_URL")
+	if databaseURL == "" {
+		t.Skip("DATABASE_URL is required for postgres integration tests")
+	}
+
+	testDatabaseURL := createTestDatabase(t, databaseURL)
+	ctx := context.Background()
+	store, err := Open(ctx, testDatabaseURL)
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	defer store.Close()
+	if err := store.Migrate(ctx); err != nil {
+		t.Fatalf("migrate store: %v", err)
+	}
+
+	item := api.RecurringInvoice{
+		ID:            "rinv-1",
+		TenantID:      "tenant-a",
+		CustomerID:    "cust-1",
+		Currency:      "ILS",
+		Items:         []api.InvoiceLineItemRequest{{Description: "Hosting", Quantity: 1, UnitPrice: 1000}},
+		InvoiceStatus: invoice.StatusDraft,
+		Recurrence:    invoice.Recurrence{Frequency: invoice.FrequencyMonthly, Interval: 1, AnchorDay: 31, StartDate: "2026-01-31"},
+		Status:        api.RecurringActive,
+		NextRunAt:     "2026-01-31T00:00:00Z",
+		CreatedAt:     nowRFC3339(),
+		UpdatedAt:     nowRFC3339(),
+	}
+	if err := store.CreateRecurringInvoice(ctx, item); err != nil {
+		t.Fatalf("create recurring invoice: %v", err)
+	}
+	got, err := store.GetRecurringInvoice(ctx, item.TenantID, item.ID)
+	if err != nil {
+		t.Fatalf("get recurring invoice: %v", err)
+	}
+	if got.Recurrence != item.Recurrence || len(got.Items) != 1 || got.Items[0] != item.Items[0] || got.NextRunAt != item.NextRunAt {
+		t.Fatalf("unexpected recurring invoice %#v", got)
+	}
+
+	due, err := store.ListDueRecurringInvoices(ctx, "2026-02-01T00:00:00Z", 10)
+	if err != nil || len(due) != 1 {
+		t.Fatalf("expected one due recurring invoice, got %#v, %v", due, err)
+	}
+	runInvoice := func(id string) invoice.Invoice {
+		return invoice.Invoice{ID: id, TenantID: item.TenantID, Number: id, CustomerID: item.CustomerID, Currency: "ILS", Subtotal: 1000, Total: 1000, Status: invoice.StatusDraft, CreatedAt: nowRFC3339(), UpdatedAt: nowRFC3339()}
+	}
+	run := api.RecurringRun{
+		TenantID:           item.TenantID,
+		RecurringInvoiceID: item.ID,
+		Period:             "2026-01-31",
+		ScheduledAt:        "2026-01-31T00:00:00Z",
+		NextRunAt:          "2026-02-28T00:00:00Z",
+		Invoice:            runInvoice("inv-1"),
+	}
+	if _, created, err := store.RecordRecurringRun(ctx, run, invoice.DefaultNumberFormat); err != nil || !created {
+		t.Fatalf("expected the first run to create an invoice, got %v, %v", created, err)
+	}
+	if _, _, err := store.RecordRecurringRun(ctx, run, invoice.DefaultNumberFormat); !errors.Is(err, api.ErrConflict) {
+		t.Fatalf("expected a stale run to conflict, got %v", err)
+	}
+
+	// Rewind the schedule: the period is already billed, so the run only
+	// advances it.
+	got.NextRunAt = run.ScheduledAt
+	if err := store.UpdateRecurringInvoice(ctx, got); err != nil {
+		t.Fatalf("update recurring invoice: %v", err)
+	}
+	run.Invoice = runInvoice("inv-2")
+	if _, created, err := store.RecordRecurringRun(ctx, run, invoice.DefaultNumberFormat); err != nil || created {
+		t.Fatalf("expected a billed period not to create an invoice, got %v, %v", created, err)
+	}
+	if _, err := store.GetInvoice(ctx, item.TenantID, "inv-2"); !errors.Is(err, api.ErrNotFound) {
+		t.Fatalf("expected no second invoice, got %v", err)
+	}
+	got, err = store.GetRecurringInvoice(ctx, item.TenantID, item.ID)
+	if err != nil || got.NextRunAt != "2026-02-28T00:00:00Z" || got.LastInvoiceID != "inv-1" || got.LastRunAt != run.ScheduledAt {
+		t.Fatalf("unexpected recurring invoice after runs %#v, %v", got, err)
+	}
+
+	got.Status = api.RecurringPaused
+	if err := store.UpdateRecurringInvoice(ctx, got); err != nil {
+		t.Fatalf("pause recurring invoice: %v", err)
+	}
+	if due, err := store.ListDueRecurringInvoices(ctx, "2026-03-01T00:00:00Z", 10); err != nil || len(due) != 0 {
+		t.Fatalf("expected paused recurring invoices not to be due, got %#v, %v", due, err)
+	}
+}
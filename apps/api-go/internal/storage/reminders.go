@@ -0,0 +1,101 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/api"
+)
+
+const reminderColumns = `tenant_id, invoice_id, step, email, manual, sent_at`
+
+// ListDueInvoiceReminders counts each overdue invoice's reminders and keeps
+// those whose next step has come due, with the same timing as
+// invoice.NextReminderAt. Reminders are claimed by RecordInvoiceReminder, so
+// no row lock is taken.
+func (s *PostgresStore) ListDueInvoiceReminders(ctx context.Context, now string, schedule []int, limit int) ([]api.DueReminder, error) {
+	rows, err := s.pool.Query(ctx, `
+		with due as (
+			select i.tenant_id as due_tenant_id, i.id as due_id, c.email as due_email,
+				coalesce(r.sent, 0) as due_sent, r.last_sent_at as due_last_sent_at
+			from invoices i
+			join customers c on c.tenant_id = i.tenant_id and c.id = i.customer_id
+			left join lateral (
+				select count(*)::int as sent, max(sent_at) as last_sent_at
+				from invoice_reminders r
+				where r.tenant_id = i.tenant_id and r.invoice_id = i.id
+			) r on true
+			where i.status = 'overdue' and i.deleted_at is null and i.due_at is not null and c.email <> ''
+		)
+		select `+invoiceColumns+`, due_sent, due_email
+		from invoices
+		join due on tenant_id = due_tenant_id and id = due_id
+		where due_sent < cardinality($2::int[])
+			and due_at + make_interval(days => ($2::int[])[due_sent + 1]) <= $1
+			and (due_sent = 0 or due_last_sent_at + make_interval(days => ($2::int[])[due_sent + 1] - ($2::int[])[due_sent]) <= $1)
+		order by due_at, id
+		limit $3
+	`, parseTime(now), schedule, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []api.DueReminder{}
+	for rows.Next() {
+		var sent int
+		var email string
+		item, err := scanInvoice(extraScanner{row: rows, extra: []any{&sent, &email}})
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, api.DueReminder{Invoice: item, Step: sent + 1, Email: email})
+	}
+	return items, rows.Err()
+}
+
+func (s *PostgresStore) ListInvoiceReminders(ctx context.Context, tenantID, invoiceID string) ([]api.InvoiceReminder, error) {
+	rows, err := s.pool.Query(ctx, `
+		select `+reminderColumns+`
+		from invoice_reminders
+		where tenant_id = $1 and invoice_id = $2
+		order by step
+	`, tenantID, invoiceID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []api.InvoiceReminder{}
+	for rows.Next() {
+		var item api.InvoiceReminder
+		var sentAt time.Time
+		if err := rows.Scan(&item.TenantID, &item.InvoiceID, &item.Step, &item.Email, &item.Manual, &sentAt); err != nil {
+			return nil, err
+		}
+		item.SentAt = sentAt.UTC().Format(time.RFC3339)
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+func (s *PostgresStore) RecordInvoiceReminder(ctx context.Context, reminder api.InvoiceReminder) error {
+	_, err := s.pool.Exec(ctx, `
+		insert into invoice_reminders (`+reminderColumns+`)
+		values ($1, $2, $3, $4, $5, $6)
+	`, reminder.TenantID, reminder.InvoiceID, reminder.Step, reminder.Email, reminder.Manual, parseTime(reminder.SentAt))
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23503" {
+		return api.ErrNotFound
+	}
+	return mapWriteError(err)
+}
+
+func (s *PostgresStore) DeleteInvoiceReminder(ctx context.Context, tenantID, invoiceID string, step int) error {
+	_, err := s.pool.Exec(ctx, `
+		delete from invoice_reminders
+		where tenant_id = $1 and invoice_id = $2 and step = $3
+	`, tenantID, invoiceID, step)
+	return err
+}
@@ -0,0 +1,80 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/api"
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/invoice"
+)
+
+func TestPostgresStoreTracksInvoiceReminders(t *testing.T) {
+	databaseURL := os.Getenv("DATABASE_URL")
+	if databaseURL == "" {
+		t.Skip("DATABASE_URL is required for postgres integration tests")
+	}
+
+	testDatabaseURL := createTestDatabase(t, databaseURL)
+	ctx := context.Background()
+	store, err := Open(ctx, testDatabaseURL)
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	defer store.Close()
+	if err := store.Migrate(ctx); err != nil {
+		t.Fatalf("migrate store: %v", err)
+	}
+
+	now := time.Now().UTC().Truncate(time.Second)
+	if err := store.CreateCustomer(ctx, api.Customer{ID: "cust-1", TenantID: "tenant-a", Name: "Acme", Email: "ap@acme.test", CreatedAt: nowRFC3339(), UpdatedAt: nowRFC3339()}); err != nil {
+		t.Fatalf("create customer: %v", err)
+	}
+	item := invoice.Invoice{
+		ID:         "inv-1",
+		TenantID:   "tenant-a",
+		Number:     "INV-0001",
+		CustomerID: "cust-1",
+		Currency:   "ILS",
+		Status:     invoice.StatusOverdue,
+		DueAt:      now.AddDate(0, 0, -10).Format(time.RFC3339),
+		CreatedAt:  nowRFC3339(),
+		UpdatedAt:  nowRFC3339(),
+	}
+	if _, err := store.CreateInvoice(ctx, item, invoice.DefaultNumberFormat); err != nil {
+		t.Fatalf("create invoice: %v", err)
+	}
+	schedule := []int{1, 7, 14}
+
+	due, err := store.ListDueInvoiceReminders(ctx, now.Format(time.RFC3339), schedule, 10)
+	if err != nil || len(due) != 1 || due[0].Invoice.ID != item.ID || due[0].Step != 1 || due[0].Email != "ap@acme.test" {
+		t.Fatalf("expected the first reminder due, got %#v, %v", due, err)
+	}
+	first := api.InvoiceReminder{TenantID: "tenant-a", InvoiceID: item.ID, Step: 1, Email: "ap@acme.test", SentAt: now.Format(time.RFC3339)}
+	if err := store.RecordInvoiceReminder(ctx, first); err != nil {
+		t.Fatalf("record reminder: %v", err)
+	}
+	if err := store.RecordInvoiceReminder(ctx, first); !errors.Is(err, api.ErrConflict) {
+		t.Fatalf("expected a second claim of step 1 to conflict, got %v", err)
+	}
+	// Step 2 is past due + 7 days but must wait six days after step 1.
+	if due, err := store.ListDueInvoiceReminders(ctx, now.Format(time.RFC3339), schedule, 10); err != nil || len(due) != 0 {
+		t.Fatalf("expected step 2 to wait for its spacing, got %#v, %v", due, err)
+	}
+	later := now.AddDate(0, 0, 6).Format(time.RFC3339)
+	if due, err := store.ListDueInvoiceReminders(ctx, later, schedule, 10); err != nil || len(due) != 1 || due[0].Step != 2 {
+		t.Fatalf("expected step 2 due six days later, got %#v, %v", due, err)
+	}
+
+	if err := store.DeleteInvoiceReminder(ctx, "tenant-a", item.ID, 1); err != nil {
+		t.Fatalf("delete reminder: %v", err)
+	}
+	if reminders, err := store.ListInvoiceReminders(ctx, "tenant-a", item.ID); err != nil || len(reminders) != 0 {
+		t.Fatalf("expected the released reminder to be gone, got %#v, %v", reminders, err)
+	}
+	if err := store.RecordInvoiceReminder(ctx, api.InvoiceReminder{TenantID: "tenant-a", InvoiceID: "inv-missing", Step: 1, Email: "ap@acme.test", SentAt: nowRFC3339()}); !errors.Is(err, api.ErrNotFound) {
+		t.Fatalf("expected a reminder for a missing invoice to be not found, got %v", err)
+	}
+}
@@ -0,0 +1,127 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/api"
+)
+
+const (
+	maxTxAttempts     = 3
+	initialRetryDelay = 25 * time.Millisecond
+)
+
+// abortedSQLStates are errors after which Postgres has rolled the
+// transaction back, commit included, and the same transaction may succeed
+// when run again.
+var abortedSQLStates = map[string]bool{
+	"40001": true, // serialization_failure
+	"40P01": true, // deadlock_detected
+}
+
+// droppedSQLStates end the session: the server is shutting down or failing
+// over. Whatever the transaction had not committed is gone.
+var droppedSQLStates = map[string]bool{
+	"57P01": true, // admin_shutdown
+	"57P02": true, // crash_shutdown
+	"57P03": true, // cannot_connect_now
+}
+
+// commitError is a failed COMMIT. When the connection drops during one the
+// transaction may or may not have landed, so it is not run again.
+type commitError struct {
+	err error
+}
+
+func (e *commitError) Error() string { return e.err.Error() }
+func (e *commitError) Unwrap() error { return e.err }
+
+// inTx runs fn in a transaction and, when the transaction fails
+// transiently, runs it again up to maxTxAttempts times in all with
+// jittered, doubling waits: Postgres aborted it for a serialization failure
+// or deadlock, or the connection dropped before the commit. fn may run more
+// than once, so it must be a pure function of its inputs: it assigns what
+// it hands back to the caller rather than accumulating into it, and works
+// on copies of arguments it changes. A transient failure that outlasts the
+// retries is returned wrapped in api.ErrUnavailable.
+func (s *PostgresStore) inTx(ctx context.Context, fn func(pgx.Tx) error) error {
+	return retryTransient(ctx, maxTxAttempts, initialRetryDelay, func() error {
+		return runTx(ctx, s.pool, fn)
+	})
+}
+
+// runTx is pgx.BeginFunc, telling a failed commit apart.
+func runTx(ctx context.Context, pool *pgxpool.Pool, fn func(pgx.Tx) error) error {
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+	if err := fn(tx); err != nil {
+		return err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return &commitError{err: err}
+	}
+	return nil
+}
+
+// retryTransient calls attempt until it succeeds, fails for good, has been
+// called attempts times or ctx is done.
+func retryTransient(ctx context.Context, attempts int, delay time.Duration, attempt func() error) error {
+	for i := 1; ; i++ {
+		err := attempt()
+		if err == nil || !transient(err) || ctx.Err() != nil {
+			return err
+		}
+		if i == attempts {
+			return fmt.Errorf("%w: %w", api.ErrUnavailable, err)
+		}
+		// Half the delay plus up to as much again at random, so
+		// transactions that collided do not collide again in step.
+		timer := time.NewTimer(delay/2 + rand.N(delay/2+1))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+		delay *= 2
+	}
+}
+
+// transient reports whether the transaction that failed with err is known
+// to have been rolled back and may succeed when run again.
+func transient(err error) bool {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && abortedSQLStates[pgErr.Code] {
+		return true
+	}
+	var commitErr *commitError
+	if errors.As(err, &commitErr) {
+		return pgconn.SafeToRetry(err)
+	}
+	if pgErr != nil {
+		return droppedSQLStates[pgErr.Code] || strings.HasPrefix(pgErr.Code, "08")
+	}
+	return pgconn.SafeToRetry(err) || connectionLost(err)
+}
+
+// connectionLost reports whether err is the connection to the server
+// breaking, as it does when a primary fails over.
+func connectionLost(err error) bool {
+	var netErr *net.OpError
+	return errors.As(err, &netErr) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF) ||
+		errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.EPIPE)
+}
@@ -0,0 +1,136 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/api"
+)
+
+func TestRetryTransientRetriesSerializationFailures(t *testing.T) {
+	attempts := 0
+	err := retryTransient(context.Background(), maxTxAttempts, time.Millisecond, func() error {
+		attempts++
+		if attempts == 1 {
+			return fmt.Errorf("insert invoice: %w", &pgconn.PgError{Code: "40001", Message: "could not serialize access"})
+		}
+		return nil
+	})
+	if err != nil || attempts != 2 {
+		t.Fatalf("expected the second attempt to succeed, got %d attempts, %v", attempts, err)
+	}
+}
+
+func TestRetryTransientGivesUp(t *testing.T) {
+	attempts := 0
+	deadlock := &pgconn.PgError{Code: "40P01", Message: "deadlock detected"}
+	err := retryTransient(context.Background(), maxTxAttempts, time.Millisecond, func() error {
+		attempts++
+		return deadlock
+	})
+	if attempts != maxTxAttempts || !errors.Is(err, api.ErrUnavailable) || !errors.Is(err, deadlock) {
+		t.Fatalf("expected %d attempts and ErrUnavailable, got %d, %v", maxTxAttempts, attempts, err)
+	}
+
+	attempts = 0
+	ctx, cancel := context.WithCancel(context.Background())
+	err = retryTransient(ctx, maxTxAttempts, time.Hour, func() error {
+		attempts++
+		cancel()
+		return deadlock
+	})
+	if attempts != 1 || !errors.Is(err, deadlock) || errors.Is(err, api.ErrUnavailable) {
+		t.Fatalf("expected a done context to stop the retries, got %d attempts, %v", attempts, err)
+	}
+}
+
+func TestRetryTransientLeavesOtherErrorsAlone(t *testing.T) {
+	for _, cause := range []error{
+		api.ErrConflict,
+		api.ErrVersionMismatch,
+		&pgconn.PgError{Code: "23505", Message: "duplicate key value"},
+		&pgconn.PgError{Code: "57014", Message: "canceling statement due to user request"},
+		context.DeadlineExceeded,
+	} {
+		attempts := 0
+		err := retryTransient(context.Background(), maxTxAttempts, time.Millisecond, func() error {
+			attempts++
+			return cause
+		})
+		if attempts != 1 || err != cause {
+			t.Fatalf("%v: expected a single attempt returning the error as is, got %d attempts, %v", cause, attempts, err)
+		}
+	}
+}
+
+func TestTransient(t *testing.T) {
+	cases := []struct {
+		err  error
+		want bool
+	}{
+		{&pgconn.PgError{Code: "40001"}, true},
+		{&commitError{err: &pgconn.PgError{Code: "40001"}}, true},
+		{&pgconn.PgError{Code: "57P01"}, true},
+		{&pgconn.PgError{Code: "08006"}, true},
+		{io.ErrUnexpectedEOF, true},
+		{&pgconn.PgError{Code: "23505"}, false},
+		{errors.New("boom"), false},
+		// The commit may have landed before the connection dropped.
+		{&commitError{err: io.ErrUnexpectedEOF}, false},
+		{&commitError{err: &pgconn.PgError{Code: "57P01"}}, false},
+	}
+	for _, tc := range cases {
+		if got := transient(tc.err); got != tc.want {
+			t.Errorf("transient(%#v) = %v, want %v", tc.err, got, tc.want)
+		}
+	}
+}
+
+func TestPostgresStoreRetriesSerializationFailures(t *testing.T) {
+	databaseURL := os.Getenv("DATABASE_URL")
+	if databaseURL == "" {
+		t.Skip("DATABASE_URL is required for postgres integration tests")
+	}
+
+	testDatabaseURL := createTestDatabase(t, databaseURL)
+	ctx := context.Background()
+	store, err := Open(ctx, testDatabaseURL)
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	defer store.Close()
+	if _, err := store.pool.Exec(ctx, `create table retried (attempt int not null)`); err != nil {
+		t.Fatalf("create table: %v", err)
+	}
+
+	attempts := 0
+	err = store.inTx(ctx, func(tx pgx.Tx) error {
+		attempts++
+		if _, err := tx.Exec(ctx, `insert into retried (attempt) values ($1)`, attempts); err != nil {
+			return err
+		}
+		if attempts == 1 {
+			_, err := tx.Exec(ctx, `do $$ begin raise exception 'could not serialize access' using errcode = '40001'; end $$`)
+			return err
+		}
+		return nil
+	})
+	if err != nil || attempts != 2 {
+		t.Fatalf("expected the second attempt to commit, got %d attempts, %v", attempts, err)
+	}
+	var rows, attempt int
+	if err := store.pool.QueryRow(ctx, `select count(*), max(attempt) from retried`).Scan(&rows, &attempt); err != nil {
+		t.Fatalf("count rows: %v", err)
+	}
+	if rows != 1 || attempt != 2 {
+		t.Fatalf("expected only the second attempt's row, got %d rows from attempt %d", rows, attempt)
+	}
+}
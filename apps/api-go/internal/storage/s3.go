@@ -0,0 +1,250 @@
+package storage
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/api"
+)
+
+// s3UnsignedPayload is sent as the payload hash so uploads are streamed
+// instead of hashed up front; TLS protects the body in transit.
+const s3UnsignedPayload = "UNSIGNED-PAYLOAD"
+
+type S3Config struct {
+	// Endpoint is the base URL of an S3-compatible service, addressed with
+	// path-style URLs. Empty means AWS, addressed with virtual-hosted URLs.
+	Endpoint        string
+	Region          string
+	Bucket          string
+	AccessKeyID     string
+	SecretAccessKey string
+}
+
+// S3ObjectStore keeps objects in an S3 bucket, signing its requests with
+// AWS Signature Version 4.
+type S3ObjectStore struct {
+	config  S3Config
+	baseURL string
+	client  *http.Client
+	now     func() time.Time
+}
+
+func NewS3ObjectStore(config S3Config) (*S3ObjectStore, error) {
+	switch {
+	case config.Bucket == "":
+		return nil, errors.New("s3 bucket is required")
+	case config.Region == "":
+		return nil, errors.New("s3 region is required")
+	case config.AccessKeyID == "" || config.SecretAccessKey == "":
+		return nil, errors.New("s3 access key ID and secret access key are required")
+	}
+	baseURL := "https://" + config.Bucket + ".s3." + config.Region + ".amazonaws.com"
+	if config.Endpoint != "" {
+		endpoint, err := url.Parse(config.Endpoint)
+		if err != nil || (endpoint.Scheme != "http" && endpoint.Scheme != "https") || endpoint.Host == "" {
+			return nil, fmt.Errorf("s3 endpoint %q must be an http or https URL", config.Endpoint)
+		}
+		baseURL = strings.TrimSuffix(config.Endpoint, "/") + "/" + s3EscapePath(config.Bucket)
+	}
+	return &S3ObjectStore{config: config, baseURL: baseURL, client: &http.Client{}, now: time.Now}, nil
+}
+
+func (s *S3ObjectStore) Put(ctx context.Context, key string, body io.Reader, size int64, contentType string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, s.objectURL(key), body)
+	if err != nil {
+		return err
+	}
+	req.ContentLength = size
+	req.Header.Set("Content-Type", contentType)
+	resp, err := s.do(req)
+	if err != nil {
+		return fmt.Errorf("put object %s: %w", key, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("put object %s: %w", key, s3Error(resp))
+	}
+	return nil
+}
+
+func (s *S3ObjectStore) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.objectURL(key), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.do(req)
+	if err != nil {
+		return nil, fmt.Errorf("get object %s: %w", key, err)
+	}
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return resp.Body, nil
+	case http.StatusNotFound:
+		resp.Body.Close()
+		return nil, api.ErrNotFound
+	}
+	defer resp.Body.Close()
+	return nil, fmt.Errorf("get object %s: %w", key, s3Error(resp))
+}
+
+// Delete succeeds for a missing object too: S3 answers 204 either way, and
+// compatible services that answer 404 are treated alike.
+func (s *S3ObjectStore) Delete(ctx context.Context, key string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, s.objectURL(key), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := s.do(req)
+	if err != nil {
+		return fmt.Errorf("delete object %s: %w", key, err)
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusNoContent, http.StatusNotFound:
+		return nil
+	}
+	return fmt.Errorf("delete object %s: %w", key, s3Error(resp))
+}
+
+// Ping sends a HEAD request for the bucket, which answers 200 only when it
+// exists and the credentials may use it.
+func (s *S3ObjectStore) Ping(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, s.baseURL+"/", nil)
+	if err != nil {
+		return err
+	}
+	resp, err := s.do(req)
+	if err != nil {
+		return fmt.Errorf("head bucket %s: %w", s.config.Bucket, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("head bucket %s: %w", s.config.Bucket, s3Error(resp))
+	}
+	return nil
+}
+
+func (s *S3ObjectStore) objectURL(key string) string {
+	return s.baseURL + "/" + s3EscapePath(key)
+}
+
+func (s *S3ObjectStore) do(req *http.Request) (*http.Response, error) {
+	signS3Request(req, s3UnsignedPayload, s.config, s.now())
+	return s.client.Do(req)
+}
+
+// s3Error describes a failed response with the code from S3's XML error
+// body when it has one.
+func s3Error(resp *http.Response) error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 4<<10))
+	code := ""
+	if _, rest, ok := strings.Cut(string(body), "<Code>"); ok {
+		code, _, _ = strings.Cut(rest, "</Code>")
+	}
+	if code == "" {
+		return fmt.Errorf("s3 answered %s", resp.Status)
+	}
+	return fmt.Errorf("s3 answered %s: %s", resp.Status, code)
+}
+
+// signS3Request adds the x-amz-date, x-amz-content-sha256 and Authorization
+// headers of Signature Version 4. It signs the host, Content-Type, Range and
+// x-amz-* headers.
+func signS3Request(req *http.Request, payloadHash string, config S3Config, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	scope := amzDate[:8] + "/" + config.Region + "/s3/aws4_request"
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	headers := map[string]string{"host": host}
+	for name, values := range req.Header {
+		name = strings.ToLower(name)
+		if name == "content-type" || name == "range" || strings.HasPrefix(name, "x-amz-") {
+			headers[name] = strings.TrimSpace(strings.Join(values, ","))
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		s3CanonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+
+	key := hmacSHA256([]byte("AWS4"+config.SecretAccessKey), amzDate[:8])
+	for _, part := range []string{config.Region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+config.AccessKeyID+"/"+scope+", SignedHeaders="+signedHeaders+", Signature="+signature)
+}
+
+func s3CanonicalQuery(query url.Values) string {
+	pairs := make([]string, 0, len(query))
+	for name, values := range query {
+		for _, value := range values {
+			pairs = append(pairs, s3Escape(name, true)+"="+s3Escape(value, true))
+		}
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, "&")
+}
+
+// s3EscapePath encodes a key the way Signature Version 4 expects, leaving
+// its slashes as separators.
+func s3EscapePath(key string) string {
+	return s3Escape(key, false)
+}
+
+// s3Escape percent-encodes every byte except the unreserved characters of
+// RFC 3986, and slashes unless escapeSlash is set.
+func s3Escape(value string, escapeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9', c == '-', c == '.', c == '_', c == '~':
+			b.WriteByte(c)
+		case c == '/' && !escapeSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
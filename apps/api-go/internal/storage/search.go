@@ -0,0 +1,165 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/invoice"
+)
+
+// searchConfig is the text search configuration of the search_vector
+// columns. "simple" lowercases without stemming or stop words, which suits
+// names and numbers in any language.
+const searchConfig = `'simple'`
+
+// SearchInvoices finds candidates through one index per source, the
+// search_vector GIN indexes for full text and the trigram indexes for
+// substrings, then ranks just those rows.
+func (s *PostgresStore) SearchInvoices(ctx context.Context, tenantID string, query invoice.SearchQuery) ([]invoice.SearchResult, error) {
+	sql, args := buildInvoiceSearchQuery(tenantID, query)
+	rows, err := s.pool.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	results := []invoice.SearchResult{}
+	for rows.Next() {
+		var result invoice.SearchResult
+		var customerName string
+		var numberMatch, customerIDMatch, customerNameMatch bool
+		var items []string
+		item, err := scanInvoice(extraScanner{row: rows, extra: []any{&result.Rank, &customerName, &numberMatch, &customerIDMatch, &customerNameMatch, &items}})
+		if err != nil {
+			return nil, err
+		}
+		result.Invoice = item
+		result.Matches = []invoice.SearchMatch{}
+		if numberMatch {
+			result.Matches = append(result.Matches, invoice.SearchMatch{Field: invoice.SearchFieldNumber, Value: item.Number})
+		}
+		if customerIDMatch {
+			result.Matches = append(result.Matches, invoice.SearchMatch{Field: invoice.SearchFieldCustomerID, Value: item.CustomerID})
+		}
+		if customerNameMatch {
+			result.Matches = append(result.Matches, invoice.SearchMatch{Field: invoice.SearchFieldCustomerName, Value: customerName})
+		}
+		for _, description := range items {
+			result.Matches = append(result.Matches, invoice.SearchMatch{Field: invoice.SearchFieldItem, Value: description})
+		}
+		results = append(results, result)
+	}
+	return results, rows.Err()
+}
+
+// buildInvoiceSearchQuery writes the search for query's mode. Full text
+// matches the search_vector columns against websearch_to_tsquery and ranks
+// with ts_rank; substring search matches with ilike and ranks by trigram
+// similarity.
+func buildInvoiceSearchQuery(tenantID string, query invoice.SearchQuery) (string, []any) {
+	var source string
+	args := []any{tenantID}
+	if query.Mode == invoice.SearchSubstring {
+		args = append(args, "%"+escapeLike(query.Text)+"%", query.Text)
+		source = `select $2::text as pattern, $3::text as term`
+	} else {
+		args = append(args, query.Text)
+		source = `select websearch_to_tsquery(` + searchConfig + `, $2) as query`
+	}
+	// match and rank take a source's search vector and the text columns it
+	// covers; each mode uses one or the other.
+	match := func(vector string, texts ...string) string {
+		if query.Mode == invoice.SearchSubstring {
+			for i, text := range texts {
+				texts[i] = text + ` ilike q.pattern`
+			}
+			return `(` + strings.Join(texts, ` or `) + `)`
+		}
+		return vector + ` @@ q.query`
+	}
+	rank := func(vector string, texts ...string) string {
+		if query.Mode == invoice.SearchSubstring {
+			for i, text := range texts {
+				texts[i] = `similarity(` + text + `, q.term)`
+			}
+			return `greatest(` + strings.Join(texts, `, `) + `)`
+		}
+		return `ts_rank(` + vector + `, q.query)`
+	}
+	// field reports whether one column matched; the search vector of
+	// invoices covers two.
+	field := func(text string) string {
+		return match(`to_tsvector(`+searchConfig+`, `+text+`)`, text)
+	}
+	arg := func(value any) string {
+		args = append(args, value)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	sql := `with q as (` + source + `),
+		matched as (
+			select i.id
+			from invoices i, q
+			where i.tenant_id = $1 and ` + match("i.search_vector", "i.number", "i.customer_id") + `
+			union
+			select i.id
+			from customers c
+			join invoices i on i.tenant_id = c.tenant_id and i.customer_id = c.id, q
+			where c.tenant_id = $1 and c.deleted_at is null and ` + match("c.search_vector", "c.name") + `
+			union
+			select l.invoice_id
+			from invoice_line_items l, q
+			where l.tenant_id = $1 and ` + match("l.search_vector", "l.description") + `
+		)
+		select ` + prefixColumns("i", invoiceColumns) + `,
+			` + rank("i.search_vector", "i.number", "i.customer_id") + `
+				+ coalesce(` + rank("c.search_vector", "c.name") + `, 0)
+				+ coalesce(li.rank, 0) as rank,
+			coalesce(c.name, ''),
+			` + field("i.number") + `,
+			` + field("i.customer_id") + `,
+			coalesce(` + match("c.search_vector", "c.name") + `, false),
+			coalesce(li.descriptions, '{}')
+		from matched m
+		join invoices i on i.tenant_id = $1 and i.id = m.id and i.deleted_at is null
+		cross join q
+		left join customers c on c.tenant_id = i.tenant_id and c.id = i.customer_id and c.deleted_at is null
+		left join lateral (
+			select max(` + rank("l.search_vector", "l.description") + `) as rank,
+				array_agg(l.description order by l.position) as descriptions
+			from invoice_line_items l
+			where l.tenant_id = i.tenant_id and l.invoice_id = i.id and ` + match("l.search_vector", "l.description") + `
+		) li on true
+		order by rank desc, i.id collate "C"`
+	if query.Limit > 0 {
+		sql += ` limit ` + arg(query.Limit)
+	}
+	if query.Offset > 0 {
+		sql += ` offset ` + arg(query.Offset)
+	}
+	return sql, args
+}
+
+// escapeLike makes value match literally inside an ilike pattern.
+func escapeLike(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(value)
+}
+
+func prefixColumns(alias, columns string) string {
+	names := strings.Split(columns, ", ")
+	for i, name := range names {
+		names[i] = alias + "." + name
+	}
+	return strings.Join(names, ", ")
+}
+
+// extraScanner scans the columns that follow the invoice columns of a row
+// into extra.
+type extraScanner struct {
+	row   invoiceScanner
+	extra []any
+}
+
+func (s extraScanner) Scan(dest ...any) error {
+	return s.row.Scan(append(dest, s.extra...)...)
+}
@@ -0,0 +1,122 @@
+package storage
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/api"
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/invoice"
+)
+
+func TestBuildInvoiceSearchQueryFullText(t *testing.T) {
+	query, args := buildInvoiceSearchQuery("tenant-a", invoice.SearchQuery{Text: "acme widgets", Mode: invoice.SearchFullText, Limit: 51, Offset: 50})
+
+	for _, fragment := range []string{
+		"websearch_to_tsquery('simple', $2)",
+		"i.search_vector @@ q.query",
+		"c.search_vector @@ q.query",
+		"l.search_vector @@ q.query",
+		"c.deleted_at is null",
+		`order by rank desc, i.id collate "C"`,
+		"limit $3",
+		"offset $4",
+	} {
+		if !strings.Contains(query, fragment) {
+			t.Fatalf("expected query to contain %q:\n%s", fragment, query)
+		}
+	}
+	if strings.Contains(query, "ilike") {
+		t.Fatalf("expected no substring matching in full-text mode:\n%s", query)
+	}
+	if len(args) != 4 || args[1] != "acme widgets" || args[2] != 51 || args[3] != 50 {
+		t.Fatalf("unexpected args: %#v", args)
+	}
+}
+
+func TestBuildInvoiceSearchQuerySubstringEscapesPattern(t *testing.T) {
+	query, args := buildInvoiceSearchQuery("tenant-a", invoice.SearchQuery{Text: `5%_\`, Mode: invoice.SearchSubstring})
+
+	for _, fragment := range []string{
+		"(i.number ilike q.pattern or i.customer_id ilike q.pattern)",
+		"similarity(c.name, q.term)",
+		"l.description ilike q.pattern",
+	} {
+		if !strings.Contains(query, fragment) {
+			t.Fatalf("expected query to contain %q:\n%s", fragment, query)
+		}
+	}
+	if strings.Contains(query, "@@") || strings.Contains(query, "limit") {
+		t.Fatalf("unexpected query:\n%s", query)
+	}
+	if len(args) != 3 || args[1] != `%5\%\_\\%` || args[2] != `5%_\` {
+		t.Fatalf("unexpected args: %#v", args)
+	}
+}
+
+func TestPostgresStoreSearchInvoices(t *testing.T) {
+	databaseURL := os.Getenv("DATABASE_URL")
+	if databaseURL == "" {
+		t.Skip("DATABASE_URL is required for postgres integration tests")
+	}
+
+	testDatabaseURL := createTestDatabase(t, databaseURL)
+	ctx := context.Background()
+	store, err := Open(ctx, testDatabaseURL)
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	defer store.Close()
+	if err := store.Migrate(ctx); err != nil {
+		t.Fatalf("migrate store: %v", err)
+	}
+
+	if err := store.CreateCustomer(ctx, api.Customer{ID: "cust-1", TenantID: "tenant-a", Name: "Acme Widgets Ltd", CreatedAt: nowRFC3339(), UpdatedAt: nowRFC3339()}); err != nil {
+		t.Fatalf("create customer: %v", err)
+	}
+	create := func(tenantID, id, number, customerID string, descriptions ...string) {
+		item := invoice.Invoice{ID: id, TenantID: tenantID, Number: number, CustomerID: customerID, Currency: "ILS", Status: invoice.StatusDraft, CreatedAt: nowRFC3339(), UpdatedAt: nowRFC3339()}
+		var lines []invoice.LineItem
+		for i, description := range descriptions {
+			line := invoice.LineItem{ID: id + "-item", InvoiceID: id, Position: i + 1, Description: description, Quantity: 1, UnitPrice: 100, CreatedAt: nowRFC3339()}
+			line.Normalize()
+			lines = append(lines, line)
+		}
+		item.SetLineItems(lines)
+		if _, err := store.CreateInvoice(ctx, item, invoice.DefaultNumberFormat); err != nil {
+			t.Fatalf("create invoice %s: %v", id, err)
+		}
+	}
+	create("tenant-a", "inv-1", "WIDGETS-1", "cust-x")
+	create("tenant-a", "inv-2", "INV-0002", "cust-1")
+	create("tenant-a", "inv-3", "INV-0003", "cust-x", "Blue widgets")
+	create("tenant-a", "inv-4", "INV-0004", "cust-x", "Hosting")
+	create("tenant-b", "inv-5", "WIDGETS-5", "cust-x")
+
+	results, err := store.SearchInvoices(ctx, "tenant-a", invoice.SearchQuery{Text: "widgets", Mode: invoice.SearchFullText})
+	if err != nil {
+		t.Fatalf("search: %v", err)
+	}
+	want := []invoice.SearchMatch{
+		{Field: invoice.SearchFieldNumber, Value: "WIDGETS-1"},
+		{Field: invoice.SearchFieldCustomerName, Value: "Acme Widgets Ltd"},
+		{Field: invoice.SearchFieldItem, Value: "Blue widgets"},
+	}
+	if len(results) != len(want) {
+		t.Fatalf("expected %d results, got %#v", len(want), results)
+	}
+	for i, result := range results {
+		if len(result.Matches) != 1 || result.Matches[0] != want[i] || result.Rank <= 0 {
+			t.Fatalf("result %d: expected %#v, got %#v", i, want[i], result)
+		}
+	}
+
+	results, err = store.SearchInvoices(ctx, "tenant-a", invoice.SearchQuery{Text: "v-00", Mode: invoice.SearchSubstring, Limit: 2, Offset: 1})
+	if err != nil {
+		t.Fatalf("substring search: %v", err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("expected the second page of three substring matches, got %#v", results)
+	}
+}
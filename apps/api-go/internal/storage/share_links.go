@@ -0,0 +1,52 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/api"
+)
+
+const shareLinkColumns = `id, tenant_id, invoice_id, expires_at, created_by, created_at`
+
+func (s *PostgresStore) CreateInvoiceShareLink(ctx context.Context, link api.InvoiceShareLink) error {
+	_, err := s.pool.Exec(ctx, `
+		insert into invoice_share_links (`+shareLinkColumns+`)
+		values ($1, $2, $3, $4, $5, $6)
+	`, link.ID, link.TenantID, link.InvoiceID, parseTime(link.ExpiresAt), link.CreatedBy, parseTime(link.CreatedAt))
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23503" {
+		return api.ErrNotFound
+	}
+	return mapWriteError(err)
+}
+
+func (s *PostgresStore) GetInvoiceShareLink(ctx context.Context, id string) (api.InvoiceShareLink, error) {
+	var link api.InvoiceShareLink
+	var expiresAt, createdAt time.Time
+	err := s.pool.QueryRow(ctx, `
+		select `+shareLinkColumns+`
+		from invoice_share_links
+		where id = $1
+	`, id).Scan(&link.ID, &link.TenantID, &link.InvoiceID, &expiresAt, &link.CreatedBy, &createdAt)
+	if err != nil {
+		return api.InvoiceShareLink{}, mapScanError(err)
+	}
+	link.ExpiresAt = expiresAt.UTC().Format(time.RFC3339)
+	link.CreatedAt = createdAt.UTC().Format(time.RFC3339)
+	return link, nil
+}
+
+func (s *PostgresStore) RevokeInvoiceShareLinks(ctx context.Context, tenantID, invoiceID string) (int, error) {
+	tag, err := s.pool.Exec(ctx, `
+		delete from invoice_share_links
+		where tenant_id = $1 and invoice_id = $2
+	`, tenantID, invoiceID)
+	if err != nil {
+		return 0, err
+	}
+	return int(tag.RowsAffected()), nil
+}
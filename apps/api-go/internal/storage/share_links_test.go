@@ -0,0 +1,61 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/api"
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/invoice"
+)
+
+func TestPostgresStoreRevokesInvoiceShareLinks(t *testing.T) {
+	databaseURL := os.Getenv("DATABASE_URL")
+	if databaseURL == "" {
+		t.Skip("DATABASE_URL is required for postgres integration tests")
+	}
+
+	testDatabaseURL := createTestDatabase(t, databaseURL)
+	ctx := context.Background()
+	store, err := Open(ctx, testDatabaseURL)
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	defer store.Close()
+	if err := store.Migrate(ctx); err != nil {
+		t.Fatalf("migrate store: %v", err)
+	}
+
+	if _, err := store.CreateInvoice(ctx, invoice.Invoice{ID: "inv-1", TenantID: "tenant-a", Number: "INV-1", Currency: "ILS", Status: invoice.StatusOpen, CreatedAt: nowRFC3339(), UpdatedAt: nowRFC3339()}, invoice.DefaultNumberFormat); err != nil {
+		t.Fatalf("create invoice: %v", err)
+	}
+	link := api.InvoiceShareLink{ID: "share-1", TenantID: "tenant-a", InvoiceID: "inv-1", ExpiresAt: "2030-01-01T00:00:00Z", CreatedBy: "user-1", CreatedAt: nowRFC3339()}
+	if err := store.CreateInvoiceShareLink(ctx, link); err != nil {
+		t.Fatalf("create share link: %v", err)
+	}
+	second := link
+	second.ID = "share-2"
+	if err := store.CreateInvoiceShareLink(ctx, second); err != nil {
+		t.Fatalf("create second share link: %v", err)
+	}
+	other := link
+	other.ID, other.TenantID = "share-3", "tenant-b"
+	if err := store.CreateInvoiceShareLink(ctx, other); !errors.Is(err, api.ErrNotFound) {
+		t.Fatalf("expected a link to another tenant's invoice to be refused, got %v", err)
+	}
+
+	got, err := store.GetInvoiceShareLink(ctx, "share-1")
+	if err != nil || got != link {
+		t.Fatalf("expected the stored link %#v, got %#v, %v", link, got, err)
+	}
+	if revoked, err := store.RevokeInvoiceShareLinks(ctx, "tenant-b", "inv-1"); err != nil || revoked != 0 {
+		t.Fatalf("expected another tenant to revoke nothing, got %d, %v", revoked, err)
+	}
+	if revoked, err := store.RevokeInvoiceShareLinks(ctx, "tenant-a", "inv-1"); err != nil || revoked != 2 {
+		t.Fatalf("expected both links revoked, got %d, %v", revoked, err)
+	}
+	if _, err := store.GetInvoiceShareLink(ctx, "share-2"); !errors.Is(err, api.ErrNotFound) {
+		t.Fatalf("expected a revoked link to be gone, got %v", err)
+	}
+}
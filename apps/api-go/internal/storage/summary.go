@@ -0,0 +1,77 @@
+package storage
+
+import (
+	"context"
+
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/api"
+)
+
+// SummarizeInvoices aggregates in one statement: the invoices issued in the
+// range are counted and summed per status with FILTER clauses, and the
+// payments received and credit notes issued in the range are joined on by
+// currency.
+func (s *PostgresStore) SummarizeInvoices(ctx context.Context, tenantID, from, to string) ([]api.CurrencySummary, error) {
+	rows, err := s.pool.Query(ctx, `
+		with issued as (
+			select currency,
+				count(*) filter (where status = 'draft') as draft_count,
+				coalesce(sum(total) filter (where status = 'draft'), 0)::bigint as draft_total,
+				count(*) filter (where status = 'open') as open_count,
+				coalesce(sum(total) filter (where status = 'open'), 0)::bigint as open_total,
+				count(*) filter (where status = 'paid') as paid_count,
+				coalesce(sum(total) filter (where status = 'paid'), 0)::bigint as paid_total,
+				count(*) filter (where status = 'overdue') as overdue_count,
+				coalesce(sum(total) filter (where status = 'overdue'), 0)::bigint as overdue_total,
+				count(*) filter (where status = 'void') as void_count,
+				coalesce(sum(total) filter (where status = 'void'), 0)::bigint as void_total,
+				coalesce(sum(total - amount_paid) filter (where status in ('open', 'overdue')), 0)::bigint as outstanding
+			from invoices
+			where tenant_id = $1 and deleted_at is null and issued_at >= $2 and issued_at <= $3
+			group by currency
+		), collected as (
+			select i.currency, sum(p.amount)::bigint as collected
+			from invoice_payments p
+			join invoices i on i.tenant_id = p.tenant_id and i.id = p.invoice_id
+			where p.tenant_id = $1 and i.deleted_at is null and p.received_at >= $2 and p.received_at <= $3
+			group by i.currency
+		), credited as (
+			select n.currency, -sum(n.total)::bigint as credited
+			from credit_notes n
+			join invoices i on i.tenant_id = n.tenant_id and i.id = n.invoice_id
+			where n.tenant_id = $1 and i.deleted_at is null and n.issued_at >= $2 and n.issued_at <= $3
+			group by n.currency
+		)
+		select coalesce(issued.currency, collected.currency, credited.currency),
+			coalesce(draft_count, 0), coalesce(draft_total, 0),
+			coalesce(open_count, 0), coalesce(open_total, 0),
+			coalesce(paid_count, 0), coalesce(paid_total, 0),
+			coalesce(overdue_count, 0), coalesce(overdue_total, 0),
+			coalesce(void_count, 0), coalesce(void_total, 0),
+			coalesce(outstanding, 0),
+			coalesce(collected.collected, 0) - coalesce(credited.credited, 0), coalesce(credited.credited, 0)
+		from issued
+		full join collected on collected.currency = issued.currency
+		full join credited on credited.currency = coalesce(issued.currency, collected.currency)
+		order by 1
+	`, tenantID, parseTime(from), parseTime(to))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []api.CurrencySummary{}
+	for rows.Next() {
+		var item api.CurrencySummary
+		status := &item.ByStatus
+		if err := rows.Scan(&item.Currency,
+			&status.Draft.Count, &status.Draft.Total,
+			&status.Open.Count, &status.Open.Total,
+			&status.Paid.Count, &status.Paid.Total,
+			&status.Overdue.Count, &status.Overdue.Total,
+			&status.Void.Count, &status.Void.Total,
+			&item.Outstanding, &item.Collected, &item.Credited); err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
@@ -0,0 +1,77 @@
+package storage
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/api"
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/invoice"
+)
+
+func TestPostgresStoreSummarizesInvoices(t *testing.T) {
+	databaseURL := os.Getenv("DATABASE_URL")
+	if databaseURL == "" {
+		t.Skip("DATABASE_URL is required for postgres integration tests")
+	}
+
+	testDatabaseURL := createTestDatabase(t, databaseURL)
+	ctx := context.Background()
+	store, err := Open(ctx, testDatabaseURL)
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	defer store.Close()
+	if err := store.Migrate(ctx); err != nil {
+		t.Fatalf("migrate store: %v", err)
+	}
+
+	inRange := "2026-03-10T09:00:00Z"
+	items := []invoice.Invoice{
+		{ID: "inv-1", Number: "INV-0001", Currency: "ILS", Subtotal: 1000, Total: 1000, Status: invoice.StatusDraft, IssuedAt: inRange},
+		{ID: "inv-2", Number: "INV-0002", Currency: "ILS", Subtotal: 5000, Total: 5000, Status: invoice.StatusOpen, IssuedAt: inRange},
+		{ID: "inv-3", Number: "INV-0003", Currency: "USD", Subtotal: 700, Total: 700, Status: invoice.StatusOverdue, IssuedAt: inRange},
+		{ID: "inv-4", Number: "INV-0004", Currency: "EUR", Subtotal: 900, Total: 900, Status: invoice.StatusOpen, IssuedAt: "2026-01-15T09:00:00Z"},
+		{ID: "inv-5", Number: "INV-0005", Currency: "ILS", Subtotal: 800, Total: 800, Status: invoice.StatusOpen, IssuedAt: inRange},
+	}
+	for _, item := range items {
+		item.TenantID = "tenant-a"
+		item.CreatedAt, item.UpdatedAt = nowRFC3339(), nowRFC3339()
+		if _, err := store.CreateInvoice(ctx, item, invoice.DefaultNumberFormat); err != nil {
+			t.Fatalf("create invoice %s: %v", item.ID, err)
+		}
+	}
+	payments := map[string]invoice.Payment{
+		"inv-2": {ID: "pay-1", InvoiceID: "inv-2", Amount: 1500, Method: invoice.PaymentBankTransfer, ReceivedAt: "2026-03-20T12:00:00Z", CreatedAt: nowRFC3339()},
+		"inv-4": {ID: "pay-2", InvoiceID: "inv-4", Amount: 400, Method: invoice.PaymentCard, ReceivedAt: "2026-03-02T12:00:00Z", CreatedAt: nowRFC3339()},
+	}
+	for _, payment := range payments {
+		if _, err := store.RecordInvoicePayment(ctx, "tenant-a", payment, false, nowRFC3339()); err != nil {
+			t.Fatalf("record payment: %v", err)
+		}
+	}
+	if err := store.SoftDeleteInvoice(ctx, "tenant-a", "inv-5", nowRFC3339()); err != nil {
+		t.Fatalf("delete invoice: %v", err)
+	}
+
+	got, err := store.SummarizeInvoices(ctx, "tenant-a", "2026-03-01T00:00:00Z", "2026-03-31T23:59:59Z")
+	if err != nil {
+		t.Fatalf("summarize invoices: %v", err)
+	}
+	want := []api.CurrencySummary{
+		{Currency: "EUR", Collected: 400},
+		{Currency: "ILS", ByStatus: api.StatusBreakdown{Draft: api.StatusTotals{Count: 1, Total: 1000}, Open: api.StatusTotals{Count: 1, Total: 5000}}, Outstanding: 3500, Collected: 1500},
+		{Currency: "USD", ByStatus: api.StatusBreakdown{Overdue: api.StatusTotals{Count: 1, Total: 700}}, Outstanding: 700},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("expected %d currencies, got %#v", len(want), got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("currency %d: expected %#v, got %#v", i, want[i], got[i])
+		}
+	}
+	if other, err := store.SummarizeInvoices(ctx, "tenant-b", "2026-03-01T00:00:00Z", "2026-03-31T23:59:59Z"); err != nil || len(other) != 0 {
+		t.Fatalf("expected nothing for another tenant, got %#v, %v", other, err)
+	}
+}
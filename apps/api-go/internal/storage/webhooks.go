@@ -0,0 +1,245 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/api"
+)
+
+const webhookDeliveryColumns = `id, tenant_id, subscription_id, event_id, event, payload, status, attempts, next_attempt_at, last_status_code, last_error, replay_of, created_at, updated_at`
+
+func (s *PostgresStore) ListWebhookSubscriptions(ctx context.Context, tenantID string) ([]api.WebhookSubscription, error) {
+	rows, err := s.pool.Query(ctx, `
+		select id, tenant_id, url, events, secret, created_at, updated_at
+		from webhook_subscriptions
+		where tenant_id = $1
+		order by created_at desc
+	`, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []api.WebhookSubscription{}
+	for rows.Next() {
+		item, err := scanWebhookSubscription(rows)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+func (s *PostgresStore) GetWebhookSubscription(ctx context.Context, tenantID, id string) (api.WebhookSubscription, error) {
+	return scanWebhookSubscription(s.pool.QueryRow(ctx, `
+		select id, tenant_id, url, events, secret, created_at, updated_at
+		from webhook_subscriptions
+		where tenant_id = $1 and id = $2
+	`, tenantID, id))
+}
+
+func (s *PostgresStore) CreateWebhookSubscription(ctx context.Context, item api.WebhookSubscription) error {
+	_, err := s.pool.Exec(ctx, `
+		insert into webhook_subscriptions (id, tenant_id, url, events, secret, created_at, updated_at)
+		values ($1, $2, $3, $4, $5, $6, $7)
+	`, item.ID, item.TenantID, item.URL, item.Events, item.Secret, parseTime(item.CreatedAt), parseTime(item.UpdatedAt))
+	return mapWriteError(err)
+}
+
+func (s *PostgresStore) CreateWebhookDeliveries(ctx context.Context, items []api.WebhookDelivery) error {
+	return s.inTx(ctx, func(tx pgx.Tx) error {
+		for _, item := range items {
+			_, err := tx.Exec(ctx, `
+				insert into webhook_deliveries (`+webhookDeliveryColumns+`)
+				values ($1, $2, $3, $4, $5, $6::jsonb, $7, $8, $9, $10, $11, $12, $13, $14)
+			`, item.ID, item.TenantID, item.SubscriptionID, item.EventID, item.Event, []byte(item.Payload), item.Status, item.Attempts,
+				nullTime(item.NextAttemptAt), nullInt(item.LastStatusCode), nullString(item.LastError), nullString(item.ReplayOf), parseTime(item.CreatedAt), parseTime(item.UpdatedAt))
+			if err != nil {
+				return mapWriteError(err)
+			}
+		}
+		return nil
+	})
+}
+
+// ClaimWebhookDeliveries leases due rows with for update skip locked, so
+// several API replicas can run the worker without sending a delivery twice.
+func (s *PostgresStore) ClaimWebhookDeliveries(ctx context.Context, now, leaseUntil string, limit int) ([]api.WebhookDelivery, error) {
+	rows, err := s.pool.Query(ctx, `
+		with due as (
+			select id as due_id
+			from webhook_deliveries
+			where status = 'pending' and next_attempt_at <= $1
+			order by next_attempt_at
+			limit $3
+			for update skip locked
+		)
+		update webhook_deliveries d
+		set next_attempt_at = $2
+		from due
+		where d.id = due.due_id
+		returning `+webhookDeliveryColumns+`
+	`, parseTime(now), parseTime(leaseUntil), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return collectWebhookDeliveries(rows)
+}
+
+func (s *PostgresStore) RecordWebhookAttempt(ctx context.Context, delivery api.WebhookDelivery, attempt api.WebhookDeliveryAttempt) error {
+	return s.inTx(ctx, func(tx pgx.Tx) error {
+		tag, err := tx.Exec(ctx, `
+			update webhook_deliveries
+			set status = $3,
+				attempts = $4,
+				next_attempt_at = $5,
+				last_status_code = $6,
+				last_error = $7,
+				updated_at = $8
+			where id = $1 and tenant_id = $2
+		`, delivery.ID, delivery.TenantID, delivery.Status, delivery.Attempts, nullTime(delivery.NextAttemptAt),
+			nullInt(delivery.LastStatusCode), nullString(delivery.LastError), parseTime(delivery.UpdatedAt))
+		if err := rowsAffectedOrNotFound(tag, err); err != nil {
+			return err
+		}
+		_, err = tx.Exec(ctx, `
+			insert into webhook_delivery_attempts (id, delivery_id, attempt, status_code, error_text, response_snippet, duration_ms, created_at)
+			values ($1, $2, $3, $4, $5, $6, $7, $8)
+		`, attempt.ID, attempt.DeliveryID, attempt.Attempt, nullInt(attempt.StatusCode), nullString(attempt.Error), nullString(attempt.ResponseSnippet), attempt.DurationMs, parseTime(attempt.CreatedAt))
+		return mapWriteError(err)
+	})
+}
+
+func (s *PostgresStore) ListWebhookDeliveries(ctx context.Context, tenantID, subscriptionID string, filter api.WebhookDeliveryFilter) ([]api.WebhookDelivery, error) {
+	var afterCreated pgtype.Timestamptz
+	var afterID string
+	if filter.After != nil {
+		afterCreated = pgtype.Timestamptz{Time: parseTime(filter.After.CreatedAt), Valid: true}
+		afterID = filter.After.ID
+	}
+	var limit pgtype.Int8
+	if filter.Limit > 0 {
+		limit = pgtype.Int8{Int64: int64(filter.Limit), Valid: true}
+	}
+	rows, err := s.pool.Query(ctx, `
+		select `+webhookDeliveryColumns+`
+		from webhook_deliveries
+		where tenant_id = $1 and subscription_id = $2 and ($3 = '' or status = $3)
+			and ($4::timestamptz is null or (created_at, id collate "C") < ($4, $5::text collate "C"))
+		order by created_at desc, id collate "C" desc
+		limit $6
+	`, tenantID, subscriptionID, filter.Status, afterCreated, afterID, limit)
+	if err != nil {
+		return nil, err
+	}
+	items, err := collectWebhookDeliveries(rows)
+	rows.Close()
+	if err != nil || len(items) == 0 {
+		return items, err
+	}
+
+	ids := make([]string, len(items))
+	index := make(map[string]int, len(items))
+	for i, item := range items {
+		ids[i] = item.ID
+		index[item.ID] = i
+	}
+	attempts, err := s.pool.Query(ctx, `
+		select id, delivery_id, attempt, status_code, error_text, response_snippet, duration_ms, created_at
+		from webhook_delivery_attempts
+		where delivery_id = any($1)
+		order by delivery_id, attempt
+	`, ids)
+	if err != nil {
+		return nil, err
+	}
+	defer attempts.Close()
+	for attempts.Next() {
+		var attempt api.WebhookDeliveryAttempt
+		var statusCode sql.NullInt32
+		var errorText, responseSnippet sql.NullString
+		var createdAt time.Time
+		if err := attempts.Scan(&attempt.ID, &attempt.DeliveryID, &attempt.Attempt, &statusCode, &errorText, &responseSnippet, &attempt.DurationMs, &createdAt); err != nil {
+			return nil, err
+		}
+		attempt.StatusCode = int(statusCode.Int32)
+		attempt.Error = errorText.String
+		attempt.ResponseSnippet = responseSnippet.String
+		attempt.CreatedAt = createdAt.UTC().Format(time.RFC3339)
+		i := index[attempt.DeliveryID]
+		items[i].AttemptLog = append(items[i].AttemptLog, attempt)
+	}
+	return items, attempts.Err()
+}
+
+func (s *PostgresStore) GetWebhookDelivery(ctx context.Context, tenantID, id string) (api.WebhookDelivery, error) {
+	rows, err := s.pool.Query(ctx, `
+		select `+webhookDeliveryColumns+`
+		from webhook_deliveries
+		where tenant_id = $1 and id = $2
+	`, tenantID, id)
+	if err != nil {
+		return api.WebhookDelivery{}, err
+	}
+	defer rows.Close()
+	items, err := collectWebhookDeliveries(rows)
+	if err != nil {
+		return api.WebhookDelivery{}, err
+	}
+	if len(items) == 0 {
+		return api.WebhookDelivery{}, api.ErrNotFound
+	}
+	return items[0], nil
+}
+
+type webhookScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanWebhookSubscription(row webhookScanner) (api.WebhookSubscription, error) {
+	var item api.WebhookSubscription
+	var createdAt, updatedAt time.Time
+	if err := row.Scan(&item.ID, &item.TenantID, &item.URL, &item.Events, &item.Secret, &createdAt, &updatedAt); err != nil {
+		return api.WebhookSubscription{}, mapScanError(err)
+	}
+	item.CreatedAt = createdAt.UTC().Format(time.RFC3339)
+	item.UpdatedAt = updatedAt.UTC().Format(time.RFC3339)
+	return item, nil
+}
+
+func collectWebhookDeliveries(rows pgx.Rows) ([]api.WebhookDelivery, error) {
+	items := []api.WebhookDelivery{}
+	for rows.Next() {
+		var item api.WebhookDelivery
+		var payload []byte
+		var nextAttemptAt sql.NullTime
+		var lastStatusCode sql.NullInt32
+		var lastError, replayOf sql.NullString
+		var createdAt, updatedAt time.Time
+		if err := rows.Scan(&item.ID, &item.TenantID, &item.SubscriptionID, &item.EventID, &item.Event, &payload, &item.Status, &item.Attempts,
+			&nextAttemptAt, &lastStatusCode, &lastError, &replayOf, &createdAt, &updatedAt); err != nil {
+			return nil, err
+		}
+		item.Payload = payload
+		item.NextAttemptAt = nullableTimeString(nextAttemptAt)
+		item.LastStatusCode = int(lastStatusCode.Int32)
+		item.LastError = lastError.String
+		item.ReplayOf = replayOf.String
+		item.CreatedAt = createdAt.UTC().Format(time.RFC3339)
+		item.UpdatedAt = updatedAt.UTC().Format(time.RFC3339)
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+func nullInt(value int) any {
+	if value == 0 {
+		return nil
+	}
+	return value
+}
@@ -0,0 +1,120 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/vgeshiktor/invoices-platform/apps/api-go/internal/api"
+)
+
+func TestPostgresStoreWebhookDeliveries(t *testing.T) {
+	databaseURL := os.Getenv("DATABASE_URL")
+	if databaseURL == "" {
+		t.Skip("DATABASE_URL is required for postgres integration tests")
+	}
+
+	testDatabaseURL := createTestDatabase(t, databaseURL)
+	ctx := context.Background()
+	store, err := Open(ctx, testDatabaseURL)
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	defer store.Close()
+	if err := store.Migrate(ctx); err != nil {
+		t.Fatalf("migrate store: %v", err)
+	}
+
+	now := time.Now().UTC()
+	stamp := now.Format(time.RFC3339)
+	subscription := api.WebhookSubscription{
+		ID:        "whk-1",
+		TenantID:  "tenant-a",
+		URL:       "https://hooks.example.com",
+		Events:    []string{api.EventInvoiceCreated, api.EventInvoicePaid},
+		Secret:    "whsec_0123456789abcdef",
+		CreatedAt: stamp,
+		UpdatedAt: stamp,
+	}
+	if err := store.CreateWebhookSubscription(ctx, subscription); err != nil {
+		t.Fatalf("create subscription: %v", err)
+	}
+	got, err := store.GetWebhookSubscription(ctx, "tenant-a", "whk-1")
+	if err != nil || got.Secret != subscription.Secret || len(got.Events) != 2 {
+		t.Fatalf("unexpected subscription %#v, %v", got, err)
+	}
+	if _, err := store.GetWebhookSubscription(ctx, "tenant-b", "whk-1"); !errors.Is(err, api.ErrNotFound) {
+		t.Fatalf("expected other tenant lookup to miss, got %v", err)
+	}
+
+	delivery := api.WebhookDelivery{
+		ID:             "whd-1",
+		TenantID:       "tenant-a",
+		SubscriptionID: "whk-1",
+		EventID:        "evt-1",
+		Event:          api.EventInvoiceCreated,
+		Payload:        []byte(`{"id":"evt-1","type":"invoice.created"}`),
+		Status:         api.WebhookDeliveryPending,
+		NextAttemptAt:  stamp,
+		CreatedAt:      stamp,
+		UpdatedAt:      stamp,
+	}
+	if err := store.CreateWebhookDeliveries(ctx, []api.WebhookDelivery{delivery}); err != nil {
+		t.Fatalf("create delivery: %v", err)
+	}
+
+	lease := now.Add(time.Minute).Format(time.RFC3339)
+	claimed, err := store.ClaimWebhookDeliveries(ctx, stamp, lease, 10)
+	if err != nil || len(claimed) != 1 || claimed[0].NextAttemptAt != lease {
+		t.Fatalf("expected to claim the delivery, got %#v, %v", claimed, err)
+	}
+	if again, err := store.ClaimWebhookDeliveries(ctx, stamp, lease, 10); err != nil || len(again) != 0 {
+		t.Fatalf("expected leased delivery to be skipped, got %#v, %v", again, err)
+	}
+
+	done := claimed[0]
+	done.Status = api.WebhookDeliverySucceeded
+	done.Attempts = 1
+	done.NextAttemptAt = ""
+	done.LastStatusCode = 204
+	attempt := api.WebhookDeliveryAttempt{ID: "wha-1", DeliveryID: "whd-1", Attempt: 1, StatusCode: 204, ResponseSnippet: "ok", DurationMs: 12, CreatedAt: stamp}
+	if err := store.RecordWebhookAttempt(ctx, done, attempt); err != nil {
+		t.Fatalf("record attempt: %v", err)
+	}
+
+	replay := delivery
+	replay.ID = "whd-2"
+	replay.ReplayOf = "whd-1"
+	if err := store.CreateWebhookDeliveries(ctx, []api.WebhookDelivery{replay}); err != nil {
+		t.Fatalf("create replay: %v", err)
+	}
+	deliveries, err := store.ListWebhookDeliveries(ctx, "tenant-a", "whk-1", api.WebhookDeliveryFilter{})
+	if err != nil || len(deliveries) != 2 {
+		t.Fatalf("list deliveries: %#v, %v", deliveries, err)
+	}
+	first, err := store.ListWebhookDeliveries(ctx, "tenant-a", "whk-1", api.WebhookDeliveryFilter{Limit: 1})
+	if err != nil || len(first) != 1 || first[0].ID != deliveries[0].ID {
+		t.Fatalf("list first page: %#v, %v", first, err)
+	}
+	cursor := api.WebhookDeliveryCursor{CreatedAt: first[0].CreatedAt, ID: first[0].ID}
+	rest, err := store.ListWebhookDeliveries(ctx, "tenant-a", "whk-1", api.WebhookDeliveryFilter{Limit: 1, After: &cursor})
+	if err != nil || len(rest) != 1 || rest[0].ID != deliveries[1].ID {
+		t.Fatalf("list page after %s: %#v, %v", first[0].ID, rest, err)
+	}
+	deliveries, err = store.ListWebhookDeliveries(ctx, "tenant-a", "whk-1", api.WebhookDeliveryFilter{Status: api.WebhookDeliverySucceeded})
+	if err != nil || len(deliveries) != 1 {
+		t.Fatalf("list succeeded deliveries: %#v, %v", deliveries, err)
+	}
+	if deliveries[0].Status != api.WebhookDeliverySucceeded || len(deliveries[0].AttemptLog) != 1 || deliveries[0].AttemptLog[0].StatusCode != 204 || deliveries[0].AttemptLog[0].ResponseSnippet != "ok" {
+		t.Fatalf("unexpected delivery %#v", deliveries[0])
+	}
+	replayed, err := store.GetWebhookDelivery(ctx, "tenant-a", "whd-2")
+	if err != nil || replayed.ReplayOf != "whd-1" || replayed.EventID != "evt-1" || len(replayed.AttemptLog) != 0 {
+		t.Fatalf("unexpected replay %#v, %v", replayed, err)
+	}
+	if _, err := store.GetWebhookDelivery(ctx, "tenant-b", "whd-2"); !errors.Is(err, api.ErrNotFound) {
+		t.Fatalf("expected other tenant lookup to miss, got %v", err)
+	}
+}
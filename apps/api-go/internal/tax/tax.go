@@ -0,0 +1,114 @@
+// Package tax computes the tax owed on invoice lines. Rates are in basis
+// points (1700 = 17%) and amounts in integer minor units. Tax is rounded
+// half away from zero to a whole minor unit, either on every line or once
+// for the whole invoice.
+package tax
+
+import "sort"
+
+// MaxRate is 100% expressed in basis points.
+const MaxRate = 10000
+
+// Rounding selects where fractional minor units are rounded.
+type Rounding string
+
+const (
+	// RoundLine rounds each line's tax and sums the rounded amounts.
+	RoundLine Rounding = "line"
+	// RoundInvoice sums the exact line taxes and rounds the total once. The
+	// rounded total is then spread over the lines so they still add up.
+	RoundInvoice Rounding = "invoice"
+)
+
+// Valid reports whether r is a known rounding mode.
+func (r Rounding) Valid() bool {
+	return r == RoundLine || r == RoundInvoice
+}
+
+// Line is one taxable amount and the rate that applies to it. A rate of 0
+// makes the line exempt.
+type Line struct {
+	Amount int64
+	Rate   int64
+}
+
+// Policy is how tax is computed for one invoice. Exempt zeroes the tax on
+// every line regardless of its rate, for customers that are not charged tax
+// at all. An empty Rounding means RoundLine.
+type Policy struct {
+	Rounding Rounding
+	Exempt   bool
+}
+
+// Result holds the tax of each input line, in order, and their sum.
+type Result struct {
+	Lines []int64
+	Total int64
+}
+
+// LineTax returns the tax on a single amount, rounded on its own.
+func LineTax(amount, rate int64) int64 {
+	return roundHalfAway(amount*rate, MaxRate)
+}
+
+// Compute returns the tax on lines under the policy. The caller must keep
+// amount * rate within int64, which invoice line validation guarantees.
+func (p Policy) Compute(lines []Line) Result {
+	result := Result{Lines: make([]int64, len(lines))}
+	if p.Exempt {
+		return result
+	}
+	if p.Rounding != RoundInvoice {
+		for i, line := range lines {
+			result.Lines[i] = LineTax(line.Amount, line.Rate)
+			result.Total += result.Lines[i]
+		}
+		return result
+	}
+
+	// Split each exact tax into whole minor units and a remainder in
+	// 1/MaxRate of a unit, so the sum cannot overflow where the products
+	// would.
+	remainders := make([]int64, len(lines))
+	var wholeSum, remainderSum int64
+	for i, line := range lines {
+		exact := line.Amount * line.Rate
+		result.Lines[i] = exact / MaxRate
+		remainders[i] = exact % MaxRate
+		wholeSum += result.Lines[i]
+		remainderSum += remainders[i]
+	}
+	result.Total = wholeSum + roundHalfAway(remainderSum, MaxRate)
+
+	// Hand the units gained or lost by rounding to the lines with the
+	// largest remainders in that direction, earlier lines first on ties.
+	extra := result.Total - wholeSum
+	order := make([]int, len(lines))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		if extra < 0 {
+			return remainders[order[a]] < remainders[order[b]]
+		}
+		return remainders[order[a]] > remainders[order[b]]
+	})
+	for _, i := range order {
+		switch {
+		case extra > 0:
+			result.Lines[i]++
+			extra--
+		case extra < 0:
+			result.Lines[i]--
+			extra++
+		}
+	}
+	return result
+}
+
+func roundHalfAway(numerator, denominator int64) int64 {
+	if numerator < 0 {
+		return -((-numerator + denominator/2) / denominator)
+	}
+	return (numerator + denominator/2) / denominator
+}
@@ -0,0 +1,119 @@
+package tax
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestComputeLineVersusInvoiceRounding(t *testing.T) {
+	cases := []struct {
+		name         string
+		lines        []Line
+		lineTotal    int64
+		lineTaxes    []int64
+		invoice      int64
+		invoiceTaxes []int64
+	}{
+		{
+			// Three halves round up one by one but only once as a sum.
+			name:         "half units",
+			lines:        []Line{{1, 5000}, {1, 5000}, {1, 5000}},
+			lineTotal:    3,
+			lineTaxes:    []int64{1, 1, 1},
+			invoice:      2,
+			invoiceTaxes: []int64{1, 1, 0},
+		},
+		{
+			// 333 * 17% = 56.61 per line: 57 * 10 against round(566.1).
+			name:         "repeated fraction",
+			lines:        slices.Repeat([]Line{{333, 1700}}, 10),
+			lineTotal:    570,
+			lineTaxes:    slices.Repeat([]int64{57}, 10),
+			invoice:      566,
+			invoiceTaxes: []int64{57, 57, 57, 57, 57, 57, 56, 56, 56, 56},
+		},
+		{
+			// 0.4 + 0.4 rounds down per line but up as a sum; the unit goes
+			// to the first of the tied remainders.
+			name:         "fractions that only add up together",
+			lines:        []Line{{4, 1000}, {4, 1000}},
+			lineTotal:    0,
+			lineTaxes:    []int64{0, 0},
+			invoice:      1,
+			invoiceTaxes: []int64{1, 0},
+		},
+		{
+			// The exempt line carries no tax in either mode and takes no
+			// share of the rounding.
+			name:         "exempt line",
+			lines:        []Line{{999, 1750}, {5000, 0}, {1, 5000}},
+			lineTotal:    176,
+			lineTaxes:    []int64{175, 0, 1},
+			invoice:      175,
+			invoiceTaxes: []int64{175, 0, 0},
+		},
+		{
+			name:         "credit",
+			lines:        []Line{{-1, 5000}, {-1, 5000}, {-1, 5000}},
+			lineTotal:    -3,
+			lineTaxes:    []int64{-1, -1, -1},
+			invoice:      -2,
+			invoiceTaxes: []int64{-1, -1, 0},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			line := Policy{Rounding: RoundLine}.Compute(tc.lines)
+			if line.Total != tc.lineTotal || !slices.Equal(line.Lines, tc.lineTaxes) {
+				t.Errorf("line rounding = %d %v, want %d %v", line.Total, line.Lines, tc.lineTotal, tc.lineTaxes)
+			}
+			invoice := Policy{Rounding: RoundInvoice}.Compute(tc.lines)
+			if invoice.Total != tc.invoice || !slices.Equal(invoice.Lines, tc.invoiceTaxes) {
+				t.Errorf("invoice rounding = %d %v, want %d %v", invoice.Total, invoice.Lines, tc.invoice, tc.invoiceTaxes)
+			}
+			if sum := sumOf(invoice.Lines); sum != invoice.Total {
+				t.Errorf("invoice rounding lines add up to %d, total is %d", sum, invoice.Total)
+			}
+		})
+	}
+}
+
+func TestComputeDefaultsToLineRounding(t *testing.T) {
+	lines := []Line{{1, 5000}, {1, 5000}, {1, 5000}}
+	if got := (Policy{}).Compute(lines); got.Total != 3 {
+		t.Fatalf("expected line rounding by default, got %d", got.Total)
+	}
+}
+
+func TestComputeExemptCustomer(t *testing.T) {
+	lines := []Line{{10000, 1700}, {333, 1750}}
+	for _, rounding := range []Rounding{RoundLine, RoundInvoice} {
+		got := Policy{Rounding: rounding, Exempt: true}.Compute(lines)
+		if got.Total != 0 || !slices.Equal(got.Lines, []int64{0, 0}) {
+			t.Errorf("%s: expected no tax for an exempt customer, got %d %v", rounding, got.Total, got.Lines)
+		}
+	}
+}
+
+func TestLineTaxRoundsHalfAwayFromZero(t *testing.T) {
+	cases := []struct{ amount, rate, want int64 }{
+		{999, 1750, 175}, // 174.825
+		{1, 5000, 1},     // 0.5
+		{1, 4999, 0},     // 0.4999
+		{-1, 5000, -1},   // -0.5
+		{10000, 0, 0},
+	}
+	for _, tc := range cases {
+		if got := LineTax(tc.amount, tc.rate); got != tc.want {
+			t.Errorf("LineTax(%d, %d) = %d, want %d", tc.amount, tc.rate, got, tc.want)
+		}
+	}
+}
+
+func sumOf(values []int64) int64 {
+	var sum int64
+	for _, v := range values {
+		sum += v
+	}
+	return sum
+}
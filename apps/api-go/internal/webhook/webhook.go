@@ -0,0 +1,204 @@
+// Package webhook signs and sends outbound webhook requests and decides when
+// a failed delivery should be retried. It knows nothing about storage; the
+// API server owns the delivery queue.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const (
+	SignatureHeader = "X-Signature"
+	EventHeader     = "X-Webhook-Event"
+	DeliveryHeader  = "X-Webhook-Delivery"
+
+	signaturePrefix = "sha256="
+	// maxResponseBody bounds how much of a receiver's reply we read, so a
+	// misbehaving endpoint cannot make the worker buffer arbitrary data.
+	maxResponseBody = 4 << 10
+	// maxResponseSnippet is how much of the reply is kept for inspection.
+	maxResponseSnippet = 1 << 10
+)
+
+// Sign returns the X-Signature value for body: "sha256=" followed by the hex
+// HMAC-SHA256 of the exact bytes sent, keyed with the subscription secret.
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return signaturePrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify reports whether signature is a valid Sign result for body. It is
+// what receivers are expected to do and is used in tests.
+func Verify(secret string, body []byte, signature string) bool {
+	return hmac.Equal([]byte(Sign(secret, body)), []byte(strings.TrimSpace(signature)))
+}
+
+// Request is one delivery attempt.
+type Request struct {
+	URL        string
+	Secret     string
+	Event      string
+	DeliveryID string
+	Body       []byte
+}
+
+// Result is what the receiver answered. StatusCode is 0 when no response
+// arrived at all. Snippet is the start of the reply body, cut to a size and
+// form that can be stored as text.
+type Result struct {
+	StatusCode int
+	Duration   time.Duration
+	Snippet    string
+}
+
+// Succeeded reports whether the receiver acknowledged the event.
+func (r Result) Succeeded() bool {
+	return r.StatusCode >= 200 && r.StatusCode < 300
+}
+
+// Sender posts webhook requests.
+type Sender interface {
+	Send(ctx context.Context, req Request) (Result, error)
+}
+
+// ErrPrivateAddress is returned for targets on loopback, private, link-local
+// or unspecified addresses. Attempt snippets are readable by the tenant, so
+// letting deliveries reach such addresses would expose internal services.
+var ErrPrivateAddress = errors.New("webhook targets must not be loopback, private, link-local or unspecified addresses")
+
+// CheckAddress returns ErrPrivateAddress when addr must not receive webhooks.
+func CheckAddress(addr netip.Addr) error {
+	addr = addr.Unmap()
+	if !addr.IsValid() || addr.IsLoopback() || addr.IsPrivate() || addr.IsUnspecified() ||
+		addr.IsLinkLocalUnicast() || addr.IsLinkLocalMulticast() || addr.IsInterfaceLocalMulticast() {
+		return ErrPrivateAddress
+	}
+	return nil
+}
+
+// CheckHost applies CheckAddress to a URL host that is an IP literal, and
+// rejects localhost names. Other names are only checked when dialed, since
+// what they resolve to can change after a subscription is created.
+func CheckHost(host string) error {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return ErrPrivateAddress
+	}
+	if addr, err := netip.ParseAddr(strings.Trim(host, "[]")); err == nil {
+		return CheckAddress(addr)
+	}
+	return nil
+}
+
+// HTTPSender sends requests with an http.Client.
+type HTTPSender struct {
+	Client *http.Client
+}
+
+// NewHTTPSender returns a sender whose requests give up after timeout. It
+// refuses to connect to addresses rejected by CheckAddress, whatever the
+// target name resolved to, and does not follow redirects, which could
+// otherwise point a delivery at an internal address.
+func NewHTTPSender(timeout time.Duration) *HTTPSender {
+	dialer := &net.Dialer{Timeout: timeout, Control: dialControl}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return &HTTPSender{Client: &http.Client{
+		Timeout:   timeout,
+		Transport: transport,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}}
+}
+
+// dialControl runs after name resolution, on the address actually dialed.
+func dialControl(_, address string, _ syscall.RawConn) error {
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return fmt.Errorf("webhook dial %s: %w", address, err)
+	}
+	if err := CheckAddress(addrPort.Addr()); err != nil {
+		return fmt.Errorf("webhook dial %s: %w", address, err)
+	}
+	return nil
+}
+
+func (s *HTTPSender) Send(ctx context.Context, req Request) (Result, error) {
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, req.URL, bytes.NewReader(req.Body))
+	if err != nil {
+		return Result{}, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("User-Agent", "invoices-platform-webhooks/1")
+	httpReq.Header.Set(SignatureHeader, Sign(req.Secret, req.Body))
+	httpReq.Header.Set(EventHeader, req.Event)
+	httpReq.Header.Set(DeliveryHeader, req.DeliveryID)
+
+	started := time.Now()
+	resp, err := s.Client.Do(httpReq)
+	if err != nil {
+		return Result{Duration: time.Since(started)}, err
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxResponseBody))
+	result := Result{StatusCode: resp.StatusCode, Duration: time.Since(started), Snippet: snippet(body)}
+	if !result.Succeeded() {
+		return result, fmt.Errorf("receiver answered %d", resp.StatusCode)
+	}
+	return result, nil
+}
+
+// snippet returns the first maxResponseSnippet bytes of body as valid UTF-8
+// without NUL bytes, which Postgres text columns reject.
+func snippet(body []byte) string {
+	body = body[:min(len(body), maxResponseSnippet)]
+	return strings.ReplaceAll(strings.ToValidUTF8(string(body), "\uFFFD"), "\x00", "")
+}
+
+// RetryPolicy bounds redelivery of failed events.
+type RetryPolicy struct {
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+}
+
+// DefaultRetryPolicy retries for roughly a day: 1m, 2m, 4m, ... capped at 6h.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 8,
+	BaseDelay:   time.Minute,
+	MaxDelay:    6 * time.Hour,
+}
+
+// Delay is how long to wait after the given failed attempt (1-based) before
+// the next one: BaseDelay doubled per attempt, capped at MaxDelay.
+func (p RetryPolicy) Delay(attempt int) time.Duration {
+	if p.BaseDelay <= 0 {
+		return 0
+	}
+	delay := p.BaseDelay
+	for i := 1; i < attempt && delay < p.MaxDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, p.MaxDelay)
+}
+
+// Exhausted reports whether no attempt should follow the given one.
+func (p RetryPolicy) Exhausted(attempt int) bool {
+	return attempt >= p.MaxAttempts
+}
@@ -0,0 +1,145 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSignMatchesKnownVector(t *testing.T) {
+	// echo -n '{"id":"evt-1"}' | openssl dgst -sha256 -hmac whsec_test
+	const want = "sha256=0fb276fce3756bee2777a7d614aa8b582cb7a3be6f1618dcf074398f19272977"
+	got := Sign("whsec_test", []byte(`{"id":"evt-1"}`))
+	if !Verify("whsec_test", []byte(`{"id":"evt-1"}`), got) {
+		t.Fatalf("expected signature %q to verify", got)
+	}
+	if got != want {
+		t.Fatalf("Sign = %q, want %q", got, want)
+	}
+	if Verify("other", []byte(`{"id":"evt-1"}`), got) || Verify("whsec_test", []byte(`{"id":"evt-2"}`), got) {
+		t.Fatal("expected signature to bind both secret and body")
+	}
+}
+
+func TestRetryPolicyBacksOffExponentially(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 5, BaseDelay: time.Second, MaxDelay: 5 * time.Second}
+	want := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second}
+	for i, delay := range want {
+		if got := policy.Delay(i + 1); got != delay {
+			t.Errorf("Delay(%d) = %v, want %v", i+1, got, delay)
+		}
+	}
+	if policy.Exhausted(4) || !policy.Exhausted(5) {
+		t.Fatal("expected policy to stop after 5 attempts")
+	}
+	if got := DefaultRetryPolicy.Delay(1000); got != DefaultRetryPolicy.MaxDelay {
+		t.Fatalf("expected large attempts to cap at MaxDelay, got %v", got)
+	}
+}
+
+func TestHTTPSenderSignsAndReportsStatus(t *testing.T) {
+	var gotSignature, gotEvent, gotBody string
+	status, reply := http.StatusOK, "accepted"
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		gotBody = string(body)
+		gotSignature = r.Header.Get(SignatureHeader)
+		gotEvent = r.Header.Get(EventHeader)
+		w.WriteHeader(status)
+		io.WriteString(w, reply)
+	}))
+	defer receiver.Close()
+
+	// The receiver listens on loopback, which the default transport refuses.
+	sender := NewHTTPSender(time.Second)
+	sender.Client.Transport = receiver.Client().Transport
+	req := Request{URL: receiver.URL, Secret: "s3cret", Event: "invoice.created", DeliveryID: "whd-1", Body: []byte(`{"ok":true}`)}
+	result, err := sender.Send(context.Background(), req)
+	if err != nil || !result.Succeeded() || result.Snippet != "accepted" {
+		t.Fatalf("expected success, got %+v, %v", result, err)
+	}
+	if gotBody != `{"ok":true}` || gotEvent != "invoice.created" || !Verify("s3cret", []byte(gotBody), gotSignature) {
+		t.Fatalf("unexpected request: body=%q event=%q signature=%q", gotBody, gotEvent, gotSignature)
+	}
+
+	status, reply = http.StatusBadGateway, "\x00"+strings.Repeat("é", maxResponseSnippet)
+	result, err = sender.Send(context.Background(), req)
+	if err == nil || result.StatusCode != http.StatusBadGateway || result.Succeeded() {
+		t.Fatalf("expected 502 failure, got %+v, %v", result, err)
+	}
+	// The NUL is dropped and the last é, cut in half, replaced.
+	if want := strings.Repeat("é", (maxResponseSnippet-1)/2) + "\uFFFD"; result.Snippet != want {
+		t.Fatalf("expected the reply cut to %d bytes of text, got %d bytes", maxResponseSnippet, len(result.Snippet))
+	}
+}
+
+func TestCheckAddressRejectsInternalTargets(t *testing.T) {
+	rejected := map[string][]string{
+		"loopback":    {"127.0.0.1", "127.8.8.8", "::1", "::ffff:127.0.0.1"},
+		"private":     {"10.0.0.1", "172.16.5.4", "192.168.1.1", "fd00::1"},
+		"link-local":  {"169.254.169.254", "fe80::1", "ff02::1"},
+		"unspecified": {"0.0.0.0", "::"},
+	}
+	for class, addrs := range rejected {
+		for _, raw := range addrs {
+			if err := CheckAddress(netip.MustParseAddr(raw)); !errors.Is(err, ErrPrivateAddress) {
+				t.Errorf("%s address %s: expected ErrPrivateAddress, got %v", class, raw, err)
+			}
+		}
+	}
+	for _, raw := range []string{"93.184.216.34", "2606:2800:220:1:248:1893:25c8:1946", "172.32.0.1"} {
+		if err := CheckAddress(netip.MustParseAddr(raw)); err != nil {
+			t.Errorf("public address %s: expected it to be allowed, got %v", raw, err)
+		}
+	}
+}
+
+func TestCheckHostRejectsLiteralsAndLocalhost(t *testing.T) {
+	for _, host := range []string{"localhost", "api.localhost.", "127.0.0.1", "[::1]", "10.1.2.3", "169.254.169.254", "0.0.0.0"} {
+		if err := CheckHost(host); !errors.Is(err, ErrPrivateAddress) {
+			t.Errorf("host %s: expected ErrPrivateAddress, got %v", host, err)
+		}
+	}
+	for _, host := range []string{"hooks.example.com", "93.184.216.34"} {
+		if err := CheckHost(host); err != nil {
+			t.Errorf("host %s: expected it to be allowed, got %v", host, err)
+		}
+	}
+}
+
+func TestHTTPSenderRefusesToDialInternalAddresses(t *testing.T) {
+	var hits int
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { hits++ }))
+	defer receiver.Close()
+
+	result, err := NewHTTPSender(time.Second).Send(context.Background(), Request{URL: receiver.URL, Secret: "s3cret", Body: []byte(`{}`)})
+	if !errors.Is(err, ErrPrivateAddress) || result.StatusCode != 0 || hits != 0 {
+		t.Fatalf("expected the dial to be refused, got %+v, %v (hits %d)", result, err, hits)
+	}
+}
+
+func TestHTTPSenderDoesNotFollowRedirects(t *testing.T) {
+	var internalHits int
+	internal := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		internalHits++
+		io.WriteString(w, "secret")
+	}))
+	defer internal.Close()
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, internal.URL, http.StatusTemporaryRedirect)
+	}))
+	defer receiver.Close()
+
+	sender := NewHTTPSender(time.Second)
+	sender.Client.Transport = receiver.Client().Transport
+	result, err := sender.Send(context.Background(), Request{URL: receiver.URL, Secret: "s3cret", Body: []byte(`{}`)})
+	if err == nil || result.StatusCode != http.StatusTemporaryRedirect || internalHits != 0 {
+		t.Fatalf("expected the redirect to be reported as a failure, got %+v, %v (internal hits %d)", result, err, internalHits)
+	}
+}
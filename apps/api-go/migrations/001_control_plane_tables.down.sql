@@ -0,0 +1,6 @@
+drop table if exists audit_events;
+drop table if exists schedules;
+drop table if exists reports;
+drop table if exists collection_jobs;
+drop table if exists provider_configs;
+drop table if exists sessions;
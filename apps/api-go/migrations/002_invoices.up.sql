@@ -0,0 +1,22 @@
+create table if not exists invoices (
+    id text primary key,
+    tenant_id text not null,
+    number text not null,
+    customer_id text not null,
+    currency text not null,
+    subtotal bigint not null,
+    tax bigint not null,
+    total bigint not null,
+    status text not null,
+    issued_at timestamptz,
+    due_at timestamptz,
+    created_at timestamptz not null,
+    updated_at timestamptz not null,
+    constraint invoices_totals_check check (subtotal + tax = total)
+);
+
+create unique index if not exists invoices_tenant_number_idx
+    on invoices (tenant_id, number);
+
+create index if not exists invoices_tenant_issued_idx
+    on invoices (tenant_id, issued_at desc);
@@ -0,0 +1,7 @@
+drop index if exists invoices_tenant_customer_idx;
+drop index if exists invoices_tenant_status_idx;
+drop index if exists invoices_tenant_total_id_idx;
+drop index if exists invoices_tenant_issued_id_idx;
+
+create index if not exists invoices_tenant_issued_idx
+    on invoices (tenant_id, issued_at desc);
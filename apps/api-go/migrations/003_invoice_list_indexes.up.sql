@@ -0,0 +1,13 @@
+drop index if exists invoices_tenant_issued_idx;
+
+create index if not exists invoices_tenant_issued_id_idx
+    on invoices (tenant_id, (coalesce(issued_at, '-infinity'::timestamptz)) desc, (id collate "C") desc);
+
+create index if not exists invoices_tenant_total_id_idx
+    on invoices (tenant_id, total desc, (id collate "C") desc);
+
+create index if not exists invoices_tenant_status_idx
+    on invoices (tenant_id, status);
+
+create index if not exists invoices_tenant_customer_idx
+    on invoices (tenant_id, customer_id);
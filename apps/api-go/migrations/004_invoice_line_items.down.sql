@@ -0,0 +1 @@
+drop table if exists invoice_line_items;
@@ -0,0 +1,20 @@
+create table if not exists invoice_line_items (
+    id text primary key,
+    tenant_id text not null,
+    invoice_id text not null references invoices (id) on delete cascade,
+    position integer not null,
+    description text not null,
+    quantity bigint not null,
+    unit_price bigint not null,
+    tax_rate bigint not null,
+    amount bigint not null,
+    tax bigint not null,
+    created_at timestamptz not null,
+    constraint invoice_line_items_quantity_check check (quantity > 0),
+    constraint invoice_line_items_unit_price_check check (unit_price >= 0),
+    constraint invoice_line_items_tax_rate_check check (tax_rate between 0 and 10000),
+    constraint invoice_line_items_amount_check check (amount = quantity * unit_price)
+);
+
+create unique index if not exists invoice_line_items_invoice_position_idx
+    on invoice_line_items (invoice_id, position);
@@ -0,0 +1,8 @@
+drop index if exists collection_jobs_tenant_status_updated_idx;
+
+alter table collection_jobs
+    drop constraint if exists collection_jobs_status_check;
+
+alter table collection_jobs
+    drop column if exists queued_at,
+    drop column if exists attempt;
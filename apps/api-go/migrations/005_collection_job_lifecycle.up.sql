@@ -0,0 +1,14 @@
+alter table collection_jobs
+    add column if not exists attempt integer not null default 1,
+    add column if not exists queued_at timestamptz;
+
+update collection_jobs set queued_at = created_at where queued_at is null;
+
+alter table collection_jobs
+    drop constraint if exists collection_jobs_status_check;
+alter table collection_jobs
+    add constraint collection_jobs_status_check
+    check (status in ('queued', 'running', 'succeeded', 'failed'));
+
+create index if not exists collection_jobs_tenant_status_updated_idx
+    on collection_jobs (tenant_id, status, updated_at desc);
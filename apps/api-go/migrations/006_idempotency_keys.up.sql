@@ -0,0 +1,14 @@
+create table if not exists idempotency_keys (
+    tenant_id text not null,
+    scope text not null,
+    key text not null,
+    status_code integer not null default 0,
+    content_type text,
+    response_body bytea,
+    created_at timestamptz not null,
+    expires_at timestamptz not null,
+    primary key (tenant_id, scope, key)
+);
+
+create index if not exists idempotency_keys_expires_idx
+    on idempotency_keys (expires_at);
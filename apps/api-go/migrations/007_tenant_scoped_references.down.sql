@@ -0,0 +1,12 @@
+drop index if exists collection_jobs_tenant_retry_of_idx;
+alter table collection_jobs
+    drop constraint if exists collection_jobs_tenant_retry_of_fkey;
+drop index if exists collection_jobs_tenant_id_idx;
+
+alter table invoice_line_items
+    drop constraint if exists invoice_line_items_tenant_invoice_fkey;
+alter table invoice_line_items
+    add constraint invoice_line_items_invoice_id_fkey
+    foreign key (invoice_id) references invoices (id) on delete cascade;
+
+drop index if exists invoices_tenant_id_idx;
@@ -0,0 +1,26 @@
+-- Child rows must point at a parent in the same tenant. Keying the foreign
+-- keys on (tenant_id, id) makes a cross-tenant reference impossible even if
+-- an application query forgets its tenant filter.
+create unique index if not exists invoices_tenant_id_idx
+    on invoices (tenant_id, id);
+
+alter table invoice_line_items
+    drop constraint if exists invoice_line_items_invoice_id_fkey;
+alter table invoice_line_items
+    drop constraint if exists invoice_line_items_tenant_invoice_fkey;
+alter table invoice_line_items
+    add constraint invoice_line_items_tenant_invoice_fkey
+    foreign key (tenant_id, invoice_id) references invoices (tenant_id, id) on delete cascade;
+
+create unique index if not exists collection_jobs_tenant_id_idx
+    on collection_jobs (tenant_id, id);
+
+alter table collection_jobs
+    drop constraint if exists collection_jobs_tenant_retry_of_fkey;
+alter table collection_jobs
+    add constraint collection_jobs_tenant_retry_of_fkey
+    foreign key (tenant_id, retry_of) references collection_jobs (tenant_id, id);
+
+create index if not exists collection_jobs_tenant_retry_of_idx
+    on collection_jobs (tenant_id, retry_of)
+    where retry_of is not null;
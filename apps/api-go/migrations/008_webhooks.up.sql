@@ -0,0 +1,54 @@
+create table if not exists webhook_subscriptions (
+    id text primary key,
+    tenant_id text not null,
+    url text not null,
+    events text[] not null,
+    secret text not null,
+    created_at timestamptz not null,
+    updated_at timestamptz not null,
+    constraint webhook_subscriptions_events_check check (cardinality(events) > 0)
+);
+
+create unique index if not exists webhook_subscriptions_tenant_id_idx
+    on webhook_subscriptions (tenant_id, id);
+create index if not exists webhook_subscriptions_tenant_created_idx
+    on webhook_subscriptions (tenant_id, created_at desc);
+
+create table if not exists webhook_deliveries (
+    id text primary key,
+    tenant_id text not null,
+    subscription_id text not null,
+    event_id text not null,
+    event text not null,
+    payload jsonb not null,
+    status text not null,
+    attempts integer not null default 0,
+    next_attempt_at timestamptz,
+    last_status_code integer,
+    last_error text,
+    created_at timestamptz not null,
+    updated_at timestamptz not null,
+    constraint webhook_deliveries_status_check check (status in ('pending', 'succeeded', 'failed')),
+    constraint webhook_deliveries_subscription_fkey
+        foreign key (tenant_id, subscription_id) references webhook_subscriptions (tenant_id, id) on delete cascade
+);
+
+-- The worker polls for due pending deliveries across all tenants.
+create index if not exists webhook_deliveries_due_idx
+    on webhook_deliveries (next_attempt_at)
+    where status = 'pending';
+create index if not exists webhook_deliveries_subscription_created_idx
+    on webhook_deliveries (tenant_id, subscription_id, created_at desc);
+
+create table if not exists webhook_delivery_attempts (
+    id text primary key,
+    delivery_id text not null references webhook_deliveries (id) on delete cascade,
+    attempt integer not null,
+    status_code integer,
+    error_text text,
+    duration_ms bigint not null,
+    created_at timestamptz not null
+);
+
+create unique index if not exists webhook_delivery_attempts_delivery_attempt_idx
+    on webhook_delivery_attempts (delivery_id, attempt);
@@ -0,0 +1,3 @@
+alter table invoices drop constraint if exists invoices_paid_at_check;
+alter table invoices drop column if exists payment_reference;
+alter table invoices drop column if exists paid_at;
@@ -0,0 +1,12 @@
+-- Paid invoices record when they were settled and, optionally, the bank or
+-- processor reference. Rows that were already paid take their last update
+-- time so the new check constraint holds for existing data.
+alter table invoices add column if not exists paid_at timestamptz;
+alter table invoices add column if not exists payment_reference text;
+
+update invoices set paid_at = updated_at where status = 'paid' and paid_at is null;
+
+alter table invoices drop constraint if exists invoices_paid_at_check;
+alter table invoices
+    add constraint invoices_paid_at_check
+    check (status <> 'paid' or paid_at is not null);
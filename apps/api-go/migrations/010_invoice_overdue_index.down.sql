@@ -0,0 +1 @@
+drop index if exists invoices_open_due_at_idx;
@@ -0,0 +1,4 @@
+-- The overdue scheduler scans open invoices by due date.
+create index if not exists invoices_open_due_at_idx
+    on invoices (due_at)
+    where status = 'open';
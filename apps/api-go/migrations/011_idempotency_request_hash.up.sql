@@ -0,0 +1,4 @@
+-- A key reused with a different request body is rejected instead of
+-- replaying a response to some other request. Keys stored before this
+-- migration have an empty hash and keep replaying until they expire.
+alter table idempotency_keys add column if not exists request_hash text not null default '';
@@ -0,0 +1,21 @@
+-- Customers are who invoices bill to. invoices.customer_id predates this
+-- table and holds free-form references, so there is no foreign key; deleting
+-- a referenced customer is refused by the application instead.
+create table if not exists customers (
+    id text primary key,
+    tenant_id text not null,
+    name text not null,
+    email text not null default '',
+    billing_address jsonb not null default '{}'::jsonb,
+    tax_id text not null default '',
+    default_currency text not null default '',
+    created_at timestamptz not null,
+    updated_at timestamptz not null,
+    deleted_at timestamptz
+);
+
+create unique index if not exists customers_tenant_id_idx
+    on customers (tenant_id, id);
+create index if not exists customers_tenant_created_idx
+    on customers (tenant_id, created_at desc)
+    where deleted_at is null;
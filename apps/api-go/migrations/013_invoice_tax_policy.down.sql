@@ -0,0 +1,7 @@
+alter table customers drop column if exists tax_exempt;
+
+alter table invoices drop constraint if exists invoices_tax_rounding_check;
+alter table invoices drop constraint if exists invoices_tax_rate_check;
+alter table invoices drop column if exists tax_exempt;
+alter table invoices drop column if exists tax_rounding;
+alter table invoices drop column if exists tax_rate;
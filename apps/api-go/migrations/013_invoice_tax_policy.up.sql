@@ -0,0 +1,28 @@
+-- Tax is computed by the server. Invoices record how: the rate for invoices
+-- without line items, where fractions are rounded, and whether the customer
+-- was exempt when the invoice was written.
+alter table invoices add column if not exists tax_rate integer not null default 0;
+alter table invoices add column if not exists tax_rounding text not null default 'line';
+alter table invoices add column if not exists tax_exempt boolean not null default false;
+
+alter table invoices drop constraint if exists invoices_tax_rate_check;
+alter table invoices
+    add constraint invoices_tax_rate_check
+    check (tax_rate between 0 and 10000);
+alter table invoices drop constraint if exists invoices_tax_rounding_check;
+alter table invoices
+    add constraint invoices_tax_rounding_check
+    check (tax_rounding in ('line', 'invoice'));
+
+-- Invoices without line items carried a client-computed tax. Keep the rate
+-- it implies so a later change to the subtotal is taxed the same way.
+update invoices i
+set tax_rate = least(10000, round(i.tax * 10000.0 / i.subtotal))
+where i.subtotal > 0
+    and i.tax > 0
+    and not exists (
+        select 1 from invoice_line_items li
+        where li.tenant_id = i.tenant_id and li.invoice_id = i.id
+    );
+
+alter table customers add column if not exists tax_exempt boolean not null default false;
@@ -0,0 +1,4 @@
+alter table invoices drop constraint if exists invoices_amount_paid_check;
+alter table invoices drop column if exists amount_paid;
+
+drop table if exists invoice_payments;
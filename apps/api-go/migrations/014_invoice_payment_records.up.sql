@@ -0,0 +1,29 @@
+-- Invoices are settled by one or more recorded payments. amount_paid is the
+-- running sum, kept on the invoice so the row lock taken to record a payment
+-- also covers the check against the total. Invoices that were already paid
+-- count as paid in full.
+create table if not exists invoice_payments (
+    id text primary key,
+    tenant_id text not null,
+    invoice_id text not null,
+    amount bigint not null,
+    method text not null,
+    received_at timestamptz not null,
+    reference text,
+    created_at timestamptz not null,
+    constraint invoice_payments_amount_check check (amount > 0),
+    constraint invoice_payments_tenant_invoice_fkey
+        foreign key (tenant_id, invoice_id) references invoices (tenant_id, id) on delete cascade
+);
+
+create index if not exists invoice_payments_tenant_invoice_idx
+    on invoice_payments (tenant_id, invoice_id, received_at);
+
+alter table invoices add column if not exists amount_paid bigint not null default 0;
+
+update invoices set amount_paid = total where status = 'paid' and amount_paid = 0;
+
+alter table invoices drop constraint if exists invoices_amount_paid_check;
+alter table invoices
+    add constraint invoices_amount_paid_check
+    check (amount_paid >= 0);
@@ -0,0 +1,14 @@
+-- pg_trgm stays installed: dropping an extension other objects may use is
+-- not this migration's call.
+drop index if exists invoice_line_items_description_trgm_idx;
+drop index if exists invoice_line_items_search_idx;
+alter table invoice_line_items drop column if exists search_vector;
+
+drop index if exists customers_name_trgm_idx;
+drop index if exists customers_search_idx;
+alter table customers drop column if exists search_vector;
+
+drop index if exists invoices_customer_id_trgm_idx;
+drop index if exists invoices_number_trgm_idx;
+drop index if exists invoices_search_idx;
+alter table invoices drop column if exists search_vector;
@@ -0,0 +1,32 @@
+-- Invoice search matches invoice numbers and customer IDs, customer names
+-- and line item descriptions. Each table gets a generated tsvector for
+-- full-text search and trigram indexes for the substring search short
+-- queries use. The 'simple' configuration does no stemming, so names and
+-- numbers in any language match as written.
+create extension if not exists pg_trgm;
+
+alter table invoices add column if not exists search_vector tsvector
+    generated always as (
+        setweight(to_tsvector('simple', number), 'A')
+        || setweight(to_tsvector('simple', customer_id), 'B')
+    ) stored;
+create index if not exists invoices_search_idx
+    on invoices using gin (search_vector);
+create index if not exists invoices_number_trgm_idx
+    on invoices using gin (number gin_trgm_ops);
+create index if not exists invoices_customer_id_trgm_idx
+    on invoices using gin (customer_id gin_trgm_ops);
+
+alter table customers add column if not exists search_vector tsvector
+    generated always as (setweight(to_tsvector('simple', name), 'B')) stored;
+create index if not exists customers_search_idx
+    on customers using gin (search_vector);
+create index if not exists customers_name_trgm_idx
+    on customers using gin (name gin_trgm_ops);
+
+alter table invoice_line_items add column if not exists search_vector tsvector
+    generated always as (setweight(to_tsvector('simple', description), 'C')) stored;
+create index if not exists invoice_line_items_search_idx
+    on invoice_line_items using gin (search_vector);
+create index if not exists invoice_line_items_description_trgm_idx
+    on invoice_line_items using gin (description gin_trgm_ops);
@@ -0,0 +1,2 @@
+drop table if exists recurring_invoice_runs;
+drop table if exists recurring_invoices;
@@ -0,0 +1,48 @@
+-- Recurring invoices are templates billed on a schedule. Each billed period
+-- gets a row in recurring_invoice_runs, written in the same transaction as
+-- the invoice and the advanced next_run_at, so the primary key refuses a
+-- second invoice for a period even if a worker restarts mid-scan.
+create table if not exists recurring_invoices (
+    id text primary key,
+    tenant_id text not null,
+    customer_id text not null,
+    currency text not null,
+    items jsonb not null,
+    tax_rounding text not null default 'line',
+    invoice_status text not null,
+    due_days integer not null default 0,
+    frequency text not null,
+    interval_count integer not null,
+    anchor_day integer not null default 0,
+    start_date date not null,
+    status text not null,
+    next_run_at timestamptz not null,
+    last_run_at timestamptz,
+    last_invoice_id text,
+    created_at timestamptz not null,
+    updated_at timestamptz not null,
+    constraint recurring_invoices_frequency_check check (frequency in ('weekly', 'monthly')),
+    constraint recurring_invoices_status_check check (status in ('active', 'paused')),
+    constraint recurring_invoices_invoice_status_check check (invoice_status in ('draft', 'open'))
+);
+
+create unique index if not exists recurring_invoices_tenant_id_idx
+    on recurring_invoices (tenant_id, id);
+create index if not exists recurring_invoices_tenant_created_idx
+    on recurring_invoices (tenant_id, created_at desc);
+create index if not exists recurring_invoices_due_idx
+    on recurring_invoices (next_run_at)
+    where status = 'active';
+
+-- invoice_id has no foreign key: deleting a generated invoice must not make
+-- its period billable again.
+create table if not exists recurring_invoice_runs (
+    tenant_id text not null,
+    recurring_invoice_id text not null,
+    period date not null,
+    invoice_id text not null,
+    created_at timestamptz not null,
+    primary key (tenant_id, recurring_invoice_id, period),
+    constraint recurring_invoice_runs_tenant_recurring_fkey
+        foreign key (tenant_id, recurring_invoice_id) references recurring_invoices (tenant_id, id) on delete cascade
+);
@@ -0,0 +1,2 @@
+drop table if exists audit_log;
+alter table invoices drop column if exists deleted_at;
@@ -0,0 +1,22 @@
+-- Invoices are soft-deleted like customers so auditors keep their history.
+-- audit_log records each change to an invoice or customer with its actor
+-- and a field diff, written in the transaction of the change.
+alter table invoices add column if not exists deleted_at timestamptz;
+
+create table if not exists audit_log (
+    seq bigserial primary key,
+    id text not null unique,
+    tenant_id text not null,
+    entity_type text not null,
+    entity_id text not null,
+    action text not null,
+    actor_type text not null,
+    actor_id text,
+    actor_email text,
+    request_id text,
+    changes jsonb not null,
+    created_at timestamptz not null
+);
+
+create index if not exists audit_log_entity_idx
+    on audit_log (tenant_id, entity_type, entity_id, seq);
@@ -0,0 +1 @@
+alter table invoices drop column if exists version;
@@ -0,0 +1,3 @@
+-- Every invoice write bumps version, which backs the ETag of invoice reads
+-- and the If-Match check of invoice updates.
+alter table invoices add column if not exists version bigint not null default 1;
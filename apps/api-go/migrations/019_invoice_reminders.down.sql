@@ -0,0 +1,2 @@
+drop index if exists invoices_overdue_due_at_idx;
+drop table if exists invoice_reminders;
@@ -0,0 +1,20 @@
+-- Dunning reminders sent per invoice. The step is claimed here before the
+-- email goes out, so the primary key keeps a restarted or concurrent worker
+-- from sending the same reminder twice.
+create table if not exists invoice_reminders (
+    tenant_id text not null,
+    invoice_id text not null,
+    step integer not null,
+    email text not null,
+    manual boolean not null default false,
+    sent_at timestamptz not null,
+    primary key (tenant_id, invoice_id, step),
+    constraint invoice_reminders_step_check check (step > 0),
+    constraint invoice_reminders_tenant_invoice_fkey
+        foreign key (tenant_id, invoice_id) references invoices (tenant_id, id) on delete cascade
+);
+
+-- The dunning scan reads overdue invoices by due date.
+create index if not exists invoices_overdue_due_at_idx
+    on invoices (due_at)
+    where status = 'overdue' and deleted_at is null;
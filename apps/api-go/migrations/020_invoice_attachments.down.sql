@@ -0,0 +1,2 @@
+drop index if exists invoice_attachments_tenant_checksum_idx;
+drop table if exists invoice_attachments;
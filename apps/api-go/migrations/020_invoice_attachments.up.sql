@@ -0,0 +1,21 @@
+-- Files attached to invoices. The blob lives in the object store under
+-- object_key; an invoice holds each distinct file once, and the checksum
+-- index finds a tenant's existing blob so it is not uploaded again.
+create table if not exists invoice_attachments (
+    id text primary key,
+    tenant_id text not null,
+    invoice_id text not null,
+    filename text not null,
+    size bigint not null,
+    content_type text not null,
+    checksum text not null,
+    object_key text not null,
+    created_at timestamptz not null,
+    constraint invoice_attachments_size_check check (size > 0),
+    constraint invoice_attachments_tenant_invoice_checksum_key unique (tenant_id, invoice_id, checksum),
+    constraint invoice_attachments_tenant_invoice_fkey
+        foreign key (tenant_id, invoice_id) references invoices (tenant_id, id) on delete cascade
+);
+
+create index if not exists invoice_attachments_tenant_checksum_idx
+    on invoice_attachments (tenant_id, checksum);
@@ -0,0 +1,6 @@
+drop table if exists credit_note_sequences;
+drop index if exists credit_notes_tenant_invoice_idx;
+drop table if exists credit_notes;
+alter table invoices drop column if exists amount_credited;
+alter table invoices drop column if exists void_reason;
+alter table invoices drop column if exists voided_at;
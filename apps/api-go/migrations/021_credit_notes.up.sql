@@ -0,0 +1,35 @@
+-- Voided invoices keep their row; voided_at and void_reason record the
+-- void, and amount_credited sums the invoice's credit notes.
+alter table invoices add column if not exists voided_at timestamptz;
+alter table invoices add column if not exists void_reason text;
+alter table invoices add column if not exists amount_credited bigint not null default 0;
+
+-- Credit notes reverse money received against an invoice. Amounts are
+-- negative; numbers come from credit_note_sequences, one counter per
+-- tenant, advanced in the transaction that issues the note so the
+-- sequence has no gaps.
+create table if not exists credit_notes (
+    id text primary key,
+    tenant_id text not null,
+    invoice_id text not null,
+    number text not null,
+    currency text not null,
+    subtotal bigint not null,
+    tax bigint not null,
+    total bigint not null,
+    reason text,
+    issued_at timestamptz not null,
+    created_at timestamptz not null,
+    constraint credit_notes_total_check check (total < 0 and subtotal <= 0 and tax <= 0 and subtotal + tax = total),
+    constraint credit_notes_tenant_number_key unique (tenant_id, number),
+    constraint credit_notes_tenant_invoice_fkey
+        foreign key (tenant_id, invoice_id) references invoices (tenant_id, id) on delete cascade
+);
+
+create index if not exists credit_notes_tenant_invoice_idx
+    on credit_notes (tenant_id, invoice_id);
+
+create table if not exists credit_note_sequences (
+    tenant_id text primary key,
+    last_value bigint not null
+);
@@ -0,0 +1 @@
+drop table if exists invoice_sequences;
@@ -0,0 +1,11 @@
+-- Invoices created without a number are numbered from invoice_sequences,
+-- one counter per tenant and year, or per tenant with year 0 when numbering
+-- does not restart yearly. The counter row is advanced in the transaction
+-- that creates the invoice, so a rolled back create gives its number back
+-- instead of leaving a gap the way a SEQUENCE would.
+create table if not exists invoice_sequences (
+    tenant_id text not null,
+    year integer not null,
+    last_value bigint not null,
+    primary key (tenant_id, year)
+);
@@ -0,0 +1,2 @@
+drop index if exists invoice_share_links_tenant_invoice_idx;
+drop table if exists invoice_share_links;
@@ -0,0 +1,16 @@
+-- Public links invoices are shared through. The token handed out names the
+-- link's id and expiry and is signed with the server's secret; the row is
+-- what makes it valid, so deleting the rows of an invoice revokes its links.
+create table if not exists invoice_share_links (
+    id text primary key,
+    tenant_id text not null,
+    invoice_id text not null,
+    expires_at timestamptz not null,
+    created_by text not null,
+    created_at timestamptz not null,
+    constraint invoice_share_links_tenant_invoice_fkey
+        foreign key (tenant_id, invoice_id) references invoices (tenant_id, id) on delete cascade
+);
+
+create index if not exists invoice_share_links_tenant_invoice_idx
+    on invoice_share_links (tenant_id, invoice_id);
@@ -0,0 +1,4 @@
+drop index if exists audit_log_created_idx;
+drop index if exists audit_events_created_idx;
+drop index if exists customers_deleted_at_idx;
+drop index if exists invoices_deleted_at_idx;
@@ -0,0 +1,12 @@
+-- The purge job finds records soft-deleted longer than the retention period
+-- ago, and audit entries older than theirs.
+create index if not exists invoices_deleted_at_idx
+    on invoices (deleted_at)
+    where deleted_at is not null;
+create index if not exists customers_deleted_at_idx
+    on customers (deleted_at)
+    where deleted_at is not null;
+create index if not exists audit_events_created_idx
+    on audit_events (created_at);
+create index if not exists audit_log_created_idx
+    on audit_log (created_at);
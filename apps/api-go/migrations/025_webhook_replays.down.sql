@@ -0,0 +1,7 @@
+alter table webhook_deliveries
+    drop constraint if exists webhook_deliveries_tenant_replay_of_fkey;
+alter table webhook_deliveries
+    drop column if exists replay_of;
+drop index if exists webhook_deliveries_tenant_id_idx;
+alter table webhook_delivery_attempts
+    drop column if exists response_snippet;
@@ -0,0 +1,15 @@
+-- Attempts keep the start of the receiver's reply for inspection, and a
+-- replayed delivery points back at the one it re-sends.
+alter table webhook_delivery_attempts
+    add column if not exists response_snippet text;
+
+create unique index if not exists webhook_deliveries_tenant_id_idx
+    on webhook_deliveries (tenant_id, id);
+
+alter table webhook_deliveries
+    add column if not exists replay_of text;
+alter table webhook_deliveries
+    drop constraint if exists webhook_deliveries_tenant_replay_of_fkey;
+alter table webhook_deliveries
+    add constraint webhook_deliveries_tenant_replay_of_fkey
+    foreign key (tenant_id, replay_of) references webhook_deliveries (tenant_id, id);
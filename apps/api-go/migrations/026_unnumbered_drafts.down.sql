@@ -0,0 +1,6 @@
+-- Unnumbered drafts borrow their ID so every invoice has a distinct number
+-- again.
+update invoices set number = id where number = '';
+drop index if exists invoices_tenant_number_idx;
+create unique index if not exists invoices_tenant_number_idx
+    on invoices (tenant_id, number);
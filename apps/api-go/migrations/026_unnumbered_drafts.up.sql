@@ -0,0 +1,6 @@
+-- Drafts get their number when they are issued, so any number of them can
+-- be unnumbered at once.
+drop index if exists invoices_tenant_number_idx;
+create unique index if not exists invoices_tenant_number_idx
+    on invoices (tenant_id, number)
+    where number <> '';
@@ -0,0 +1 @@
+alter table idempotency_keys drop column if exists response_headers;
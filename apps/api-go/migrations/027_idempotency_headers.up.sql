@@ -0,0 +1,2 @@
+-- Replayed responses carry the Location and ETag headers of the first one.
+alter table idempotency_keys add column if not exists response_headers jsonb not null default '{}'::jsonb;
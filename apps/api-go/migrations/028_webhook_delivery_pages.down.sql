@@ -0,0 +1,4 @@
+drop index if exists webhook_deliveries_subscription_created_id_idx;
+
+create index if not exists webhook_deliveries_subscription_created_idx
+    on webhook_deliveries (tenant_id, subscription_id, created_at desc);
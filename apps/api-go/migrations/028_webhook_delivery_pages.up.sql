@@ -0,0 +1,6 @@
+-- Delivery listings page on (created_at, id) like the invoice list, with
+-- the ID compared bytewise so the cursor matches the order served.
+drop index if exists webhook_deliveries_subscription_created_idx;
+
+create index if not exists webhook_deliveries_subscription_created_id_idx
+    on webhook_deliveries (tenant_id, subscription_id, created_at desc, (id collate "C") desc);
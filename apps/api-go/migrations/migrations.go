@@ -0,0 +1,12 @@
+// Package migrations embeds the versioned SQL files that define the Postgres
+// schema, so the binary carries the schema it expects wherever it runs.
+//
+// Files are named NNN_description.up.sql with a matching .down.sql that
+// undoes it. Versions are applied in order and must not be renumbered once
+// released.
+package migrations
+
+import "embed"
+
+//go:embed *.sql
+var FS embed.FS
@@ -5,6 +5,26 @@ export const operations = {
     "method": "GET",
     "path": "/healthz"
   },
+  "getReadyz": {
+    "method": "GET",
+    "path": "/readyz"
+  },
+  "getInfo": {
+    "method": "GET",
+    "path": "/info"
+  },
+  "getMetrics": {
+    "method": "GET",
+    "path": "/metrics"
+  },
+  "getOpenAPI": {
+    "method": "GET",
+    "path": "/openapi.json"
+  },
+  "getDocs": {
+    "method": "GET",
+    "path": "/docs"
+  },
   "login": {
     "method": "POST",
     "path": "/auth/login"
@@ -69,6 +89,10 @@ export const operations = {
     "method": "POST",
     "path": "/v1/collection-jobs/{collectionJobId}/retry"
   },
+  "streamCollectionJobEvents": {
+    "method": "GET",
+    "path": "/v1/collection-jobs/{collectionJobId}/events"
+  },
   "listReports": {
     "method": "GET",
     "path": "/v1/reports"
@@ -112,6 +136,194 @@ export const operations = {
   "listAuditEvents": {
     "method": "GET",
     "path": "/v1/audit-events"
+  },
+  "listInvoices": {
+    "method": "GET",
+    "path": "/v1/invoices"
+  },
+  "createInvoice": {
+    "method": "POST",
+    "path": "/v1/invoices"
+  },
+  "createInvoiceBatch": {
+    "method": "POST",
+    "path": "/v1/invoices/batch"
+  },
+  "importInvoices": {
+    "method": "POST",
+    "path": "/v1/invoices/import"
+  },
+  "getInvoiceSummary": {
+    "method": "GET",
+    "path": "/v1/invoices/summary"
+  },
+  "exportInvoices": {
+    "method": "GET",
+    "path": "/v1/invoices/export"
+  },
+  "searchInvoices": {
+    "method": "GET",
+    "path": "/v1/invoices/search"
+  },
+  "getInvoice": {
+    "method": "GET",
+    "path": "/v1/invoices/{invoiceId}"
+  },
+  "updateInvoice": {
+    "method": "PATCH",
+    "path": "/v1/invoices/{invoiceId}"
+  },
+  "deleteInvoice": {
+    "method": "DELETE",
+    "path": "/v1/invoices/{invoiceId}"
+  },
+  "getInvoiceHistory": {
+    "method": "GET",
+    "path": "/v1/invoices/{invoiceId}/history"
+  },
+  "getInvoicePdf": {
+    "method": "GET",
+    "path": "/v1/invoices/{invoiceId}/pdf"
+  },
+  "addInvoiceLineItem": {
+    "method": "POST",
+    "path": "/v1/invoices/{invoiceId}/items"
+  },
+  "deleteInvoiceLineItem": {
+    "method": "DELETE",
+    "path": "/v1/invoices/{invoiceId}/items/{itemId}"
+  },
+  "listInvoicePayments": {
+    "method": "GET",
+    "path": "/v1/invoices/{invoiceId}/payments"
+  },
+  "recordInvoicePayment": {
+    "method": "POST",
+    "path": "/v1/invoices/{invoiceId}/payments"
+  },
+  "payInvoice": {
+    "method": "POST",
+    "path": "/v1/invoices/{invoiceId}/pay"
+  },
+  "duplicateInvoice": {
+    "method": "POST",
+    "path": "/v1/invoices/{invoiceId}/duplicate"
+  },
+  "shareInvoice": {
+    "method": "POST",
+    "path": "/v1/invoices/{invoiceId}/share"
+  },
+  "revokeInvoiceShares": {
+    "method": "DELETE",
+    "path": "/v1/invoices/{invoiceId}/share"
+  },
+  "voidInvoice": {
+    "method": "POST",
+    "path": "/v1/invoices/{invoiceId}/void"
+  },
+  "createInvoiceCreditNote": {
+    "method": "POST",
+    "path": "/v1/invoices/{invoiceId}/credit-note"
+  },
+  "listInvoiceCreditNotes": {
+    "method": "GET",
+    "path": "/v1/invoices/{invoiceId}/credit-notes"
+  },
+  "getInvoiceCreditNote": {
+    "method": "GET",
+    "path": "/v1/invoices/{invoiceId}/credit-notes/{creditNoteId}"
+  },
+  "getInvoiceCreditNotePdf": {
+    "method": "GET",
+    "path": "/v1/invoices/{invoiceId}/credit-notes/{creditNoteId}/pdf"
+  },
+  "remindInvoice": {
+    "method": "POST",
+    "path": "/v1/invoices/{invoiceId}/remind"
+  },
+  "listInvoiceAttachments": {
+    "method": "GET",
+    "path": "/v1/invoices/{invoiceId}/attachments"
+  },
+  "uploadInvoiceAttachment": {
+    "method": "POST",
+    "path": "/v1/invoices/{invoiceId}/attachments"
+  },
+  "getInvoiceAttachment": {
+    "method": "GET",
+    "path": "/v1/invoices/{invoiceId}/attachments/{attachmentId}"
+  },
+  "downloadInvoiceAttachment": {
+    "method": "GET",
+    "path": "/v1/invoices/{invoiceId}/attachments/{attachmentId}/download"
+  },
+  "listWebhooks": {
+    "method": "GET",
+    "path": "/v1/webhooks"
+  },
+  "createWebhook": {
+    "method": "POST",
+    "path": "/v1/webhooks"
+  },
+  "listWebhookDeliveries": {
+    "method": "GET",
+    "path": "/v1/webhooks/{webhookId}/deliveries"
+  },
+  "replayWebhookDelivery": {
+    "method": "POST",
+    "path": "/v1/webhooks/deliveries/{deliveryId}/replay"
+  },
+  "listCustomers": {
+    "method": "GET",
+    "path": "/v1/customers"
+  },
+  "createCustomer": {
+    "method": "POST",
+    "path": "/v1/customers"
+  },
+  "getCustomer": {
+    "method": "GET",
+    "path": "/v1/customers/{customerId}"
+  },
+  "updateCustomer": {
+    "method": "PATCH",
+    "path": "/v1/customers/{customerId}"
+  },
+  "deleteCustomer": {
+    "method": "DELETE",
+    "path": "/v1/customers/{customerId}"
+  },
+  "listRecurringInvoices": {
+    "method": "GET",
+    "path": "/v1/recurring-invoices"
+  },
+  "createRecurringInvoice": {
+    "method": "POST",
+    "path": "/v1/recurring-invoices"
+  },
+  "getRecurringInvoice": {
+    "method": "GET",
+    "path": "/v1/recurring-invoices/{recurringInvoiceId}"
+  },
+  "pauseRecurringInvoice": {
+    "method": "POST",
+    "path": "/v1/recurring-invoices/{recurringInvoiceId}/pause"
+  },
+  "resumeRecurringInvoice": {
+    "method": "POST",
+    "path": "/v1/recurring-invoices/{recurringInvoiceId}/resume"
+  },
+  "receivePaymentWebhook": {
+    "method": "POST",
+    "path": "/v1/payments/webhooks/{provider}"
+  },
+  "getPublicInvoice": {
+    "method": "GET",
+    "path": "/v1/public/invoices/{token}"
+  },
+  "getPublicInvoicePdf": {
+    "method": "GET",
+    "path": "/v1/public/invoices/{token}/pdf"
   }
 } as const;
 
@@ -24,6 +24,7 @@ export type CollectionJob = {
   id: string;
   tenantId: string;
   status: "queued" | "running" | "succeeded" | "failed";
+  attempt?: number;
   providers: Array<"gmail" | "outlook">;
   month: number;
   year: number;
@@ -32,6 +33,7 @@ export type CollectionJob = {
   invoicesDir?: string;
   retryOf?: string;
   error?: string;
+  queuedAt?: string;
   startedAt?: string;
   finishedAt?: string;
   createdAt: string;